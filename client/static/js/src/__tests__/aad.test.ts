@@ -13,6 +13,7 @@ import { describe, test, expect } from 'bun:test';
 import {
   AAD_FIELD_FILENAME,
   AAD_FIELD_SHA256,
+  AAD_FIELD_FOLDER,
   buildChunkAAD,
   buildFEKEnvelopeAAD,
   buildMetadataFieldAAD,
@@ -186,4 +187,8 @@ describe('AAD field-label constants', () => {
   test('AAD_FIELD_SHA256 is the exact canonical string', () => {
     expect(AAD_FIELD_SHA256).toBe('encrypted_sha256sum');
   });
+
+  test('AAD_FIELD_FOLDER is the exact canonical string', () => {
+    expect(AAD_FIELD_FOLDER).toBe('encrypted_folder');
+  });
 });
@@ -40,16 +40,20 @@
 // Canonical AAD field-label constants for metadata encryption.
 //
 // These strings are permanent wire-format commitments:
-// changing either value would invalidate every existing file's metadata
+// changing any value would invalidate every existing file's metadata
 // AAD. They are AAD labels only -- they are NOT renames of any DB column
 // or API field. The existing schema and API field names remain
-// "encrypted_filename" and "encrypted_sha256sum" (which is also why these
-// are the chosen label strings -- the AAD label tracks the stored field's
-// name verbatim).
+// "encrypted_filename", "encrypted_sha256sum", "encrypted_folder",
+// "encrypted_tags" and "encrypted_note" (which is also why these are the
+// chosen label strings -- the AAD label tracks the stored field's name
+// verbatim).
 //
 // Callers of buildMetadataFieldAAD MUST reference these constants.
 export const AAD_FIELD_FILENAME = 'encrypted_filename';
 export const AAD_FIELD_SHA256 = 'encrypted_sha256sum';
+export const AAD_FIELD_FOLDER = 'encrypted_folder';
+export const AAD_FIELD_TAGS = 'encrypted_tags';
+export const AAD_FIELD_NOTE = 'encrypted_note';
 
 // uint64 wire-format upper bound (2^64 - 1). bigint values outside [0, MAX_U64]
 // are rejected before encoding, since the wire format is a fixed 8-byte BE uint.
@@ -126,17 +130,17 @@ export function buildFEKEnvelopeAAD(
 }
 
 /**
- * Constructs the AAD for an encrypted metadata field (filename or
- * original-plaintext SHA-256 digest).
+ * Constructs the AAD for an encrypted metadata field (filename,
+ * original-plaintext SHA-256 digest, folder path, tags or note).
  *
  * Binding fileID prevents moving a metadata row to a different file.
- * Binding fieldName prevents substituting encrypted_filename ciphertext
- * into the encrypted_sha256sum slot or vice versa.
+ * Binding fieldName prevents substituting one field's ciphertext into
+ * another field's slot (e.g. encrypted_filename into encrypted_folder).
  * Binding ownerUsername prevents moving a metadata row to a different
  * user's account.
  *
- * fieldName MUST be one of the canonical constants: AAD_FIELD_FILENAME or
- * AAD_FIELD_SHA256.
+ * fieldName MUST be one of the canonical constants: AAD_FIELD_FILENAME,
+ * AAD_FIELD_SHA256, AAD_FIELD_FOLDER, AAD_FIELD_TAGS or AAD_FIELD_NOTE.
  */
 export function buildMetadataFieldAAD(
   fileID: string,
@@ -40,7 +40,8 @@ export interface ServerFileEntry {
   /** Canonical owner_username (required for metadata-field AAD). */
   owner_username: string;
   storage_id: string;
-  password_type: 'account' | 'custom';
+  /** 'drop' until a file received through a file drop is accepted with arkfile-client. */
+  password_type: 'account' | 'custom' | 'drop';
   password_hint?: string;
   encrypted_filename: string;
   filename_nonce: string;
@@ -65,7 +66,7 @@ export interface FilesResponse {
 /** A file entry after client-side metadata decryption */
 interface DecryptedFileEntry {
   file_id: string;
-  password_type: 'account' | 'custom';
+  password_type: 'account' | 'custom' | 'drop';
   password_hint: string;
   filename: string;        // decrypted or "[Encrypted]"
   sha256sum: string;       // decrypted hex or ""
@@ -154,14 +155,15 @@ export async function displayFiles(data: FilesResponse): Promise<void> {
       file_id: file.file_id,
       password_type: file.password_type,
       password_hint: file.password_hint || '',
-      filename: '[Encrypted]',
+      filename: file.password_type === 'drop' ? '[Received via file drop]' : '[Encrypted]',
       sha256sum: '',
       size_readable: file.size_readable,
       upload_date: file.upload_date,
       metadata_decrypted: false,
     };
 
-    if (accountKey) {
+    // File drop uploads are encrypted to the drop key, not the account key
+    if (accountKey && file.password_type !== 'drop') {
       try {
         entry.filename = await decryptMetadataField(
           file.encrypted_filename,
@@ -216,7 +218,7 @@ export async function displayFiles(data: FilesResponse): Promise<void> {
     typeEl.className = file.password_type === 'account'
       ? 'encryption-type encryption-type-account'
       : 'encryption-type encryption-type-custom';
-    typeEl.textContent = file.password_type === 'account' ? 'Account Password' : 'Custom Password';
+    typeEl.textContent = encryptionLabel(file.password_type);
 
     fileInfo.appendChild(nameEl);
     fileInfo.appendChild(sizeEl);
@@ -270,14 +272,17 @@ export async function displayFiles(data: FilesResponse): Promise<void> {
     const deleteBtn = document.createElement('button');
     deleteBtn.textContent = 'Delete';
     deleteBtn.className = 'danger-button';
-    deleteBtn.title = 'Permanently delete this file from the server';
+    deleteBtn.title = 'Move this file to the trash';
     deleteBtn.addEventListener('click', () => {
       confirmAndDeleteFile(file.file_id, file.filename);
     });
 
-    fileActions.appendChild(downloadBtn);
-    fileActions.appendChild(shareBtn);
-    fileActions.appendChild(exportBtn);
+    // A file drop upload can only be deleted here until it is accepted
+    if (file.password_type !== 'drop') {
+      fileActions.appendChild(downloadBtn);
+      fileActions.appendChild(shareBtn);
+      fileActions.appendChild(exportBtn);
+    }
     fileActions.appendChild(deleteBtn);
     fileElement.appendChild(fileInfo);
     fileElement.appendChild(fileActions);
@@ -288,6 +293,18 @@ export async function displayFiles(data: FilesResponse): Promise<void> {
   updateStorageInfo(data.storage);
 }
 
+/** Human-readable name of a file's password type. */
+function encryptionLabel(passwordType: DecryptedFileEntry['password_type']): string {
+  switch (passwordType) {
+    case 'account':
+      return 'Account Password';
+    case 'custom':
+      return 'Custom Password';
+    case 'drop':
+      return 'File Drop (accept with arkfile-client)';
+  }
+}
+
 // ============================================================================
 // Storage Info
 // ============================================================================
@@ -343,10 +360,9 @@ async function fetchAdminContactForStorage(): Promise<void> {
 async function confirmAndDeleteFile(fileId: string, filename: string): Promise<void> {
   const displayName = filename === '[Encrypted]' ? fileId : filename;
   const confirmed = window.confirm(
-    `Are you sure you want to permanently delete "${displayName}"?\n\n` +
-    `This action cannot be undone.\n\n` +
-    `Consider using "Export Backup" first to save an offline-decryptable ` +
-    `copy (.arkbackup) before deleting, if this file is important.`
+    `Move "${displayName}" to the trash?\n\n` +
+    `It can be restored with "arkfile-client restore" until the server ` +
+    `purges it; it keeps using storage until then.`
   );
 
   if (!confirmed) return;
@@ -357,7 +373,9 @@ async function confirmAndDeleteFile(fileId: string, filename: string): Promise<v
     });
 
     if (response.ok) {
-      showSuccess(`File deleted: ${displayName}`);
+      // "File moved to trash", or "File deleted successfully" when the server's trash is disabled
+      const data = await response.json().catch(() => ({}));
+      showSuccess(`${data.message || 'File deleted'}: ${displayName}`);
       await loadFiles();
     } else {
       const data = await response.json().catch(() => ({}));
@@ -420,7 +438,7 @@ function showMetadataModal(file: DecryptedFileEntry): void {
   modal.appendChild(makeRow('Filename', file.filename));
   modal.appendChild(makeRow('Size', file.size_readable));
   modal.appendChild(makeRow('Uploaded', new Date(file.upload_date).toLocaleString()));
-  modal.appendChild(makeRow('Encryption', file.password_type === 'account' ? 'Account Password' : 'Custom Password'));
+  modal.appendChild(makeRow('Encryption', encryptionLabel(file.password_type)));
   if (file.sha256sum) {
     modal.appendChild(makeRow('SHA-256', file.sha256sum, true, true));
   }
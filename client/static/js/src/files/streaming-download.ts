@@ -270,11 +270,12 @@ export class StreamingDownloadManager {
    * Share recipients do NOT have the owner's account key, so server-side
    * encrypted metadata cannot be decrypted here. Filename/sha256 come from
    * the ShareEnvelope (decrypted by the caller using the share password).
+   * For a share bundle, `shareMetadata.fileId` names the member to download.
    */
   async downloadSharedFile(
     shareId: string,
     fek: Uint8Array,
-    shareMetadata?: { filename?: string | undefined; sha256?: string | undefined },
+    shareMetadata?: { filename?: string | undefined; sha256?: string | undefined; fileId?: string | undefined },
   ): Promise<StreamingDownloadResult> {
     const t0 = Date.now();
     console.log(`${LOG_PREFIX_SHARE} Starting shared download (shareId hash=${shortHash(shareId)})`);
@@ -289,14 +290,15 @@ export class StreamingDownloadManager {
       this.reportProgress('metadata', 0, 0, 0, 0);
 
       const tMeta = Date.now();
-      const metadata = await this.fetchShareMetadata(shareId);
+      const fileQuery = shareMetadata?.fileId ? `?file_id=${encodeURIComponent(shareMetadata.fileId)}` : '';
+      const metadata = await this.fetchShareMetadata(shareId, fileQuery);
       console.log(`${LOG_PREFIX_SHARE} Metadata fetched in ${Date.now() - tMeta}ms (chunk_count=${metadata.chunk_count}, size_bytes=${metadata.size_bytes})`);
 
       const filename = shareMetadata?.filename ?? 'shared-file';
       const sha256sum = shareMetadata?.sha256;
 
       const tStream = Date.now();
-      const generator = this.makeShareChunkGenerator(shareId, metadata, fek);
+      const generator = this.makeShareChunkGenerator(shareId, metadata, fek, fileQuery);
       const streamResult = await this.streamDecryptedChunks(
         generator,
         metadata.chunk_count,
@@ -419,6 +421,7 @@ export class StreamingDownloadManager {
     shareId: string,
     metadata: ChunkedDownloadMetadata,
     fek: Uint8Array,
+    fileQuery: string = '',
   ): AsyncGenerator<Uint8Array> {
     await this.ensureConfig();
     const decryptor = await AESGCMDecryptor.fromRawKey(fek);
@@ -436,7 +439,7 @@ export class StreamingDownloadManager {
 
       const tFetch = Date.now();
       const encryptedChunk = await downloadChunkWithRetry(
-        `${this.baseUrl}/api/public/shares/${shareId}/chunks/${chunkIndex}`,
+        `${this.baseUrl}/api/public/shares/${shareId}/chunks/${chunkIndex}${fileQuery}`,
         headers,
         this.options.retryConfig,
         (attempt, error, delay) => {
@@ -603,10 +606,10 @@ export class StreamingDownloadManager {
   }
 
   /** Fetch download metadata for a shared file */
-  private async fetchShareMetadata(shareId: string): Promise<ChunkedDownloadMetadata> {
+  private async fetchShareMetadata(shareId: string, fileQuery: string = ''): Promise<ChunkedDownloadMetadata> {
     const headers: Record<string, string> = { 'Content-Type': 'application/json' };
     if (this.options.downloadToken) headers['X-Download-Token'] = this.options.downloadToken;
-    const response = await fetch(`${this.baseUrl}/api/public/shares/${shareId}/metadata`, { method: 'GET', headers });
+    const response = await fetch(`${this.baseUrl}/api/public/shares/${shareId}/metadata${fileQuery}`, { method: 'GET', headers });
     if (!response.ok) {
       console.error(`${LOG_PREFIX_SHARE} Metadata fetch failed: HTTP ${response.status} ${response.statusText}`);
       throw new Error(`Failed to fetch share metadata: ${response.status} ${response.statusText}`);
@@ -678,7 +681,7 @@ export async function downloadSharedFileChunked(
   shareId: string,
   fek: Uint8Array,
   downloadToken: string,
-  shareMetadata?: { filename?: string | undefined; sha256?: string | undefined },
+  shareMetadata?: { filename?: string | undefined; sha256?: string | undefined; fileId?: string | undefined },
   options: Partial<StreamingDownloadOptions> = {},
 ): Promise<StreamingDownloadResult> {
   const manager = new StreamingDownloadManager('', { downloadToken, ...options });
@@ -18,7 +18,7 @@
  * construction and we trigger the download from a blob URL here.
  */
 
-import { shareCrypto } from './share-crypto';
+import { shareCrypto, DecryptedShareBundleFile } from './share-crypto';
 import { showError, showWarning } from '../ui/messages';
 import { isSwAvailable } from '../files/sw-streaming-download';
 import {
@@ -34,6 +34,13 @@ interface ShareEnvelope {
   salt: string;
   encrypted_envelope: string;
   size_bytes: number;
+  files?: ShareEnvelopeFile[]; // members of a share bundle
+}
+
+interface ShareEnvelopeFile {
+  file_id: string;
+  size_bytes: number;
+  available: boolean;
 }
 
 export class ShareAccessUI {
@@ -75,6 +82,12 @@ export class ShareAccessUI {
         <button id="downloadBtn" class="btn primary">Download</button>
         <a id="swDownloadAnywayLink" href="#" style="display:none; font-size:0.9em; margin-left:0.5rem;">Download anyway</a>
       </div>
+
+      <div id="bundleDetails" class="hidden">
+        <h3>Shared Files</h3>
+        <ul id="bundleFileList"></ul>
+        <button id="downloadAllBtn" class="btn primary">Download all</button>
+      </div>
     `;
 
     const form = document.getElementById('shareAccessForm') as HTMLFormElement;
@@ -167,6 +180,13 @@ export class ShareAccessUI {
       // Store the Download Token for later use
       this.downloadToken = decryptedEnvelope.downloadToken;
 
+      // A share bundle lists several files, each with its own FEK
+      if (decryptedEnvelope.files) {
+        this.showBundleDetails(decryptedEnvelope.files);
+        if (statusDiv) statusDiv.className = 'hidden';
+        return;
+      }
+
       // 3. Get filename from the decrypted ShareEnvelope metadata
       // Share recipients cannot decrypt server-side encrypted_filename because
       // it's encrypted with the owner's account key. Instead, the filename
@@ -256,10 +276,61 @@ export class ShareAccessUI {
     }
   }
 
+  /**
+   * Lists the files of a share bundle with a Download button for each and a
+   * "Download all" button that fetches the available files one after another.
+   * Files the owner has moved to the trash are listed but cannot be downloaded.
+   */
+  private showBundleDetails(files: DecryptedShareBundleFile[]): void {
+    const form = document.getElementById('shareAccessForm');
+    const details = document.getElementById('bundleDetails');
+    const list = document.getElementById('bundleFileList');
+    const downloadAllBtn = document.getElementById('downloadAllBtn') as HTMLButtonElement | null;
+
+    if (form) form.classList.add('hidden');
+    if (details) details.classList.remove('hidden');
+
+    const serverFiles = new Map((this.envelope?.files ?? []).map((f) => [f.file_id, f]));
+    const available = files.filter((f) => serverFiles.get(f.fileId)?.available);
+
+    files.forEach((file, i) => {
+      // Folder bundles name files by relative path; the browser saves them flat
+      const filename = file.filename || `shared-file-${i + 1}`;
+      const serverFile = serverFiles.get(file.fileId);
+      const item = document.createElement('li');
+      item.textContent = `${filename} (${this.formatBytes(serverFile?.size_bytes ?? file.sizeBytes ?? 0)}) `;
+      if (serverFile?.available) {
+        const button = document.createElement('button');
+        button.className = 'btn';
+        button.textContent = 'Download';
+        button.onclick = () => {
+          this.downloadFile(filename.replace(/\//g, '_'), file.fek, file.sha256, file.fileId);
+        };
+        item.appendChild(button);
+      } else {
+        item.appendChild(document.createTextNode('(unavailable)'));
+      }
+      list?.appendChild(item);
+    });
+
+    if (downloadAllBtn) {
+      downloadAllBtn.disabled = available.length === 0;
+      downloadAllBtn.onclick = async () => {
+        downloadAllBtn.disabled = true;
+        for (const file of available) {
+          const filename = (file.filename || 'shared-file').replace(/\//g, '_');
+          await this.downloadFile(filename, file.fek, file.sha256, file.fileId);
+        }
+        downloadAllBtn.disabled = false;
+      };
+    }
+  }
+
   private async downloadFile(
     filename: string,
     fek: Uint8Array,
     sha256?: string,
+    fileId?: string,
   ): Promise<void> {
     const statusDiv = document.getElementById('shareStatus');
 
@@ -280,7 +351,7 @@ export class ShareAccessUI {
         this.shareId,
         fek,
         this.downloadToken,
-        { filename, sha256 },
+        { filename, sha256, fileId },
         {
           showProgressUI: true,
           onProgress: (progress: { stage: string; percentage: number; error?: string | undefined }) => {
@@ -90,12 +90,24 @@ export async function validateSharePasswordStrength(
  * The share recipient decrypts it to get the FEK, download token, and file metadata.
  */
 interface ShareEnvelopeJSON {
-  fek: string;            // base64-encoded FEK
+  fek?: string;           // base64-encoded FEK (single-file shares)
   download_token: string; // base64-encoded Download Token
   filename?: string;      // plaintext filename (for preview before download)
   size_bytes?: number;    // file size in bytes (for preview before download)
   sha256?: string;        // plaintext SHA256 hex digest (for post-download verification)
   kdf_params?: ShareKDFParamsEmbedded; // embedded KDF params for verification
+  files?: ShareBundleEntryJSON[]; // one entry per file (share bundles, instead of fek)
+}
+
+/**
+ * One file of a share bundle envelope (matches Go's crypto.ShareBundleEntry)
+ */
+interface ShareBundleEntryJSON {
+  file_id: string;
+  fek: string;            // base64-encoded FEK of this file
+  filename?: string;      // relative path inside the bundle
+  size_bytes?: number;
+  sha256?: string;
 }
 
 /**
@@ -239,8 +251,11 @@ export async function encryptFEKForShare(
  * Includes FEK, download token, and optional file metadata
  */
 export interface DecryptedShareEnvelope {
+  /** FEK of a single-file share; empty for a bundle (see files) */
   fek: Uint8Array;
   downloadToken: string;
+  /** The files of a share bundle, in share order */
+  files?: DecryptedShareBundleFile[];
   /** File metadata from the envelope (available if included during share creation) */
   metadata?: {
     filename?: string;
@@ -249,6 +264,17 @@ export interface DecryptedShareEnvelope {
   };
 }
 
+/**
+ * One file of a decrypted share bundle
+ */
+export interface DecryptedShareBundleFile {
+  fileId: string;
+  fek: Uint8Array;
+  filename?: string;
+  sizeBytes?: number;
+  sha256?: string;
+}
+
 /**
  * Decrypts a Share Envelope to extract FEK, Download Token, and file metadata
  * 
@@ -342,8 +368,9 @@ export async function decryptShareEnvelope(
       throw new DecryptionError('Invalid share envelope format: not valid JSON');
     }
     
-    // Validate required fields
-    if (!envelope.fek || !envelope.download_token) {
+    // Validate required fields (a bundle carries files instead of fek)
+    const isBundle = Array.isArray(envelope.files) && envelope.files.length > 0;
+    if ((!envelope.fek && !isBundle) || !envelope.download_token) {
       throw new DecryptionError('Invalid share envelope: missing fek or download_token');
     }
 
@@ -354,16 +381,33 @@ export async function decryptShareEnvelope(
     await validateAgainstFloor(envelope.kdf_params);
     
     // Decode FEK from base64
-    const fek = fromBase64(envelope.fek);
-    if (fek.length !== KEY_SIZES.FILE_ENCRYPTION_KEY) {
-      throw new DecryptionError(
-        `Invalid FEK size in envelope: expected ${KEY_SIZES.FILE_ENCRYPTION_KEY} bytes, got ${fek.length}`
-      );
+    const decodeEnvelopeFEK = (fekBase64: string): Uint8Array => {
+      const fek = fromBase64(fekBase64);
+      if (fek.length !== KEY_SIZES.FILE_ENCRYPTION_KEY) {
+        throw new DecryptionError(
+          `Invalid FEK size in envelope: expected ${KEY_SIZES.FILE_ENCRYPTION_KEY} bytes, got ${fek.length}`
+        );
+      }
+      return fek;
+    };
+
+    if (isBundle) {
+      const files: DecryptedShareBundleFile[] = envelope.files!.map((entry) => {
+        if (!entry.file_id || !entry.fek) {
+          throw new DecryptionError('Invalid share envelope: bundle entry missing file_id or fek');
+        }
+        const file: DecryptedShareBundleFile = { fileId: entry.file_id, fek: decodeEnvelopeFEK(entry.fek) };
+        if (entry.filename) file.filename = entry.filename;
+        if (entry.size_bytes) file.sizeBytes = entry.size_bytes;
+        if (entry.sha256) file.sha256 = entry.sha256;
+        return file;
+      });
+      return { fek: new Uint8Array(0), downloadToken: envelope.download_token, files };
     }
-    
+
     // Build result with optional metadata
     const result: DecryptedShareEnvelope = {
-      fek,
+      fek: decodeEnvelopeFEK(envelope.fek!),
       downloadToken: envelope.download_token,
     };
     
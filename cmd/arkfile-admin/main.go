@@ -75,6 +75,11 @@ STORAGE MANAGEMENT COMMANDS (Admin API):
     swap-providers        Swap primary and secondary provider roles
     set-cost              Set monthly cost per TB for a provider
     verify-all            Verify all file locations via HEAD requests (detect missing/corrupt blobs)
+    repair-storage        Re-copy under-replicated files from a verified-good provider
+    drain-provider        Move all files off a provider, then delete them there and deactivate it
+    tier-storage          Move files not downloaded in N days from the primary to the cheapest provider
+    tiering-report        Show projected monthly savings from tier-storage
+    placement-policy      Per-user placement rules: list | set | delete | assign
 
 BILLING COMMANDS (storage credits / usage metering):
     billing show                          Show current price + last 30 days of sweep activity
@@ -440,6 +445,31 @@ func main() {
 			logError("Verify all failed: %v", err)
 			os.Exit(1)
 		}
+	case "repair-storage":
+		if err := handleRepairStorageCommand(client, config, args); err != nil {
+			logError("Repair storage failed: %v", err)
+			os.Exit(1)
+		}
+	case "drain-provider":
+		if err := handleDrainProviderCommand(client, config, args); err != nil {
+			logError("Drain provider failed: %v", err)
+			os.Exit(1)
+		}
+	case "tier-storage":
+		if err := handleTierStorageCommand(client, config, args); err != nil {
+			logError("Tier storage failed: %v", err)
+			os.Exit(1)
+		}
+	case "tiering-report":
+		if err := handleTieringReportCommand(client, config, args); err != nil {
+			logError("Tiering report failed: %v", err)
+			os.Exit(1)
+		}
+	case "placement-policy":
+		if err := handlePlacementPolicyCommand(client, config, args); err != nil {
+			logError("Placement policy command failed: %v", err)
+			os.Exit(1)
+		}
 
 	// Billing - storage credits / usage metering subcommand group.
 	// All subcommands live in cmd/arkfile-admin/billing_commands.go.
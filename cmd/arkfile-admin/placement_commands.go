@@ -0,0 +1,276 @@
+package main
+
+// Placement policy subcommand group for arkfile-admin. The top-level command
+// `arkfile-admin placement-policy` dispatches to one of:
+//
+//   list     - GET /api/admin/storage/placement-policies
+//   set      - POST /api/admin/storage/placement-policies
+//   delete   - DELETE /api/admin/storage/placement-policies/:name
+//   assign   - PUT /api/admin/users/:username/placement-policy
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// handlePlacementPolicyCommand is the top-level dispatcher for
+// `arkfile-admin placement-policy ...`.
+func handlePlacementPolicyCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	if len(args) == 0 {
+		printPlacementPolicyUsage()
+		return fmt.Errorf("placement-policy requires a subcommand")
+	}
+	sub := args[0]
+	rest := args[1:]
+
+	switch sub {
+	case "list":
+		return handlePlacementPolicyListCommand(client, config, rest)
+	case "set":
+		return handlePlacementPolicySetCommand(client, config, rest)
+	case "delete":
+		return handlePlacementPolicyDeleteCommand(client, config, rest)
+	case "assign":
+		return handlePlacementPolicyAssignCommand(client, config, rest)
+	case "help", "--help", "-h":
+		printPlacementPolicyUsage()
+		return nil
+	default:
+		printPlacementPolicyUsage()
+		return fmt.Errorf("unknown placement-policy subcommand: %s", sub)
+	}
+}
+
+func printPlacementPolicyUsage() {
+	fmt.Print(`Usage: arkfile-admin placement-policy SUBCOMMAND [FLAGS]
+
+Per-user storage placement rules (data residency, extra copies). A policy
+lists the providers a user's files may be stored on, in order, and how many
+verified copies each file needs. New uploads land on the first available
+provider and are replicated to the next ones before the upload completes.
+Copy tasks never copy a file outside its owner's policy, and repair-storage
+and drain-provider only create copies on the policy's providers.
+
+SUBCOMMANDS:
+    list       List placement policies and how many users each is assigned to.
+    set        Create or replace a placement policy.
+    delete     Delete a placement policy that is no longer assigned.
+    assign     Assign a policy to a user (or clear it with --policy "").
+
+EXAMPLES:
+    arkfile-admin placement-policy set --name eu --providers hetzner-fsn1,cloudflare-r2-eu --copies 2
+    arkfile-admin placement-policy set --name premium --providers wasabi-us-central-1,backblaze-us-west,hetzner-fsn1 --copies 3
+    arkfile-admin placement-policy assign --username alice --policy eu
+    arkfile-admin placement-policy list
+    arkfile-admin repair-storage     # add copies required by newly assigned policies
+`)
+}
+
+// handlePlacementPolicyListCommand lists every placement policy.
+func handlePlacementPolicyListCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("placement-policy list", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Emit JSON instead of formatted text")
+	fs.Usage = func() {
+		fmt.Print(`Usage: arkfile-admin placement-policy list [--json]
+
+FLAGS:
+    --json     Emit machine-readable JSON.
+    --help     Show this help message.
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	resp, err := client.makeRequest("GET", "/api/admin/storage/placement-policies", nil, session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to list placement policies: %w", err)
+	}
+	if *jsonOut {
+		return printJSON(resp.Data)
+	}
+
+	policies, _ := resp.Data["policies"].([]interface{})
+	if len(policies) == 0 {
+		fmt.Println("No placement policies defined. All users follow the server-wide write policy.")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-7s %-7s %s\n", "NAME", "COPIES", "USERS", "PROVIDERS")
+	for _, raw := range policies {
+		p, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var providers []string
+		if ids, ok := p["provider_ids"].([]interface{}); ok {
+			for _, id := range ids {
+				providers = append(providers, fmt.Sprint(id))
+			}
+		}
+		fmt.Printf("%-20s %-7d %-7d %s\n", safeString(p, "name"), safeInt64(p, "copies"),
+			safeInt64(p, "user_count"), strings.Join(providers, ", "))
+		if desc := safeString(p, "description"); desc != "" {
+			fmt.Printf("    %s\n", desc)
+		}
+	}
+	return nil
+}
+
+// handlePlacementPolicySetCommand creates or replaces a placement policy.
+func handlePlacementPolicySetCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("placement-policy set", flag.ExitOnError)
+	name := fs.String("name", "", "Policy name (required)")
+	providers := fs.String("providers", "", "Comma-separated provider IDs in placement order (required)")
+	copies := fs.Int("copies", 1, "Verified copies per file")
+	description := fs.String("description", "", "Free-text description (optional)")
+	fs.Usage = func() {
+		fmt.Print(`Usage: arkfile-admin placement-policy set --name NAME --providers ID[,ID...] [--copies N] [--description TEXT]
+
+Create or replace a placement policy. Uploads land on the first listed
+provider that is configured and active; replicas go to the next ones until
+--copies copies exist. If fewer providers are available than --copies, uploads
+by users on the policy are refused instead of being stored elsewhere.
+
+Changing a policy applies to new uploads and later copy/repair/drain tasks;
+existing copies are not moved.
+
+FLAGS:
+    --name NAME          Policy name (required)
+    --providers IDS      Comma-separated provider IDs in placement order (required)
+    --copies N           Verified copies per file (default 1, at most the number of providers)
+    --description TEXT   Free-text description (optional)
+    --help               Show this help message
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || *providers == "" {
+		return fmt.Errorf("--name and --providers are required")
+	}
+
+	var providerIDs []string
+	for _, id := range strings.Split(*providers, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			providerIDs = append(providerIDs, id)
+		}
+	}
+	if *copies < 1 || *copies > len(providerIDs) {
+		return fmt.Errorf("--copies must be between 1 and %d (the number of providers)", len(providerIDs))
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	payload := map[string]interface{}{
+		"name":         *name,
+		"provider_ids": providerIDs,
+		"copies":       *copies,
+		"description":  *description,
+	}
+	if _, err := client.makeRequest("POST", "/api/admin/storage/placement-policies", payload, session.AccessToken); err != nil {
+		return fmt.Errorf("failed to save placement policy: %w", err)
+	}
+
+	fmt.Printf("Placement policy %s saved (copies: %d, providers: %s)\n", *name, *copies, strings.Join(providerIDs, ", "))
+	return nil
+}
+
+// handlePlacementPolicyDeleteCommand deletes an unassigned placement policy.
+func handlePlacementPolicyDeleteCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("placement-policy delete", flag.ExitOnError)
+	name := fs.String("name", "", "Policy name (required)")
+	fs.Usage = func() {
+		fmt.Print(`Usage: arkfile-admin placement-policy delete --name NAME
+
+Delete a placement policy. Fails while any user is still assigned to it.
+
+FLAGS:
+    --name NAME    Policy name (required)
+    --help         Show this help message
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	if _, err := client.makeRequest("DELETE", "/api/admin/storage/placement-policies/"+*name, nil, session.AccessToken); err != nil {
+		return fmt.Errorf("failed to delete placement policy: %w", err)
+	}
+
+	fmt.Printf("Placement policy %s deleted\n", *name)
+	return nil
+}
+
+// handlePlacementPolicyAssignCommand assigns a placement policy to a user.
+func handlePlacementPolicyAssignCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("placement-policy assign", flag.ExitOnError)
+	username := fs.String("username", "", "User to assign (required)")
+	policy := fs.String("policy", "", "Policy name; empty clears the assignment")
+	fs.Usage = func() {
+		fmt.Print(`Usage: arkfile-admin placement-policy assign --username USER --policy NAME
+
+Assign a placement policy to a user. Pass --policy "" to return the user to the
+server-wide write policy. Files the user already stored keep their current
+copies; run 'arkfile-admin repair-storage' to add the copies the policy requires.
+
+FLAGS:
+    --username USER    User to assign (required)
+    --policy NAME      Policy name; empty clears the assignment
+    --help             Show this help message
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("--username is required")
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	payload := map[string]interface{}{"policy": *policy}
+	if _, err := client.makeRequest("PUT", "/api/admin/users/"+*username+"/placement-policy", payload, session.AccessToken); err != nil {
+		return fmt.Errorf("failed to assign placement policy: %w", err)
+	}
+
+	if *policy == "" {
+		fmt.Printf("Placement policy cleared for %s\n", *username)
+	} else {
+		fmt.Printf("User %s assigned to placement policy %s\n", *username, *policy)
+	}
+	return nil
+}
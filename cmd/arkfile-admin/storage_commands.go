@@ -88,6 +88,14 @@ EXAMPLES:
 				}
 			}
 			fmt.Printf("  Verified:   %s\n", verified)
+
+			if rs, ok := pm["read_stats"].(map[string]interface{}); ok && safeInt64(rs, "requests") > 0 {
+				fmt.Printf("  Reads:      %d requests, avg %.0f ms, %.1f%% recent errors, %d hedged\n",
+					safeInt64(rs, "requests"), safeFloat64(rs, "avg_latency_ms"), safeFloat64(rs, "error_rate")*100, safeInt64(rs, "hedges"))
+				if lastErr := safeString(rs, "last_error"); lastErr != "" {
+					fmt.Printf("  Last error: %s\n", lastErr)
+				}
+			}
 		}
 	} else {
 		fmt.Println("  (no providers configured)")
@@ -102,9 +110,21 @@ EXAMPLES:
 	if replEnabled {
 		replStr = "enabled"
 	}
+	switch policy := safeString(resp.Data, "write_policy"); policy {
+	case "quorum":
+		replStr = fmt.Sprintf("%s (write policy: quorum, %d providers per upload)", replStr, safeInt64(resp.Data, "write_quorum"))
+	case "erasure":
+		replStr = fmt.Sprintf("%s (write policy: erasure, %s shards per upload)", replStr, safeString(resp.Data, "erasure_shards"))
+	case "":
+	default:
+		replStr = fmt.Sprintf("%s (write policy: %s)", replStr, policy)
+	}
 
 	fmt.Printf("\nReplication: %s\n", replStr)
 	fmt.Printf("Total files: %d | Fully replicated: %d | Gaps: %d\n", totalFiles, fullyReplicated, gaps)
+	if ecFiles := safeInt64(resp.Data, "erasure_coded_files"); ecFiles > 0 {
+		fmt.Printf("Erasure-coded files: %d\n", ecFiles)
+	}
 
 	return nil
 }
@@ -410,6 +430,9 @@ FLAGS:
 		failed := safeInt64(details, "files_failed")
 		bytesCopied := safeInt64(details, "bytes_copied")
 		fmt.Printf("  Copied: %d | Skipped: %d | Failed: %d\n", copied, skipped, failed)
+		if notAllowed := safeInt64(details, "files_not_allowed"); notAllowed > 0 {
+			fmt.Printf("  Not allowed by placement policy: %d\n", notAllowed)
+		}
 		if bytesCopied > 0 {
 			fmt.Printf("  Bytes copied: %s\n", formatFileSize(bytesCopied))
 		}
@@ -810,7 +833,7 @@ EXAMPLES:
 				if missing > 0 || sizeMismatch > 0 {
 					if *fix {
 						fmt.Printf("\n  %d locations updated to status 'missing'.\n", missing+sizeMismatch)
-						fmt.Printf("  Run 'copy-all --skip-existing' to re-copy missing files.\n")
+						fmt.Printf("  Run 'repair-storage' to restore the missing copies.\n")
 					} else {
 						fmt.Printf("\n  [!] %d issues found. Run with --fix to mark missing files.\n", missing+sizeMismatch)
 					}
@@ -823,6 +846,515 @@ EXAMPLES:
 	}
 }
 
+// handleRepairStorageCommand re-replicates files that have fewer healthy copies
+// than the replication target.
+func handleRepairStorageCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("repair-storage", flag.ExitOnError)
+	target := fs.Int("target", 0, "Healthy copies required per file (default: from write policy)")
+	fileID := fs.String("file-id", "", "Only repair this file")
+	dryRun := fs.Bool("dry-run", false, "Count under-replicated files without copying")
+	watch := fs.Bool("watch", false, "Poll task status until complete")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	fs.Usage = func() {
+		fmt.Printf(`Usage: arkfile-admin repair-storage [FLAGS]
+
+Find files with fewer active copies than the replication target and copy them
+from a provider that still holds a good copy. Every new copy is checked against
+the file's stored blob SHA-256; a source that fails the check is marked
+"corrupt" and replaced from another source.
+
+The default target follows STORAGE_WRITE_POLICY: the write quorum for quorum
+writes, 2 for async-secondary with a secondary configured, otherwise 1.
+
+FLAGS:
+    --target N        Healthy copies required per file (default: from write policy)
+    --file-id ID      Only repair this file
+    --dry-run         Count under-replicated files without copying
+    --watch           Poll task status until complete
+    --json            Output as JSON
+    --help            Show this help message
+
+EXAMPLES:
+    arkfile-admin repair-storage --dry-run --watch
+    arkfile-admin repair-storage --target 2 --watch
+    arkfile-admin repair-storage --file-id abc123
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target < 0 {
+		return fmt.Errorf("--target must not be negative")
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	payload := map[string]interface{}{
+		"target":  *target,
+		"dry_run": *dryRun,
+	}
+	if *fileID != "" {
+		payload["file_id"] = *fileID
+	}
+
+	resp, err := client.makeRequest("POST", "/api/admin/storage/repair", payload, session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to start repair task: %w", err)
+	}
+
+	taskID := safeString(resp.Data, "task_id")
+	if taskID == "" {
+		return fmt.Errorf("no task_id in response")
+	}
+
+	fmt.Printf("Repair task started: %s\n", taskID)
+
+	if !*watch {
+		fmt.Printf("Use 'arkfile-admin task-status --task-id %s --watch' to monitor progress.\n", taskID)
+		return nil
+	}
+
+	// Watch mode: poll until complete
+	for {
+		time.Sleep(3 * time.Second)
+
+		taskResp, err := client.makeRequest("GET", "/api/admin/storage/task/"+taskID, nil, session.AccessToken)
+		if err != nil {
+			fmt.Printf("  (poll error: %v)\n", err)
+			continue
+		}
+
+		status := safeString(taskResp.Data, "status")
+		current := safeInt64(taskResp.Data, "progress_current")
+		total := safeInt64(taskResp.Data, "progress_total")
+
+		if *jsonOutput && (status == "completed" || status == "failed" || status == "canceled") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(taskResp.Data)
+		}
+
+		pct := float64(0)
+		if total > 0 {
+			pct = float64(current) / float64(total) * 100
+		}
+
+		var repaired, failed, unrecoverable, noHash, copies, corrupt, shards, bytesCopied int64
+		if detailsRaw, ok := taskResp.Data["details"].(map[string]interface{}); ok {
+			repaired = safeInt64(detailsRaw, "files_repaired")
+			failed = safeInt64(detailsRaw, "files_failed")
+			unrecoverable = safeInt64(detailsRaw, "files_unrecoverable")
+			noHash = safeInt64(detailsRaw, "files_skipped_no_hash")
+			copies = safeInt64(detailsRaw, "copies_created")
+			corrupt = safeInt64(detailsRaw, "corrupt_sources")
+			shards = safeInt64(detailsRaw, "shards_rebuilt")
+			bytesCopied = safeInt64(detailsRaw, "bytes_copied")
+		}
+
+		fmt.Printf("\r  Status: %s | Progress: %d/%d (%.1f%%) | Repaired: %d | Failed: %d | Unrecoverable: %d",
+			status, current, total, pct, repaired, failed, unrecoverable)
+
+		if status == "completed" || status == "failed" || status == "canceled" {
+			fmt.Println()
+			if status != "completed" {
+				fmt.Printf("\nTask %s: %s\n", taskID, status)
+				return nil
+			}
+			if *dryRun {
+				fmt.Printf("\n%d file(s) are below the replication target.\n", total)
+				if total > 0 {
+					fmt.Printf("Run without --dry-run to repair them.\n")
+				}
+				return nil
+			}
+			fmt.Printf("\nRepair complete.\n")
+			fmt.Printf("  Under-replicated files: %d\n", total)
+			fmt.Printf("  Repaired: %d\n", repaired)
+			fmt.Printf("  Failed: %d\n", failed)
+			fmt.Printf("  Unrecoverable (no good copy left): %d\n", unrecoverable)
+			fmt.Printf("  Skipped (no stored hash): %d\n", noHash)
+			fmt.Printf("  Copies created: %d (%s)\n", copies, formatFileSize(bytesCopied))
+			if shards > 0 {
+				fmt.Printf("  Erasure shards rebuilt: %d\n", shards)
+			}
+			if corrupt > 0 {
+				fmt.Printf("\n  [!] %d source copies failed the hash check and were marked 'corrupt'.\n", corrupt)
+			}
+			return nil
+		}
+	}
+}
+
+// handleDrainProviderCommand moves all files off a provider and retires it.
+func handleDrainProviderCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("drain-provider", flag.ExitOnError)
+	providerID := fs.String("provider-id", "", "Provider to drain (required)")
+	target := fs.Int("target", 0, "Copies to keep on the remaining providers (default: from write policy)")
+	confirm := fs.Bool("confirm", false, "Skip confirmation prompt")
+	watch := fs.Bool("watch", false, "Poll task status until complete")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	fs.Usage = func() {
+		fmt.Printf(`Usage: arkfile-admin drain-provider --provider-id ID [FLAGS]
+
+Move every file off a provider before decommissioning it. For each file with a
+copy on the provider, the copies on the remaining providers are re-hashed
+against the stored blob SHA-256, missing copies are created and verified, and
+only then is the copy on the drained provider deleted. When every file has been
+moved the provider is marked inactive and taken out of rotation; it stays
+inactive across restarts, so it can then be removed from the configuration.
+
+The primary provider cannot be drained; promote another provider with
+set-primary first. Files that cannot be moved keep their copy on the drained
+provider and the provider stays active; fix the cause and run drain again.
+
+FLAGS:
+    --provider-id ID   Provider to drain (required)
+    --target N         Copies to keep on the remaining providers (default: from write policy)
+    --confirm          Skip confirmation prompt
+    --watch            Poll task status until complete
+    --json             Output as JSON
+    --help             Show this help message
+
+EXAMPLES:
+    arkfile-admin drain-provider --provider-id wasabi-us-central-1 --watch
+    arkfile-admin drain-provider --provider-id old-minio --target 2 --confirm
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *providerID == "" {
+		return fmt.Errorf("--provider-id is required")
+	}
+	if *target < 0 {
+		return fmt.Errorf("--target must not be negative")
+	}
+
+	if !*confirm {
+		fmt.Printf("Drain %s? Its copies will be deleted once every file is verified elsewhere. (yes/no): ", *providerID)
+		var response string
+		if _, err := fmt.Scanln(&response); err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "yes" && response != "y" {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	payload := map[string]interface{}{
+		"provider_id": *providerID,
+		"target":      *target,
+	}
+
+	resp, err := client.makeRequest("POST", "/api/admin/storage/drain-provider", payload, session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to start drain task: %w", err)
+	}
+
+	taskID := safeString(resp.Data, "task_id")
+	if taskID == "" {
+		return fmt.Errorf("no task_id in response")
+	}
+
+	fmt.Printf("Drain task started: %s\n", taskID)
+
+	if !*watch {
+		fmt.Printf("Use 'arkfile-admin task-status --task-id %s --watch' to monitor progress.\n", taskID)
+		return nil
+	}
+
+	// Watch mode: poll until complete
+	for {
+		time.Sleep(3 * time.Second)
+
+		taskResp, err := client.makeRequest("GET", "/api/admin/storage/task/"+taskID, nil, session.AccessToken)
+		if err != nil {
+			fmt.Printf("  (poll error: %v)\n", err)
+			continue
+		}
+
+		status := safeString(taskResp.Data, "status")
+		current := safeInt64(taskResp.Data, "progress_current")
+		total := safeInt64(taskResp.Data, "progress_total")
+
+		if *jsonOutput && (status == "completed" || status == "failed" || status == "canceled") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(taskResp.Data)
+		}
+
+		pct := float64(0)
+		if total > 0 {
+			pct = float64(current) / float64(total) * 100
+		}
+
+		var moved, failed, copies, corrupt, deleted, deleteFailures, bytesCopied int64
+		deactivated := false
+		if detailsRaw, ok := taskResp.Data["details"].(map[string]interface{}); ok {
+			moved = safeInt64(detailsRaw, "files_moved")
+			failed = safeInt64(detailsRaw, "files_failed")
+			copies = safeInt64(detailsRaw, "copies_created")
+			corrupt = safeInt64(detailsRaw, "corrupt_copies")
+			deleted = safeInt64(detailsRaw, "objects_deleted")
+			deleteFailures = safeInt64(detailsRaw, "delete_failures")
+			bytesCopied = safeInt64(detailsRaw, "bytes_copied")
+			deactivated = safeBool(detailsRaw, "provider_deactivated")
+		}
+
+		fmt.Printf("\r  Status: %s | Progress: %d/%d (%.1f%%) | Moved: %d | Failed: %d",
+			status, current, total, pct, moved, failed)
+
+		if status == "completed" || status == "failed" || status == "canceled" {
+			fmt.Println()
+			if status != "completed" {
+				fmt.Printf("\nTask %s: %s\n", taskID, status)
+				return nil
+			}
+			fmt.Printf("\nDrain of %s complete.\n", *providerID)
+			fmt.Printf("  Files moved: %d\n", moved)
+			fmt.Printf("  Files failed: %d\n", failed)
+			fmt.Printf("  Copies created: %d (%s)\n", copies, formatFileSize(bytesCopied))
+			fmt.Printf("  Objects deleted: %d\n", deleted)
+			if corrupt > 0 {
+				fmt.Printf("  Corrupt copies replaced: %d\n", corrupt)
+			}
+			if deactivated {
+				fmt.Printf("\n  Provider %s is now inactive and can be removed from the configuration.\n", *providerID)
+			} else {
+				fmt.Printf("\n  [!] Provider %s is still active (%d failed, %d delete failures). Check the server log and run drain-provider again.\n",
+					*providerID, failed, deleteFailures)
+			}
+			return nil
+		}
+	}
+}
+
+// handleTierStorageCommand moves cold files off the primary onto the cheapest provider.
+func handleTierStorageCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("tier-storage", flag.ExitOnError)
+	coldAfterDays := fs.Int("cold-after-days", 30, "Days without a download before a file moves off the primary")
+	watch := fs.Bool("watch", false, "Poll task status until complete")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	fs.Usage = func() {
+		fmt.Printf(`Usage: arkfile-admin tier-storage [FLAGS]
+
+Move files that nobody has downloaded in --cold-after-days from the primary
+provider to the cheapest active provider, using the costs set with set-cost.
+Each file gets a verified copy on the cheaper provider before its copy on the
+primary is deleted. Files that were moved and have been downloaded since are
+copied back to the primary (this also happens automatically on download).
+
+Run 'arkfile-admin tiering-report' first to see what would move and the
+projected monthly savings. Repair does not put a tiered copy back on the
+primary; a tiered file needs one copy fewer than the replication target.
+
+FLAGS:
+    --cold-after-days N   Days without a download before a file moves (default 30)
+    --watch               Poll task status until complete
+    --json                Output as JSON
+    --help                Show this help message
+
+EXAMPLES:
+    arkfile-admin tiering-report --cold-after-days 60
+    arkfile-admin tier-storage --cold-after-days 60 --watch
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *coldAfterDays < 1 {
+		return fmt.Errorf("--cold-after-days must be at least 1")
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	payload := map[string]interface{}{
+		"cold_after_days": *coldAfterDays,
+	}
+
+	resp, err := client.makeRequest("POST", "/api/admin/storage/tier", payload, session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to start tiering task: %w", err)
+	}
+
+	taskID := safeString(resp.Data, "task_id")
+	if taskID == "" {
+		return fmt.Errorf("no task_id in response")
+	}
+
+	fmt.Printf("Tiering task started: %s\n", taskID)
+
+	if !*watch {
+		fmt.Printf("Use 'arkfile-admin task-status --task-id %s --watch' to monitor progress.\n", taskID)
+		return nil
+	}
+
+	// Watch mode: poll until complete
+	for {
+		time.Sleep(3 * time.Second)
+
+		taskResp, err := client.makeRequest("GET", "/api/admin/storage/task/"+taskID, nil, session.AccessToken)
+		if err != nil {
+			fmt.Printf("  (poll error: %v)\n", err)
+			continue
+		}
+
+		status := safeString(taskResp.Data, "status")
+		current := safeInt64(taskResp.Data, "progress_current")
+		total := safeInt64(taskResp.Data, "progress_total")
+
+		if *jsonOutput && (status == "completed" || status == "failed" || status == "canceled") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(taskResp.Data)
+		}
+
+		pct := float64(0)
+		if total > 0 {
+			pct = float64(current) / float64(total) * 100
+		}
+
+		var demoted, promoted, failed, notAllowed, noHash, corrupt, bytesCopied, bytesFreed int64
+		var savings float64
+		var hotID, coldID string
+		if detailsRaw, ok := taskResp.Data["details"].(map[string]interface{}); ok {
+			hotID = safeString(detailsRaw, "hot_provider_id")
+			coldID = safeString(detailsRaw, "cold_provider_id")
+			demoted = safeInt64(detailsRaw, "files_demoted")
+			promoted = safeInt64(detailsRaw, "files_promoted")
+			failed = safeInt64(detailsRaw, "files_failed")
+			notAllowed = safeInt64(detailsRaw, "files_not_allowed")
+			noHash = safeInt64(detailsRaw, "files_skipped_no_hash")
+			corrupt = safeInt64(detailsRaw, "corrupt_copies")
+			bytesCopied = safeInt64(detailsRaw, "bytes_copied")
+			bytesFreed = safeInt64(detailsRaw, "bytes_freed")
+			savings = safeFloat64(detailsRaw, "monthly_savings_cents")
+		}
+
+		fmt.Printf("\r  Status: %s | Progress: %d/%d (%.1f%%) | Moved down: %d | Moved back: %d | Failed: %d",
+			status, current, total, pct, demoted, promoted, failed)
+
+		if status == "completed" || status == "failed" || status == "canceled" {
+			fmt.Println()
+			if status != "completed" {
+				fmt.Printf("\nTask %s: %s\n", taskID, status)
+				return nil
+			}
+			fmt.Printf("\nTiering %s -> %s complete.\n", hotID, coldID)
+			fmt.Printf("  Moved to %s: %d (%s freed on %s)\n", coldID, demoted, formatFileSize(bytesFreed), hotID)
+			fmt.Printf("  Moved back to %s: %d\n", hotID, promoted)
+			fmt.Printf("  Failed: %d\n", failed)
+			fmt.Printf("  Skipped (no stored hash): %d\n", noHash)
+			if notAllowed > 0 {
+				fmt.Printf("  Not allowed by placement policy: %d\n", notAllowed)
+			}
+			fmt.Printf("  Bytes copied: %s\n", formatFileSize(bytesCopied))
+			fmt.Printf("  Monthly storage cost change: -$%.2f\n", savings/100)
+			if corrupt > 0 {
+				fmt.Printf("\n  [!] %d copies failed the hash check and were marked 'corrupt'. Run repair-storage.\n", corrupt)
+			}
+			return nil
+		}
+	}
+}
+
+// handleTieringReportCommand shows what tier-storage would move and save.
+func handleTieringReportCommand(client *HTTPClient, config *AdminConfig, args []string) error {
+	fs := flag.NewFlagSet("tiering-report", flag.ExitOnError)
+	coldAfterDays := fs.Int("cold-after-days", 30, "Days without a download before a file counts as cold")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	fs.Usage = func() {
+		fmt.Printf(`Usage: arkfile-admin tiering-report [FLAGS]
+
+Show how many files tier-storage would move off the primary for a given
+--cold-after-days, and the projected monthly savings. Savings are the
+primary's cost for the bytes it would no longer hold, minus the cheapest
+provider's cost for copies it does not have yet. Costs come from set-cost.
+
+FLAGS:
+    --cold-after-days N   Days without a download before a file counts as cold (default 30)
+    --json                Output as JSON
+    --help                Show this help message
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *coldAfterDays < 1 {
+		return fmt.Errorf("--cold-after-days must be at least 1")
+	}
+
+	session, err := loadAdminSession(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("not logged in as admin (use 'arkfile-admin login'): %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return fmt.Errorf("admin session expired, please login again")
+	}
+
+	resp, err := client.makeRequest("GET", fmt.Sprintf("/api/admin/storage/tiering-report?cold_after_days=%d", *coldAfterDays), nil, session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to get tiering report: %w", err)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.Data)
+	}
+
+	report, ok := resp.Data["report"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no report in response")
+	}
+	hot, _ := report["hot_provider"].(map[string]interface{})
+	cold, _ := report["cold_provider"].(map[string]interface{})
+
+	fmt.Printf("Primary (hot):   %s at $%.2f/TB/month\n", safeString(hot, "provider_id"), float64(safeInt64(hot, "cost_per_tb_cents"))/100)
+	fmt.Printf("Cheapest (cold): %s at $%.2f/TB/month\n", safeString(cold, "provider_id"), float64(safeInt64(cold, "cost_per_tb_cents"))/100)
+	fmt.Printf("\nNot downloaded in %d days:\n", safeInt64(report, "cold_after_days"))
+	fmt.Printf("  Files to move:      %d (%s)\n", safeInt64(report, "candidate_files"), formatFileSize(safeInt64(report, "candidate_bytes")))
+	fmt.Printf("  New cold copies:    %s\n", formatFileSize(safeInt64(report, "new_cold_bytes")))
+	if returning := safeInt64(report, "returning_files"); returning > 0 {
+		fmt.Printf("  Files to move back: %d (%s)\n", returning, formatFileSize(safeInt64(report, "returning_bytes")))
+	}
+	fmt.Printf("  Projected savings:  $%.2f/month\n", safeFloat64(report, "projected_monthly_savings_cents")/100)
+	fmt.Printf("\nAlready tiered: %d files (%s), saving ~$%.2f/month\n",
+		safeInt64(report, "tiered_files"), formatFileSize(safeInt64(report, "tiered_bytes")),
+		safeFloat64(report, "current_monthly_savings_cents")/100)
+	return nil
+}
+
 // handleListTasksCommand lists background storage tasks
 func handleListTasksCommand(client *HTTPClient, config *AdminConfig, args []string) error {
 	fs := flag.NewFlagSet("list-tasks", flag.ExitOnError)
@@ -919,18 +1451,21 @@ FLAGS:
 // handleCancelAllTasksCommand requests cancellation of many active tasks
 func handleCancelAllTasksCommand(client *HTTPClient, config *AdminConfig, args []string) error {
 	fs := flag.NewFlagSet("cancel-all-tasks", flag.ExitOnError)
-	cancelType := fs.String("type", "", "Category of tasks to cancel (copy, verify, all) (required)")
+	cancelType := fs.String("type", "", "Category of tasks to cancel (copy, verify, repair, drain, tier, all) (required)")
 
 	fs.Usage = func() {
-		fmt.Printf(`Usage: arkfile-admin cancel-all-tasks --type copy|verify|all
+		fmt.Printf(`Usage: arkfile-admin cancel-all-tasks --type copy|verify|repair|drain|tier|all
 
 Cancel all running tasks in a category.
 "copy" cancels file copies and automatic replication.
 "verify" cancels head integrity checks.
+"repair" cancels re-replication of under-replicated files.
+"drain" cancels provider drains (files already moved stay moved).
+"tier" cancels storage tiering (files already moved stay moved).
 "all" cancels everything.
 
 FLAGS:
-    --type CATEGORY    Category of tasks to cancel (copy, verify, all) (required)
+    --type CATEGORY    Category of tasks to cancel (copy, verify, repair, drain, tier, all) (required)
     --help             Show this help message
 `)
 	}
@@ -942,8 +1477,8 @@ FLAGS:
 		return fmt.Errorf("--type is required")
 	}
 
-	if *cancelType != "copy" && *cancelType != "verify" && *cancelType != "all" {
-		return fmt.Errorf("invalid type: %s (must be copy, verify, or all)", *cancelType)
+	if *cancelType != "copy" && *cancelType != "verify" && *cancelType != "repair" && *cancelType != "drain" && *cancelType != "tier" && *cancelType != "all" {
+		return fmt.Errorf("invalid type: %s (must be copy, verify, repair, drain, tier, or all)", *cancelType)
 	}
 
 	session, err := loadAdminSession(config.TokenFile)
@@ -0,0 +1,303 @@
+// annotations.go - Encrypted tags and notes on files.
+//
+// A file can carry key/value tags (e.g. project=apollo, retention=7y) and a
+// free-text note. The tags are stored as one JSON object; both it and the
+// note are encrypted with the account key and AAD-bound to the file like
+// the filename, so the server cannot read or move them between files.
+// `annotate` edits them, and `list-files` shows them and filters on tags
+// with --tag. Tags and notes belong to a single version of a file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+const (
+	maxTagsPerFile   = 64
+	maxTagKeyLength  = 64
+	maxTagValueBytes = 256
+	maxNoteBytes     = 4096
+)
+
+// fileAnnotations is the decrypted tags and note of a file.
+type fileAnnotations struct {
+	Tags map[string]string
+	Note string
+}
+
+// decryptFileAnnotations decrypts a file's tags and note. A file without
+// them yields empty values.
+func decryptFileAnnotations(f ServerFileInfo, accountKey []byte, ownerUsername string) (fileAnnotations, error) {
+	var a fileAnnotations
+	if f.EncryptedTags != "" {
+		tagsJSON, err := decryptMetadataField(f.EncryptedTags, f.TagsNonce, accountKey,
+			f.FileID, crypto.AADFieldTags, ownerUsername)
+		if err != nil {
+			return a, fmt.Errorf("failed to decrypt tags: %w", err)
+		}
+		if err := json.Unmarshal([]byte(tagsJSON), &a.Tags); err != nil {
+			return a, fmt.Errorf("failed to parse tags: %w", err)
+		}
+	}
+	if f.EncryptedNote != "" {
+		note, err := decryptMetadataField(f.EncryptedNote, f.NoteNonce, accountKey,
+			f.FileID, crypto.AADFieldNote, ownerUsername)
+		if err != nil {
+			return a, fmt.Errorf("failed to decrypt note: %w", err)
+		}
+		a.Note = note
+	}
+	return a, nil
+}
+
+// validateTag checks a tag key and value against the client's limits.
+func validateTag(key, value string) error {
+	if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength {
+		return fmt.Errorf("tag key must be 1-%d characters", maxTagKeyLength)
+	}
+	if strings.ContainsAny(key, "=,") || strings.IndexFunc(key, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("tag key %q may not contain '=', ',' or whitespace", key)
+	}
+	if len(value) > maxTagValueBytes {
+		return fmt.Errorf("value of tag %q is longer than %d bytes", key, maxTagValueBytes)
+	}
+	if strings.IndexFunc(key+value, unicode.IsControl) >= 0 {
+		return fmt.Errorf("tag %q may not contain control characters", key)
+	}
+	return nil
+}
+
+// tagFilter matches files carrying a tag, with a given value unless
+// AnyValue is set.
+type tagFilter struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// parseTagFilters parses --tag KEY or --tag KEY=VALUE arguments.
+func parseTagFilters(args []string) ([]tagFilter, error) {
+	filters := make([]tagFilter, 0, len(args))
+	for _, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q: want KEY or KEY=VALUE", arg)
+		}
+		filters = append(filters, tagFilter{Key: key, Value: value, AnyValue: !hasValue})
+	}
+	return filters, nil
+}
+
+// matchesTagFilters reports whether tags satisfy every filter.
+func matchesTagFilters(tags map[string]string, filters []tagFilter) bool {
+	for _, f := range filters {
+		v, ok := tags[f.Key]
+		if !ok || (!f.AnyValue && v != f.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTags renders tags as "k1=v1, k2=v2" sorted by key.
+func formatTags(tags map[string]string) string {
+	keys := slices.Sorted(maps.Keys(tags))
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + tags[k]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// annotationPatch builds the PATCH body for changed tags and/or note. A nil
+// argument leaves that value unchanged.
+func annotationPatch(accountKey []byte, fileID, owner string, tags map[string]string, note *string) (map[string]interface{}, error) {
+	body := make(map[string]interface{})
+	if tags != nil {
+		enc, nonce := "", ""
+		if len(tags) > 0 {
+			tagsJSON, err := json.Marshal(tags)
+			if err != nil {
+				return nil, err
+			}
+			if enc, nonce, err = encryptMetadataField(string(tagsJSON), accountKey, fileID, crypto.AADFieldTags, owner); err != nil {
+				return nil, err
+			}
+		}
+		body["encrypted_tags"], body["tags_nonce"] = enc, nonce
+	}
+	if note != nil {
+		enc, nonce := "", ""
+		if *note != "" {
+			var err error
+			if enc, nonce, err = encryptMetadataField(*note, accountKey, fileID, crypto.AADFieldNote, owner); err != nil {
+				return nil, err
+			}
+		}
+		body["encrypted_note"], body["note_nonce"] = enc, nonce
+	}
+	return body, nil
+}
+
+// handleAnnotateCommand shows or edits the tags and note of a file.
+func handleAnnotateCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	fileID := fs.String("file-id", "", "File ID to annotate")
+	var setTags, removeTags multiStringFlag
+	fs.Var(&setTags, "tag", "Set a tag, as KEY=VALUE (may be repeated)")
+	fs.Var(&removeTags, "untag", "Remove the tag KEY (may be repeated)")
+	clearTags := fs.Bool("clear-tags", false, "Remove every tag")
+	note := fs.String("note", "", "Set the note")
+	clearNote := fs.Bool("clear-note", false, "Remove the note")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client annotate --file-id FILE_ID [--tag KEY=VALUE]... [--untag KEY]...\n" +
+			"                                [--clear-tags] [--note TEXT | --clear-note]\n\n" +
+			"Show or edit the tags and note of a file. Both are encrypted with your account key;\n" +
+			"the server cannot read them. Without options the current tags and note are shown.\n" +
+			"--clear-tags is applied before --tag, so the two together replace every tag.\n" +
+			"Use 'list-files --tag KEY[=VALUE]' to list files by tag.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fileID == "" {
+		return fmt.Errorf("--file-id is required")
+	}
+	noteSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "note" {
+			noteSet = true
+		}
+	})
+	if noteSet && *clearNote {
+		return fmt.Errorf("--note and --clear-note cannot be used together")
+	}
+	if len(*note) > maxNoteBytes {
+		return fmt.Errorf("note is longer than %d bytes", maxNoteBytes)
+	}
+	newTags := make(map[string]string, len(setTags))
+	for _, t := range setTags {
+		key, value, ok := strings.Cut(t, "=")
+		if !ok {
+			return fmt.Errorf("invalid --tag %q: want KEY=VALUE", t)
+		}
+		if err := validateTag(key, value); err != nil {
+			return err
+		}
+		newTags[key] = value
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	files, err := fetchAllFileVersions(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch file list: %w", err)
+	}
+	i := slices.IndexFunc(files, func(f ServerFileInfo) bool { return f.FileID == *fileID })
+	if i < 0 {
+		return fmt.Errorf("file %s not found", *fileID)
+	}
+	owner := files[i].OwnerUsername
+	if owner == "" {
+		owner = session.Username
+	}
+	current, err := decryptFileAnnotations(files[i], accountKey, owner)
+	if err != nil {
+		return err
+	}
+
+	editTags := *clearTags || len(setTags) > 0 || len(removeTags) > 0
+	editNote := noteSet || *clearNote
+	if !editTags && !editNote {
+		printAnnotations(current)
+		return nil
+	}
+
+	var tags map[string]string
+	if editTags {
+		tags = make(map[string]string)
+		if !*clearTags {
+			maps.Copy(tags, current.Tags)
+		}
+		for _, key := range removeTags {
+			delete(tags, key)
+		}
+		maps.Copy(tags, newTags)
+		if len(tags) > maxTagsPerFile {
+			return fmt.Errorf("a file can have at most %d tags", maxTagsPerFile)
+		}
+	}
+	var notePtr *string
+	if editNote {
+		notePtr = note
+		if *clearNote {
+			notePtr = new(string)
+		}
+	}
+
+	body, err := annotationPatch(accountKey, *fileID, owner, tags, notePtr)
+	if err != nil {
+		return err
+	}
+	if _, err := client.makeRequestWithSession("PATCH", "/api/files/"+*fileID+"/annotations", body, session); err != nil {
+		return fmt.Errorf("failed to update annotations: %w", err)
+	}
+
+	updated := current
+	if editTags {
+		updated.Tags = tags
+	}
+	if editNote {
+		updated.Note = *notePtr
+	}
+	fmt.Printf("Annotations of %s updated.\n", *fileID)
+	printAnnotations(updated)
+	return nil
+}
+
+// printAnnotations prints a file's tags, one per line, and its note.
+func printAnnotations(a fileAnnotations) {
+	if len(a.Tags) == 0 {
+		fmt.Println("Tags: (none)")
+	} else {
+		fmt.Println("Tags:")
+		for _, k := range slices.Sorted(maps.Keys(a.Tags)) {
+			fmt.Printf("  %s=%s\n", k, a.Tags[k])
+		}
+	}
+	if a.Note == "" {
+		fmt.Println("Note: (none)")
+	} else {
+		fmt.Printf("Note:\n%s\n", a.Note)
+	}
+}
+
+// noteSummary shortens a note to one line of at most 60 characters for
+// listings.
+func noteSummary(note string) string {
+	line := strings.Join(strings.Fields(note), " ")
+	if utf8.RuneCountInString(line) <= 60 {
+		return line
+	}
+	return string([]rune(line)[:57]) + "..."
+}
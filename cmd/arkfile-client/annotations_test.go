@@ -0,0 +1,111 @@
+// annotations_test.go - Unit tests for encrypted tags and notes.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// annotatedFile returns a ServerFileInfo carrying the encrypted tags and
+// note that annotationPatch produces for fileID.
+func annotatedFile(t *testing.T, accountKey []byte, fileID string, tags map[string]string, note string) ServerFileInfo {
+	t.Helper()
+	body, err := annotationPatch(accountKey, fileID, testOwner, tags, &note)
+	if err != nil {
+		t.Fatalf("annotationPatch: %v", err)
+	}
+	return ServerFileInfo{
+		FileID:        fileID,
+		EncryptedTags: body["encrypted_tags"].(string),
+		TagsNonce:     body["tags_nonce"].(string),
+		EncryptedNote: body["encrypted_note"].(string),
+		NoteNonce:     body["note_nonce"].(string),
+	}
+}
+
+func TestFileAnnotations_RoundTrip(t *testing.T) {
+	accountKey := make([]byte, 32)
+	tags := map[string]string{"project": "apollo", "retention": "7y"}
+	f := annotatedFile(t, accountKey, testFileID, tags, "Q3 audit copy")
+
+	a, err := decryptFileAnnotations(f, accountKey, testOwner)
+	if err != nil {
+		t.Fatalf("decryptFileAnnotations: %v", err)
+	}
+	if !reflect.DeepEqual(a.Tags, tags) || a.Note != "Q3 audit copy" {
+		t.Errorf("annotations = %+v", a)
+	}
+
+	// Ciphertexts are bound to their file and field
+	moved := f
+	moved.FileID = testFileID2
+	if _, err := decryptFileAnnotations(moved, accountKey, testOwner); err == nil {
+		t.Error("tags moved to another file must not decrypt")
+	}
+	swapped := f
+	swapped.EncryptedNote, swapped.NoteNonce = f.EncryptedTags, f.TagsNonce
+	if _, err := decryptFileAnnotations(swapped, accountKey, testOwner); err == nil {
+		t.Error("tags in the note field must not decrypt")
+	}
+}
+
+func TestAnnotationPatch_ClearsAndLeavesUnchanged(t *testing.T) {
+	accountKey := make([]byte, 32)
+	empty := ""
+	body, err := annotationPatch(accountKey, testFileID, testOwner, map[string]string{}, &empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"encrypted_tags", "tags_nonce", "encrypted_note", "note_nonce"} {
+		if body[k] != "" {
+			t.Errorf("%s = %v, want empty to clear", k, body[k])
+		}
+	}
+
+	body, err = annotationPatch(accountKey, testFileID, testOwner, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 0 {
+		t.Errorf("nil tags and note should send nothing, got %v", body)
+	}
+}
+
+func TestTagFilters(t *testing.T) {
+	filters, err := parseTagFilters([]string{"project=apollo", "retention"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		tags map[string]string
+		want bool
+	}{
+		{map[string]string{"project": "apollo", "retention": "7y"}, true},
+		{map[string]string{"project": "apollo", "retention": ""}, true},
+		{map[string]string{"project": "gemini", "retention": "7y"}, false},
+		{map[string]string{"project": "apollo"}, false},
+		{nil, false},
+	} {
+		if got := matchesTagFilters(tc.tags, filters); got != tc.want {
+			t.Errorf("matchesTagFilters(%v) = %v, want %v", tc.tags, got, tc.want)
+		}
+	}
+	if _, err := parseTagFilters([]string{"=apollo"}); err == nil {
+		t.Error("expected error for a filter without a key")
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	if err := validateTag("project", "apollo"); err != nil {
+		t.Errorf("valid tag rejected: %v", err)
+	}
+	for _, key := range []string{"", "a b", "a,b", "a\x00"} {
+		if err := validateTag(key, "v"); err == nil {
+			t.Errorf("tag key %q should be rejected", key)
+		}
+	}
+	if err := validateTag("k", string(make([]byte, maxTagValueBytes+1))); err == nil {
+		t.Error("over-long value should be rejected")
+	}
+}
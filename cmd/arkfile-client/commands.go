@@ -6,19 +6,25 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/arkfile/Arkfile/crypto"
@@ -29,6 +35,10 @@ import (
 // UPLOAD COMMAND
 // ============================================================
 
+// maxParallelChunks caps --parallel for upload and download. Each worker
+// holds about two chunk-sized buffers.
+const maxParallelChunks = 16
+
 // multiStringFlag implements flag.Value for repeatable --file flags.
 // Each occurrence appends to the slice. This is what enables
 //
@@ -172,32 +182,115 @@ func handleUploadCommand(client *HTTPClient, config *ClientConfig, args []string
 	fs.Var(&fileFlags, "file", "Path to a file to upload (may be repeated for multiple files)")
 	dir := fs.String("dir", "", "Directory of files to upload (non-recursive by default)")
 	recursive := fs.Bool("recursive", false, "When used with --dir, recurse into subdirectories")
+	folder := fs.String("folder", "", "Folder path to store the files under (e.g. docs/2026); default is the top level")
+	name := fs.String("name", "", "Filename to store stdin input under (required with -)")
+	spoolDir := fs.String("spool-dir", "", "Directory for the encrypted temporary copy of stdin input; needs free space the size of the whole input (default: system temp directory)")
 	passwordType := fs.String("password-type", "account", "Password type: account or custom")
 	hint := fs.String("hint", "", "Password hint (for custom password) -- one hint applies to every file in the batch")
 	force := fs.Bool("force", false, "Force upload even if a file is a duplicate")
+	resume := fs.Bool("resume", false, "Resume interrupted uploads of the given files (or of every interrupted upload if none are given)")
+	parallel := fs.Int("parallel", 1, fmt.Sprintf("Number of chunks to encrypt and upload concurrently (1-%d)", maxParallelChunks))
+	versionOf := fs.String("version-of", "", "Upload a single file as a new version of this file ID")
+	index := fs.Bool("index", false, "Add the uploaded files to the encrypted search index (see 'arkfile-client search')")
 
 	fs.Usage = func() {
-		fmt.Printf("Usage: arkfile-client upload [--file FILE]... [PATHS...] [--dir DIR [--recursive]] [--password-type account|custom] [--hint HINT] [--force]\n\n" +
+		fmt.Printf("Usage: arkfile-client upload [--file FILE]... [PATHS...] [--dir DIR [--recursive]] [--folder PATH] [--password-type account|custom] [--hint HINT] [--force] [--resume] [--parallel N] [--index]\n" +
+			"       arkfile-client upload - --name NAME [--folder PATH] [--spool-dir DIR] [--force] [--parallel N] [--index]\n" +
+			"       arkfile-client upload FILE --version-of FILE_ID [--folder PATH] [--password-type account|custom] [--force]\n\n" +
 			"Encrypt and upload one or more files sequentially using streaming per-chunk AES-GCM.\n" +
 			"Multiple files may be supplied via repeated --file flags, positional path arguments, and/or a --dir.\n" +
 			"One password (and one hint) applies to every file in the batch.\n" +
 			"Files are uploaded one at a time. Per-file failures are logged and skipped; fatal\n" +
 			"conditions (auth expired, quota exceeded, account disabled, server's per-user upload\n" +
 			"session cap reached) abort the batch and the remaining files are reported as skipped.\n" +
-			"The JWT is refreshed proactively between files when within 5 minutes of expiry.\n")
+			"The JWT is refreshed proactively between files when within 5 minutes of expiry.\n" +
+			"Each upload keeps a resume journal in ~/.arkfile-uploads until it completes. With --resume,\n" +
+			"files whose upload was interrupted send only the chunks the server is missing; other files\n" +
+			"are uploaded normally. With --resume and no files, every interrupted upload is resumed.\n" +
+			"A file modified since its upload was interrupted is uploaded again from the start.\n" +
+			"--parallel N uploads up to N chunks of each file at once; memory use grows with N.\n" +
+			"--folder PATH stores the files in an encrypted folder path. Files found under --dir keep\n" +
+			"their subdirectory relative to --dir below PATH, so --dir --recursive preserves the tree.\n" +
+			"With - the file is read from stdin (e.g. pg_dump | arkfile-client upload - --name db.sql).\n" +
+			"The size and SHA-256 must be known before the first chunk is encrypted, so the input is\n" +
+			"first copied to a temporary file (mode 0600) in --spool-dir and deleted after the upload.\n" +
+			"The copy is encrypted with a random key held only in memory, so a copy left behind by a\n" +
+			"crash is unreadable, but --spool-dir still needs free space the size of the whole input.\n" +
+			"Stdin uploads use the account password and cannot be resumed.\n" +
+			"--version-of FILE_ID stores one file (or stdin) as the newest version of FILE_ID, which\n" +
+			"may name any version of that file. Older versions stay downloadable with 'download\n" +
+			"--version N' and count toward your storage quota until removed by 'version-retention'.\n" +
+			"The new version goes into the same folder as FILE_ID unless --folder is given.\n" +
+			"--index adds each uploaded file's name and folder to the encrypted search index.\n")
+	}
+
+	// Allow flags after the paths as well as before them (upload - --name X)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	folderSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "folder" {
+			folderSet = true
+		}
+	})
+	if *versionOf != "" && (len(fileFlags)+len(positional) != 1 || *dir != "" || *resume) {
+		return fmt.Errorf("--version-of uploads exactly one file and cannot be used with --dir or --resume")
+	}
+	// A new version stays in the folder of the file it replaces unless
+	// --folder says otherwise
+	inheritFolder := *versionOf != "" && !folderSet
+
+	if slices.Contains(positional, stdinPath) {
+		switch {
+		case len(positional) != 1 || len(fileFlags) > 0 || *dir != "":
+			return fmt.Errorf("stdin (-) must be the only input")
+		case *resume:
+			return fmt.Errorf("stdin uploads cannot be resumed")
+		case *passwordType != "account":
+			return fmt.Errorf("stdin uploads use the account password; the custom password prompt would read stdin")
+		case !isPlainFileName(*name):
+			return fmt.Errorf("--name is required with stdin (-) and must be a plain filename")
+		case *parallel < 1 || *parallel > maxParallelChunks:
+			return fmt.Errorf("--parallel must be between 1 and %d", maxParallelChunks)
+		}
+		baseFolder, err := cleanFolderPath(*folder)
+		if err != nil {
+			return fmt.Errorf("invalid --folder: %w", err)
+		}
+		return uploadFromStdin(client, config, *name, baseFolder, *spoolDir, *versionOf, inheritFolder, *force, *index, *parallel)
 	}
-
-	if err := fs.Parse(args); err != nil {
-		return err
+	if *name != "" || *spoolDir != "" {
+		return fmt.Errorf("--name and --spool-dir only apply to stdin (-) uploads")
 	}
 
-	files, err := collectUploadInputs([]string(fileFlags), fs.Args(), *dir, *recursive)
+	files, err := collectUploadInputs([]string(fileFlags), positional, *dir, *recursive)
 	if err != nil {
 		return err
 	}
-	if len(files) == 0 {
+	if *parallel < 1 || *parallel > maxParallelChunks {
+		return fmt.Errorf("--parallel must be between 1 and %d", maxParallelChunks)
+	}
+	if len(files) == 0 && !*resume {
 		return fmt.Errorf("no files supplied: provide --file, positional arguments, and/or --dir")
 	}
+	baseFolder, err := cleanFolderPath(*folder)
+	if err != nil {
+		return fmt.Errorf("invalid --folder: %w", err)
+	}
+	folders, err := uploadFolders(files, *dir, baseFolder)
+	if err != nil {
+		return err
+	}
 
 	// Resolve session and account key once for the whole batch.
 	session, err := requireSession(config)
@@ -205,12 +298,41 @@ func handleUploadCommand(client *HTTPClient, config *ClientConfig, args []string
 		return err
 	}
 
+	var journals []*uploadJournal
+	if *resume {
+		journals, err = loadUploadJournals(getUploadJournalDir(), client.baseURL, session.Username)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			seen := make(map[string]bool)
+			for _, journal := range journals {
+				if !seen[journal.FilePath] {
+					seen[journal.FilePath] = true
+					files = append(files, journal.FilePath)
+				}
+				// Journals are oldest first; the newest one's folder wins
+				folders[journal.FilePath] = journal.Folder
+			}
+			if len(files) == 0 {
+				fmt.Println("No interrupted uploads to resume.")
+				return nil
+			}
+		}
+	}
+
 	accountKey, err := requireAccountKey()
 	if err != nil {
 		return err
 	}
 	defer clearBytes(accountKey)
 
+	if inheritFolder {
+		if folders[files[0]], err = versionTargetFolder(client, session, accountKey, *versionOf); err != nil {
+			return err
+		}
+	}
+
 	// Determine KEK once for the whole batch. Same password applies to
 	// every file -- this matches the documented UX (one prompt per
 	// multi-select). Per-file custom passwords are still trivially
@@ -280,10 +402,18 @@ func handleUploadCommand(client *HTTPClient, config *ClientConfig, args []string
 			break
 		}
 
-		fileID, err := uploadOneFile(client, session, config, accountKey, kek, finalPasswordType, *hint, path, *force)
+		var fileID string
+		if *resume {
+			fileID, err = resumeOrUploadFile(client, session, config, accountKey, kek, finalPasswordType, *hint, path, folders[path], *force, *parallel, journals)
+		} else {
+			fileID, err = uploadOneFile(client, session, config, accountKey, kek, finalPasswordType, *hint, path, folders[path], *versionOf, *force, *parallel)
+		}
 		if err == nil {
 			succeeded++
 			fmt.Printf("[OK] %s (file_id=%s)\n", filepath.Base(path), fileID)
+			if *index {
+				indexUploadedFile(client, session, accountKey, fileID, filepath.Base(path), folders[path])
+			}
 			continue
 		}
 
@@ -333,7 +463,9 @@ func handleUploadCommand(client *HTTPClient, config *ClientConfig, args []string
 // envelope, and the metadata fields. On HTTP 409 / file_id_conflict from
 // the server (vanishingly rare in practice), the client retries with a
 // freshly minted UUID up to 3 times, then surfaces a hard error.
-func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfig, accountKey, kek []byte, finalPasswordType, hint, filePath string, force bool) (string, error) {
+//
+// A non-empty versionOf uploads the file as the next version of that file.
+func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfig, accountKey, kek []byte, finalPasswordType, hint, filePath, folder, versionOf string, force bool, parallel int) (string, error) {
 	if err := isSeekableFile(filePath); err != nil {
 		return "", err
 	}
@@ -350,24 +482,80 @@ func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfi
 	// the file is fine, just already uploaded. Reported as a skippable
 	// failure so the rest of the batch can continue.
 	if !force {
-		agentClient, agentErr := NewAgentClient()
-		if agentErr == nil {
-			dedupResult, dedupErr := performDedupCheck(agentClient, client, session, accountKey, sha256hex)
-			if dedupErr == nil && dedupResult.IsDuplicate {
-				if dedupResult.Filename != "" {
-					return "", fmt.Errorf("duplicate of '%s' (file_id=%s); use --force to upload anyway", dedupResult.Filename, dedupResult.FileID)
-				}
-				return "", fmt.Errorf("duplicate (file_id=%s); use --force to upload anyway", dedupResult.FileID)
-			}
+		if err := checkDuplicateDigest(client, session, accountKey, sha256hex); err != nil {
+			return "", err
 		}
 	}
 
+	return uploadFileWithDigest(client, session, accountKey, kek, finalPasswordType, hint, filePath, folder, versionOf, sha256hex, parallel)
+}
+
+// checkDuplicateDigest returns an error naming the existing file when a file
+// with plaintext digest sha256hex was already uploaded. Dedup is best
+// effort: without the agent, or when the check itself fails, it passes.
+func checkDuplicateDigest(client *HTTPClient, session *AuthSession, accountKey []byte, sha256hex string) error {
+	agentClient, agentErr := NewAgentClient()
+	if agentErr != nil {
+		return nil
+	}
+	dedupResult, dedupErr := performDedupCheck(agentClient, client, session, accountKey, sha256hex)
+	if dedupErr != nil || !dedupResult.IsDuplicate {
+		return nil
+	}
+	if dedupResult.Filename != "" {
+		return fmt.Errorf("duplicate of '%s' (file_id=%s); use --force to upload anyway", dedupResult.Filename, dedupResult.FileID)
+	}
+	return fmt.Errorf("duplicate (file_id=%s); use --force to upload anyway", dedupResult.FileID)
+}
+
+// uploadFileWithDigest encrypts and uploads filePath whose plaintext SHA-256
+// is already known, without a dedup check, into folder ("" for the top
+// level; already cleaned with cleanFolderPath), as a new version of
+// versionOf if that is set. Returns the new file_id.
+func uploadFileWithDigest(client *HTTPClient, session *AuthSession, accountKey, kek []byte, finalPasswordType, hint, filePath, folder, versionOf, sha256hex string, parallel int) (string, error) {
+	return uploadSourceFile(client, session, accountKey, kek, finalPasswordType, hint, uploadSource{
+		Path:      filePath,
+		Filename:  filepath.Base(filePath),
+		Folder:    folder,
+		SHA256:    sha256hex,
+		VersionOf: versionOf,
+		Resumable: true,
+	}, parallel)
+}
+
+// uploadSource is a local file ready for upload. Filename and Folder are
+// the plaintext metadata stored (encrypted) with it; SHA256 is the digest
+// of the file's contents. VersionOf, if set, is the file this upload is a
+// new version of. Only a Resumable upload keeps a resume journal, since
+// resuming re-reads Path. Open, if set, reads Path in place of os.Open (the
+// encrypted stdin spool).
+type uploadSource struct {
+	Path      string
+	Filename  string
+	Folder    string
+	SHA256    string
+	VersionOf string
+	Resumable bool
+	Open      func(path string) (chunkSource, error)
+}
+
+// chunkSource is what uploadFileChunks reads chunks from.
+type chunkSource interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// uploadSourceFile encrypts and uploads src. Returns the new file_id.
+func uploadSourceFile(client *HTTPClient, session *AuthSession, accountKey, kek []byte, finalPasswordType, hint string, src uploadSource, parallel int) (string, error) {
 	// Owner of any newly uploaded file is the authenticated user. This
 	// is bound into metadata AAD and must match what the server stores
 	// in file_metadata.owner_username.
 	ownerUsername := session.Username
 
-	filename := filepath.Base(filePath)
+	filePath := src.Path
+	filename := src.Filename
+	folder := src.Folder
+	sha256hex := src.SHA256
 
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -375,7 +563,7 @@ func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfi
 	}
 	fileSizeBytes := fileInfo.Size()
 	if fileSizeBytes == 0 {
-		return "", fmt.Errorf("cannot upload empty file (0 bytes): %s", filepath.Base(filePath))
+		return "", fmt.Errorf("cannot upload empty file (0 bytes): %s", filename)
 	}
 	chunkSizeBytes := int64(crypto.PlaintextChunkSize())
 	chunkCount := (fileSizeBytes + chunkSizeBytes - 1) / chunkSizeBytes
@@ -389,6 +577,22 @@ func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfi
 		keyTypeByte = 0x02
 	}
 
+	// The resume journal identifies the file by absolute path. A fresh
+	// upload supersedes any interrupted upload of the same file.
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	journalDir := getUploadJournalDir()
+	if src.Resumable {
+		if journals, jerr := loadUploadJournals(journalDir, client.baseURL, ownerUsername); jerr == nil {
+			for _, stale := range journalsForFile(journals, absPath) {
+				logVerbose("Discarding interrupted upload session %s of %s", stale.SessionID, filename)
+				discardUploadJournal(client, session, journalDir, stale)
+			}
+		}
+	}
+
 	logVerbose("Uploading %s (%s), %d chunks", filename, formatFileSize(fileSizeBytes), chunkCount)
 
 	// Retry loop for file_id collisions. The client
@@ -421,8 +625,37 @@ func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfi
 			return "", fmt.Errorf("failed to encrypt metadata: %w", merr)
 		}
 
+		var encFolderB64, folderNonceB64 string
+		if folder != "" {
+			encFolderB64, folderNonceB64, merr = encryptFolderPath(folder, accountKey, fileID, ownerUsername)
+			if merr != nil {
+				clearBytes(fek)
+				return "", fmt.Errorf("failed to encrypt folder: %w", merr)
+			}
+		}
+
+		var journal *uploadJournal
+		if src.Resumable {
+			journal = &uploadJournal{
+				FileID:         fileID,
+				ServerURL:      client.baseURL,
+				Username:       ownerUsername,
+				FilePath:       absPath,
+				Folder:         folder,
+				VersionOf:      src.VersionOf,
+				FileSize:       fileSizeBytes,
+				FileModTime:    fileInfo.ModTime(),
+				PasswordType:   finalPasswordType,
+				EncryptedFEK:   encryptedFEKB64,
+				ChunkCount:     chunkCount,
+				ChunkSizeBytes: chunkSizeBytes,
+				CreatedAt:      time.Now().UTC(),
+			}
+		}
+
 		returnedFileID, derr := doChunkedUpload(client, session, &ChunkedUploadParams{
 			FilePath:        filePath,
+			Open:            src.Open,
 			FileID:          fileID,
 			OwnerUsername:   ownerUsername,
 			FEK:             fek,
@@ -432,12 +665,18 @@ func uploadOneFile(client *HTTPClient, session *AuthSession, config *ClientConfi
 			FnNonceB64:      fnNonceB64,
 			EncSHA256B64:    encSHA256B64,
 			ShaNonceB64:     shaNonceB64,
+			EncFolderB64:    encFolderB64,
+			FolderNonceB64:  folderNonceB64,
 			PasswordType:    finalPasswordType,
 			PasswordHint:    hint,
 			FileSizeBytes:   fileSizeBytes,
 			TotalEncSize:    totalEncSize,
 			ChunkCount:      chunkCount,
 			ChunkSizeBytes:  chunkSizeBytes,
+			VersionOf:       src.VersionOf,
+			Parallel:        parallel,
+			Journal:         journal,
+			JournalDir:      journalDir,
 		})
 		// Clear FEK as soon as the upload returns (success or failure).
 		clearBytes(fek)
@@ -486,9 +725,13 @@ func isFileIDConflict(err error) bool {
 // FileID is the client-generated UUIDv4 that the server validates and
 // stores in `file_metadata.file_id`. It is also bound into the AAD of
 // every chunk and the FEK envelope. OwnerUsername is bound into the
-// AAD of the metadata fields (filename and SHA-256 digest).
+// AAD of the metadata fields (filename and SHA-256 digest). VersionOf, if
+// set, makes the upload the next version of that file. Parallel is the
+// number of chunks uploaded concurrently. Open, if set, opens FilePath in
+// place of os.Open.
 type ChunkedUploadParams struct {
 	FilePath        string
+	Open            func(path string) (chunkSource, error)
 	FileID          string
 	OwnerUsername   string
 	FEK             []byte
@@ -498,17 +741,27 @@ type ChunkedUploadParams struct {
 	FnNonceB64      string
 	EncSHA256B64    string
 	ShaNonceB64     string
+	EncFolderB64    string
+	FolderNonceB64  string
 	PasswordType    string
 	PasswordHint    string
 	FileSizeBytes   int64
 	TotalEncSize    int64
 	ChunkCount      int64
 	ChunkSizeBytes  int64
+	VersionOf       string
+	Parallel        int
+	Journal         *uploadJournal
+	JournalDir      string
 }
 
 // doChunkedUpload performs the streaming chunked upload to the server.
 // Opens the file, reads it in plaintext chunks, encrypts each chunk,
 // and streams multipart chunks to the server's chunked upload endpoint.
+//
+// When params.Journal is set it is saved as soon as the server has created
+// the upload session, so an interrupted upload can be continued with
+// `upload --resume`, and removed once the upload is finalized.
 func doChunkedUpload(client *HTTPClient, session *AuthSession, params *ChunkedUploadParams) (string, error) {
 	chunkSize := crypto.PlaintextChunkSize()
 
@@ -528,6 +781,13 @@ func doChunkedUpload(client *HTTPClient, session *AuthSession, params *ChunkedUp
 		"password_type":       params.PasswordType,
 		"password_hint":       params.PasswordHint,
 	}
+	if params.EncFolderB64 != "" {
+		initPayload["encrypted_folder"] = params.EncFolderB64
+		initPayload["folder_nonce"] = params.FolderNonceB64
+	}
+	if params.VersionOf != "" {
+		initPayload["version_of"] = params.VersionOf
+	}
 
 	initResp, err := client.makeRequestWithSession("POST", "/api/uploads/init", initPayload, session)
 	if err != nil {
@@ -548,63 +808,148 @@ func doChunkedUpload(client *HTTPClient, session *AuthSession, params *ChunkedUp
 
 	logVerbose("Upload initialized with ID: %s", uploadID)
 
-	// Step 2: Open file and stream chunks
-	f, err := os.Open(params.FilePath)
+	if params.Journal != nil {
+		params.Journal.SessionID = uploadID
+		if err := saveUploadJournal(params.JournalDir, params.Journal); err != nil {
+			// The upload itself is unaffected; it just cannot be resumed.
+			fmt.Printf("[!] %s: failed to save resume journal: %v\n", filepath.Base(params.FilePath), err)
+			params.Journal = nil
+		}
+	}
+
+	// Step 2: Stream chunks
+	if err := uploadFileChunks(client, session, params, uploadID, nil); err != nil {
+		return "", err
+	}
+
+	// Step 3: Finalize upload
+	fileID, err := finalizeChunkedUpload(client, session, uploadID, params.ChunkCount)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return "", err
 	}
-	defer f.Close()
 
-	buf := make([]byte, chunkSize)
-	var chunkIndex int64
+	if params.Journal != nil {
+		removeUploadJournal(params.JournalDir, uploadID)
+	}
+	return fileID, nil
+}
 
-	for {
-		n, readErr := io.ReadFull(f, buf)
-		if n == 0 && readErr == io.EOF {
-			break
-		}
-		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
-			return "", fmt.Errorf("failed to read file at chunk %d: %w", chunkIndex, readErr)
-		}
+// uploadFileChunks reads, encrypts and uploads every chunk of params.FilePath
+// whose index is not in skip (the chunks the server already holds when an
+// upload is resumed). The file must still be params.FileSizeBytes long.
+//
+// params.Parallel workers each read, encrypt and upload one chunk at a time,
+// so encryption and network I/O overlap while memory stays capped at about
+// two chunk buffers per worker. The first failure cancels the other workers.
+func uploadFileChunks(client *HTTPClient, session *AuthSession, params *ChunkedUploadParams, uploadID string, skip map[int64]bool) error {
+	open := params.Open
+	if open == nil {
+		open = func(path string) (chunkSource, error) { return os.Open(path) }
+	}
+	f, err := open(params.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
 
-		plaintext := buf[:n]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokens := newSessionTokenSource(client, session)
+
+	workers := max(params.Parallel, 1)
+	jobs := make(chan int64)
+	errCh := make(chan error, workers)
+	var sent, done atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, params.ChunkSizeBytes)
+			for chunkIndex := range jobs {
+				if err := uploadFileChunk(ctx, client, tokens, params, uploadID, f, buf, chunkIndex); err != nil {
+					// Workers stopped by another worker's failure stay quiet
+					if ctx.Err() == nil {
+						errCh <- err
+						cancel()
+					}
+					return
+				}
+				sent.Add(1)
+				if verbose {
+					n := done.Add(1) + int64(len(skip))
+					progress := float64(n) / float64(params.ChunkCount) * 100
+					logVerbose("  Chunk %d uploaded, %d/%d (%.1f%%)", chunkIndex+1, n, params.ChunkCount, progress)
+				}
+			}
+		}()
+	}
 
-		// Encrypt the chunk. AAD binds (file_id, chunk_index,
-		// total_chunks) so that the server cannot swap/reorder/truncate
-		// chunks at decrypt time.
-		encryptedChunk, err := encryptChunk(plaintext, params.FEK, params.FileID, chunkIndex, params.ChunkCount)
-		if err != nil {
-			return "", fmt.Errorf("failed to encrypt chunk %d: %w", chunkIndex, err)
+feed:
+	for chunkIndex := int64(0); chunkIndex < params.ChunkCount; chunkIndex++ {
+		if skip[chunkIndex] {
+			continue
 		}
-
-		// Refresh the JWT proactively if it is near expiry before uploading
-		// the chunk. This ensures long uploads on slow connections (e.g. 6 GB
-		// at 1 MB/s) never hit a mid-chunk 401.
-		if rerr := ensureFreshSessionToken(client, session, 0); rerr != nil {
-			return "", fmt.Errorf("%w: session refresh failed before chunk %d", rerr, chunkIndex)
+		select {
+		case jobs <- chunkIndex:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		return err
+	}
 
-		// Upload the chunk
-		if err := uploadChunk(client, session, uploadID, chunkIndex, encryptedChunk); err != nil {
-			return "", fmt.Errorf("failed to upload chunk %d: %w", chunkIndex, err)
+	if len(skip) > 0 {
+		logVerbose("Uploaded %d missing chunks; %d were already on the server", sent.Load(), params.ChunkCount-sent.Load())
+	}
+	return nil
+}
+
+// uploadFileChunk reads chunk chunkIndex of f into buf, encrypts it and
+// uploads it.
+func uploadFileChunk(ctx context.Context, client *HTTPClient, tokens *sessionTokenSource, params *ChunkedUploadParams, uploadID string, f io.ReaderAt, buf []byte, chunkIndex int64) error {
+	offset := chunkIndex * params.ChunkSizeBytes
+	want := min(params.ChunkSizeBytes, params.FileSizeBytes-offset)
+	n, readErr := f.ReadAt(buf[:want], offset)
+	if int64(n) != want {
+		if readErr == nil || readErr == io.EOF {
+			return fmt.Errorf("file changed during upload: chunk %d is short", chunkIndex)
 		}
+		return fmt.Errorf("failed to read file at chunk %d: %w", chunkIndex, readErr)
+	}
 
-		chunkIndex++
+	// Encrypt the chunk. AAD binds (file_id, chunk_index,
+	// total_chunks) so that the server cannot swap/reorder/truncate
+	// chunks at decrypt time.
+	encryptedChunk, err := encryptChunk(buf[:n], params.FEK, params.FileID, chunkIndex, params.ChunkCount)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt chunk %d: %w", chunkIndex, err)
+	}
 
-		if verbose {
-			progress := float64(chunkIndex) / float64(params.ChunkCount) * 100
-			logVerbose("  Chunk %d/%d uploaded (%.1f%%)", chunkIndex, params.ChunkCount, progress)
-		}
+	// Refresh the JWT proactively if it is near expiry before uploading
+	// the chunk. This ensures long uploads on slow connections (e.g. 6 GB
+	// at 1 MB/s) never hit a mid-chunk 401.
+	session, err := tokens.fresh()
+	if err != nil {
+		return fmt.Errorf("%w: session refresh failed before chunk %d", err, chunkIndex)
+	}
 
-		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
-			break
-		}
+	if err := uploadChunk(ctx, client, session, uploadID, chunkIndex, encryptedChunk); err != nil {
+		return fmt.Errorf("failed to upload chunk %d: %w", chunkIndex, err)
 	}
+	return nil
+}
 
-	// Step 3: Finalize upload
+// finalizeChunkedUpload asks the server to assemble the uploaded chunks and
+// returns the file_id of the stored file.
+func finalizeChunkedUpload(client *HTTPClient, session *AuthSession, uploadID string, totalChunks int64) (string, error) {
 	finalizePayload := map[string]interface{}{
 		"upload_id":    uploadID,
-		"total_chunks": chunkIndex,
+		"total_chunks": totalChunks,
 	}
 
 	finalizeResp, err := client.makeRequestWithSession("POST", "/api/uploads/"+uploadID+"/complete", finalizePayload, session)
@@ -628,12 +973,12 @@ func doChunkedUpload(client *HTTPClient, session *AuthSession, params *ChunkedUp
 // uploadChunk sends a single encrypted chunk to the server as raw bytes.
 // Computes SHA-256 of the encrypted chunk and sends it as X-Chunk-Hash header,
 // as required by the server's UploadChunk handler.
-func uploadChunk(client *HTTPClient, session *AuthSession, uploadID string, chunkIndex int64, data []byte) error {
+func uploadChunk(ctx context.Context, client *HTTPClient, session *AuthSession, uploadID string, chunkIndex int64, data []byte) error {
 	h := sha256.Sum256(data)
 	chunkHash := hex.EncodeToString(h[:])
 
 	url := fmt.Sprintf("%s/api/uploads/%s/chunks/%d", client.baseURL, uploadID, chunkIndex)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create chunk request: %w", err)
 	}
@@ -664,18 +1009,53 @@ func uploadChunk(client *HTTPClient, session *AuthSession, uploadID string, chun
 func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []string) error {
 	fs := flag.NewFlagSet("download", flag.ExitOnError)
 	fileID := fs.String("file-id", "", "File ID to download")
-	outputPath := fs.String("output", "", "Output file path (default: decrypted filename)")
+	folder := fs.String("folder", "", "Restore every file in this folder (and below it); \"/\" restores all files")
+	outputPath := fs.String("output", "", "Output file path (default: decrypted filename; - for stdout); with --folder, the output directory (default: .)")
+	parallel := fs.Int("parallel", 1, fmt.Sprintf("Number of chunks to download and decrypt concurrently (1-%d)", maxParallelChunks))
+	version := fs.Int64("version", 0, "Download this version of the file (see list-files --versions)")
 
 	fs.Usage = func() {
-		fmt.Printf("Usage: arkfile-client download --file-id FILE_ID [--output PATH]\n\nDownload and decrypt a file using streaming per-chunk AES-GCM.\n")
+		fmt.Printf("Usage: arkfile-client download --file-id FILE_ID [--version N] [--output PATH] [--parallel N]\n" +
+			"       arkfile-client download --folder PATH [--output DIR] [--parallel N]\n\n" +
+			"Download and decrypt a file using streaming per-chunk AES-GCM.\n" +
+			"--parallel N fetches up to N chunks at once; chunks are still written in order.\n" +
+			"--output - writes the file to stdout. Each chunk is written as soon as its AES-GCM tag\n" +
+			"verifies; the file's SHA-256 is checked at the end and a mismatch exits non-zero.\n" +
+			"--folder PATH restores every file in the encrypted folder PATH and its subfolders into DIR,\n" +
+			"recreating the folders below PATH. Each file's SHA-256 is verified; existing local files\n" +
+			"are never overwritten. Custom-password files in the folder share one prompted password.\n" +
+			"--version N downloads version N of the file that FILE_ID names (any of its versions).\n")
 	}
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *fileID == "" {
-		return fmt.Errorf("--file-id is required")
+	folderSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "folder" {
+			folderSet = true
+		}
+	})
+	if folderSet && *fileID != "" {
+		return fmt.Errorf("--file-id and --folder cannot be used together")
+	}
+	if *fileID == "" && !folderSet {
+		return fmt.Errorf("--file-id or --folder is required")
+	}
+	if *version < 0 || (*version > 0 && *fileID == "") {
+		return fmt.Errorf("--version takes a version number and requires --file-id")
+	}
+	toStdout := *outputPath == stdinPath
+	if toStdout && folderSet {
+		return fmt.Errorf("--output - cannot be used with --folder")
+	}
+	if toStdout {
+		// Keep stdout for the file's contents
+		statusOut = os.Stderr
+	}
+	if *parallel < 1 || *parallel > maxParallelChunks {
+		return fmt.Errorf("--parallel must be between 1 and %d", maxParallelChunks)
 	}
 
 	session, err := requireSession(config)
@@ -683,12 +1063,35 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 		return err
 	}
 
+	if folderSet {
+		base, err := cleanFolderPath(*folder)
+		if err != nil {
+			return fmt.Errorf("invalid --folder: %w", err)
+		}
+		outDir := *outputPath
+		if outDir == "" {
+			outDir = "."
+		}
+		return downloadFolder(client, config, session, base, outDir, *parallel)
+	}
+
 	accountKey, err := requireAccountKey()
 	if err != nil {
 		return err
 	}
 	defer clearBytes(accountKey)
 
+	if *version > 0 {
+		files, err := fetchAllFileVersions(client, session)
+		if err != nil {
+			return fmt.Errorf("failed to fetch file versions: %w", err)
+		}
+		if *fileID, err = resolveFileVersion(files, *fileID, *version); err != nil {
+			return err
+		}
+		logVerbose("Version %d is file_id=%s", *version, *fileID)
+	}
+
 	// Fetch file metadata
 	metaReq, err := http.NewRequest("GET", client.baseURL+"/api/files/"+*fileID+"/meta", nil)
 	if err != nil {
@@ -710,6 +1113,7 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 	if err := decodeJSONResponse(metaResp, &fileMeta); err != nil {
 		return fmt.Errorf("failed to decode file metadata: %w", err)
 	}
+	fileMeta.FileID = *fileID
 
 	// Owner of the file is needed to reconstruct metadata AAD. Owner
 	// endpoint should populate `owner_username`; fall back to the
@@ -742,7 +1146,11 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 	case "account", "":
 		kek = accountKey
 	case "custom":
-		customPass, err := readPassword(fmt.Sprintf("Enter custom password for '%s': ", *outputPath))
+		label := *outputPath
+		if toStdout {
+			label = *fileID
+		}
+		customPass, err := readPassword(fmt.Sprintf("Enter custom password for '%s': ", label))
 		if err != nil {
 			return fmt.Errorf("failed to read custom password: %w", err)
 		}
@@ -765,6 +1173,10 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 	}
 	defer clearBytes(fek)
 
+	if toStdout {
+		return downloadToWriter(client, session, accountKey, fek, fileMeta, ownerUsername, os.Stdout, *parallel)
+	}
+
 	logVerbose("Downloading %s (%s)...", *outputPath, formatFileSize(fileMeta.SizeBytes))
 
 	// Create output file
@@ -775,7 +1187,7 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 	defer outFile.Close()
 
 	// Stream download by chunks
-	if err := doChunkedDownload(client, session, *fileID, fek, fileMeta, outFile); err != nil {
+	if err := doChunkedDownload(client, session, *fileID, fek, fileMeta, outFile, *parallel); err != nil {
 		// Clean up partial output file on error
 		outFile.Close()
 		os.Remove(*outputPath)
@@ -812,61 +1224,178 @@ func handleDownloadCommand(client *HTTPClient, config *ClientConfig, args []stri
 	return nil
 }
 
+// errDownloadTargetExists means a file appeared at a download's destination
+// while the download was in progress.
+var errDownloadTargetExists = errors.New("destination file already exists")
+
+// downloadToVerifiedFile downloads a file into a temporary dotfile next to
+// dest, checks its plaintext SHA-256 against expectedSHA256 and only then
+// renames it into place. An existing dest is never overwritten.
+func downloadToVerifiedFile(client *HTTPClient, session *AuthSession, fek []byte, meta ServerFileInfo, expectedSHA256, dest string, parallel int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".arkfile-download.*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if err := doChunkedDownload(client, session, meta.FileID, fek, meta, tmp, parallel); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	actualSHA256, err := computeStreamingSHA256(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to compute SHA-256 of download: %w", err)
+	}
+	if actualSHA256 != expectedSHA256 {
+		return fmt.Errorf("SHA-256 mismatch: expected %s, got %s", expectedSHA256, actualSHA256)
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		return errDownloadTargetExists
+	}
+	return os.Rename(tmpPath, dest)
+}
+
 // doChunkedDownload streams chunks from the server and decrypts each one.
 // fileID and meta.ChunkCount are bound into the per-chunk AAD so chunk
 // swap / reorder / cross-file substitution / truncation all fail at the
 // AEAD layer.
-func doChunkedDownload(client *HTTPClient, session *AuthSession, fileID string, fek []byte, meta ServerFileInfo, outFile *os.File) error {
+//
+// parallel workers download and decrypt chunks concurrently. Chunks are
+// written to out strictly in order, each only after its AES-GCM check;
+// at most 2*parallel decrypted chunks wait in memory for an earlier one.
+// The first failure cancels the remaining requests.
+func doChunkedDownload(client *HTTPClient, session *AuthSession, fileID string, fek []byte, meta ServerFileInfo, out io.Writer, parallel int) error {
 	chunkCount := meta.ChunkCount
 	if chunkCount == 0 {
 		chunkCount = 1
 	}
 
-	for i := int64(0); i < chunkCount; i++ {
-		// Download chunk
-		chunkURL := fmt.Sprintf("%s/api/files/%s/chunks/%d", client.baseURL, fileID, i)
-		chunkReq, err := http.NewRequest("GET", chunkURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create chunk request for chunk %d: %w", i, err)
-		}
-		chunkReq.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokens := newSessionTokenSource(client, session)
 
-		chunkResp, err := client.client.Do(chunkReq)
-		if err != nil {
-			return fmt.Errorf("failed to download chunk %d: %w", i, err)
-		}
+	type chunkResult struct {
+		index     int64
+		plaintext []byte
+		err       error
+	}
 
-		if chunkResp.StatusCode != http.StatusOK {
-			chunkResp.Body.Close()
-			return fmt.Errorf("server returned HTTP %d for chunk %d", chunkResp.StatusCode, i)
-		}
+	workers := max(parallel, 1)
+	window := 2 * workers
+	jobs := make(chan int64)
+	slots := make(chan struct{}, window) // one per chunk requested but not yet written
+	results := make(chan chunkResult, window)
 
-		encryptedChunk, err := io.ReadAll(chunkResp.Body)
-		chunkResp.Body.Close()
-		if err != nil {
-			return fmt.Errorf("failed to read chunk %d body: %w", i, err)
+	go func() {
+		defer close(jobs)
+		for i := int64(0); i < chunkCount; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
 
-		// Decrypt the chunk with AAD = (file_id, chunk_index, total_chunks).
-		plaintext, err := decryptChunk(encryptedChunk, fek, fileID, i, chunkCount)
-		if err != nil {
-			return fmt.Errorf("failed to decrypt chunk %d: %w", i, err)
-		}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				plaintext, err := downloadChunk(ctx, client, tokens, fileID, fek, i, chunkCount)
+				results <- chunkResult{index: i, plaintext: plaintext, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-		// Write plaintext to output file
-		if _, err := outFile.Write(plaintext); err != nil {
-			return fmt.Errorf("failed to write chunk %d to output: %w", i, err)
+	pending := make(map[int64][]byte, window)
+	next := int64(0)
+	for res := range results {
+		if res.err != nil {
+			return res.err
 		}
+		pending[res.index] = res.plaintext
 
-		if verbose {
-			progress := float64(i+1) / float64(chunkCount) * 100
-			logVerbose("  Chunk %d/%d (%.1f%%)", i+1, chunkCount, progress)
+		for {
+			plaintext, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+
+			// Write plaintext to output file
+			if _, err := out.Write(plaintext); err != nil {
+				return fmt.Errorf("failed to write chunk %d to output: %w", next, err)
+			}
+			next++
+			<-slots
+
+			if verbose {
+				progress := float64(next) / float64(chunkCount) * 100
+				logVerbose("  Chunk %d/%d (%.1f%%)", next, chunkCount, progress)
+			}
 		}
 	}
 
+	if next != chunkCount {
+		return fmt.Errorf("download stopped after %d of %d chunks", next, chunkCount)
+	}
 	return nil
 }
 
+// downloadChunk fetches chunk i of a file and decrypts it with AAD =
+// (file_id, chunk_index, total_chunks).
+func downloadChunk(ctx context.Context, client *HTTPClient, tokens *sessionTokenSource, fileID string, fek []byte, i, chunkCount int64) ([]byte, error) {
+	session, err := tokens.fresh()
+	if err != nil {
+		return nil, fmt.Errorf("%w: session refresh failed before chunk %d", err, i)
+	}
+
+	chunkURL := fmt.Sprintf("%s/api/files/%s/chunks/%d", client.baseURL, fileID, i)
+	chunkReq, err := http.NewRequestWithContext(ctx, "GET", chunkURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk request for chunk %d: %w", i, err)
+	}
+	chunkReq.Header.Set("Authorization", "Bearer "+session.AccessToken)
+
+	chunkResp, err := client.client.Do(chunkReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download chunk %d: %w", i, err)
+	}
+	defer chunkResp.Body.Close()
+
+	if chunkResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP %d for chunk %d", chunkResp.StatusCode, i)
+	}
+
+	encryptedChunk, err := io.ReadAll(chunkResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %d body: %w", i, err)
+	}
+
+	plaintext, err := decryptChunk(encryptedChunk, fek, fileID, i, chunkCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt chunk %d: %w", i, err)
+	}
+	return plaintext, nil
+}
+
 // ============================================================
 // LIST FILES COMMAND
 // ============================================================
@@ -877,10 +1406,21 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 	rawOutput := fs.Bool("raw", false, "Output raw server response (no decryption)")
 	limit := fs.Int("limit", 100, "Maximum number of files to list")
 	offset := fs.Int("offset", 0, "Offset for pagination")
+	tree := fs.Bool("tree", false, "Show files as a tree of their decrypted folders")
+	versions := fs.Bool("versions", false, "List every stored version of each file, not just the newest")
+	var tagFlags multiStringFlag
+	fs.Var(&tagFlags, "tag", "Only list files with this tag, as KEY or KEY=VALUE (may be repeated)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	filters, err := parseTagFilters(tagFlags)
+	if err != nil {
+		return err
+	}
+	if *rawOutput && len(filters) > 0 {
+		return fmt.Errorf("--tag cannot be used with --raw")
+	}
 
 	session, err := requireSession(config)
 	if err != nil {
@@ -889,6 +1429,9 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 
 	// Fetch file list
 	url := fmt.Sprintf("/api/files?limit=%d&offset=%d", *limit, *offset)
+	if *versions {
+		url += "&versions=all"
+	}
 	req, err := http.NewRequest("GET", client.baseURL+url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -919,35 +1462,75 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 	if err := decodeJSONResponse(resp, &fileList); err != nil {
 		return fmt.Errorf("failed to decode file list: %w", err)
 	}
+	if *versions {
+		fileList.Files = groupFileVersions(fileList.Files)
+	}
+	current := currentVersions(fileList.Files)
+
+	var accountKey []byte
+	agentClient, agentErr := NewAgentClient()
+	if agentErr == nil {
+		// Pass empty token for read-only listing (no session binding check)
+		accountKey, _ = agentClient.GetAccountKey("")
+	}
+
+	annotations := make(map[string]fileAnnotations, len(fileList.Files))
+	if accountKey != nil {
+		for _, f := range fileList.Files {
+			owner := f.OwnerUsername
+			if owner == "" {
+				owner = session.Username
+			}
+			if a, err := decryptFileAnnotations(f, accountKey, owner); err == nil {
+				annotations[f.FileID] = a
+			} else {
+				logVerbose("Cannot read the annotations of %s: %v", f.FileID, err)
+			}
+		}
+	}
+	if len(filters) > 0 {
+		if accountKey == nil {
+			return fmt.Errorf("filtering by tag needs the account key; log in first")
+		}
+		fileList.Files = slices.DeleteFunc(fileList.Files, func(f ServerFileInfo) bool {
+			return !matchesTagFilters(annotations[f.FileID].Tags, filters)
+		})
+	}
 
 	if *jsonOutput {
 		// Decrypt filenames and output as JSON
 		type DecryptedFile struct {
-			FileID       string `json:"file_id"`
-			Filename     string `json:"filename"`
-			SizeBytes    int64  `json:"size_bytes"`
-			SizeReadable string `json:"size_readable"`
-			UploadDate   string `json:"upload_date"`
-			PasswordType string `json:"password_type"`
-			ChunkCount   int64  `json:"chunk_count"`
-		}
-
-		var accountKey []byte
-		agentClient, agentErr := NewAgentClient()
-		if agentErr == nil {
-			// Pass empty token for read-only listing (no session binding check)
-			accountKey, _ = agentClient.GetAccountKey("")
+			FileID        string            `json:"file_id"`
+			Folder        string            `json:"folder"`
+			Filename      string            `json:"filename"`
+			SizeBytes     int64             `json:"size_bytes"`
+			SizeReadable  string            `json:"size_readable"`
+			UploadDate    string            `json:"upload_date"`
+			PasswordType  string            `json:"password_type"`
+			ChunkCount    int64             `json:"chunk_count"`
+			VersionGroup  string            `json:"version_group,omitempty"`
+			VersionNumber int64             `json:"version_number"`
+			VersionCount  int               `json:"version_count"`
+			Current       bool              `json:"current"`
+			Tags          map[string]string `json:"tags,omitempty"`
+			Note          string            `json:"note,omitempty"`
 		}
 
 		decryptedFiles := make([]DecryptedFile, 0, len(fileList.Files))
 		for _, f := range fileList.Files {
 			df := DecryptedFile{
-				FileID:       f.FileID,
-				SizeBytes:    f.SizeBytes,
-				SizeReadable: f.SizeReadable,
-				UploadDate:   f.UploadDate,
-				PasswordType: f.PasswordType,
-				ChunkCount:   f.ChunkCount,
+				FileID:        f.FileID,
+				SizeBytes:     f.SizeBytes,
+				SizeReadable:  f.SizeReadable,
+				UploadDate:    f.UploadDate,
+				PasswordType:  f.PasswordType,
+				ChunkCount:    f.ChunkCount,
+				VersionGroup:  f.VersionGroup,
+				VersionNumber: f.VersionNumber,
+				VersionCount:  f.VersionCount,
+				Current:       f.VersionNumber == current[versionGroupOf(f)],
+				Tags:          annotations[f.FileID].Tags,
+				Note:          annotations[f.FileID].Note,
 			}
 			owner := f.OwnerUsername
 			if owner == "" {
@@ -965,6 +1548,7 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 			} else {
 				df.Filename = "[encrypted]"
 			}
+			df.Folder = displayFolder(f, accountKey, owner)
 			decryptedFiles = append(decryptedFiles, df)
 		}
 
@@ -979,11 +1563,33 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 		return nil
 	}
 
-	var accountKey []byte
-	agentClient, agentErr := NewAgentClient()
-	if agentErr == nil {
-		// Pass empty token for read-only listing (no session binding check)
-		accountKey, _ = agentClient.GetAccountKey("")
+	if *tree {
+		entries := make([]treeFile, 0, len(fileList.Files))
+		for _, f := range fileList.Files {
+			owner := f.OwnerUsername
+			if owner == "" {
+				owner = session.Username
+			}
+			entry := treeFile{Folder: displayFolder(f, accountKey, owner), Filename: "[encrypted]", FileID: f.FileID, Size: f.SizeReadable}
+			if accountKey != nil {
+				if name, err := decryptMetadataField(
+					f.EncryptedFilename, f.FilenameNonce, accountKey,
+					f.FileID, crypto.AADFieldFilename, owner,
+				); err == nil {
+					entry.Filename = name
+				}
+			}
+			if f.VersionNumber < current[versionGroupOf(f)] {
+				entry.Filename += fmt.Sprintf(" (version %d)", f.VersionNumber)
+			}
+			if entry.Size == "" {
+				entry.Size = formatFileSize(f.SizeBytes)
+			}
+			entries = append(entries, entry)
+		}
+		fmt.Print(renderFileTree(entries))
+		fmt.Printf("\nTotal: %d files\n", len(fileList.Files))
+		return nil
 	}
 
 	sep := strings.Repeat("-", 80)
@@ -1011,9 +1617,25 @@ func handleListFilesCommand(client *HTTPClient, config *ClientConfig, args []str
 		fmt.Printf("File %d of %d\n", i+1, len(fileList.Files))
 		fmt.Printf("  File ID:   %s\n", f.FileID)
 		fmt.Printf("  Filename:  %s\n", filename)
+		if folder := displayFolder(f, accountKey, owner); folder != "" {
+			fmt.Printf("  Folder:    %s\n", folder)
+		}
 		fmt.Printf("  Size:      %s\n", size)
 		fmt.Printf("  Uploaded:  %s\n", f.UploadDate)
 		fmt.Printf("  Type:      %s\n", f.PasswordType)
+		if f.VersionCount > 1 {
+			state := "older"
+			if f.VersionNumber == current[versionGroupOf(f)] {
+				state = "current"
+			}
+			fmt.Printf("  Version:   %d, %s (%d versions stored)\n", f.VersionNumber, state, f.VersionCount)
+		}
+		if a := annotations[f.FileID]; len(a.Tags) > 0 {
+			fmt.Printf("  Tags:      %s\n", formatTags(a.Tags))
+		}
+		if note := annotations[f.FileID].Note; note != "" {
+			fmt.Printf("  Note:      %s\n", noteSummary(note))
+		}
 	}
 
 	fmt.Printf("\nTotal: %d files\n", len(fileList.Files))
@@ -1036,8 +1658,11 @@ type ShareInfo struct {
 	RevokedReason interface{} `json:"revoked_reason"`
 	AccessCount   int         `json:"access_count"`
 	MaxAccesses   interface{} `json:"max_accesses"`
+	SuspendedAt   interface{} `json:"suspended_at"`
 	SizeBytes     int64       `json:"size_bytes"`
 	IsActive      bool        `json:"is_active"`
+	FileCount     int         `json:"file_count"`
+	FileIDs       []string    `json:"file_ids"`
 }
 
 type ShareListResponse struct {
@@ -1054,28 +1679,31 @@ type FileMetadataBatchResponse struct {
 }
 
 type EnrichedShareInfo struct {
-	ShareID           string `json:"share_id"`
-	FileID            string `json:"file_id"`
-	ShareURL          string `json:"share_url"`
-	CreatedAt         string `json:"created_at"`
-	ExpiresAt         string `json:"expires_at,omitempty"`
-	RevokedAt         string `json:"revoked_at,omitempty"`
-	RevokedReason     string `json:"revoked_reason,omitempty"`
-	AccessCount       int    `json:"access_count"`
-	MaxAccesses       *int   `json:"max_accesses,omitempty"`
-	SizeBytes         int64  `json:"size_bytes"`
-	IsActive          bool   `json:"is_active"`
-	PasswordType      string `json:"password_type,omitempty"`
-	FilenameLocal     string `json:"filename_local,omitempty"`
-	SizeBytesLocal    int64  `json:"size_bytes_local,omitempty"`
-	SizeReadableLocal string `json:"size_readable_local,omitempty"`
-	SHA256Local       string `json:"sha256_local,omitempty"`
-	MetadataDecrypted bool   `json:"metadata_decrypted"`
+	ShareID           string   `json:"share_id"`
+	FileID            string   `json:"file_id"`
+	ShareURL          string   `json:"share_url"`
+	CreatedAt         string   `json:"created_at"`
+	ExpiresAt         string   `json:"expires_at,omitempty"`
+	RevokedAt         string   `json:"revoked_at,omitempty"`
+	RevokedReason     string   `json:"revoked_reason,omitempty"`
+	AccessCount       int      `json:"access_count"`
+	MaxAccesses       *int     `json:"max_accesses,omitempty"`
+	SuspendedAt       string   `json:"suspended_at,omitempty"`
+	SizeBytes         int64    `json:"size_bytes"`
+	IsActive          bool     `json:"is_active"`
+	PasswordType      string   `json:"password_type,omitempty"`
+	FilenameLocal     string   `json:"filename_local,omitempty"`
+	SizeBytesLocal    int64    `json:"size_bytes_local,omitempty"`
+	SizeReadableLocal string   `json:"size_readable_local,omitempty"`
+	SHA256Local       string   `json:"sha256_local,omitempty"`
+	MetadataDecrypted bool     `json:"metadata_decrypted"`
+	FileCount         int      `json:"file_count,omitempty"`
+	FileIDs           []string `json:"file_ids,omitempty"`
 }
 
 func handleShareCommand(client *HTTPClient, config *ClientConfig, args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("subcommand required: create, list, revoke, download")
+		return fmt.Errorf("subcommand required: create, list, log, update, revoke, download, with, sent, with-me, fetch, unshare")
 	}
 
 	subcommand := args[0]
@@ -1086,12 +1714,26 @@ func handleShareCommand(client *HTTPClient, config *ClientConfig, args []string)
 		return handleShareCreate(client, config, subArgs)
 	case "list":
 		return handleShareList(client, config, subArgs)
+	case "log":
+		return handleShareLog(client, config, subArgs)
+	case "update":
+		return handleShareUpdate(client, config, subArgs)
 	case "revoke":
 		return handleShareRevoke(client, config, subArgs)
 	case "download":
 		return handleShareDownload(client, config, subArgs)
+	case "with":
+		return handleShareWith(client, config, subArgs)
+	case "sent":
+		return handleShareSent(client, config, subArgs)
+	case "with-me":
+		return handleShareWithMe(client, config, subArgs)
+	case "fetch":
+		return handleShareFetch(client, config, subArgs)
+	case "unshare":
+		return handleShareUnshare(client, config, subArgs)
 	default:
-		return fmt.Errorf("unknown share subcommand: %s (use create, list, revoke, or download)", subcommand)
+		return fmt.Errorf("unknown share subcommand: %s (use create, list, log, update, revoke, download, with, sent, with-me, fetch, or unshare)", subcommand)
 	}
 }
 
@@ -1133,9 +1775,11 @@ func parseDuration(s string) (int, error) {
 
 func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string) error {
 	fs := flag.NewFlagSet("share create", flag.ExitOnError)
-	fileID := fs.String("file-id", "", "File ID to share")
+	var fileIDs multiStringFlag
+	fs.Var(&fileIDs, "file-id", "File ID to share (repeat to share several files as one bundle)")
+	folder := fs.String("folder", "", "Share every file in this folder (and below it) as one bundle")
 	expiresStr := fs.String("expires", "24h", "Share expiry duration (e.g. 2m, 24h, 7d; 0 = no expiry)")
-	maxDownloads := fs.Int("max-downloads", 0, "Maximum download count (0 = unlimited)")
+	maxDownloads := fs.Int("max-downloads", 0, "Maximum download count (0 = unlimited; per file for bundles)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -1147,8 +1791,11 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 		return fmt.Errorf("invalid --expires value: %w", err)
 	}
 
-	if *fileID == "" {
-		return fmt.Errorf("--file-id is required")
+	if len(fileIDs) == 0 && *folder == "" {
+		return fmt.Errorf("--file-id or --folder is required")
+	}
+	if len(fileIDs) > 0 && *folder != "" {
+		return fmt.Errorf("use either --file-id or --folder, not both")
 	}
 
 	session, err := requireSession(config)
@@ -1162,8 +1809,13 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 	}
 	defer clearBytes(accountKey)
 
+	if len(fileIDs) > 1 || *folder != "" {
+		return createShareBundle(client, config, session, accountKey, fileIDs, *folder, expiresMinutes, *maxDownloads)
+	}
+	fileID := fileIDs[0]
+
 	// Fetch file metadata to get encrypted FEK and metadata
-	metaReq, err := http.NewRequest("GET", client.baseURL+"/api/files/"+*fileID+"/meta", nil)
+	metaReq, err := http.NewRequest("GET", client.baseURL+"/api/files/"+fileID+"/meta", nil)
 	if err != nil {
 		return fmt.Errorf("failed to create metadata request: %w", err)
 	}
@@ -1200,7 +1852,7 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 	}
 
 	// Unwrap FEK. fileID is bound into the FEK envelope AAD
-	fek, _, err := unwrapFEK(fileMeta.EncryptedFEK, sourceKEK, *fileID)
+	fek, _, err := unwrapFEK(fileMeta.EncryptedFEK, sourceKEK, fileID)
 	if err != nil {
 		return fmt.Errorf("failed to unwrap FEK: %w", err)
 	}
@@ -1218,7 +1870,7 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 	if fileMeta.EncryptedFilename != "" && fileMeta.FilenameNonce != "" {
 		if name, err := decryptMetadataField(
 			fileMeta.EncryptedFilename, fileMeta.FilenameNonce, accountKey,
-			*fileID, crypto.AADFieldFilename, ownerUsername,
+			fileID, crypto.AADFieldFilename, ownerUsername,
 		); err == nil {
 			filename = name
 		} else {
@@ -1230,7 +1882,7 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 	if fileMeta.EncryptedSHA256 != "" && fileMeta.SHA256Nonce != "" {
 		if hash, err := decryptMetadataField(
 			fileMeta.EncryptedSHA256, fileMeta.SHA256Nonce, accountKey,
-			*fileID, crypto.AADFieldSha256, ownerUsername,
+			fileID, crypto.AADFieldSha256, ownerUsername,
 		); err == nil {
 			sha256hex = hash
 		} else {
@@ -1238,19 +1890,9 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 		}
 	}
 
-	// Generate client-side share ID: 32 random bytes -> base64url without padding (43 chars)
-	// Retry if first character is '-' or '_' to avoid issues with shell tools and URL parsers.
-	// Probability of retry: 2/64 (~3%), so this almost always succeeds on the first attempt.
-	var shareID string
-	shareIDBytes := make([]byte, 32)
-	for {
-		if _, err := rand.Read(shareIDBytes); err != nil {
-			return fmt.Errorf("failed to generate share ID: %w", err)
-		}
-		shareID = base64URLEncode(shareIDBytes)
-		if shareID[0] != '-' && shareID[0] != '_' {
-			break
-		}
+	shareID, err := newClientShareID()
+	if err != nil {
+		return err
 	}
 
 	// Generate download token
@@ -1259,77 +1901,116 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 		return fmt.Errorf("failed to generate download token: %w", err)
 	}
 
+	// Build the ShareEnvelope JSON: {fek, download_token, filename, size_bytes, sha256}
+	envelopeJSON, err := crypto.CreateShareEnvelope(fek, downloadToken, filename, fileMeta.SizeBytes, sha256hex)
+	if err != nil {
+		return fmt.Errorf("failed to create share envelope: %w", err)
+	}
+
+	// Build the request payload matching the server's ShareRequest struct
+	sharePayload, err := sealShareEnvelope(envelopeJSON, downloadToken, shareID, fileID, expiresMinutes, *maxDownloads)
+	if err != nil {
+		return err
+	}
+	sharePayload["file_id"] = fileID
+
+	shareURL, err := postShare(client, session, sharePayload)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Share created!\n")
+	fmt.Printf("  File: %s\n", filename)
+	printShareCreated(shareID, shareURL, expiresMinutes)
+	return nil
+}
+
+// newClientShareID generates a client-side share ID: 32 random bytes ->
+// base64url without padding (43 chars).
+// Retry if first character is '-' or '_' to avoid issues with shell tools and URL parsers.
+// Probability of retry: 2/64 (~3%), so this almost always succeeds on the first attempt.
+func newClientShareID() (string, error) {
+	shareIDBytes := make([]byte, 32)
+	for {
+		if _, err := rand.Read(shareIDBytes); err != nil {
+			return "", fmt.Errorf("failed to generate share ID: %w", err)
+		}
+		shareID := base64URLEncode(shareIDBytes)
+		if shareID[0] != '-' && shareID[0] != '_' {
+			return shareID, nil
+		}
+	}
+}
+
+// sealShareEnvelope prompts for the share password, encrypts envelopeJSON
+// with the derived share KEK and returns the share request payload without
+// its file fields. aadFileID is the file the envelope is bound to.
+func sealShareEnvelope(envelopeJSON, downloadToken []byte, shareID, aadFileID string, expiresMinutes, maxDownloads int) (map[string]interface{}, error) {
 	// Always prompt for share password (shares require password per design)
 	sharePass, err := readPasswordWithStrengthCheck("Enter share password: ", "share")
 	if err != nil {
-		return fmt.Errorf("failed to read share password: %w", err)
+		return nil, fmt.Errorf("failed to read share password: %w", err)
 	}
 	defer clearBytes(sharePass)
 
 	// Generate share salt and derive share KEK
 	saltB64, err := crypto.GenerateShareSalt()
 	if err != nil {
-		return fmt.Errorf("failed to generate share salt: %w", err)
+		return nil, fmt.Errorf("failed to generate share salt: %w", err)
 	}
 
 	shareKEK, err := crypto.DeriveShareKey(string(sharePass), saltB64)
 	if err != nil {
-		return fmt.Errorf("failed to derive share KEK: %w", err)
+		return nil, fmt.Errorf("failed to derive share KEK: %w", err)
 	}
 	defer clearBytes(shareKEK)
 
-	// Build the ShareEnvelope JSON: {fek, download_token, filename, size_bytes, sha256}
-	envelopeJSON, err := crypto.CreateShareEnvelope(fek, downloadToken, filename, fileMeta.SizeBytes, sha256hex)
-	if err != nil {
-		return fmt.Errorf("failed to create share envelope: %w", err)
-	}
-
 	// Encrypt envelope with AES-GCM-AAD, binding it to this specific share_id + file_id
-	aad := crypto.CreateAAD(shareID, *fileID)
+	aad := crypto.CreateAAD(shareID, aadFileID)
 	encryptedEnvelope, err := crypto.EncryptGCMWithAAD(envelopeJSON, shareKEK, aad)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt share envelope: %w", err)
+		return nil, fmt.Errorf("failed to encrypt share envelope: %w", err)
 	}
 
-	// Encode encrypted envelope and download token for server storage
-	encryptedEnvelopeB64 := encodeBase64(encryptedEnvelope)
-	downloadTokenB64 := encodeBase64(downloadToken)
-
 	// Hash the download token for server-side verification
-	downloadTokenHash, err := crypto.HashDownloadToken(downloadTokenB64)
+	downloadTokenHash, err := crypto.HashDownloadToken(encodeBase64(downloadToken))
 	if err != nil {
-		return fmt.Errorf("failed to hash download token: %w", err)
+		return nil, fmt.Errorf("failed to hash download token: %w", err)
 	}
 
-	// Build the request payload matching the server's ShareRequest struct
 	sharePayload := map[string]interface{}{
 		"share_id":            shareID,
-		"file_id":             *fileID,
 		"salt":                saltB64,
-		"encrypted_envelope":  encryptedEnvelopeB64,
+		"encrypted_envelope":  encodeBase64(encryptedEnvelope),
 		"download_token_hash": downloadTokenHash,
 	}
 
-	if *maxDownloads > 0 {
-		sharePayload["max_accesses"] = *maxDownloads
+	if maxDownloads > 0 {
+		sharePayload["max_accesses"] = maxDownloads
 	}
 
 	if expiresMinutes > 0 {
 		sharePayload["expires_after_minutes"] = expiresMinutes
 	}
 
+	return sharePayload, nil
+}
+
+// postShare creates the share on the server and returns its URL.
+func postShare(client *HTTPClient, session *AuthSession, sharePayload map[string]interface{}) (string, error) {
 	createResp, err := client.makeRequest("POST", "/api/shares", sharePayload, session.AccessToken)
 	if err != nil {
-		return fmt.Errorf("failed to create share: %w", err)
+		return "", fmt.Errorf("failed to create share: %w", err)
 	}
 
 	shareURL := ""
 	if val, ok := createResp.Data["share_url"].(string); ok {
 		shareURL = val
 	}
+	return shareURL, nil
+}
 
-	fmt.Printf("Share created!\n")
-	fmt.Printf("  File: %s\n", filename)
+func printShareCreated(shareID, shareURL string, expiresMinutes int) {
 	fmt.Printf("  Share ID: %s\n", shareID)
 	if shareURL != "" {
 		fmt.Printf("  Share URL: %s\n", shareURL)
@@ -1340,8 +2021,6 @@ func handleShareCreate(client *HTTPClient, config *ClientConfig, args []string)
 		fmt.Printf("  Expires: never\n")
 	}
 	fmt.Printf("  Password protected: yes\n")
-
-	return nil
 }
 
 func handleShareList(client *HTTPClient, config *ClientConfig, args []string) error {
@@ -1424,7 +2103,11 @@ func handleShareList(client *HTTPClient, config *ClientConfig, args []string) er
 		fmt.Println(sep)
 		fmt.Printf("Share %d of %d\n", i+1, len(enrichedShares))
 		fmt.Printf("  Share ID:  %s\n", s.ShareID)
-		fmt.Printf("  File ID:   %s\n", s.FileID)
+		if s.FileCount > 1 {
+			fmt.Printf("  Files:     %d (%s)\n", s.FileCount, strings.Join(s.FileIDs, ", "))
+		} else {
+			fmt.Printf("  File ID:   %s\n", s.FileID)
+		}
 
 		expires := "never"
 		if s.ExpiresAt != "" {
@@ -1444,6 +2127,10 @@ func handleShareList(client *HTTPClient, config *ClientConfig, args []string) er
 		}
 		fmt.Printf("  Active:    %s\n", active)
 
+		if s.SuspendedAt != "" {
+			fmt.Printf("  Suspended: %s\n", s.SuspendedAt)
+		}
+
 		if s.RevokedAt != "" {
 			reason := s.RevokedReason
 			if reason == "" {
@@ -1454,8 +2141,10 @@ func handleShareList(client *HTTPClient, config *ClientConfig, args []string) er
 
 		fmt.Printf("  Filename:  %s\n", defaultString(s.FilenameLocal, "[encrypted]"))
 		fmt.Printf("  Size:      %s\n", defaultString(s.SizeReadableLocal, formatFileSize(s.SizeBytes)))
-		fmt.Printf("  SHA-256:   %s\n", defaultString(s.SHA256Local, "[encrypted]"))
-		fmt.Printf("  Type:      %s\n", defaultString(s.PasswordType, "unknown"))
+		if s.FileCount <= 1 {
+			fmt.Printf("  SHA-256:   %s\n", defaultString(s.SHA256Local, "[encrypted]"))
+			fmt.Printf("  Type:      %s\n", defaultString(s.PasswordType, "unknown"))
+		}
 		fmt.Printf("  URL:       %s\n", s.ShareURL)
 	}
 	fmt.Println(sep)
@@ -1471,7 +2160,7 @@ func handleShareList(client *HTTPClient, config *ClientConfig, args []string) er
 
 func enrichShareList(client *HTTPClient, session *AuthSession, shares []ShareInfo) ([]EnrichedShareInfo, error) {
 	fileIDs := collectShareFileIDs(shares)
-	metadataByFileID, err := fetchMetadataBatchForShares(client, session, fileIDs)
+	metadataByFileID, err := fetchMetadataBatch(client, session, fileIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -1495,6 +2184,19 @@ func enrichShareList(client *HTTPClient, session *AuthSession, shares []ShareInf
 			FilenameLocal:     "[encrypted]",
 			SizeBytesLocal:    share.SizeBytes,
 			SizeReadableLocal: formatFileSize(share.SizeBytes),
+			FileCount:         share.FileCount,
+		}
+		if suspendedStr, ok := share.SuspendedAt.(string); ok {
+			item.SuspendedAt = suspendedStr
+		}
+
+		// A bundle's size is the total of its files, and its names are
+		// only known to the recipient's envelope listing
+		if share.FileCount > 1 {
+			item.FileIDs = share.FileIDs
+			item.FilenameLocal = fmt.Sprintf("[%d files]", share.FileCount)
+			enriched = append(enriched, item)
+			continue
 		}
 
 		if expStr, ok := share.ExpiresAt.(string); ok {
@@ -1567,7 +2269,7 @@ func collectShareFileIDs(shares []ShareInfo) []string {
 	return fileIDs
 }
 
-func fetchMetadataBatchForShares(client *HTTPClient, session *AuthSession, fileIDs []string) (map[string]ServerFileInfo, error) {
+func fetchMetadataBatch(client *HTTPClient, session *AuthSession, fileIDs []string) (map[string]ServerFileInfo, error) {
 	if len(fileIDs) == 0 {
 		return map[string]ServerFileInfo{}, nil
 	}
@@ -1658,7 +2360,9 @@ func handleShareRevoke(client *HTTPClient, config *ClientConfig, args []string)
 func handleShareDownload(client *HTTPClient, config *ClientConfig, args []string) error {
 	fs := flag.NewFlagSet("share download", flag.ExitOnError)
 	shareID := fs.String("share-id", "", "Share ID to download")
-	outputPath := fs.String("output", "", "Output file path (default: filename from envelope)")
+	outputPath := fs.String("output", "", "Output file path (default: filename from envelope); output directory with --all")
+	fileID := fs.String("file-id", "", "Download one file of a share bundle")
+	all := fs.Bool("all", false, "Download every file of a share bundle")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -1667,108 +2371,201 @@ func handleShareDownload(client *HTTPClient, config *ClientConfig, args []string
 	if *shareID == "" {
 		return fmt.Errorf("--share-id is required")
 	}
+	if *all && *fileID != "" {
+		return fmt.Errorf("use either --all or --file-id, not both")
+	}
 
 	// Step 1: Fetch share envelope (no auth required — public endpoint)
-	// GET /api/public/shares/:id/envelope -> {share_id, file_id, salt, encrypted_envelope, size_bytes}
-	envelopeURL := client.baseURL + "/api/public/shares/" + *shareID + "/envelope"
+	shareEnvelopeData, err := fetchShareEnvelope(client, *shareID)
+	if err != nil {
+		return err
+	}
+
+	// Step 2: Prompt for share password and decrypt the share envelope
+	sharePass, err := readPassword("Enter share password: ")
+	if err != nil {
+		return fmt.Errorf("failed to read share password: %w", err)
+	}
+	defer clearBytes(sharePass)
+
+	envelope, err := openShareEnvelope(shareEnvelopeData, sharePass)
+	if err != nil {
+		return err
+	}
+
+	downloadToken, err := decodeBase64(envelope.DownloadToken)
+	if err != nil {
+		return fmt.Errorf("failed to decode download token from envelope: %w", err)
+	}
+	downloadTokenB64 := encodeBase64(downloadToken)
+
+	if envelope.IsBundle() {
+		return downloadShareBundle(client, *shareID, shareEnvelopeData, envelope, downloadTokenB64, *fileID, *outputPath, *all)
+	}
+	if *all || *fileID != "" {
+		return fmt.Errorf("share %s contains a single file; --all and --file-id only apply to share bundles", *shareID)
+	}
+
+	// Decode FEK from envelope
+	fek, err := decodeBase64(envelope.FEK)
+	if err != nil {
+		return fmt.Errorf("failed to decode FEK from envelope: %w", err)
+	}
+	defer clearBytes(fek)
+
+	// Determine output path from envelope filename
+	filename := envelope.Filename
+	if *outputPath == "" {
+		if filename != "" {
+			*outputPath = filename
+		} else {
+			*outputPath = *shareID + ".bin"
+		}
+	}
+
+	fmt.Printf("Downloading shared file...\n")
+	if filename != "" {
+		fmt.Printf("  Filename: %s\n", filename)
+	}
+
+	sizeBytes, err := downloadShareFile(client, *shareID, "", fek, downloadTokenB64, *outputPath, false, true)
+	if err != nil {
+		return err
+	}
+	if shareEnvelopeData.SizeBytes != 0 {
+		sizeBytes = shareEnvelopeData.SizeBytes
+	}
+
+	fmt.Printf("Download complete!\n")
+	fmt.Printf("  Saved to: %s\n", *outputPath)
+	fmt.Printf("  Size: %s\n", formatFileSize(sizeBytes))
+
+	// Verify SHA-256 integrity against envelope hash
+	if envelope.SHA256 != "" {
+		actualSHA256, shaErr := computeStreamingSHA256(*outputPath)
+		if shaErr != nil {
+			fmt.Printf("  [!] WARNING: Could not compute SHA-256 for verification: %v\n", shaErr)
+		} else if actualSHA256 == envelope.SHA256 {
+			fmt.Printf("  [OK] SHA-256 verified: %s\n", actualSHA256)
+		} else {
+			return fmt.Errorf("[FAIL] SHA-256 mismatch!\n  Expected: %s\n  Got:      %s\n  File may be corrupt or tampered", envelope.SHA256, actualSHA256)
+		}
+	}
+
+	return nil
+}
+
+// shareEnvelopeResponse is the public envelope response for a share. Files
+// is only set for share bundles.
+type shareEnvelopeResponse struct {
+	ShareID           string              `json:"share_id"`
+	FileID            string              `json:"file_id"`
+	Salt              string              `json:"salt"`
+	EncryptedEnvelope string              `json:"encrypted_envelope"`
+	SizeBytes         int64               `json:"size_bytes"`
+	Files             []shareEnvelopeFile `json:"files"`
+}
+
+// shareEnvelopeFile is a member of a share bundle as reported by the server.
+type shareEnvelopeFile struct {
+	FileID    string `json:"file_id"`
+	SizeBytes int64  `json:"size_bytes"`
+	Available bool   `json:"available"`
+}
+
+// fetchShareEnvelope fetches the encrypted envelope and salt of a share.
+// GET /api/public/shares/:id/envelope -> {share_id, file_id, salt, encrypted_envelope, size_bytes[, files]}
+func fetchShareEnvelope(client *HTTPClient, shareID string) (*shareEnvelopeResponse, error) {
+	envelopeURL := client.baseURL + "/api/public/shares/" + shareID + "/envelope"
 	envelopeReq, err := http.NewRequest("GET", envelopeURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create envelope request: %w", err)
+		return nil, fmt.Errorf("failed to create envelope request: %w", err)
 	}
 
 	envelopeResp, err := client.client.Do(envelopeReq)
 	if err != nil {
-		return fmt.Errorf("failed to fetch share envelope: %w", err)
+		return nil, fmt.Errorf("failed to fetch share envelope: %w", err)
 	}
 	defer envelopeResp.Body.Close()
 
 	if envelopeResp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(envelopeResp.Body)
-		return fmt.Errorf("share not found or expired (HTTP %d): %s", envelopeResp.StatusCode, string(body))
+		return nil, fmt.Errorf("share not found or expired (HTTP %d): %s", envelopeResp.StatusCode, string(body))
 	}
 
-	var shareEnvelopeData struct {
-		ShareID           string `json:"share_id"`
-		FileID            string `json:"file_id"`
-		Salt              string `json:"salt"`
-		EncryptedEnvelope string `json:"encrypted_envelope"`
-		SizeBytes         int64  `json:"size_bytes"`
-	}
+	var shareEnvelopeData shareEnvelopeResponse
 	if err := decodeJSONResponse(envelopeResp, &shareEnvelopeData); err != nil {
-		return fmt.Errorf("failed to decode share envelope response: %w", err)
+		return nil, fmt.Errorf("failed to decode share envelope response: %w", err)
 	}
 
 	if shareEnvelopeData.Salt == "" {
-		return fmt.Errorf("share envelope missing salt")
+		return nil, fmt.Errorf("share envelope missing salt")
 	}
 	if shareEnvelopeData.EncryptedEnvelope == "" {
-		return fmt.Errorf("share envelope missing encrypted_envelope")
+		return nil, fmt.Errorf("share envelope missing encrypted_envelope")
 	}
 	if shareEnvelopeData.FileID == "" {
-		return fmt.Errorf("share envelope missing file_id")
-	}
-
-	// Step 2: Prompt for share password and derive share KEK
-	sharePass, err := readPassword("Enter share password: ")
-	if err != nil {
-		return fmt.Errorf("failed to read share password: %w", err)
+		return nil, fmt.Errorf("share envelope missing file_id")
 	}
-	defer clearBytes(sharePass)
+	return &shareEnvelopeData, nil
+}
 
-	shareKEK, err := crypto.DeriveShareKey(string(sharePass), shareEnvelopeData.Salt)
+// openShareEnvelope derives the share KEK from the share password and
+// decrypts and parses the share envelope.
+func openShareEnvelope(data *shareEnvelopeResponse, sharePass []byte) (*crypto.ShareEnvelope, error) {
+	shareKEK, err := crypto.DeriveShareKey(string(sharePass), data.Salt)
 	if err != nil {
-		return fmt.Errorf("failed to derive share KEK: %w", err)
+		return nil, fmt.Errorf("failed to derive share KEK: %w", err)
 	}
 	defer clearBytes(shareKEK)
 
-	// Step 3: Decrypt the share envelope with AES-GCM-AAD
+	// Decrypt the share envelope with AES-GCM-AAD
 	// AAD = shareID + fileID (binds envelope to this specific share)
-	encryptedEnvelope, err := decodeBase64(shareEnvelopeData.EncryptedEnvelope)
+	encryptedEnvelope, err := decodeBase64(data.EncryptedEnvelope)
 	if err != nil {
-		return fmt.Errorf("failed to decode encrypted envelope: %w", err)
+		return nil, fmt.Errorf("failed to decode encrypted envelope: %w", err)
 	}
 
-	aad := crypto.CreateAAD(shareEnvelopeData.ShareID, shareEnvelopeData.FileID)
+	aad := crypto.CreateAAD(data.ShareID, data.FileID)
 	envelopeJSON, err := crypto.DecryptGCMWithAAD(encryptedEnvelope, shareKEK, aad)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt share envelope (wrong password?): %w", err)
+		return nil, fmt.Errorf("failed to decrypt share envelope (wrong password?): %w", err)
 	}
 
-	// Step 4: Parse the share envelope JSON to get FEK, download token, filename, sha256
+	// Parse the share envelope JSON to get FEK(s), download token, filename, sha256
 	envelope, err := crypto.ParseShareEnvelope(envelopeJSON)
 	if err != nil {
-		return fmt.Errorf("failed to parse share envelope: %w", err)
+		return nil, fmt.Errorf("failed to parse share envelope: %w", err)
 	}
+	return envelope, nil
+}
 
-	// Decode FEK and download token from envelope
-	fek, err := decodeBase64(envelope.FEK)
-	if err != nil {
-		return fmt.Errorf("failed to decode FEK from envelope: %w", err)
+// downloadShareFile streams and decrypts one shared file to outputPath and
+// returns its encrypted size. fileID names the member of a share bundle and
+// is empty for single-file shares. With exclusive set an existing file at
+// outputPath is never replaced. A partial file is removed on failure.
+func downloadShareFile(client *HTTPClient, shareID, fileID string, fek []byte, downloadTokenB64, outputPath string, exclusive, showProgress bool) (int64, error) {
+	query := ""
+	if fileID != "" {
+		query = "?file_id=" + url.QueryEscape(fileID)
 	}
-	defer clearBytes(fek)
 
-	downloadToken, err := decodeBase64(envelope.DownloadToken)
-	if err != nil {
-		return fmt.Errorf("failed to decode download token from envelope: %w", err)
-	}
-	downloadTokenB64 := encodeBase64(downloadToken)
-
-	// Step 5: Get chunk metadata
+	// Get chunk metadata
 	// GET /api/public/shares/:id/metadata -> {file_id, size_bytes, chunk_count, chunk_size_bytes}
-	chunkMetaURL := client.baseURL + "/api/public/shares/" + *shareID + "/metadata"
+	chunkMetaURL := client.baseURL + "/api/public/shares/" + shareID + "/metadata" + query
 	chunkMetaReq, err := http.NewRequest("GET", chunkMetaURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create chunk metadata request: %w", err)
+		return 0, fmt.Errorf("failed to create chunk metadata request: %w", err)
 	}
 
 	chunkMetaResp, err := client.client.Do(chunkMetaReq)
 	if err != nil {
-		return fmt.Errorf("failed to fetch chunk metadata: %w", err)
+		return 0, fmt.Errorf("failed to fetch chunk metadata: %w", err)
 	}
 	defer chunkMetaResp.Body.Close()
 
 	if chunkMetaResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get chunk metadata (HTTP %d)", chunkMetaResp.StatusCode)
+		return 0, fmt.Errorf("failed to get chunk metadata (HTTP %d)", chunkMetaResp.StatusCode)
 	}
 
 	var chunkMeta struct {
@@ -1778,7 +2575,7 @@ func handleShareDownload(client *HTTPClient, config *ClientConfig, args []string
 		ChunkSizeBytes int64  `json:"chunk_size_bytes"`
 	}
 	if err := decodeJSONResponse(chunkMetaResp, &chunkMeta); err != nil {
-		return fmt.Errorf("failed to decode chunk metadata: %w", err)
+		return 0, fmt.Errorf("failed to decode chunk metadata: %w", err)
 	}
 
 	chunkCount := chunkMeta.ChunkCount
@@ -1786,44 +2583,31 @@ func handleShareDownload(client *HTTPClient, config *ClientConfig, args []string
 		chunkCount = 1
 	}
 
-	// Determine output path from envelope filename
-	filename := envelope.Filename
-	if *outputPath == "" {
-		if filename != "" {
-			*outputPath = filename
-		} else {
-			*outputPath = *shareID + ".bin"
-		}
-	}
-
-	sizeBytes := shareEnvelopeData.SizeBytes
-	if sizeBytes == 0 {
-		sizeBytes = chunkMeta.SizeBytes
+	if showProgress {
+		fmt.Printf("  Size: %s\n", formatFileSize(chunkMeta.SizeBytes))
+		fmt.Printf("  Chunks: %d\n", chunkCount)
 	}
 
-	fmt.Printf("Downloading shared file...\n")
-	if filename != "" {
-		fmt.Printf("  Filename: %s\n", filename)
+	// Create output file
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if exclusive {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
 	}
-	fmt.Printf("  Size: %s\n", formatFileSize(sizeBytes))
-	fmt.Printf("  Chunks: %d\n", chunkCount)
-
-	// Step 6: Create output file
-	outFile, err := os.OpenFile(*outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	outFile, err := os.OpenFile(outputPath, flags, 0600)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return 0, fmt.Errorf("failed to create output file: %w", err)
 	}
 
-	// Step 7: Stream download + decrypt each chunk
+	// Stream download + decrypt each chunk
 	// GET /api/public/shares/:id/chunks/:chunkIndex with X-Download-Token header
 	downloadFailed := false
 	for i := int64(0); i < chunkCount; i++ {
-		chunkURL := fmt.Sprintf("%s/api/public/shares/%s/chunks/%d", client.baseURL, *shareID, i)
+		chunkURL := fmt.Sprintf("%s/api/public/shares/%s/chunks/%d%s", client.baseURL, shareID, i, query)
 		chunkReq, err := http.NewRequest("GET", chunkURL, nil)
 		if err != nil {
 			outFile.Close()
-			os.Remove(*outputPath)
-			return fmt.Errorf("failed to create chunk request: %w", err)
+			os.Remove(outputPath)
+			return 0, fmt.Errorf("failed to create chunk request: %w", err)
 		}
 		// Download token authenticates the chunk download (no user auth required)
 		chunkReq.Header.Set("X-Download-Token", downloadTokenB64)
@@ -1875,27 +2659,11 @@ func handleShareDownload(client *HTTPClient, config *ClientConfig, args []string
 	outFile.Close()
 
 	if downloadFailed {
-		os.Remove(*outputPath)
-		return fmt.Errorf("download failed: %w", err)
+		os.Remove(outputPath)
+		return 0, fmt.Errorf("download failed: %w", err)
 	}
 
-	fmt.Printf("Download complete!\n")
-	fmt.Printf("  Saved to: %s\n", *outputPath)
-	fmt.Printf("  Size: %s\n", formatFileSize(sizeBytes))
-
-	// Step 8: Verify SHA-256 integrity against envelope hash
-	if envelope.SHA256 != "" {
-		actualSHA256, shaErr := computeStreamingSHA256(*outputPath)
-		if shaErr != nil {
-			fmt.Printf("  [!] WARNING: Could not compute SHA-256 for verification: %v\n", shaErr)
-		} else if actualSHA256 == envelope.SHA256 {
-			fmt.Printf("  [OK] SHA-256 verified: %s\n", actualSHA256)
-		} else {
-			return fmt.Errorf("[FAIL] SHA-256 mismatch!\n  Expected: %s\n  Got:      %s\n  File may be corrupt or tampered", envelope.SHA256, actualSHA256)
-		}
-	}
-
-	return nil
+	return chunkMeta.SizeBytes, nil
 }
 
 // ============================================================
@@ -2189,9 +2957,13 @@ func handleDeleteFileCommand(client *HTTPClient, config *ClientConfig, args []st
 	fs := flag.NewFlagSet("delete-file", flag.ExitOnError)
 	fileID := fs.String("file-id", "", "File ID to delete")
 	confirm := fs.Bool("confirm", false, "Skip confirmation prompt")
+	permanent := fs.Bool("permanent", false, "Delete the file for good instead of moving it to the trash")
 
 	fs.Usage = func() {
-		fmt.Printf("Usage: arkfile-client delete-file --file-id FILE_ID [--confirm]\n\nPermanently delete a file from the server.\n")
+		fmt.Printf("Usage: arkfile-client delete-file --file-id FILE_ID [--permanent] [--confirm]\n\n" +
+			"Move a file to the trash. It can be restored with 'arkfile-client restore' until\n" +
+			"the server purges it (see 'arkfile-client trash'). --permanent deletes the file\n" +
+			"from the server immediately, including a file that is already in the trash.\n")
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -2207,7 +2979,7 @@ func handleDeleteFileCommand(client *HTTPClient, config *ClientConfig, args []st
 		return err
 	}
 
-	if !*confirm {
+	if *permanent && !*confirm {
 		fmt.Println("WARNING: This will permanently delete the file from the server.")
 		fmt.Println("Consider using 'export' first to save an offline-decryptable backup (.arkbackup)")
 		fmt.Println("before deleting, if this file is important.")
@@ -2223,7 +2995,11 @@ func handleDeleteFileCommand(client *HTTPClient, config *ClientConfig, args []st
 		}
 	}
 
-	_, err = client.makeRequest("DELETE", "/api/files/"+*fileID, nil, session.AccessToken)
+	endpoint := "/api/files/" + *fileID
+	if *permanent {
+		endpoint += "?permanent=true"
+	}
+	resp, err := client.makeRequest("DELETE", endpoint, nil, session.AccessToken)
 	if err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
@@ -2236,6 +3012,11 @@ func handleDeleteFileCommand(client *HTTPClient, config *ClientConfig, args []st
 		}
 	}
 
+	// The server deletes immediately when its trash is disabled
+	if !*permanent && strings.Contains(resp.Message, "trash") {
+		fmt.Printf("File %s moved to trash. Restore it with: arkfile-client restore --file-id %s\n", *fileID, *fileID)
+		return nil
+	}
 	fmt.Printf("File %s deleted successfully.\n", *fileID)
 	return nil
 }
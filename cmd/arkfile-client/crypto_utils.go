@@ -111,10 +111,10 @@ func encryptMetadata(filename, sha256hex string, accountKey []byte, fileID, owne
 	return encFilenameB64, fnNonceB64, encSHA256B64, shaNonceB64, nil
 }
 
-// decryptMetadataField decrypts a single metadata field (filename or
-// SHA-256 digest), verifying the AAD bound to (fileID, fieldLabel,
-// ownerUsername). fieldLabel must be one of crypto.AADFieldFilename or
-// crypto.AADFieldSha256.
+// decryptMetadataField decrypts a single metadata field (filename, SHA-256
+// digest, folder path, tags or note), verifying the AAD bound to (fileID,
+// fieldLabel, ownerUsername). fieldLabel must be one of the crypto.AADField
+// constants.
 func decryptMetadataField(encDataB64, nonceB64 string, accountKey []byte, fileID, fieldLabel, ownerUsername string) (string, error) {
 	if fileID == "" {
 		return "", fmt.Errorf("fileID cannot be empty")
@@ -147,6 +147,39 @@ func decryptMetadataField(encDataB64, nonceB64 string, accountKey []byte, fileID
 	return string(plaintext), nil
 }
 
+// encryptMetadataField encrypts a single metadata field with the account
+// key, binding it to (fileID, fieldLabel, ownerUsername). It is the inverse
+// of decryptMetadataField and returns the base64 ciphertext and nonce.
+func encryptMetadataField(plaintext string, accountKey []byte, fileID, fieldLabel, ownerUsername string) (encB64, nonceB64 string, err error) {
+	if fileID == "" {
+		return "", "", fmt.Errorf("fileID cannot be empty")
+	}
+	if ownerUsername == "" {
+		return "", "", fmt.Errorf("ownerUsername cannot be empty")
+	}
+
+	aad := crypto.BuildMetadataFieldAAD(fileID, fieldLabel, ownerUsername)
+	encRaw, err := crypto.EncryptGCMWithAAD([]byte(plaintext), accountKey, aad)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt %s: %w", fieldLabel, err)
+	}
+
+	nonceSize := crypto.AesGcmNonceSize()
+	return base64.StdEncoding.EncodeToString(encRaw[nonceSize:]),
+		base64.StdEncoding.EncodeToString(encRaw[:nonceSize]), nil
+}
+
+// encryptFolderPath encrypts a file's folder path with the account key,
+// binding it to (fileID, crypto.AADFieldFolder, ownerUsername) exactly like
+// the filename. folder must already be cleaned with cleanFolderPath; the
+// top level ("") is never encrypted and should be sent as no folder at all.
+func encryptFolderPath(folder string, accountKey []byte, fileID, ownerUsername string) (encFolderB64, folderNonceB64 string, err error) {
+	if folder == "" {
+		return "", "", fmt.Errorf("folder cannot be empty")
+	}
+	return encryptMetadataField(folder, accountKey, fileID, crypto.AADFieldFolder, ownerUsername)
+}
+
 // wrapFEK encrypts the FEK with a KEK (account key or custom key) under
 // AAD = BuildFEKEnvelopeAAD(fileID, keyTypeByte), then prepends the
 // 2-byte envelope header [0x01][keyTypeByte]. Returns the base64-encoded
@@ -299,7 +332,7 @@ func isSeekableFile(filePath string) error {
 	}
 
 	if !info.Mode().IsRegular() {
-		return fmt.Errorf("upload requires a seekable file (regular file on disk). Stdin/pipe input is not supported")
+		return fmt.Errorf("upload requires a seekable file (regular file on disk); use `upload - --name NAME` for stdin/pipe input")
 	}
 
 	return nil
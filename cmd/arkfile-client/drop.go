@@ -0,0 +1,635 @@
+// drop.go - File drops: links through which others upload files into your
+// vault.
+//
+// `drop create` generates a hybrid X25519 + ML-KEM-768 key pair, wraps the
+// private key with the account key and registers the drop; the printed link
+// carries the public key fingerprint in its fragment. `drop send` needs no
+// account: it checks the served key against the fingerprint, seals the file
+// key to it and uploads the file. Received files stay sealed to the drop
+// until `drop accept` re-wraps them with the account key.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// dropInfo is the public view of a drop served to senders.
+type dropInfo struct {
+	DropID           string `json:"drop_id"`
+	PublicKey        string `json:"public_key"`
+	ExpiresAt        string `json:"expires_at"`
+	RemainingBytes   int64  `json:"remaining_bytes"`
+	RemainingUploads *int64 `json:"remaining_uploads"`
+}
+
+// dropDetails is the owner's view of one drop.
+type dropDetails struct {
+	DropID              string `json:"drop_id"`
+	EncryptedPrivateKey string `json:"encrypted_private_key"`
+	Uploads             []struct {
+		FileID   string `json:"file_id"`
+		Status   string `json:"status"`
+		InVault  bool   `json:"in_vault"`
+		Accepted bool   `json:"accepted"`
+	} `json:"uploads"`
+}
+
+// dropSummary is one entry of GET /api/drops.
+type dropSummary struct {
+	DropID       string  `json:"drop_id"`
+	CreatedAt    string  `json:"created_at"`
+	ExpiresAt    string  `json:"expires_at"`
+	RevokedAt    *string `json:"revoked_at"`
+	MaxBytes     int64   `json:"max_bytes"`
+	MaxUploads   *int64  `json:"max_uploads"`
+	UploadCount  int64   `json:"upload_count"`
+	UsedBytes    int64   `json:"used_bytes"`
+	PendingFiles int64   `json:"pending_files"`
+	IsActive     bool    `json:"is_active"`
+}
+
+func handleDropCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("subcommand required: create, list, revoke, send, accept")
+	}
+
+	subcommand := args[0]
+	subArgs := args[1:]
+
+	switch subcommand {
+	case "create":
+		return handleDropCreate(client, config, subArgs)
+	case "list":
+		return handleDropList(client, config, subArgs)
+	case "revoke":
+		return handleDropRevoke(client, config, subArgs)
+	case "send":
+		return handleDropSend(config, subArgs)
+	case "accept":
+		return handleDropAccept(client, config, subArgs)
+	default:
+		return fmt.Errorf("unknown drop subcommand: %s (use create, list, revoke, send, or accept)", subcommand)
+	}
+}
+
+func handleDropCreate(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("drop create", flag.ExitOnError)
+	maxSizeMB := fs.Int64("max-size-mb", 1024, "Total size in MB the drop accepts")
+	maxUploads := fs.Int("max-uploads", 0, "Number of files the drop accepts (0 = unlimited)")
+	expires := fs.String("expires", "7d", "Expiry duration (e.g. 2m, 24h, 7d)")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client drop create [--max-size-mb MB] [--max-uploads N] [--expires DURATION]\n\n" +
+			"Create a link through which anyone holding it can upload files into your vault.\n" +
+			"Uploads are encrypted on the sender's machine to a key only you can open.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *maxSizeMB <= 0 {
+		return fmt.Errorf("--max-size-mb must be positive")
+	}
+	if *maxUploads < 0 {
+		return fmt.Errorf("--max-uploads must be non-negative")
+	}
+	expiresMinutes, err := parseDuration(*expires)
+	if err != nil {
+		return fmt.Errorf("invalid --expires value: %w", err)
+	}
+	if expiresMinutes == 0 {
+		return fmt.Errorf("a file drop must expire; set --expires")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	dropID, err := newClientShareID()
+	if err != nil {
+		return err
+	}
+	key, err := crypto.GenerateHybridKey()
+	if err != nil {
+		return err
+	}
+	wrapped, err := crypto.WrapDropPrivateKey(key, accountKey, dropID, session.Username)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"drop_id":               dropID,
+		"public_key":            base64.StdEncoding.EncodeToString(key.PublicKey()),
+		"encrypted_private_key": base64.StdEncoding.EncodeToString(wrapped),
+		"max_bytes":             *maxSizeMB * 1024 * 1024,
+		"expires_after_minutes": expiresMinutes,
+	}
+	if *maxUploads > 0 {
+		payload["max_uploads"] = *maxUploads
+	}
+
+	var created struct {
+		DropURL string `json:"drop_url"`
+	}
+	if err := ownerJSONRequest(client, session, "POST", "/api/drops", payload, &created); err != nil {
+		return fmt.Errorf("failed to create file drop: %w", err)
+	}
+	// The fingerprint is computed here, not taken on trust from the server
+	dropURL := created.DropURL
+	if i := strings.IndexByte(dropURL, '#'); i >= 0 {
+		dropURL = dropURL[:i]
+	}
+	if dropURL == "" {
+		return fmt.Errorf("server did not return a drop URL")
+	}
+	dropURL += "#" + crypto.HybridKeyFingerprint(key.PublicKey())
+
+	fmt.Printf("File drop created successfully\n")
+	fmt.Printf("Drop ID: %s\n", dropID)
+	fmt.Printf("Drop URL: %s\n", dropURL)
+	fmt.Printf("Expires in: %s\n", *expires)
+	fmt.Printf("\nSenders upload with: arkfile-client drop send --link '%s' --file FILE\n", dropURL)
+	fmt.Printf("Accept received files with: arkfile-client drop accept --drop-id %s\n", dropID)
+	return nil
+}
+
+func handleDropList(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("drop list", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	drops, err := fetchDropList(client, session)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"drops": drops})
+	}
+
+	if len(drops) == 0 {
+		fmt.Println("No file drops found.")
+		return nil
+	}
+
+	sep := strings.Repeat("-", 80)
+	for i, d := range drops {
+		fmt.Println(sep)
+		fmt.Printf("Drop %d of %d\n", i+1, len(drops))
+		fmt.Printf("  Drop ID:   %s\n", d.DropID)
+		fmt.Printf("  Expires:   %s\n", d.ExpiresAt)
+
+		files := fmt.Sprintf("%d", d.UploadCount)
+		if d.MaxUploads != nil {
+			files = fmt.Sprintf("%d / %d", d.UploadCount, *d.MaxUploads)
+		}
+		fmt.Printf("  Files:     %s\n", files)
+		fmt.Printf("  Size:      %s / %s\n", formatFileSize(d.UsedBytes), formatFileSize(d.MaxBytes))
+		fmt.Printf("  Pending:   %d\n", d.PendingFiles)
+
+		active := "yes"
+		if !d.IsActive {
+			active = "no"
+		}
+		fmt.Printf("  Active:    %s\n", active)
+		if d.RevokedAt != nil {
+			fmt.Printf("  Revoked:   %s\n", *d.RevokedAt)
+		}
+	}
+	fmt.Println(sep)
+	return nil
+}
+
+// fetchDropList returns the authenticated user's drops.
+func fetchDropList(client *HTTPClient, session *AuthSession) ([]dropSummary, error) {
+	var list struct {
+		Drops []dropSummary `json:"drops"`
+	}
+	if err := ownerJSONRequest(client, session, "GET", "/api/drops", nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to fetch file drops: %w", err)
+	}
+	return list.Drops, nil
+}
+
+// ownerJSONRequest performs an authenticated request with an optional JSON
+// body and decodes the JSON response into target.
+func ownerJSONRequest(client *HTTPClient, session *AuthSession, method, endpoint string, payload, target interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, client.baseURL+endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+	return decodeJSONResponse(resp, target)
+}
+
+func handleDropRevoke(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("drop revoke", flag.ExitOnError)
+	dropID := fs.String("drop-id", "", "Drop ID to revoke")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dropID == "" {
+		return fmt.Errorf("--drop-id is required")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	if _, err := client.makeRequestWithSession("POST", "/api/drops/"+*dropID+"/revoke", nil, session); err != nil {
+		return fmt.Errorf("failed to revoke file drop: %w", err)
+	}
+
+	fmt.Printf("File drop %s revoked successfully. Files already received stay in your vault.\n", *dropID)
+	return nil
+}
+
+// parseDropLink splits a drop URL into the server base URL, the drop ID and
+// the public key fingerprint from its fragment.
+func parseDropLink(link string) (baseURL, dropID, fingerprint string, err error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", "", "", fmt.Errorf("invalid drop link %q", link)
+	}
+	const prefix = "/api/public/drops/"
+	if !strings.HasPrefix(u.Path, prefix) || strings.Contains(u.Path[len(prefix):], "/") || len(u.Path) == len(prefix) {
+		return "", "", "", fmt.Errorf("not a file drop link: %q", link)
+	}
+	if u.Fragment == "" {
+		return "", "", "", fmt.Errorf("drop link has no key fingerprint; copy the whole link including the part after '#'")
+	}
+	return u.Scheme + "://" + u.Host, u.Path[len(prefix):], u.Fragment, nil
+}
+
+// fetchDropKey fetches a drop's public information and checks its public
+// key against the fingerprint from the drop link.
+func fetchDropKey(client *HTTPClient, dropID, fingerprint string) (*dropInfo, []byte, error) {
+	resp, err := client.client.Get(client.baseURL + "/api/public/drops/" + dropID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch file drop: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("file drop not found, closed or expired (HTTP %d): %s", resp.StatusCode, string(body))
+	}
+	var info dropInfo
+	if err := decodeJSONResponse(resp, &info); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode file drop: %w", err)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(info.PublicKey)
+	if err != nil || len(publicKey) != crypto.HybridPublicKeySize {
+		return nil, nil, fmt.Errorf("server returned an invalid drop key")
+	}
+	if crypto.HybridKeyFingerprint(publicKey) != fingerprint {
+		return nil, nil, fmt.Errorf("the drop key does not match the link's fingerprint; refusing to upload")
+	}
+	return &info, publicKey, nil
+}
+
+func handleDropSend(config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("drop send", flag.ExitOnError)
+	link := fs.String("link", "", "Drop link, including the part after '#'")
+	var files multiStringFlag
+	fs.Var(&files, "file", "File to upload (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client drop send --link URL --file FILE [--file FILE ...]\n\n" +
+			"Upload files through a file drop link. No account is needed. Files are\n" +
+			"encrypted on this machine to the drop owner's key.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *link == "" || len(files) == 0 {
+		return fmt.Errorf("--link and --file are required")
+	}
+
+	baseURL, dropID, fingerprint, err := parseDropLink(*link)
+	if err != nil {
+		return err
+	}
+	client := newHTTPClient(baseURL, config.TLSInsecure, config.TimeoutSecs, verbose)
+
+	info, publicKey, err := fetchDropKey(client, dropID, fingerprint)
+	if err != nil {
+		return err
+	}
+	logVerbose("File drop %s... accepts %s more, expires %s", dropID[:8], formatFileSize(info.RemainingBytes), info.ExpiresAt)
+
+	for _, path := range files {
+		if _, err := sendDropFile(client, dropID, publicKey, path); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		fmt.Printf("Sent %s\n", filepath.Base(path))
+	}
+	return nil
+}
+
+// sendDropFile encrypts one file to a drop's public key and uploads it. It
+// returns the file ID.
+func sendDropFile(client *HTTPClient, dropID string, publicKey []byte, path string) (string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	fileSizeBytes := fileInfo.Size()
+	if fileSizeBytes == 0 {
+		return "", fmt.Errorf("cannot upload empty file (0 bytes)")
+	}
+	sha256hex, err := computeStreamingSHA256(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	chunkSizeBytes := int64(crypto.PlaintextChunkSize())
+	chunkCount := (fileSizeBytes + chunkSizeBytes - 1) / chunkSizeBytes
+
+	fileID := uuid.New().String()
+	fek, err := generateFEK()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate file encryption key: %w", err)
+	}
+	defer clearBytes(fek)
+
+	envelope, metadataKey, err := crypto.SealDropFEK(publicKey, fileID, fek)
+	if err != nil {
+		return "", err
+	}
+	// Senders do not know the owner; the drop ID stands in for the owner
+	// in the metadata AAD until the file is accepted.
+	encFilenameB64, fnNonceB64, encSHA256B64, shaNonceB64, err :=
+		encryptMetadata(filepath.Base(path), sha256hex, metadataKey, fileID, dropID)
+	clearBytes(metadataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+
+	dropPath := "/api/public/drops/" + dropID + "/uploads"
+	initResp, err := client.makeRequest("POST", dropPath+"/init", map[string]interface{}{
+		"file_id":             fileID,
+		"encrypted_filename":  encFilenameB64,
+		"filename_nonce":      fnNonceB64,
+		"encrypted_sha256sum": encSHA256B64,
+		"sha256sum_nonce":     shaNonceB64,
+		"encrypted_fek":       base64.StdEncoding.EncodeToString(envelope),
+		"total_size":          calculateTotalEncryptedSize(fileSizeBytes),
+		"chunk_size":          chunkSizeBytes,
+		"password_type":       "drop",
+	}, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize upload: %w", err)
+	}
+	sessionID := initResp.SessionID
+	if sessionID == "" {
+		return "", fmt.Errorf("server did not return session_id")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, chunkSizeBytes)
+	for chunkIndex := int64(0); chunkIndex < chunkCount; chunkIndex++ {
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("failed to read chunk %d: %w", chunkIndex, err)
+		}
+		encryptedChunk, err := encryptChunk(buf[:n], fek, fileID, chunkIndex, chunkCount)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt chunk %d: %w", chunkIndex, err)
+		}
+		if err := uploadDropChunk(client, dropID, sessionID, chunkIndex, encryptedChunk); err != nil {
+			return "", fmt.Errorf("failed to upload chunk %d: %w", chunkIndex, err)
+		}
+		logVerbose("  Chunk %d/%d uploaded", chunkIndex+1, chunkCount)
+	}
+
+	if _, err := client.makeRequest("POST", dropPath+"/"+sessionID+"/complete", map[string]interface{}{
+		"upload_id":    sessionID,
+		"total_chunks": chunkCount,
+	}, ""); err != nil {
+		return "", fmt.Errorf("failed to finalize upload: %w", err)
+	}
+	return fileID, nil
+}
+
+// uploadDropChunk sends one encrypted chunk of an upload through a drop.
+func uploadDropChunk(client *HTTPClient, dropID, sessionID string, chunkIndex int64, data []byte) error {
+	chunkURL := fmt.Sprintf("%s/api/public/drops/%s/uploads/%s/chunks/%d", client.baseURL, dropID, sessionID, chunkIndex)
+	req, err := http.NewRequest("POST", chunkURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create chunk request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	h := sha256.Sum256(data)
+	req.Header.Set("X-Chunk-Hash", hex.EncodeToString(h[:]))
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chunk upload request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("chunk upload returned HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func handleDropAccept(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("drop accept", flag.ExitOnError)
+	dropID := fs.String("drop-id", "", "Accept files from this drop only (default: all drops)")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client drop accept [--drop-id DROP_ID]\n\n" +
+			"Re-wrap files received through your file drops with your account key so\n" +
+			"they can be listed, downloaded and shared like your own uploads.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	dropIDs := []string{*dropID}
+	if *dropID == "" {
+		drops, err := fetchDropList(client, session)
+		if err != nil {
+			return err
+		}
+		dropIDs = dropIDs[:0]
+		for _, d := range drops {
+			if d.PendingFiles > 0 {
+				dropIDs = append(dropIDs, d.DropID)
+			}
+		}
+	}
+
+	accepted := 0
+	for _, id := range dropIDs {
+		n, err := acceptDropFiles(client, session, accountKey, id)
+		accepted += n
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Accepted %d file(s).\n", accepted)
+	return nil
+}
+
+// acceptDropFiles accepts every received file of one drop that is still
+// sealed to the drop key and returns how many were accepted.
+func acceptDropFiles(client *HTTPClient, session *AuthSession, accountKey []byte, dropID string) (int, error) {
+	var details dropDetails
+	if err := ownerJSONRequest(client, session, "GET", "/api/drops/"+dropID, nil, &details); err != nil {
+		return 0, fmt.Errorf("failed to fetch file drop %s: %w", dropID, err)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(details.EncryptedPrivateKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid drop key encoding: %w", err)
+	}
+	key, err := crypto.UnwrapDropPrivateKey(wrapped, accountKey, dropID, session.Username)
+	if err != nil {
+		return 0, err
+	}
+
+	accepted := 0
+	for _, upload := range details.Uploads {
+		if !upload.InVault || upload.Accepted {
+			continue
+		}
+		meta, err := fetchFileMeta(client, session, upload.FileID)
+		if err != nil {
+			return accepted, err
+		}
+		payload, filename, err := acceptDropFile(*meta, key, accountKey, dropID, session.Username)
+		if err != nil {
+			return accepted, fmt.Errorf("file %s: %w", upload.FileID, err)
+		}
+		if _, err := client.makeRequestWithSession("PATCH", "/api/files/"+upload.FileID, payload, session); err != nil {
+			return accepted, fmt.Errorf("failed to accept file %s: %w", upload.FileID, err)
+		}
+		fmt.Printf("Accepted %s (%s)\n", filename, upload.FileID)
+		accepted++
+	}
+	return accepted, nil
+}
+
+// acceptDropFile opens a received file's FEK and metadata with the drop key
+// and returns the PATCH /api/files/:id body that re-wraps them with the
+// account key, along with the file's name.
+func acceptDropFile(meta ServerFileInfo, key *crypto.HybridPrivateKey, accountKey []byte, dropID, username string) (map[string]interface{}, string, error) {
+	envelope, err := base64.StdEncoding.DecodeString(meta.EncryptedFEK)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid FEK encoding: %w", err)
+	}
+	fek, metadataKey, err := crypto.OpenDropFEK(key, meta.FileID, envelope)
+	if err != nil {
+		return nil, "", err
+	}
+	defer clearBytes(fek)
+	defer clearBytes(metadataKey)
+
+	filename, err := decryptMetadataField(meta.EncryptedFilename, meta.FilenameNonce, metadataKey, meta.FileID, crypto.AADFieldFilename, dropID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt filename: %w", err)
+	}
+	sha256hex, err := decryptMetadataField(meta.EncryptedSHA256, meta.SHA256Nonce, metadataKey, meta.FileID, crypto.AADFieldSha256, dropID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt SHA-256: %w", err)
+	}
+	if !isPlainFileName(filename) {
+		// A sender chose the name; keep it from naming a path
+		filename = strings.NewReplacer("/", "_", "\\", "_").Replace(filename)
+	}
+
+	encFilenameB64, fnNonceB64, encSHA256B64, shaNonceB64, err :=
+		encryptMetadata(filename, sha256hex, accountKey, meta.FileID, username)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+	encryptedFEK, err := wrapFEK(fek, accountKey, "account", meta.FileID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to wrap FEK: %w", err)
+	}
+
+	return map[string]interface{}{
+		"encrypted_filename":  encFilenameB64,
+		"filename_nonce":      fnNonceB64,
+		"encrypted_sha256sum": encSHA256B64,
+		"sha256sum_nonce":     shaNonceB64,
+		"encrypted_fek":       encryptedFEK,
+		"password_type":       "account",
+	}, filename, nil
+}
@@ -0,0 +1,147 @@
+// drop_test.go - Unit tests for sending files through a file drop and
+// accepting them.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+const testDropID = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopr"
+
+func TestParseDropLink(t *testing.T) {
+	base, dropID, fp, err := parseDropLink("https://files.example.com/api/public/drops/" + testDropID + "#fingerprint")
+	if err != nil {
+		t.Fatalf("parseDropLink: %v", err)
+	}
+	if base != "https://files.example.com" || dropID != testDropID || fp != "fingerprint" {
+		t.Errorf("got %q %q %q", base, dropID, fp)
+	}
+
+	for _, link := range []string{
+		"https://files.example.com/api/public/drops/" + testDropID,
+		"https://files.example.com/shared/" + testDropID + "#fp",
+		"https://files.example.com/api/public/drops/" + testDropID + "/uploads/init#fp",
+		"/api/public/drops/" + testDropID + "#fp",
+	} {
+		if _, _, _, err := parseDropLink(link); err == nil {
+			t.Errorf("expected an error for %q", link)
+		}
+	}
+}
+
+// fakeDropServer serves one drop and records the upload sent through it.
+type fakeDropServer struct {
+	publicKey []byte
+	init      map[string]interface{}
+	chunks    [][]byte
+}
+
+func (f *fakeDropServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/api/public/drops/" + testDropID
+	switch {
+	case r.Method == "GET" && r.URL.Path == prefix:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"drop_id":         testDropID,
+			"public_key":      base64.StdEncoding.EncodeToString(f.publicKey),
+			"remaining_bytes": 1 << 20,
+		})
+	case r.URL.Path == prefix+"/uploads/init":
+		json.NewDecoder(r.Body).Decode(&f.init)
+		w.Write([]byte(`{"session_id":"sess-1","file_id":"` + f.init["file_id"].(string) + `"}`))
+	case strings.HasPrefix(r.URL.Path, prefix+"/uploads/sess-1/chunks/"):
+		if r.Header.Get("Authorization") != "" || r.Header.Get("X-Chunk-Hash") == "" {
+			http.Error(w, "bad chunk request", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.chunks = append(f.chunks, data)
+		w.Write([]byte(`{"message":"ok"}`))
+	case r.URL.Path == prefix+"/uploads/sess-1/complete":
+		w.Write([]byte(`{"message":"File uploaded successfully","file_id":"` + f.init["file_id"].(string) + `"}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestDropSendAndAccept(t *testing.T) {
+	key, err := crypto.GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeDropServer{publicKey: key.PublicKey()}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	client := newHTTPClient(srv.URL, false, 10, false)
+
+	// The served key must match the link's fingerprint
+	if _, _, err := fetchDropKey(client, testDropID, "wrong"); err == nil {
+		t.Fatal("expected a fingerprint mismatch")
+	}
+	_, publicKey, err := fetchDropKey(client, testDropID, crypto.HybridKeyFingerprint(key.PublicKey()))
+	if err != nil {
+		t.Fatalf("fetchDropKey: %v", err)
+	}
+
+	content := []byte("scanned contract")
+	path := filepath.Join(t.TempDir(), "contract.pdf")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fileID, err := sendDropFile(client, testDropID, publicKey, path)
+	if err != nil {
+		t.Fatalf("sendDropFile: %v", err)
+	}
+	if fake.init["password_type"] != "drop" || fake.init["file_id"] != fileID || len(fake.chunks) != 1 {
+		t.Fatalf("init = %v, %d chunks", fake.init, len(fake.chunks))
+	}
+
+	// The owner accepts the file; it is re-wrapped for the account key
+	meta := ServerFileInfo{
+		FileID:            fileID,
+		EncryptedFEK:      fake.init["encrypted_fek"].(string),
+		EncryptedFilename: fake.init["encrypted_filename"].(string),
+		FilenameNonce:     fake.init["filename_nonce"].(string),
+		EncryptedSHA256:   fake.init["encrypted_sha256sum"].(string),
+		SHA256Nonce:       fake.init["sha256sum_nonce"].(string),
+	}
+	accountKey := bytes.Repeat([]byte{0x01}, 32)
+	payload, filename, err := acceptDropFile(meta, key, accountKey, testDropID, testOwner)
+	if err != nil {
+		t.Fatalf("acceptDropFile: %v", err)
+	}
+	if filename != "contract.pdf" || payload["password_type"] != "account" {
+		t.Errorf("filename %q, payload %v", filename, payload)
+	}
+
+	fek, keyType, err := unwrapFEK(payload["encrypted_fek"].(string), accountKey, fileID)
+	if err != nil || keyType != "account" {
+		t.Fatalf("unwrap accepted FEK: %v (%s)", err, keyType)
+	}
+	plaintext, err := decryptChunk(fake.chunks[0], fek, fileID, 0, 1)
+	if err != nil || !bytes.Equal(plaintext, content) {
+		t.Errorf("decrypted chunk %q, %v", plaintext, err)
+	}
+	name, err := decryptMetadataField(payload["encrypted_filename"].(string), payload["filename_nonce"].(string),
+		accountKey, fileID, crypto.AADFieldFilename, testOwner)
+	if err != nil || name != "contract.pdf" {
+		t.Errorf("account filename %q, %v", name, err)
+	}
+
+	// Only the drop's key opens the file
+	other, _ := crypto.GenerateHybridKey()
+	if _, _, err := acceptDropFile(meta, other, accountKey, testDropID, testOwner); err == nil {
+		t.Error("expected an error for a different drop key")
+	}
+}
@@ -0,0 +1,392 @@
+// folders.go - Client-side folder hierarchy for uploaded files.
+//
+// Every file may carry a folder path ("docs/2026/taxes") next to its
+// filename. Like the filename, the path is encrypted with the account key
+// under AAD = BuildMetadataFieldAAD(fileID, "encrypted_folder", owner), so
+// the server stores an opaque blob and cannot move a file between folders
+// or swap a filename into the folder slot. A file without a folder lives at
+// the top level.
+//
+// `upload --dir --recursive` records each file's directory relative to
+// --dir, `list-files --tree` shows the decrypted hierarchy and
+// `download --folder` restores a folder into a local directory.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// maxFolderPathLength caps a folder path (in bytes) before encryption.
+const maxFolderPathLength = 1024
+
+// encryptedFolderLabel stands in for a folder path that cannot be decrypted.
+const encryptedFolderLabel = "[encrypted]"
+
+// cleanFolderPath normalizes a folder path to its canonical stored form:
+// slash-separated, without leading or trailing slashes. "", "." and "/"
+// all mean the top level and clean to "". Empty, "." and ".." components
+// are rejected rather than resolved, so a stored path always maps onto a
+// directory below the restore target.
+func cleanFolderPath(p string) (string, error) {
+	p = strings.Trim(filepath.ToSlash(p), "/")
+	if p == "" || p == "." {
+		return "", nil
+	}
+	if len(p) > maxFolderPathLength {
+		return "", fmt.Errorf("folder path is longer than %d bytes", maxFolderPathLength)
+	}
+	for _, part := range strings.Split(p, "/") {
+		switch {
+		case part == "":
+			return "", fmt.Errorf("folder path %q contains an empty component", p)
+		case part == "." || part == "..":
+			return "", fmt.Errorf("folder path %q must not contain %q", p, part)
+		case strings.ContainsAny(part, "\\\x00"):
+			return "", fmt.Errorf("folder path %q contains an invalid character", p)
+		}
+	}
+	return p, nil
+}
+
+// folderRelative reports whether folder is base or lies below it, and
+// returns its path relative to base ("" for base itself). base "" is the
+// top level, which contains every folder.
+func folderRelative(folder, base string) (string, bool) {
+	switch {
+	case base == "":
+		return folder, true
+	case folder == base:
+		return "", true
+	case strings.HasPrefix(folder, base+"/"):
+		return folder[len(base)+1:], true
+	}
+	return "", false
+}
+
+// uploadFolders returns the folder each upload input is stored under. Files
+// found under dir keep their directory relative to dir, below base; every
+// other file goes into base itself.
+func uploadFolders(files []string, dir, base string) (map[string]string, error) {
+	folders := make(map[string]string, len(files))
+	var dirAbs string
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve --dir: %w", err)
+		}
+		dirAbs = abs
+	}
+	for _, p := range files {
+		folders[p] = base
+		if dirAbs == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(dirAbs, filepath.Dir(abs))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		folder, err := cleanFolderPath(path.Join(base, filepath.ToSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot store %s in a folder: %w", p, err)
+		}
+		folders[p] = folder
+	}
+	return folders, nil
+}
+
+// decryptFileFolder returns the decrypted folder path of a server file, or
+// "" for a file at the top level.
+func decryptFileFolder(f ServerFileInfo, accountKey []byte, ownerUsername string) (string, error) {
+	if f.EncryptedFolder == "" && f.FolderNonce == "" {
+		return "", nil
+	}
+	folder, err := decryptMetadataField(f.EncryptedFolder, f.FolderNonce, accountKey,
+		f.FileID, crypto.AADFieldFolder, ownerUsername)
+	if err != nil {
+		return "", err
+	}
+	cleaned, err := cleanFolderPath(folder)
+	if err != nil {
+		return "", err
+	}
+	if cleaned != folder {
+		return "", fmt.Errorf("folder path %q is not in canonical form", folder)
+	}
+	return folder, nil
+}
+
+// displayFolder returns the folder of a server file for listings, or
+// encryptedFolderLabel when it cannot be decrypted (including when no
+// account key is available).
+func displayFolder(f ServerFileInfo, accountKey []byte, ownerUsername string) string {
+	if f.EncryptedFolder == "" && f.FolderNonce == "" {
+		return ""
+	}
+	if accountKey == nil {
+		return encryptedFolderLabel
+	}
+	folder, err := decryptFileFolder(f, accountKey, ownerUsername)
+	if err != nil {
+		return encryptedFolderLabel
+	}
+	return folder
+}
+
+// isPlainFileName reports whether a decrypted filename can be written into a
+// local directory as-is.
+func isPlainFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// ============================================================
+// TREE LISTING
+// ============================================================
+
+// treeFile is one file shown by `list-files --tree`.
+type treeFile struct {
+	Folder   string
+	Filename string
+	FileID   string
+	Size     string
+}
+
+type treeNode struct {
+	children map[string]*treeNode
+	files    []treeFile
+}
+
+// renderFileTree draws files as an indented folder tree rooted at ".".
+// Folders come before files at each level; both are sorted by name.
+func renderFileTree(files []treeFile) string {
+	root := &treeNode{children: make(map[string]*treeNode)}
+	for _, f := range files {
+		node := root
+		if f.Folder != "" {
+			for _, part := range strings.Split(f.Folder, "/") {
+				child, ok := node.children[part]
+				if !ok {
+					child = &treeNode{children: make(map[string]*treeNode)}
+					node.children[part] = child
+				}
+				node = child
+			}
+		}
+		node.files = append(node.files, f)
+	}
+
+	var b strings.Builder
+	b.WriteString(".\n")
+	writeTreeNode(&b, root, "")
+	return b.String()
+}
+
+func writeTreeNode(b *strings.Builder, node *treeNode, indent string) {
+	sort.Slice(node.files, func(i, j int) bool {
+		if node.files[i].Filename != node.files[j].Filename {
+			return node.files[i].Filename < node.files[j].Filename
+		}
+		return node.files[i].FileID < node.files[j].FileID
+	})
+	names := sortedKeys(node.children)
+	total := len(names) + len(node.files)
+	n := 0
+	branch := func() (string, string) {
+		n++
+		if n == total {
+			return "`-- ", "    "
+		}
+		return "|-- ", "|   "
+	}
+
+	for _, name := range names {
+		head, next := branch()
+		fmt.Fprintf(b, "%s%s%s/\n", indent, head, name)
+		writeTreeNode(b, node.children[name], indent+next)
+	}
+	for _, f := range node.files {
+		head, _ := branch()
+		fmt.Fprintf(b, "%s%s%s  (%s, %s)\n", indent, head, f.Filename, f.Size, f.FileID)
+	}
+}
+
+// ============================================================
+// FOLDER DOWNLOAD
+// ============================================================
+
+// folderDownload is one file selected by `download --folder`.
+type folderDownload struct {
+	Meta     ServerFileInfo
+	Filename string
+	SHA256   string
+	Dest     string
+}
+
+// selectFolderDownloads picks the files in folder base (and below it) and
+// maps each to outDir/<path relative to base>/<filename>. Files whose
+// metadata cannot be decrypted, whose name cannot be used locally or whose
+// destination is taken by an earlier file are returned as skip messages.
+func selectFolderDownloads(files []ServerFileInfo, accountKey []byte, username, base, outDir string) ([]folderDownload, []string) {
+	sorted := slices.Clone(files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FileID < sorted[j].FileID })
+
+	var selected []folderDownload
+	var skipped []string
+	targets := make(map[string]string)
+	for _, f := range sorted {
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = username
+		}
+		folder, err := decryptFileFolder(f, accountKey, owner)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: folder could not be decrypted", f.FileID))
+			continue
+		}
+		rel, ok := folderRelative(folder, base)
+		if !ok {
+			continue
+		}
+		name, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+			f.FileID, crypto.AADFieldFilename, owner)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: filename could not be decrypted", f.FileID))
+			continue
+		}
+		sha, err := decryptMetadataField(f.EncryptedSHA256, f.SHA256Nonce, accountKey,
+			f.FileID, crypto.AADFieldSha256, owner)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: SHA-256 could not be decrypted", f.FileID))
+			continue
+		}
+		display := name
+		if folder != "" {
+			display = folder + "/" + name
+		}
+		if !isPlainFileName(name) {
+			skipped = append(skipped, fmt.Sprintf("%s: filename cannot be used locally", display))
+			continue
+		}
+		dest := filepath.Join(outDir, filepath.FromSlash(rel), name)
+		if other, taken := targets[dest]; taken {
+			skipped = append(skipped, fmt.Sprintf("%s: same path as file %s", display, other))
+			continue
+		}
+		targets[dest] = f.FileID
+		selected = append(selected, folderDownload{Meta: f, Filename: display, SHA256: sha, Dest: dest})
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Dest < selected[j].Dest })
+	return selected, skipped
+}
+
+// downloadFolder restores every file in folder base into outDir, recreating
+// the folder structure below base. Existing local files are never
+// overwritten. Custom-password files share one prompted password.
+func downloadFolder(client *HTTPClient, config *ClientConfig, session *AuthSession, base, outDir string, parallel int) error {
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	files, err := fetchFileList(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch file list: %w", err)
+	}
+
+	selected, skippedMsgs := selectFolderDownloads(files, accountKey, session.Username, base, outDir)
+	for _, msg := range skippedMsgs {
+		fmt.Printf("[SKIP] %s\n", msg)
+	}
+	if len(selected) == 0 {
+		if len(skippedMsgs) > 0 {
+			return fmt.Errorf("no files restored (%d skipped)", len(skippedMsgs))
+		}
+		fmt.Printf("No files found in folder %q.\n", base)
+		return nil
+	}
+
+	var customKEK []byte
+	defer func() { clearBytes(customKEK) }()
+
+	restored, failed, skipped := 0, 0, len(skippedMsgs)
+	for _, d := range selected {
+		if _, err := os.Lstat(d.Dest); err == nil {
+			fmt.Printf("[SKIP] %s: %s already exists\n", d.Filename, d.Dest)
+			skipped++
+			continue
+		}
+
+		kek := accountKey
+		switch d.Meta.PasswordType {
+		case "account", "":
+		case "custom":
+			if customKEK == nil {
+				customPass, err := readPassword("Enter custom password for custom-password files in this folder: ")
+				if err != nil {
+					return fmt.Errorf("failed to read custom password: %w", err)
+				}
+				customKEK = crypto.DeriveCustomPasswordKey(customPass, config.Username)
+				clearBytes(customPass)
+			}
+			kek = customKEK
+		default:
+			fmt.Printf("[X] %s: unsupported password type: %s\n", d.Filename, d.Meta.PasswordType)
+			failed++
+			continue
+		}
+
+		if err := ensureFreshSessionToken(client, session, 0); err != nil {
+			return fmt.Errorf("session refresh failed: %w", err)
+		}
+		if err := restoreFolderFile(client, session, kek, d, parallel); err != nil {
+			fmt.Printf("[X] %s: %v\n", d.Filename, err)
+			failed++
+			continue
+		}
+		restored++
+		fmt.Printf("[OK] %s -> %s\n", d.Filename, d.Dest)
+	}
+
+	fmt.Printf("\nRestored: %d. Failed: %d. Skipped: %d.\n", restored, failed, skipped)
+	if failed > 0 || skipped > 0 {
+		return fmt.Errorf("folder download finished with %d failed and %d skipped (restored %d)", failed, skipped, restored)
+	}
+	return nil
+}
+
+// restoreFolderFile unwraps the FEK of one selected file and downloads it to
+// its destination, creating parent directories as needed.
+func restoreFolderFile(client *HTTPClient, session *AuthSession, kek []byte, d folderDownload, parallel int) error {
+	if d.Meta.EncryptedFEK == "" {
+		return fmt.Errorf("file metadata missing encrypted FEK")
+	}
+	fek, _, err := unwrapFEK(d.Meta.EncryptedFEK, kek, d.Meta.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap FEK (wrong password?): %w", err)
+	}
+	defer clearBytes(fek)
+
+	if err := os.MkdirAll(filepath.Dir(d.Dest), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	err = downloadToVerifiedFile(client, session, fek, d.Meta, d.SHA256, d.Dest, parallel)
+	if errors.Is(err, errDownloadTargetExists) {
+		return fmt.Errorf("%s appeared during the download", d.Dest)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+// folders_test.go - Unit tests for encrypted folder paths: normalization,
+// the AAD-bound round trip, upload folder mapping, the tree listing and the
+// selection of files for `download --folder`. Nothing touches the network.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestCleanFolderPath(t *testing.T) {
+	valid := map[string]string{
+		"":                "",
+		".":               "",
+		"/":               "",
+		"docs":            "docs",
+		"/docs/2026/":     "docs/2026",
+		"photos/.private": "photos/.private",
+	}
+	for in, want := range valid {
+		got, err := cleanFolderPath(in)
+		if err != nil || got != want {
+			t.Errorf("cleanFolderPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"a//b", "a/./b", "../etc", "docs/..", "a\\b", "a\x00b", strings.Repeat("x", maxFolderPathLength+1)} {
+		if got, err := cleanFolderPath(in); err == nil {
+			t.Errorf("cleanFolderPath(%q) = %q; want an error", in, got)
+		}
+	}
+}
+
+func TestEncryptFolderPath_RoundTrip(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatalf("GenerateAESKey failed: %v", err)
+	}
+
+	encFolder, folderNonce, err := encryptFolderPath("docs/2026", accountKey, testFileID, testOwner)
+	if err != nil {
+		t.Fatalf("encryptFolderPath failed: %v", err)
+	}
+	f := ServerFileInfo{FileID: testFileID, EncryptedFolder: encFolder, FolderNonce: folderNonce}
+	if folder, err := decryptFileFolder(f, accountKey, testOwner); err != nil || folder != "docs/2026" {
+		t.Fatalf("decryptFileFolder = %q, %v", folder, err)
+	}
+
+	// The folder ciphertext is bound to its field, file and owner
+	if _, err := decryptMetadataField(encFolder, folderNonce, accountKey, testFileID, crypto.AADFieldFilename, testOwner); err == nil {
+		t.Error("folder ciphertext must not decrypt as a filename")
+	}
+	f.FileID = testFileID2
+	if _, err := decryptFileFolder(f, accountKey, testOwner); err == nil {
+		t.Error("folder ciphertext must not decrypt for another file")
+	}
+	f.FileID = testFileID
+	if _, err := decryptFileFolder(f, accountKey, testOwner2); err == nil {
+		t.Error("folder ciphertext must not decrypt for another owner")
+	}
+
+	// A filename moved into the folder slot is rejected
+	encFn, fnNonce, _, _, err := encryptMetadata("report.pdf", "abcd1234", accountKey, testFileID, testOwner)
+	if err != nil {
+		t.Fatalf("encryptMetadata failed: %v", err)
+	}
+	swapped := ServerFileInfo{FileID: testFileID, EncryptedFolder: encFn, FolderNonce: fnNonce}
+	if _, err := decryptFileFolder(swapped, accountKey, testOwner); err == nil {
+		t.Error("filename ciphertext must not decrypt as a folder")
+	}
+
+	if folder, err := decryptFileFolder(ServerFileInfo{FileID: testFileID}, accountKey, testOwner); err != nil || folder != "" {
+		t.Errorf("file without a folder = %q, %v; want top level", folder, err)
+	}
+}
+
+func TestUploadFolders(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "photos")
+	nested := filepath.Join(dir, "2026", "march", "a.jpg")
+	top := filepath.Join(dir, "b.jpg")
+	other := filepath.Join(root, "c.txt")
+
+	folders, err := uploadFolders([]string{nested, top, other}, dir, "backup")
+	if err != nil {
+		t.Fatalf("uploadFolders: %v", err)
+	}
+	want := map[string]string{nested: "backup/2026/march", top: "backup", other: "backup"}
+	for p, folder := range want {
+		if folders[p] != folder {
+			t.Errorf("folder of %s = %q, want %q", p, folders[p], folder)
+		}
+	}
+
+	folders, err = uploadFolders([]string{nested, other}, "", "")
+	if err != nil || folders[nested] != "" || folders[other] != "" {
+		t.Errorf("without --dir every file goes to the base folder: %v, %v", folders, err)
+	}
+}
+
+func TestRenderFileTree(t *testing.T) {
+	got := renderFileTree([]treeFile{
+		{Folder: "docs/2026", Filename: "q1.xlsx", FileID: "id-q1", Size: "2 KB"},
+		{Filename: "readme.txt", FileID: "id-readme", Size: "1 KB"},
+		{Folder: "docs", Filename: "cv.pdf", FileID: "id-cv", Size: "3 KB"},
+		{Folder: "photos", Filename: "cat.jpg", FileID: "id-cat", Size: "4 KB"},
+	})
+	want := strings.Join([]string{
+		".",
+		"|-- docs/",
+		"|   |-- 2026/",
+		"|   |   `-- q1.xlsx  (2 KB, id-q1)",
+		"|   `-- cv.pdf  (3 KB, id-cv)",
+		"|-- photos/",
+		"|   `-- cat.jpg  (4 KB, id-cat)",
+		"`-- readme.txt  (1 KB, id-readme)",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSelectFolderDownloads(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatalf("GenerateAESKey failed: %v", err)
+	}
+	file := func(id, folder, name string) ServerFileInfo {
+		t.Helper()
+		encFn, fnNonce, encSHA, shaNonce, err := encryptMetadata(name, "sha-"+id, accountKey, id, testOwner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := ServerFileInfo{FileID: id, EncryptedFilename: encFn, FilenameNonce: fnNonce, EncryptedSHA256: encSHA, SHA256Nonce: shaNonce}
+		if folder != "" {
+			if f.EncryptedFolder, f.FolderNonce, err = encryptFolderPath(folder, accountKey, id, testOwner); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f
+	}
+	files := []ServerFileInfo{
+		file("id-1", "docs", "cv.pdf"),
+		file("id-2", "docs/2026", "q1.xlsx"),
+		file("id-3", "docsextra", "no.txt"),
+		file("id-4", "", "top.txt"),
+		file("id-5", "docs", "cv.pdf"),
+		file("id-6", "docs", ".."),
+	}
+	out := filepath.Join(t.TempDir(), "restore")
+
+	selected, skipped := selectFolderDownloads(files, accountKey, testOwner, "docs", out)
+	var dests []string
+	for _, d := range selected {
+		rel, _ := filepath.Rel(out, d.Dest)
+		dests = append(dests, filepath.ToSlash(rel)+"="+d.Meta.FileID)
+	}
+	if got, want := strings.Join(dests, " "), "2026/q1.xlsx=id-2 cv.pdf=id-1"; got != want {
+		t.Errorf("selected %s, want %s", got, want)
+	}
+	if selected[0].SHA256 != "sha-id-2" {
+		t.Errorf("expected digest of id-2, got %q", selected[0].SHA256)
+	}
+	if len(skipped) != 2 || !strings.Contains(skipped[0], "id-1") || !strings.Contains(skipped[1], "cannot be used locally") {
+		t.Errorf("unexpected skips: %q", skipped)
+	}
+
+	// The top level selects everything
+	all, _ := selectFolderDownloads(files, accountKey, testOwner, "", out)
+	if len(all) != 4 {
+		t.Errorf("restoring the top level selected %d files, want 4", len(all))
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("selection must not create the output directory")
+	}
+}
@@ -0,0 +1,155 @@
+//go:build linux
+
+// fuse_linux.go - FUSE binding for `arkfile-client mount`.
+//
+// The kernel protocol, fusermount and mounting are left to go-fuse; this
+// file only maps its node callbacks onto vaultFS by inode number. The mount
+// is read-only, so go-fuse answers every request that would modify it.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// vaultCacheTimeout is how long the kernel may cache entries and attributes.
+// The tree never changes while mounted.
+const vaultCacheTimeout = time.Hour
+
+// vaultInode is the go-fuse node of one vaultFS inode.
+type vaultInode struct {
+	fs.Inode
+	vfs *vaultFS
+	ino uint64
+}
+
+var (
+	_ fs.NodeLookuper  = (*vaultInode)(nil)
+	_ fs.NodeGetattrer = (*vaultInode)(nil)
+	_ fs.NodeOpener    = (*vaultInode)(nil)
+	_ fs.NodeReader    = (*vaultInode)(nil)
+	_ fs.NodeReaddirer = (*vaultInode)(nil)
+	_ fs.NodeStatfser  = (*vaultInode)(nil)
+)
+
+// mountVault mounts vfs read-only at mountpoint and serves it in the
+// background. go-fuse uses the fusermount helper, or mount(2) when running
+// as root.
+func mountVault(mountpoint string, vfs *vaultFS) (vaultMount, error) {
+	mountpoint, err := filepath.Abs(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	timeout := vaultCacheTimeout
+	root := &vaultInode{vfs: vfs, ino: vaultRootID}
+	server, err := fs.Mount(mountpoint, root, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:      "arkfile",
+			Name:        "arkfile",
+			Options:     []string{"ro", "nosuid", "nodev"},
+			DirectMount: os.Geteuid() == 0,
+		},
+		EntryTimeout:   &timeout,
+		AttrTimeout:    &timeout,
+		RootStableAttr: &fs.StableAttr{Ino: vaultRootID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// fuseErrno returns the errno to answer a failed vaultFS call with.
+func fuseErrno(err error) syscall.Errno {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	}
+	logVerbose("mount: %v", err)
+	return syscall.EIO
+}
+
+func setFuseAttr(out *fuse.Attr, a vaultAttr) {
+	out.Ino = a.Ino
+	out.Size = a.Size
+	out.Blocks = (a.Size + 511) / 512
+	out.Mtime, out.Atime, out.Ctime = a.Mtime, a.Mtime, a.Mtime
+	out.Mode = a.Mode
+	out.Nlink = a.Nlink
+	out.Uid, out.Gid = a.UID, a.GID
+}
+
+func (n *vaultInode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	attr, err := n.vfs.Lookup(n.ino, name)
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	setFuseAttr(&out.Attr, attr)
+	child := &vaultInode{vfs: n.vfs, ino: attr.Ino}
+	return n.NewInode(ctx, child, fs.StableAttr{Mode: attr.Mode & syscall.S_IFMT, Ino: attr.Ino}), 0
+}
+
+func (n *vaultInode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	attr, err := n.vfs.Getattr(n.ino)
+	if err != nil {
+		return fuseErrno(err)
+	}
+	setFuseAttr(&out.Attr, attr)
+	return 0
+}
+
+// Open unwraps the file's key up front, so a wrong key fails the open
+// rather than the first read. The content never changes, so the kernel may
+// keep its page cache across opens.
+func (n *vaultInode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, 0, syscall.EROFS
+	}
+	if err := n.vfs.Open(n.ino); err != nil {
+		return nil, 0, fuseErrno(err)
+	}
+	return nil, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+func (n *vaultInode) Read(ctx context.Context, f fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	data, err := n.vfs.Read(n.ino, off, len(dest))
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	return fuse.ReadResultData(data), 0
+}
+
+func (n *vaultInode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := n.vfs.ReadDir(n.ino)
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	list := make([]fuse.DirEntry, len(entries))
+	for i, e := range entries {
+		list[i] = fuse.DirEntry{Ino: e.Ino, Name: e.Name, Mode: syscall.S_IFREG}
+		if e.Dir {
+			list[i].Mode = syscall.S_IFDIR
+		}
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+// Statfs reports a full filesystem the size of the mounted files.
+func (n *vaultInode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	const bsize = 4096
+	files, bytes := n.vfs.Statfs()
+	out.Blocks = (bytes + bsize - 1) / bsize
+	out.Files = files
+	out.Bsize = bsize
+	out.Frsize = bsize
+	out.NameLen = 255
+	return 0
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+// fuse_linux_test.go - Mounts a vault through go-fuse and reads it back with
+// ordinary file system calls. Skipped where FUSE is unavailable.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestMountVault(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte(strings.Repeat("0123456789", 10))
+	meta := mountTestFile(t, accountKey, testFileID, "docs", "log.txt", int64(len(plaintext)), 16)
+	if meta.EncryptedFEK, err = wrapFEK(bytes.Repeat([]byte{7}, 32), accountKey, "account", testFileID); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := buildVaultTree([]ServerFileInfo{meta}, accountKey, testOwner)
+	chunks := &fakeChunks{data: map[string][]byte{testFileID: plaintext}, size: 16}
+	vfs := newVaultFS(nodes, accountKey, chunks.fetch, 2)
+	defer vfs.close()
+
+	dir := t.TempDir()
+	mnt, err := mountVault(dir, vfs)
+	if err != nil {
+		t.Skipf("FUSE is not available: %v", err)
+	}
+	defer func() {
+		if err := mnt.Unmount(); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+		mnt.Wait()
+	}()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "docs" || !entries[0].IsDir() {
+		t.Fatalf("ReadDir(/) = %v, %v", entries, err)
+	}
+	path := filepath.Join(dir, "docs", "log.txt")
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != 100 || info.Mode() != 0400 {
+		t.Fatalf("Stat = %v, %v", info, err)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("ReadFile = %q, %v", got, err)
+	}
+
+	if _, err := os.OpenFile(path, os.O_WRONLY, 0); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("opening for writing: %v, want EROFS", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), nil, 0600); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("creating a file: %v, want EROFS", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing file: %v", err)
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil || st.Files != 1 || st.Blocks != 1 {
+		t.Errorf("Statfs = files %d, blocks %d, %v", st.Files, st.Blocks, err)
+	}
+}
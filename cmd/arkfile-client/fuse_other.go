@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func mountVault(mountpoint string, vfs *vaultFS) (vaultMount, error) {
+	return nil, errors.New("mount is only supported on Linux")
+}
@@ -18,6 +18,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -51,9 +52,22 @@ COMMANDS:
     upload            Encrypt and upload a file (streaming, per-chunk AES-GCM)
     download          Download and decrypt a file (streaming, per-chunk AES-GCM)
     list-files        List files with auto-decrypted filenames
-    delete-file       Permanently delete a file from the server
-    share             Manage file shares (create, list, delete, revoke)
-    share download    Download a shared file (no auth required)
+    search            Find files by name or folder words (encrypted index)
+    annotate          Show or edit a file's encrypted tags and note
+    rename            Rename a file without re-uploading it
+    rekey             Change a file's password type without re-uploading it
+    sync              Two-way sync of a local directory with your files
+    mount             Mount your files as a read-only filesystem (Linux, FUSE)
+    delete-file       Move a file to the trash (--permanent deletes it for good)
+    trash             List deleted files that can still be restored
+    restore           Restore a deleted file from the trash
+    version-retention Show or set how many versions of each file are kept
+    share             Manage file shares (create, list, log, update, delete, revoke)
+    share download    Download a shared file or bundle (no auth required)
+    share with        Share a file with another user (no share password; also sent, with-me, fetch, unshare)
+    user-key          Manage the key other users share files with you to (create, show)
+    drop              Manage file drop links others upload into (create, list, revoke, accept)
+    drop send         Upload files through a file drop link (no auth required)
     export            Export an encrypted file as a .arkbackup bundle
     decrypt-blob      Decrypt a .arkbackup bundle offline (no network required)
     contact-info      Manage your contact information (get, set, delete)
@@ -76,13 +90,48 @@ EXAMPLES:
     arkfile-client upload --file document.pdf --username alice12345
     arkfile-client upload --file document.pdf --username alice12345 --password-type custom
     arkfile-client upload --file document.pdf --username alice12345 --force
+    arkfile-client upload --resume
+    arkfile-client upload --dir ~/Photos --recursive --folder photos
+    arkfile-client upload --file report.pdf --version-of abc123
+    arkfile-client upload --dir ~/Documents --index
+    pg_dump mydb | arkfile-client upload - --name db.sql --folder backups
     arkfile-client download --file-id abc123 --output document.pdf --username alice12345
+    arkfile-client download --folder photos/2026 --output ~/restore
+    arkfile-client download --file-id abc123 --output - | psql mydb
+    arkfile-client download --file-id abc123 --version 2
     arkfile-client list-files
+    arkfile-client list-files --tree
+    arkfile-client list-files --versions
     arkfile-client list-files --json
     arkfile-client list-files --raw
+    arkfile-client list-files --tag project=apollo --json
+    arkfile-client annotate --file-id abc123 --tag project=apollo --tag retention=7y --note "Q3 audit copy"
+    arkfile-client rename --file-id abc123 --name report-final.pdf
+    arkfile-client rekey --file-id abc123 --password-type custom --hint "usual plus year"
+    arkfile-client search --reindex
+    arkfile-client search tax 2025
+    arkfile-client sync ~/Documents/vault --dry-run
+    arkfile-client mount ~/vault
+    arkfile-client version-retention --keep 5
+    arkfile-client delete-file --file-id abc123
+    arkfile-client trash
+    arkfile-client restore --file-id abc123
     arkfile-client share create --file-id abc123
+    arkfile-client share create --file-id abc123 --file-id def456
+    arkfile-client share create --folder photos/2026 --expires 7d
     arkfile-client share list
+    arkfile-client share log --share-id xyz
+    arkfile-client share update --share-id xyz --extend 1d
+    arkfile-client share update --share-id xyz --suspend
     arkfile-client share download --share-id xyz --output file.pdf
+    arkfile-client share download --share-id xyz --all --output ~/shared
+    arkfile-client user-key create
+    arkfile-client share with --file-id abc123 --user bob12345678 --fingerprint fp
+    arkfile-client share with-me
+    arkfile-client share fetch --id xyz --output contract.pdf
+    arkfile-client drop create --max-size-mb 500 --max-uploads 10 --expires 3d
+    arkfile-client drop send --link 'https://host/api/public/drops/xyz#fp' --file scan.pdf
+    arkfile-client drop accept
     arkfile-client generate-test-file --filename test.bin --size 104857600
     arkfile-client agent start
     arkfile-client logout
@@ -153,12 +202,21 @@ type ServerFileInfo struct {
 	EncryptedFilename string `json:"encrypted_filename"`
 	SHA256Nonce       string `json:"sha256sum_nonce"`
 	EncryptedSHA256   string `json:"encrypted_sha256sum"`
+	FolderNonce       string `json:"folder_nonce"`
+	EncryptedFolder   string `json:"encrypted_folder"`
 	EncryptedFEK      string `json:"encrypted_fek"`
 	SizeBytes         int64  `json:"size_bytes"`
 	SizeReadable      string `json:"size_readable"`
 	UploadDate        string `json:"upload_date"`
 	ChunkCount        int64  `json:"chunk_count"`
 	ChunkSizeBytes    int64  `json:"chunk_size_bytes"`
+	VersionGroup      string `json:"version_group"`
+	VersionNumber     int64  `json:"version_number"`
+	VersionCount      int    `json:"version_count"`
+	TagsNonce         string `json:"tags_nonce"`
+	EncryptedTags     string `json:"encrypted_tags"`
+	NoteNonce         string `json:"note_nonce"`
+	EncryptedNote     string `json:"encrypted_note"`
 }
 
 // ServerFileListResponse represents the server's file list response format
@@ -277,16 +335,71 @@ func main() {
 			logError("List files failed: %v", err)
 			os.Exit(1)
 		}
+	case "sync":
+		if err := handleSyncCommand(client, config, args); err != nil {
+			logError("Sync failed: %v", err)
+			os.Exit(1)
+		}
+	case "search":
+		if err := handleSearchCommand(client, config, args); err != nil {
+			logError("Search failed: %v", err)
+			os.Exit(1)
+		}
+	case "annotate":
+		if err := handleAnnotateCommand(client, config, args); err != nil {
+			logError("Annotate failed: %v", err)
+			os.Exit(1)
+		}
+	case "rename":
+		if err := handleRenameCommand(client, config, args); err != nil {
+			logError("Rename failed: %v", err)
+			os.Exit(1)
+		}
+	case "rekey":
+		if err := handleRekeyCommand(client, config, args); err != nil {
+			logError("Rekey failed: %v", err)
+			os.Exit(1)
+		}
+	case "mount":
+		if err := handleMountCommand(client, config, args); err != nil {
+			logError("Mount failed: %v", err)
+			os.Exit(1)
+		}
 	case "delete-file":
 		if err := handleDeleteFileCommand(client, config, args); err != nil {
 			logError("Delete file failed: %v", err)
 			os.Exit(1)
 		}
+	case "trash":
+		if err := handleTrashCommand(client, config, args); err != nil {
+			logError("Trash listing failed: %v", err)
+			os.Exit(1)
+		}
+	case "restore":
+		if err := handleRestoreCommand(client, config, args); err != nil {
+			logError("Restore failed: %v", err)
+			os.Exit(1)
+		}
+	case "version-retention":
+		if err := handleVersionRetentionCommand(client, config, args); err != nil {
+			logError("Version retention failed: %v", err)
+			os.Exit(1)
+		}
 	case "share":
 		if err := handleShareCommand(client, config, args); err != nil {
 			logError("Share command failed: %v", err)
 			os.Exit(1)
 		}
+	case "drop":
+		if err := handleDropCommand(client, config, args); err != nil {
+			logError("Drop command failed: %v", err)
+			os.Exit(1)
+		}
+	case "user-key":
+		if err := handleUserKeyCommand(client, config, args); err != nil {
+			logError("User key command failed: %v", err)
+			os.Exit(1)
+		}
 	case "export":
 		if err := handleExportCommand(client, config, args); err != nil {
 			logError("Export failed: %v", err)
@@ -1025,31 +1138,51 @@ func handleLoginCommand(client *HTTPClient, config *ClientConfig, args []string)
 	return nil
 }
 
-// populateDigestCache fetches the file list and populates the agent's digest cache
-func populateDigestCache(client *HTTPClient, session *AuthSession, accountKey []byte, agentClient *AgentClient) error {
-	req, err := http.NewRequest("GET", client.baseURL+"/api/files?limit=1000&offset=0", nil)
+// fetchFileList returns the authenticated user's files from GET /api/files:
+// the current version of each file.
+func fetchFileList(client *HTTPClient, session *AuthSession) ([]ServerFileInfo, error) {
+	return fetchFiles(client, session, "/api/files?limit=1000&offset=0")
+}
+
+// fetchAllFileVersions returns every stored version of the authenticated
+// user's files.
+func fetchAllFileVersions(client *HTTPClient, session *AuthSession) ([]ServerFileInfo, error) {
+	return fetchFiles(client, session, "/api/files?limit=1000&offset=0&versions=all")
+}
+
+func fetchFiles(client *HTTPClient, session *AuthSession, endpoint string) ([]ServerFileInfo, error) {
+	req, err := http.NewRequest("GET", client.baseURL+endpoint, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
 
 	resp, err := client.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned %d", resp.StatusCode)
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
 	}
 
 	var fileList ServerFileListResponse
 	if err := decodeJSONResponse(resp, &fileList); err != nil {
+		return nil, err
+	}
+	return fileList.Files, nil
+}
+
+// populateDigestCache fetches the file list and populates the agent's digest cache
+func populateDigestCache(client *HTTPClient, session *AuthSession, accountKey []byte, agentClient *AgentClient) error {
+	files, err := fetchFileList(client, session)
+	if err != nil {
 		return err
 	}
 
-	cache := make(map[string]string, len(fileList.Files))
-	for _, f := range fileList.Files {
+	cache := make(map[string]string, len(files))
+	for _, f := range files {
 		if f.EncryptedSHA256 == "" || f.SHA256Nonce == "" {
 			continue
 		}
@@ -1291,9 +1424,13 @@ func printUsage() {
 	fmt.Print(Usage)
 }
 
+// statusOut receives verbose logging and password prompts. It is switched
+// to stderr while stdout carries file data (download --output -).
+var statusOut io.Writer = os.Stdout
+
 func logVerbose(format string, args ...interface{}) {
 	if verbose {
-		fmt.Printf("[VERBOSE] "+format+"\n", args...)
+		fmt.Fprintf(statusOut, "[VERBOSE] "+format+"\n", args...)
 	}
 }
 
@@ -1514,6 +1651,30 @@ func ensureFreshSessionToken(client *HTTPClient, session *AuthSession, threshold
 	return nil
 }
 
+// sessionTokenSource serializes proactive JWT refreshes among the workers
+// of a parallel chunk pipeline, which share one *AuthSession.
+type sessionTokenSource struct {
+	mu      sync.Mutex
+	client  *HTTPClient
+	session *AuthSession
+}
+
+func newSessionTokenSource(client *HTTPClient, session *AuthSession) *sessionTokenSource {
+	return &sessionTokenSource{client: client, session: session}
+}
+
+// fresh refreshes the shared session if it is near expiry and returns a
+// snapshot of it that the caller can use without further locking.
+func (s *sessionTokenSource) fresh() (*AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ensureFreshSessionToken(s.client, s.session, 0); err != nil {
+		return nil, err
+	}
+	snapshot := *s.session
+	return &snapshot, nil
+}
+
 // makeRequestWithSession is a session-aware wrapper around makeRequest
 // that performs at most one refresh-and-retry on HTTP 401 and translates
 // 429 too_many_in_progress_uploads into the typed errTooManyInProgressUploads
@@ -1623,7 +1784,7 @@ func readPassword(prompt string) ([]byte, error) {
 	if (fi.Mode() & os.ModeCharDevice) != 0 {
 		// Interactive terminal mode with timeout
 		if prompt != "" {
-			fmt.Print(prompt)
+			fmt.Fprint(statusOut, prompt)
 		}
 		type readResult struct {
 			data []byte
@@ -1637,13 +1798,13 @@ func readPassword(prompt string) ([]byte, error) {
 		select {
 		case result := <-ch:
 			if result.err != nil {
-				fmt.Println()
+				fmt.Fprintln(statusOut)
 				return nil, result.err
 			}
-			fmt.Println()
+			fmt.Fprintln(statusOut)
 			return result.data, nil
 		case <-time.After(PasswordTimeoutInteractive):
-			fmt.Println("\nPassword entry timed out")
+			fmt.Fprintln(statusOut, "\nPassword entry timed out")
 			return nil, fmt.Errorf("password entry timed out after %v", PasswordTimeoutInteractive)
 		}
 	}
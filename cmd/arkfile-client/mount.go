@@ -0,0 +1,551 @@
+// mount.go - `arkfile-client mount`: the user's files as a read-only FUSE
+// filesystem.
+//
+// At mount time the client fetches the file list once and decrypts each
+// filename and folder path with the account key held by the agent, building
+// a static directory tree. Nothing is downloaded up front: a read fetches
+// and decrypts only the chunks it touches, through
+// GET /api/files/:fileId/chunks/:chunkIndex, and every chunk is checked
+// against its AES-GCM tag (and the chunk AAD) before any byte of it is
+// returned. A small cache of decrypted chunks serves sequential reads.
+//
+// Custom-password files are left out, since their keys cannot be unwrapped
+// with the account key. Files uploaded after mounting appear after a
+// remount.
+
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// defaultMountCacheChunks is the default number of decrypted chunks kept in
+// memory (16 MiB each with the default chunk size).
+const defaultMountCacheChunks = 4
+
+// vaultRootID is the inode number of the mount's root directory, which FUSE
+// fixes at 1.
+const vaultRootID = 1
+
+// vaultAttr is what the filesystem reports about a node.
+type vaultAttr struct {
+	Ino   uint64
+	Size  uint64
+	Mtime uint64
+	Mode  uint32 // file type and permission bits, as in stat(2)
+	Nlink uint32
+	UID   uint32
+	GID   uint32
+}
+
+// vaultDirEntry is one directory listing entry.
+type vaultDirEntry struct {
+	Ino  uint64
+	Name string
+	Dir  bool
+}
+
+// vaultMount is a mounted vault; see mountVault.
+type vaultMount interface {
+	Wait()
+	Unmount() error
+}
+
+// vaultNode is a directory or file in the mounted tree. Inode numbers are
+// indexes into vaultFS.nodes and never change while mounted.
+type vaultNode struct {
+	ino      uint64
+	name     string
+	parent   uint64
+	children map[string]uint64 // nil for files
+	file     *vaultFile
+	mtime    uint64
+}
+
+// vaultFile is the server-side identity and layout of a mounted file.
+type vaultFile struct {
+	meta       ServerFileInfo
+	size       int64 // plaintext size
+	chunkSize  int64 // plaintext bytes per chunk
+	chunkCount int64
+}
+
+// chunkFetcher downloads and decrypts one chunk of a file.
+type chunkFetcher func(ctx context.Context, fileID string, fek []byte, index, chunkCount int64) ([]byte, error)
+
+// vaultFS serves a static tree of the user's files by inode number; the FUSE
+// binding in fuse_linux.go calls it for every request. Errors that are not a
+// syscall.Errno are reported as EIO.
+type vaultFS struct {
+	nodes      []*vaultNode // nodes[0] is unused; nodes[vaultRootID] is the root
+	uid, gid   uint32
+	accountKey []byte
+	fetch      chunkFetcher
+	totalBytes uint64
+	fileCount  uint64
+
+	mu    sync.Mutex
+	feks  map[string][]byte // unwrapped on first open
+	cache *chunkCache
+}
+
+// buildVaultTree lays out the decrypted files as a tree and returns it along
+// with a note for every file that was left out. Two entries with the same
+// name in one directory keep the name for the first (by file_id); the others
+// get " (<file_id prefix>)" appended.
+func buildVaultTree(files []ServerFileInfo, accountKey []byte, username string) ([]*vaultNode, []string) {
+	root := &vaultNode{ino: vaultRootID, parent: vaultRootID, children: make(map[string]uint64)}
+	nodes := []*vaultNode{nil, root}
+	var skipped []string
+
+	dir := func(folder string, mtime uint64) *vaultNode {
+		node := root
+		if folder == "" {
+			return node
+		}
+		for _, part := range strings.Split(folder, "/") {
+			if ino, ok := node.children[part]; ok && nodes[ino].children != nil {
+				node = nodes[ino]
+			} else {
+				child := &vaultNode{ino: uint64(len(nodes)), name: part, parent: node.ino, children: make(map[string]uint64)}
+				nodes = append(nodes, child)
+				if ok {
+					// A file already holds the name; the folder takes precedence
+					renameVaultNode(node, nodes[ino], nodes)
+				}
+				node.children[part] = child.ino
+				node = child
+			}
+			node.mtime = max(node.mtime, mtime)
+		}
+		return node
+	}
+
+	sorted := append([]ServerFileInfo(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FileID < sorted[j].FileID })
+	for _, f := range sorted {
+		if f.PasswordType == "custom" {
+			skipped = append(skipped, fmt.Sprintf("%s: custom-password file", f.FileID))
+			continue
+		}
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = username
+		}
+		name, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+			f.FileID, crypto.AADFieldFilename, owner)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: filename could not be decrypted", f.FileID))
+			continue
+		}
+		folder, err := decryptFileFolder(f, accountKey, owner)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: folder could not be decrypted", f.FileID))
+			continue
+		}
+		if !isPlainFileName(name) {
+			skipped = append(skipped, fmt.Sprintf("%s: filename cannot be used as a path", f.FileID))
+			continue
+		}
+
+		mtime := uint64(0)
+		if t, err := time.Parse(time.RFC3339, f.UploadDate); err == nil {
+			mtime = uint64(t.Unix())
+		}
+		parent := dir(folder, mtime)
+		file := &vaultNode{ino: uint64(len(nodes)), name: name, parent: parent.ino, file: newVaultFile(f), mtime: mtime}
+		nodes = append(nodes, file)
+		if _, taken := parent.children[name]; taken {
+			renameVaultNode(parent, file, nodes)
+		} else {
+			parent.children[name] = file.ino
+		}
+	}
+	return nodes, skipped
+}
+
+// renameVaultNode gives file node n a name in parent that is not taken,
+// derived from its file_id, and links it there.
+func renameVaultNode(parent, n *vaultNode, nodes []*vaultNode) {
+	if parent.children[n.name] == n.ino {
+		delete(parent.children, n.name)
+	}
+	id := n.file.meta.FileID
+	for l := 8; ; l++ {
+		candidate := fmt.Sprintf("%s (%s)", n.name, id[:min(l, len(id))])
+		if l > len(id) {
+			candidate = fmt.Sprintf("%s (%s-%d)", n.name, id, n.ino)
+		}
+		if _, taken := parent.children[candidate]; !taken {
+			n.name = candidate
+			parent.children[candidate] = n.ino
+			return
+		}
+	}
+}
+
+func newVaultFile(meta ServerFileInfo) *vaultFile {
+	chunkCount := max(meta.ChunkCount, 1)
+	chunkSize := meta.ChunkSizeBytes
+	if chunkSize <= 0 {
+		chunkSize = crypto.PlaintextChunkSize()
+	}
+	// size_bytes is the encrypted size: every chunk carries nonce + tag
+	size := max(meta.SizeBytes-chunkCount*int64(crypto.AesGcmOverhead()), 0)
+	return &vaultFile{meta: meta, size: size, chunkSize: chunkSize, chunkCount: chunkCount}
+}
+
+func newVaultFS(nodes []*vaultNode, accountKey []byte, fetch chunkFetcher, cacheChunks int) *vaultFS {
+	v := &vaultFS{
+		nodes:      nodes,
+		uid:        uint32(os.Getuid()),
+		gid:        uint32(os.Getgid()),
+		accountKey: accountKey,
+		fetch:      fetch,
+		feks:       make(map[string][]byte),
+		cache:      newChunkCache(cacheChunks),
+	}
+	for _, n := range nodes[vaultRootID:] {
+		if n.file != nil {
+			v.fileCount++
+			v.totalBytes += uint64(n.file.size)
+		}
+	}
+	return v
+}
+
+// close wipes all key material and cached plaintext.
+func (v *vaultFS) close() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for id, fek := range v.feks {
+		clearBytes(fek)
+		delete(v.feks, id)
+	}
+	v.cache.clear()
+}
+
+func (v *vaultFS) node(ino uint64) (*vaultNode, error) {
+	if ino < vaultRootID || ino >= uint64(len(v.nodes)) {
+		return nil, syscall.ENOENT
+	}
+	return v.nodes[ino], nil
+}
+
+func (v *vaultFS) attr(n *vaultNode) vaultAttr {
+	a := vaultAttr{Ino: n.ino, Mtime: n.mtime, UID: v.uid, GID: v.gid}
+	if n.file != nil {
+		a.Mode = syscall.S_IFREG | 0400
+		a.Size = uint64(n.file.size)
+		a.Nlink = 1
+	} else {
+		a.Mode = syscall.S_IFDIR | 0500
+		a.Nlink = 2
+	}
+	return a
+}
+
+func (v *vaultFS) Lookup(parent uint64, name string) (vaultAttr, error) {
+	p, err := v.node(parent)
+	if err != nil {
+		return vaultAttr{}, err
+	}
+	if p.children == nil {
+		return vaultAttr{}, syscall.ENOTDIR
+	}
+	ino, ok := p.children[name]
+	if !ok {
+		return vaultAttr{}, syscall.ENOENT
+	}
+	return v.attr(v.nodes[ino]), nil
+}
+
+func (v *vaultFS) Getattr(ino uint64) (vaultAttr, error) {
+	n, err := v.node(ino)
+	if err != nil {
+		return vaultAttr{}, err
+	}
+	return v.attr(n), nil
+}
+
+// Open unwraps the file's FEK with the account key, once per file.
+func (v *vaultFS) Open(ino uint64) error {
+	n, err := v.node(ino)
+	if err != nil {
+		return err
+	}
+	if n.file == nil {
+		return syscall.EISDIR
+	}
+	_, err = v.fek(n.file)
+	return err
+}
+
+func (v *vaultFS) fek(f *vaultFile) ([]byte, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if fek, ok := v.feks[f.meta.FileID]; ok {
+		return fek, nil
+	}
+	fek, _, err := unwrapFEK(f.meta.EncryptedFEK, v.accountKey, f.meta.FileID)
+	if err != nil {
+		logVerbose("mount: failed to unwrap FEK of %s: %v", f.meta.FileID, err)
+		return nil, syscall.EACCES
+	}
+	v.feks[f.meta.FileID] = fek
+	return fek, nil
+}
+
+// Read returns up to size bytes at off, decrypting only the chunks that
+// overlap the range.
+func (v *vaultFS) Read(ino uint64, off int64, size int) ([]byte, error) {
+	n, err := v.node(ino)
+	if err != nil {
+		return nil, err
+	}
+	f := n.file
+	if f == nil {
+		return nil, syscall.EISDIR
+	}
+	if off < 0 {
+		return nil, syscall.EINVAL
+	}
+	end := min(off+int64(size), f.size)
+	if off >= end {
+		return nil, nil
+	}
+	fek, err := v.fek(f)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, end-off)
+	for i := off / f.chunkSize; i*f.chunkSize < end; i++ {
+		chunk, err := v.chunk(f, fek, i)
+		if err != nil {
+			return nil, err
+		}
+		start := i * f.chunkSize
+		lo := max(off, start) - start
+		hi := min(end-start, int64(len(chunk)))
+		if lo >= hi {
+			return nil, fmt.Errorf("chunk %d of %s is shorter than expected", i, f.meta.FileID)
+		}
+		out = append(out, chunk[lo:hi]...)
+		clearBytes(chunk)
+	}
+	return out, nil
+}
+
+// chunk returns the caller's own copy of a decrypted chunk, which the caller
+// wipes when done with it.
+func (v *vaultFS) chunk(f *vaultFile, fek []byte, index int64) ([]byte, error) {
+	key := chunkCacheKey{fileID: f.meta.FileID, index: index}
+	if chunk, ok := v.cache.get(key); ok {
+		return chunk, nil
+	}
+	chunk, err := v.fetch(context.Background(), f.meta.FileID, fek, index, f.chunkCount)
+	if err != nil {
+		return nil, err
+	}
+	v.cache.put(key, bytes.Clone(chunk))
+	return chunk, nil
+}
+
+func (v *vaultFS) ReadDir(ino uint64) ([]vaultDirEntry, error) {
+	n, err := v.node(ino)
+	if err != nil {
+		return nil, err
+	}
+	if n.children == nil {
+		return nil, syscall.ENOTDIR
+	}
+	entries := []vaultDirEntry{
+		{Ino: n.ino, Name: ".", Dir: true},
+		{Ino: n.parent, Name: "..", Dir: true},
+	}
+	for _, name := range sortedKeys(n.children) {
+		child := v.nodes[n.children[name]]
+		entries = append(entries, vaultDirEntry{Ino: child.ino, Name: name, Dir: child.children != nil})
+	}
+	return entries, nil
+}
+
+func (v *vaultFS) Statfs() (uint64, uint64) {
+	return v.fileCount, v.totalBytes
+}
+
+// ============================================================
+// CHUNK CACHE
+// ============================================================
+
+type chunkCacheKey struct {
+	fileID string
+	index  int64
+}
+
+type chunkCacheEntry struct {
+	key  chunkCacheKey
+	data []byte
+}
+
+// chunkCache is a small LRU of decrypted chunks. The cache owns the slices
+// it holds and hands out copies, so evicted chunks are wiped.
+type chunkCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front = most recently used
+	entries  map[chunkCacheKey]*list.Element
+}
+
+func newChunkCache(capacity int) *chunkCache {
+	return &chunkCache{capacity: capacity, order: list.New(), entries: make(map[chunkCacheKey]*list.Element)}
+}
+
+// get returns a copy of the cached chunk, which the caller owns.
+func (c *chunkCache) get(key chunkCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return bytes.Clone(e.Value.(*chunkCacheEntry).data), true
+	}
+	return nil, false
+}
+
+// put hands data to the cache, which wipes it once it is evicted (or at
+// once, when it is not kept). The caller must not use data afterwards.
+func (c *chunkCache) put(key chunkCacheKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || c.capacity <= 0 {
+		clearBytes(data)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&chunkCacheEntry{key: key, data: data})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*chunkCacheEntry)
+		delete(c.entries, entry.key)
+		clearBytes(entry.data)
+	}
+}
+
+func (c *chunkCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries {
+		clearBytes(e.Value.(*chunkCacheEntry).data)
+	}
+	c.entries = make(map[chunkCacheKey]*list.Element)
+	c.order.Init()
+}
+
+// ============================================================
+// MOUNT COMMAND
+// ============================================================
+
+func handleMountCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("mount", flag.ExitOnError)
+	cacheChunks := fs.Int("cache-chunks", defaultMountCacheChunks, "Number of decrypted chunks to keep in memory")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client mount <mountpoint> [--cache-chunks N]\n\n" +
+			"Present your files as a read-only filesystem (Linux, requires FUSE and fusermount).\n" +
+			"Filenames and folders are decrypted with the account key held by the agent. Reads fetch\n" +
+			"and decrypt only the chunks they touch; each chunk is authenticated before use.\n" +
+			"Custom-password files are not shown. The file list is read once at mount time.\n" +
+			"Runs in the foreground; press Ctrl-C (or run fusermount -u <mountpoint>) to unmount.\n")
+	}
+
+	// Allow flags after the mountpoint as well as before it
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one mountpoint is required")
+	}
+	if *cacheChunks < 0 {
+		return fmt.Errorf("--cache-chunks must not be negative")
+	}
+	mountpoint := positional[0]
+	if info, err := os.Stat(mountpoint); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", mountpoint)
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	files, err := fetchFileList(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch file list: %w", err)
+	}
+	nodes, skipped := buildVaultTree(files, accountKey, session.Username)
+	for _, msg := range skipped {
+		logVerbose("mount: not shown: %s", msg)
+	}
+
+	tokens := newSessionTokenSource(client, session)
+	fetch := func(ctx context.Context, fileID string, fek []byte, index, chunkCount int64) ([]byte, error) {
+		return downloadChunk(ctx, client, tokens, fileID, fek, index, chunkCount)
+	}
+	vfs := newVaultFS(nodes, accountKey, fetch, *cacheChunks)
+	defer vfs.close()
+
+	mnt, err := mountVault(mountpoint, vfs)
+	if err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			if err := mnt.Unmount(); err != nil {
+				logError("Unmount failed: %v (run fusermount -u %s)", err, mountpoint)
+			}
+		}
+	}()
+
+	fmt.Printf("Mounted %d files at %s (read-only). Press Ctrl-C to unmount.\n", vfs.fileCount, filepath.Clean(mountpoint))
+	if len(skipped) > 0 {
+		fmt.Printf("%d files are not shown (custom password or undecryptable metadata; use --verbose for details).\n", len(skipped))
+	}
+
+	mnt.Wait()
+	fmt.Println("Unmounted.")
+	return nil
+}
@@ -0,0 +1,241 @@
+// mount_test.go - Unit tests for `arkfile-client mount`: laying out the
+// decrypted vault as a tree, and serving reads from only the chunks they
+// touch. Chunks come from a fake fetcher; nothing is mounted.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func mountTestFile(t *testing.T, accountKey []byte, id, folder, name string, size, chunkSize int64) ServerFileInfo {
+	t.Helper()
+	encFn, fnNonce, encSHA, shaNonce, err := encryptMetadata(name, "sha-"+id, accountKey, id, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkCount := max((size+chunkSize-1)/chunkSize, 1)
+	f := ServerFileInfo{
+		FileID:            id,
+		PasswordType:      "account",
+		EncryptedFilename: encFn,
+		FilenameNonce:     fnNonce,
+		EncryptedSHA256:   encSHA,
+		SHA256Nonce:       shaNonce,
+		SizeBytes:         size + chunkCount*int64(crypto.AesGcmOverhead()),
+		ChunkCount:        chunkCount,
+		ChunkSizeBytes:    chunkSize,
+		UploadDate:        "2026-03-01T12:00:00Z",
+	}
+	if folder != "" {
+		if f.EncryptedFolder, f.FolderNonce, err = encryptFolderPath(folder, accountKey, id, testOwner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// vaultPaths lists every path in the tree, depth first.
+func vaultPaths(nodes []*vaultNode, ino uint64, prefix string) []string {
+	var out []string
+	n := nodes[ino]
+	for _, name := range sortedKeys(n.children) {
+		child := nodes[n.children[name]]
+		p := prefix + name
+		if child.children != nil {
+			out = append(out, p+"/")
+			out = append(out, vaultPaths(nodes, child.ino, p+"/")...)
+		} else {
+			out = append(out, fmt.Sprintf("%s=%s", p, child.file.meta.FileID))
+		}
+	}
+	return out
+}
+
+func TestBuildVaultTree(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := mountTestFile(t, accountKey, "id-custom", "", "secret.txt", 10, 16)
+	custom.PasswordType = "custom"
+	garbled := mountTestFile(t, accountKey, "id-garbled", "", "x.txt", 10, 16)
+	garbled.FileID = "id-garbled-moved" // AAD no longer matches
+	files := []ServerFileInfo{
+		mountTestFile(t, accountKey, "id-b-cv-second", "docs", "cv.pdf", 10, 16),
+		mountTestFile(t, accountKey, "id-a-cv-first", "docs", "cv.pdf", 10, 16),
+		mountTestFile(t, accountKey, "id-q1", "docs/2026", "q1.xlsx", 10, 16),
+		mountTestFile(t, accountKey, "id-readme", "", "readme.txt", 10, 16),
+		mountTestFile(t, accountKey, "id-dots", "", "..", 10, 16),
+		custom,
+		garbled,
+	}
+
+	nodes, skipped := buildVaultTree(files, accountKey, testOwner)
+	got := strings.Join(vaultPaths(nodes, vaultRootID, ""), " ")
+	want := "docs/ docs/2026/ docs/2026/q1.xlsx=id-q1 docs/cv.pdf=id-a-cv-first " +
+		"docs/cv.pdf (id-b-cv-)=id-b-cv-second readme.txt=id-readme"
+	if got != want {
+		t.Errorf("tree:\n %s\nwant:\n %s", got, want)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("skipped %q, want the custom, garbled and '..' files", skipped)
+	}
+	for i, n := range nodes[vaultRootID:] {
+		if n.ino != uint64(i+vaultRootID) {
+			t.Fatalf("node %d has inode %d", i+vaultRootID, n.ino)
+		}
+	}
+}
+
+func TestBuildVaultTree_FolderAndFileNameClash(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []ServerFileInfo{
+		mountTestFile(t, accountKey, "id-1-file", "", "docs", 10, 16),
+		mountTestFile(t, accountKey, "id-2-nested", "docs", "a.txt", 10, 16),
+	}
+	nodes, _ := buildVaultTree(files, accountKey, testOwner)
+	got := strings.Join(vaultPaths(nodes, vaultRootID, ""), " ")
+	if want := "docs/ docs/a.txt=id-2-nested docs (id-1-fil)=id-1-file"; got != want {
+		t.Errorf("tree: %s, want %s", got, want)
+	}
+}
+
+// fakeChunks serves the plaintext chunks of files and records fetches.
+type fakeChunks struct {
+	mu      sync.Mutex
+	data    map[string][]byte
+	size    int64
+	fetched []string
+}
+
+func (c *fakeChunks) fetch(ctx context.Context, fileID string, fek []byte, index, chunkCount int64) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetched = append(c.fetched, fmt.Sprintf("%s/%d", fileID, index))
+	data := c.data[fileID]
+	start := index * c.size
+	return append([]byte(nil), data[start:min(start+c.size, int64(len(data)))]...), nil
+}
+
+func TestVaultFS_ReadFetchesOnlyTouchedChunks(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte(strings.Repeat("0123456789", 10)) // 100 bytes, 7 chunks of 16
+	meta := mountTestFile(t, accountKey, testFileID, "", "log.txt", int64(len(plaintext)), 16)
+	fek := bytes.Repeat([]byte{7}, 32)
+	if meta.EncryptedFEK, err = wrapFEK(fek, accountKey, "account", testFileID); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, _ := buildVaultTree([]ServerFileInfo{meta}, accountKey, testOwner)
+	chunks := &fakeChunks{data: map[string][]byte{testFileID: plaintext}, size: 16}
+	vfs := newVaultFS(nodes, accountKey, chunks.fetch, 2)
+	defer vfs.close()
+
+	attr, err := vfs.Lookup(vaultRootID, "log.txt")
+	if err != nil || attr.Size != 100 || attr.Mode != syscall.S_IFREG|0400 {
+		t.Fatalf("Lookup = %+v, %v", attr, err)
+	}
+	if err := vfs.Open(attr.Ino); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Bytes 30..50 span chunks 1, 2 and 3
+	got, err := vfs.Read(attr.Ino, 30, 20)
+	if err != nil || !bytes.Equal(got, plaintext[30:50]) {
+		t.Fatalf("Read(30, 20) = %q, %v", got, err)
+	}
+	if f := strings.Join(chunks.fetched, " "); f != testFileID+"/1 "+testFileID+"/2 "+testFileID+"/3" {
+		t.Errorf("fetched %s", f)
+	}
+
+	// Chunk 3 is cached; chunk 6 is the short last chunk
+	chunks.fetched = nil
+	got, err = vfs.Read(attr.Ino, 50, 4096)
+	if err != nil || !bytes.Equal(got, plaintext[50:]) {
+		t.Fatalf("Read(50, 4096) = %q, %v", got, err)
+	}
+	if f := strings.Join(chunks.fetched, " "); strings.Contains(f, "/3") || !strings.HasSuffix(f, "/6") {
+		t.Errorf("fetched %s", f)
+	}
+
+	if got, err := vfs.Read(attr.Ino, 100, 10); err != nil || len(got) != 0 {
+		t.Errorf("read at EOF = %q, %v", got, err)
+	}
+
+	if _, err := vfs.Read(vaultRootID, 0, 10); err != syscall.EISDIR {
+		t.Errorf("reading a directory: %v, want EISDIR", err)
+	}
+}
+
+func TestVaultFS_OpenWithWrongKey(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := mountTestFile(t, accountKey, testFileID, "", "a.txt", 10, 16)
+	if meta.EncryptedFEK, err = wrapFEK(bytes.Repeat([]byte{1}, 32), otherKey, "account", testFileID); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := buildVaultTree([]ServerFileInfo{meta}, accountKey, testOwner)
+	vfs := newVaultFS(nodes, accountKey, (&fakeChunks{}).fetch, 2)
+	attr, err := vfs.Lookup(vaultRootID, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vfs.Open(attr.Ino); err != syscall.EACCES {
+		t.Errorf("Open with an FEK wrapped by another key: %v, want EACCES", err)
+	}
+}
+
+func TestChunkCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newChunkCache(2)
+	a, b, d := chunkCacheKey{"f", 0}, chunkCacheKey{"f", 1}, chunkCacheKey{"f", 2}
+	bData := []byte("b")
+	c.put(a, []byte("a"))
+	c.put(b, bData)
+	c.get(a)
+	c.put(d, []byte("d"))
+	if _, ok := c.get(b); ok {
+		t.Error("least recently used chunk was not evicted")
+	}
+	if bData[0] != 0 {
+		t.Error("evicted chunk must be wiped")
+	}
+	if _, ok := c.get(a); !ok {
+		t.Error("recently used chunk was evicted")
+	}
+
+	// Readers get their own copy, which eviction and clear leave alone
+	data, _ := c.get(d)
+	data[0] = 'x'
+	if again, _ := c.get(d); again[0] != 'd' {
+		t.Error("get must return a copy")
+	}
+	cached := c.entries[d].Value.(*chunkCacheEntry).data
+	c.clear()
+	if cached[0] != 0 {
+		t.Error("clear must wipe cached plaintext")
+	}
+	if data[0] != 'x' {
+		t.Error("clear must not touch a reader's copy")
+	}
+}
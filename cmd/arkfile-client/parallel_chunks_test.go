@@ -0,0 +1,207 @@
+// parallel_chunks_test.go - Unit tests for the --parallel chunk pipelines in
+// uploadFileChunks and doChunkedDownload: every chunk is sent exactly once,
+// downloads are reassembled in order, and failures stop the pipeline.
+//
+// All tests run against httptest servers and stay offline-safe.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const parallelTestFileID = "f1f1f1f1-2222-4333-8444-555555555555"
+
+// chunkIndexFromPath returns the trailing /chunks/N index of a request path.
+func chunkIndexFromPath(t *testing.T, path string) int64 {
+	t.Helper()
+	i, err := strconv.ParseInt(path[strings.LastIndex(path, "/")+1:], 10, 64)
+	if err != nil {
+		t.Errorf("bad chunk path %q", path)
+	}
+	return i
+}
+
+func TestUploadFileChunks_Parallel(t *testing.T) {
+	plaintext := []byte(strings.Repeat("0123456789", 10)) // 100 bytes, 13 chunks of 8
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, plaintext, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fek := bytes.Repeat([]byte{7}, 32)
+	const chunkSize, chunkCount = 8, 13
+
+	var mu sync.Mutex
+	received := make(map[int64][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		i := chunkIndexFromPath(t, r.URL.Path)
+		// Early chunks are slow, so later ones finish first
+		time.Sleep(time.Duration(chunkCount-i) * time.Millisecond)
+		mu.Lock()
+		if _, dup := received[i]; dup {
+			t.Errorf("chunk %d uploaded twice", i)
+		}
+		received[i] = body
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	params := &ChunkedUploadParams{
+		FilePath:       path,
+		FileID:         parallelTestFileID,
+		FEK:            fek,
+		FileSizeBytes:  int64(len(plaintext)),
+		ChunkCount:     chunkCount,
+		ChunkSizeBytes: chunkSize,
+		Parallel:       4,
+	}
+	if err := uploadFileChunks(client, newTestSession("tok", "ref", 30*time.Minute), params, "sess", nil); err != nil {
+		t.Fatalf("uploadFileChunks: %v", err)
+	}
+
+	var reassembled []byte
+	for i := int64(0); i < chunkCount; i++ {
+		chunk, ok := received[i]
+		if !ok {
+			t.Fatalf("chunk %d was never uploaded", i)
+		}
+		pt, err := decryptChunk(chunk, fek, parallelTestFileID, i, chunkCount)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		reassembled = append(reassembled, pt...)
+	}
+	if !bytes.Equal(reassembled, plaintext) {
+		t.Error("uploaded chunks do not reassemble to the original file")
+	}
+}
+
+func TestUploadFileChunks_ParallelFailureStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, make([]byte, 64*8), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	uploaded := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chunkIndexFromPath(t, r.URL.Path) == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "boom")
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		uploaded++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	params := &ChunkedUploadParams{
+		FilePath:       path,
+		FileID:         parallelTestFileID,
+		FEK:            make([]byte, 32),
+		FileSizeBytes:  64 * 8,
+		ChunkCount:     64,
+		ChunkSizeBytes: 8,
+		Parallel:       3,
+	}
+	err := uploadFileChunks(client, newTestSession("tok", "ref", 30*time.Minute), params, "sess", nil)
+	if err == nil || !strings.Contains(err.Error(), "chunk 2") {
+		t.Fatalf("expected chunk 2 failure, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if uploaded >= 63 {
+		t.Errorf("pipeline kept uploading after the failure (%d chunks)", uploaded)
+	}
+}
+
+// serveEncryptedChunks serves plaintext split into chunkSize chunks, each
+// encrypted for parallelTestFileID, answering later chunks faster. The chunk
+// at tamper (if >= 0) is corrupted.
+func serveEncryptedChunks(t *testing.T, plaintext, fek []byte, chunkSize int, tamper int64) (*httptest.Server, int64) {
+	t.Helper()
+	chunkCount := int64((len(plaintext) + chunkSize - 1) / chunkSize)
+	chunks := make([][]byte, chunkCount)
+	for i := range chunks {
+		end := min((i+1)*chunkSize, len(plaintext))
+		enc, err := encryptChunk(plaintext[i*chunkSize:end], fek, parallelTestFileID, int64(i), chunkCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(i) == tamper {
+			enc[len(enc)-1] ^= 0xff
+		}
+		chunks[i] = enc
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := chunkIndexFromPath(t, r.URL.Path)
+		time.Sleep(time.Duration(chunkCount-i) * time.Millisecond)
+		w.Write(chunks[i])
+	}))
+	return srv, chunkCount
+}
+
+func TestDoChunkedDownload_ParallelReassemblesInOrder(t *testing.T) {
+	plaintext := []byte(strings.Repeat("abcdefghij", 20))
+	fek := bytes.Repeat([]byte{3}, 32)
+	srv, chunkCount := serveEncryptedChunks(t, plaintext, fek, 16, -1)
+	defer srv.Close()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	meta := ServerFileInfo{ChunkCount: chunkCount}
+	if err := doChunkedDownload(client, newTestSession("tok", "ref", 30*time.Minute), parallelTestFileID, fek, meta, out, 5); err != nil {
+		t.Fatalf("doChunkedDownload: %v", err)
+	}
+	got, _ := os.ReadFile(out.Name())
+	if !bytes.Equal(got, plaintext) {
+		t.Error("downloaded file does not match the original")
+	}
+}
+
+func TestDoChunkedDownload_ParallelStopsAtTamperedChunk(t *testing.T) {
+	plaintext := []byte(strings.Repeat("abcdefghij", 20))
+	fek := bytes.Repeat([]byte{3}, 32)
+	srv, chunkCount := serveEncryptedChunks(t, plaintext, fek, 16, 4)
+	defer srv.Close()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	meta := ServerFileInfo{ChunkCount: chunkCount}
+	err = doChunkedDownload(client, newTestSession("tok", "ref", 30*time.Minute), parallelTestFileID, fek, meta, out, 4)
+	if err == nil || !strings.Contains(err.Error(), "chunk 4") {
+		t.Fatalf("expected chunk 4 decryption failure, got %v", err)
+	}
+
+	// Nothing from the tampered chunk onwards reaches the output
+	got, _ := os.ReadFile(out.Name())
+	if len(got) > 4*16 || !bytes.Equal(got, plaintext[:len(got)]) {
+		t.Errorf("output holds %d bytes; want at most the 64 bytes before the tampered chunk", len(got))
+	}
+}
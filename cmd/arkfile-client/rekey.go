@@ -0,0 +1,221 @@
+// rekey.go - Rename a file or change its password type without re-uploading.
+//
+// `rename` encrypts a new filename with the account key and replaces the
+// stored one. `rekey` unwraps the file's FEK with its current KEK and wraps
+// it again for the account password or a new custom password. Either way
+// the encrypted blob in storage is untouched, and because the FEK itself
+// does not change, existing shares keep working.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// fetchFileMeta returns the owner metadata of a file, including its FEK
+// envelope and password type.
+func fetchFileMeta(client *HTTPClient, session *AuthSession, fileID string) (*ServerFileInfo, error) {
+	req, err := http.NewRequest("GET", client.baseURL+"/api/files/"+fileID+"/meta", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP %d for metadata request", resp.StatusCode)
+	}
+
+	var meta ServerFileInfo
+	if err := decodeJSONResponse(resp, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode file metadata: %w", err)
+	}
+	meta.FileID = fileID
+	if meta.OwnerUsername == "" {
+		meta.OwnerUsername = session.Username
+	}
+	return &meta, nil
+}
+
+// rewrapFEK unwraps encryptedFEK with oldKEK and wraps the FEK again with
+// newKEK for newType. The plaintext FEK is cleared before returning.
+func rewrapFEK(encryptedFEK string, oldKEK, newKEK []byte, newType, fileID string) (string, error) {
+	fek, _, err := unwrapFEK(encryptedFEK, oldKEK, fileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap FEK: %w", err)
+	}
+	defer clearBytes(fek)
+	return wrapFEK(fek, newKEK, newType, fileID)
+}
+
+// handleRenameCommand replaces the encrypted filename of a file.
+func handleRenameCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	fileID := fs.String("file-id", "", "File ID to rename")
+	name := fs.String("name", "", "New filename")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client rename --file-id FILE_ID --name NEW_NAME\n\n" +
+			"Rename a file without re-uploading it. The new name is encrypted with your\n" +
+			"account key; if the file is in the search index it is re-indexed under the new name.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fileID == "" || *name == "" {
+		return fmt.Errorf("--file-id and --name are required")
+	}
+	if !isPlainFileName(*name) {
+		return fmt.Errorf("invalid filename %q: it may not contain '/' or '\\'", *name)
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	meta, err := fetchFileMeta(client, session, *fileID)
+	if err != nil {
+		return err
+	}
+	oldName := decryptedFilename(*meta, accountKey, meta.OwnerUsername)
+
+	encName, nonce, err := encryptMetadataField(*name, accountKey, *fileID, crypto.AADFieldFilename, meta.OwnerUsername)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt filename: %w", err)
+	}
+	resp, err := client.makeRequestWithSession("PATCH", "/api/files/"+*fileID, map[string]interface{}{
+		"encrypted_filename": encName,
+		"filename_nonce":     nonce,
+	}, session)
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	fmt.Printf("Renamed %s: %s -> %s\n", *fileID, oldName, *name)
+
+	// The server drops the search tokens of the old name; index the new one
+	// if the file was indexed before.
+	if cleared, _ := resp.Data["search_tokens_cleared"].(bool); cleared {
+		folder, err := decryptFileFolder(*meta, accountKey, meta.OwnerUsername)
+		if err == nil {
+			err = indexFile(client, session, accountKey, *fileID, *name, folder)
+		}
+		if err != nil {
+			fmt.Printf("[!] Renamed but not re-indexed for search: %v (run 'search --reindex')\n", err)
+		}
+	}
+	return nil
+}
+
+// handleRekeyCommand re-wraps a file's FEK for a different password.
+func handleRekeyCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	fileID := fs.String("file-id", "", "File ID to re-key")
+	passwordType := fs.String("password-type", "", "New password type: account or custom")
+	hint := fs.String("hint", "", "Password hint (for custom password)")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client rekey --file-id FILE_ID --password-type account|custom [--hint HINT]\n\n" +
+			"Switch a file between your account password and a custom password, or change\n" +
+			"its custom password, without re-uploading it. The file key is unwrapped and\n" +
+			"wrapped again on this machine; existing shares of the file keep working.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fileID == "" {
+		return fmt.Errorf("--file-id is required")
+	}
+	if *passwordType != "account" && *passwordType != "custom" {
+		return fmt.Errorf("invalid --password-type: must be 'account' or 'custom'")
+	}
+	if *hint != "" && *passwordType != "custom" {
+		return fmt.Errorf("--hint is only used with --password-type custom")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	meta, err := fetchFileMeta(client, session, *fileID)
+	if err != nil {
+		return err
+	}
+	if meta.EncryptedFEK == "" {
+		return fmt.Errorf("file metadata missing encrypted FEK")
+	}
+	currentType := meta.PasswordType
+	if currentType == "" {
+		currentType = "account"
+	}
+	if currentType == "account" && *passwordType == "account" {
+		return fmt.Errorf("file %s already uses the account password", *fileID)
+	}
+
+	var oldKEK []byte
+	switch currentType {
+	case "account":
+		oldKEK = accountKey
+	case "custom":
+		customPass, err := readPassword("Enter current custom password for this file: ")
+		if err != nil {
+			return fmt.Errorf("failed to read custom password: %w", err)
+		}
+		defer clearBytes(customPass)
+		oldKEK = crypto.DeriveCustomPasswordKey(customPass, config.Username)
+		defer clearBytes(oldKEK)
+	default:
+		return fmt.Errorf("unsupported password type: %s", meta.PasswordType)
+	}
+
+	var newKEK []byte
+	switch *passwordType {
+	case "account":
+		newKEK = accountKey
+	case "custom":
+		customPass, err := readPasswordWithStrengthCheck("Enter new custom password for this file: ", "custom")
+		if err != nil {
+			return fmt.Errorf("failed to read custom password: %w", err)
+		}
+		defer clearBytes(customPass)
+		newKEK = crypto.DeriveCustomPasswordKey(customPass, config.Username)
+		defer clearBytes(newKEK)
+	}
+
+	encryptedFEK, err := rewrapFEK(meta.EncryptedFEK, oldKEK, newKEK, *passwordType, *fileID)
+	if err != nil {
+		return err
+	}
+	if _, err := client.makeRequestWithSession("PATCH", "/api/files/"+*fileID, map[string]interface{}{
+		"encrypted_fek": encryptedFEK,
+		"password_type": *passwordType,
+		"password_hint": *hint,
+	}, session); err != nil {
+		return fmt.Errorf("failed to re-key file: %w", err)
+	}
+
+	fmt.Printf("File %s now uses the %s password (was %s).\n", *fileID, *passwordType, currentType)
+	return nil
+}
@@ -0,0 +1,85 @@
+// rekey_test.go - Unit tests for renaming and re-keying files.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestRewrapFEK_AccountToCustomAndBack(t *testing.T) {
+	accountKey := make([]byte, 32)
+	customKEK := bytes.Repeat([]byte{0x42}, 32)
+	fek := bytes.Repeat([]byte{0x07}, 32)
+
+	original, err := wrapFEK(fek, accountKey, "account", testFileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	custom, err := rewrapFEK(original, accountKey, customKEK, "custom", testFileID)
+	if err != nil {
+		t.Fatalf("rewrapFEK to custom: %v", err)
+	}
+	got, keyType, err := unwrapFEK(custom, customKEK, testFileID)
+	if err != nil {
+		t.Fatalf("unwrap with the custom KEK: %v", err)
+	}
+	if !bytes.Equal(got, fek) || keyType != "custom" {
+		t.Errorf("unwrapped %x (%s), want the original FEK as custom", got, keyType)
+	}
+	if _, _, err := unwrapFEK(custom, accountKey, testFileID); err == nil {
+		t.Error("the account key must no longer unwrap the FEK")
+	}
+
+	back, err := rewrapFEK(custom, customKEK, accountKey, "account", testFileID)
+	if err != nil {
+		t.Fatalf("rewrapFEK to account: %v", err)
+	}
+	if got, keyType, err = unwrapFEK(back, accountKey, testFileID); err != nil || !bytes.Equal(got, fek) || keyType != "account" {
+		t.Errorf("round trip back to account failed: %x %s %v", got, keyType, err)
+	}
+
+	if _, err := rewrapFEK(original, customKEK, accountKey, "custom", testFileID); err == nil {
+		t.Error("expected an error when the current KEK is wrong")
+	}
+}
+
+func TestFetchFileMeta_DefaultsOwner(t *testing.T) {
+	accountKey := make([]byte, 32)
+	encName, nonce, err := encryptMetadataField("report.pdf", accountKey, testFileID, crypto.AADFieldFilename, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/files/"+testFileID+"/meta" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"encrypted_filename":"` + encName + `","filename_nonce":"` + nonce + `","password_type":"custom"}`))
+	}))
+	defer srv.Close()
+
+	session := newTestSession("tok", "ref", 30*time.Minute)
+	session.Username = "alice"
+	meta, err := fetchFileMeta(newHTTPClient(srv.URL, false, 10, false), session, testFileID)
+	if err != nil {
+		t.Fatalf("fetchFileMeta: %v", err)
+	}
+	if meta.FileID != testFileID || meta.OwnerUsername != "alice" || meta.PasswordType != "custom" {
+		t.Errorf("meta = %+v", meta)
+	}
+	if name := decryptedFilename(*meta, accountKey, meta.OwnerUsername); name != "report.pdf" {
+		t.Errorf("filename = %q", name)
+	}
+
+	if _, err := fetchFileMeta(newHTTPClient(srv.URL, false, 10, false), session, testFileID2); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,227 @@
+// search.go - Encrypted keyword search over the user's files.
+//
+// Files are indexed under the words of their decrypted filename and folder.
+// Each word is turned into a blind-index token keyed by the account key
+// (crypto.SearchTokens) and only the tokens are sent to the server, which
+// answers a search with the IDs of files holding every query token. The hits
+// are then fetched through the metadata batch endpoint and decrypted here.
+// Indexing is opt-in: `upload --index` indexes new uploads and
+// `search --reindex` (re)indexes every current file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// maxSearchTerms matches the server's limit on tokens per search.
+const maxSearchTerms = 32
+
+// SearchResponse represents the server's answer to a search.
+type SearchResponse struct {
+	FileIDs      []string `json:"file_ids"`
+	IndexedFiles int      `json:"indexed_files"`
+}
+
+// indexFile stores the search tokens for a file's name and folder.
+func indexFile(client *HTTPClient, session *AuthSession, accountKey []byte, fileID, filename, folder string) error {
+	tokens, err := crypto.SearchTokens(accountKey, filename, folder)
+	if err != nil {
+		return err
+	}
+	_, err = client.makeRequestWithSession("PUT", "/api/files/"+fileID+"/search-tokens",
+		map[string]interface{}{"tokens": tokens}, session)
+	return err
+}
+
+// indexUploadedFile indexes a file right after its upload. A failure only
+// leaves the file out of search results, so it is reported as a warning.
+func indexUploadedFile(client *HTTPClient, session *AuthSession, accountKey []byte, fileID, filename, folder string) {
+	if err := indexFile(client, session, accountKey, fileID, filename, folder); err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %s: uploaded but not indexed for search: %v\n", filename, err)
+	}
+}
+
+// reindexFiles indexes every current file, returning how many were indexed.
+// Files whose name cannot be decrypted are skipped.
+func reindexFiles(client *HTTPClient, session *AuthSession, accountKey []byte) (int, error) {
+	files, err := fetchFileList(client, session)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch file list: %w", err)
+	}
+
+	indexed := 0
+	for _, f := range files {
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = session.Username
+		}
+		filename, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+			f.FileID, crypto.AADFieldFilename, owner)
+		if err != nil {
+			logVerbose("Skipping %s: cannot decrypt filename: %v", f.FileID, err)
+			continue
+		}
+		folder, err := decryptFileFolder(f, accountKey, owner)
+		if err != nil {
+			logVerbose("Indexing %s without its folder: %v", f.FileID, err)
+			folder = ""
+		}
+		if err := indexFile(client, session, accountKey, f.FileID, filename, folder); err != nil {
+			return indexed, fmt.Errorf("failed to index %s: %w", f.FileID, err)
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// searchFileIDs asks the server for the files carrying every token.
+func searchFileIDs(client *HTTPClient, session *AuthSession, tokens []string) (*SearchResponse, error) {
+	payload, err := json.Marshal(map[string]interface{}{"tokens": tokens})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", client.baseURL+"/api/files/search", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	var result SearchResponse
+	if err := decodeJSONResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// handleSearchCommand searches the user's files by keyword.
+func handleSearchCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	reindex := fs.Bool("reindex", false, "Index every current file before searching")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client search [--reindex] [--json] TERM...\n\n" +
+			"Find files whose name or folder contains every TERM (whole words, case-insensitive).\n" +
+			"The server stores keyed tokens for each word and never sees the words themselves,\n" +
+			"though it can tell which files share a word. Only indexed files are found: use\n" +
+			"'upload --index' for new files and --reindex to index everything you already have.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	keywords := crypto.SearchKeywords(fs.Args()...)
+	if len(keywords) == 0 && !*reindex {
+		return fmt.Errorf("at least one search term is required")
+	}
+	if len(keywords) > maxSearchTerms {
+		return fmt.Errorf("at most %d search terms are allowed", maxSearchTerms)
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	if *reindex {
+		n, err := reindexFiles(client, session, accountKey)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Indexed %d files.\n", n)
+		if len(keywords) == 0 {
+			return nil
+		}
+	}
+
+	tokens, err := crypto.SearchTokens(accountKey, strings.Join(keywords, " "))
+	if err != nil {
+		return err
+	}
+	result, err := searchFileIDs(client, session, tokens)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	metadata, err := fetchMetadataBatch(client, session, result.FileIDs)
+	if err != nil {
+		return err
+	}
+
+	type SearchHit struct {
+		FileID     string `json:"file_id"`
+		Folder     string `json:"folder"`
+		Filename   string `json:"filename"`
+		SizeBytes  int64  `json:"size_bytes"`
+		UploadDate string `json:"upload_date"`
+	}
+	hits := make([]SearchHit, 0, len(result.FileIDs))
+	for _, fileID := range result.FileIDs {
+		f, ok := metadata[fileID]
+		if !ok {
+			// Deleted between the search and the metadata request
+			continue
+		}
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = session.Username
+		}
+		hits = append(hits, SearchHit{
+			FileID:     f.FileID,
+			Folder:     displayFolder(f, accountKey, owner),
+			Filename:   decryptedFilename(f, accountKey, owner),
+			SizeBytes:  f.SizeBytes,
+			UploadDate: f.UploadDate,
+		})
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hits)
+	}
+
+	if len(hits) == 0 {
+		if result.IndexedFiles == 0 {
+			fmt.Println("No files are indexed yet. Run 'arkfile-client search --reindex' to index them.")
+		} else {
+			fmt.Printf("No matching files (%d files indexed).\n", result.IndexedFiles)
+		}
+		return nil
+	}
+
+	for _, h := range hits {
+		name := h.Filename
+		if h.Folder != "" {
+			name = h.Folder + "/" + name
+		}
+		fmt.Printf("%s  %10s  %s  %s\n", h.FileID, formatFileSize(h.SizeBytes), h.UploadDate, name)
+	}
+	fmt.Printf("%d matching files.\n", len(hits))
+	return nil
+}
@@ -0,0 +1,72 @@
+// search_test.go - Unit tests for the encrypted search index requests.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestIndexFile_SendsBlindTokens(t *testing.T) {
+	accountKey := make([]byte, 32)
+	var got []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/files/"+testFileID+"/search-tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Tokens []string `json:"tokens"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got = body.Tokens
+		w.Write([]byte(`{"success":true,"message":"Search index updated"}`))
+	}))
+	defer srv.Close()
+
+	err := indexFile(newHTTPClient(srv.URL, false, 10, false), newTestSession("tok", "ref", 30*time.Minute),
+		accountKey, testFileID, "Tax-Return.pdf", "finance/2025")
+	if err != nil {
+		t.Fatalf("indexFile: %v", err)
+	}
+
+	want, _ := crypto.SearchTokens(accountKey, "tax return pdf finance 2025")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens = %v, want %v", got, want)
+	}
+	for _, tok := range got {
+		if tok == "tax" || !crypto.IsValidSearchToken(tok) {
+			t.Errorf("token %q is not a blind-index token", tok)
+		}
+	}
+}
+
+func TestSearchFileIDs_DecodesResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/files/search" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"file_ids":["` + testFileID + `","` + testFileID2 + `"],"indexed_files":7}`))
+	}))
+	defer srv.Close()
+
+	result, err := searchFileIDs(newHTTPClient(srv.URL, false, 10, false), newTestSession("tok", "ref", 30*time.Minute),
+		[]string{"0123456789abcdef0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("searchFileIDs: %v", err)
+	}
+	if !reflect.DeepEqual(result.FileIDs, []string{testFileID, testFileID2}) || result.IndexedFiles != 7 {
+		t.Errorf("result = %+v", result)
+	}
+}
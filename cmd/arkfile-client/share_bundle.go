@@ -0,0 +1,346 @@
+// share_bundle.go - Share several files, or a whole folder, behind one share
+// link and password.
+//
+// The bundle's Share Envelope lists the FEK, name, size and SHA-256 of every
+// file and is encrypted once with the share password, bound to the share ID
+// and the first file. Entry names are paths relative to the shared folder,
+// so `share download --all` can recreate the folder structure.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// maxShareBundleFiles mirrors the server's limit on files per bundle.
+const maxShareBundleFiles = 100
+
+// shareBundleSource is a file selected for a bundle with its entry name.
+type shareBundleSource struct {
+	Meta   ServerFileInfo
+	Name   string
+	SHA256 string
+}
+
+// selectBundleFilesByID returns the given files in order, named by their
+// decrypted filenames. Every ID must be one of the user's files.
+func selectBundleFilesByID(files []ServerFileInfo, fileIDs []string, accountKey []byte, username string) ([]shareBundleSource, error) {
+	byID := make(map[string]ServerFileInfo, len(files))
+	for _, f := range files {
+		byID[f.FileID] = f
+	}
+
+	sources := make([]shareBundleSource, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		f, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("file %s not found", id)
+		}
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = username
+		}
+		name, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+			f.FileID, crypto.AADFieldFilename, owner)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: filename could not be decrypted", id)
+		}
+		sha, err := decryptMetadataField(f.EncryptedSHA256, f.SHA256Nonce, accountKey,
+			f.FileID, crypto.AADFieldSha256, owner)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: SHA-256 could not be decrypted", id)
+		}
+		sources = append(sources, shareBundleSource{Meta: f, Name: name, SHA256: sha})
+	}
+	return sources, nil
+}
+
+// selectBundleFilesByFolder returns every file in folder base (and below
+// it), named by its path relative to base.
+func selectBundleFilesByFolder(files []ServerFileInfo, base string, accountKey []byte, username string) ([]shareBundleSource, error) {
+	selected, skipped := selectFolderDownloads(files, accountKey, username, base, "")
+	if len(skipped) > 0 {
+		return nil, fmt.Errorf("cannot share folder %q: %s", base, strings.Join(skipped, "; "))
+	}
+	sources := make([]shareBundleSource, 0, len(selected))
+	for _, d := range selected {
+		sources = append(sources, shareBundleSource{Meta: d.Meta, Name: filepath.ToSlash(d.Dest), SHA256: d.SHA256})
+	}
+	return sources, nil
+}
+
+// buildShareBundleEntries unwraps the FEK of every source and returns the
+// bundle envelope entries. Entry names must be unique. Custom-password files
+// share one prompted password.
+func buildShareBundleEntries(sources []shareBundleSource, accountKey []byte, username string) ([]crypto.ShareBundleEntry, error) {
+	var customKEK []byte
+	defer func() { clearBytes(customKEK) }()
+
+	names := make(map[string]string, len(sources))
+	entries := make([]crypto.ShareBundleEntry, 0, len(sources))
+	for _, src := range sources {
+		if other, taken := names[src.Name]; taken {
+			return nil, fmt.Errorf("files %s and %s are both named %q", other, src.Meta.FileID, src.Name)
+		}
+		names[src.Name] = src.Meta.FileID
+
+		kek := accountKey
+		switch src.Meta.PasswordType {
+		case "account", "":
+		case "custom":
+			if customKEK == nil {
+				customPass, err := readPassword("Enter custom password for custom-password files in this share: ")
+				if err != nil {
+					return nil, fmt.Errorf("failed to read custom password: %w", err)
+				}
+				customKEK = crypto.DeriveCustomPasswordKey(customPass, username)
+				clearBytes(customPass)
+			}
+			kek = customKEK
+		default:
+			return nil, fmt.Errorf("%s: unsupported password type: %s", src.Name, src.Meta.PasswordType)
+		}
+
+		fek, _, err := unwrapFEK(src.Meta.EncryptedFEK, kek, src.Meta.FileID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to unwrap FEK (wrong password?): %w", src.Name, err)
+		}
+		entries = append(entries, crypto.ShareBundleEntry{
+			FileID:    src.Meta.FileID,
+			FEK:       encodeBase64(fek),
+			Filename:  src.Name,
+			SizeBytes: src.Meta.SizeBytes,
+			SHA256:    src.SHA256,
+		})
+		clearBytes(fek)
+	}
+	return entries, nil
+}
+
+// createShareBundle shares several files, given by ID or as a folder, with
+// one link and password.
+func createShareBundle(client *HTTPClient, config *ClientConfig, session *AuthSession, accountKey []byte, fileIDs []string, folder string, expiresMinutes, maxDownloads int) error {
+	files, err := fetchFileList(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch file list: %w", err)
+	}
+
+	var sources []shareBundleSource
+	if folder != "" {
+		base, err := cleanFolderPath(folder)
+		if err != nil {
+			return fmt.Errorf("invalid --folder: %w", err)
+		}
+		if sources, err = selectBundleFilesByFolder(files, base, accountKey, session.Username); err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return fmt.Errorf("no files found in folder %q", base)
+		}
+	} else if sources, err = selectBundleFilesByID(files, fileIDs, accountKey, session.Username); err != nil {
+		return err
+	}
+	if len(sources) > maxShareBundleFiles {
+		return fmt.Errorf("a share bundle can hold at most %d files (selected %d)", maxShareBundleFiles, len(sources))
+	}
+
+	entries, err := buildShareBundleEntries(sources, accountKey, config.Username)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for i := range entries {
+			entries[i].FEK = ""
+		}
+	}()
+
+	shareID, err := newClientShareID()
+	if err != nil {
+		return err
+	}
+	downloadToken, err := crypto.GenerateDownloadToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate download token: %w", err)
+	}
+
+	envelopeJSON, err := crypto.CreateShareBundleEnvelope(downloadToken, entries)
+	if err != nil {
+		return fmt.Errorf("failed to create share envelope: %w", err)
+	}
+	defer clearBytes(envelopeJSON)
+
+	// The envelope is bound to the first file of the bundle
+	sharePayload, err := sealShareEnvelope(envelopeJSON, downloadToken, shareID, entries[0].FileID, expiresMinutes, maxDownloads)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(entries))
+	var total int64
+	for i, e := range entries {
+		ids[i] = e.FileID
+		total += e.SizeBytes
+	}
+	sharePayload["file_ids"] = ids
+
+	shareURL, err := postShare(client, session, sharePayload)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Share bundle created!\n")
+	fmt.Printf("  Files: %d (%s)\n", len(entries), formatFileSize(total))
+	for _, e := range entries {
+		fmt.Printf("    %s\n", e.Filename)
+	}
+	printShareCreated(shareID, shareURL, expiresMinutes)
+	return nil
+}
+
+// bundleEntryPath maps a bundle entry name to a path below outDir. Names are
+// a slash-separated folder path and a filename; anything cleanFolderPath or
+// isPlainFileName would reject is refused, so a crafted envelope cannot
+// write outside outDir.
+func bundleEntryPath(outDir, name string) (string, error) {
+	dir, file := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	clean, err := cleanFolderPath(dir)
+	if err != nil || clean != dir || strings.HasPrefix(name, "/") || !isPlainFileName(file) {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+	return filepath.Join(outDir, filepath.FromSlash(dir), file), nil
+}
+
+// printShareBundle lists the files of a bundle and whether they can still be
+// downloaded.
+func printShareBundle(data *shareEnvelopeResponse, envelope *crypto.ShareEnvelope) {
+	available := make(map[string]bool, len(data.Files))
+	for _, f := range data.Files {
+		available[f.FileID] = f.Available
+	}
+
+	var total int64
+	for _, e := range envelope.Files {
+		total += e.SizeBytes
+	}
+	fmt.Printf("Share bundle: %d files, %s\n", len(envelope.Files), formatFileSize(total))
+	for _, e := range envelope.Files {
+		status := ""
+		if !available[e.FileID] {
+			status = "  [unavailable]"
+		}
+		fmt.Printf("  %-40s  %10s  %s%s\n", e.Filename, formatFileSize(e.SizeBytes), e.FileID, status)
+	}
+	fmt.Printf("\nUse --all to download every file, or --file-id to download one.\n")
+}
+
+// downloadShareBundle lists a bundle, or downloads one file (fileID) or every
+// file (all) of it. With --all, outputPath is the target directory and the
+// bundle's folder structure is recreated below it; existing files are never
+// overwritten.
+func downloadShareBundle(client *HTTPClient, shareID string, data *shareEnvelopeResponse, envelope *crypto.ShareEnvelope, downloadTokenB64, fileID, outputPath string, all bool) error {
+	if !all && fileID == "" {
+		printShareBundle(data, envelope)
+		return nil
+	}
+
+	available := make(map[string]bool, len(data.Files))
+	for _, f := range data.Files {
+		available[f.FileID] = f.Available
+	}
+
+	if fileID != "" {
+		for _, e := range envelope.Files {
+			if e.FileID != fileID {
+				continue
+			}
+			if outputPath == "" {
+				outputPath = path.Base(e.Filename)
+				if !isPlainFileName(outputPath) || outputPath == "." || outputPath == ".." {
+					return fmt.Errorf("unsafe file name %q; use --output", e.Filename)
+				}
+			}
+			fmt.Printf("Downloading %s...\n", e.Filename)
+			if err := downloadBundleEntry(client, shareID, e, downloadTokenB64, outputPath, false); err != nil {
+				return err
+			}
+			fmt.Printf("[OK] %s -> %s\n", e.Filename, outputPath)
+			return nil
+		}
+		return fmt.Errorf("file %s is not part of this share", fileID)
+	}
+
+	outDir := outputPath
+	if outDir == "" {
+		outDir = "."
+	}
+
+	downloaded, failed, skipped := 0, 0, 0
+	for _, e := range envelope.Files {
+		dest, err := bundleEntryPath(outDir, e.Filename)
+		if err != nil {
+			fmt.Printf("[SKIP] %s: %v\n", e.FileID, err)
+			skipped++
+			continue
+		}
+		if !available[e.FileID] {
+			fmt.Printf("[SKIP] %s: no longer available\n", e.Filename)
+			skipped++
+			continue
+		}
+		if _, err := os.Lstat(dest); err == nil {
+			fmt.Printf("[SKIP] %s: %s already exists\n", e.Filename, dest)
+			skipped++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			fmt.Printf("[X] %s: failed to create directory: %v\n", e.Filename, err)
+			failed++
+			continue
+		}
+		if err := downloadBundleEntry(client, shareID, e, downloadTokenB64, dest, true); err != nil {
+			fmt.Printf("[X] %s: %v\n", e.Filename, err)
+			failed++
+			continue
+		}
+		downloaded++
+		fmt.Printf("[OK] %s -> %s\n", e.Filename, dest)
+	}
+
+	fmt.Printf("\nDownloaded: %d. Failed: %d. Skipped: %d.\n", downloaded, failed, skipped)
+	if failed > 0 || skipped > 0 {
+		return fmt.Errorf("bundle download finished with %d failed and %d skipped (downloaded %d)", failed, skipped, downloaded)
+	}
+	return nil
+}
+
+// downloadBundleEntry downloads one bundle file and verifies its SHA-256.
+// A file that fails verification is removed.
+func downloadBundleEntry(client *HTTPClient, shareID string, e crypto.ShareBundleEntry, downloadTokenB64, dest string, exclusive bool) error {
+	fek, err := decodeBase64(e.FEK)
+	if err != nil {
+		return fmt.Errorf("failed to decode FEK from envelope: %w", err)
+	}
+	defer clearBytes(fek)
+
+	if _, err := downloadShareFile(client, shareID, e.FileID, fek, downloadTokenB64, dest, exclusive, false); err != nil {
+		return err
+	}
+
+	if e.SHA256 != "" {
+		actualSHA256, err := computeStreamingSHA256(dest)
+		if err != nil {
+			return fmt.Errorf("could not compute SHA-256 for verification: %w", err)
+		}
+		if actualSHA256 != e.SHA256 {
+			os.Remove(dest)
+			return fmt.Errorf("SHA-256 mismatch (expected %s, got %s); file may be corrupt or tampered", e.SHA256, actualSHA256)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// share_bundle_test.go - Unit tests for share bundles.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestBundleEntryPath(t *testing.T) {
+	out := t.TempDir()
+	for name, want := range map[string]string{
+		"a.txt":            "a.txt",
+		"docs/2026/q1.pdf": "docs/2026/q1.pdf",
+	} {
+		got, err := bundleEntryPath(out, name)
+		if err != nil || got != filepath.Join(out, filepath.FromSlash(want)) {
+			t.Errorf("bundleEntryPath(%q) = %q, %v", name, got, err)
+		}
+	}
+	for _, name := range []string{"", "..", "../x", "a/../../x", "/etc/passwd", "a//b", "./a", "a\\b", "dir/"} {
+		if got, err := bundleEntryPath(out, name); err == nil {
+			t.Errorf("bundleEntryPath(%q) = %q, want an error", name, got)
+		}
+	}
+}
+
+func TestBuildShareBundleEntries(t *testing.T) {
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	feks := map[string][]byte{}
+	file := func(id, folder, name string) ServerFileInfo {
+		t.Helper()
+		encFn, fnNonce, encSHA, shaNonce, err := encryptMetadata(name, "sha-"+id, accountKey, id, testOwner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := ServerFileInfo{FileID: id, EncryptedFilename: encFn, FilenameNonce: fnNonce,
+			EncryptedSHA256: encSHA, SHA256Nonce: shaNonce, PasswordType: "account", SizeBytes: 10}
+		if folder != "" {
+			if f.EncryptedFolder, f.FolderNonce, err = encryptFolderPath(folder, accountKey, id, testOwner); err != nil {
+				t.Fatal(err)
+			}
+		}
+		feks[id] = bytes.Repeat([]byte{byte(len(feks) + 1)}, 32)
+		if f.EncryptedFEK, err = wrapFEK(feks[id], accountKey, "account", id); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	files := []ServerFileInfo{
+		file("id-1", "docs", "cv.pdf"),
+		file("id-2", "docs/2026", "q1.xlsx"),
+		file("id-3", "other", "cv.pdf"),
+	}
+
+	// A folder bundle names entries relative to the folder
+	sources, err := selectBundleFilesByFolder(files, "docs", accountKey, testOwner)
+	if err != nil {
+		t.Fatalf("selectBundleFilesByFolder: %v", err)
+	}
+	entries, err := buildShareBundleEntries(sources, accountKey, testOwner)
+	if err != nil {
+		t.Fatalf("buildShareBundleEntries: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Filename+"="+e.FileID)
+		fek, err := decodeBase64(e.FEK)
+		if err != nil || !bytes.Equal(fek, feks[e.FileID]) {
+			t.Errorf("entry %s carries the wrong FEK", e.FileID)
+		}
+		if e.SHA256 != "sha-"+e.FileID || e.SizeBytes != 10 {
+			t.Errorf("entry %s metadata = %+v", e.FileID, e)
+		}
+	}
+	if got, want := strings.Join(names, " "), "2026/q1.xlsx=id-2 cv.pdf=id-1"; got != want {
+		t.Errorf("entries %s, want %s", got, want)
+	}
+
+	// Files picked by ID keep the given order; equal names are refused
+	sources, err = selectBundleFilesByID(files, []string{"id-3", "id-2"}, accountKey, testOwner)
+	if err != nil || sources[0].Name != "cv.pdf" || sources[1].Name != "q1.xlsx" {
+		t.Fatalf("selectBundleFilesByID = %+v, %v", sources, err)
+	}
+	sources, _ = selectBundleFilesByID(files, []string{"id-1", "id-3"}, accountKey, testOwner)
+	if _, err := buildShareBundleEntries(sources, accountKey, testOwner); err == nil {
+		t.Error("expected an error for two files with the same name")
+	}
+	if _, err := selectBundleFilesByID(files, []string{"id-1", "missing"}, accountKey, testOwner); err == nil {
+		t.Error("expected an error for an unknown file ID")
+	}
+}
+
+func TestDownloadShareBundle_All(t *testing.T) {
+	const shareID = "bundle-share"
+	const token = "dG9rZW4="
+	fek := bytes.Repeat([]byte{0x11}, 32)
+	contents := map[string][]byte{"id-a": []byte("alpha"), "id-b": []byte("bravo")}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileID := r.URL.Query().Get("file_id")
+		plaintext, ok := contents[fileID]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		enc, err := encryptChunk(plaintext, fek, fileID, 0, 1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/public/shares/" + shareID + "/metadata":
+			fmt.Fprintf(w, `{"file_id":%q,"size_bytes":%d,"chunk_count":1}`, fileID, len(enc))
+		case "/api/public/shares/" + shareID + "/chunks/0":
+			if r.Header.Get("X-Download-Token") != token {
+				http.Error(w, "bad token", http.StatusForbidden)
+				return
+			}
+			w.Write(enc)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	digest := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+	fekB64 := encodeBase64(fek)
+	envelope := &crypto.ShareEnvelope{Files: []crypto.ShareBundleEntry{
+		{FileID: "id-a", FEK: fekB64, Filename: "a.txt", SHA256: digest(contents["id-a"])},
+		{FileID: "id-b", FEK: fekB64, Filename: "sub/b.txt", SHA256: digest(contents["id-b"])},
+		{FileID: "id-c", FEK: fekB64, Filename: "c.txt"},
+		{FileID: "id-d", FEK: fekB64, Filename: "../evil.txt"},
+	}}
+	data := &shareEnvelopeResponse{Files: []shareEnvelopeFile{
+		{FileID: "id-a", Available: true},
+		{FileID: "id-b", Available: true},
+	}}
+
+	out := filepath.Join(t.TempDir(), "out")
+	client := newHTTPClient(srv.URL, false, 10, false)
+	err := downloadShareBundle(client, shareID, data, envelope, token, "", out, true)
+	if err == nil || !strings.Contains(err.Error(), "2 skipped") {
+		t.Fatalf("expected the unavailable and unsafe entries to be skipped, got %v", err)
+	}
+	for name, want := range map[string]string{"a.txt": "alpha", "sub/b.txt": "bravo"} {
+		got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v", name, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(out), "evil.txt")); !os.IsNotExist(err) {
+		t.Error("an unsafe entry name escaped the output directory")
+	}
+
+	// A second run never overwrites what is already there
+	if err := os.WriteFile(filepath.Join(out, "a.txt"), []byte("local"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	downloadShareBundle(client, shareID, data, envelope, token, "", out, true)
+	if got, _ := os.ReadFile(filepath.Join(out, "a.txt")); string(got) != "local" {
+		t.Errorf("existing file was overwritten: %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+// share_log.go - Download history of an anonymous share.
+//
+// `share log` lists every download of a share: when it started, the
+// privacy-preserving entity ID of the downloader (the server never records
+// IP addresses), which chunks were served and whether the download finished.
+// Entity IDs of anonymous downloaders rotate daily, so the same recipient
+// may appear under different IDs on different days.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// shareAccessEntry is one download of GET /api/shares/:id/accesses.
+type shareAccessEntry struct {
+	AccessID     int64      `json:"access_id"`
+	FileID       string     `json:"file_id"`
+	EntityID     string     `json:"entity_id"`
+	StartedAt    string     `json:"started_at"`
+	LastChunkAt  *string    `json:"last_chunk_at"`
+	CompletedAt  *string    `json:"completed_at"`
+	Completed    bool       `json:"completed"`
+	ChunkCount   int64      `json:"chunk_count"`
+	ChunksServed int64      `json:"chunks_served"`
+	ChunkRanges  [][2]int64 `json:"chunk_ranges"`
+}
+
+// shareAccessLog is the response of GET /api/shares/:id/accesses.
+type shareAccessLog struct {
+	ShareID     string             `json:"share_id"`
+	AccessCount int64              `json:"access_count"`
+	Accesses    []shareAccessEntry `json:"accesses"`
+	Limit       int                `json:"limit"`
+	Offset      int                `json:"offset"`
+	Returned    int                `json:"returned"`
+	HasMore     bool               `json:"has_more"`
+}
+
+// fetchShareAccesses returns one page of a share's download history.
+func fetchShareAccesses(client *HTTPClient, session *AuthSession, shareID string, limit, offset int) (*shareAccessLog, error) {
+	var log shareAccessLog
+	endpoint := fmt.Sprintf("/api/shares/%s/accesses?limit=%d&offset=%d", shareID, limit, offset)
+	if err := ownerJSONRequest(client, session, "GET", endpoint, nil, &log); err != nil {
+		return nil, fmt.Errorf("failed to fetch share log: %w", err)
+	}
+	return &log, nil
+}
+
+// formatChunkRanges renders inclusive chunk ranges as "0-41, 45".
+func formatChunkRanges(ranges [][2]int64) string {
+	if len(ranges) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r[0] == r[1] {
+			parts = append(parts, fmt.Sprintf("%d", r[0]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r[0], r[1]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func handleShareLog(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share log", flag.ExitOnError)
+	shareID := fs.String("share-id", "", "Share ID to show the download history of")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	limit := fs.Int("limit", 100, "Maximum number of downloads to list")
+	offset := fs.Int("offset", 0, "Offset for pagination")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client share log --share-id SHARE_ID [--json]\n\n" +
+			"Show every download of a share: when it started, the downloader's entity ID,\n" +
+			"the chunks served and whether the download finished.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shareID == "" {
+		return fmt.Errorf("--share-id is required")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	log, err := fetchShareAccesses(client, session, *shareID, *limit, *offset)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(log)
+	}
+	if len(log.Accesses) == 0 {
+		fmt.Println("This share has not been downloaded.")
+		return nil
+	}
+
+	sep := strings.Repeat("-", 80)
+	for _, a := range log.Accesses {
+		fmt.Println(sep)
+		fmt.Printf("  Started:   %s\n", a.StartedAt)
+		fmt.Printf("  Entity ID: %s\n", a.EntityID)
+		fmt.Printf("  File ID:   %s\n", a.FileID)
+		fmt.Printf("  Chunks:    %d of %d served (%s)\n", a.ChunksServed, a.ChunkCount, formatChunkRanges(a.ChunkRanges))
+		if a.Completed && a.CompletedAt != nil {
+			fmt.Printf("  Finished:  yes, %s\n", *a.CompletedAt)
+		} else if a.LastChunkAt != nil {
+			fmt.Printf("  Finished:  no (last chunk %s)\n", *a.LastChunkAt)
+		} else {
+			fmt.Printf("  Finished:  no\n")
+		}
+	}
+	fmt.Println(sep)
+
+	if log.HasMore {
+		fmt.Printf("\nShowing %d downloads starting at offset %d. More results available.\n", log.Returned, log.Offset)
+	} else {
+		fmt.Printf("\nTotal shown: %d downloads\n", log.Returned)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// share_log_test.go - Unit tests for the share download history.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFormatChunkRanges(t *testing.T) {
+	cases := []struct {
+		ranges [][2]int64
+		want   string
+	}{
+		{nil, "none"},
+		{[][2]int64{{0, 0}}, "0"},
+		{[][2]int64{{0, 41}}, "0-41"},
+		{[][2]int64{{0, 3}, {5, 5}, {7, 9}}, "0-3, 5, 7-9"},
+	}
+	for _, tc := range cases {
+		if got := formatChunkRanges(tc.ranges); got != tc.want {
+			t.Errorf("formatChunkRanges(%v) = %q, want %q", tc.ranges, got, tc.want)
+		}
+	}
+}
+
+func TestFetchShareAccesses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/shares/share-1/accesses" || r.URL.Query().Get("limit") != "10" ||
+			r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"share_id":"share-1","access_count":1,"returned":1,"accesses":[
+			{"access_id":3,"file_id":"f1","entity_id":"e1","started_at":"2026-10-16T10:00:00Z",
+			 "completed":true,"completed_at":"2026-10-16T10:01:00Z","chunk_count":3,"chunks_served":3,
+			 "chunk_ranges":[[0,2]]}]}`))
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	log, err := fetchShareAccesses(client, newTestSession("tok", "ref", time.Hour), "share-1", 10, 0)
+	if err != nil {
+		t.Fatalf("fetchShareAccesses: %v", err)
+	}
+	if len(log.Accesses) != 1 {
+		t.Fatalf("got %d accesses", len(log.Accesses))
+	}
+	a := log.Accesses[0]
+	if !a.Completed || a.EntityID != "e1" || a.ChunksServed != 3 || formatChunkRanges(a.ChunkRanges) != "0-2" {
+		t.Errorf("unexpected access %+v", a)
+	}
+
+	if _, err := fetchShareAccesses(client, newTestSession("other", "ref", time.Hour), "share-1", 10, 0); err == nil {
+		t.Error("expected an error for a rejected request")
+	}
+}
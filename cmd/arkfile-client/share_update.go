@@ -0,0 +1,131 @@
+// share_update.go - Changing the policy of an existing anonymous share.
+//
+// `share update` extends or shortens a share's expiry, changes its download
+// limit, or suspends and resumes it, so the owner does not have to revoke
+// the link and issue a new one. A share that expired or ran out of downloads
+// becomes active again once the update lifts that limit; revoked shares stay
+// revoked.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// shareUpdatePayload builds the PATCH /api/shares/:id body from the flags of
+// `share update` that were set.
+func shareUpdatePayload(set map[string]bool, expires, extend, shorten string, maxDownloads int, suspend, resume bool) (map[string]interface{}, error) {
+	payload := map[string]interface{}{}
+
+	expiryFlags := 0
+	for _, name := range []string{"expires", "extend", "shorten"} {
+		if set[name] {
+			expiryFlags++
+		}
+	}
+	if expiryFlags > 1 {
+		return nil, fmt.Errorf("use only one of --expires, --extend and --shorten")
+	}
+	switch {
+	case set["expires"]:
+		minutes, err := parseDuration(expires)
+		if err != nil {
+			return nil, err
+		}
+		payload["expires_after_minutes"] = minutes
+	case set["extend"], set["shorten"]:
+		value, sign := extend, 1
+		if set["shorten"] {
+			value, sign = shorten, -1
+		}
+		minutes, err := parseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		if minutes == 0 {
+			return nil, fmt.Errorf("--extend and --shorten need a non-zero duration")
+		}
+		payload["extend_minutes"] = sign * minutes
+	}
+
+	if set["max-downloads"] {
+		if maxDownloads < 0 {
+			return nil, fmt.Errorf("--max-downloads must not be negative")
+		}
+		payload["max_accesses"] = maxDownloads
+	}
+
+	if suspend && resume {
+		return nil, fmt.Errorf("use either --suspend or --resume, not both")
+	}
+	if suspend || resume {
+		payload["suspended"] = suspend
+	}
+
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("nothing to update (use --expires, --extend, --shorten, --max-downloads, --suspend or --resume)")
+	}
+	return payload, nil
+}
+
+func handleShareUpdate(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share update", flag.ExitOnError)
+	shareID := fs.String("share-id", "", "Share ID to update")
+	expires := fs.String("expires", "", "New expiry counted from now (e.g. 2m, 24h, 7d; 0 = no expiry)")
+	extend := fs.String("extend", "", "Move the current expiry later by this long (e.g. 1d)")
+	shorten := fs.String("shorten", "", "Move the current expiry earlier by this long (e.g. 12h)")
+	maxDownloads := fs.Int("max-downloads", 0, "New maximum download count (0 = unlimited; per file for bundles)")
+	suspend := fs.Bool("suspend", false, "Pause the share without revoking it")
+	resume := fs.Bool("resume", false, "Resume a suspended share")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shareID == "" {
+		return fmt.Errorf("--share-id is required")
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	payload, err := shareUpdatePayload(set, *expires, *extend, *shorten, *maxDownloads, *suspend, *resume)
+	if err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		ExpiresAt   *string `json:"expires_at"`
+		MaxAccesses *int    `json:"max_accesses"`
+		AccessCount int     `json:"access_count"`
+		Suspended   bool    `json:"suspended"`
+		IsActive    bool    `json:"is_active"`
+	}
+	if err := ownerJSONRequest(client, session, "PATCH", "/api/shares/"+*shareID, payload, &result); err != nil {
+		return fmt.Errorf("failed to update share: %w", err)
+	}
+
+	fmt.Printf("Share %s updated\n", *shareID)
+	expiry := "never"
+	if result.ExpiresAt != nil {
+		expiry = *result.ExpiresAt
+	}
+	fmt.Printf("  Expires:   %s\n", expiry)
+	downloads := fmt.Sprintf("%d", result.AccessCount)
+	if result.MaxAccesses != nil {
+		downloads = fmt.Sprintf("%d / %d", result.AccessCount, *result.MaxAccesses)
+	}
+	fmt.Printf("  Downloads: %s\n", downloads)
+	if result.Suspended {
+		fmt.Printf("  Suspended: yes\n")
+	}
+	active := "yes"
+	if !result.IsActive {
+		active = "no"
+	}
+	fmt.Printf("  Active:    %s\n", active)
+	return nil
+}
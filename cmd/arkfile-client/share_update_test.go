@@ -0,0 +1,71 @@
+// share_update_test.go - Unit tests for changing a share's policy.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShareUpdatePayload(t *testing.T) {
+	flags := func(names ...string) map[string]bool {
+		set := map[string]bool{}
+		for _, name := range names {
+			set[name] = true
+		}
+		return set
+	}
+
+	cases := []struct {
+		name    string
+		set     map[string]bool
+		expires string
+		extend  string
+		shorten string
+		max     int
+		suspend bool
+		resume  bool
+		want    map[string]interface{}
+	}{
+		{name: "extend", set: flags("extend"), extend: "1d",
+			want: map[string]interface{}{"extend_minutes": 1440}},
+		{name: "shorten", set: flags("shorten"), shorten: "12h",
+			want: map[string]interface{}{"extend_minutes": -720}},
+		{name: "no expiry", set: flags("expires"), expires: "0",
+			want: map[string]interface{}{"expires_after_minutes": 0}},
+		{name: "unlimited and resume", set: flags("max-downloads", "resume"), resume: true,
+			want: map[string]interface{}{"max_accesses": 0, "suspended": false}},
+		{name: "suspend", set: flags("suspend"), suspend: true,
+			want: map[string]interface{}{"suspended": true}},
+	}
+	for _, tc := range cases {
+		got, err := shareUpdatePayload(tc.set, tc.expires, tc.extend, tc.shorten, tc.max, tc.suspend, tc.resume)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	bad := []struct {
+		name string
+		set  map[string]bool
+	}{
+		{"nothing", flags()},
+		{"two expiry flags", flags("expires", "extend")},
+		{"zero extension", flags("extend")},
+	}
+	for _, tc := range bad {
+		if _, err := shareUpdatePayload(tc.set, "7d", "0", "", 0, false, false); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+	if _, err := shareUpdatePayload(flags("suspend", "resume"), "", "", "", 0, true, true); err == nil {
+		t.Error("expected an error for --suspend with --resume")
+	}
+	if _, err := shareUpdatePayload(flags("max-downloads"), "", "", "", -1, false, false); err == nil {
+		t.Error("expected an error for a negative download limit")
+	}
+}
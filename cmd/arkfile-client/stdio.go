@@ -0,0 +1,230 @@
+// stdio.go - Streaming uploads from stdin and downloads to stdout.
+//
+// `arkfile-client upload - --name NAME` reads a file from a pipe. Every
+// chunk's AAD binds the total chunk count, and the upload session is
+// created with the total size and the encrypted SHA-256, so all three must
+// be known before the first chunk is encrypted. A pipe cannot be read
+// twice, so the input is spooled once to a private temporary file while it
+// is hashed, uploaded from there and deleted. The spool is encrypted with
+// AES-256-CTR under a random key that only this process holds, so a spool
+// left behind by a crash or SIGKILL is unreadable. It still takes scratch
+// disk as large as the whole input; see --spool-dir.
+//
+// `arkfile-client download --output -` writes each decrypted chunk to
+// stdout as soon as its AES-GCM tag has been verified, so nothing is
+// written to disk. The whole-file SHA-256 is checked once the last chunk
+// has been written; a mismatch is reported on stderr and the command exits
+// non-zero.
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// stdinPath is the upload/download path argument meaning stdin or stdout.
+const stdinPath = "-"
+
+// spoolCipher encrypts the stdin spool. The key is random per run and never
+// written anywhere. CTR mode keeps the spool the same size as the input and
+// lets any byte range be decrypted on its own, which the parallel chunk
+// readers need.
+type spoolCipher struct {
+	block cipher.Block
+	iv    [aes.BlockSize]byte
+}
+
+// newSpoolCipher returns a spoolCipher with a fresh random key and IV.
+func newSpoolCipher() (*spoolCipher, error) {
+	key := make([]byte, 32)
+	defer clearBytes(key)
+	sc := &spoolCipher{}
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate spool key: %w", err)
+	}
+	if _, err := rand.Read(sc.iv[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate spool IV: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	sc.block = block
+	return sc, nil
+}
+
+// streamAt returns the keystream positioned at byte offset off.
+func (sc *spoolCipher) streamAt(off int64) cipher.Stream {
+	ctr := sc.iv
+	carry := uint64(off / aes.BlockSize)
+	for i := len(ctr) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(ctr[i]) + carry&0xff
+		ctr[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	stream := cipher.NewCTR(sc.block, ctr[:])
+	if skip := off % aes.BlockSize; skip > 0 {
+		pad := make([]byte, skip)
+		stream.XORKeyStream(pad, pad)
+	}
+	return stream
+}
+
+// open returns a reader that decrypts the spool at path.
+func (sc *spoolCipher) open(path string) (chunkSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &spoolReader{File: f, sc: sc}, nil
+}
+
+// spoolReader decrypts the spool file on every ReadAt.
+type spoolReader struct {
+	*os.File
+	sc *spoolCipher
+}
+
+func (r *spoolReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.File.ReadAt(p, off)
+	r.sc.streamAt(off).XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// spoolInput encrypts r into the spool file f with sc while hashing the
+// plaintext, and closes f.
+func spoolInput(r io.Reader, f *os.File, sc *spoolCipher) (sha256hex string, size int64, err error) {
+	h := sha256.New()
+	w := cipher.StreamWriter{S: sc.streamAt(0), W: f}
+	size, err = io.Copy(io.MultiWriter(w, h), r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to spool input: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// uploadFromStdin spools stdin into spoolDir (the system temp directory
+// when "") and uploads it as filename in folder, with the account password.
+// The spool is encrypted under a key held only in memory (see spoolCipher).
+// A non-empty versionOf uploads it as the next version of that file, in
+// that file's folder when inheritFolder is set. The spool file (mode 0600)
+// is removed afterwards, and also when the command is interrupted. With
+// index set the upload is added to the search index.
+func uploadFromStdin(client *HTTPClient, config *ClientConfig, filename, folder, spoolDir, versionOf string, inheritFolder, force, index bool, parallel int) error {
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	if inheritFolder {
+		if folder, err = versionTargetFolder(client, session, accountKey, versionOf); err != nil {
+			return err
+		}
+	}
+
+	sc, err := newSpoolCipher()
+	if err != nil {
+		return err
+	}
+	spool, err := os.CreateTemp(spoolDir, "arkfile-stdin-*.spool")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	spoolPath := spool.Name()
+	defer os.Remove(spoolPath)
+
+	// The default SIGINT handler would exit without removing the spool.
+	// An interrupted stdin upload cannot be resumed, so there is nothing
+	// to finish first.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			_ = os.Remove(spoolPath)
+			os.Exit(130)
+		}
+	}()
+
+	logVerbose("Reading %s from stdin into %s...", filename, spoolPath)
+	sha256hex, size, err := spoolInput(os.Stdin, spool, sc)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return fmt.Errorf("cannot upload empty input (0 bytes read from stdin)")
+	}
+	logVerbose("Read %s from stdin, SHA-256 %s", formatFileSize(size), sha256hex)
+
+	if !force {
+		if err := checkDuplicateDigest(client, session, accountKey, sha256hex); err != nil {
+			return err
+		}
+	}
+
+	fileID, err := uploadSourceFile(client, session, accountKey, accountKey, "account", "", uploadSource{
+		Path:      spoolPath,
+		Filename:  filename,
+		Folder:    folder,
+		SHA256:    sha256hex,
+		VersionOf: versionOf,
+		Open:      sc.open,
+	}, parallel)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[OK] %s (file_id=%s)\n", filename, fileID)
+	if index {
+		indexUploadedFile(client, session, accountKey, fileID, filename, folder)
+	}
+	return nil
+}
+
+// downloadToWriter streams a file's plaintext to out and then checks the
+// whole-file SHA-256. Each chunk reaches out only after its AES-GCM tag has
+// been verified, so out never receives unauthenticated data; a digest
+// mismatch can only mean the metadata and the chunks disagree.
+func downloadToWriter(client *HTTPClient, session *AuthSession, accountKey, fek []byte, fileMeta ServerFileInfo, ownerUsername string, out io.Writer, parallel int) error {
+	expectedSHA256 := ""
+	if fileMeta.EncryptedSHA256 != "" && fileMeta.SHA256Nonce != "" {
+		sha, err := decryptMetadataField(
+			fileMeta.EncryptedSHA256, fileMeta.SHA256Nonce, accountKey,
+			fileMeta.FileID, crypto.AADFieldSha256, ownerUsername,
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!] WARNING: Could not decrypt expected SHA-256: %v\n", err)
+		} else {
+			expectedSHA256 = sha
+		}
+	}
+
+	h := sha256.New()
+	if err := doChunkedDownload(client, session, fileMeta.FileID, fek, fileMeta, io.MultiWriter(out, h), parallel); err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	actualSHA256 := hex.EncodeToString(h.Sum(nil))
+	if expectedSHA256 != "" && actualSHA256 != expectedSHA256 {
+		return fmt.Errorf("[FAIL] SHA-256 mismatch!\n  Expected: %s\n  Got:      %s\n  Output may be corrupt", expectedSHA256, actualSHA256)
+	}
+	logVerbose("Download complete: %s, SHA-256 %s", formatFileSize(fileMeta.SizeBytes), actualSHA256)
+	return nil
+}
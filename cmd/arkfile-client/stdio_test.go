@@ -0,0 +1,163 @@
+// stdio_test.go - Unit tests for stdin uploads and stdout downloads: the
+// stdin spool, and streaming a download to a writer with its whole-file
+// SHA-256 check. Downloads run against httptest servers.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestSpoolInput(t *testing.T) {
+	input := strings.Repeat("pg_dump output\n", 1000)
+	f, err := os.CreateTemp(t.TempDir(), "arkfile-stdin-*.spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := newSpoolCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start near the top of the counter so reads carry across bytes
+	for i := 8; i < len(sc.iv); i++ {
+		sc.iv[i] = 0xff
+	}
+
+	sha, size, err := spoolInput(strings.NewReader(input), f, sc)
+	if err != nil {
+		t.Fatalf("spoolInput: %v", err)
+	}
+	sum := sha256.Sum256([]byte(input))
+	if sha != hex.EncodeToString(sum[:]) || size != int64(len(input)) {
+		t.Errorf("spoolInput = %s, %d; want digest and size of the input", sha, size)
+	}
+
+	raw, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != len(input) || bytes.Contains(raw, []byte("pg_dump")) {
+		t.Fatal("spool holds the input in the clear")
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("spool mode = %o, want 0600", info.Mode().Perm())
+	}
+
+	r, err := sc.open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, span := range [][2]int{{0, len(input)}, {17, 100}, {4095, 4097}, {len(input) - 5, len(input)}} {
+		buf := make([]byte, span[1]-span[0])
+		if _, err := r.ReadAt(buf, int64(span[0])); err != nil {
+			t.Fatalf("ReadAt(%d): %v", span[0], err)
+		}
+		if string(buf) != input[span[0]:span[1]] {
+			t.Errorf("ReadAt(%d, %d) did not decrypt to the input", span[0], len(buf))
+		}
+	}
+
+	// A different key cannot read it
+	other, err := newSpoolCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.iv = sc.iv
+	r2, err := other.open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	buf := make([]byte, 64)
+	if _, err := r2.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) == input[:64] {
+		t.Error("spool decrypted without its key")
+	}
+}
+
+// encryptedSHAMeta returns file metadata whose encrypted digest is sha.
+func encryptedSHAMeta(t *testing.T, accountKey []byte, chunkCount int64, sha string) ServerFileInfo {
+	t.Helper()
+	_, _, encSHA, shaNonce, err := encryptMetadata("dump.sql", sha, accountKey, parallelTestFileID, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ServerFileInfo{FileID: parallelTestFileID, ChunkCount: chunkCount, EncryptedSHA256: encSHA, SHA256Nonce: shaNonce}
+}
+
+func TestDownloadToWriter(t *testing.T) {
+	plaintext := []byte(strings.Repeat("0123456789", 25))
+	fek := bytes.Repeat([]byte{5}, 32)
+	srv, chunkCount := serveEncryptedChunks(t, plaintext, fek, 16, -1)
+	defer srv.Close()
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newHTTPClient(srv.URL, false, 10, false)
+	session := newTestSession("tok", "ref", 30*time.Minute)
+	sum := sha256.Sum256(plaintext)
+
+	var out bytes.Buffer
+	meta := encryptedSHAMeta(t, accountKey, chunkCount, hex.EncodeToString(sum[:]))
+	if err := downloadToWriter(client, session, accountKey, fek, meta, testOwner, &out, 3); err != nil {
+		t.Fatalf("downloadToWriter: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Error("streamed output does not match the original")
+	}
+
+	// Chunks that authenticate but disagree with the recorded digest
+	out.Reset()
+	meta = encryptedSHAMeta(t, accountKey, chunkCount, strings.Repeat("0", 64))
+	err = downloadToWriter(client, session, accountKey, fek, meta, testOwner, &out, 1)
+	if err == nil || !strings.Contains(err.Error(), "SHA-256 mismatch") {
+		t.Fatalf("expected a SHA-256 mismatch, got %v", err)
+	}
+}
+
+func TestDownloadToWriter_StopsAtTamperedChunk(t *testing.T) {
+	plaintext := []byte(strings.Repeat("abcdefghij", 20))
+	fek := bytes.Repeat([]byte{3}, 32)
+	srv, chunkCount := serveEncryptedChunks(t, plaintext, fek, 16, 2)
+	defer srv.Close()
+	accountKey, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newHTTPClient(srv.URL, false, 10, false)
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	sum := sha256.Sum256(plaintext)
+	meta := encryptedSHAMeta(t, accountKey, chunkCount, hex.EncodeToString(sum[:]))
+	err = downloadToWriter(client, newTestSession("tok", "ref", 30*time.Minute), accountKey, fek, meta, testOwner, out, 1)
+	if err == nil || !strings.Contains(err.Error(), "chunk 2") {
+		t.Fatalf("expected chunk 2 decryption failure, got %v", err)
+	}
+
+	// Only the verified chunks before the tampered one were written
+	got, _ := os.ReadFile(out.Name())
+	if !bytes.Equal(got, plaintext[:2*16]) {
+		t.Errorf("output holds %d bytes; want the 32 bytes before the tampered chunk", len(got))
+	}
+}
@@ -0,0 +1,772 @@
+// sync.go - Two-way sync between a local directory and the user's files.
+//
+// `arkfile-client sync <localdir>` compares the local tree against the
+// server's file list. The server only ever sees ciphertext, so the
+// comparison runs on decrypted metadata: the plaintext SHA-256 in
+// `encrypted_sha256sum` (decrypted with the account key) identifies file
+// contents, and the decrypted folder path and filename name downloads.
+// Uploads store each file's directory relative to <localdir> as its
+// encrypted folder, so the tree round-trips between machines.
+//
+// A state file (<localdir>/.arkfile-sync.json by default) records which
+// file_id each local path was last synced with, and the size, modification
+// time and digest it had. Repeat runs only hash files whose size or
+// modification time changed, and can tell "new on one side" apart from
+// "deleted on the other". A modified file is uploaded as a new version of
+// the file it was synced with; the server lists only the newest version of
+// each file, so other machines follow the state entry's file_id along its
+// version chain and download the newer version over an unchanged local
+// copy. Sync never deletes anything: a file removed on
+// one side while it still exists on the other is reported as a conflict
+// and left for the user to resolve.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// syncStateFileName is the default state file, kept inside the synced
+// directory. Dotfiles are never synced, so it is not uploaded itself.
+const syncStateFileName = ".arkfile-sync.json"
+
+const syncStateVersion = 1
+
+// syncState is the on-disk record of the last sync of one directory.
+//
+// Files maps slash-separated paths relative to the synced directory to the
+// file they were last synced with. IgnoredFileIDs lists server files that
+// were superseded by a newer upload of a locally modified file; they stay on
+// the server but are not downloaded again.
+type syncState struct {
+	Version        int                        `json:"version"`
+	ServerURL      string                     `json:"server_url"`
+	Username       string                     `json:"username"`
+	Files          map[string]*syncStateEntry `json:"files"`
+	IgnoredFileIDs []string                   `json:"ignored_file_ids,omitempty"`
+}
+
+type syncStateEntry struct {
+	FileID  string    `json:"file_id"`
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// syncLocalFile is a regular file found under the synced directory.
+type syncLocalFile struct {
+	RelPath string // slash-separated, relative to the synced directory
+	Path    string
+	Size    int64
+	ModTime time.Time
+	SHA256  string
+}
+
+// syncRemoteFile is a server file with its metadata decrypted. Filename and
+// SHA256 are empty when they could not be decrypted. Folder is "" for a file
+// at the top level.
+type syncRemoteFile struct {
+	FileID       string
+	Folder       string
+	Filename     string
+	SHA256       string
+	PasswordType string
+	Meta         ServerFileInfo
+}
+
+type syncActionKind int
+
+const (
+	syncUpload   syncActionKind = iota // local file is new or modified
+	syncDownload                       // server file is missing locally
+	syncLink                           // local and server file already match; record it
+	syncForget                         // gone on both sides; drop the state entry
+	syncConflict                       // needs a decision from the user
+	syncSkip                           // server file that sync cannot handle
+)
+
+// syncAction is one step of a sync plan. Supersedes names the server file a
+// modified local file replaces.
+type syncAction struct {
+	Kind       syncActionKind
+	RelPath    string
+	Local      *syncLocalFile
+	Remote     *syncRemoteFile
+	Supersedes string
+	Reason     string
+}
+
+func newSyncState(serverURL, username string) *syncState {
+	return &syncState{
+		Version:   syncStateVersion,
+		ServerURL: serverURL,
+		Username:  username,
+		Files:     make(map[string]*syncStateEntry),
+	}
+}
+
+// loadSyncState reads the state file at path. A missing file yields an empty
+// state. A state file written for another server or user is an error, since
+// its file IDs mean nothing here.
+func loadSyncState(path, serverURL, username string) (*syncState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newSyncState(serverURL, username), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	var state syncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("malformed sync state %s: %w", path, err)
+	}
+	if state.Version != syncStateVersion {
+		return nil, fmt.Errorf("sync state %s has unsupported version %d", path, state.Version)
+	}
+	if state.ServerURL != serverURL || state.Username != username {
+		return nil, fmt.Errorf("sync state %s belongs to %s on %s; use --state to choose another state file",
+			path, state.Username, state.ServerURL)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*syncStateEntry)
+	}
+	return &state, nil
+}
+
+// saveSyncState writes the state file (mode 0600) via a temp file and
+// rename, so an interrupted sync never leaves a truncated state behind.
+func saveSyncState(path string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".arkfile-sync.*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, werr := tmp.Write(data); werr != nil {
+		_ = tmp.Close()
+		return werr
+	}
+	if cerr := tmp.Chmod(0600); cerr != nil {
+		_ = tmp.Close()
+		return cerr
+	}
+	if cerr := tmp.Close(); cerr != nil {
+		return cerr
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *syncState) isIgnored(fileID string) bool {
+	return slices.Contains(s.IgnoredFileIDs, fileID)
+}
+
+// scanSyncDir lists the regular files under root, using the same rules as
+// `upload --dir --recursive`: dotfiles and dot-directories are skipped and
+// symlinks must stay inside root. A file whose size and modification time
+// match its state entry keeps the recorded digest; every other file is hashed.
+// Empty files cannot be uploaded and are left out.
+func scanSyncDir(root string, state *syncState) (map[string]*syncLocalFile, error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	paths, err := collectUploadInputs(nil, nil, rootAbs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]*syncLocalFile, len(paths))
+	for _, path := range paths {
+		rel, err := filepath.Rel(rootAbs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.Size() == 0 {
+			logVerbose("Skipping empty file %s", rel)
+			continue
+		}
+		file := &syncLocalFile{
+			RelPath: filepath.ToSlash(rel),
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if entry, ok := state.Files[file.RelPath]; ok && entry.Size == file.Size && entry.ModTime.Equal(file.ModTime) {
+			file.SHA256 = entry.SHA256
+		} else {
+			logVerbose("Computing SHA-256 digest of %s...", rel)
+			if file.SHA256, err = computeStreamingSHA256(path); err != nil {
+				return nil, fmt.Errorf("failed to compute SHA-256 of %s: %w", rel, err)
+			}
+		}
+		local[file.RelPath] = file
+	}
+	return local, nil
+}
+
+// decryptRemoteFiles decrypts the folder, filename and digest of every server file,
+// keyed by file_id. Files whose metadata does not decrypt are kept with
+// empty fields so they are reported instead of silently ignored.
+func decryptRemoteFiles(files []ServerFileInfo, accountKey []byte, username string) map[string]*syncRemoteFile {
+	remote := make(map[string]*syncRemoteFile, len(files))
+	for _, f := range files {
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = username
+		}
+		r := &syncRemoteFile{FileID: f.FileID, PasswordType: f.PasswordType, Meta: f}
+		if name, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+			f.FileID, crypto.AADFieldFilename, owner); err == nil {
+			r.Filename = name
+		} else {
+			logVerbose("Warning: failed to decrypt filename for file %s: %v", f.FileID, err)
+		}
+		if folder, err := decryptFileFolder(f, accountKey, owner); err == nil {
+			r.Folder = folder
+		} else {
+			// Without its folder the file has no known place locally
+			logVerbose("Warning: failed to decrypt folder for file %s: %v", f.FileID, err)
+			r.Filename = ""
+		}
+		if sha, err := decryptMetadataField(f.EncryptedSHA256, f.SHA256Nonce, accountKey,
+			f.FileID, crypto.AADFieldSha256, owner); err == nil {
+			r.SHA256 = sha
+		} else {
+			logVerbose("Warning: failed to decrypt sha256 for file %s: %v", f.FileID, err)
+		}
+		remote[f.FileID] = r
+	}
+	return remote
+}
+
+// syncDownloadName returns the slash-separated local path for a downloaded
+// server file, or "" when its folder or filename has a component that is not
+// a plain, visible name. Hidden paths are never synced, so downloading into
+// one would re-download the file on every run.
+func syncDownloadName(folder, filename string) string {
+	if !isPlainFileName(filename) {
+		return ""
+	}
+	rel := path.Join(folder, filename)
+	for _, part := range strings.Split(rel, "/") {
+		if !isPlainFileName(part) || strings.HasPrefix(part, ".") {
+			return ""
+		}
+	}
+	return rel
+}
+
+// splitSyncVersions returns the current version of every version chain in
+// files, and maps the file_id of each older version to the file_id of its
+// chain's current version.
+func splitSyncVersions(files []ServerFileInfo) ([]ServerFileInfo, map[string]string) {
+	latest := currentVersions(files)
+	currentID := make(map[string]string, len(latest))
+	var current []ServerFileInfo
+	for _, f := range files {
+		if f.VersionNumber == latest[versionGroupOf(f)] {
+			currentID[versionGroupOf(f)] = f.FileID
+			current = append(current, f)
+		}
+	}
+	newer := make(map[string]string)
+	for _, f := range files {
+		if id := currentID[versionGroupOf(f)]; id != f.FileID {
+			newer[f.FileID] = id
+		}
+	}
+	return current, newer
+}
+
+// planSync compares the local files, the server files and the last sync
+// state and returns the actions that bring both sides up to date:
+//
+//   - A tracked file unchanged on both sides needs nothing.
+//   - A tracked file modified locally is uploaded as a new version of its
+//     server file; the old version is ignored from then on.
+//   - A tracked file whose server file has a newer version (uploaded from
+//     another machine) is replaced by it when unchanged locally, linked
+//     when it already matches, and a conflict when modified on both sides.
+//   - An untracked local file is linked to a server file with the same
+//     digest, or uploaded when there is none.
+//   - An untracked server file whose contents are not present locally is
+//     downloaded to its folder path under its own name.
+//   - A tracked file deleted on exactly one side, or a download whose name
+//     is taken locally, is a conflict.
+//   - A tracked file deleted on both sides is forgotten.
+//
+// remote holds the current version of each server file; newer maps older
+// version file_ids to the current one (see splitSyncVersions).
+func planSync(state *syncState, local map[string]*syncLocalFile, remote map[string]*syncRemoteFile, newer map[string]string) []syncAction {
+	var actions []syncAction
+
+	// serverFile returns the current version of the server file a state
+	// entry was synced with.
+	serverFile := func(fileID string) (*syncRemoteFile, bool) {
+		if id, ok := newer[fileID]; ok {
+			fileID = id
+		}
+		r, ok := remote[fileID]
+		return r, ok
+	}
+
+	// Server files that already belong to a local path
+	claimed := make(map[string]bool)
+	for _, entry := range state.Files {
+		claimed[entry.FileID] = true
+		if r, ok := serverFile(entry.FileID); ok {
+			claimed[r.FileID] = true
+		}
+	}
+
+	remoteBySHA := make(map[string][]*syncRemoteFile)
+	for _, id := range sortedKeys(remote) {
+		if r := remote[id]; r.SHA256 != "" {
+			remoteBySHA[r.SHA256] = append(remoteBySHA[r.SHA256], r)
+		}
+	}
+
+	localSHAs := make(map[string]bool, len(local))
+	for _, rel := range sortedKeys(local) {
+		l := local[rel]
+		localSHAs[l.SHA256] = true
+
+		entry, tracked := state.Files[rel]
+		if !tracked {
+			var match *syncRemoteFile
+			for _, r := range remoteBySHA[l.SHA256] {
+				if !claimed[r.FileID] && !state.isIgnored(r.FileID) {
+					match = r
+					break
+				}
+			}
+			if match != nil {
+				claimed[match.FileID] = true
+				actions = append(actions, syncAction{Kind: syncLink, RelPath: rel, Local: l, Remote: match})
+			} else {
+				actions = append(actions, syncAction{Kind: syncUpload, RelPath: rel, Local: l})
+			}
+			continue
+		}
+
+		r, onServer := serverFile(entry.FileID)
+		replaced := onServer && r.FileID != entry.FileID
+		switch {
+		case replaced && l.SHA256 == r.SHA256:
+			// Already matches the newer version
+			actions = append(actions, syncAction{Kind: syncLink, RelPath: rel, Local: l, Remote: r})
+		case replaced && l.SHA256 != entry.SHA256:
+			actions = append(actions, syncAction{Kind: syncConflict, RelPath: rel, Local: l, Remote: r,
+				Reason: fmt.Sprintf("modified locally and on the server (file %s)", r.FileID)})
+		case replaced && (r.SHA256 == "" || r.PasswordType == "custom"):
+			actions = append(actions, syncAction{Kind: syncSkip, RelPath: rel, Local: l, Remote: r,
+				Reason: fmt.Sprintf("newer version %s cannot be synced; use download", r.FileID)})
+		case replaced:
+			actions = append(actions, syncAction{Kind: syncDownload, RelPath: rel, Local: l, Remote: r})
+		case l.SHA256 == entry.SHA256 && onServer:
+			// In sync; refresh the recorded size and mtime if they moved
+			if l.Size != entry.Size || !l.ModTime.Equal(entry.ModTime) {
+				actions = append(actions, syncAction{Kind: syncLink, RelPath: rel, Local: l, Remote: r})
+			}
+		case l.SHA256 == entry.SHA256:
+			actions = append(actions, syncAction{Kind: syncConflict, RelPath: rel, Local: l,
+				Reason: "deleted on server, still present locally"})
+		case onServer:
+			actions = append(actions, syncAction{Kind: syncUpload, RelPath: rel, Local: l, Supersedes: entry.FileID})
+		default:
+			actions = append(actions, syncAction{Kind: syncConflict, RelPath: rel, Local: l,
+				Reason: "modified locally, deleted on server"})
+		}
+	}
+
+	for _, rel := range sortedKeys(state.Files) {
+		if _, ok := local[rel]; ok {
+			continue
+		}
+		if r, onServer := serverFile(state.Files[rel].FileID); onServer {
+			actions = append(actions, syncAction{Kind: syncConflict, RelPath: rel, Remote: r,
+				Reason: "deleted locally, still on server"})
+		} else {
+			actions = append(actions, syncAction{Kind: syncForget, RelPath: rel})
+		}
+	}
+
+	// Untracked server files, in path order so the first of several
+	// same-named files wins the name.
+	var untracked []*syncRemoteFile
+	for _, r := range remote {
+		if !claimed[r.FileID] && !state.isIgnored(r.FileID) {
+			untracked = append(untracked, r)
+		}
+	}
+	sort.Slice(untracked, func(i, j int) bool {
+		pi := path.Join(untracked[i].Folder, untracked[i].Filename)
+		pj := path.Join(untracked[j].Folder, untracked[j].Filename)
+		if pi != pj {
+			return pi < pj
+		}
+		return untracked[i].FileID < untracked[j].FileID
+	})
+
+	targets := make(map[string]bool)
+	for _, r := range untracked {
+		if r.SHA256 != "" && localSHAs[r.SHA256] {
+			// Contents already present locally (a server-side duplicate)
+			continue
+		}
+		name := syncDownloadName(r.Folder, r.Filename)
+		display := path.Join(r.Folder, r.Filename)
+		switch {
+		case r.SHA256 == "" || r.Filename == "":
+			actions = append(actions, syncAction{Kind: syncSkip, RelPath: r.FileID, Remote: r,
+				Reason: "metadata could not be decrypted"})
+			continue
+		case r.PasswordType == "custom":
+			actions = append(actions, syncAction{Kind: syncSkip, RelPath: display, Remote: r,
+				Reason: "custom-password file; use download"})
+			continue
+		case name == "":
+			actions = append(actions, syncAction{Kind: syncSkip, RelPath: display, Remote: r,
+				Reason: "path cannot be used locally"})
+			continue
+		}
+		localSHAs[r.SHA256] = true
+		if _, exists := local[name]; exists || targets[name] {
+			actions = append(actions, syncAction{Kind: syncConflict, RelPath: name, Remote: r,
+				Reason: fmt.Sprintf("server file %s has different contents", r.FileID)})
+			continue
+		}
+		if entry, tracked := state.Files[name]; tracked {
+			if _, onServer := serverFile(entry.FileID); onServer {
+				actions = append(actions, syncAction{Kind: syncConflict, RelPath: name, Remote: r,
+					Reason: fmt.Sprintf("server file %s reuses the name of a file deleted locally", r.FileID)})
+				continue
+			}
+		}
+		targets[name] = true
+		actions = append(actions, syncAction{Kind: syncDownload, RelPath: name, Remote: r})
+	}
+	return actions
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func handleSyncCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show what would be transferred without changing anything")
+	statePath := fs.String("state", "", "Sync state file (default: <localdir>/"+syncStateFileName+")")
+	parallel := fs.Int("parallel", 1, fmt.Sprintf("Number of chunks to transfer concurrently per file (1-%d)", maxParallelChunks))
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client sync <localdir> [--dry-run] [--state PATH] [--parallel N]\n\n" +
+			"Sync a local directory with your files on the server, in both directions.\n" +
+			"Local and server files are matched by their plaintext SHA-256 digest, decrypted\n" +
+			"from the server's metadata with your account key. New and modified local files are\n" +
+			"uploaded (with the account password); server files missing locally are downloaded\n" +
+			"into <localdir> under their own names. Sync never deletes: a file deleted on one side\n" +
+			"but present on the other, or a download whose name is taken, is reported as a conflict.\n" +
+			"A modified local file is uploaded as a new version of the file it was synced with,\n" +
+			"and other machines replace their unchanged copy with that newer version. A file\n" +
+			"modified on both sides is reported as a conflict.\n" +
+			"Custom-password files on the server are not downloaded. Dotfiles are not synced.\n" +
+			"The state file records which server file each local path was synced with, so repeat\n" +
+			"runs only hash changed files.\n")
+	}
+
+	// Allow flags after the directory argument as well as before it
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one local directory is required")
+	}
+	if *parallel < 1 || *parallel > maxParallelChunks {
+		return fmt.Errorf("--parallel must be between 1 and %d", maxParallelChunks)
+	}
+
+	root := positional[0]
+	if info, err := os.Stat(root); err != nil {
+		return fmt.Errorf("cannot sync %s: %w", root, err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("cannot sync %s: not a directory", root)
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(root, syncStateFileName)
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	state, err := loadSyncState(*statePath, client.baseURL, session.Username)
+	if err != nil {
+		return err
+	}
+	local, err := scanSyncDir(root, state)
+	if err != nil {
+		return err
+	}
+	if rerr := ensureFreshSessionToken(client, session, 0); rerr != nil {
+		return rerr
+	}
+	files, err := fetchAllFileVersions(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to list server files: %w", err)
+	}
+	current, newer := splitSyncVersions(files)
+	remote := decryptRemoteFiles(current, accountKey, session.Username)
+
+	// Superseded server files that have since been deleted need no record
+	state.IgnoredFileIDs = slices.DeleteFunc(state.IgnoredFileIDs, func(id string) bool {
+		_, ok := remote[id]
+		_, old := newer[id]
+		return !ok && !old
+	})
+
+	actions := planSync(state, local, remote, newer)
+	if *dryRun {
+		printSyncPlan(actions)
+		return nil
+	}
+
+	// Interrupting finishes the current transfer and stops before the next
+	interrupted := false
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			interrupted = true
+		}
+	}()
+
+	var uploaded, downloaded, linked, conflicts, failed, skipped int
+	var fatalReason string
+	for _, action := range actions {
+		if interrupted && fatalReason == "" {
+			fatalReason = "interrupted"
+		}
+		if fatalReason != "" && (action.Kind == syncUpload || action.Kind == syncDownload) {
+			skipped++
+			fmt.Printf("[!] %s: skipped (%s)\n", action.RelPath, fatalReason)
+			continue
+		}
+
+		var err error
+		switch action.Kind {
+		case syncUpload:
+			if err = ensureFreshSessionToken(client, session, 0); err == nil {
+				err = syncUploadFile(client, session, accountKey, state, action, *parallel)
+			}
+			if err == nil {
+				uploaded++
+				fmt.Printf("[UP] %s (file_id=%s)\n", action.RelPath, state.Files[action.RelPath].FileID)
+			}
+		case syncDownload:
+			if err = ensureFreshSessionToken(client, session, 0); err == nil {
+				err = syncDownloadFile(client, session, accountKey, root, state, action, *parallel)
+			}
+			if err == nil {
+				downloaded++
+				fmt.Printf("[DOWN] %s (file_id=%s)\n", action.RelPath, action.Remote.FileID)
+			}
+		case syncLink:
+			state.Files[action.RelPath] = &syncStateEntry{
+				FileID:  action.Remote.FileID,
+				SHA256:  action.Local.SHA256,
+				Size:    action.Local.Size,
+				ModTime: action.Local.ModTime,
+			}
+			linked++
+			logVerbose("[LINK] %s (file_id=%s)", action.RelPath, action.Remote.FileID)
+		case syncForget:
+			delete(state.Files, action.RelPath)
+			logVerbose("Forgetting %s: deleted locally and on the server", action.RelPath)
+		case syncConflict:
+			conflicts++
+			fmt.Printf("[CONFLICT] %s: %s\n", action.RelPath, action.Reason)
+			continue
+		case syncSkip:
+			fmt.Printf("[SKIP] %s: %s\n", action.RelPath, action.Reason)
+			continue
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("[X] %s: %v\n", action.RelPath, err)
+			if isFatalUploadError(err) {
+				fatalReason = "aborted after fatal error"
+			}
+			continue
+		}
+		// Record progress after every step so an interrupted sync resumes
+		// where it stopped.
+		if err := saveSyncState(*statePath, state); err != nil {
+			return fmt.Errorf("failed to save sync state: %w", err)
+		}
+	}
+	if err := saveSyncState(*statePath, state); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+
+	fmt.Printf("\nUploaded: %d. Downloaded: %d. Linked: %d. Conflicts: %d. Failed: %d. Skipped: %d.\n",
+		uploaded, downloaded, linked, conflicts, failed, skipped)
+	if failed > 0 || skipped > 0 || conflicts > 0 {
+		return fmt.Errorf("sync finished with %d failed, %d skipped and %d conflicts", failed, skipped, conflicts)
+	}
+	return nil
+}
+
+func printSyncPlan(actions []syncAction) {
+	if len(actions) == 0 {
+		fmt.Println("Already in sync.")
+		return
+	}
+	for _, action := range actions {
+		switch action.Kind {
+		case syncUpload:
+			if action.Supersedes != "" {
+				fmt.Printf("[UP] %s (modified; replaces file_id=%s)\n", action.RelPath, action.Supersedes)
+			} else {
+				fmt.Printf("[UP] %s\n", action.RelPath)
+			}
+		case syncDownload:
+			if action.Local != nil {
+				fmt.Printf("[DOWN] %s (newer version file_id=%s, %s; replaces the local copy)\n",
+					action.RelPath, action.Remote.FileID, formatFileSize(action.Remote.Meta.SizeBytes))
+			} else {
+				fmt.Printf("[DOWN] %s (file_id=%s, %s)\n", action.RelPath, action.Remote.FileID, formatFileSize(action.Remote.Meta.SizeBytes))
+			}
+		case syncLink:
+			fmt.Printf("[LINK] %s (file_id=%s)\n", action.RelPath, action.Remote.FileID)
+		case syncForget:
+			fmt.Printf("[FORGET] %s (deleted on both sides)\n", action.RelPath)
+		case syncConflict:
+			fmt.Printf("[CONFLICT] %s: %s\n", action.RelPath, action.Reason)
+		case syncSkip:
+			fmt.Printf("[SKIP] %s: %s\n", action.RelPath, action.Reason)
+		}
+	}
+}
+
+// syncUploadFile uploads a new or modified local file with the account
+// password, in the folder of its path relative to the synced directory, and
+// records it in state. A modified file is uploaded as a new version of the
+// server file it replaces.
+func syncUploadFile(client *HTTPClient, session *AuthSession, accountKey []byte, state *syncState, action syncAction, parallel int) error {
+	l := action.Local
+	info, err := os.Stat(l.Path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() != l.Size || !info.ModTime().Equal(l.ModTime) {
+		return fmt.Errorf("file changed during sync; run sync again")
+	}
+
+	folder, err := cleanFolderPath(path.Dir(action.RelPath))
+	if err != nil {
+		return err
+	}
+	fileID, err := uploadFileWithDigest(client, session, accountKey, accountKey, "account", "", l.Path, folder, action.Supersedes, l.SHA256, parallel)
+	if err != nil {
+		return err
+	}
+	state.Files[action.RelPath] = &syncStateEntry{FileID: fileID, SHA256: l.SHA256, Size: l.Size, ModTime: l.ModTime}
+	if action.Supersedes != "" && !state.isIgnored(action.Supersedes) {
+		state.IgnoredFileIDs = append(state.IgnoredFileIDs, action.Supersedes)
+	}
+	return nil
+}
+
+// syncDownloadFile downloads an account-password server file into root,
+// verifies its digest and records it in state. Missing parent directories
+// of the file's folder are created. When action.Local is set the download
+// is a newer version of that file and replaces it, provided the local copy
+// has not changed since it was scanned.
+func syncDownloadFile(client *HTTPClient, session *AuthSession, accountKey []byte, root string, state *syncState, action syncAction, parallel int) error {
+	r := action.Remote
+	if r.Meta.EncryptedFEK == "" {
+		return fmt.Errorf("file metadata missing encrypted FEK")
+	}
+	fek, _, err := unwrapFEK(r.Meta.EncryptedFEK, accountKey, r.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap FEK: %w", err)
+	}
+	defer clearBytes(fek)
+
+	dest := filepath.Join(root, filepath.FromSlash(action.RelPath))
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if action.Local == nil {
+		if err := downloadToVerifiedFile(client, session, fek, r.Meta, r.SHA256, dest, parallel); err != nil {
+			return err
+		}
+	} else {
+		// Download beside the local copy (a dotfile, so never synced) and
+		// swap it in only if the local copy is still the one scanned.
+		next := filepath.Join(filepath.Dir(dest), ".arkfile-sync."+r.FileID+".new")
+		_ = os.Remove(next)
+		if err := downloadToVerifiedFile(client, session, fek, r.Meta, r.SHA256, next, parallel); err != nil {
+			return err
+		}
+		info, err := os.Stat(dest)
+		if err != nil || info.Size() != action.Local.Size || !info.ModTime().Equal(action.Local.ModTime) {
+			_ = os.Remove(next)
+			return fmt.Errorf("file changed during sync; run sync again")
+		}
+		if err := os.Rename(next, dest); err != nil {
+			_ = os.Remove(next)
+			return err
+		}
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		return err
+	}
+	state.Files[action.RelPath] = &syncStateEntry{FileID: r.FileID, SHA256: r.SHA256, Size: info.Size(), ModTime: info.ModTime()}
+	return nil
+}
@@ -0,0 +1,339 @@
+// sync_test.go - Unit tests for `arkfile-client sync`: the state file, the
+// local scan and the sync plan. All tests run on temp directories and
+// in-memory file lists; nothing touches the network.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func syncLocal(rel, sha string) *syncLocalFile {
+	return &syncLocalFile{RelPath: rel, Path: "/sync/" + rel, Size: 10, ModTime: time.Unix(1000, 0), SHA256: sha}
+}
+
+func syncRemote(id, name, sha string) *syncRemoteFile {
+	return &syncRemoteFile{FileID: id, Filename: name, SHA256: sha, PasswordType: "account"}
+}
+
+func syncEntry(id, sha string) *syncStateEntry {
+	return &syncStateEntry{FileID: id, SHA256: sha, Size: 10, ModTime: time.Unix(1000, 0)}
+}
+
+// planSummary renders a plan as "KIND path" lines for easy comparison.
+func planSummary(actions []syncAction) string {
+	kinds := map[syncActionKind]string{
+		syncUpload: "UP", syncDownload: "DOWN", syncLink: "LINK",
+		syncForget: "FORGET", syncConflict: "CONFLICT", syncSkip: "SKIP",
+	}
+	var lines []string
+	for _, a := range actions {
+		lines = append(lines, kinds[a.Kind]+" "+a.RelPath)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPlanSync_FirstRun(t *testing.T) {
+	state := newSyncState("https://vault.example", "testuser")
+	local := map[string]*syncLocalFile{
+		"a.txt":     syncLocal("a.txt", "aaa"),
+		"sub/b.txt": syncLocal("sub/b.txt", "bbb"),
+	}
+	remote := map[string]*syncRemoteFile{
+		"id-b":   syncRemote("id-b", "b.txt", "bbb"),
+		"id-c":   syncRemote("id-c", "c.txt", "ccc"),
+		"id-c2":  syncRemote("id-c2", "c-copy.txt", "ccc"),
+		"id-dup": syncRemote("id-dup", "a.txt", "aaa"),
+	}
+
+	actions := planSync(state, local, remote, nil)
+	want := strings.Join([]string{
+		"LINK a.txt",
+		"LINK sub/b.txt",
+		"DOWN c-copy.txt",
+	}, "\n")
+	if got := planSummary(actions); got != want {
+		t.Fatalf("plan:\n%s\nwant:\n%s", got, want)
+	}
+	if actions[0].Remote.FileID != "id-dup" || actions[1].Remote.FileID != "id-b" {
+		t.Errorf("links matched the wrong server files: %+v, %+v", actions[0].Remote, actions[1].Remote)
+	}
+}
+
+func TestPlanSync_IncrementalChanges(t *testing.T) {
+	state := newSyncState("https://vault.example", "testuser")
+	state.Files = map[string]*syncStateEntry{
+		"same.txt":          syncEntry("id-same", "s1"),
+		"edited.txt":        syncEntry("id-edited", "e1"),
+		"gone-remote.txt":   syncEntry("id-gone", "g1"),
+		"edited-gone.txt":   syncEntry("id-eg", "eg1"),
+		"deleted-local.txt": syncEntry("id-dl", "d1"),
+		"both-gone.txt":     syncEntry("id-bg", "b1"),
+	}
+	state.IgnoredFileIDs = []string{"id-old"}
+	local := map[string]*syncLocalFile{
+		"same.txt":        syncLocal("same.txt", "s1"),
+		"edited.txt":      syncLocal("edited.txt", "e2"),
+		"gone-remote.txt": syncLocal("gone-remote.txt", "g1"),
+		"edited-gone.txt": syncLocal("edited-gone.txt", "eg2"),
+		"new.txt":         syncLocal("new.txt", "n1"),
+	}
+	remote := map[string]*syncRemoteFile{
+		"id-same":   syncRemote("id-same", "same.txt", "s1"),
+		"id-edited": syncRemote("id-edited", "edited.txt", "e1"),
+		"id-dl":     syncRemote("id-dl", "deleted-local.txt", "d1"),
+		"id-old":    syncRemote("id-old", "old.txt", "o1"),
+	}
+
+	actions := planSync(state, local, remote, nil)
+	want := strings.Join([]string{
+		"CONFLICT edited-gone.txt",
+		"UP edited.txt",
+		"CONFLICT gone-remote.txt",
+		"UP new.txt",
+		"FORGET both-gone.txt",
+		"CONFLICT deleted-local.txt",
+	}, "\n")
+	if got := planSummary(actions); got != want {
+		t.Fatalf("plan:\n%s\nwant:\n%s", got, want)
+	}
+	for _, a := range actions {
+		if a.RelPath == "edited.txt" && a.Supersedes != "id-edited" {
+			t.Errorf("modified file should supersede id-edited, got %q", a.Supersedes)
+		}
+	}
+}
+
+func TestPlanSync_DownloadRules(t *testing.T) {
+	state := newSyncState("https://vault.example", "testuser")
+	state.Files = map[string]*syncStateEntry{"removed.txt": syncEntry("id-removed", "r1")}
+	local := map[string]*syncLocalFile{
+		"taken.txt": syncLocal("taken.txt", "t1"),
+	}
+	custom := syncRemote("id-custom", "secret.txt", "x1")
+	custom.PasswordType = "custom"
+	remote := map[string]*syncRemoteFile{
+		"id-taken":   syncRemote("id-taken", "taken.txt", "t2"),
+		"id-custom":  custom,
+		"id-hidden":  syncRemote("id-hidden", ".hidden", "h1"),
+		"id-broken":  syncRemote("id-broken", "", ""),
+		"id-twin1":   syncRemote("id-twin1", "twin.txt", "w1"),
+		"id-twin2":   syncRemote("id-twin2", "twin.txt", "w2"),
+		"id-reused":  syncRemote("id-reused", "removed.txt", "r2"),
+		"id-present": syncRemote("id-present", "elsewhere.txt", "t1"),
+		"id-again":   syncRemote("id-again", "another.txt", "t1"),
+		"id-nested":  syncRemote("id-nested", "q1.xlsx", "n1"),
+		"id-dotdir":  syncRemote("id-dotdir", "config", "g1"),
+	}
+	remote["id-nested"].Folder = "docs/2026"
+	remote["id-dotdir"].Folder = "src/.git"
+
+	// taken.txt links to the first server file with its contents; the
+	// second copy is a server-side duplicate and is left alone. The file
+	// deleted on both sides frees its name for a new server file. Server
+	// files land at their folder path unless it is hidden.
+	got := planSummary(planSync(state, local, remote, nil))
+	want := strings.Join([]string{
+		"LINK taken.txt",
+		"FORGET removed.txt",
+		"SKIP id-broken",
+		"SKIP .hidden",
+		"DOWN docs/2026/q1.xlsx",
+		"DOWN removed.txt",
+		"SKIP secret.txt",
+		"SKIP src/.git/config",
+		"CONFLICT taken.txt",
+		"DOWN twin.txt",
+		"CONFLICT twin.txt",
+	}, "\n")
+	if got != want {
+		t.Fatalf("plan:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPlanSync_InSyncIsEmpty(t *testing.T) {
+	state := newSyncState("https://vault.example", "testuser")
+	state.Files = map[string]*syncStateEntry{"a.txt": syncEntry("id-a", "aaa")}
+	local := map[string]*syncLocalFile{"a.txt": syncLocal("a.txt", "aaa")}
+	remote := map[string]*syncRemoteFile{"id-a": syncRemote("id-a", "a.txt", "aaa")}
+
+	if actions := planSync(state, local, remote, nil); len(actions) != 0 {
+		t.Errorf("expected no actions, got:\n%s", planSummary(actions))
+	}
+
+	// A touched but unchanged file only refreshes its state entry
+	local["a.txt"].ModTime = time.Unix(2000, 0)
+	if got := planSummary(planSync(state, local, remote, nil)); got != "LINK a.txt" {
+		t.Errorf("expected a LINK refresh, got %q", got)
+	}
+}
+
+func TestSplitSyncVersions(t *testing.T) {
+	files := []ServerFileInfo{
+		{FileID: "id-v1", VersionNumber: 1},
+		{FileID: "id-v3", VersionGroup: "id-v1", VersionNumber: 3},
+		{FileID: "id-v2", VersionGroup: "id-v1", VersionNumber: 2},
+		{FileID: "id-solo", VersionNumber: 1},
+	}
+	current, newer := splitSyncVersions(files)
+	var ids []string
+	for _, f := range current {
+		ids = append(ids, f.FileID)
+	}
+	if strings.Join(ids, ",") != "id-v3,id-solo" {
+		t.Errorf("current versions = %v", ids)
+	}
+	if len(newer) != 2 || newer["id-v1"] != "id-v3" || newer["id-v2"] != "id-v3" {
+		t.Errorf("newer = %v", newer)
+	}
+}
+
+// TestPlanSync_TwoMachines follows an edit from one machine to another: the
+// server lists only the newest version, so the second machine must find it
+// through the version chain of the file it last synced.
+func TestPlanSync_TwoMachines(t *testing.T) {
+	stateA := newSyncState("https://vault.example", "testuser")
+	stateA.Files["report.txt"] = syncEntry("id-v1", "r1")
+	stateB := newSyncState("https://vault.example", "testuser")
+	stateB.Files["report.txt"] = syncEntry("id-v1", "r1")
+
+	// Machine A edits the file and uploads it as version 2
+	localA := map[string]*syncLocalFile{"report.txt": syncLocal("report.txt", "r2")}
+	remote := map[string]*syncRemoteFile{"id-v1": syncRemote("id-v1", "report.txt", "r1")}
+	actions := planSync(stateA, localA, remote, nil)
+	if got := planSummary(actions); got != "UP report.txt" || actions[0].Supersedes != "id-v1" {
+		t.Fatalf("machine A plan: %q (supersedes %q)", got, actions[0].Supersedes)
+	}
+	stateA.Files["report.txt"] = syncEntry("id-v2", "r2")
+
+	current, newer := splitSyncVersions([]ServerFileInfo{
+		{FileID: "id-v1", VersionNumber: 1},
+		{FileID: "id-v2", VersionGroup: "id-v1", VersionNumber: 2},
+	})
+	if len(current) != 1 || current[0].FileID != "id-v2" {
+		t.Fatalf("current versions = %+v", current)
+	}
+	remote = map[string]*syncRemoteFile{"id-v2": syncRemote("id-v2", "report.txt", "r2")}
+
+	// Machine A is now in sync
+	if actions := planSync(stateA, localA, remote, newer); len(actions) != 0 {
+		t.Errorf("machine A should be in sync, got:\n%s", planSummary(actions))
+	}
+
+	// Machine B still has version 1 unchanged: download version 2 over it
+	localB := map[string]*syncLocalFile{"report.txt": syncLocal("report.txt", "r1")}
+	actions = planSync(stateB, localB, remote, newer)
+	if got := planSummary(actions); got != "DOWN report.txt" {
+		t.Fatalf("machine B plan: %q", got)
+	}
+	if actions[0].Remote.FileID != "id-v2" || actions[0].Local == nil {
+		t.Errorf("machine B should replace its copy with id-v2, got %+v", actions[0])
+	}
+
+	// Already edited the same way on B: just record the new file_id
+	localB["report.txt"] = syncLocal("report.txt", "r2")
+	if got := planSummary(planSync(stateB, localB, remote, newer)); got != "LINK report.txt" {
+		t.Errorf("matching edit on B: %q", got)
+	}
+
+	// Edited differently on B: a conflict, and no upload over version 2
+	localB["report.txt"] = syncLocal("report.txt", "r3")
+	if got := planSummary(planSync(stateB, localB, remote, newer)); got != "CONFLICT report.txt" {
+		t.Errorf("edits on both machines: %q", got)
+	}
+
+	// Deleted on B while A edited it: still a conflict on the newest version
+	actions = planSync(stateB, map[string]*syncLocalFile{}, remote, newer)
+	if got := planSummary(actions); got != "CONFLICT report.txt" || actions[0].Remote.FileID != "id-v2" {
+		t.Errorf("deleted on B: %q", got)
+	}
+}
+
+func TestSyncState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), syncStateFileName)
+
+	state, err := loadSyncState(path, "https://vault.example", "testuser")
+	if err != nil || len(state.Files) != 0 {
+		t.Fatalf("missing state should load empty, got %+v, %v", state, err)
+	}
+	state.Files["dir/a.txt"] = syncEntry("id-a", "aaa")
+	state.Files["dir/a.txt"].ModTime = time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	state.IgnoredFileIDs = []string{"id-old"}
+	if err := saveSyncState(path, state); err != nil {
+		t.Fatalf("saveSyncState: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state mode = %o, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := loadSyncState(path, "https://vault.example", "testuser")
+	if err != nil {
+		t.Fatalf("loadSyncState: %v", err)
+	}
+	entry := loaded.Files["dir/a.txt"]
+	if entry == nil || entry.FileID != "id-a" || !entry.ModTime.Equal(state.Files["dir/a.txt"].ModTime) {
+		t.Errorf("entry did not round-trip: %+v", entry)
+	}
+	if !loaded.isIgnored("id-old") {
+		t.Error("ignored file IDs did not round-trip")
+	}
+
+	if _, err := loadSyncState(path, "https://vault.example", "someoneelse"); err == nil {
+		t.Error("state of another user must be rejected")
+	}
+	if _, err := loadSyncState(path, "https://other.example", "testuser"); err == nil {
+		t.Error("state of another server must be rejected")
+	}
+}
+
+func TestScanSyncDir(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "hello")
+	write("sub/b.txt", "world")
+	write("empty.txt", "")
+	write(".hidden", "secret")
+	write(syncStateFileName, "{}")
+
+	state := newSyncState("https://vault.example", "testuser")
+	local, err := scanSyncDir(root, state)
+	if err != nil {
+		t.Fatalf("scanSyncDir: %v", err)
+	}
+	if len(local) != 2 || local["a.txt"] == nil || local["sub/b.txt"] == nil {
+		t.Fatalf("unexpected scan result: %v", sortedKeys(local))
+	}
+	const helloSHA = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if local["a.txt"].SHA256 != helloSHA {
+		t.Errorf("a.txt digest = %s", local["a.txt"].SHA256)
+	}
+
+	// An unchanged file reuses the recorded digest instead of re-hashing
+	a := local["a.txt"]
+	state.Files["a.txt"] = &syncStateEntry{FileID: "id-a", SHA256: "recorded", Size: a.Size, ModTime: a.ModTime}
+	local, err = scanSyncDir(root, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local["a.txt"].SHA256 != "recorded" {
+		t.Errorf("unchanged file was re-hashed: %s", local["a.txt"].SHA256)
+	}
+}
@@ -0,0 +1,227 @@
+// trash.go - Client side of the server's trash for deleted files.
+//
+// `delete-file` moves a file to the trash instead of deleting it; it stays
+// there, still counting toward the storage quota, until the server purges it
+// after its retention period. `trash` lists the trashed files with their
+// purge times, `restore` takes one back out, and `delete-file --permanent`
+// skips the trash (or removes a file that is already in it).
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// ServerTrashInfo is a file in the trash listing: the usual file metadata
+// plus when it was deleted and when the server will purge it.
+type ServerTrashInfo struct {
+	ServerFileInfo
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at"`
+}
+
+// ServerTrashListResponse represents the server's trash listing.
+type ServerTrashListResponse struct {
+	Files         []ServerTrashInfo `json:"files"`
+	RetentionDays int               `json:"retention_days"`
+}
+
+// fetchTrash returns the files in the user's trash, most recently deleted
+// first.
+func fetchTrash(client *HTTPClient, session *AuthSession) (*ServerTrashListResponse, error) {
+	req, err := http.NewRequest("GET", client.baseURL+"/api/files/trash", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	var trash ServerTrashListResponse
+	if err := decodeJSONResponse(resp, &trash); err != nil {
+		return nil, err
+	}
+	return &trash, nil
+}
+
+// decryptedFilename decrypts a listed file's name, or returns "[encrypted]".
+func decryptedFilename(f ServerFileInfo, accountKey []byte, owner string) string {
+	if accountKey == nil || f.EncryptedFilename == "" || f.FilenameNonce == "" {
+		return "[encrypted]"
+	}
+	name, err := decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, accountKey,
+		f.FileID, crypto.AADFieldFilename, owner)
+	if err != nil {
+		return "[encrypted]"
+	}
+	return name
+}
+
+// handleTrashCommand lists the files in the trash.
+func handleTrashCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("trash", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client trash [--json]\n\n" +
+			"List deleted files that can still be restored with 'arkfile-client restore',\n" +
+			"and when the server will purge each of them for good. Trashed files count\n" +
+			"toward your storage quota until they are purged.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+
+	trash, err := fetchTrash(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch trash: %w", err)
+	}
+
+	var accountKey []byte
+	if agentClient, agentErr := NewAgentClient(); agentErr == nil {
+		// Pass empty token for read-only listing (no session binding check)
+		accountKey, _ = agentClient.GetAccountKey("")
+	}
+
+	if *jsonOutput {
+		type DecryptedTrashFile struct {
+			FileID    string `json:"file_id"`
+			Folder    string `json:"folder"`
+			Filename  string `json:"filename"`
+			SizeBytes int64  `json:"size_bytes"`
+			DeletedAt string `json:"deleted_at"`
+			PurgeAt   string `json:"purge_at"`
+		}
+		out := make([]DecryptedTrashFile, 0, len(trash.Files))
+		for _, f := range trash.Files {
+			owner := f.OwnerUsername
+			if owner == "" {
+				owner = session.Username
+			}
+			out = append(out, DecryptedTrashFile{
+				FileID:    f.FileID,
+				Folder:    displayFolder(f.ServerFileInfo, accountKey, owner),
+				Filename:  decryptedFilename(f.ServerFileInfo, accountKey, owner),
+				SizeBytes: f.SizeBytes,
+				DeletedAt: f.DeletedAt,
+				PurgeAt:   f.PurgeAt,
+			})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	if len(trash.Files) == 0 {
+		fmt.Println("Trash is empty.")
+		return nil
+	}
+
+	sep := strings.Repeat("-", 80)
+	for i, f := range trash.Files {
+		owner := f.OwnerUsername
+		if owner == "" {
+			owner = session.Username
+		}
+		size := f.SizeReadable
+		if size == "" {
+			size = formatFileSize(f.SizeBytes)
+		}
+		fmt.Println(sep)
+		fmt.Printf("Trashed file %d of %d\n", i+1, len(trash.Files))
+		fmt.Printf("  File ID:   %s\n", f.FileID)
+		fmt.Printf("  Filename:  %s\n", decryptedFilename(f.ServerFileInfo, accountKey, owner))
+		if folder := displayFolder(f.ServerFileInfo, accountKey, owner); folder != "" {
+			fmt.Printf("  Folder:    %s\n", folder)
+		}
+		fmt.Printf("  Size:      %s\n", size)
+		fmt.Printf("  Deleted:   %s\n", f.DeletedAt)
+		fmt.Printf("  Purge at:  %s\n", f.PurgeAt)
+	}
+	fmt.Println(sep)
+	fmt.Printf("Total: %d files in trash (kept for %d days)\n", len(trash.Files), trash.RetentionDays)
+	return nil
+}
+
+// handleRestoreCommand takes a file out of the trash.
+func handleRestoreCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fileID := fs.String("file-id", "", "File ID to restore from the trash")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client restore --file-id FILE_ID\n\n" +
+			"Restore a deleted file from the trash. See 'arkfile-client trash' for the\n" +
+			"files that can still be restored.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *fileID == "" {
+		return fmt.Errorf("--file-id is required")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+
+	trash, err := fetchTrash(client, session)
+	if err != nil {
+		return fmt.Errorf("failed to fetch trash: %w", err)
+	}
+	i := slices.IndexFunc(trash.Files, func(f ServerTrashInfo) bool { return f.FileID == *fileID })
+	if i < 0 {
+		return fmt.Errorf("file %s is not in the trash", *fileID)
+	}
+	restored := trash.Files[i].ServerFileInfo
+
+	if _, err := client.makeRequestWithSession("POST", "/api/files/"+*fileID+"/restore", nil, session); err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+
+	owner := restored.OwnerUsername
+	if owner == "" {
+		owner = session.Username
+	}
+	filename := "[encrypted]"
+
+	// Put the file's digest back in the agent cache that delete-file cleared
+	if agentClient, agentErr := NewAgentClient(); agentErr == nil {
+		if accountKey, keyErr := agentClient.GetAccountKey(session.AccessToken); keyErr == nil {
+			filename = decryptedFilename(restored, accountKey, owner)
+			if sha256hex, err := decryptMetadataField(restored.EncryptedSHA256, restored.SHA256Nonce,
+				accountKey, restored.FileID, crypto.AADFieldSha256, owner); err == nil {
+				if addErr := agentClient.AddDigest(restored.FileID, sha256hex); addErr != nil {
+					logVerbose("Warning: failed to add digest to cache: %v", addErr)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("File %s (%s) restored.\n", *fileID, filename)
+	return nil
+}
@@ -0,0 +1,48 @@
+// trash_test.go - Unit tests for the trash listing used by `trash` and
+// `restore`.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchTrash_DecodesListing(t *testing.T) {
+	accountKey := make([]byte, 32)
+	encName, nameNonce, _, _, err := encryptMetadata("report.pdf", "00", accountKey, testFileID, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/files/trash" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"retention_days":30,"files":[{"file_id":"` + testFileID + `","owner_username":"` + testOwner +
+			`","encrypted_filename":"` + encName + `","filename_nonce":"` + nameNonce +
+			`","size_bytes":42,"deleted_at":"2026-10-01T12:00:00Z","purge_at":"2026-10-31T12:00:00Z"}]}`))
+	}))
+	defer srv.Close()
+
+	trash, err := fetchTrash(newHTTPClient(srv.URL, false, 10, false), newTestSession("tok", "ref", 30*time.Minute))
+	if err != nil {
+		t.Fatalf("fetchTrash: %v", err)
+	}
+	if trash.RetentionDays != 30 || len(trash.Files) != 1 {
+		t.Fatalf("trash = %+v", trash)
+	}
+	f := trash.Files[0]
+	if f.FileID != testFileID || f.SizeBytes != 42 || f.PurgeAt != "2026-10-31T12:00:00Z" {
+		t.Errorf("trashed file = %+v", f)
+	}
+	if got := decryptedFilename(f.ServerFileInfo, accountKey, testOwner); got != "report.pdf" {
+		t.Errorf("filename = %q, want report.pdf", got)
+	}
+	if got := decryptedFilename(f.ServerFileInfo, nil, testOwner); got != "[encrypted]" {
+		t.Errorf("filename without key = %q", got)
+	}
+}
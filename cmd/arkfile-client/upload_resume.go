@@ -0,0 +1,339 @@
+// upload_resume.go - Local resume journals for interrupted chunked uploads.
+//
+// Every upload saves a small journal once the server has created its upload
+// session, and removes it when the upload is finalized. If the process dies
+// in between, `arkfile-client upload --resume` finds the journal, asks the
+// server which chunks it already holds (GET /api/uploads/:sessionId/status)
+// and sends only the missing ones.
+//
+// The journal never holds key material: the FEK is kept only in its wrapped
+// form (the same envelope the server stores), and is unwrapped on resume
+// with the account key or the re-entered custom password.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// uploadJournal is the local record of one in-progress chunked upload.
+//
+// FileSize and FileModTime identify the local file as it was when the upload
+// started; a file that changed since cannot be resumed, because the chunks
+// already on the server were encrypted from the old contents.
+type uploadJournal struct {
+	SessionID      string    `json:"session_id"`
+	FileID         string    `json:"file_id"`
+	ServerURL      string    `json:"server_url"`
+	Username       string    `json:"username"`
+	FilePath       string    `json:"file_path"`
+	Folder         string    `json:"folder,omitempty"`
+	VersionOf      string    `json:"version_of,omitempty"`
+	FileSize       int64     `json:"file_size"`
+	FileModTime    time.Time `json:"file_mtime"`
+	PasswordType   string    `json:"password_type"`
+	EncryptedFEK   string    `json:"encrypted_fek"`
+	ChunkCount     int64     `json:"chunk_count"`
+	ChunkSizeBytes int64     `json:"chunk_size_bytes"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// uploadSessionStatus is the server's GET /api/uploads/:sessionId/status response.
+type uploadSessionStatus struct {
+	SessionID       string  `json:"session_id"`
+	FileID          string  `json:"file_id"`
+	EncryptedSHA256 string  `json:"encrypted_sha256sum"`
+	SHA256Nonce     string  `json:"sha256sum_nonce"`
+	Status          string  `json:"status"`
+	TotalChunks     int64   `json:"total_chunks"`
+	UploadedChunks  []int64 `json:"uploaded_chunks"`
+	IsExpired       bool    `json:"is_expired"`
+}
+
+// errUploadSessionGone means the server no longer has a resumable session for
+// a journal: it was completed, canceled, failed, expired or removed.
+var errUploadSessionGone = errors.New("upload session is no longer resumable")
+
+func getUploadJournalDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ".arkfile-uploads"
+	}
+	return filepath.Join(homeDir, ".arkfile-uploads")
+}
+
+func uploadJournalPath(dir, sessionID string) string {
+	return filepath.Join(dir, sessionID+".json")
+}
+
+// saveUploadJournal writes the journal to <dir>/<session_id>.json (mode 0600)
+// via a temp file and rename, so a crash mid-write never leaves a truncated
+// journal behind.
+func saveUploadJournal(dir string, journal *uploadJournal) error {
+	if journal.SessionID == "" || strings.ContainsAny(journal.SessionID, `/\`) {
+		return fmt.Errorf("invalid upload session ID %q", journal.SessionID)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".journal.*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, werr := tmp.Write(data); werr != nil {
+		_ = tmp.Close()
+		return werr
+	}
+	if cerr := tmp.Chmod(0600); cerr != nil {
+		_ = tmp.Close()
+		return cerr
+	}
+	if cerr := tmp.Close(); cerr != nil {
+		return cerr
+	}
+	return os.Rename(tmpPath, uploadJournalPath(dir, journal.SessionID))
+}
+
+// removeUploadJournal deletes a journal. A missing journal is not an error.
+func removeUploadJournal(dir, sessionID string) {
+	if err := os.Remove(uploadJournalPath(dir, sessionID)); err != nil && !os.IsNotExist(err) {
+		logVerbose("Warning: failed to remove upload journal %s: %v", sessionID, err)
+	}
+}
+
+// loadUploadJournals returns the journals in dir that belong to serverURL and
+// username, oldest first. Unreadable journals are skipped.
+func loadUploadJournals(dir, serverURL, username string) ([]*uploadJournal, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload journals: %w", err)
+	}
+
+	var journals []*uploadJournal
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logVerbose("Warning: skipping unreadable upload journal %s: %v", entry.Name(), err)
+			continue
+		}
+		var journal uploadJournal
+		if err := json.Unmarshal(data, &journal); err != nil || journal.SessionID == "" {
+			logVerbose("Warning: skipping malformed upload journal %s", entry.Name())
+			continue
+		}
+		if journal.ServerURL != serverURL || journal.Username != username {
+			continue
+		}
+		journals = append(journals, &journal)
+	}
+	sort.Slice(journals, func(i, j int) bool {
+		return journals[i].CreatedAt.Before(journals[j].CreatedAt)
+	})
+	return journals, nil
+}
+
+// journalsForFile returns the journals of one local file, oldest first.
+func journalsForFile(journals []*uploadJournal, absPath string) []*uploadJournal {
+	var matches []*uploadJournal
+	for _, journal := range journals {
+		if journal.FilePath == absPath {
+			matches = append(matches, journal)
+		}
+	}
+	return matches
+}
+
+// checkUnchanged reports whether the local file still has the size and
+// modification time recorded when the upload started.
+func (j *uploadJournal) checkUnchanged(info os.FileInfo) error {
+	if info.Size() != j.FileSize {
+		return fmt.Errorf("file size changed from %d to %d bytes", j.FileSize, info.Size())
+	}
+	if !info.ModTime().Equal(j.FileModTime) {
+		return fmt.Errorf("file modified at %s, after the upload started", info.ModTime().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// fetchUploadStatus queries the server for the state of an upload session.
+// Returns errUploadSessionGone when the session cannot be resumed.
+func fetchUploadStatus(client *HTTPClient, session *AuthSession, sessionID string) (*uploadSessionStatus, error) {
+	if rerr := ensureFreshSessionToken(client, session, 0); rerr != nil {
+		return nil, rerr
+	}
+
+	req, err := http.NewRequest("GET", client.baseURL+"/api/uploads/"+sessionID+"/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errUploadSessionGone
+	default:
+		return nil, fmt.Errorf("upload status returned HTTP %d", resp.StatusCode)
+	}
+
+	var status uploadSessionStatus
+	if err := decodeJSONResponse(resp, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse upload status: %w", err)
+	}
+	if status.Status != "in_progress" || status.IsExpired {
+		return &status, errUploadSessionGone
+	}
+	return &status, nil
+}
+
+// discardUploadJournal cancels the journal's server session, releasing its
+// slot in the per-user in-progress upload cap, and removes the journal.
+// Cancellation is best effort: the server expires abandoned sessions anyway.
+func discardUploadJournal(client *HTTPClient, session *AuthSession, dir string, journal *uploadJournal) {
+	if _, err := client.makeRequestWithSession("DELETE", "/api/uploads/"+journal.SessionID, nil, session); err != nil {
+		logVerbose("Could not cancel upload session %s: %v", journal.SessionID, err)
+	}
+	removeUploadJournal(dir, journal.SessionID)
+}
+
+// resumeOneFile continues the upload recorded in journal, sending only the
+// chunks the server does not hold yet. Returns errUploadSessionGone (after
+// removing the journal) when the upload must start over instead.
+func resumeOneFile(client *HTTPClient, session *AuthSession, accountKey, kek []byte, finalPasswordType, journalDir string, journal *uploadJournal, parallel int) (string, error) {
+	info, err := os.Stat(journal.FilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if err := journal.checkUnchanged(info); err != nil {
+		logVerbose("Cannot resume %s: %v", journal.FilePath, err)
+		discardUploadJournal(client, session, journalDir, journal)
+		return "", errUploadSessionGone
+	}
+	if journal.PasswordType != finalPasswordType {
+		return "", fmt.Errorf("interrupted upload used a %s password; rerun with --password-type %s", journal.PasswordType, journal.PasswordType)
+	}
+
+	status, err := fetchUploadStatus(client, session, journal.SessionID)
+	if errors.Is(err, errUploadSessionGone) {
+		logVerbose("Upload session %s is no longer resumable; starting over", journal.SessionID)
+		removeUploadJournal(journalDir, journal.SessionID)
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	if status.FileID != journal.FileID || status.TotalChunks != journal.ChunkCount {
+		return "", fmt.Errorf("upload session %s does not match its journal", journal.SessionID)
+	}
+
+	fek, keyType, err := unwrapFEK(journal.EncryptedFEK, kek, journal.FileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap file key (wrong password?): %w", err)
+	}
+	defer clearBytes(fek)
+	if keyType != journal.PasswordType {
+		return "", fmt.Errorf("file key is wrapped for a %s password, journal says %s", keyType, journal.PasswordType)
+	}
+
+	uploaded := make(map[int64]bool, len(status.UploadedChunks))
+	for _, chunk := range status.UploadedChunks {
+		uploaded[chunk] = true
+	}
+	fmt.Printf("Resuming %s: %d/%d chunks already uploaded\n", filepath.Base(journal.FilePath), len(uploaded), journal.ChunkCount)
+
+	params := &ChunkedUploadParams{
+		FilePath:       journal.FilePath,
+		FileID:         journal.FileID,
+		FEK:            fek,
+		FileSizeBytes:  journal.FileSize,
+		ChunkCount:     journal.ChunkCount,
+		ChunkSizeBytes: journal.ChunkSizeBytes,
+		Parallel:       parallel,
+	}
+	if err := uploadFileChunks(client, session, params, journal.SessionID, uploaded); err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	fileID, err := finalizeChunkedUpload(client, session, journal.SessionID, journal.ChunkCount)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	removeUploadJournal(journalDir, journal.SessionID)
+
+	// Store the digest in the agent cache for dedup, as a fresh upload does.
+	// The journal does not keep the plaintext digest; the session's encrypted
+	// copy is decrypted instead of re-reading the whole file.
+	sha256hex, err := decryptMetadataField(status.EncryptedSHA256, status.SHA256Nonce, accountKey,
+		journal.FileID, crypto.AADFieldSha256, session.Username)
+	if err == nil {
+		if agentClient, agentErr := NewAgentClient(); agentErr == nil {
+			if err := agentClient.AddDigest(fileID, sha256hex); err != nil {
+				logVerbose("Warning: failed to store digest in cache: %v", err)
+			}
+		}
+	}
+	return fileID, nil
+}
+
+// resumeOrUploadFile resumes the newest interrupted upload of filePath, or
+// uploads it from scratch when there is none, it cannot be resumed or it was
+// started for a different folder. Older journals of the same file are
+// superseded and discarded. An upload started as a new version of a file
+// is uploaded again as a new version of that file.
+func resumeOrUploadFile(client *HTTPClient, session *AuthSession, config *ClientConfig, accountKey, kek []byte, finalPasswordType, hint, filePath, folder string, force bool, parallel int, journals []*uploadJournal) (string, error) {
+	journalDir := getUploadJournalDir()
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	matches := journalsForFile(journals, absPath)
+	versionOf := ""
+	if len(matches) > 0 {
+		versionOf = matches[len(matches)-1].VersionOf
+	}
+	if len(matches) > 0 && matches[len(matches)-1].Folder != folder {
+		// The session already holds the old encrypted folder; start over
+		// so the file lands where it was asked to go.
+		logVerbose("Interrupted upload of %s targets folder %q; uploading again", filepath.Base(filePath), matches[len(matches)-1].Folder)
+		matches = nil
+	}
+	if len(matches) > 0 {
+		for _, stale := range matches[:len(matches)-1] {
+			discardUploadJournal(client, session, journalDir, stale)
+		}
+		fileID, err := resumeOneFile(client, session, accountKey, kek, finalPasswordType, journalDir, matches[len(matches)-1], parallel)
+		if !errors.Is(err, errUploadSessionGone) {
+			return fileID, err
+		}
+	}
+	return uploadOneFile(client, session, config, accountKey, kek, finalPasswordType, hint, filePath, folder, versionOf, force, parallel)
+}
@@ -0,0 +1,254 @@
+// upload_resume_test.go - Unit tests for resumable uploads: the local
+// resume journal, the upload status query, and sending only missing chunks.
+//
+// Network-touching tests use httptest.NewServer so they remain
+// deterministic and offline-safe.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestJournal(sessionID, filePath string, createdAt time.Time) *uploadJournal {
+	return &uploadJournal{
+		SessionID:      sessionID,
+		FileID:         "f1f1f1f1-2222-4333-8444-555555555555",
+		ServerURL:      "https://vault.example",
+		Username:       "testuser",
+		FilePath:       filePath,
+		FileSize:       10,
+		FileModTime:    time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		PasswordType:   "account",
+		EncryptedFEK:   "ZW5jcnlwdGVkLWZlaw==",
+		ChunkCount:     3,
+		ChunkSizeBytes: 4,
+		CreatedAt:      createdAt,
+	}
+}
+
+func TestUploadJournal_SaveLoadRemove(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	now := time.Now().UTC()
+
+	newer := newTestJournal("session-b", "/data/a.bin", now)
+	older := newTestJournal("session-a", "/data/a.bin", now.Add(-time.Hour))
+	other := newTestJournal("session-c", "/data/c.bin", now)
+	otherUser := newTestJournal("session-d", "/data/a.bin", now)
+	otherUser.Username = "someoneelse"
+	for _, j := range []*uploadJournal{newer, older, other, otherUser} {
+		if err := saveUploadJournal(dir, j); err != nil {
+			t.Fatalf("saveUploadJournal(%s): %v", j.SessionID, err)
+		}
+	}
+
+	info, err := os.Stat(uploadJournalPath(dir, "session-a"))
+	if err != nil {
+		t.Fatalf("stat journal: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("journal mode = %o, want 0600", info.Mode().Perm())
+	}
+
+	journals, err := loadUploadJournals(dir, "https://vault.example", "testuser")
+	if err != nil {
+		t.Fatalf("loadUploadJournals: %v", err)
+	}
+	if len(journals) != 3 {
+		t.Fatalf("expected 3 journals for testuser, got %d", len(journals))
+	}
+	if !journals[0].FileModTime.Equal(older.FileModTime) {
+		t.Errorf("mtime did not round-trip: got %v, want %v", journals[0].FileModTime, older.FileModTime)
+	}
+
+	matches := journalsForFile(journals, "/data/a.bin")
+	if len(matches) != 2 || matches[0].SessionID != "session-a" || matches[1].SessionID != "session-b" {
+		t.Fatalf("journalsForFile should return both journals oldest first, got %+v", matches)
+	}
+
+	removeUploadJournal(dir, "session-a")
+	removeUploadJournal(dir, "session-a") // already gone: no-op
+	journals, _ = loadUploadJournals(dir, "https://vault.example", "testuser")
+	if len(journals) != 2 {
+		t.Errorf("expected 2 journals after removal, got %d", len(journals))
+	}
+}
+
+func TestUploadJournal_LoadMissingDirAndMalformed(t *testing.T) {
+	dir := t.TempDir()
+	journals, err := loadUploadJournals(filepath.Join(dir, "missing"), "https://vault.example", "testuser")
+	if err != nil || journals != nil {
+		t.Fatalf("missing dir should yield no journals and no error, got %v, %v", journals, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journals, err = loadUploadJournals(dir, "https://vault.example", "testuser")
+	if err != nil || len(journals) != 0 {
+		t.Errorf("malformed journal should be skipped, got %v, %v", journals, err)
+	}
+
+	if err := saveUploadJournal(dir, newTestJournal("../escape", "/x", time.Now())); err == nil {
+		t.Error("session IDs containing path separators must be rejected")
+	}
+}
+
+func TestUploadJournal_CheckUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	j := newTestJournal("s", path, time.Now())
+	j.FileModTime = info.ModTime()
+	if err := j.checkUnchanged(info); err != nil {
+		t.Errorf("unchanged file reported as changed: %v", err)
+	}
+
+	j.FileModTime = info.ModTime().Add(-time.Second)
+	if err := j.checkUnchanged(info); err == nil {
+		t.Error("expected a modification-time mismatch")
+	}
+
+	j.FileModTime = info.ModTime()
+	j.FileSize = 11
+	if err := j.checkUnchanged(info); err == nil {
+		t.Error("expected a size mismatch")
+	}
+}
+
+func TestFetchUploadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/uploads/live/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"session_id":      "live",
+				"file_id":         "file-1",
+				"status":          "in_progress",
+				"total_chunks":    3,
+				"uploaded_chunks": []int{0, 2},
+				"is_expired":      false,
+			})
+		case "/api/uploads/done/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "completed", "total_chunks": 3})
+		case "/api/uploads/stale/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "in_progress", "is_expired": true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	session := newTestSession("tok", "ref", 30*time.Minute)
+
+	status, err := fetchUploadStatus(client, session, "live")
+	if err != nil {
+		t.Fatalf("fetchUploadStatus: %v", err)
+	}
+	if status.TotalChunks != 3 || len(status.UploadedChunks) != 2 || status.UploadedChunks[1] != 2 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	for _, id := range []string{"done", "stale", "missing"} {
+		if _, err := fetchUploadStatus(client, session, id); !errors.Is(err, errUploadSessionGone) {
+			t.Errorf("%s: expected errUploadSessionGone, got %v", id, err)
+		}
+	}
+}
+
+func TestUploadFileChunks_SendsOnlyMissingChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	session := newTestSession("tok", "ref", 30*time.Minute)
+	params := &ChunkedUploadParams{
+		FilePath:       path,
+		FileID:         "f1f1f1f1-2222-4333-8444-555555555555",
+		FEK:            make([]byte, 32),
+		FileSizeBytes:  10,
+		ChunkCount:     3,
+		ChunkSizeBytes: 4,
+	}
+
+	if err := uploadFileChunks(client, session, params, "sess", map[int64]bool{0: true}); err != nil {
+		t.Fatalf("uploadFileChunks: %v", err)
+	}
+	sort.Strings(received)
+	want := []string{"/api/uploads/sess/chunks/1", "/api/uploads/sess/chunks/2"}
+	if strings.Join(received, ",") != strings.Join(want, ",") {
+		t.Errorf("chunks sent = %v, want %v", received, want)
+	}
+
+	// A file truncated since the upload started is detected, not padded out
+	if err := os.WriteFile(path, []byte("01234"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := uploadFileChunks(client, session, params, "sess", map[int64]bool{0: true})
+	if err == nil || !strings.Contains(err.Error(), "file changed") {
+		t.Errorf("expected file-changed error, got %v", err)
+	}
+}
+
+func TestResumeOneFile_ChangedFileStartsOver(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			canceled = r.URL.Path
+		}
+		fmt.Fprint(w, `{"success":true}`)
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	session := newTestSession("tok", "ref", 30*time.Minute)
+	journalDir := filepath.Join(dir, "journals")
+	journal := newTestJournal("sess-old", path, time.Now())
+	journal.FileModTime = time.Now().Add(-24 * time.Hour)
+	if err := saveUploadJournal(journalDir, journal); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := resumeOneFile(client, session, make([]byte, 32), make([]byte, 32), "account", journalDir, journal, 1)
+	if !errors.Is(err, errUploadSessionGone) {
+		t.Fatalf("expected errUploadSessionGone for a modified file, got %v", err)
+	}
+	if canceled != "/api/uploads/sess-old" {
+		t.Errorf("stale server session should be canceled, got %q", canceled)
+	}
+	if _, err := os.Stat(uploadJournalPath(journalDir, "sess-old")); !os.IsNotExist(err) {
+		t.Errorf("stale journal should be removed, stat err = %v", err)
+	}
+}
@@ -0,0 +1,546 @@
+// user_shares.go - Sharing files directly with another Arkfile user.
+//
+// `user-key create` generates a hybrid X25519 + ML-KEM-768 key pair, wraps
+// the private key with the account key and publishes the public key so
+// others can share with you. `share with` unwraps a file's FEK on this
+// machine and seals it to the recipient's public key, along with a copy of
+// the filename and SHA-256; no share password is involved. Recipients list
+// such files with `share with-me` and download them with `share fetch`.
+// Compare key fingerprints out of band before sharing: the server could
+// otherwise serve a key of its own.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// userShareFile is one entry of GET /api/shared-with-me.
+type userShareFile struct {
+	ShareID           string `json:"share_id"`
+	FileID            string `json:"file_id"`
+	OwnerUsername     string `json:"owner_username"`
+	EncryptedFEK      string `json:"encrypted_fek"`
+	EncryptedFilename string `json:"encrypted_filename"`
+	FilenameNonce     string `json:"filename_nonce"`
+	EncryptedSHA256   string `json:"encrypted_sha256sum"`
+	SHA256Nonce       string `json:"sha256sum_nonce"`
+	SharedAt          string `json:"shared_at"`
+	SizeBytes         int64  `json:"size_bytes"`
+	ChunkCount        int64  `json:"chunk_count"`
+	ChunkSizeBytes    int64  `json:"chunk_size_bytes"`
+}
+
+// userShareSummary is one entry of GET /api/user-shares.
+type userShareSummary struct {
+	ShareID           string `json:"share_id"`
+	FileID            string `json:"file_id"`
+	RecipientUsername string `json:"recipient_username"`
+	CreatedAt         string `json:"created_at"`
+}
+
+func handleUserKeyCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("subcommand required: create, show")
+	}
+
+	switch args[0] {
+	case "create":
+		return handleUserKeyCreate(client, config, args[1:])
+	case "show":
+		return handleUserKeyShow(client, config, args[1:])
+	default:
+		return fmt.Errorf("unknown user-key subcommand: %s (use create or show)", args[0])
+	}
+}
+
+func handleUserKeyCreate(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("user-key create", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client user-key create\n\n" +
+			"Create the key other users share files with you to. The private key is\n" +
+			"encrypted with your account key before it leaves this machine.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	key, err := crypto.GenerateHybridKey()
+	if err != nil {
+		return err
+	}
+	wrapped, err := crypto.WrapUserPrivateKey(key, accountKey, session.Username)
+	if err != nil {
+		return err
+	}
+
+	var created map[string]interface{}
+	if err := ownerJSONRequest(client, session, "PUT", "/api/user/encryption-key", map[string]interface{}{
+		"public_key":            base64.StdEncoding.EncodeToString(key.PublicKey()),
+		"encrypted_private_key": base64.StdEncoding.EncodeToString(wrapped),
+	}, &created); err != nil {
+		return fmt.Errorf("failed to store encryption key: %w", err)
+	}
+
+	fmt.Printf("Encryption key created\n")
+	fmt.Printf("Fingerprint: %s\n", crypto.HybridKeyFingerprint(key.PublicKey()))
+	fmt.Printf("\nGive this fingerprint to people who share files with you so they can check it.\n")
+	return nil
+}
+
+func handleUserKeyShow(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("user-key show", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	key, err := loadUserKey(client, session, accountKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Username: %s\n", session.Username)
+	fmt.Printf("Fingerprint: %s\n", crypto.HybridKeyFingerprint(key.PublicKey()))
+	return nil
+}
+
+// loadUserKey fetches and unwraps the user's own private key, checking that
+// it matches the public key the server hands out for them.
+func loadUserKey(client *HTTPClient, session *AuthSession, accountKey []byte) (*crypto.HybridPrivateKey, error) {
+	var stored struct {
+		PublicKey           string `json:"public_key"`
+		EncryptedPrivateKey string `json:"encrypted_private_key"`
+	}
+	if err := ownerJSONRequest(client, session, "GET", "/api/user/encryption-key", nil, &stored); err != nil {
+		return nil, fmt.Errorf("failed to fetch your encryption key (run 'user-key create' first): %w", err)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(stored.EncryptedPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key encoding: %w", err)
+	}
+	key, err := crypto.UnwrapUserPrivateKey(wrapped, accountKey, session.Username)
+	if err != nil {
+		return nil, err
+	}
+	if base64.StdEncoding.EncodeToString(key.PublicKey()) != stored.PublicKey {
+		return nil, fmt.Errorf("the server's copy of your public key does not match your private key")
+	}
+	return key, nil
+}
+
+// fetchUserPublicKey fetches a user's public key. If fingerprint is set the
+// key must match it.
+func fetchUserPublicKey(client *HTTPClient, session *AuthSession, username, fingerprint string) ([]byte, error) {
+	var served struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := ownerJSONRequest(client, session, "GET", "/api/users/"+username+"/public-key", nil, &served); err != nil {
+		return nil, fmt.Errorf("failed to fetch the public key of %s: %w", username, err)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(served.PublicKey)
+	if err != nil || len(publicKey) != crypto.HybridPublicKeySize {
+		return nil, fmt.Errorf("server returned an invalid public key for %s", username)
+	}
+	if fingerprint != "" && crypto.HybridKeyFingerprint(publicKey) != fingerprint {
+		return nil, fmt.Errorf("the key of %s does not match fingerprint %s; refusing to share", username, fingerprint)
+	}
+	return publicKey, nil
+}
+
+func handleShareWith(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share with", flag.ExitOnError)
+	fileID := fs.String("file-id", "", "File ID to share")
+	recipient := fs.String("user", "", "Username to share the file with")
+	fingerprint := fs.String("fingerprint", "", "Expected fingerprint of the recipient's key")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client share with --file-id FILE_ID --user USERNAME [--fingerprint FP]\n\n" +
+			"Share a file with another Arkfile user without a share password. The file key\n" +
+			"is encrypted on this machine to the recipient's public key. Pass the fingerprint\n" +
+			"the recipient gave you ('user-key show') to make sure the key is theirs.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fileID == "" || *recipient == "" {
+		return fmt.Errorf("--file-id and --user are required")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	if *recipient == session.Username {
+		return fmt.Errorf("cannot share a file with yourself")
+	}
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+
+	publicKey, err := fetchUserPublicKey(client, session, *recipient, *fingerprint)
+	if err != nil {
+		return err
+	}
+	meta, err := fetchFileMeta(client, session, *fileID)
+	if err != nil {
+		return err
+	}
+
+	var kek []byte
+	switch meta.PasswordType {
+	case "account", "":
+		kek = accountKey
+	case "custom":
+		customPass, err := readPassword("Enter custom password for this file: ")
+		if err != nil {
+			return fmt.Errorf("failed to read custom password: %w", err)
+		}
+		defer clearBytes(customPass)
+		kek = crypto.DeriveCustomPasswordKey(customPass, config.Username)
+		defer clearBytes(kek)
+	default:
+		return fmt.Errorf("cannot share a file with password type %s (accept file drop uploads first)", meta.PasswordType)
+	}
+	fek, _, err := unwrapFEK(meta.EncryptedFEK, kek, *fileID)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap FEK (wrong password?): %w", err)
+	}
+	defer clearBytes(fek)
+
+	payload, filename, err := sealUserShare(*meta, fek, accountKey, *recipient, publicKey)
+	if err != nil {
+		return err
+	}
+	var created struct {
+		ShareID string `json:"share_id"`
+	}
+	if err := ownerJSONRequest(client, session, "POST", "/api/user-shares", payload, &created); err != nil {
+		return fmt.Errorf("failed to share file: %w", err)
+	}
+
+	fmt.Printf("Shared %s with %s\n", filename, *recipient)
+	fmt.Printf("Share ID: %s\n", created.ShareID)
+	if *fingerprint == "" {
+		fmt.Printf("[!] Recipient key fingerprint %s was not checked; compare it with %s\n",
+			crypto.HybridKeyFingerprint(publicKey), *recipient)
+	}
+	fmt.Printf("Revoke with: arkfile-client share unshare --id %s\n", created.ShareID)
+	return nil
+}
+
+// sealUserShare returns the POST /api/user-shares body sharing a file with
+// recipient: the FEK sealed to their public key and the file's name and
+// SHA-256 re-encrypted for them. It also returns the filename.
+func sealUserShare(meta ServerFileInfo, fek, accountKey []byte, recipient string, publicKey []byte) (map[string]interface{}, string, error) {
+	filename, err := decryptMetadataField(meta.EncryptedFilename, meta.FilenameNonce, accountKey,
+		meta.FileID, crypto.AADFieldFilename, meta.OwnerUsername)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt filename: %w", err)
+	}
+	sha256hex, err := decryptMetadataField(meta.EncryptedSHA256, meta.SHA256Nonce, accountKey,
+		meta.FileID, crypto.AADFieldSha256, meta.OwnerUsername)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt SHA-256: %w", err)
+	}
+
+	envelope, metadataKey, err := crypto.SealUserShareFEK(publicKey, meta.FileID, fek)
+	if err != nil {
+		return nil, "", err
+	}
+	defer clearBytes(metadataKey)
+	encFilenameB64, fnNonceB64, encSHA256B64, shaNonceB64, err :=
+		encryptMetadata(filename, sha256hex, metadataKey, meta.FileID, meta.OwnerUsername)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+
+	return map[string]interface{}{
+		"file_id":             meta.FileID,
+		"recipient_username":  recipient,
+		"encrypted_fek":       base64.StdEncoding.EncodeToString(envelope),
+		"encrypted_filename":  encFilenameB64,
+		"filename_nonce":      fnNonceB64,
+		"encrypted_sha256sum": encSHA256B64,
+		"sha256sum_nonce":     shaNonceB64,
+	}, filename, nil
+}
+
+// openUserShare opens a file shared with the user and returns its FEK,
+// filename and SHA-256. The caller clears the FEK.
+func openUserShare(f userShareFile, key *crypto.HybridPrivateKey) (fek []byte, filename, sha256hex string, err error) {
+	envelope, err := base64.StdEncoding.DecodeString(f.EncryptedFEK)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid FEK encoding: %w", err)
+	}
+	fek, metadataKey, err := crypto.OpenUserShareFEK(key, f.FileID, envelope)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer clearBytes(metadataKey)
+
+	filename, err = decryptMetadataField(f.EncryptedFilename, f.FilenameNonce, metadataKey, f.FileID, crypto.AADFieldFilename, f.OwnerUsername)
+	if err == nil {
+		sha256hex, err = decryptMetadataField(f.EncryptedSHA256, f.SHA256Nonce, metadataKey, f.FileID, crypto.AADFieldSha256, f.OwnerUsername)
+	}
+	if err != nil {
+		clearBytes(fek)
+		return nil, "", "", fmt.Errorf("failed to decrypt metadata: %w", err)
+	}
+	if !isPlainFileName(filename) {
+		// The owner chose the name; keep it from naming a path
+		filename = strings.NewReplacer("/", "_", "\\", "_").Replace(filename)
+	}
+	return fek, filename, sha256hex, nil
+}
+
+func fetchSharedWithMe(client *HTTPClient, session *AuthSession) ([]userShareFile, error) {
+	var list struct {
+		Files []userShareFile `json:"files"`
+	}
+	if err := ownerJSONRequest(client, session, "GET", "/api/shared-with-me", nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to list files shared with you: %w", err)
+	}
+	return list.Files, nil
+}
+
+func handleShareWithMe(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share with-me", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	files, err := fetchSharedWithMe(client, session)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		if *jsonOutput {
+			fmt.Println(`{"files":[]}`)
+		} else {
+			fmt.Println("No files have been shared with you.")
+		}
+		return nil
+	}
+
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+	key, err := loadUserKey(client, session, accountKey)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		ShareID       string `json:"share_id"`
+		FileID        string `json:"file_id"`
+		Filename      string `json:"filename"`
+		OwnerUsername string `json:"owner_username"`
+		SizeBytes     int64  `json:"size_bytes"`
+		SharedAt      string `json:"shared_at"`
+	}
+	entries := make([]entry, 0, len(files))
+	for _, f := range files {
+		filename := "[undecryptable]"
+		if fek, name, _, err := openUserShare(f, key); err == nil {
+			clearBytes(fek)
+			filename = name
+		} else {
+			logVerbose("Share %s: %v", f.ShareID, err)
+		}
+		entries = append(entries, entry{f.ShareID, f.FileID, filename, f.OwnerUsername, f.SizeBytes, f.SharedAt})
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"files": entries})
+	}
+	fmt.Printf("%-44s %-30s %-20s %10s\n", "SHARE ID", "FILENAME", "FROM", "SIZE")
+	for _, e := range entries {
+		fmt.Printf("%-44s %-30s %-20s %10s\n", e.ShareID, e.Filename, e.OwnerUsername, formatFileSize(e.SizeBytes))
+	}
+	return nil
+}
+
+func handleShareFetch(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share fetch", flag.ExitOnError)
+	shareID := fs.String("id", "", "Share ID from 'share with-me'")
+	outputPath := fs.String("output", "", "Output file path (default: the shared filename)")
+	parallel := fs.Int("parallel", 1, fmt.Sprintf("Number of chunks to download and decrypt concurrently (1-%d)", maxParallelChunks))
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client share fetch --id SHARE_ID [--output PATH] [--parallel N]\n\n" +
+			"Download and decrypt a file another user shared with you. The file's SHA-256\n" +
+			"is verified before it is saved; an existing file is never overwritten.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shareID == "" {
+		return fmt.Errorf("--id is required")
+	}
+	if *parallel < 1 || *parallel > maxParallelChunks {
+		return fmt.Errorf("--parallel must be between 1 and %d", maxParallelChunks)
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	files, err := fetchSharedWithMe(client, session)
+	if err != nil {
+		return err
+	}
+	var shared *userShareFile
+	for i := range files {
+		if files[i].ShareID == *shareID {
+			shared = &files[i]
+			break
+		}
+	}
+	if shared == nil {
+		return fmt.Errorf("no file shared with you has share ID %s", *shareID)
+	}
+
+	accountKey, err := requireAccountKey()
+	if err != nil {
+		return err
+	}
+	defer clearBytes(accountKey)
+	key, err := loadUserKey(client, session, accountKey)
+	if err != nil {
+		return err
+	}
+	fek, filename, sha256hex, err := openUserShare(*shared, key)
+	if err != nil {
+		return err
+	}
+	defer clearBytes(fek)
+
+	dest := *outputPath
+	if dest == "" {
+		dest = filename
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	meta := ServerFileInfo{
+		FileID:         shared.FileID,
+		OwnerUsername:  shared.OwnerUsername,
+		SizeBytes:      shared.SizeBytes,
+		ChunkCount:     shared.ChunkCount,
+		ChunkSizeBytes: shared.ChunkSizeBytes,
+	}
+	logVerbose("Downloading %s (%s) from %s...", filename, formatFileSize(shared.SizeBytes), shared.OwnerUsername)
+	if err := downloadToVerifiedFile(client, session, fek, meta, sha256hex, dest, *parallel); err != nil {
+		return err
+	}
+
+	fmt.Printf("Download complete!\n")
+	fmt.Printf("  Saved to: %s\n", dest)
+	fmt.Printf("  Shared by: %s\n", shared.OwnerUsername)
+	fmt.Printf("  [OK] SHA-256 verified: %s\n", sha256hex)
+	return nil
+}
+
+func handleShareSent(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share sent", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	var list struct {
+		Shares []userShareSummary `json:"shares"`
+	}
+	if err := ownerJSONRequest(client, session, "GET", "/api/user-shares", nil, &list); err != nil {
+		return fmt.Errorf("failed to list your user shares: %w", err)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	if len(list.Shares) == 0 {
+		fmt.Println("You have not shared any files with other users.")
+		return nil
+	}
+	fmt.Printf("%-44s %-38s %-20s %s\n", "SHARE ID", "FILE ID", "RECIPIENT", "CREATED")
+	for _, s := range list.Shares {
+		fmt.Printf("%-44s %-38s %-20s %s\n", s.ShareID, s.FileID, s.RecipientUsername, s.CreatedAt)
+	}
+	return nil
+}
+
+func handleShareUnshare(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("share unshare", flag.ExitOnError)
+	shareID := fs.String("id", "", "Share ID to remove")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client share unshare --id SHARE_ID\n\n" +
+			"Stop sharing a file with another user, or remove a file someone shared with you.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shareID == "" {
+		return fmt.Errorf("--id is required")
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	if err := ownerJSONRequest(client, session, "DELETE", "/api/user-shares/"+*shareID, nil, &result); err != nil {
+		return fmt.Errorf("failed to remove share: %w", err)
+	}
+	fmt.Printf("Share %s removed\n", *shareID)
+	return nil
+}
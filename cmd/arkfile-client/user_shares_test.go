@@ -0,0 +1,106 @@
+// user_shares_test.go - Unit tests for sharing files directly with another
+// user.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+func TestUserShare_SealAndOpen(t *testing.T) {
+	accountKey := bytes.Repeat([]byte{0x01}, 32)
+	encName, nameNonce, encSHA, shaNonce, err := encryptMetadata("contract.pdf", "abc123", accountKey, testFileID, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ServerFileInfo{
+		FileID:            testFileID,
+		OwnerUsername:     testOwner,
+		EncryptedFilename: encName,
+		FilenameNonce:     nameNonce,
+		EncryptedSHA256:   encSHA,
+		SHA256Nonce:       shaNonce,
+	}
+	recipient, err := crypto.GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fek := bytes.Repeat([]byte{0x42}, 32)
+
+	payload, filename, err := sealUserShare(meta, fek, accountKey, "bob", recipient.PublicKey())
+	if err != nil {
+		t.Fatalf("sealUserShare: %v", err)
+	}
+	if filename != "contract.pdf" || payload["recipient_username"] != "bob" || payload["file_id"] != testFileID {
+		t.Fatalf("filename %q, payload %v", filename, payload)
+	}
+
+	shared := userShareFile{
+		FileID:            testFileID,
+		OwnerUsername:     testOwner,
+		EncryptedFEK:      payload["encrypted_fek"].(string),
+		EncryptedFilename: payload["encrypted_filename"].(string),
+		FilenameNonce:     payload["filename_nonce"].(string),
+		EncryptedSHA256:   payload["encrypted_sha256sum"].(string),
+		SHA256Nonce:       payload["sha256sum_nonce"].(string),
+	}
+	gotFEK, gotName, gotSHA, err := openUserShare(shared, recipient)
+	if err != nil {
+		t.Fatalf("openUserShare: %v", err)
+	}
+	if !bytes.Equal(gotFEK, fek) || gotName != "contract.pdf" || gotSHA != "abc123" {
+		t.Errorf("opened %x %q %q", gotFEK, gotName, gotSHA)
+	}
+
+	// The recipient's copy is bound to the owner and only their key opens it
+	other, _ := crypto.GenerateHybridKey()
+	if _, _, _, err := openUserShare(shared, other); err == nil {
+		t.Error("expected an error for a different recipient key")
+	}
+	spoofed := shared
+	spoofed.OwnerUsername = "mallory"
+	if _, _, _, err := openUserShare(spoofed, recipient); err == nil {
+		t.Error("expected an error for a different owner")
+	}
+}
+
+func TestFetchUserPublicKey_Fingerprint(t *testing.T) {
+	key, err := crypto.GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/users/bob/public-key" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"public_key": base64.StdEncoding.EncodeToString(key.PublicKey()),
+		})
+	}))
+	defer srv.Close()
+	client := newHTTPClient(srv.URL, false, 10, false)
+	session := newTestSession("tok", "ref", time.Hour)
+
+	got, err := fetchUserPublicKey(client, session, "bob", crypto.HybridKeyFingerprint(key.PublicKey()))
+	if err != nil || !bytes.Equal(got, key.PublicKey()) {
+		t.Fatalf("fetchUserPublicKey: %v", err)
+	}
+	if _, err := fetchUserPublicKey(client, session, "bob", ""); err != nil {
+		t.Errorf("unchecked fetch: %v", err)
+	}
+	if _, err := fetchUserPublicKey(client, session, "bob", "wrong"); err == nil {
+		t.Error("expected a fingerprint mismatch")
+	}
+	if _, err := fetchUserPublicKey(client, session, "carol", ""); err == nil {
+		t.Error("expected an error for a user without a key")
+	}
+}
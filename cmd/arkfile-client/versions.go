@@ -0,0 +1,163 @@
+// versions.go - Client side of file version chains.
+//
+// `upload --version-of FILE_ID` stores a file as the next version of an
+// existing file instead of as an unrelated new one. Every version is a
+// complete file with its own file_id and FEK; the server links them by
+// version_group (the first version's file_id) and numbers them from 1.
+// Listings show the newest version of each file unless `list-files
+// --versions` asks for all of them, and `download --version N` fetches an
+// older one. Old versions use storage quota until deleted or pruned by the
+// retention setting (`version-retention --keep N`).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+)
+
+// versionGroupOf returns the identifier of a file's version chain: its
+// version_group, or its own file_id if it was never versioned.
+func versionGroupOf(f ServerFileInfo) string {
+	if f.VersionGroup != "" {
+		return f.VersionGroup
+	}
+	return f.FileID
+}
+
+// resolveFileVersion returns the file_id of version n of the chain that
+// fileID belongs to. fileID may name any version of the file.
+func resolveFileVersion(files []ServerFileInfo, fileID string, n int64) (string, error) {
+	i := slices.IndexFunc(files, func(f ServerFileInfo) bool { return f.FileID == fileID })
+	if i < 0 {
+		return "", fmt.Errorf("file %s not found", fileID)
+	}
+	group := versionGroupOf(files[i])
+	var stored []int64
+	for _, f := range files {
+		if versionGroupOf(f) != group {
+			continue
+		}
+		if max(f.VersionNumber, 1) == n {
+			return f.FileID, nil
+		}
+		stored = append(stored, max(f.VersionNumber, 1))
+	}
+	slices.Sort(stored)
+	return "", fmt.Errorf("file %s has no version %d (stored versions: %v)", fileID, n, stored)
+}
+
+// groupFileVersions orders files so the versions of each file are adjacent,
+// newest first. Chains keep the order in which their first entry appears.
+func groupFileVersions(files []ServerFileInfo) []ServerFileInfo {
+	var order []string
+	chains := make(map[string][]ServerFileInfo)
+	for _, f := range files {
+		group := versionGroupOf(f)
+		if _, ok := chains[group]; !ok {
+			order = append(order, group)
+		}
+		chains[group] = append(chains[group], f)
+	}
+	grouped := make([]ServerFileInfo, 0, len(files))
+	for _, group := range order {
+		chain := chains[group]
+		slices.SortStableFunc(chain, func(a, b ServerFileInfo) int {
+			return int(b.VersionNumber - a.VersionNumber)
+		})
+		grouped = append(grouped, chain...)
+	}
+	return grouped
+}
+
+// currentVersions maps each version chain in files to its highest version
+// number.
+func currentVersions(files []ServerFileInfo) map[string]int64 {
+	current := make(map[string]int64)
+	for _, f := range files {
+		group := versionGroupOf(f)
+		current[group] = max(current[group], f.VersionNumber)
+	}
+	return current
+}
+
+// versionTargetFolder returns the decrypted folder of the file a new version
+// is uploaded for, so that by default the new version stays where the file
+// is.
+func versionTargetFolder(client *HTTPClient, session *AuthSession, accountKey []byte, fileID string) (string, error) {
+	files, err := fetchAllFileVersions(client, session)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch file list: %w", err)
+	}
+	i := slices.IndexFunc(files, func(f ServerFileInfo) bool { return f.FileID == fileID })
+	if i < 0 {
+		return "", fmt.Errorf("file %s not found", fileID)
+	}
+	owner := files[i].OwnerUsername
+	if owner == "" {
+		owner = session.Username
+	}
+	folder, err := decryptFileFolder(files[i], accountKey, owner)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt the folder of %s (use --folder): %w", fileID, err)
+	}
+	return folder, nil
+}
+
+// handleVersionRetentionCommand shows or sets how many versions of each
+// file the server keeps.
+func handleVersionRetentionCommand(client *HTTPClient, config *ClientConfig, args []string) error {
+	fs := flag.NewFlagSet("version-retention", flag.ExitOnError)
+	keep := fs.Int("keep", -1, "Number of versions of each file to keep (0 keeps all)")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage: arkfile-client version-retention [--keep N]\n\n" +
+			"Show or set how many versions of each file the server keeps. When a new version\n" +
+			"is uploaded with 'upload --version-of', the oldest versions beyond N are deleted.\n" +
+			"Setting N prunes every file that already has more than N versions. Pruned versions\n" +
+			"go to the trash and can be restored until it is purged (see 'trash'). 0 keeps all\n" +
+			"versions (the default). Stored versions count toward your storage quota.\n")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := requireSession(config)
+	if err != nil {
+		return err
+	}
+
+	if *keep < 0 {
+		resp, err := client.makeRequestWithSession("GET", "/api/user/version-retention", nil, session)
+		if err != nil {
+			return fmt.Errorf("failed to get version retention: %w", err)
+		}
+		value, _ := resp.Data["version_retention"].(float64)
+		printVersionRetention(int(value))
+		return nil
+	}
+
+	resp, err := client.makeRequestWithSession("PUT", "/api/user/version-retention",
+		map[string]interface{}{"version_retention": *keep}, session)
+	if err != nil {
+		return fmt.Errorf("failed to set version retention: %w", err)
+	}
+	printVersionRetention(*keep)
+	if pruned, _ := resp.Data["pruned_versions"].(float64); pruned > 0 {
+		fmt.Printf("Deleted %d old version(s).\n", int(pruned))
+	}
+	return nil
+}
+
+func printVersionRetention(keep int) {
+	switch keep {
+	case 0:
+		fmt.Println("Keeping all versions of each file.")
+	case 1:
+		fmt.Println("Keeping only the newest version of each file.")
+	default:
+		fmt.Printf("Keeping the newest %d versions of each file.\n", keep)
+	}
+}
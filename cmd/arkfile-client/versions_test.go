@@ -0,0 +1,106 @@
+// versions_test.go - Unit tests for file version chains on the client:
+// resolving `download --version N`, grouping `list-files --versions`, and
+// sending version_of when uploading a new version.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// versionedFiles is a listing in the server's order (newest upload first):
+// a chain doc-1 <- doc-2 <- doc-4 (version 3 was pruned) and an unversioned
+// file.
+func versionedFiles() []ServerFileInfo {
+	return []ServerFileInfo{
+		{FileID: "doc-4", VersionGroup: "doc-1", VersionNumber: 4, VersionCount: 3},
+		{FileID: "solo", VersionNumber: 1, VersionCount: 1},
+		{FileID: "doc-1", VersionGroup: "doc-1", VersionNumber: 1, VersionCount: 3},
+		{FileID: "doc-2", VersionGroup: "doc-1", VersionNumber: 2, VersionCount: 3},
+	}
+}
+
+func TestResolveFileVersion(t *testing.T) {
+	files := versionedFiles()
+	for _, tc := range []struct {
+		fileID string
+		n      int64
+		want   string
+	}{
+		{"doc-4", 2, "doc-2"},
+		{"doc-1", 4, "doc-4"},
+		{"doc-2", 1, "doc-1"},
+		{"solo", 1, "solo"},
+	} {
+		got, err := resolveFileVersion(files, tc.fileID, tc.n)
+		if err != nil || got != tc.want {
+			t.Errorf("resolveFileVersion(%s, %d) = %q, %v; want %q", tc.fileID, tc.n, got, err, tc.want)
+		}
+	}
+
+	if _, err := resolveFileVersion(files, "doc-4", 3); err == nil || !strings.Contains(err.Error(), "[1 2 4]") {
+		t.Errorf("pruned version: %v, want an error listing the stored versions", err)
+	}
+	if _, err := resolveFileVersion(files, "missing", 1); err == nil {
+		t.Error("unknown file must fail")
+	}
+}
+
+func TestGroupFileVersions(t *testing.T) {
+	files := versionedFiles()
+	var order []string
+	for _, f := range groupFileVersions(files) {
+		order = append(order, f.FileID)
+	}
+	if got := strings.Join(order, " "); got != "doc-4 doc-2 doc-1 solo" {
+		t.Errorf("grouped order %s", got)
+	}
+
+	current := currentVersions(files)
+	if current["doc-1"] != 4 || current["solo"] != 1 {
+		t.Errorf("current versions %v", current)
+	}
+}
+
+func TestDoChunkedUpload_SendsVersionOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("second draft"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var init map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/uploads/init":
+			json.NewDecoder(r.Body).Decode(&init)
+			w.Write([]byte(`{"success":true,"data":{"session_id":"sess-1"}}`))
+		case strings.HasSuffix(r.URL.Path, "/complete"):
+			w.Write([]byte(`{"success":true,"data":{"file_id":"` + testFileID2 + `","version_number":2}}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient(srv.URL, false, 10, false)
+	fileID, err := doChunkedUpload(client, newTestSession("tok", "ref", 30*time.Minute), &ChunkedUploadParams{
+		FilePath:       path,
+		FileID:         testFileID2,
+		FEK:            make([]byte, 32),
+		FileSizeBytes:  12,
+		ChunkCount:     1,
+		ChunkSizeBytes: 16,
+		VersionOf:      testFileID,
+	})
+	if err != nil || fileID != testFileID2 {
+		t.Fatalf("doChunkedUpload = %q, %v", fileID, err)
+	}
+	if init["version_of"] != testFileID {
+		t.Errorf("init payload version_of = %v, want %s", init["version_of"], testFileID)
+	}
+}
@@ -67,15 +67,23 @@ type Config struct {
 	} `json:"database"`
 
 	Storage struct {
-		Provider                string `json:"provider"` // "generic-s3", "backblaze", "wasabi", "vultr", "aws-s3"
+		Provider                string `json:"provider"` // "generic-s3", "backblaze", "wasabi", "vultr", "aws-s3", "local"
 		Endpoint                string `json:"endpoint"`
 		AccessKeyID             string `json:"access_key_id"`
 		SecretAccessKey         string `json:"secret_access_key"`
 		BucketName              string `json:"bucket_name"`
+		LocalPath               string `json:"local_path"` // Root directory for the "local" filesystem provider
 		Region                  string `json:"region"`
 		UseSSL                  bool   `json:"use_ssl"`
 		ForcePathStyle          bool   `json:"force_path_style"`          // Required for many self-hosted S3 (SeaweedFS, Ceph, MinIO)
 		EnableUploadReplication bool   `json:"enable_upload_replication"` // When true and a secondary provider is configured, new uploads are auto-replicated
+		WritePolicy             string `json:"write_policy"`              // "primary-only", "async-secondary", "quorum", "N-of-M", or "erasure"
+		WriteQuorum             int    `json:"write_quorum"`              // Providers that must hold a verified copy under the quorum policy
+		ErasureShards           string `json:"erasure_shards"`            // "K+M" data+parity shards under the erasure policy, e.g. "2+1"
+		HedgedReads             bool   `json:"hedged_reads"`              // Send a parallel chunk read to the next replica when a provider is slow
+		ScrubPeriodDays         int    `json:"scrub_period_days"`         // Re-hash every stored blob at least this often (0 disables scrubbing)
+		ScrubMaxBytesPerSec     int64  `json:"scrub_max_bytes_per_sec"`   // Read rate limit for the background scrubber
+		TrashRetentionDays      int    `json:"trash_retention_days"`      // Days a deleted file stays restorable in the trash (0 deletes immediately)
 	} `json:"storage"`
 
 	Security struct {
@@ -219,6 +227,7 @@ func loadPrimaryStorageConfig(cfg *Config) {
 	cfg.Storage.AccessKeyID = os.Getenv(storageSlotKey(1, "ACCESS_KEY"))
 	cfg.Storage.SecretAccessKey = os.Getenv(storageSlotKey(1, "SECRET_KEY"))
 	cfg.Storage.BucketName = os.Getenv(storageSlotKey(1, "BUCKET"))
+	cfg.Storage.LocalPath = os.Getenv(storageSlotKey(1, "PATH"))
 	cfg.Storage.Region = os.Getenv(storageSlotKey(1, "REGION"))
 	if cfg.Storage.Region == "" {
 		cfg.Storage.Region = "us-east-1"
@@ -437,6 +446,59 @@ func loadEnvConfig(cfg *Config) error {
 		}
 	}
 
+	// Upload write policy. When unset, ENABLE_UPLOAD_REPLICATION=true maps to
+	// async-secondary (the original behavior) and anything else to primary-only.
+	// The value is parsed and checked against the configured providers at startup.
+	cfg.Storage.WritePolicy = os.Getenv("STORAGE_WRITE_POLICY")
+	if cfg.Storage.WritePolicy == "" {
+		if cfg.Storage.EnableUploadReplication {
+			cfg.Storage.WritePolicy = "async-secondary"
+		} else {
+			cfg.Storage.WritePolicy = "primary-only"
+		}
+	}
+	if v := os.Getenv("STORAGE_WRITE_QUORUM"); v != "" {
+		if quorum, err := strconv.Atoi(v); err == nil && quorum > 0 {
+			cfg.Storage.WriteQuorum = quorum
+		}
+	}
+	cfg.Storage.ErasureShards = os.Getenv("STORAGE_ERASURE_SHARDS")
+	if cfg.Storage.ErasureShards == "" {
+		cfg.Storage.ErasureShards = "2+1"
+	}
+
+	// Hedged chunk reads across replicas (default: enabled)
+	cfg.Storage.HedgedReads = true
+	if v := os.Getenv("STORAGE_HEDGED_READS"); v != "" {
+		if hedged, err := strconv.ParseBool(v); err == nil {
+			cfg.Storage.HedgedReads = hedged
+		}
+	}
+
+	// Background scrubbing: re-hash every stored blob within the period, reading
+	// no faster than the rate limit. A period of 0 disables the scrubber.
+	cfg.Storage.ScrubPeriodDays = 30
+	if v := os.Getenv("STORAGE_SCRUB_PERIOD_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.Storage.ScrubPeriodDays = days
+		}
+	}
+	cfg.Storage.ScrubMaxBytesPerSec = 8 * 1024 * 1024
+	if v := os.Getenv("STORAGE_SCRUB_MAX_BYTES_PER_SEC"); v != "" {
+		if rate, err := strconv.ParseInt(v, 10, 64); err == nil && rate > 0 {
+			cfg.Storage.ScrubMaxBytesPerSec = rate
+		}
+	}
+
+	// Trash: deleted files can be restored for this many days before they are
+	// purged. 0 disables the trash and makes every delete permanent.
+	cfg.Storage.TrashRetentionDays = 30
+	if v := os.Getenv("STORAGE_TRASH_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.Storage.TrashRetentionDays = days
+		}
+	}
+
 	// Billing / usage metering envs
 	if v := os.Getenv("ARKFILE_BILLING_ENABLED"); v != "" {
 		if parsed, err := strconv.ParseBool(v); err == nil {
@@ -539,6 +601,10 @@ func validateConfig(cfg *Config) error {
 			cfg.Storage.BucketName == "" {
 			return fmt.Errorf("AWS S3 storage requires STORAGE_1_ACCESS_KEY, STORAGE_1_SECRET_KEY, and STORAGE_1_BUCKET")
 		}
+	case "local":
+		if cfg.Storage.LocalPath == "" {
+			return fmt.Errorf("local storage requires STORAGE_1_PATH")
+		}
 	default:
 		return fmt.Errorf("unsupported storage provider: %s", cfg.Storage.Provider)
 	}
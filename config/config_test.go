@@ -260,6 +260,14 @@ func TestStorageProviderSupport(t *testing.T) {
 				"STORAGE_1_BUCKET":     "test-bucket",
 			},
 		},
+		{
+			name: "local",
+			envVars: map[string]string{
+				"JWT_SECRET":         "test-jwt-secret",
+				"STORAGE_PROVIDER_1": "local",
+				"STORAGE_1_PATH":     "/var/lib/arkfile/objects",
+			},
+		},
 	}
 
 	for _, provider := range providers {
@@ -614,3 +622,189 @@ func TestPaymentsConfigValidationRejectsInvalidTopUpRange(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "ARKFILE_MIN_TOP_UP_USD must be less than ARKFILE_MAX_TOP_UP_USD")
 }
+
+// TestStorageWritePolicyFromEnv verifies STORAGE_WRITE_POLICY defaults and the
+// backwards-compatible mapping from ENABLE_UPLOAD_REPLICATION.
+func TestStorageWritePolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantPolicy string
+		wantQuorum int
+	}{
+		{name: "default", env: map[string]string{}, wantPolicy: "primary-only"},
+		{name: "legacy replication flag", env: map[string]string{"ENABLE_UPLOAD_REPLICATION": "true"}, wantPolicy: "async-secondary"},
+		{name: "explicit quorum", env: map[string]string{"STORAGE_WRITE_POLICY": "quorum", "STORAGE_WRITE_QUORUM": "2"}, wantPolicy: "quorum", wantQuorum: 2},
+		{name: "explicit policy wins over legacy flag", env: map[string]string{"ENABLE_UPLOAD_REPLICATION": "true", "STORAGE_WRITE_POLICY": "2-of-3"}, wantPolicy: "2-of-3"},
+	}
+
+	baseEnv := map[string]string{
+		"JWT_SECRET":           "test-jwt-secret",
+		"STORAGE_PROVIDER_1":   "generic-s3",
+		"STORAGE_1_ENDPOINT":   "http://localhost:9332",
+		"STORAGE_1_ACCESS_KEY": "test",
+		"STORAGE_1_SECRET_KEY": "test",
+		"STORAGE_1_BUCKET":     "test-bucket",
+	}
+	keys := []string{"ENABLE_UPLOAD_REPLICATION", "STORAGE_WRITE_POLICY", "STORAGE_WRITE_QUORUM"}
+	for key := range baseEnv {
+		keys = append(keys, key)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := map[string]string{}
+			for _, key := range keys {
+				originalEnv[key] = os.Getenv(key)
+				os.Unsetenv(key)
+			}
+			defer func() {
+				for key, value := range originalEnv {
+					if value == "" {
+						os.Unsetenv(key)
+					} else {
+						os.Setenv(key, value)
+					}
+				}
+				ResetConfigForTest()
+			}()
+
+			for key, value := range baseEnv {
+				os.Setenv(key, value)
+			}
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			ResetConfigForTest()
+
+			cfg, err := LoadConfig()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantPolicy, cfg.Storage.WritePolicy)
+			assert.Equal(t, tt.wantQuorum, cfg.Storage.WriteQuorum)
+		})
+	}
+}
+
+func TestStorageScrubFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantPeriod int
+		wantRate   int64
+	}{
+		{name: "defaults", env: map[string]string{}, wantPeriod: 30, wantRate: 8 * 1024 * 1024},
+		{name: "custom", env: map[string]string{"STORAGE_SCRUB_PERIOD_DAYS": "7", "STORAGE_SCRUB_MAX_BYTES_PER_SEC": "1048576"}, wantPeriod: 7, wantRate: 1048576},
+		{name: "disabled", env: map[string]string{"STORAGE_SCRUB_PERIOD_DAYS": "0"}, wantPeriod: 0, wantRate: 8 * 1024 * 1024},
+		{name: "invalid values ignored", env: map[string]string{"STORAGE_SCRUB_PERIOD_DAYS": "-1", "STORAGE_SCRUB_MAX_BYTES_PER_SEC": "fast"}, wantPeriod: 30, wantRate: 8 * 1024 * 1024},
+	}
+
+	baseEnv := map[string]string{
+		"JWT_SECRET":           "test-jwt-secret",
+		"STORAGE_PROVIDER_1":   "generic-s3",
+		"STORAGE_1_ENDPOINT":   "http://localhost:9332",
+		"STORAGE_1_ACCESS_KEY": "test",
+		"STORAGE_1_SECRET_KEY": "test",
+		"STORAGE_1_BUCKET":     "test-bucket",
+	}
+	keys := []string{"STORAGE_SCRUB_PERIOD_DAYS", "STORAGE_SCRUB_MAX_BYTES_PER_SEC"}
+	for key := range baseEnv {
+		keys = append(keys, key)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := map[string]string{}
+			for _, key := range keys {
+				originalEnv[key] = os.Getenv(key)
+				os.Unsetenv(key)
+			}
+			defer func() {
+				for key, value := range originalEnv {
+					if value == "" {
+						os.Unsetenv(key)
+					} else {
+						os.Setenv(key, value)
+					}
+				}
+				ResetConfigForTest()
+			}()
+
+			for key, value := range baseEnv {
+				os.Setenv(key, value)
+			}
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			ResetConfigForTest()
+
+			cfg, err := LoadConfig()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantPeriod, cfg.Storage.ScrubPeriodDays)
+			assert.Equal(t, tt.wantRate, cfg.Storage.ScrubMaxBytesPerSec)
+		})
+	}
+}
+
+func TestStorageTrashRetentionFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantDays int
+	}{
+		{name: "default", value: "", wantDays: 30},
+		{name: "custom", value: "7", wantDays: 7},
+		{name: "disabled", value: "0", wantDays: 0},
+		{name: "invalid value ignored", value: "-3", wantDays: 30},
+	}
+
+	baseEnv := map[string]string{
+		"JWT_SECRET":           "test-jwt-secret",
+		"STORAGE_PROVIDER_1":   "generic-s3",
+		"STORAGE_1_ENDPOINT":   "http://localhost:9332",
+		"STORAGE_1_ACCESS_KEY": "test",
+		"STORAGE_1_SECRET_KEY": "test",
+		"STORAGE_1_BUCKET":     "test-bucket",
+	}
+	keys := []string{"STORAGE_TRASH_RETENTION_DAYS"}
+	for key := range baseEnv {
+		keys = append(keys, key)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalEnv := map[string]string{}
+			for _, key := range keys {
+				originalEnv[key] = os.Getenv(key)
+				os.Unsetenv(key)
+			}
+			defer func() {
+				for key, value := range originalEnv {
+					if value == "" {
+						os.Unsetenv(key)
+					} else {
+						os.Setenv(key, value)
+					}
+				}
+				ResetConfigForTest()
+			}()
+
+			for key, value := range baseEnv {
+				os.Setenv(key, value)
+			}
+			if tt.value != "" {
+				os.Setenv("STORAGE_TRASH_RETENTION_DAYS", tt.value)
+			}
+			ResetConfigForTest()
+
+			cfg, err := LoadConfig()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantDays, cfg.Storage.TrashRetentionDays)
+		})
+	}
+}
@@ -47,17 +47,21 @@ import (
 // Canonical AAD field-label constants for metadata encryption.
 //
 // These strings are permanent wire-format commitments:
-// changing either value would invalidate every existing file's metadata
+// changing any value would invalidate every existing file's metadata
 // AAD. They are AAD labels only -- they are NOT renames of any DB column
 // or API field. The existing schema and API field names remain
-// "encrypted_filename" and "encrypted_sha256sum" (which is also why these
-// are the chosen label strings -- the AAD label tracks the stored field's
-// name verbatim).
+// "encrypted_filename", "encrypted_sha256sum", "encrypted_folder",
+// "encrypted_tags" and "encrypted_note" (which is also why these are the
+// chosen label strings -- the AAD label tracks the stored field's name
+// verbatim).
 //
 // Callers of BuildMetadataFieldAAD MUST reference these constants.
 const (
 	AADFieldFilename = "encrypted_filename"
 	AADFieldSha256   = "encrypted_sha256sum"
+	AADFieldFolder   = "encrypted_folder"
+	AADFieldTags     = "encrypted_tags"
+	AADFieldNote     = "encrypted_note"
 )
 
 // BuildChunkAAD constructs the AAD for a file-content chunk.
@@ -88,7 +92,8 @@ func BuildChunkAAD(fileID string, chunkIndex, totalChunks int64) []byte {
 // Binding keyTypeByte prevents an attacker from flipping the 0x01/0x02
 // indicator byte to mis-route the client to the wrong KEK derivation.
 //
-// keyTypeByte values: 0x01 = account password, 0x02 = custom password.
+// keyTypeByte values: 0x01 = account password, 0x02 = custom password,
+// 0x03 = file drop (see file_drop.go), 0x04 = user share (see user_keys.go).
 // See crypto/chunking-params.json envelope.keyTypes.
 func BuildFEKEnvelopeAAD(fileID string, keyTypeByte byte) []byte {
 	fidBytes := []byte(fileID)
@@ -102,16 +107,16 @@ func BuildFEKEnvelopeAAD(fileID string, keyTypeByte byte) []byte {
 }
 
 // BuildMetadataFieldAAD constructs the AAD for an encrypted metadata field
-// (filename or original-plaintext SHA-256 digest).
+// (filename, original-plaintext SHA-256 digest, folder path, tags or note).
 //
 // Binding fileID prevents moving a metadata row to a different file.
-// Binding fieldName prevents substituting encrypted_filename ciphertext
-// into the encrypted_sha256sum slot or vice versa.
+// Binding fieldName prevents substituting one field's ciphertext into
+// another field's slot (e.g. encrypted_filename into encrypted_folder).
 // Binding ownerUsername prevents moving a metadata row to a different
 // user's account.
 //
-// fieldName MUST be one of the canonical constants: AADFieldFilename or
-// AADFieldSha256.
+// fieldName MUST be one of the canonical constants: AADFieldFilename,
+// AADFieldSha256, AADFieldFolder, AADFieldTags or AADFieldNote.
 func BuildMetadataFieldAAD(fileID, fieldName, ownerUsername string) []byte {
 	fidBytes := []byte(fileID)
 	fnBytes := []byte(fieldName)
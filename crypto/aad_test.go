@@ -155,6 +155,14 @@ func TestBuildMetadataFieldAAD_FieldNameDistinction(t *testing.T) {
 	}
 }
 
+func TestBuildMetadataFieldAAD_FolderDistinction(t *testing.T) {
+	fn := BuildMetadataFieldAAD("file-x", AADFieldFilename, "alice")
+	fo := BuildMetadataFieldAAD("file-x", AADFieldFolder, "alice")
+	if bytes.Equal(fn, fo) {
+		t.Errorf("metadata AAD collided across filename/folder labels: %x", fn)
+	}
+}
+
 func TestBuildMetadataFieldAAD_UsernameDistinction(t *testing.T) {
 	a := BuildMetadataFieldAAD("file-x", AADFieldFilename, "alice")
 	b := BuildMetadataFieldAAD("file-x", AADFieldFilename, "bob")
@@ -183,6 +191,10 @@ func TestAADFieldLabels_AreCanonicalStrings(t *testing.T) {
 		t.Errorf("AADFieldSha256 drifted: got %q, expected exactly %q",
 			AADFieldSha256, "encrypted_sha256sum")
 	}
+	if AADFieldFolder != "encrypted_folder" {
+		t.Errorf("AADFieldFolder drifted: got %q, expected exactly %q",
+			AADFieldFolder, "encrypted_folder")
+	}
 }
 
 // =============================================================================
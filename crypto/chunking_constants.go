@@ -26,6 +26,8 @@ type EnvelopeParams struct {
 type KeyTypeMapping struct {
 	Account int `json:"account"`
 	Custom  int `json:"custom"`
+	Drop    int `json:"drop"`
+	User    int `json:"user"`
 }
 
 // AesGcmParams represents AES-GCM configuration
@@ -98,6 +100,10 @@ func KeyTypeForContext(passwordType string) (byte, error) {
 		return byte(p.Envelope.KeyTypes.Account), nil
 	case "custom":
 		return byte(p.Envelope.KeyTypes.Custom), nil
+	case "drop":
+		return byte(p.Envelope.KeyTypes.Drop), nil
+	case "user":
+		return byte(p.Envelope.KeyTypes.User), nil
 	default:
 		return 0, fmt.Errorf("unknown password type: %s", passwordType)
 	}
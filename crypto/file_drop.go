@@ -0,0 +1,72 @@
+package crypto
+
+// File drops
+//
+// A file drop lets people without an account upload files into a user's
+// vault. The owner generates a hybrid key pair for each drop (see
+// hybrid_kem.go); the public key is served to senders and the private key is
+// stored on the server wrapped with the owner's account key, so only the
+// owner can open what was dropped.
+//
+// A sender encrypts the file under a fresh FEK as usual and seals the FEK to
+// the drop's public key with key type 0x03 (see "Sealing FEKs to a hybrid
+// key" in hybrid_kem.go). The metadata key derived alongside encrypts the
+// filename and SHA-256, with AAD = BuildMetadataFieldAAD(fileID, field,
+// dropID): senders do not learn the owner's username, so the drop ID takes
+// its place. When the owner accepts a dropped file the FEK and metadata are
+// re-encrypted with the account key and the file becomes an ordinary
+// account-password file. The drop link carries HybridKeyFingerprint of the
+// public key.
+
+// dropFEKInfo holds the HKDF info strings for the keys derived from a drop's
+// KEM shared secret.
+var dropFEKInfo = hybridFEKInfo{
+	fek:      "arkfile-drop-fek-v1",
+	metadata: "arkfile-drop-metadata-v1",
+}
+
+// dropKeyAADLabel separates the drop private key AAD from other AAD shapes.
+const dropKeyAADLabel = "drop_private_key"
+
+// DropFEKEnvelopeSize returns the size of a drop FEK envelope holding a
+// 32-byte FEK.
+func DropFEKEnvelopeSize() int {
+	return hybridFEKEnvelopeSize()
+}
+
+// SealDropFEK seals fek to a drop's public key. It returns the FEK envelope
+// and the key for the file's encrypted metadata. Callers should clear
+// metadataKey after use.
+func SealDropFEK(publicKey []byte, fileID string, fek []byte) (envelope, metadataKey []byte, err error) {
+	return sealHybridFEK(publicKey, fileID, "drop", dropFEKInfo, fek)
+}
+
+// OpenDropFEK opens a FEK envelope produced by SealDropFEK and returns the
+// FEK and the metadata key.
+func OpenDropFEK(privateKey *HybridPrivateKey, fileID string, envelope []byte) (fek, metadataKey []byte, err error) {
+	return openHybridFEK(privateKey, fileID, "drop", dropFEKInfo, envelope)
+}
+
+// BuildDropKeyAAD constructs the AAD for a drop's private key wrapped with
+// the owner's account key. Binding the drop ID and owner stops the server
+// from presenting one drop's key as another's.
+//
+//	[4B len(label)][label][4B len(dropID)][dropID][4B len(owner)][owner]
+func BuildDropKeyAAD(dropID, ownerUsername string) []byte {
+	out := make([]byte, 0, 12+len(dropKeyAADLabel)+len(dropID)+len(ownerUsername))
+	out = appendLenPrefixedString(out, []byte(dropKeyAADLabel))
+	out = appendLenPrefixedString(out, []byte(dropID))
+	out = appendLenPrefixedString(out, []byte(ownerUsername))
+	return out
+}
+
+// WrapDropPrivateKey encrypts a drop's private key with the owner's account
+// key.
+func WrapDropPrivateKey(privateKey *HybridPrivateKey, accountKey []byte, dropID, ownerUsername string) ([]byte, error) {
+	return wrapHybridPrivateKey(privateKey, accountKey, BuildDropKeyAAD(dropID, ownerUsername))
+}
+
+// UnwrapDropPrivateKey decrypts a private key produced by WrapDropPrivateKey.
+func UnwrapDropPrivateKey(wrapped, accountKey []byte, dropID, ownerUsername string) (*HybridPrivateKey, error) {
+	return unwrapHybridPrivateKey(wrapped, accountKey, BuildDropKeyAAD(dropID, ownerUsername))
+}
@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHybridKEM_RoundTrip(t *testing.T) {
+	key, err := GenerateHybridKey()
+	if err != nil {
+		t.Fatalf("GenerateHybridKey failed: %v", err)
+	}
+	pub := key.PublicKey()
+	if len(pub) != HybridPublicKeySize {
+		t.Fatalf("public key is %d bytes, want %d", len(pub), HybridPublicKeySize)
+	}
+
+	secret, ciphertext, err := HybridEncapsulate(pub)
+	if err != nil {
+		t.Fatalf("HybridEncapsulate failed: %v", err)
+	}
+	if len(ciphertext) != HybridCiphertextSize || len(secret) != HybridSharedSecretSize {
+		t.Fatalf("ciphertext %d bytes, secret %d bytes", len(ciphertext), len(secret))
+	}
+
+	// The key survives a round trip through its encoding
+	restored, err := NewHybridPrivateKey(key.Bytes())
+	if err != nil {
+		t.Fatalf("NewHybridPrivateKey failed: %v", err)
+	}
+	if !bytes.Equal(restored.PublicKey(), pub) {
+		t.Error("restored key has a different public key")
+	}
+	got, err := restored.Decapsulate(ciphertext)
+	if err != nil {
+		t.Fatalf("Decapsulate failed: %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Error("decapsulated secret differs")
+	}
+
+	// Changing either half of the ciphertext changes the secret
+	for _, i := range []int{0, 32, HybridCiphertextSize - 1} {
+		tampered := bytes.Clone(ciphertext)
+		tampered[i] ^= 1
+		if got, err := key.Decapsulate(tampered); err == nil && bytes.Equal(got, secret) {
+			t.Errorf("tampering with byte %d did not change the secret", i)
+		}
+	}
+
+	if _, _, err := HybridEncapsulate(pub[:32]); err == nil {
+		t.Error("expected an error for a short public key")
+	}
+	if _, err := NewHybridPrivateKey(make([]byte, 10)); err == nil {
+		t.Error("expected an error for a short private key")
+	}
+}
+
+func TestDropFEK_SealOpen(t *testing.T) {
+	key, err := GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fek := bytes.Repeat([]byte{0x42}, 32)
+
+	envelope, metaKey, err := SealDropFEK(key.PublicKey(), "file-1", fek)
+	if err != nil {
+		t.Fatalf("SealDropFEK failed: %v", err)
+	}
+	if len(envelope) != DropFEKEnvelopeSize() || envelope[0] != 0x01 || envelope[1] != 0x03 {
+		t.Fatalf("envelope is %d bytes with header %x", len(envelope), envelope[:2])
+	}
+
+	gotFEK, gotMetaKey, err := OpenDropFEK(key, "file-1", envelope)
+	if err != nil {
+		t.Fatalf("OpenDropFEK failed: %v", err)
+	}
+	if !bytes.Equal(gotFEK, fek) || !bytes.Equal(gotMetaKey, metaKey) {
+		t.Error("opened FEK or metadata key differs")
+	}
+
+	// The envelope is bound to its file ID, and only the drop key opens it
+	if _, _, err := OpenDropFEK(key, "file-2", envelope); err == nil {
+		t.Error("expected an error for a different file ID")
+	}
+	other, _ := GenerateHybridKey()
+	if _, _, err := OpenDropFEK(other, "file-1", envelope); err == nil {
+		t.Error("expected an error for a different drop key")
+	}
+	account := append([]byte{0x01, 0x01}, envelope[2:]...)
+	if _, _, err := OpenDropFEK(key, "file-1", account); err == nil {
+		t.Error("expected an error for an account-password envelope")
+	}
+}
+
+func TestDropPrivateKey_Wrap(t *testing.T) {
+	key, err := GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKey := bytes.Repeat([]byte{0x07}, 32)
+
+	wrapped, err := WrapDropPrivateKey(key, accountKey, "drop-1", "alice")
+	if err != nil {
+		t.Fatalf("WrapDropPrivateKey failed: %v", err)
+	}
+	restored, err := UnwrapDropPrivateKey(wrapped, accountKey, "drop-1", "alice")
+	if err != nil {
+		t.Fatalf("UnwrapDropPrivateKey failed: %v", err)
+	}
+	if !bytes.Equal(restored.PublicKey(), key.PublicKey()) {
+		t.Error("unwrapped key differs")
+	}
+	if _, err := UnwrapDropPrivateKey(wrapped, accountKey, "drop-2", "alice"); err == nil {
+		t.Error("expected an error for a different drop ID")
+	}
+	if _, err := UnwrapDropPrivateKey(wrapped, accountKey, "drop-1", "bob"); err == nil {
+		t.Error("expected an error for a different owner")
+	}
+
+	fp := HybridKeyFingerprint(key.PublicKey())
+	if len(fp) != 22 || fp == HybridKeyFingerprint(restored.PublicKey()[1:]) {
+		t.Errorf("fingerprint %q", fp)
+	}
+}
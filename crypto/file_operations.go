@@ -249,6 +249,9 @@ func VerifyFileIntegrity(filePath string, expectedHash string, expectedSize int6
 //   - 0x01 is the envelope version byte.
 //   - key_type is 0x01 (account password) or 0x02 (custom password). Values
 //     are sourced from crypto/chunking-params.json via chunking_constants.go.
+//     Files uploaded through a file drop use key_type 0x03, and FEKs shared
+//     with another user key_type 0x04; both carry a hybrid KEM ciphertext
+//     before the nonce (see crypto/hybrid_kem.go).
 //   - The AEAD authentication tag is computed with AAD =
 //     BuildFEKEnvelopeAAD(file_id, key_type) (see crypto/aad.go). This binds
 //     the FEK envelope to the specific file_id and key type, so an attacker
@@ -264,7 +267,7 @@ func VerifyFileIntegrity(filePath string, expectedHash string, expectedSize int6
 // =============================================================================
 
 // CreateFEKEnvelopeHeader creates the 2-byte FEK envelope header.
-// keyType: "account" or "custom"
+// keyType: "account", "custom", "drop" or "user"
 func CreateFEKEnvelopeHeader(keyType string) []byte {
 	envelope := make([]byte, 2)
 	envelope[0] = 0x01 // Version 1
@@ -274,6 +277,10 @@ func CreateFEKEnvelopeHeader(keyType string) []byte {
 		envelope[1] = 0x01
 	case "custom":
 		envelope[1] = 0x02
+	case "drop":
+		envelope[1] = 0x03
+	case "user":
+		envelope[1] = 0x04
 	default:
 		envelope[1] = 0x00 // Unknown
 	}
@@ -282,7 +289,7 @@ func CreateFEKEnvelopeHeader(keyType string) []byte {
 }
 
 // ParseFEKEnvelopeHeader parses a 2-byte FEK envelope header and returns the
-// key type ("account" or "custom"). Returns an error for unknown version
+// key type ("account", "custom", "drop" or "user"). Returns an error for unknown version
 // bytes or short input.
 func ParseFEKEnvelopeHeader(envelope []byte) (version byte, keyType string, err error) {
 	if len(envelope) < 2 {
@@ -299,6 +306,10 @@ func ParseFEKEnvelopeHeader(envelope []byte) (version byte, keyType string, err
 		keyType = "account"
 	case 0x02:
 		keyType = "custom"
+	case 0x03:
+		keyType = "drop"
+	case 0x04:
+		keyType = "user"
 	default:
 		keyType = "unknown"
 	}
@@ -502,7 +513,8 @@ func DecryptFileMetadata(filenameNonce, encryptedFilename, sha256Nonce, encrypte
 // BuildMetadataFieldAAD(fileID, fieldName, ownerUsername) to bind the
 // ciphertext to its file, field label, and owner.
 //
-// fieldName MUST be AADFieldFilename or AADFieldSha256.
+// fieldName MUST be AADFieldFilename, AADFieldSha256, AADFieldFolder, AADFieldTags
+// or AADFieldNote.
 func DecryptMetadataWithDerivedKey(derivedKey []byte, nonce, encryptedData []byte, fileID, fieldName, ownerUsername string) ([]byte, error) {
 	// Validate input lengths.
 	if len(nonce) != 12 {
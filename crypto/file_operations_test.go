@@ -529,6 +529,8 @@ func TestCreateAndParsePasswordEnvelope(t *testing.T) {
 	}{
 		{"account", 0x01, "account"},
 		{"custom", 0x01, "custom"},
+		{"drop", 0x01, "drop"},
+		{"user", 0x01, "user"},
 		{"unknown_type", 0x01, "unknown"},
 	}
 
@@ -0,0 +1,286 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha3"
+	"encoding/base64"
+	"fmt"
+)
+
+// Hybrid key encapsulation (X25519 + ML-KEM-768)
+//
+// Public-key encryption on the file path (file drops and user-to-user
+// sharing) encapsulates a shared secret to both an X25519 and an
+// ML-KEM-768 key and combines the two secrets, so the result stays safe as
+// long as either algorithm holds (see docs/wip/post-quantum.md). The
+// combiner is the one from the X-Wing draft
+// (draft-connolly-cfrg-xwing-kem):
+//
+//	SHA3-256(ss_M || ss_X || ct_X || pk_X || label)
+//
+// The key and ciphertext encodings are Arkfile's own and are not
+// interchangeable with X-Wing:
+//
+//	public key:  [32B X25519 public key][1184B ML-KEM-768 encapsulation key]
+//	private key: [32B X25519 private key][64B ML-KEM-768 seed]
+//	ciphertext:  [32B ephemeral X25519 public key][1088B ML-KEM-768 ciphertext]
+
+// hybridKEMLabel is the X-Wing combiner label.
+const hybridKEMLabel = "\\.//^\\"
+
+// Sizes of the hybrid KEM encodings in bytes.
+const (
+	HybridPublicKeySize    = 32 + mlkem.EncapsulationKeySize768
+	HybridPrivateKeySize   = 32 + mlkem.SeedSize
+	HybridCiphertextSize   = 32 + mlkem.CiphertextSize768
+	HybridSharedSecretSize = 32
+)
+
+// HybridPrivateKey is an X25519 + ML-KEM-768 decapsulation key.
+type HybridPrivateKey struct {
+	x25519 *ecdh.PrivateKey
+	mlkem  *mlkem.DecapsulationKey768
+}
+
+// GenerateHybridKey generates a new hybrid key pair.
+func GenerateHybridKey() (*HybridPrivateKey, error) {
+	x, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate X25519 key: %w", err)
+	}
+	m, err := mlkem.GenerateKey768()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ML-KEM key: %w", err)
+	}
+	return &HybridPrivateKey{x25519: x, mlkem: m}, nil
+}
+
+// NewHybridPrivateKey parses a private key produced by Bytes.
+func NewHybridPrivateKey(b []byte) (*HybridPrivateKey, error) {
+	if len(b) != HybridPrivateKeySize {
+		return nil, fmt.Errorf("invalid hybrid private key length: expected %d, got %d", HybridPrivateKeySize, len(b))
+	}
+	x, err := ecdh.X25519().NewPrivateKey(b[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %w", err)
+	}
+	m, err := mlkem.NewDecapsulationKey768(b[32:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ML-KEM seed: %w", err)
+	}
+	return &HybridPrivateKey{x25519: x, mlkem: m}, nil
+}
+
+// Bytes returns the encoded private key. Callers should clear it after use.
+func (k *HybridPrivateKey) Bytes() []byte {
+	out := make([]byte, 0, HybridPrivateKeySize)
+	out = append(out, k.x25519.Bytes()...)
+	return append(out, k.mlkem.Bytes()...)
+}
+
+// PublicKey returns the encoded public key.
+func (k *HybridPrivateKey) PublicKey() []byte {
+	out := make([]byte, 0, HybridPublicKeySize)
+	out = append(out, k.x25519.PublicKey().Bytes()...)
+	return append(out, k.mlkem.EncapsulationKey().Bytes()...)
+}
+
+// Decapsulate recovers the shared secret from a ciphertext produced by
+// HybridEncapsulate for this key.
+func (k *HybridPrivateKey) Decapsulate(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) != HybridCiphertextSize {
+		return nil, fmt.Errorf("invalid hybrid ciphertext length: expected %d, got %d", HybridCiphertextSize, len(ciphertext))
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ciphertext[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 ciphertext: %w", err)
+	}
+	ssX, err := k.x25519.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("X25519 decapsulation failed: %w", err)
+	}
+	ssM, err := k.mlkem.Decapsulate(ciphertext[32:])
+	if err != nil {
+		return nil, fmt.Errorf("ML-KEM decapsulation failed: %w", err)
+	}
+	return combineHybridSecrets(ssM, ssX, ciphertext[:32], k.x25519.PublicKey().Bytes()), nil
+}
+
+// HybridEncapsulate generates a shared secret for the encoded public key
+// and returns it with the ciphertext to send to the key's owner.
+func HybridEncapsulate(publicKey []byte) (sharedSecret, ciphertext []byte, err error) {
+	if len(publicKey) != HybridPublicKeySize {
+		return nil, nil, fmt.Errorf("invalid hybrid public key length: expected %d, got %d", HybridPublicKeySize, len(publicKey))
+	}
+	peer, err := ecdh.X25519().NewPublicKey(publicKey[:32])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+	ek, err := mlkem.NewEncapsulationKey768(publicKey[32:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ML-KEM public key: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ephemeral X25519 key: %w", err)
+	}
+	ssX, err := ephemeral.ECDH(peer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X25519 encapsulation failed: %w", err)
+	}
+	ssM, ctM := ek.Encapsulate()
+
+	ctX := ephemeral.PublicKey().Bytes()
+	ciphertext = make([]byte, 0, HybridCiphertextSize)
+	ciphertext = append(ciphertext, ctX...)
+	ciphertext = append(ciphertext, ctM...)
+	return combineHybridSecrets(ssM, ssX, ctX, publicKey[:32]), ciphertext, nil
+}
+
+// combineHybridSecrets is the X-Wing combiner.
+func combineHybridSecrets(ssM, ssX, ctX, pkX []byte) []byte {
+	h := sha3.New256()
+	h.Write(ssM)
+	h.Write(ssX)
+	h.Write(ctX)
+	h.Write(pkX)
+	h.Write([]byte(hybridKEMLabel))
+	return h.Sum(nil)
+}
+
+// Sealing FEKs to a hybrid key
+//
+// File drops and user-to-user shares seal a FEK to a hybrid public key:
+//
+//	[0x01][key type][hybrid KEM ciphertext (1120 bytes)][nonce][encrypted FEK][tag]
+//
+// Two keys are derived from the KEM shared secret with HKDF: one encrypts
+// the FEK with AAD = BuildFEKEnvelopeAAD(fileID, key type), the other is
+// returned to the caller to encrypt the file's metadata.
+
+// HybridKeyFingerprintBytes is the length of a hybrid key fingerprint before
+// base64url encoding.
+const HybridKeyFingerprintBytes = 16
+
+// hybridFEKInfo holds the HKDF info strings of one kind of sealed FEK.
+type hybridFEKInfo struct {
+	fek      string
+	metadata string
+}
+
+// hybridFEKEnvelopeSize returns the size of a sealed envelope holding a
+// 32-byte FEK.
+func hybridFEKEnvelopeSize() int {
+	return 2 + HybridCiphertextSize + AesGcmOverhead() + MustGetChunkingParams().AesGcm.KeySizeBytes
+}
+
+// sealHybridFEK seals fek to publicKey under an envelope of keyType. It
+// returns the envelope and the metadata key.
+func sealHybridFEK(publicKey []byte, fileID, keyType string, info hybridFEKInfo, fek []byte) (envelope, metadataKey []byte, err error) {
+	sharedSecret, ciphertext, err := HybridEncapsulate(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer SecureClear(sharedSecret)
+
+	wrapKey, metadataKey, err := deriveHybridFEKKeys(sharedSecret, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer SecureClear(wrapKey)
+
+	header := CreateFEKEnvelopeHeader(keyType)
+	encrypted, err := EncryptGCMWithAAD(fek, wrapKey, BuildFEKEnvelopeAAD(fileID, header[1]))
+	if err != nil {
+		SecureClear(metadataKey)
+		return nil, nil, fmt.Errorf("failed to encrypt FEK: %w", err)
+	}
+
+	envelope = make([]byte, 0, len(header)+len(ciphertext)+len(encrypted))
+	envelope = append(envelope, header...)
+	envelope = append(envelope, ciphertext...)
+	envelope = append(envelope, encrypted...)
+	return envelope, metadataKey, nil
+}
+
+// openHybridFEK opens an envelope produced by sealHybridFEK with the same
+// keyType and info.
+func openHybridFEK(privateKey *HybridPrivateKey, fileID, keyType string, info hybridFEKInfo, envelope []byte) (fek, metadataKey []byte, err error) {
+	_, gotType, err := ParseFEKEnvelopeHeader(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+	if gotType != keyType {
+		return nil, nil, fmt.Errorf("expected a %s FEK envelope, got key type %s", keyType, gotType)
+	}
+	if len(envelope) < 2+HybridCiphertextSize+AesGcmOverhead() {
+		return nil, nil, fmt.Errorf("%s FEK envelope too short: %d bytes", keyType, len(envelope))
+	}
+
+	sharedSecret, err := privateKey.Decapsulate(envelope[2 : 2+HybridCiphertextSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	defer SecureClear(sharedSecret)
+
+	wrapKey, metadataKey, err := deriveHybridFEKKeys(sharedSecret, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer SecureClear(wrapKey)
+
+	fek, err = DecryptGCMWithAAD(envelope[2+HybridCiphertextSize:], wrapKey, BuildFEKEnvelopeAAD(fileID, envelope[1]))
+	if err != nil {
+		SecureClear(metadataKey)
+		return nil, nil, fmt.Errorf("failed to decrypt FEK: %w", err)
+	}
+	return fek, metadataKey, nil
+}
+
+// deriveHybridFEKKeys derives the FEK wrapping key and the metadata key from
+// a KEM shared secret.
+func deriveHybridFEKKeys(sharedSecret []byte, info hybridFEKInfo) (wrapKey, metadataKey []byte, err error) {
+	wrapKey, err = hkdfExpand(sharedSecret, []byte(info.fek), 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive FEK wrapping key: %w", err)
+	}
+	metadataKey, err = hkdfExpand(sharedSecret, []byte(info.metadata), 32)
+	if err != nil {
+		SecureClear(wrapKey)
+		return nil, nil, fmt.Errorf("failed to derive metadata key: %w", err)
+	}
+	return wrapKey, metadataKey, nil
+}
+
+// wrapHybridPrivateKey encrypts a private key with an account key under aad.
+func wrapHybridPrivateKey(privateKey *HybridPrivateKey, accountKey, aad []byte) ([]byte, error) {
+	raw := privateKey.Bytes()
+	defer SecureClear(raw)
+	wrapped, err := EncryptGCMWithAAD(raw, accountKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap private key: %w", err)
+	}
+	return wrapped, nil
+}
+
+// unwrapHybridPrivateKey decrypts a private key produced by
+// wrapHybridPrivateKey.
+func unwrapHybridPrivateKey(wrapped, accountKey, aad []byte) (*HybridPrivateKey, error) {
+	raw, err := DecryptGCMWithAAD(wrapped, accountKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap private key: %w", err)
+	}
+	defer SecureClear(raw)
+	return NewHybridPrivateKey(raw)
+}
+
+// HybridKeyFingerprint returns a short base64url fingerprint of an encoded
+// public key, for people to compare out of band.
+func HybridKeyFingerprint(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return base64.RawURLEncoding.EncodeToString(sum[:HybridKeyFingerprintBytes])
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Encrypted search index
+//
+// Clients index their files by keyword without revealing the keywords: each
+// keyword is turned into a blind-index token, a truncated HMAC-SHA256 under
+// a key derived from the account key, and only the tokens are stored on the
+// server. A search sends the tokens for the query words and gets back the
+// IDs of files holding all of them. The server never sees a keyword, but it
+// can tell when two files (or a file and a query) share one, and how many
+// keywords each file has.
+
+// searchIndexInfo is the HKDF info string for the search index key. Changing
+// it invalidates every stored token.
+const searchIndexInfo = "arkfile-search-index-v1"
+
+// SearchTokenBytes is the length of a blind-index token before hex encoding.
+// 128 bits keeps accidental collisions out of reach at any realistic index
+// size while halving what the server stores per keyword.
+const SearchTokenBytes = 16
+
+// MaxSearchKeywords bounds the keywords taken from a single file.
+const MaxSearchKeywords = 128
+
+// DeriveSearchIndexKey derives the key for blind-index tokens from the
+// account key.
+func DeriveSearchIndexKey(accountKey []byte) ([]byte, error) {
+	key, err := hkdfExpand(accountKey, []byte(searchIndexInfo), 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive search index key: %w", err)
+	}
+	return key, nil
+}
+
+// BlindIndexToken returns the hex-encoded token for a keyword. The keyword
+// should already be normalized by SearchKeywords.
+func BlindIndexToken(indexKey []byte, keyword string) string {
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(keyword))
+	return hex.EncodeToString(mac.Sum(nil)[:SearchTokenBytes])
+}
+
+// IsValidSearchToken reports whether s has the shape of a blind-index token:
+// lowercase hex of SearchTokenBytes bytes.
+func IsValidSearchToken(s string) bool {
+	if len(s) != hex.EncodedLen(SearchTokenBytes) {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchKeywords splits text into the keywords a file is indexed under and a
+// query is matched on: lowercased runs of letters and digits, without
+// duplicates, in order of first appearance. Both sides must use this so that
+// "Tax-Return_2025.PDF" is found by "tax 2025".
+func SearchKeywords(texts ...string) []string {
+	seen := make(map[string]struct{})
+	var keywords []string
+	for _, text := range texts {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			if _, ok := seen[w]; ok {
+				continue
+			}
+			if len(keywords) == MaxSearchKeywords {
+				return keywords
+			}
+			seen[w] = struct{}{}
+			keywords = append(keywords, w)
+		}
+	}
+	return keywords
+}
+
+// SearchTokens returns the blind-index tokens for the keywords in texts.
+func SearchTokens(accountKey []byte, texts ...string) ([]string, error) {
+	indexKey, err := DeriveSearchIndexKey(accountKey)
+	if err != nil {
+		return nil, err
+	}
+	keywords := SearchKeywords(texts...)
+	tokens := make([]string, len(keywords))
+	for i, w := range keywords {
+		tokens[i] = BlindIndexToken(indexKey, w)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// TestSearchTokens_KnownVector pins the index key derivation and token
+// format; a change here orphans every token already stored on servers.
+func TestSearchTokens_KnownVector(t *testing.T) {
+	accountKey := make([]byte, 32)
+	for i := range accountKey {
+		accountKey[i] = byte(i)
+	}
+
+	indexKey, err := DeriveSearchIndexKey(accountKey)
+	if err != nil {
+		t.Fatalf("DeriveSearchIndexKey failed: %v", err)
+	}
+	if got := hex.EncodeToString(indexKey); got != "7b3930a2d8e1c3703737434c3bced586f003a20b3e8a6bb4825bcfd9bc071645" {
+		t.Errorf("index key = %s", got)
+	}
+	if got := BlindIndexToken(indexKey, "report"); got != "d457e30eac9c9ae84a9b429bbd6a2893" {
+		t.Errorf("token = %s", got)
+	}
+
+	tokens, err := SearchTokens(accountKey, "Report.PDF")
+	if err != nil {
+		t.Fatalf("SearchTokens failed: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0] != "d457e30eac9c9ae84a9b429bbd6a2893" {
+		t.Errorf("tokens = %v", tokens)
+	}
+	for _, tok := range tokens {
+		if !IsValidSearchToken(tok) {
+			t.Errorf("token %q fails IsValidSearchToken", tok)
+		}
+	}
+}
+
+func TestSearchTokens_DifferentAccountKeys(t *testing.T) {
+	a, _ := SearchTokens(make([]byte, 32), "report")
+	b, _ := SearchTokens(append(make([]byte, 31), 1), "report")
+	if a[0] == b[0] {
+		t.Error("different account keys must give different tokens")
+	}
+	if _, err := SearchTokens(nil, "report"); err == nil {
+		t.Error("expected error for empty account key")
+	}
+}
+
+func TestSearchKeywords(t *testing.T) {
+	got := SearchKeywords("Tax-Return_2025.PDF", "finance/Tax", "Résumé")
+	want := []string{"tax", "return", "2025", "pdf", "finance", "résumé"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchKeywords = %v, want %v", got, want)
+	}
+	if got := SearchKeywords("..--__"); len(got) != 0 {
+		t.Errorf("punctuation only = %v", got)
+	}
+
+	long := ""
+	for i := 0; i < MaxSearchKeywords+10; i++ {
+		long += " w" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	if got := SearchKeywords(long); len(got) != MaxSearchKeywords {
+		t.Errorf("got %d keywords, want cap of %d", len(got), MaxSearchKeywords)
+	}
+}
+
+func TestIsValidSearchToken(t *testing.T) {
+	for _, tc := range []struct {
+		token string
+		valid bool
+	}{
+		{"d457e30eac9c9ae84a9b429bbd6a2893", true},
+		{"D457E30EAC9C9AE84A9B429BBD6A2893", false},
+		{"d457e30eac9c9ae84a9b429bbd6a289", false},
+		{"d457e30eac9c9ae84a9b429bbd6a28933", false},
+		{"g457e30eac9c9ae84a9b429bbd6a2893", false},
+		{"", false},
+	} {
+		if got := IsValidSearchToken(tc.token); got != tc.valid {
+			t.Errorf("IsValidSearchToken(%q) = %v, want %v", tc.token, got, tc.valid)
+		}
+	}
+}
@@ -114,15 +114,35 @@ type ShareKDFParamsEmbedded struct {
 //
 // The metadata is protected by the same AES-GCM-AAD encryption as the FEK,
 // so only someone with the share password can access it.
+//
+// A bundle share covers several files with one password and one Download
+// Token: its envelope leaves the top-level FEK and metadata empty and lists
+// each file in Files instead.
 type ShareEnvelope struct {
-	FEK           string                  `json:"fek"`                  // base64-encoded FEK
+	FEK           string                  `json:"fek,omitempty"`        // base64-encoded FEK (single-file shares)
 	DownloadToken string                  `json:"download_token"`       // base64-encoded Download Token
 	Filename      string                  `json:"filename,omitempty"`   // plaintext filename (for share recipient preview)
 	SizeBytes     int64                   `json:"size_bytes,omitempty"` // file size in bytes (for share recipient preview)
 	SHA256        string                  `json:"sha256,omitempty"`     // plaintext SHA256 hex digest (for share recipient integrity verification)
+	Files         []ShareBundleEntry      `json:"files,omitempty"`      // the files of a bundle share, in share order
 	KDFParams     *ShareKDFParamsEmbedded `json:"kdf_params,omitempty"` // embedded KDF params for verification
 }
 
+// ShareBundleEntry is one file of a bundle Share Envelope. Filename may be a
+// slash-separated path when a folder was shared.
+type ShareBundleEntry struct {
+	FileID    string `json:"file_id"`
+	FEK       string `json:"fek"` // base64-encoded FEK
+	Filename  string `json:"filename,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// IsBundle reports whether the envelope lists several files.
+func (e *ShareEnvelope) IsBundle() bool {
+	return len(e.Files) > 0
+}
+
 // ValidateShareKDFParams enforces compile-time KDF parameter floors
 func ValidateShareKDFParams(p *ShareKDFParamsEmbedded) error {
 	if p == nil {
@@ -182,6 +202,32 @@ func CreateShareEnvelope(fek, downloadToken []byte, filename string, sizeBytes i
 	return envelopeJSON, nil
 }
 
+// CreateShareBundleEnvelope creates a Share Envelope JSON payload for a
+// bundle share: one Download Token and the FEK and metadata of every file.
+func CreateShareBundleEnvelope(downloadToken []byte, files []ShareBundleEntry) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("a share bundle needs at least one file")
+	}
+	envelope := ShareEnvelope{
+		DownloadToken: base64.StdEncoding.EncodeToString(downloadToken),
+		Files:         files,
+		KDFParams: &ShareKDFParamsEmbedded{
+			Algorithm: "argon2id",
+			MemoryKiB: ShareKDFParams.Memory,
+			Time:      ShareKDFParams.Iterations,
+			Parallel:  ShareKDFParams.Parallelism,
+			KeyLen:    ShareKDFParams.KeyLength,
+		},
+	}
+
+	envelopeJSON, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal share envelope: %w", err)
+	}
+
+	return envelopeJSON, nil
+}
+
 // ParseShareEnvelope parses a Share Envelope JSON payload
 func ParseShareEnvelope(envelopeJSON []byte) (*ShareEnvelope, error) {
 	var envelope ShareEnvelope
@@ -190,9 +236,14 @@ func ParseShareEnvelope(envelopeJSON []byte) (*ShareEnvelope, error) {
 	}
 
 	// Validate required fields
-	if envelope.FEK == "" || envelope.DownloadToken == "" {
+	if (envelope.FEK == "" && !envelope.IsBundle()) || envelope.DownloadToken == "" {
 		return nil, fmt.Errorf("invalid envelope: missing required fields")
 	}
+	for _, f := range envelope.Files {
+		if f.FileID == "" || f.FEK == "" {
+			return nil, fmt.Errorf("invalid envelope: bundle entry missing file_id or fek")
+		}
+	}
 
 	// Validate KDF parameters
 	if err := ValidateShareKDFParams(envelope.KDFParams); err != nil {
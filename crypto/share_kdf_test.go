@@ -328,6 +328,48 @@ func TestParseShareEnvelope_MissingDownloadToken(t *testing.T) {
 	}
 }
 
+// TestCreateParseShareBundleEnvelope_RoundTrip verifies a bundle envelope
+// keeps each file's FEK and metadata and carries no top-level FEK
+func TestCreateParseShareBundleEnvelope_RoundTrip(t *testing.T) {
+	downloadToken := make([]byte, 32)
+	files := []ShareBundleEntry{
+		{FileID: "file-a", FEK: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), Filename: "a.txt", SizeBytes: 10, SHA256: "aa"},
+		{FileID: "file-b", FEK: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x02}, 32)), Filename: "docs/b.txt", SizeBytes: 20, SHA256: "bb"},
+	}
+
+	envelopeJSON, err := CreateShareBundleEnvelope(downloadToken, files)
+	if err != nil {
+		t.Fatalf("CreateShareBundleEnvelope failed: %v", err)
+	}
+
+	parsed, err := ParseShareEnvelope(envelopeJSON)
+	if err != nil {
+		t.Fatalf("ParseShareEnvelope failed: %v", err)
+	}
+	if !parsed.IsBundle() || parsed.FEK != "" {
+		t.Fatalf("expected a bundle envelope without a top-level FEK, got %+v", parsed)
+	}
+	if len(parsed.Files) != 2 || parsed.Files[0] != files[0] || parsed.Files[1] != files[1] {
+		t.Errorf("bundle entries mismatch: got %+v", parsed.Files)
+	}
+
+	if _, err := CreateShareBundleEnvelope(downloadToken, nil); err == nil {
+		t.Error("CreateShareBundleEnvelope should reject an empty bundle")
+	}
+}
+
+// TestParseShareEnvelope_BundleEntryMissingFEK verifies incomplete bundle entries are rejected
+func TestParseShareEnvelope_BundleEntryMissingFEK(t *testing.T) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"download_token": base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		"files":          []map[string]string{{"file_id": "file-a"}},
+	})
+
+	if _, err := ParseShareEnvelope(data); err == nil {
+		t.Error("ParseShareEnvelope should reject a bundle entry without a FEK")
+	}
+}
+
 // TestParseShareEnvelope_InvalidJSON verifies malformed JSON is rejected
 func TestParseShareEnvelope_InvalidJSON(t *testing.T) {
 	_, err := ParseShareEnvelope([]byte("{not valid json"))
@@ -0,0 +1,66 @@
+package crypto
+
+// User encryption keys
+//
+// Each account may publish a hybrid public key (see hybrid_kem.go) so other
+// users can share files with it directly, without a share password. The
+// private key is stored on the server wrapped with the user's account key.
+//
+// To share a file, the owner unwraps its FEK and seals it to the recipient's
+// public key with key type 0x04 (see "Sealing FEKs to a hybrid key" in
+// hybrid_kem.go). The metadata key derived alongside encrypts a copy of the
+// filename and SHA-256 for the recipient, with AAD =
+// BuildMetadataFieldAAD(fileID, field, ownerUsername). The file's chunks are
+// not re-encrypted. Users compare HybridKeyFingerprint of a public key out
+// of band before trusting it.
+
+// userShareFEKInfo holds the HKDF info strings for the keys derived from a
+// user share's KEM shared secret.
+var userShareFEKInfo = hybridFEKInfo{
+	fek:      "arkfile-user-share-fek-v1",
+	metadata: "arkfile-user-share-metadata-v1",
+}
+
+// userKeyAADLabel separates the user private key AAD from other AAD shapes.
+const userKeyAADLabel = "user_private_key"
+
+// UserShareFEKEnvelopeSize returns the size of a user share FEK envelope
+// holding a 32-byte FEK.
+func UserShareFEKEnvelopeSize() int {
+	return hybridFEKEnvelopeSize()
+}
+
+// SealUserShareFEK seals fek to a recipient's public key. It returns the FEK
+// envelope and the key for the recipient's copy of the file metadata.
+// Callers should clear metadataKey after use.
+func SealUserShareFEK(publicKey []byte, fileID string, fek []byte) (envelope, metadataKey []byte, err error) {
+	return sealHybridFEK(publicKey, fileID, "user", userShareFEKInfo, fek)
+}
+
+// OpenUserShareFEK opens a FEK envelope produced by SealUserShareFEK and
+// returns the FEK and the metadata key.
+func OpenUserShareFEK(privateKey *HybridPrivateKey, fileID string, envelope []byte) (fek, metadataKey []byte, err error) {
+	return openHybridFEK(privateKey, fileID, "user", userShareFEKInfo, envelope)
+}
+
+// BuildUserKeyAAD constructs the AAD for a user's private key wrapped with
+// their account key. Binding the username stops the server from handing one
+// user's key to another.
+//
+//	[4B len(label)][label][4B len(username)][username]
+func BuildUserKeyAAD(username string) []byte {
+	out := make([]byte, 0, 8+len(userKeyAADLabel)+len(username))
+	out = appendLenPrefixedString(out, []byte(userKeyAADLabel))
+	out = appendLenPrefixedString(out, []byte(username))
+	return out
+}
+
+// WrapUserPrivateKey encrypts a user's private key with their account key.
+func WrapUserPrivateKey(privateKey *HybridPrivateKey, accountKey []byte, username string) ([]byte, error) {
+	return wrapHybridPrivateKey(privateKey, accountKey, BuildUserKeyAAD(username))
+}
+
+// UnwrapUserPrivateKey decrypts a private key produced by WrapUserPrivateKey.
+func UnwrapUserPrivateKey(wrapped, accountKey []byte, username string) (*HybridPrivateKey, error) {
+	return unwrapHybridPrivateKey(wrapped, accountKey, BuildUserKeyAAD(username))
+}
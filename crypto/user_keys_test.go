@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUserShareFEK_SealOpen(t *testing.T) {
+	key, err := GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fek := bytes.Repeat([]byte{0x24}, 32)
+
+	envelope, metaKey, err := SealUserShareFEK(key.PublicKey(), "file-1", fek)
+	if err != nil {
+		t.Fatalf("SealUserShareFEK failed: %v", err)
+	}
+	if len(envelope) != UserShareFEKEnvelopeSize() || envelope[0] != 0x01 || envelope[1] != 0x04 {
+		t.Fatalf("envelope is %d bytes with header %x", len(envelope), envelope[:2])
+	}
+
+	gotFEK, gotMetaKey, err := OpenUserShareFEK(key, "file-1", envelope)
+	if err != nil {
+		t.Fatalf("OpenUserShareFEK failed: %v", err)
+	}
+	if !bytes.Equal(gotFEK, fek) || !bytes.Equal(gotMetaKey, metaKey) {
+		t.Error("opened FEK or metadata key differs")
+	}
+
+	if _, _, err := OpenUserShareFEK(key, "file-2", envelope); err == nil {
+		t.Error("expected an error for a different file ID")
+	}
+	other, _ := GenerateHybridKey()
+	if _, _, err := OpenUserShareFEK(other, "file-1", envelope); err == nil {
+		t.Error("expected an error for a different recipient key")
+	}
+
+	// Drop and user envelopes are not interchangeable
+	if _, _, err := OpenDropFEK(key, "file-1", envelope); err == nil {
+		t.Error("expected an error opening a user share envelope as a drop")
+	}
+	dropEnvelope, dropMetaKey, err := SealDropFEK(key.PublicKey(), "file-1", fek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SecureClear(dropMetaKey)
+	relabelled := append([]byte{0x01, 0x04}, dropEnvelope[2:]...)
+	if _, _, err := OpenUserShareFEK(key, "file-1", relabelled); err == nil {
+		t.Error("expected an error for a relabelled drop envelope")
+	}
+}
+
+func TestUserPrivateKey_Wrap(t *testing.T) {
+	key, err := GenerateHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKey := bytes.Repeat([]byte{0x09}, 32)
+
+	wrapped, err := WrapUserPrivateKey(key, accountKey, "alice")
+	if err != nil {
+		t.Fatalf("WrapUserPrivateKey failed: %v", err)
+	}
+	restored, err := UnwrapUserPrivateKey(wrapped, accountKey, "alice")
+	if err != nil {
+		t.Fatalf("UnwrapUserPrivateKey failed: %v", err)
+	}
+	if !bytes.Equal(restored.PublicKey(), key.PublicKey()) {
+		t.Error("unwrapped key differs")
+	}
+	if _, err := UnwrapUserPrivateKey(wrapped, accountKey, "bob"); err == nil {
+		t.Error("expected an error for a different username")
+	}
+	if _, err := UnwrapDropPrivateKey(wrapped, accountKey, "alice", "alice"); err == nil {
+		t.Error("expected an error unwrapping a user key as a drop key")
+	}
+}
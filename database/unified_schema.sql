@@ -19,6 +19,8 @@ CREATE TABLE IF NOT EXISTS users (
     last_login TIMESTAMP,
     registration_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     requires_reregistration BOOLEAN NOT NULL DEFAULT false,  -- Set when an operator rotates the account's OPAQUE credentials; login routes the user through one-time re-registration.
+    placement_policy TEXT DEFAULT NULL,        -- storage_placement_policies.name; NULL uses the server-wide write policy
+    version_retention INTEGER NOT NULL DEFAULT 0, -- versions kept per file when a new version is uploaded; 0 keeps all
     deleted_at TIMESTAMP DEFAULT NULL          -- Soft-deletion indicator. NULL means active.
 );
 
@@ -34,6 +36,10 @@ CREATE TABLE IF NOT EXISTS file_metadata (
     encrypted_filename TEXT NOT NULL,           -- base64-encoded AES-GCM encrypted filename
     sha256sum_nonce TEXT NOT NULL,              -- base64-encoded 12-byte nonce for sha256 encryption  
     encrypted_sha256sum TEXT NOT NULL,          -- base64-encoded AES-GCM encrypted sha256 hash
+    folder_nonce TEXT DEFAULT NULL,             -- base64-encoded 12-byte nonce for folder path encryption
+    encrypted_folder TEXT DEFAULT NULL,         -- base64-encoded AES-GCM encrypted folder path; NULL means the top level
+    version_group VARCHAR(36) DEFAULT NULL,     -- file_id of the first version in this file's version chain; NULL if never versioned
+    version_number INTEGER NOT NULL DEFAULT 1,  -- 1-based position in the version chain; the highest is the current version
     encrypted_file_sha256sum CHAR(64),          -- sha256sum of the final encrypted file in storage (pre-padding)
     stored_blob_sha256sum CHAR(64),             -- sha256sum of the complete S3 object (encrypted data + padding)
     encrypted_fek TEXT NOT NULL,                -- base64-encoded AES-GCM encrypted FEK envelope (AAD-bound to file_id + key_type)
@@ -42,6 +48,8 @@ CREATE TABLE IF NOT EXISTS file_metadata (
     chunk_count INTEGER NOT NULL DEFAULT 1,     -- Number of 16MB chunks for chunked downloads
     chunk_size_bytes INTEGER NOT NULL DEFAULT 16777216, -- Size of each chunk (16MB default, last chunk may be smaller)
     upload_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_accessed_at TIMESTAMP DEFAULT NULL,    -- last download by owner or share recipient (drives storage tiering)
+    deleted_at TIMESTAMP DEFAULT NULL,          -- when the owner moved the file to the trash; NULL means live
     FOREIGN KEY (owner_username) REFERENCES users(username) ON DELETE CASCADE
 );
 
@@ -212,10 +220,81 @@ CREATE TABLE IF NOT EXISTS file_share_keys (
     max_accesses INTEGER,                       -- Optional access limit
     revoked_at DATETIME,                        -- Timestamp when the share was revoked
     revoked_reason TEXT,                        -- Reason for revocation (e.g., 'manual_revocation', 'max_downloads_reached')
+    suspended_at DATETIME,                      -- Set while the owner has paused the share (resumable, unlike revocation)
     FOREIGN KEY (owner_username) REFERENCES users(username) ON DELETE CASCADE,
     FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE
 );
 
+-- Members of a bundle share (one link and password for several files). The share's
+-- file_share_keys.file_id is the first member and binds the envelope AAD; permanently
+-- deleting that file removes the whole bundle. max_accesses applies per member, and
+-- file_share_keys.access_count mirrors the lowest member count.
+CREATE TABLE IF NOT EXISTS file_share_bundle_files (
+    share_id TEXT NOT NULL,
+    file_id TEXT NOT NULL,
+    position INTEGER NOT NULL,                  -- Order of the file in the Share Envelope
+    access_count INTEGER NOT NULL DEFAULT 0,    -- Completed downloads of this member
+    PRIMARY KEY (share_id, file_id),
+    FOREIGN KEY (share_id) REFERENCES file_share_keys(share_id) ON DELETE CASCADE,
+    FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE
+);
+
+-- Download history of anonymous shares, shown to the share owner. A request for
+-- chunk 0 starts a new row; later chunks from the same entity extend the latest one.
+-- entity_id is the privacy-preserving identifier from logging.EntityIDService (it
+-- rotates daily for anonymous clients); raw IP addresses are never stored.
+CREATE TABLE IF NOT EXISTS share_accesses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    share_id TEXT NOT NULL,
+    file_id TEXT NOT NULL,                      -- File downloaded (the member for bundles)
+    entity_id TEXT NOT NULL,                    -- Privacy-preserving entity identifier
+    chunk_count INTEGER NOT NULL,               -- Chunks in the file when the download started
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_chunk_at DATETIME,                     -- Last chunk served in full
+    completed_at DATETIME,                      -- Set once every chunk has been served
+    FOREIGN KEY (share_id) REFERENCES file_share_keys(share_id) ON DELETE CASCADE
+);
+
+-- Chunks served in full for each share access (the access's chunk-range coverage)
+CREATE TABLE IF NOT EXISTS share_access_chunks (
+    access_id INTEGER NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    PRIMARY KEY (access_id, chunk_index),
+    FOREIGN KEY (access_id) REFERENCES share_accesses(id) ON DELETE CASCADE
+);
+
+-- Per-user encryption keys for direct user-to-user shares. The X25519 + ML-KEM-768
+-- public key is served to other users; the private key is stored wrapped with the
+-- user's account key.
+CREATE TABLE IF NOT EXISTS user_encryption_keys (
+    username TEXT PRIMARY KEY,
+    public_key TEXT NOT NULL,                   -- base64 hybrid public key
+    encrypted_private_key TEXT NOT NULL,        -- base64 hybrid private key, AES-GCM under the account key
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (username) REFERENCES users(username) ON DELETE CASCADE
+);
+
+-- Files shared directly with another user. encrypted_fek is sealed to the recipient's
+-- public key (key type 0x04); the filename and SHA-256 are a copy encrypted for the
+-- recipient. Revoking a share deletes its row.
+CREATE TABLE IF NOT EXISTS user_file_shares (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    share_id TEXT NOT NULL UNIQUE,              -- Server-generated identifier
+    file_id TEXT NOT NULL,
+    owner_username TEXT NOT NULL,
+    recipient_username TEXT NOT NULL,
+    encrypted_fek TEXT NOT NULL,                -- base64 FEK envelope sealed to the recipient
+    encrypted_filename TEXT NOT NULL,
+    filename_nonce TEXT NOT NULL,
+    encrypted_sha256sum TEXT NOT NULL,
+    sha256sum_nonce TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(file_id, recipient_username),
+    FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_username) REFERENCES users(username) ON DELETE CASCADE,
+    FOREIGN KEY (recipient_username) REFERENCES users(username) ON DELETE CASCADE
+);
+
 
 -- =====================================================
 -- PHASE 7: CHUNKED UPLOAD SYSTEM
@@ -229,6 +308,9 @@ CREATE TABLE IF NOT EXISTS upload_sessions (
     filename_nonce TEXT NOT NULL,
     encrypted_sha256sum TEXT NOT NULL,
     sha256sum_nonce TEXT NOT NULL,
+    encrypted_folder TEXT DEFAULT NULL,
+    folder_nonce TEXT DEFAULT NULL,
+    version_group VARCHAR(36) DEFAULT NULL,     -- version chain the completed file joins; NULL for a standalone upload
     owner_username TEXT NOT NULL,
     total_size BIGINT NOT NULL,
     chunk_size INTEGER NOT NULL,
@@ -237,6 +319,7 @@ CREATE TABLE IF NOT EXISTS upload_sessions (
     password_type TEXT NOT NULL,
     storage_upload_id TEXT,
     storage_id VARCHAR(36),
+    provider_id TEXT,                          -- provider holding the multipart upload; NULL means the primary
     padded_size BIGINT,
     status TEXT NOT NULL DEFAULT 'in_progress',
     encrypted_hash CHAR(64),
@@ -247,6 +330,34 @@ CREATE TABLE IF NOT EXISTS upload_sessions (
     FOREIGN KEY (owner_username) REFERENCES users(username) ON DELETE CASCADE
 );
 
+-- File drops: links through which people without an account upload files into the
+-- owner's vault. Uploads are encrypted to the drop's X25519 + ML-KEM-768 public key;
+-- the private key is stored wrapped with the owner's account key. Expiry stops new
+-- uploads only; revoking a drop also stops uploads already in progress.
+CREATE TABLE IF NOT EXISTS file_drops (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    drop_id TEXT NOT NULL UNIQUE,               -- 256-bit crypto-secure identifier (Client-generated)
+    owner_username TEXT NOT NULL,
+    public_key TEXT NOT NULL,                   -- base64 hybrid public key served to senders
+    encrypted_private_key TEXT NOT NULL,        -- base64 hybrid private key, AES-GCM under the owner's account key
+    max_bytes BIGINT NOT NULL,                  -- Total encrypted bytes the drop accepts
+    max_uploads INTEGER,                        -- Optional number of files (NULL = unlimited)
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    FOREIGN KEY (owner_username) REFERENCES users(username) ON DELETE CASCADE
+);
+
+-- Upload sessions opened through a file drop. In-progress and completed sessions
+-- count toward the drop's max_bytes and max_uploads.
+CREATE TABLE IF NOT EXISTS file_drop_uploads (
+    session_id TEXT PRIMARY KEY,
+    drop_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (drop_id) REFERENCES file_drops(drop_id) ON DELETE CASCADE
+);
+
 -- Individual chunk tracking
 CREATE TABLE IF NOT EXISTS upload_chunks (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -514,13 +625,49 @@ CREATE TABLE IF NOT EXISTS file_storage_locations (
     file_id VARCHAR(36) NOT NULL,
     provider_id TEXT NOT NULL,
     storage_id VARCHAR(36) NOT NULL,
-    status TEXT NOT NULL DEFAULT 'active',
+    status TEXT NOT NULL DEFAULT 'active',      -- 'tiered': copy moved to a cheaper provider by tier-storage
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     verified_at TIMESTAMP,
     FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE,
     FOREIGN KEY (provider_id) REFERENCES storage_providers(provider_id)
 );
 
+-- Erasure-coded blobs (STORAGE_WRITE_POLICY=erasure): one row per Reed-Solomon shard,
+-- each stored as its own object (object_name) on a different provider. Any data_shards
+-- of a blob's shards rebuild it (see storage/erasure.go). Files stored this way have
+-- no file_storage_locations rows.
+CREATE TABLE IF NOT EXISTS file_erasure_shards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    file_id VARCHAR(36) NOT NULL,
+    storage_id VARCHAR(36) NOT NULL,
+    shard_index INTEGER NOT NULL,               -- 0..data_shards-1 hold data, the rest parity
+    data_shards INTEGER NOT NULL,
+    parity_shards INTEGER NOT NULL,
+    stripe_unit INTEGER NOT NULL,               -- bytes per shard per stripe
+    object_size BIGINT NOT NULL,                -- size of the striped blob (padded_size)
+    provider_id TEXT NOT NULL,
+    object_name TEXT NOT NULL,
+    sha256 CHAR(64) NOT NULL,                   -- hash of the whole shard object
+    status TEXT NOT NULL DEFAULT 'active',      -- 'active', 'missing', 'corrupt'
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    verified_at TIMESTAMP,                      -- last time the scrubber matched sha256
+    FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE,
+    FOREIGN KEY (provider_id) REFERENCES storage_providers(provider_id)
+);
+
+-- Storage placement policies: named per-user placement rules (e.g. data residency).
+-- Users assigned to a policy (users.placement_policy) only have copies on the listed
+-- providers. provider_ids is an ordered JSON array: uploads land on the first available
+-- provider and replicas go to the following ones until `copies` copies exist.
+CREATE TABLE IF NOT EXISTS storage_placement_policies (
+    name TEXT PRIMARY KEY,
+    provider_ids TEXT NOT NULL,
+    copies INTEGER NOT NULL DEFAULT 1,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Admin tasks: tracks background task progress for long-running admin operations.
 CREATE TABLE IF NOT EXISTS admin_tasks (
     task_id TEXT PRIMARY KEY,
@@ -538,6 +685,31 @@ CREATE TABLE IF NOT EXISTS admin_tasks (
     FOREIGN KEY (admin_username) REFERENCES users(username) ON DELETE CASCADE
 );
 
+-- Encrypted search index: blind-index tokens the client derives from its account key and
+-- the file's decrypted name and folder (see crypto/search_index.go). The server matches
+-- tokens against search queries without learning the keywords behind them.
+CREATE TABLE IF NOT EXISTS file_search_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    file_id VARCHAR(36) NOT NULL,
+    owner_username TEXT NOT NULL,
+    token CHAR(32) NOT NULL,                    -- hex of a truncated HMAC-SHA256
+    FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE
+);
+
+-- File annotations: client-encrypted key/value tags (a JSON object) and a free-text note per
+-- file. Both are AES-GCM ciphertexts under the account key, AAD-bound to the file like
+-- the filename; the server stores and returns them but cannot read them.
+CREATE TABLE IF NOT EXISTS file_annotations (
+    file_id VARCHAR(36) PRIMARY KEY,
+    owner_username TEXT NOT NULL,
+    tags_nonce TEXT,
+    encrypted_tags TEXT,
+    note_nonce TEXT,
+    encrypted_note TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_id) REFERENCES file_metadata(file_id) ON DELETE CASCADE
+);
+
 -- Additive column for existing deployments: SHA-256 of the complete S3 object (encrypted data + padding).
 -- Migration for stored_blob_sha256sum is handled in Go startup code (main.go)
 -- to gracefully handle both fresh installs and existing deployments.
@@ -586,7 +758,12 @@ CREATE INDEX IF NOT EXISTS idx_file_share_keys_file_id ON file_share_keys(file_i
 CREATE INDEX IF NOT EXISTS idx_file_share_keys_owner ON file_share_keys(owner_username);
 CREATE INDEX IF NOT EXISTS idx_file_share_keys_expires_at ON file_share_keys(expires_at);
 CREATE INDEX IF NOT EXISTS idx_file_share_keys_revoked ON file_share_keys(revoked_at);
+CREATE INDEX IF NOT EXISTS idx_share_bundle_files_file ON file_share_bundle_files(file_id);
 CREATE INDEX IF NOT EXISTS idx_file_share_keys_token_hash ON file_share_keys(download_token_hash);
+CREATE INDEX IF NOT EXISTS idx_share_accesses_share ON share_accesses(share_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_share_accesses_entity ON share_accesses(share_id, file_id, entity_id);
+CREATE INDEX IF NOT EXISTS idx_user_file_shares_owner ON user_file_shares(owner_username);
+CREATE INDEX IF NOT EXISTS idx_user_file_shares_recipient ON user_file_shares(recipient_username);
 
 -- Upload session indexes
 -- file_id must be globally unique across both file_metadata and
@@ -597,6 +774,8 @@ CREATE INDEX IF NOT EXISTS idx_upload_sessions_owner ON upload_sessions(owner_us
 CREATE INDEX IF NOT EXISTS idx_upload_sessions_status ON upload_sessions(status);
 CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires ON upload_sessions(expires_at);
 CREATE INDEX IF NOT EXISTS idx_upload_chunks_session ON upload_chunks(session_id);
+CREATE INDEX IF NOT EXISTS idx_file_drops_owner ON file_drops(owner_username);
+CREATE INDEX IF NOT EXISTS idx_file_drop_uploads_drop ON file_drop_uploads(drop_id);
 
 -- Security and monitoring indexes
 CREATE INDEX IF NOT EXISTS idx_events_window ON security_events(time_window, event_type);
@@ -655,10 +834,18 @@ CREATE INDEX IF NOT EXISTS idx_fsl_provider_id ON file_storage_locations(provide
 CREATE INDEX IF NOT EXISTS idx_fsl_status ON file_storage_locations(status);
 CREATE UNIQUE INDEX IF NOT EXISTS idx_fsl_file_provider ON file_storage_locations(file_id, provider_id);
 
+CREATE INDEX IF NOT EXISTS idx_fes_file_id ON file_erasure_shards(file_id);
+CREATE INDEX IF NOT EXISTS idx_fes_provider_id ON file_erasure_shards(provider_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fes_storage_shard ON file_erasure_shards(storage_id, shard_index);
+
 CREATE INDEX IF NOT EXISTS idx_admin_tasks_status ON admin_tasks(status);
 CREATE INDEX IF NOT EXISTS idx_admin_tasks_type ON admin_tasks(task_type);
 CREATE INDEX IF NOT EXISTS idx_admin_tasks_admin ON admin_tasks(admin_username);
 
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fst_file_token ON file_search_tokens(file_id, token);
+CREATE INDEX IF NOT EXISTS idx_fst_owner_token ON file_search_tokens(owner_username, token);
+CREATE INDEX IF NOT EXISTS idx_file_annotations_owner ON file_annotations(owner_username);
+
 -- =====================================================
 -- PHASE 14: TRIGGERS FOR AUTOMATIC UPDATES
 -- =====================================================
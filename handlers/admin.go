@@ -601,6 +601,19 @@ func DeleteUser(c echo.Context) error {
 		return JSONError(c, http.StatusInternalServerError, "Failed to delete user's file shares")
 	}
 
+	// Close the user's file drops
+	if _, err := tx.Exec("DELETE FROM file_drops WHERE owner_username = ?", targetUsername); err != nil {
+		return JSONError(c, http.StatusInternalServerError, "Failed to delete user's file drops")
+	}
+
+	// Remove direct shares to and from the user, then the user's encryption key
+	if _, err := tx.Exec("DELETE FROM user_file_shares WHERE owner_username = ? OR recipient_username = ?", targetUsername, targetUsername); err != nil {
+		return JSONError(c, http.StatusInternalServerError, "Failed to delete user's direct shares")
+	}
+	if _, err := tx.Exec("DELETE FROM user_encryption_keys WHERE username = ?", targetUsername); err != nil {
+		return JSONError(c, http.StatusInternalServerError, "Failed to delete user's encryption key")
+	}
+
 	// Soft-delete user record. Set deleted_at timestamp instead of hard-deleting the row.
 	// This preserves audit records and structural integrity while immediately locking out the user.
 	if _, err := tx.Exec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE username = ?", targetUsername); err != nil {
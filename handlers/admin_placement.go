@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+// AdminListPlacementPolicies handles GET /api/admin/storage/placement-policies
+func AdminListPlacementPolicies(c echo.Context) error {
+	policies, err := models.ListPlacementPolicies(database.DB)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to list placement policies: %v", err)
+		return JSONError(c, http.StatusInternalServerError, "Failed to list placement policies")
+	}
+
+	return JSONResponse(c, http.StatusOK, "Placement policies retrieved", map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// AdminSetPlacementPolicy handles POST /api/admin/storage/placement-policies
+// Creates or replaces a named placement policy. Every provider must be known
+// in storage_providers. Changing a policy affects new uploads and later copy,
+// repair, and drain tasks; existing copies are not moved.
+func AdminSetPlacementPolicy(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+
+	var req struct {
+		Name        string   `json:"name"`
+		ProviderIDs []string `json:"provider_ids"`
+		Copies      int      `json:"copies"`
+		Description string   `json:"description"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return JSONError(c, http.StatusBadRequest, "Invalid request")
+	}
+	if req.Copies == 0 {
+		req.Copies = 1
+	}
+
+	policy := &models.PlacementPolicy{
+		Name:        strings.TrimSpace(req.Name),
+		ProviderIDs: req.ProviderIDs,
+		Copies:      req.Copies,
+		Description: req.Description,
+	}
+	if err := policy.Validate(); err != nil {
+		return JSONError(c, http.StatusBadRequest, err.Error())
+	}
+	for _, id := range policy.ProviderIDs {
+		if _, err := models.GetStorageProviderByID(database.DB, id); err == sql.ErrNoRows {
+			return JSONError(c, http.StatusBadRequest, "Unknown storage provider: "+id)
+		} else if err != nil {
+			return JSONError(c, http.StatusInternalServerError, "Failed to look up storage provider")
+		}
+	}
+
+	if err := models.UpsertPlacementPolicy(database.DB, policy); err != nil {
+		logging.ErrorLogger.Printf("Failed to save placement policy %s: %v", policy.Name, err)
+		return JSONError(c, http.StatusInternalServerError, "Failed to save placement policy")
+	}
+
+	LogAdminAction(database.DB, adminUsername, "set_placement_policy", "",
+		fmt.Sprintf("policy=%s providers=%s copies=%d", policy.Name, strings.Join(policy.ProviderIDs, ","), policy.Copies))
+
+	return JSONResponse(c, http.StatusOK, "Placement policy saved", map[string]interface{}{
+		"policy": policy,
+	})
+}
+
+// AdminDeletePlacementPolicy handles DELETE /api/admin/storage/placement-policies/:name
+// A policy can only be deleted once no user is assigned to it.
+func AdminDeletePlacementPolicy(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+	name := c.Param("name")
+
+	err := models.DeletePlacementPolicy(database.DB, name)
+	if errors.Is(err, models.ErrPlacementPolicyInUse) {
+		return JSONError(c, http.StatusConflict, "Placement policy is still assigned to users; reassign them first")
+	} else if err == sql.ErrNoRows {
+		return JSONError(c, http.StatusNotFound, "Placement policy not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to delete placement policy %s: %v", name, err)
+		return JSONError(c, http.StatusInternalServerError, "Failed to delete placement policy")
+	}
+
+	LogAdminAction(database.DB, adminUsername, "delete_placement_policy", "", "policy="+name)
+
+	return JSONResponse(c, http.StatusOK, "Placement policy deleted", map[string]interface{}{
+		"name": name,
+	})
+}
+
+// AdminSetUserPlacementPolicy handles PUT /api/admin/users/:username/placement-policy
+// Assigns a placement policy to a user; an empty policy returns the user to
+// the server-wide write policy. Files the user already stored keep their
+// current copies; run repair-storage to add copies the policy requires.
+func AdminSetUserPlacementPolicy(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+	targetUsername := c.Param("username")
+
+	var req struct {
+		Policy string `json:"policy"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return JSONError(c, http.StatusBadRequest, "Invalid request")
+	}
+
+	if req.Policy != "" {
+		if _, err := models.GetPlacementPolicy(database.DB, req.Policy); err == sql.ErrNoRows {
+			return JSONError(c, http.StatusNotFound, "Placement policy not found: "+req.Policy)
+		} else if err != nil {
+			return JSONError(c, http.StatusInternalServerError, "Failed to look up placement policy")
+		}
+	}
+
+	if err := models.SetUserPlacementPolicy(database.DB, targetUsername, req.Policy); err == sql.ErrNoRows {
+		return JSONError(c, http.StatusNotFound, fmt.Sprintf("User '%s' not found", targetUsername))
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to set placement policy for %s: %v", targetUsername, err)
+		return JSONError(c, http.StatusInternalServerError, "Failed to set placement policy")
+	}
+
+	LogAdminAction(database.DB, adminUsername, "set_user_placement_policy", targetUsername, "policy="+req.Policy)
+
+	return JSONResponse(c, http.StatusOK, "User placement policy updated", map[string]interface{}{
+		"username": targetUsername,
+		"policy":   req.Policy,
+	})
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/arkfile/Arkfile/auth"
-	"github.com/arkfile/Arkfile/config"
 	"github.com/arkfile/Arkfile/database"
 	"github.com/arkfile/Arkfile/logging"
 	"github.com/arkfile/Arkfile/models"
@@ -137,6 +136,11 @@ func AdminStorageStatus(c echo.Context) error {
 			p["last_verified_at"] = nil
 		}
 
+		// In-memory read health since server start (only for providers in the registry)
+		if storage.Registry.GetProvider(providerID) != nil {
+			p["read_stats"] = storage.Registry.ReadStatsFor(providerID)
+		}
+
 		providers = append(providers, p)
 	}
 
@@ -171,19 +175,26 @@ func AdminStorageStatus(c echo.Context) error {
 		partiallyReplicated = totalFiles - fullyReplicated
 	}
 
-	cfg, _ := config.LoadConfig()
-	replicationEnabled := false
-	if cfg != nil {
-		replicationEnabled = cfg.Storage.EnableUploadReplication
-	}
+	writePolicy := storage.Registry.WritePolicy()
+	replicationEnabled := writePolicy != storage.WritePolicyPrimaryOnly
+
+	var erasureCodedFiles int64
+	database.DB.QueryRow("SELECT COUNT(DISTINCT file_id) FROM file_erasure_shards").Scan(&erasureCodedFiles)
 
-	return JSONResponse(c, http.StatusOK, "Storage status retrieved", map[string]interface{}{
+	response := map[string]interface{}{
 		"providers":            providers,
 		"total_files":          totalFiles,
 		"fully_replicated":     fullyReplicated,
 		"partially_replicated": partiallyReplicated,
 		"replication_enabled":  replicationEnabled,
-	})
+		"write_policy":         string(writePolicy),
+		"write_quorum":         storage.Registry.RequiredWriteCopies(),
+		"erasure_coded_files":  erasureCodedFiles,
+	}
+	if dataShards, parityShards := storage.Registry.ErasureShards(); dataShards > 0 {
+		response["erasure_shards"] = fmt.Sprintf("%d+%d", dataShards, parityShards)
+	}
+	return JSONResponse(c, http.StatusOK, "Storage status retrieved", response)
 }
 
 // AdminSyncStatus handles GET /api/admin/storage/sync-status
@@ -803,6 +814,9 @@ func AdminAlertsSummary(c echo.Context) error {
 		"SELECT COUNT(*) FROM admin_tasks WHERE status = 'running'",
 	).Scan(&staleTasks)
 
+	// Copies the background scrubber found not matching their stored hash
+	integrityFailures, _ := models.CountUnacknowledgedSecurityAlerts(database.DB, models.AlertTypeStorageIntegrity)
+
 	// Sync gaps: files not on all configured active providers
 	configuredProviders := 1
 	if storage.Registry.HasSecondary() {
@@ -815,12 +829,13 @@ func AdminAlertsSummary(c echo.Context) error {
 		database.DB.QueryRow(`
 			SELECT COUNT(*) FROM file_metadata fm
 			WHERE (SELECT COUNT(DISTINCT fsl.provider_id) FROM file_storage_locations fsl
-			       WHERE fsl.file_id = fm.file_id AND fsl.status = 'active') < ?`,
+			       WHERE fsl.file_id = fm.file_id AND fsl.status = 'active') < ?
+			  AND fm.file_id NOT IN (SELECT file_id FROM file_erasure_shards)`,
 			configuredProviders,
 		).Scan(&syncGaps)
 	}
 
-	hasAlerts := replicationFailures > 0 || syncGaps > 0 || orphanedBlobs > 0 || staleTasks > 0
+	hasAlerts := replicationFailures > 0 || syncGaps > 0 || orphanedBlobs > 0 || staleTasks > 0 || integrityFailures > 0
 	message := ""
 	if hasAlerts {
 		parts := []string{}
@@ -836,6 +851,9 @@ func AdminAlertsSummary(c echo.Context) error {
 		if staleTasks > 0 {
 			parts = append(parts, formatAlertCount(staleTasks, "stale task", "stale tasks"))
 		}
+		if integrityFailures > 0 {
+			parts = append(parts, formatAlertCount(integrityFailures, "corrupt stored copy", "corrupt stored copies"))
+		}
 		message = joinAlertParts(parts) + ". Run 'storage-sync-status' for details."
 	}
 
@@ -845,6 +863,7 @@ func AdminAlertsSummary(c echo.Context) error {
 			"sync_gaps":            syncGaps,
 			"orphaned_blobs":       orphanedBlobs,
 			"stale_tasks":          staleTasks,
+			"integrity_failures":   integrityFailures,
 		},
 		"has_alerts": hasAlerts,
 		"message":    message,
@@ -906,6 +925,149 @@ func AdminVerifyAll(c echo.Context) error {
 	})
 }
 
+// AdminRepairStorage handles POST /api/admin/storage/repair
+// Initiates a background task that restores missing copies of files that have
+// fewer active locations than the replication target, verifying each new copy
+// against the file's stored blob hash.
+func AdminRepairStorage(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+
+	var req struct {
+		Target int    `json:"target"`
+		FileID string `json:"file_id"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return JSONError(c, http.StatusBadRequest, "Invalid request")
+	}
+	if req.Target < 0 {
+		return JSONError(c, http.StatusBadRequest, "target must not be negative")
+	}
+
+	tr := GetTaskRunner()
+	if tr == nil {
+		return JSONError(c, http.StatusInternalServerError, "Task runner not initialized")
+	}
+
+	taskID, err := tr.SubmitRepairTask(RepairTaskRequest{
+		AdminUsername: adminUsername,
+		Target:        req.Target,
+		FileID:        req.FileID,
+		DryRun:        req.DryRun,
+	})
+	if err != nil {
+		return JSONError(c, http.StatusBadRequest, err.Error())
+	}
+
+	return JSONResponse(c, http.StatusOK, "Repair task queued", map[string]interface{}{
+		"task_id":   taskID,
+		"task_type": "repair",
+		"status":    "pending",
+	})
+}
+
+// AdminDrainProvider handles POST /api/admin/storage/drain-provider
+// Initiates a background task that moves every file off a provider onto the
+// remaining providers, verifies the remaining copies, deletes the drained
+// copies, and marks the provider inactive.
+func AdminDrainProvider(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+
+	var req struct {
+		ProviderID string `json:"provider_id"`
+		Target     int    `json:"target"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return JSONError(c, http.StatusBadRequest, "Invalid request")
+	}
+	if req.ProviderID == "" {
+		return JSONError(c, http.StatusBadRequest, "provider_id is required")
+	}
+	if req.Target < 0 {
+		return JSONError(c, http.StatusBadRequest, "target must not be negative")
+	}
+
+	tr := GetTaskRunner()
+	if tr == nil {
+		return JSONError(c, http.StatusInternalServerError, "Task runner not initialized")
+	}
+
+	taskID, err := tr.SubmitDrainTask(DrainTaskRequest{
+		AdminUsername: adminUsername,
+		ProviderID:    req.ProviderID,
+		Target:        req.Target,
+	})
+	if err != nil {
+		return JSONError(c, http.StatusBadRequest, err.Error())
+	}
+
+	return JSONResponse(c, http.StatusOK, "Drain task queued", map[string]interface{}{
+		"task_id":   taskID,
+		"task_type": "drain-provider",
+		"status":    "pending",
+	})
+}
+
+// AdminTierStorage handles POST /api/admin/storage/tier
+// Initiates a background task that moves files not downloaded in
+// cold_after_days from the primary to the cheapest active provider, and moves
+// tiered files that were downloaded since back to the primary.
+func AdminTierStorage(c echo.Context) error {
+	adminUsername := auth.GetUsernameFromToken(c)
+
+	var req struct {
+		ColdAfterDays int `json:"cold_after_days"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return JSONError(c, http.StatusBadRequest, "Invalid request")
+	}
+	if req.ColdAfterDays < 0 {
+		return JSONError(c, http.StatusBadRequest, "cold_after_days must not be negative")
+	}
+
+	tr := GetTaskRunner()
+	if tr == nil {
+		return JSONError(c, http.StatusInternalServerError, "Task runner not initialized")
+	}
+
+	taskID, err := tr.SubmitTieringTask(TieringTaskRequest{
+		AdminUsername: adminUsername,
+		ColdAfterDays: req.ColdAfterDays,
+	})
+	if err != nil {
+		return JSONError(c, http.StatusBadRequest, err.Error())
+	}
+
+	return JSONResponse(c, http.StatusOK, "Tiering task queued", map[string]interface{}{
+		"task_id":   taskID,
+		"task_type": "tier-storage",
+		"status":    "pending",
+	})
+}
+
+// AdminTieringReport handles GET /api/admin/storage/tiering-report
+// Reports which files tier-storage would move for ?cold_after_days=N and the
+// projected monthly savings, based on the providers' cost_per_tb_cents.
+func AdminTieringReport(c echo.Context) error {
+	coldAfterDays := 0
+	if v := c.QueryParam("cold_after_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return JSONError(c, http.StatusBadRequest, "cold_after_days must be a non-negative integer")
+		}
+		coldAfterDays = days
+	}
+
+	report, err := buildTieringReport(coldAfterDays)
+	if err != nil {
+		return JSONError(c, http.StatusBadRequest, err.Error())
+	}
+
+	return JSONResponse(c, http.StatusOK, "Tiering report generated", map[string]interface{}{
+		"report": report,
+	})
+}
+
 // AdminListTasks handles GET /api/admin/storage/tasks
 // Lists admin tasks, optionally filtered by status.
 func AdminListTasks(c echo.Context) error {
@@ -977,10 +1139,10 @@ func AdminListTasks(c echo.Context) error {
 // AdminCancelAllTasks handles POST /api/admin/storage/cancel-all-tasks
 func AdminCancelAllTasks(c echo.Context) error {
 	var req struct {
-		Type string `json:"type"` // "copy", "verify", "all"
+		Type string `json:"type"` // "copy", "verify", "repair", "drain", "tier", "all"
 	}
 	if err := c.Bind(&req); err != nil || req.Type == "" {
-		return JSONError(c, http.StatusBadRequest, "type (copy, verify, all) is required")
+		return JSONError(c, http.StatusBadRequest, "type (copy, verify, repair, drain, tier, all) is required")
 	}
 
 	tr := GetTaskRunner()
@@ -40,6 +40,10 @@ func TestAdminStorageStatus_SingleProvider(t *testing.T) {
 	mockDB.ExpectQuery(`SELECT COUNT\(\*\) FROM file_metadata`).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(50)))
 
+	// Mock erasure-coded file count
+	mockDB.ExpectQuery(`SELECT COUNT\(DISTINCT file_id\) FROM file_erasure_shards`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(0)))
+
 	err := AdminStorageStatus(c)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -51,7 +55,10 @@ func TestAdminStorageStatus_SingleProvider(t *testing.T) {
 
 	data := resp["data"].(map[string]interface{})
 	assert.Equal(t, false, data["replication_enabled"])
+	assert.Equal(t, "primary-only", data["write_policy"])
 	assert.Equal(t, float64(50), data["total_files"])
+	assert.Equal(t, float64(0), data["erasure_coded_files"])
+	assert.NotContains(t, data, "erasure_shards")
 
 	providers := data["providers"].([]interface{})
 	assert.Len(t, providers, 1)
@@ -74,7 +81,9 @@ func TestAdminStorageStatus_MultiProvider(t *testing.T) {
 	// Set up secondary provider on registry
 	mockSecondary := &storage.MockObjectStorageProvider{}
 	storage.Registry.SetSecondary(mockSecondary, "wasabi-us-central-1")
+	require.NoError(t, storage.Registry.SetWritePolicy(storage.WritePolicyQuorum, 2))
 	t.Cleanup(func() {
+		storage.Registry.SetWritePolicy(storage.WritePolicyPrimaryOnly, 1)
 		storage.Registry.SetSecondary(nil, "")
 	})
 
@@ -99,6 +108,10 @@ func TestAdminStorageStatus_MultiProvider(t *testing.T) {
 		WithArgs(2).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(80)))
 
+	// Mock erasure-coded file count
+	mockDB.ExpectQuery(`SELECT COUNT\(DISTINCT file_id\) FROM file_erasure_shards`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(0)))
+
 	err := AdminStorageStatus(c)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -108,6 +121,9 @@ func TestAdminStorageStatus_MultiProvider(t *testing.T) {
 	require.NoError(t, err)
 
 	data := resp["data"].(map[string]interface{})
+	assert.Equal(t, true, data["replication_enabled"])
+	assert.Equal(t, "quorum", data["write_policy"])
+	assert.Equal(t, float64(2), data["write_quorum"])
 	providers := data["providers"].([]interface{})
 	assert.Len(t, providers, 2)
 
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/arkfile/Arkfile/config"
 	"github.com/arkfile/Arkfile/database"
 	"github.com/arkfile/Arkfile/logging"
 	"github.com/arkfile/Arkfile/models"
@@ -58,7 +59,7 @@ func (tr *TaskRunner) CancelTask(taskID string) bool {
 	return false
 }
 
-// CancelTasksByCategory cancels active tasks of a specific category ("copy", "verify", "all").
+// CancelTasksByCategory cancels active tasks of a specific category ("copy", "verify", "repair", "drain", "tier", "all").
 // Returns the count of active tasks that were successfully cancelled.
 func (tr *TaskRunner) CancelTasksByCategory(category string) (int, error) {
 	var query string
@@ -69,6 +70,12 @@ func (tr *TaskRunner) CancelTasksByCategory(category string) (int, error) {
 		query = "SELECT task_id FROM admin_tasks WHERE status = 'running' AND task_type LIKE 'copy-%'"
 	case "verify":
 		query = "SELECT task_id FROM admin_tasks WHERE status = 'running' AND task_type LIKE 'verify-%'"
+	case "repair":
+		query = "SELECT task_id FROM admin_tasks WHERE status = 'running' AND task_type = 'repair'"
+	case "drain":
+		query = "SELECT task_id FROM admin_tasks WHERE status = 'running' AND task_type = 'drain-provider'"
+	case "tier":
+		query = "SELECT task_id FROM admin_tasks WHERE status = 'running' AND task_type = 'tier-storage'"
 	case "all":
 		query = "SELECT task_id FROM admin_tasks WHERE status = 'running'"
 	default:
@@ -109,6 +116,7 @@ type CopyTaskDetails struct {
 	FilesCopied      int    `json:"files_copied"`
 	FilesSkipped     int    `json:"files_skipped"`
 	FilesFailed      int    `json:"files_failed"`
+	FilesNotAllowed  int    `json:"files_not_allowed"` // destination outside the owner's placement policy
 	BytesCopied      int64  `json:"bytes_copied"`
 	CurrentFileBytes int64  `json:"current_file_bytes"` // bytes copied for file in progress
 	CurrentFileSize  int64  `json:"current_file_size"`  // total size of file in progress
@@ -176,24 +184,32 @@ type fileCopyItem struct {
 	FileID     string
 	StorageID  string
 	PaddedSize int64
+	Owner      string
 }
 
 func buildSingleFileCopyList(fileID string) ([]fileCopyItem, error) {
-	var storageID string
+	var storageID, owner string
 	var paddedSizeRaw interface{}
 	err := database.DB.QueryRow(
-		"SELECT storage_id, padded_size FROM file_metadata WHERE file_id = ?", fileID,
-	).Scan(&storageID, &paddedSizeRaw)
+		"SELECT storage_id, padded_size, owner_username FROM file_metadata WHERE file_id = ?", fileID,
+	).Scan(&storageID, &paddedSizeRaw, &owner)
 	if err != nil {
 		return nil, err
 	}
 	paddedSize := toInt64FromInterface(paddedSizeRaw)
-	return []fileCopyItem{{FileID: fileID, StorageID: storageID, PaddedSize: paddedSize}}, nil
+	if shards, err := models.GetErasureShardsByFileID(database.DB, fileID); err != nil {
+		return nil, err
+	} else if len(shards) > 0 {
+		return nil, fmt.Errorf("file %s is erasure-coded; its shards cannot be copied as a whole object", fileID)
+	}
+	return []fileCopyItem{{FileID: fileID, StorageID: storageID, PaddedSize: paddedSize, Owner: owner}}, nil
 }
 
+// buildUserFileCopyList and buildAllFileCopyList leave out erasure-coded
+// files: they have no whole object to copy, only shards.
 func buildUserFileCopyList(username string) ([]fileCopyItem, error) {
 	rows, err := database.DB.Query(
-		"SELECT file_id, storage_id, padded_size FROM file_metadata WHERE owner_username = ?", username,
+		"SELECT file_id, storage_id, padded_size, owner_username FROM file_metadata WHERE owner_username = ? AND file_id NOT IN (SELECT file_id FROM file_erasure_shards)", username,
 	)
 	if err != nil {
 		return nil, err
@@ -203,7 +219,7 @@ func buildUserFileCopyList(username string) ([]fileCopyItem, error) {
 }
 
 func buildAllFileCopyList() ([]fileCopyItem, error) {
-	rows, err := database.DB.Query("SELECT file_id, storage_id, padded_size FROM file_metadata")
+	rows, err := database.DB.Query("SELECT file_id, storage_id, padded_size, owner_username FROM file_metadata WHERE file_id NOT IN (SELECT file_id FROM file_erasure_shards)")
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +232,7 @@ func scanFileCopyItems(rows *sql.Rows) ([]fileCopyItem, error) {
 	for rows.Next() {
 		var item fileCopyItem
 		var paddedSizeRaw interface{}
-		if err := rows.Scan(&item.FileID, &item.StorageID, &paddedSizeRaw); err != nil {
+		if err := rows.Scan(&item.FileID, &item.StorageID, &paddedSizeRaw, &item.Owner); err != nil {
 			return nil, err
 		}
 		item.PaddedSize = toInt64FromInterface(paddedSizeRaw)
@@ -279,6 +295,8 @@ func (tr *TaskRunner) runCopyTask(
 		models.UpdateAdminTaskDetails(database.DB, taskID, string(detailsSnap))
 	}
 
+	placement := placementLookup{}
+
 	for i, file := range files {
 		// Check for cancellation between files
 		if ctx.Err() != nil {
@@ -287,6 +305,16 @@ func (tr *TaskRunner) runCopyTask(
 			return
 		}
 
+		// Never copy a file to a provider its owner's placement policy excludes
+		if !placement.allows(file.Owner, req.DestID) {
+			logging.InfoLogger.Printf("Task %s: skipping file %s: placement policy of %s does not allow %s",
+				taskID, file.FileID, file.Owner, req.DestID)
+			details.FilesNotAllowed++
+			persistDetails()
+			models.UpdateAdminTaskProgress(database.DB, taskID, i+1)
+			continue
+		}
+
 		// Skip if already active on destination
 		if req.SkipExisting {
 			locs, _ := models.GetActiveFileStorageLocations(database.DB, file.FileID)
@@ -372,8 +400,8 @@ func (tr *TaskRunner) runCopyTask(
 		logging.ErrorLogger.Printf("Task %s: failed to mark complete: %v", taskID, err)
 	}
 
-	logging.InfoLogger.Printf("Task %s: completed (copied: %d, skipped: %d, failed: %d, bytes: %d)",
-		taskID, details.FilesCopied, details.FilesSkipped, details.FilesFailed, details.BytesCopied)
+	logging.InfoLogger.Printf("Task %s: completed (copied: %d, skipped: %d, failed: %d, not allowed: %d, bytes: %d)",
+		taskID, details.FilesCopied, details.FilesSkipped, details.FilesFailed, details.FilesNotAllowed, details.BytesCopied)
 }
 
 // VerifyTaskRequest describes a verify-all operation submitted by an admin API handler.
@@ -395,12 +423,14 @@ type VerifyTaskDetails struct {
 	Errors       int    `json:"errors"`
 }
 
-// fileVerifyItem is a file location to verify.
+// fileVerifyItem is a file location, or one shard of an erasure-coded file, to verify.
 type fileVerifyItem struct {
 	FileID     string
 	ProviderID string
 	StorageID  string
-	PaddedSize int64
+	PaddedSize int64  // expected object size: the blob's padded size, or the shard size
+	ObjectName string // object to check: StorageID, or the shard's object
+	ShardIndex int    // -1 for a whole-file copy
 }
 
 // SubmitVerifyTask creates an admin_tasks row and runs the verification in background.
@@ -443,12 +473,23 @@ func (tr *TaskRunner) SubmitVerifyTask(req VerifyTaskRequest) (string, error) {
 	return taskID, nil
 }
 
+// verifyShardsQuery selects active erasure shards in the same columns as the
+// location queries below; a shard's size follows from its blob's layout (see
+// storage.ErasureLayout.ShardSize).
+const verifyShardsQuery = `
+		SELECT file_id, provider_id, storage_id,
+		       (object_size + data_shards * stripe_unit - 1) / (data_shards * stripe_unit) * stripe_unit,
+		       object_name, shard_index
+		FROM file_erasure_shards
+		WHERE status = 'active'`
+
 func buildVerifyList(providerID string) ([]fileVerifyItem, error) {
 	rows, err := database.DB.Query(`
-		SELECT fsl.file_id, fsl.provider_id, fsl.storage_id, COALESCE(fm.padded_size, fm.size_bytes)
+		SELECT fsl.file_id, fsl.provider_id, fsl.storage_id, COALESCE(fm.padded_size, fm.size_bytes), fsl.storage_id, -1
 		FROM file_storage_locations fsl
 		JOIN file_metadata fm ON fsl.file_id = fm.file_id
-		WHERE fsl.provider_id = ? AND fsl.status = 'active'`, providerID)
+		WHERE fsl.provider_id = ? AND fsl.status = 'active'
+		UNION ALL`+verifyShardsQuery+` AND provider_id = ?`, providerID, providerID)
 	if err != nil {
 		return nil, err
 	}
@@ -458,10 +499,11 @@ func buildVerifyList(providerID string) ([]fileVerifyItem, error) {
 
 func buildVerifyListAll() ([]fileVerifyItem, error) {
 	rows, err := database.DB.Query(`
-		SELECT fsl.file_id, fsl.provider_id, fsl.storage_id, COALESCE(fm.padded_size, fm.size_bytes)
+		SELECT fsl.file_id, fsl.provider_id, fsl.storage_id, COALESCE(fm.padded_size, fm.size_bytes), fsl.storage_id, -1
 		FROM file_storage_locations fsl
 		JOIN file_metadata fm ON fsl.file_id = fm.file_id
-		WHERE fsl.status = 'active'`)
+		WHERE fsl.status = 'active'
+		UNION ALL` + verifyShardsQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -473,16 +515,26 @@ func scanVerifyItems(rows *sql.Rows) ([]fileVerifyItem, error) {
 	var items []fileVerifyItem
 	for rows.Next() {
 		var item fileVerifyItem
-		var paddedSizeRaw interface{}
-		if err := rows.Scan(&item.FileID, &item.ProviderID, &item.StorageID, &paddedSizeRaw); err != nil {
+		var paddedSizeRaw, shardIndexRaw interface{}
+		if err := rows.Scan(&item.FileID, &item.ProviderID, &item.StorageID, &paddedSizeRaw, &item.ObjectName, &shardIndexRaw); err != nil {
 			return nil, err
 		}
 		item.PaddedSize = toInt64FromInterface(paddedSizeRaw)
+		item.ShardIndex = int(toInt64FromInterface(shardIndexRaw))
 		items = append(items, item)
 	}
 	return items, rows.Err()
 }
 
+// markVerifyItemMissing records that a verified location or shard is gone.
+func markVerifyItemMissing(item fileVerifyItem) {
+	if item.ShardIndex >= 0 {
+		models.UpdateFileErasureShardStatus(database.DB, item.StorageID, item.ShardIndex, "missing")
+		return
+	}
+	models.UpdateFileStorageLocationStatus(database.DB, item.FileID, item.ProviderID, "missing")
+}
+
 // runVerifyTask is the background goroutine that performs HEAD-based verification.
 func (tr *TaskRunner) runVerifyTask(
 	ctx context.Context,
@@ -549,7 +601,7 @@ func (tr *TaskRunner) runVerifyTask(
 				return
 			}
 
-			size, err := provider.HeadObject(ctx, itm.StorageID)
+			size, err := provider.HeadObject(ctx, itm.ObjectName)
 			if err != nil {
 				// Object is missing or unreachable
 				resultsCh <- verifyResult{index: idx, missing: true}
@@ -581,12 +633,12 @@ func (tr *TaskRunner) runVerifyTask(
 		} else if r.missing {
 			details.Missing++
 			if req.Fix {
-				models.UpdateFileStorageLocationStatus(database.DB, items[r.index].FileID, items[r.index].ProviderID, "missing")
+				markVerifyItemMissing(items[r.index])
 			}
 		} else if r.sizeMismatch {
 			details.SizeMismatch++
 			if req.Fix {
-				models.UpdateFileStorageLocationStatus(database.DB, items[r.index].FileID, items[r.index].ProviderID, "missing")
+				markVerifyItemMissing(items[r.index])
 			}
 		} else if r.err != nil {
 			details.Errors++
@@ -631,8 +683,779 @@ func (tr *TaskRunner) runVerifyTask(
 		taskID, details.VerifiedOK, details.Missing, details.SizeMismatch, details.Errors)
 }
 
+// RepairTaskRequest describes a repair operation submitted by an admin API handler.
+type RepairTaskRequest struct {
+	AdminUsername string
+	Target        int    // healthy copies required per file; 0 uses the write policy's target
+	FileID        string // optional: repair a single file
+	DryRun        bool   // report under-replicated files without copying
+}
+
+// RepairTaskDetails holds the JSON-serializable details stored in admin_tasks.details.
+type RepairTaskDetails struct {
+	Target             int    `json:"target"`
+	FileID             string `json:"file_id,omitempty"`
+	DryRun             bool   `json:"dry_run"`
+	FilesRepaired      int    `json:"files_repaired"`
+	FilesFailed        int    `json:"files_failed"`
+	FilesUnrecoverable int    `json:"files_unrecoverable"` // no healthy copy left anywhere
+	FilesSkippedNoHash int    `json:"files_skipped_no_hash"`
+	CopiesCreated      int    `json:"copies_created"`
+	CorruptSources     int    `json:"corrupt_sources"` // source copies that failed the hash check
+	ShardsRebuilt      int    `json:"shards_rebuilt"`  // lost erasure shards rebuilt from parity
+	BytesCopied        int64  `json:"bytes_copied"`
+}
+
+// fileRepairItem is an under-replicated file and the configured providers that
+// currently hold an active copy of it.
+type fileRepairItem struct {
+	FileID       string
+	StorageID    string
+	PaddedSize   int64
+	ExpectedHash string
+	Owner        string
+	Healthy      []string
+	Tiered       []string                  // providers tier-storage moved the file's copy off
+	Allowed      []string                  // providers allowed by the owner's placement policy; nil allows any
+	Target       int                       // copies required by the owner's placement policy; 0 uses the task target
+	Shards       []models.FileErasureShard // every shard row of an erasure-coded file; nil for replicas
+}
+
+// copyTarget returns how many copies the file needs: its owner's placement
+// policy copy count, or taskTarget when the owner has no policy. Copies that
+// tier-storage moved to a cheaper provider are absent on purpose and lower
+// the target by one each, but never below a single copy.
+func (item fileRepairItem) copyTarget(taskTarget int) int {
+	target := taskTarget
+	if item.Target > 0 {
+		target = item.Target
+	}
+	if target -= len(item.Tiered); target < 1 {
+		target = 1
+	}
+	return target
+}
+
+// allows reports whether a copy on providerID is permitted by the file's
+// placement policy, and so counts toward its target.
+func (item fileRepairItem) allows(providerID string) bool {
+	if item.Allowed == nil {
+		return true
+	}
+	for _, id := range item.Allowed {
+		if id == providerID {
+			return true
+		}
+	}
+	return false
+}
+
+// lostShards returns the shards of an erasure-coded file that are not active
+// on a configured provider.
+func (item fileRepairItem) lostShards() []models.FileErasureShard {
+	var lost []models.FileErasureShard
+	for _, shard := range item.Shards {
+		if shard.Status != "active" || storage.Registry.GetProvider(shard.ProviderID) == nil {
+			lost = append(lost, shard)
+		}
+	}
+	return lost
+}
+
+// placedCopies returns how many of the file's healthy copies count toward its target.
+func (item fileRepairItem) placedCopies() int {
+	n := 0
+	for _, id := range item.Healthy {
+		if item.allows(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// SubmitRepairTask creates an admin_tasks row for every file with fewer active
+// copies than the replication target and restores the missing copies in the
+// background. Returns the task ID immediately.
+func (tr *TaskRunner) SubmitRepairTask(req RepairTaskRequest) (string, error) {
+	configured := storage.Registry.ConfiguredProviderIDs()
+	if req.Target <= 0 {
+		req.Target = storage.Registry.ReplicationTarget()
+	}
+	if req.Target > len(configured) {
+		return "", fmt.Errorf("repair target %d exceeds the %d configured provider(s)", req.Target, len(configured))
+	}
+
+	items, err := buildRepairList(req.FileID, req.Target, configured)
+	if err != nil {
+		return "", fmt.Errorf("failed to build repair list: %w", err)
+	}
+
+	taskID, err := models.CreateAdminTask(database.DB, "repair", req.AdminUsername, len(items))
+	if err != nil {
+		return "", fmt.Errorf("failed to create task record: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.mu.Lock()
+	tr.activeTasks[taskID] = cancel
+	tr.mu.Unlock()
+
+	go tr.runRepairTask(ctx, taskID, req, items)
+
+	return taskID, nil
+}
+
+// buildRepairList returns the files (or the single file, if fileID is set)
+// that have fewer than target active locations on configured providers, or
+// fewer than their placement policy's copy count on the policy's providers.
+// Locations on providers that are no longer configured do not count.
+// Erasure-coded files are listed when any of their shards is lost.
+func buildRepairList(fileID string, target int, configured []string) ([]fileRepairItem, error) {
+	erasure, err := loadErasureRepairItems(fileID)
+	if err != nil {
+		return nil, err
+	}
+	var items []fileRepairItem
+	for _, item := range erasure {
+		if len(item.lostShards()) > 0 {
+			items = append(items, item)
+		}
+	}
+	if fileID != "" && len(erasure) > 0 {
+		return items, nil
+	}
+
+	all, err := loadFileRepairItems(fileID, configured)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range all {
+		if item.placedCopies() < item.copyTarget(target) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// loadFileRepairItems returns every file (or the single file, if fileID is set)
+// with the configured providers that hold an active copy of it, the providers
+// its copy was tiered off, and its owner's placement policy. Erasure-coded
+// files are skipped: their redundancy comes from parity shards, not copies.
+func loadFileRepairItems(fileID string, configured []string) ([]fileRepairItem, error) {
+	query := `
+		SELECT fm.file_id, fm.storage_id, COALESCE(fm.padded_size, fm.size_bytes),
+		       COALESCE(fm.stored_blob_sha256sum, ''), fm.owner_username, fsl.provider_id, fsl.status
+		FROM file_metadata fm
+		LEFT JOIN file_storage_locations fsl ON fsl.file_id = fm.file_id AND fsl.status IN ('active', 'tiered')
+		WHERE fm.file_id NOT IN (SELECT file_id FROM file_erasure_shards)`
+	var args []interface{}
+	if fileID != "" {
+		query += " AND fm.file_id = ?"
+		args = append(args, fileID)
+	}
+	query += " ORDER BY fm.file_id"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	isConfigured := make(map[string]bool, len(configured))
+	for _, id := range configured {
+		isConfigured[id] = true
+	}
+
+	var items []fileRepairItem
+	for rows.Next() {
+		var item fileRepairItem
+		var paddedSizeRaw interface{}
+		var providerID, status sql.NullString
+		if err := rows.Scan(&item.FileID, &item.StorageID, &paddedSizeRaw, &item.ExpectedHash, &item.Owner, &providerID, &status); err != nil {
+			return nil, err
+		}
+		if len(items) == 0 || items[len(items)-1].FileID != item.FileID {
+			item.PaddedSize = toInt64FromInterface(paddedSizeRaw)
+			items = append(items, item)
+		}
+		if providerID.Valid && isConfigured[providerID.String] {
+			last := &items[len(items)-1]
+			if status.String == "tiered" {
+				last.Tiered = append(last.Tiered, providerID.String)
+			} else {
+				last.Healthy = append(last.Healthy, providerID.String)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if fileID != "" && len(items) == 0 {
+		return nil, fmt.Errorf("file %s not found", fileID)
+	}
+
+	placement := placementLookup{}
+	for i := range items {
+		policy, err := placement.policyFor(items[i].Owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load placement policy for %s: %w", items[i].Owner, err)
+		}
+		if policy != nil {
+			items[i].Allowed = policy.ProviderIDs
+			items[i].Target = policy.Copies
+		}
+	}
+	return items, nil
+}
+
+// loadErasureRepairItems returns every erasure-coded file (or the single file,
+// if fileID is set) with all of its shard rows.
+func loadErasureRepairItems(fileID string) ([]fileRepairItem, error) {
+	var shards []models.FileErasureShard
+	var err error
+	if fileID != "" {
+		shards, err = models.GetErasureShardsByFileID(database.DB, fileID)
+	} else {
+		shards, err = models.GetAllErasureShards(database.DB)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []fileRepairItem
+	for _, shard := range shards {
+		if len(items) == 0 || items[len(items)-1].StorageID != shard.StorageID {
+			items = append(items, fileRepairItem{FileID: shard.FileID, StorageID: shard.StorageID, PaddedSize: shard.ObjectSize})
+		}
+		last := &items[len(items)-1]
+		last.Shards = append(last.Shards, shard)
+	}
+	return items, nil
+}
+
+// runRepairTask is the background goroutine that restores missing copies.
+func (tr *TaskRunner) runRepairTask(
+	ctx context.Context,
+	taskID string,
+	req RepairTaskRequest,
+	items []fileRepairItem,
+) {
+	// Acquire semaphore slot
+	tr.semaphore <- struct{}{}
+	defer func() { <-tr.semaphore }()
+
+	// Clean up active task tracking when done
+	defer func() {
+		tr.mu.Lock()
+		delete(tr.activeTasks, taskID)
+		tr.mu.Unlock()
+	}()
+
+	// Mark task as running
+	if err := models.StartAdminTask(database.DB, taskID); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark as running: %v", taskID, err)
+		return
+	}
+
+	details := RepairTaskDetails{
+		Target: req.Target,
+		FileID: req.FileID,
+		DryRun: req.DryRun,
+	}
+
+	persistDetails := func() {
+		detailsSnap, _ := json.Marshal(details)
+		models.UpdateAdminTaskDetails(database.DB, taskID, string(detailsSnap))
+	}
+
+	for i, item := range items {
+		// Check for cancellation between files
+		if ctx.Err() != nil {
+			models.UpdateAdminTaskStatus(database.DB, taskID, "canceled")
+			logging.InfoLogger.Printf("Task %s: canceled at file %d/%d", taskID, i, len(items))
+			return
+		}
+
+		switch {
+		case req.DryRun:
+			// Only report: the item list itself is the result
+		case item.Shards != nil && len(item.Shards)-len(item.lostShards()) < item.Shards[0].DataShards:
+			logging.ErrorLogger.Printf("Task %s: file %s has too few shards left to rebuild the lost ones", taskID, item.FileID)
+			details.FilesUnrecoverable++
+		case item.Shards != nil:
+			outcome := repairErasureFile(ctx, item)
+			details.ShardsRebuilt += outcome.shardsRebuilt
+			details.BytesCopied += outcome.bytesCopied
+			if outcome.err != nil {
+				logging.ErrorLogger.Printf("Task %s: rebuild of file %s failed: %v", taskID, item.FileID, outcome.err)
+				details.FilesFailed++
+			} else {
+				details.FilesRepaired++
+			}
+		case len(item.Healthy) == 0:
+			logging.ErrorLogger.Printf("Task %s: file %s has no healthy copy on any configured provider", taskID, item.FileID)
+			details.FilesUnrecoverable++
+		case item.ExpectedHash == "":
+			// Without a stored hash a new copy cannot be verified, so don't make one
+			details.FilesSkippedNoHash++
+		default:
+			outcome := repairFile(ctx, item, item.copyTarget(req.Target), "")
+			details.CopiesCreated += outcome.copiesCreated
+			details.CorruptSources += outcome.corruptSources
+			details.BytesCopied += outcome.bytesCopied
+			if outcome.err != nil {
+				logging.ErrorLogger.Printf("Task %s: repair of file %s incomplete: %v", taskID, item.FileID, outcome.err)
+				details.FilesFailed++
+			} else {
+				details.FilesRepaired++
+			}
+		}
+
+		persistDetails()
+		models.UpdateAdminTaskProgress(database.DB, taskID, i+1)
+	}
+
+	// Complete the task
+	detailsJSON, _ := json.Marshal(details)
+	if err := models.CompleteAdminTask(database.DB, taskID, string(detailsJSON)); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark complete: %v", taskID, err)
+	}
+
+	logging.InfoLogger.Printf("Task %s: repair completed (files: %d, repaired: %d, failed: %d, unrecoverable: %d, copies: %d, corrupt sources: %d, shards rebuilt: %d)",
+		taskID, len(items), details.FilesRepaired, details.FilesFailed, details.FilesUnrecoverable,
+		details.CopiesCreated, details.CorruptSources, details.ShardsRebuilt)
+}
+
+// repairOutcome summarizes the work done for one file by repairFile.
+type repairOutcome struct {
+	copiesCreated  int
+	corruptSources int
+	shardsRebuilt  int
+	bytesCopied    int64
+	err            error // set when the target could not be reached
+}
+
+// repairFile copies item to configured providers that lack an active copy until
+// target copies exist. Each copy is streamed from a healthy source and its
+// SHA-256 is checked against the stored blob hash; a mismatch means the source
+// itself is bad, so it is marked "corrupt", dropped from the healthy set, and
+// becomes a destination for a fresh copy from another source. Files without a
+// stored hash are only checked by size.
+//
+// New copies only go to providers allowed by the file's placement policy, and
+// only copies on those providers count toward target; any healthy copy may
+// serve as a source.
+//
+// Providers the file was tiered off never receive a copy; see copyTarget.
+//
+// sourceOnly, if set, names a provider being drained: it may serve as a source
+// but never receives a copy and does not count toward target.
+func repairFile(ctx context.Context, item fileRepairItem, target int, sourceOnly string) repairOutcome {
+	var outcome repairOutcome
+	healthy := append([]string(nil), item.Healthy...)
+
+	// Providers already holding a copy, tiered off, or already tried as a destination
+	tried := make(map[string]bool)
+	for _, id := range healthy {
+		tried[id] = true
+	}
+	for _, id := range item.Tiered {
+		tried[id] = true
+	}
+	if sourceOnly != "" {
+		tried[sourceOnly] = true
+	}
+	requeued := make(map[string]bool)
+
+	copies := func() int {
+		n := 0
+		for _, id := range healthy {
+			if id != sourceOnly && item.allows(id) {
+				n++
+			}
+		}
+		return n
+	}
+
+	for copies() < target {
+		if ctx.Err() != nil {
+			outcome.err = ctx.Err()
+			return outcome
+		}
+		if len(healthy) == 0 {
+			outcome.err = fmt.Errorf("no verified source copy left")
+			return outcome
+		}
+
+		destID := nextRepairDestination(tried, item.Allowed)
+		if destID == "" {
+			outcome.err = fmt.Errorf("only %d of %d copies could be made", copies(), target)
+			return outcome
+		}
+		tried[destID] = true
+		dest := storage.Registry.GetProvider(destID)
+
+		for len(healthy) > 0 {
+			sourceID := healthy[0]
+			source := storage.Registry.GetProvider(sourceID)
+
+			models.SetFileStorageLocationStatus(database.DB, item.FileID, destID, item.StorageID, "pending")
+			copyHash, err := storage.Registry.CopyObjectBetweenProviders(ctx, item.StorageID, source, dest, item.PaddedSize, nil)
+			if err == nil && item.ExpectedHash != "" && copyHash != item.ExpectedHash {
+				logging.ErrorLogger.Printf("Repair: copy of file %s on %s does not match stored hash (expected %s, got %s)",
+					item.FileID, sourceID, item.ExpectedHash, copyHash)
+				dest.RemoveObject(ctx, item.StorageID, storage.RemoveObjectOptions{})
+				models.UpdateFileStorageLocationStatus(database.DB, item.FileID, destID, "failed")
+				models.UpdateFileStorageLocationStatus(database.DB, item.FileID, sourceID, "corrupt")
+				outcome.corruptSources++
+				healthy = healthy[1:]
+				if !requeued[sourceID] && sourceID != sourceOnly {
+					requeued[sourceID] = true
+					delete(tried, sourceID)
+				}
+				continue
+			}
+			if err == nil && item.PaddedSize > 0 {
+				if size, headErr := dest.HeadObject(ctx, item.StorageID); headErr != nil || size != item.PaddedSize {
+					err = fmt.Errorf("copy on %s has size %d, expected %d (%v)", destID, size, item.PaddedSize, headErr)
+				}
+			}
+			if err != nil {
+				// Destination problem: give up on this destination, keep the source
+				logging.ErrorLogger.Printf("Repair: copy of file %s from %s to %s failed: %v", item.FileID, sourceID, destID, err)
+				models.UpdateFileStorageLocationStatus(database.DB, item.FileID, destID, "failed")
+				break
+			}
+
+			models.UpdateFileStorageLocationStatus(database.DB, item.FileID, destID, "active")
+			models.MarkFileStorageLocationVerified(database.DB, item.FileID, destID)
+			if item.ExpectedHash != "" {
+				models.MarkFileStorageLocationVerified(database.DB, item.FileID, sourceID)
+			}
+			models.IncrementStorageProviderStats(database.DB, destID, 1, item.PaddedSize)
+			outcome.copiesCreated++
+			outcome.bytesCopied += item.PaddedSize
+			healthy = append(healthy, destID)
+			break
+		}
+	}
+	return outcome
+}
+
+// repairErasureFile rebuilds the lost shards of an erasure-coded file from its
+// surviving shards. A shard is rebuilt on its own provider while that provider
+// is configured, overwriting a corrupt copy; otherwise it goes to a provider
+// holding no other shard of the file, so one lost provider never costs the
+// file two shards. Every rebuilt shard must match the hash recorded when the
+// file was striped, or nothing is recorded.
+func repairErasureFile(ctx context.Context, item fileRepairItem) repairOutcome {
+	var outcome repairOutcome
+	layout := erasureLayoutFromShards(item.Shards)
+	lost := item.lostShards()
+
+	tried := make(map[string]bool)
+	for _, shard := range item.Shards {
+		tried[shard.ProviderID] = true
+	}
+	targets := make([]storage.ErasureShard, len(lost))
+	for i, shard := range lost {
+		destID := shard.ProviderID
+		if storage.Registry.GetProvider(destID) == nil {
+			if destID = nextRepairDestination(tried, nil); destID == "" {
+				outcome.err = fmt.Errorf("no provider without a shard of the file is left for shard %d", shard.ShardIndex)
+				return outcome
+			}
+			tried[destID] = true
+		}
+		targets[i] = storage.ErasureShard{Index: shard.ShardIndex, ProviderID: destID, ObjectName: shard.ObjectName, SHA256: shard.SHA256}
+	}
+
+	if err := storage.Registry.RebuildErasureShards(ctx, item.StorageID, layout, targets); err != nil {
+		outcome.err = err
+		return outcome
+	}
+
+	shardSize := layout.ShardSize()
+	for i, target := range targets {
+		if err := models.SetFileErasureShardRebuilt(database.DB, item.StorageID, target.Index, target.ProviderID); err != nil {
+			logging.ErrorLogger.Printf("Repair: failed to record rebuilt shard %d of file %s: %v", target.Index, item.FileID, err)
+		}
+		if target.ProviderID != lost[i].ProviderID {
+			models.IncrementStorageProviderStats(database.DB, target.ProviderID, 1, shardSize)
+		}
+		outcome.shardsRebuilt++
+		outcome.bytesCopied += shardSize
+	}
+	return outcome
+}
+
+// nextRepairDestination returns the first configured provider not in tried
+// that is not disabled in storage_providers, or "" if none is left. If allowed
+// is non-nil only those providers are considered, in placement policy order.
+func nextRepairDestination(tried map[string]bool, allowed []string) string {
+	candidates := allowed
+	if candidates == nil {
+		candidates = storage.Registry.ConfiguredProviderIDs()
+	}
+	for _, id := range candidates {
+		if tried[id] || storage.Registry.GetProvider(id) == nil {
+			continue
+		}
+		if record, err := models.GetStorageProviderByID(database.DB, id); err == nil && !record.IsActive {
+			tried[id] = true
+			continue
+		}
+		return id
+	}
+	return ""
+}
+
+// DrainTaskRequest describes a drain-provider operation submitted by an admin API handler.
+type DrainTaskRequest struct {
+	AdminUsername string
+	ProviderID    string // provider to empty and deactivate
+	Target        int    // copies to keep on the remaining providers; 0 uses the write policy's target
+}
+
+// DrainTaskDetails holds the JSON-serializable details stored in admin_tasks.details.
+type DrainTaskDetails struct {
+	ProviderID          string `json:"provider_id"`
+	Target              int    `json:"target"`
+	FilesMoved          int    `json:"files_moved"`  // remaining copies verified, source copy removed
+	FilesFailed         int    `json:"files_failed"` // source copy kept
+	CopiesCreated       int    `json:"copies_created"`
+	CorruptCopies       int    `json:"corrupt_copies"` // copies that failed the hash check
+	BytesCopied         int64  `json:"bytes_copied"`
+	ObjectsDeleted      int    `json:"objects_deleted"`
+	DeleteFailures      int    `json:"delete_failures"`
+	ProviderDeactivated bool   `json:"provider_deactivated"`
+}
+
+// SubmitDrainTask moves every file with an active copy on req.ProviderID onto the
+// other configured providers in the background, then deletes the drained copies
+// and deactivates the provider. Returns the task ID immediately.
+func (tr *TaskRunner) SubmitDrainTask(req DrainTaskRequest) (string, error) {
+	if storage.Registry.GetProvider(req.ProviderID) == nil {
+		return "", fmt.Errorf("provider %s not found", req.ProviderID)
+	}
+	if req.ProviderID == storage.Registry.PrimaryID() {
+		return "", fmt.Errorf("cannot drain the primary provider %s; promote another provider with set-primary first", req.ProviderID)
+	}
+
+	remaining := storage.Registry.ConfiguredProviderCount() - 1
+	if quorum := storage.Registry.RequiredWriteCopies(); quorum > remaining {
+		return "", fmt.Errorf("write quorum of %d cannot be met by the %d remaining provider(s); lower STORAGE_WRITE_QUORUM first", quorum, remaining)
+	}
+	if dataShards, parityShards := storage.Registry.ErasureShards(); storage.Registry.WritePolicy() == storage.WritePolicyErasure && dataShards+parityShards > remaining {
+		return "", fmt.Errorf("erasure coding %d+%d cannot be met by the %d remaining provider(s); lower STORAGE_ERASURE_SHARDS first", dataShards, parityShards, remaining)
+	}
+	// Draining only moves whole copies; shards would be lost with the provider
+	if shards, err := models.CountErasureShardsByProvider(database.DB, req.ProviderID); err != nil {
+		return "", fmt.Errorf("failed to count erasure-coded shards on %s: %w", req.ProviderID, err)
+	} else if shards > 0 {
+		return "", fmt.Errorf("provider %s holds %d erasure-coded shard(s), which drain-provider cannot move", req.ProviderID, shards)
+	}
+	if req.Target <= 0 {
+		req.Target = storage.Registry.ReplicationTarget()
+		if req.Target > remaining {
+			req.Target = remaining
+		}
+	}
+	if req.Target > remaining {
+		return "", fmt.Errorf("drain target %d exceeds the %d remaining provider(s)", req.Target, remaining)
+	}
+
+	items, err := buildDrainList(req.ProviderID, storage.Registry.ConfiguredProviderIDs())
+	if err != nil {
+		return "", fmt.Errorf("failed to build drain list: %w", err)
+	}
+
+	taskID, err := models.CreateAdminTask(database.DB, "drain-provider", req.AdminUsername, len(items))
+	if err != nil {
+		return "", fmt.Errorf("failed to create task record: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.mu.Lock()
+	tr.activeTasks[taskID] = cancel
+	tr.mu.Unlock()
+
+	go tr.runDrainTask(ctx, taskID, req, items)
+
+	return taskID, nil
+}
+
+// buildDrainList returns the files with an active copy on providerID.
+func buildDrainList(providerID string, configured []string) ([]fileRepairItem, error) {
+	all, err := loadFileRepairItems("", configured)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []fileRepairItem
+	for _, item := range all {
+		for _, id := range item.Healthy {
+			if id == providerID {
+				items = append(items, item)
+				break
+			}
+		}
+	}
+	return items, nil
+}
+
+// runDrainTask is the background goroutine that empties a provider.
+func (tr *TaskRunner) runDrainTask(
+	ctx context.Context,
+	taskID string,
+	req DrainTaskRequest,
+	items []fileRepairItem,
+) {
+	// Acquire semaphore slot
+	tr.semaphore <- struct{}{}
+	defer func() { <-tr.semaphore }()
+
+	// Clean up active task tracking when done
+	defer func() {
+		tr.mu.Lock()
+		delete(tr.activeTasks, taskID)
+		tr.mu.Unlock()
+	}()
+
+	// Mark task as running
+	if err := models.StartAdminTask(database.DB, taskID); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark as running: %v", taskID, err)
+		return
+	}
+
+	details := DrainTaskDetails{
+		ProviderID: req.ProviderID,
+		Target:     req.Target,
+	}
+
+	persistDetails := func() {
+		detailsSnap, _ := json.Marshal(details)
+		models.UpdateAdminTaskDetails(database.DB, taskID, string(detailsSnap))
+	}
+
+	drained := storage.Registry.GetProvider(req.ProviderID)
+
+	for i, item := range items {
+		// Check for cancellation between files
+		if ctx.Err() != nil {
+			models.UpdateAdminTaskStatus(database.DB, taskID, "canceled")
+			logging.InfoLogger.Printf("Task %s: canceled at file %d/%d", taskID, i, len(items))
+			return
+		}
+
+		// Copies already on other providers only count once they re-hash correctly
+		var corrupt int
+		item.Healthy, corrupt = verifyExistingCopies(ctx, item, req.ProviderID)
+		details.CorruptCopies += corrupt
+		item.Healthy = append(item.Healthy, req.ProviderID)
+
+		outcome := repairFile(ctx, item, item.copyTarget(req.Target), req.ProviderID)
+		details.CopiesCreated += outcome.copiesCreated
+		details.CorruptCopies += outcome.corruptSources
+		details.BytesCopied += outcome.bytesCopied
+
+		if outcome.err != nil {
+			logging.ErrorLogger.Printf("Task %s: drain of file %s incomplete, keeping copy on %s: %v",
+				taskID, item.FileID, req.ProviderID, outcome.err)
+			details.FilesFailed++
+		} else {
+			details.FilesMoved++
+			if err := drained.RemoveObject(ctx, item.StorageID, storage.RemoveObjectOptions{}); err != nil {
+				logging.ErrorLogger.Printf("Task %s: failed to delete file %s from %s: %v", taskID, item.FileID, req.ProviderID, err)
+				models.UpdateFileStorageLocationStatus(database.DB, item.FileID, req.ProviderID, "delete_failed")
+				details.DeleteFailures++
+			} else {
+				models.UpdateFileStorageLocationStatus(database.DB, item.FileID, req.ProviderID, "deleted")
+				details.ObjectsDeleted++
+			}
+		}
+
+		persistDetails()
+		models.UpdateAdminTaskProgress(database.DB, taskID, i+1)
+	}
+
+	// Only retire the provider once nothing is left on it
+	if details.FilesFailed == 0 && details.DeleteFailures == 0 {
+		if err := models.SetStorageProviderActive(database.DB, req.ProviderID, false); err != nil {
+			logging.ErrorLogger.Printf("Task %s: failed to deactivate provider %s: %v", taskID, req.ProviderID, err)
+		} else {
+			oldTertiaryID := storage.Registry.TertiaryID()
+			storage.Registry.RemoveProvider(req.ProviderID)
+			details.ProviderDeactivated = true
+
+			// A drained secondary hands its slot to the tertiary; record the
+			// new role so startup reconciliation keeps the same order.
+			if oldTertiaryID != "" && storage.Registry.SecondaryID() == oldTertiaryID {
+				if _, err := database.DB.Exec("UPDATE storage_providers SET role = 'secondary', updated_at = CURRENT_TIMESTAMP WHERE provider_id = ?", oldTertiaryID); err != nil {
+					logging.ErrorLogger.Printf("Task %s: failed to set %s as secondary: %v", taskID, oldTertiaryID, err)
+				}
+			}
+		}
+	}
+	if err := models.RecalculateProviderStats(database.DB, req.ProviderID); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to recalculate stats for provider %s: %v", taskID, req.ProviderID, err)
+	}
+
+	// Complete the task
+	detailsJSON, _ := json.Marshal(details)
+	if err := models.CompleteAdminTask(database.DB, taskID, string(detailsJSON)); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark complete: %v", taskID, err)
+	}
+
+	logging.InfoLogger.Printf("Task %s: drain of %s completed (moved: %d, failed: %d, copies: %d, deleted: %d, deactivated: %t)",
+		taskID, req.ProviderID, details.FilesMoved, details.FilesFailed, details.CopiesCreated,
+		details.ObjectsDeleted, details.ProviderDeactivated)
+}
+
+// verifyExistingCopies re-reads the active copies of item on providers other
+// than exclude and returns those that still match the stored hash (or, for
+// files without one, the expected size). Mismatching copies are marked
+// "corrupt"; copies that cannot be read are left alone but not returned.
+func verifyExistingCopies(ctx context.Context, item fileRepairItem, exclude string) ([]string, int) {
+	var verified []string
+	corrupt := 0
+	for _, id := range item.Healthy {
+		if id == exclude {
+			continue
+		}
+		provider := storage.Registry.GetProvider(id)
+		if provider == nil {
+			continue
+		}
+
+		if item.ExpectedHash == "" {
+			if size, err := provider.HeadObject(ctx, item.StorageID); err == nil && (item.PaddedSize == 0 || size == item.PaddedSize) {
+				verified = append(verified, id)
+			}
+			continue
+		}
+
+		hash, _, err := hashStoredObject(ctx, provider, item.StorageID, 0)
+		if err != nil {
+			logging.ErrorLogger.Printf("Drain: failed to read file %s on %s: %v", item.FileID, id, err)
+			continue
+		}
+		if hash != item.ExpectedHash {
+			logging.ErrorLogger.Printf("Drain: copy of file %s on %s does not match stored hash", item.FileID, id)
+			models.UpdateFileStorageLocationStatus(database.DB, item.FileID, id, "corrupt")
+			corrupt++
+			continue
+		}
+		models.MarkFileStorageLocationVerified(database.DB, item.FileID, id)
+		verified = append(verified, id)
+	}
+	return verified, corrupt
+}
+
 // StartPeriodicCleanupJobs runs background sweep tasks periodically.
-// This implements multipart upload aborter and the storage orphan reconciler.
+// This implements multipart upload aborter, the storage orphan reconciler,
+// the trash purge, and the background blob scrubber.
 func StartPeriodicCleanupJobs(ctx context.Context) {
 	// Skip periodic background sweeps in Go unit tests to avoid interfering with sqlmock expectations
 	if flag.Lookup("test.v") != nil {
@@ -644,9 +1467,12 @@ func StartPeriodicCleanupJobs(ctx context.Context) {
 		ticker := time.NewTicker(30 * time.Minute)
 		defer ticker.Stop()
 
+		trashRetentionDays := config.GetConfig().Storage.TrashRetentionDays
+
 		// Run immediately on startup (safely wrapped in recovery)
 		abortAbandonedMultipartUploads(ctx)
 		removeOrphanedStorageObjects(ctx)
+		purgeExpiredTrash(ctx, trashRetentionDays)
 
 		for {
 			select {
@@ -655,9 +1481,15 @@ func StartPeriodicCleanupJobs(ctx context.Context) {
 			case <-ticker.C:
 				abortAbandonedMultipartUploads(ctx)
 				removeOrphanedStorageObjects(ctx)
+				purgeExpiredTrash(ctx, trashRetentionDays)
 			}
 		}
 	}()
+
+	// Re-hash a rate-limited slice of stored blobs every hour (storage_scrubber.go)
+	if cfg := config.GetConfig(); cfg.Storage.ScrubPeriodDays > 0 {
+		startStorageScrubber(ctx, cfg.Storage.ScrubPeriodDays, cfg.Storage.ScrubMaxBytesPerSec)
+	}
 }
 
 func abortAbandonedMultipartUploads(ctx context.Context) {
@@ -673,7 +1505,7 @@ func abortAbandonedMultipartUploads(ctx context.Context) {
 
 	// Query for abandoned or canceled sessions with active storage upload IDs.
 	rows, err := database.DB.Query(`
-		SELECT id, storage_id, storage_upload_id 
+		SELECT id, storage_id, storage_upload_id, provider_id
 		FROM upload_sessions 
 		WHERE status IN ('abandoned', 'canceled') 
 		  AND storage_upload_id IS NOT NULL 
@@ -689,12 +1521,13 @@ func abortAbandonedMultipartUploads(ctx context.Context) {
 		id              string
 		storageID       string
 		storageUploadID string
+		providerID      sql.NullString
 	}
 
 	var items []sessionCleanupItem
 	for rows.Next() {
 		var item sessionCleanupItem
-		if err := rows.Scan(&item.id, &item.storageID, &item.storageUploadID); err == nil {
+		if err := rows.Scan(&item.id, &item.storageID, &item.storageUploadID, &item.providerID); err == nil {
 			items = append(items, item)
 		}
 	}
@@ -703,12 +1536,16 @@ func abortAbandonedMultipartUploads(ctx context.Context) {
 		return
 	}
 
-	primary := storage.Registry.Primary()
 	for _, item := range items {
 		logging.InfoLogger.Printf("stale multipart cleanup: aborting stale multipart upload for session %s (storage_id: %s, upload_id: %s)",
 			item.id, item.storageID, item.storageUploadID)
 
-		err := primary.AbortMultipartUpload(ctx, item.storageID, item.storageUploadID)
+		var err error
+		if providerID, provider := uploadSessionProvider(item.providerID.String); provider == nil {
+			err = fmt.Errorf("provider %s is not configured", providerID)
+		} else {
+			err = provider.AbortMultipartUpload(ctx, item.storageID, item.storageUploadID)
+		}
 		if err != nil {
 			logging.ErrorLogger.Printf("stale multipart cleanup: failed to abort multipart upload for session %s: %v", item.id, err)
 		}
@@ -763,6 +1600,21 @@ func removeOrphanedStorageObjects(ctx context.Context) {
 		}
 	}
 
+	// 2b. Shards of erasure-coded files are stored under their own object names
+	rowsShards, err := database.DB.Query(`SELECT object_name FROM file_erasure_shards`)
+	if err != nil {
+		logging.ErrorLogger.Printf("orphan reconciler cleanup: failed to query file_erasure_shards object names: %v", err)
+		return
+	}
+	defer rowsShards.Close()
+
+	for rowsShards.Next() {
+		var name string
+		if err := rowsShards.Scan(&name); err == nil {
+			referenced[name] = true
+		}
+	}
+
 	// 3. List all objects in primary storage
 	primary := storage.Registry.Primary()
 	objects, err := primary.ListObjects(ctx)
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// setupRepairTest swaps in a SQLite DB holding the full server schema, with
+// alice as the owner of test files, and a registry of three local providers
+// (p1, p2, p3).
+func setupRepairTest(t *testing.T) []*storage.LocalFSStorage {
+	t.Helper()
+	return setupRepairTestDB(t, testutil.SchemaDB(t))
+}
+
+// setupRepairTestDB is setupRepairTest on a database opened by the caller.
+func setupRepairTestDB(t *testing.T, db *sql.DB) []*storage.LocalFSStorage {
+	t.Helper()
+	testutil.InsertUsers(t, db, "alice")
+
+	originalDB := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = originalDB })
+
+	providers := make([]*storage.LocalFSStorage, 3)
+	for i := range providers {
+		var err error
+		providers[i], err = storage.NewLocalProvider(t.TempDir())
+		require.NoError(t, err)
+		id := []string{"p1", "p2", "p3"}[i]
+		_, err = db.Exec(`INSERT INTO storage_providers (provider_id, provider_type, bucket_name, endpoint, env_var_prefix)
+			VALUES (?, 'local', '', 'file://', ?)`, id, "STORAGE_"+id)
+		require.NoError(t, err)
+	}
+
+	originalRegistry := storage.Registry
+	storage.Registry = storage.NewProviderRegistry(providers[0], "p1")
+	storage.Registry.SetSecondary(providers[1], "p2")
+	storage.Registry.SetTertiary(providers[2], "p3")
+	t.Cleanup(func() { storage.Registry = originalRegistry })
+
+	return providers
+}
+
+// addRepairTestFile records a file whose stored hash is that of data, with
+// active locations on the given providers.
+func addRepairTestFile(t *testing.T, fileID string, data []byte, activeOn ...string) {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	_, err := database.DB.Exec(`INSERT INTO file_metadata (file_id, storage_id, owner_username, password_hint, filename_nonce, encrypted_filename,
+			sha256sum_nonce, encrypted_sha256sum, encrypted_fek, size_bytes, padded_size, stored_blob_sha256sum)
+		VALUES (?, ?, 'alice', '', '', '', '', '', '', ?, ?, ?)`, fileID, "blob-"+fileID, len(data), len(data), hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	for _, providerID := range activeOn {
+		require.NoError(t, models.InsertFileStorageLocation(database.DB, fileID, providerID, "blob-"+fileID, "active"))
+	}
+}
+
+func putRepairTestObject(t *testing.T, p *storage.LocalFSStorage, name string, data []byte) {
+	t.Helper()
+	_, err := p.PutObject(context.Background(), name, strings.NewReader(string(data)), int64(len(data)), storage.PutObjectOptions{})
+	require.NoError(t, err)
+}
+
+func repairTestLocationStatus(t *testing.T, fileID, providerID string) (string, bool) {
+	t.Helper()
+	var status string
+	var verifiedAt sql.NullString
+	err := database.DB.QueryRow("SELECT status, verified_at FROM file_storage_locations WHERE file_id = ? AND provider_id = ?",
+		fileID, providerID).Scan(&status, &verifiedAt)
+	require.NoError(t, err)
+	return status, verifiedAt.Valid
+}
+
+func TestBuildRepairList_SelectsUnderReplicatedFiles(t *testing.T) {
+	setupRepairTest(t)
+	data := []byte("blob")
+	addRepairTestFile(t, "full", data, "p1", "p2")
+	addRepairTestFile(t, "single", data, "p1")
+	addRepairTestFile(t, "orphan", data)
+	addRepairTestFile(t, "stale", data, "p1", "old-provider")
+
+	items, err := buildRepairList("", 2, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+
+	got := map[string][]string{}
+	for _, item := range items {
+		got[item.FileID] = item.Healthy
+	}
+	assert.Len(t, got, 3)
+	assert.NotContains(t, got, "full")
+	assert.Equal(t, []string{"p1"}, got["single"])
+	assert.Empty(t, got["orphan"])
+	// Copies on providers that are no longer configured do not count
+	assert.Equal(t, []string{"p1"}, got["stale"])
+
+	_, err = buildRepairList("missing-file", 2, storage.Registry.ConfiguredProviderIDs())
+	assert.Error(t, err)
+}
+
+func TestRepairFile_CopiesAndVerifies(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("padded encrypted blob")
+	addRepairTestFile(t, "f1", data, "p1")
+	putRepairTestObject(t, providers[0], "blob-f1", data)
+
+	items, err := buildRepairList("f1", 2, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	outcome := repairFile(context.Background(), items[0], 2, "")
+	require.NoError(t, outcome.err)
+	assert.Equal(t, 1, outcome.copiesCreated)
+	assert.Equal(t, int64(len(data)), outcome.bytesCopied)
+
+	status, verified := repairTestLocationStatus(t, "f1", "p2")
+	assert.Equal(t, "active", status)
+	assert.True(t, verified)
+	_, verified = repairTestLocationStatus(t, "f1", "p1")
+	assert.True(t, verified, "a source that produced a matching copy is verified too")
+
+	size, err := providers[1].HeadObject(context.Background(), "blob-f1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+
+	// The target is met, so the tertiary is left alone
+	_, err = providers[2].HeadObject(context.Background(), "blob-f1")
+	assert.Error(t, err)
+}
+
+func TestRepairFile_ReplacesCorruptSource(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("good blob")
+	addRepairTestFile(t, "f1", data, "p1", "p2")
+	putRepairTestObject(t, providers[0], "blob-f1", []byte("bad blob!"))
+	putRepairTestObject(t, providers[1], "blob-f1", data)
+
+	items, err := buildRepairList("f1", 3, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	outcome := repairFile(context.Background(), items[0], 3, "")
+	require.NoError(t, outcome.err)
+	assert.Equal(t, 1, outcome.corruptSources)
+	assert.Equal(t, 2, outcome.copiesCreated)
+
+	// The corrupt primary copy was rewritten from the good secondary
+	for _, id := range []string{"p1", "p2", "p3"} {
+		status, _ := repairTestLocationStatus(t, "f1", id)
+		assert.Equal(t, "active", status, id)
+	}
+	for _, p := range providers {
+		obj, err := p.GetObject(context.Background(), "blob-f1", storage.GetObjectOptions{})
+		require.NoError(t, err)
+		got, err := io.ReadAll(obj)
+		obj.Close()
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+	}
+}
+
+func TestRepairFile_SkipsInactiveProvider(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("blob")
+	addRepairTestFile(t, "f1", data, "p1")
+	putRepairTestObject(t, providers[0], "blob-f1", data)
+	_, err := database.DB.Exec("UPDATE storage_providers SET is_active = false WHERE provider_id = 'p2'")
+	require.NoError(t, err)
+
+	items, err := buildRepairList("f1", 2, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+
+	outcome := repairFile(context.Background(), items[0], 2, "")
+	require.NoError(t, outcome.err)
+
+	status, _ := repairTestLocationStatus(t, "f1", "p3")
+	assert.Equal(t, "active", status)
+	_, err = providers[1].HeadObject(context.Background(), "blob-f1")
+	assert.Error(t, err)
+}
+
+func TestRunRepairTask_RecordsDetails(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("blob")
+	addRepairTestFile(t, "ok", data, "p1")
+	putRepairTestObject(t, providers[0], "blob-ok", data)
+	addRepairTestFile(t, "lost", data)
+	addRepairTestFile(t, "nohash", data, "p1")
+	_, err := database.DB.Exec("UPDATE file_metadata SET stored_blob_sha256sum = NULL WHERE file_id = 'nohash'")
+	require.NoError(t, err)
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := RepairTaskRequest{AdminUsername: "admin", Target: 2}
+	items, err := buildRepairList("", req.Target, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	taskID, err := models.CreateAdminTask(database.DB, "repair", req.AdminUsername, len(items))
+	require.NoError(t, err)
+
+	tr.runRepairTask(context.Background(), taskID, req, items)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", task.Status)
+	assert.Equal(t, 3, task.ProgressCurrent)
+
+	var details RepairTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesRepaired)
+	assert.Equal(t, 1, details.FilesUnrecoverable)
+	assert.Equal(t, 1, details.FilesSkippedNoHash)
+	assert.Equal(t, 1, details.CopiesCreated)
+}
+
+// runTestDrain runs a drain of providerID synchronously and returns its details.
+func runTestDrain(t *testing.T, providerID string, target int) DrainTaskDetails {
+	t.Helper()
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	items, err := buildDrainList(providerID, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	taskID, err := models.CreateAdminTask(database.DB, "drain-provider", "admin", len(items))
+	require.NoError(t, err)
+
+	tr.runDrainTask(context.Background(), taskID, DrainTaskRequest{AdminUsername: "admin", ProviderID: providerID, Target: target}, items)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", task.Status)
+	var details DrainTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	return details
+}
+
+func TestSubmitDrainTask_RejectsPrimary(t *testing.T) {
+	setupRepairTest(t)
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+
+	_, err := tr.SubmitDrainTask(DrainTaskRequest{AdminUsername: "admin", ProviderID: "p1"})
+	assert.Error(t, err)
+	_, err = tr.SubmitDrainTask(DrainTaskRequest{AdminUsername: "admin", ProviderID: "nope"})
+	assert.Error(t, err)
+	_, err = tr.SubmitDrainTask(DrainTaskRequest{AdminUsername: "admin", ProviderID: "p3", Target: 3})
+	assert.Error(t, err, "only two providers remain")
+}
+
+func TestRunDrainTask_MovesFilesAndDeactivates(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("blob")
+	addRepairTestFile(t, "only-on-p3", data, "p3")
+	putRepairTestObject(t, providers[2], "blob-only-on-p3", data)
+	addRepairTestFile(t, "also-on-p1", data, "p1", "p3")
+	putRepairTestObject(t, providers[0], "blob-also-on-p1", data)
+	putRepairTestObject(t, providers[2], "blob-also-on-p1", data)
+
+	details := runTestDrain(t, "p3", 1)
+	assert.Equal(t, 2, details.FilesMoved)
+	assert.Equal(t, 0, details.FilesFailed)
+	assert.Equal(t, 1, details.CopiesCreated)
+	assert.Equal(t, 2, details.ObjectsDeleted)
+	assert.True(t, details.ProviderDeactivated)
+
+	status, _ := repairTestLocationStatus(t, "only-on-p3", "p1")
+	assert.Equal(t, "active", status)
+	_, verified := repairTestLocationStatus(t, "also-on-p1", "p1")
+	assert.True(t, verified, "existing copies are re-hashed before the drained copy is deleted")
+	for _, fileID := range []string{"only-on-p3", "also-on-p1"} {
+		status, _ := repairTestLocationStatus(t, fileID, "p3")
+		assert.Equal(t, "deleted", status)
+	}
+
+	objects, err := providers[2].ListObjects(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	record, err := models.GetStorageProviderByID(database.DB, "p3")
+	require.NoError(t, err)
+	assert.False(t, record.IsActive)
+	assert.False(t, storage.Registry.HasTertiary())
+}
+
+func TestRunDrainTask_KeepsOnlyCopyWhenItIsCorrupt(t *testing.T) {
+	providers := setupRepairTest(t)
+	addRepairTestFile(t, "f1", []byte("original"), "p3")
+	putRepairTestObject(t, providers[2], "blob-f1", []byte("bitrot!!"))
+
+	details := runTestDrain(t, "p3", 1)
+	assert.Equal(t, 0, details.FilesMoved)
+	assert.Equal(t, 1, details.FilesFailed)
+	assert.False(t, details.ProviderDeactivated)
+
+	// The bad copy is all there is, so it is not deleted
+	_, err := providers[2].HeadObject(context.Background(), "blob-f1")
+	assert.NoError(t, err)
+	record, err := models.GetStorageProviderByID(database.DB, "p3")
+	require.NoError(t, err)
+	assert.True(t, record.IsActive)
+	assert.True(t, storage.Registry.HasTertiary())
+}
@@ -132,8 +132,8 @@ func TestAbortStaleMultipartUploads_Success(t *testing.T) {
 	storage.Registry = storage.NewProviderRegistry(mockPrimary, "mock-primary")
 
 	// Set up expected database queries
-	rows := sqlmock.NewRows([]string{"id", "storage_id", "storage_upload_id"}).
-		AddRow("session-123", "stor-123", "upload-abc")
+	rows := sqlmock.NewRows([]string{"id", "storage_id", "storage_upload_id", "provider_id"}).
+		AddRow("session-123", "stor-123", "upload-abc", nil)
 	mockDB.ExpectQuery(`SELECT id, storage_id, storage_upload_id`).
 		WillReturnRows(rows)
 
@@ -176,9 +176,14 @@ func TestGarbageCollectOrphanedStorageObjects_Success(t *testing.T) {
 	mockDB.ExpectQuery(`SELECT DISTINCT storage_id FROM upload_sessions`).
 		WillReturnRows(sessRows)
 
+	// 2b. Query for erasure-coded shard objects
+	shardRows := sqlmock.NewRows([]string{"object_name"}).AddRow("referenced-ec-789.ec0")
+	mockDB.ExpectQuery(`SELECT object_name FROM file_erasure_shards`).
+		WillReturnRows(shardRows)
+
 	// Mock S3 responses
-	// ListObjects returns a referenced key and an orphaned key
-	mockPrimary.On("ListObjects", mock.Anything).Return([]string{"referenced-meta-123", "orphaned-999"}, nil)
+	// ListObjects returns a referenced key, a shard key and an orphaned key
+	mockPrimary.On("ListObjects", mock.Anything).Return([]string{"referenced-meta-123", "referenced-ec-789.ec0", "orphaned-999"}, nil)
 
 	// GetObject for the orphaned key to perform Stat
 	mockObj := &storage.MockStoredObject{}
@@ -200,6 +200,15 @@ func TestDeleteUser_Success_Admin(t *testing.T) {
 	mockDB.ExpectExec("DELETE FROM file_share_keys WHERE owner_username = ?").
 		WithArgs(targetUsername).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mockDB.ExpectExec("DELETE FROM file_drops WHERE owner_username = ?").
+		WithArgs(targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_file_shares WHERE owner_username = \\? OR recipient_username = \\?").
+		WithArgs(targetUsername, targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_encryption_keys WHERE username = ?").
+		WithArgs(targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	// Mock soft deletion of user record
 	mockDB.ExpectExec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE username = ?").
@@ -558,6 +567,15 @@ func TestDeleteUser_Error_DeleteUserRecordError(t *testing.T) {
 	mockDB.ExpectExec("DELETE FROM file_share_keys WHERE owner_username = ?").
 		WithArgs(targetUsername).
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM file_drops WHERE owner_username = ?").
+		WithArgs(targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_file_shares WHERE owner_username = \\? OR recipient_username = \\?").
+		WithArgs(targetUsername, targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_encryption_keys WHERE username = ?").
+		WithArgs(targetUsername).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	dbErr := fmt.Errorf("simulated DB error deleting user record")
 	mockDB.ExpectExec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE username = ?").
@@ -600,6 +618,9 @@ func TestDeleteUser_Error_LogAdminActionFailure(t *testing.T) {
 	mockDB.ExpectBegin()
 	mockDB.ExpectQuery("SELECT file_id, storage_id FROM file_metadata WHERE owner_username = ?").WithArgs(targetUsername).WillReturnRows(sqlmock.NewRows([]string{"file_id", "storage_id"}))
 	mockDB.ExpectExec("DELETE FROM file_share_keys WHERE owner_username = ?").WithArgs(targetUsername).WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM file_drops WHERE owner_username = ?").WithArgs(targetUsername).WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_file_shares WHERE owner_username = \\? OR recipient_username = \\?").WithArgs(targetUsername, targetUsername).WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("DELETE FROM user_encryption_keys WHERE username = ?").WithArgs(targetUsername).WillReturnResult(sqlmock.NewResult(0, 0))
 	mockDB.ExpectExec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE username = ?").WithArgs(targetUsername).WillReturnResult(sqlmock.NewResult(0, 1))
 
 	// Mock the logging action to fail.
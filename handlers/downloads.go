@@ -38,9 +38,16 @@ func DownloadFileChunk(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
 	}
 
-	// Verify ownership
+	// Verify ownership, or that the file was shared with this user
 	if file.OwnerUsername != username {
-		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		shared, err := hasUserShare(fileID, username)
+		if err != nil {
+			logging.ErrorLogger.Printf("Database error checking user share during chunk download: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+		}
+		if !shared {
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		}
 	}
 
 	// Check if user is approved for file operations
@@ -91,8 +98,14 @@ func DownloadFileChunk(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid chunk range")
 	}
 
-	// Get the chunk from storage using byte range with three-tier fallback
-	reader, _, err := storage.Registry.GetObjectChunkWithFallback(c.Request().Context(), file.StorageID, startByte, actualChunkSize)
+	// A new download counts as an access for storage tiering
+	if chunkIndex == 0 {
+		noteFileAccess(fileID)
+	}
+
+	// Get the chunk from storage using byte range with three-tier fallback,
+	// limited to the providers that hold an active copy of the file
+	reader, _, err := storage.Registry.GetObjectChunkFrom(c.Request().Context(), fileReadProviders(fileID), file.StorageID, startByte, actualChunkSize)
 	if err != nil {
 		logging.ErrorLogger.Printf("Failed to get chunk %d of file %s (storage_id: %s) from all providers: %v", chunkIndex, fileID, file.StorageID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chunk from storage")
@@ -117,6 +130,21 @@ func DownloadFileChunk(c echo.Context) error {
 	return c.Stream(http.StatusOK, "application/octet-stream", reader)
 }
 
+// fileReadProviders returns the providers holding an active copy of fileID, so
+// chunk reads skip providers the file was never copied to or was tiered off.
+// On error it returns nil, which reads from every configured provider.
+func fileReadProviders(fileID string) []string {
+	locations, err := models.GetActiveFileStorageLocations(database.DB, fileID)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(locations))
+	for _, loc := range locations {
+		ids = append(ids, loc.ProviderID)
+	}
+	return ids
+}
+
 // parseChunkIndex parses a chunk index string to int64
 func parseChunkIndex(s string) (int64, error) {
 	n := int64(0)
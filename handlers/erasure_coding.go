@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// LookupErasureLayout is the registry's erasure layout source (see
+// storage.ProviderRegistry.SetErasureLayoutSource). It returns the layout of a
+// blob stored under the erasure write policy, listing only its active shards,
+// or nil for blobs stored as full replicas.
+func LookupErasureLayout(ctx context.Context, storageID string) (*storage.ErasureLayout, error) {
+	shards, err := models.GetErasureShardsByStorageID(database.DB, storageID)
+	if err != nil {
+		return nil, err
+	}
+	return erasureLayoutFromShards(shards), nil
+}
+
+func erasureLayoutFromShards(shards []models.FileErasureShard) *storage.ErasureLayout {
+	if len(shards) == 0 {
+		return nil
+	}
+	layout := &storage.ErasureLayout{
+		DataShards:   shards[0].DataShards,
+		ParityShards: shards[0].ParityShards,
+		StripeUnit:   shards[0].StripeUnit,
+		ObjectSize:   shards[0].ObjectSize,
+	}
+	for _, shard := range shards {
+		if shard.Status != "active" {
+			continue
+		}
+		layout.Shards = append(layout.Shards, storage.ErasureShard{
+			Index:      shard.ShardIndex,
+			ProviderID: shard.ProviderID,
+			ObjectName: shard.ObjectName,
+			SHA256:     shard.SHA256,
+		})
+	}
+	return layout
+}
+
+// recordErasureShards records the shards of a newly striped blob and adds
+// them to their providers' cached stats.
+func recordErasureShards(tx *sql.Tx, fileID, storageID string, layout *storage.ErasureLayout) error {
+	for _, shard := range layout.Shards {
+		if err := models.InsertFileErasureShard(tx, &models.FileErasureShard{
+			FileID:       fileID,
+			StorageID:    storageID,
+			ShardIndex:   shard.Index,
+			DataShards:   layout.DataShards,
+			ParityShards: layout.ParityShards,
+			StripeUnit:   layout.StripeUnit,
+			ObjectSize:   layout.ObjectSize,
+			ProviderID:   shard.ProviderID,
+			ObjectName:   shard.ObjectName,
+			SHA256:       shard.SHA256,
+		}); err != nil {
+			return err
+		}
+		if err := models.IncrementStorageProviderStats(tx, shard.ProviderID, 1, layout.ShardSize()); err != nil {
+			// Non-fatal: stats can be recalculated later
+			logging.ErrorLogger.Printf("Failed to update provider stats for %s: %v", shard.ProviderID, err)
+		}
+	}
+	return nil
+}
+
+// removeErasureShards deletes every shard object of a file and takes them off
+// their providers' cached stats. Failures are logged; like replica deletes,
+// they do not block deleting the file.
+func removeErasureShards(ctx context.Context, fileID string, shards []models.FileErasureShard) {
+	shardSize := erasureLayoutFromShards(shards).ShardSize()
+	locations := make([]storage.RemoveLocation, len(shards))
+	for i, shard := range shards {
+		locations[i] = storage.RemoveLocation{ProviderID: shard.ProviderID, StorageID: shard.ObjectName}
+	}
+	for _, result := range storage.Registry.RemoveObjectAll(ctx, locations) {
+		if !result.Success {
+			logging.ErrorLogger.Printf("Failed to delete a shard of file %s from provider %s: %v", fileID, result.ProviderID, result.Error)
+			continue
+		}
+		if err := models.IncrementStorageProviderStats(database.DB, result.ProviderID, -1, -shardSize); err != nil {
+			logging.ErrorLogger.Printf("Failed to decrement provider stats for %s: %v", result.ProviderID, err)
+		}
+	}
+}
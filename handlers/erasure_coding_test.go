@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// addErasureTestFile stores data as file "ec" (blob "blob-ec"), striped with
+// k+m erasure coding across the repair test providers the way CompleteUpload
+// does.
+func addErasureTestFile(t *testing.T, providers []*storage.LocalFSStorage, k, m int, data []byte) *storage.ErasureLayout {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, storage.Registry.SetErasureCoding(k, m))
+	storage.Registry.SetErasureLayoutSource(LookupErasureLayout)
+
+	sum := sha256.Sum256(data)
+	addRepairTestFile(t, "ec", data)
+	putRepairTestObject(t, providers[0], "blob-ec", data)
+
+	layout, err := storage.Registry.StripeObject(ctx, "p1", "blob-ec", int64(len(data)), hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	tx, err := database.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, recordErasureShards(tx, "ec", "blob-ec", layout))
+	require.NoError(t, tx.Commit())
+	require.NoError(t, providers[0].RemoveObject(ctx, "blob-ec", storage.RemoveObjectOptions{}))
+	return layout
+}
+
+func erasureTestData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func erasureTestShardHash(t *testing.T, p *storage.LocalFSStorage, name string) string {
+	t.Helper()
+	obj, err := p.GetObject(context.Background(), name, storage.GetObjectOptions{})
+	require.NoError(t, err)
+	defer obj.Close()
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, obj)
+	require.NoError(t, err)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func TestErasureCodedFile_ReadSkipCopyAndDelete(t *testing.T) {
+	providers := setupRepairTest(t)
+	ctx := context.Background()
+	data := erasureTestData(300000)
+	layout := addErasureTestFile(t, providers, 2, 1, data)
+
+	provider, err := models.GetStorageProviderByID(database.DB, "p3")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), provider.TotalObjects)
+	assert.Equal(t, layout.ShardSize(), provider.TotalSizeBytes)
+
+	// Lose the first data shard and mark it missing: reads rebuild from parity
+	require.NoError(t, providers[0].RemoveObject(ctx, "blob-ec.ec0", storage.RemoveObjectOptions{}))
+	require.NoError(t, models.UpdateFileErasureShardStatus(database.DB, "blob-ec", 0, "missing"))
+	found, err := LookupErasureLayout(ctx, "blob-ec")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Len(t, found.Shards, 2)
+
+	reader, _, err := storage.Registry.GetObjectChunkFrom(ctx, fileReadProviders("ec"), "blob-ec", 1000, 200000)
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data[1000:201000], got)
+
+	// Repair rebuilds the lost shard rather than making whole copies
+	items, err := buildRepairList("", 3, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Len(t, items[0].Shards, 3)
+	assert.Empty(t, items[0].Healthy)
+	files, err := buildAllFileCopyList()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	_, err = buildSingleFileCopyList("ec")
+	assert.Error(t, err)
+
+	// Deleting the file removes every remaining shard
+	shards, err := models.GetErasureShardsByFileID(database.DB, "ec")
+	require.NoError(t, err)
+	removeErasureShards(ctx, "ec", shards)
+	for i, p := range providers {
+		_, err := p.HeadObject(ctx, storage.ErasureShardName("blob-ec", i))
+		assert.Error(t, err, "shard %d", i)
+	}
+}
+
+func TestRunRepairTask_RebuildsLostErasureShard(t *testing.T) {
+	providers := setupRepairTest(t)
+	ctx := context.Background()
+	data := erasureTestData(300000)
+	layout := addErasureTestFile(t, providers, 2, 1, data)
+
+	require.NoError(t, providers[0].RemoveObject(ctx, "blob-ec.ec0", storage.RemoveObjectOptions{}))
+	require.NoError(t, models.UpdateFileErasureShardStatus(database.DB, "blob-ec", 0, "missing"))
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := RepairTaskRequest{AdminUsername: "admin", Target: 1, FileID: "ec"}
+	items, err := buildRepairList(req.FileID, req.Target, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	taskID, err := models.CreateAdminTask(database.DB, "repair", req.AdminUsername, len(items))
+	require.NoError(t, err)
+
+	tr.runRepairTask(ctx, taskID, req, items)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	var details RepairTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesRepaired)
+	assert.Equal(t, 1, details.ShardsRebuilt)
+	assert.Equal(t, layout.ShardSize(), details.BytesCopied)
+
+	shards, err := models.GetErasureShardsByFileID(database.DB, "ec")
+	require.NoError(t, err)
+	assert.Equal(t, "active", shards[0].Status)
+	assert.Equal(t, "p1", shards[0].ProviderID)
+	assert.Equal(t, shards[0].SHA256, erasureTestShardHash(t, providers[0], "blob-ec.ec0"))
+
+	items, err = buildRepairList("", 1, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestRepairErasureFile_MovesShardOffRemovedProvider(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := erasureTestData(5000)
+	layout := addErasureTestFile(t, providers, 1, 1, data)
+	require.Equal(t, "p2", layout.Shards[1].ProviderID)
+	storage.Registry.RemoveProvider("p2")
+
+	items, err := buildRepairList("ec", 1, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	outcome := repairErasureFile(context.Background(), items[0])
+	require.NoError(t, outcome.err)
+	assert.Equal(t, 1, outcome.shardsRebuilt)
+
+	shards, err := models.GetErasureShardsByFileID(database.DB, "ec")
+	require.NoError(t, err)
+	assert.Equal(t, "p3", shards[1].ProviderID, "the shard goes to the provider holding no other shard")
+	assert.Equal(t, shards[1].SHA256, erasureTestShardHash(t, providers[2], "blob-ec.ec1"))
+	provider, err := models.GetStorageProviderByID(database.DB, "p3")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), provider.TotalObjects)
+}
+
+func TestRepairErasureFile_TooFewShards(t *testing.T) {
+	providers := setupRepairTest(t)
+	addErasureTestFile(t, providers, 2, 1, erasureTestData(5000))
+	require.NoError(t, models.UpdateFileErasureShardStatus(database.DB, "blob-ec", 0, "corrupt"))
+	require.NoError(t, models.UpdateFileErasureShardStatus(database.DB, "blob-ec", 2, "missing"))
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := RepairTaskRequest{AdminUsername: "admin", Target: 1}
+	items, err := buildRepairList("", req.Target, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	taskID, err := models.CreateAdminTask(database.DB, "repair", req.AdminUsername, len(items))
+	require.NoError(t, err)
+
+	tr.runRepairTask(context.Background(), taskID, req, items)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	var details RepairTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesUnrecoverable)
+	assert.Equal(t, 0, details.ShardsRebuilt)
+}
+
+func TestScrubStoredBlobs_FlagsCorruptErasureShard(t *testing.T) {
+	providers := setupRepairTest(t)
+	layout := addErasureTestFile(t, providers, 2, 1, erasureTestData(5000))
+	putRepairTestObject(t, providers[1], "blob-ec.ec1", make([]byte, layout.ShardSize()))
+
+	items, err := buildScrubList(10)
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	assert.Equal(t, "blob-ec.ec1", items[1].ObjectName)
+	assert.Equal(t, 1, items[1].ShardIndex)
+
+	// A one-day period with three shards checks one shard per pass
+	var res scrubResult
+	for range items {
+		pass := scrubStoredBlobs(context.Background(), 1, 0)
+		res.Verified += pass.Verified
+		res.Mismatched += pass.Mismatched
+	}
+	assert.Equal(t, 2, res.Verified)
+	assert.Equal(t, 1, res.Mismatched)
+
+	shards, err := models.GetErasureShardsByFileID(database.DB, "ec")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"active", "corrupt", "active"}, []string{shards[0].Status, shards[1].Status, shards[2].Status})
+	count, err := models.CountUnacknowledgedSecurityAlerts(database.DB, models.AlertTypeStorageIntegrity)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// The corrupt shard is no longer read from, and repair rebuilds it
+	repair, err := buildRepairList("ec", 1, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, repair, 1)
+	require.NoError(t, repairErasureFile(context.Background(), repair[0]).err)
+	assert.Equal(t, layout.Shards[1].SHA256, erasureTestShardHash(t, providers[1], "blob-ec.ec1"))
+}
+
+func TestBuildVerifyList_IncludesErasureShards(t *testing.T) {
+	providers := setupRepairTest(t)
+	layout := addErasureTestFile(t, providers, 2, 1, erasureTestData(5000))
+
+	items, err := buildVerifyList("p2")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, fileVerifyItem{FileID: "ec", ProviderID: "p2", StorageID: "blob-ec", PaddedSize: layout.ShardSize(),
+		ObjectName: "blob-ec.ec1", ShardIndex: 1}, items[0])
+
+	markVerifyItemMissing(items[0])
+	shards, err := models.GetErasureShardsByFileID(database.DB, "ec")
+	require.NoError(t, err)
+	assert.Equal(t, "missing", shards[1].Status)
+}
@@ -0,0 +1,96 @@
+// file_annotations.go - Client-encrypted tags and notes on files (see
+// models/file_annotations.go). The client encrypts the tags (a JSON object)
+// and the note with the account key under AAD labels crypto.AADFieldTags
+// and crypto.AADFieldNote; the server only checks their shape and size.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+const (
+	// Limits on the base64 ciphertexts; they leave room for a few dozen
+	// tags and a note of a few pages.
+	maxEncryptedTagsLength = 16384
+	maxEncryptedNoteLength = 16384
+)
+
+// FileAnnotationsRequest updates a file's encrypted tags and/or note. A
+// field pair that is absent is left unchanged; a pair of empty strings
+// clears the value.
+type FileAnnotationsRequest struct {
+	EncryptedTags *string `json:"encrypted_tags"`
+	TagsNonce     *string `json:"tags_nonce"`
+	EncryptedNote *string `json:"encrypted_note"`
+	NoteNonce     *string `json:"note_nonce"`
+}
+
+// annotationValue checks one ciphertext/nonce pair of a
+// FileAnnotationsRequest. It returns nil when the pair is absent.
+func annotationValue(ciphertext, nonce *string, maxLength int) (*models.EncryptedValue, bool) {
+	if ciphertext == nil && nonce == nil {
+		return nil, true
+	}
+	if ciphertext == nil || nonce == nil || (*ciphertext == "") != (*nonce == "") || len(*ciphertext) > maxLength {
+		return nil, false
+	}
+	return &models.EncryptedValue{Ciphertext: *ciphertext, Nonce: *nonce}, true
+}
+
+// UpdateFileAnnotations handles PATCH /api/files/:fileId/annotations
+// Sets or clears the encrypted tags and note of one of the user's files.
+func UpdateFileAnnotations(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+	fileID := c.Param("fileId")
+
+	var request FileAnnotationsRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	tags, ok := annotationValue(request.EncryptedTags, request.TagsNonce, maxEncryptedTagsLength)
+	if !ok {
+		return JSONErrorCode(c, http.StatusBadRequest, "invalid_encrypted_tags",
+			"Encrypted tags and tags nonce must be sent together and fit the size limit")
+	}
+	note, ok := annotationValue(request.EncryptedNote, request.NoteNonce, maxEncryptedNoteLength)
+	if !ok {
+		return JSONErrorCode(c, http.StatusBadRequest, "invalid_encrypted_note",
+			"Encrypted note and note nonce must be sent together and fit the size limit")
+	}
+	if tags == nil && note == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Nothing to update")
+	}
+
+	file, err := models.GetFileByFileID(database.DB, fileID)
+	if err != nil {
+		if err.Error() == "file not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Database error checking file ownership for annotations: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update annotations")
+	}
+	if file.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	annotations, err := models.UpdateFileAnnotations(database.DB, fileID, username, tags, note)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to update annotations of file %s: %v", fileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update annotations")
+	}
+
+	database.LogUserAction(username, "updated annotations", fileID)
+
+	return JSONResponse(c, http.StatusOK, "Annotations updated", annotations)
+}
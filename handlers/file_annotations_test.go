@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// patchAnnotations calls UpdateFileAnnotations and returns the status code.
+func patchAnnotations(t *testing.T, username, fileID, body string) int {
+	t.Helper()
+	c, rec := authedContext(http.MethodPatch, "/api/files/"+fileID+"/annotations", []byte(body), username)
+	c.SetParamNames("fileId")
+	c.SetParamValues(fileID)
+	if err := UpdateFileAnnotations(c); err != nil {
+		httpErr, ok := err.(*echo.HTTPError)
+		require.True(t, ok, "expected *echo.HTTPError, got %v", err)
+		return httpErr.Code
+	}
+	return rec.Code
+}
+
+func TestUpdateFileAnnotations_ShownInListing(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertTestFile(t, db, provider, "alice", "doc")
+
+	require.Equal(t, http.StatusOK, patchAnnotations(t, "alice", "doc",
+		`{"encrypted_tags":"encTags","tags_nonce":"tagsNonce"}`))
+	require.Equal(t, http.StatusOK, patchAnnotations(t, "alice", "doc",
+		`{"encrypted_note":"encNote","note_nonce":"noteNonce"}`))
+
+	doc := listFileIDs(t, ListFiles, "/api/files")["doc"]
+	assert.Equal(t, "encTags", doc["encrypted_tags"], "setting the note keeps the tags")
+	assert.Equal(t, "tagsNonce", doc["tags_nonce"])
+	assert.Equal(t, "encNote", doc["encrypted_note"])
+	assert.Equal(t, "noteNonce", doc["note_nonce"])
+
+	// Clearing the tags leaves the note
+	require.Equal(t, http.StatusOK, patchAnnotations(t, "alice", "doc", `{"encrypted_tags":"","tags_nonce":""}`))
+	doc = listFileIDs(t, ListFiles, "/api/files")["doc"]
+	assert.NotContains(t, doc, "encrypted_tags")
+	assert.Equal(t, "encNote", doc["encrypted_note"])
+
+	// Clearing both removes the row
+	require.Equal(t, http.StatusOK, patchAnnotations(t, "alice", "doc", `{"encrypted_note":"","note_nonce":""}`))
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_annotations`).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestUpdateFileAnnotations_Validation(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	insertTestFile(t, db, provider, "alice", "doc")
+
+	assert.Equal(t, http.StatusForbidden, patchAnnotations(t, "bob", "doc", `{"encrypted_note":"x","note_nonce":"n"}`))
+	assert.Equal(t, http.StatusNotFound, patchAnnotations(t, "alice", "missing", `{"encrypted_note":"x","note_nonce":"n"}`))
+	assert.Equal(t, http.StatusBadRequest, patchAnnotations(t, "alice", "doc", `{}`), "nothing to update")
+	assert.Equal(t, http.StatusBadRequest, patchAnnotations(t, "alice", "doc", `{"encrypted_tags":"x"}`), "nonce missing")
+	assert.Equal(t, http.StatusBadRequest, patchAnnotations(t, "alice", "doc", `{"encrypted_note":"x","note_nonce":""}`))
+
+	long := make([]byte, maxEncryptedNoteLength+1)
+	for i := range long {
+		long[i] = 'A'
+	}
+	assert.Equal(t, http.StatusBadRequest, patchAnnotations(t, "alice", "doc",
+		`{"encrypted_note":"`+string(long)+`","note_nonce":"n"}`))
+}
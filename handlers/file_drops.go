@@ -0,0 +1,505 @@
+// file_drops.go - File drops: links through which people without an account
+// upload files into the owner's vault.
+//
+// The owner's client generates a hybrid X25519 + ML-KEM-768 key pair for
+// each drop (see crypto/file_drop.go) and sends the public key together with
+// the private key wrapped under the owner's account key. Senders fetch the
+// public key, seal each file's FEK to it and upload through the ordinary
+// chunked upload code; the resulting files belong to the owner with
+// password_type "drop" until the owner's client re-wraps them with the
+// account key. The server never sees a plaintext key.
+//
+// The owner sets the drop's total size, optional number of files and
+// expiry. Expiry stops new uploads; revoking the drop also stops uploads in
+// progress. Public drop routes sit behind the share enumeration and rate
+// limiting middleware, keyed by drop ID.
+
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+)
+
+// maxInProgressUploadsPerDrop caps concurrent uploads through one drop, in
+// place of the per-user cap that applies to the owner's own uploads.
+const maxInProgressUploadsPerDrop = 4
+
+// fileDrop is a drop as loaded for an upload.
+type fileDrop struct {
+	DropID        string
+	OwnerUsername string
+	PublicKey     string
+	MaxBytes      int64
+	MaxUploads    sql.NullInt64
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+}
+
+// FileDropRequest is the body of POST /api/drops.
+type FileDropRequest struct {
+	DropID              string `json:"drop_id"`               // Client-generated, same format as share IDs
+	PublicKey           string `json:"public_key"`            // Base64 hybrid public key
+	EncryptedPrivateKey string `json:"encrypted_private_key"` // Base64 private key, AES-GCM under the account key
+	MaxBytes            int64  `json:"max_bytes"`             // Total encrypted bytes the drop accepts
+	MaxUploads          *int   `json:"max_uploads"`           // Optional number of files (nil = unlimited)
+	ExpiresAfterMinutes int    `json:"expires_after_minutes"` // Required
+}
+
+// FileDropResponse is the response to POST /api/drops. The drop URL carries
+// the public key fingerprint in its fragment, which never reaches the
+// server, so senders can verify the key they are served.
+type FileDropResponse struct {
+	DropID      string    `json:"drop_id"`
+	DropURL     string    `json:"drop_url"`
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CreateFileDrop creates a file drop for the authenticated user.
+func CreateFileDrop(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	var request FileDropRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	if !isValidShareID(request.DropID) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid drop ID format")
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(request.PublicKey)
+	if err != nil || len(publicKey) != crypto.HybridPublicKeySize {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid public key")
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(request.EncryptedPrivateKey)
+	if err != nil || len(privateKey) != crypto.HybridPrivateKeySize+crypto.AesGcmOverhead() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid encrypted private key")
+	}
+	if request.MaxBytes <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Max bytes must be positive")
+	}
+	if request.MaxUploads != nil && *request.MaxUploads < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Max uploads must be at least 1")
+	}
+	if request.ExpiresAfterMinutes <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "A file drop must expire; expires_after_minutes is required")
+	}
+
+	baseURL, err := publicShareBaseURL(c)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to construct drop URL for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Share URL configuration error")
+	}
+
+	var existing string
+	err = database.DB.QueryRow("SELECT drop_id FROM file_drops WHERE drop_id = ?", request.DropID).Scan(&existing)
+	if err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "Drop ID already exists, please retry")
+	} else if err != sql.ErrNoRows {
+		logging.ErrorLogger.Printf("Database error checking drop_id uniqueness: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to validate drop ID")
+	}
+
+	var maxUploads sql.NullInt64
+	if request.MaxUploads != nil {
+		maxUploads = sql.NullInt64{Int64: int64(*request.MaxUploads), Valid: true}
+	}
+	createdAt := time.Now()
+	expiresAt := createdAt.Add(time.Duration(request.ExpiresAfterMinutes) * time.Minute)
+
+	_, err = database.DB.Exec(`
+		INSERT INTO file_drops (drop_id, owner_username, public_key, encrypted_private_key, max_bytes, max_uploads, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)`,
+		request.DropID, username, request.PublicKey, request.EncryptedPrivateKey, request.MaxBytes, maxUploads, expiresAt,
+	)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to create file drop for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create file drop")
+	}
+
+	logging.InfoLogger.Printf("File drop created: drop_id=%s..., owner=%s", request.DropID[:8], username)
+	database.LogUserAction(username, "created_drop", request.DropID[:8]+"...")
+
+	fingerprint := crypto.HybridKeyFingerprint(publicKey)
+	return c.JSON(http.StatusOK, FileDropResponse{
+		DropID:      request.DropID,
+		DropURL:     baseURL + "/api/public/drops/" + request.DropID + "#" + fingerprint,
+		Fingerprint: fingerprint,
+		CreatedAt:   createdAt,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// ListFileDrops lists the authenticated user's file drops with their usage.
+// pending_files counts received files not yet re-wrapped by the owner.
+func ListFileDrops(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	rows, err := database.DB.Query(`
+		SELECT d.drop_id, d.created_at, d.expires_at, d.revoked_at, d.max_bytes, d.max_uploads,
+		       (SELECT COUNT(*) FROM file_drop_uploads du JOIN upload_sessions s ON s.id = du.session_id
+		         WHERE du.drop_id = d.drop_id AND s.status IN ('in_progress', 'completed')),
+		       (SELECT COALESCE(SUM(s.total_size), 0) FROM file_drop_uploads du JOIN upload_sessions s ON s.id = du.session_id
+		         WHERE du.drop_id = d.drop_id AND s.status IN ('in_progress', 'completed')),
+		       (SELECT COUNT(*) FROM file_drop_uploads du
+		         JOIN upload_sessions s ON s.id = du.session_id
+		         JOIN file_metadata fm ON fm.file_id = s.file_id
+		         WHERE du.drop_id = d.drop_id AND fm.password_type = 'drop' AND fm.deleted_at IS NULL)
+		FROM file_drops d
+		WHERE d.owner_username = ?
+		ORDER BY d.created_at DESC
+	`, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query file drops for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drops")
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	drops := []map[string]interface{}{}
+	for rows.Next() {
+		var (
+			dropID                 string
+			createdAt, expiresAt   *time.Time
+			revokedAt              *time.Time
+			maxBytes               float64 // rqlite returns numbers as float64
+			maxUploads             sql.NullFloat64
+			uploadCount, usedBytes float64
+			pendingFiles           float64
+		)
+		if err := rows.Scan(&dropID, &createdAt, &expiresAt, &revokedAt, &maxBytes, &maxUploads,
+			&uploadCount, &usedBytes, &pendingFiles); err != nil {
+			logging.ErrorLogger.Printf("Error scanning file drop row: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drops")
+		}
+
+		drop := map[string]interface{}{
+			"drop_id":       dropID,
+			"created_at":    createdAt,
+			"expires_at":    expiresAt,
+			"revoked_at":    revokedAt,
+			"max_bytes":     int64(maxBytes),
+			"max_uploads":   nil,
+			"upload_count":  int64(uploadCount),
+			"used_bytes":    int64(usedBytes),
+			"pending_files": int64(pendingFiles),
+			"is_active":     revokedAt == nil && expiresAt != nil && now.Before(*expiresAt),
+		}
+		if maxUploads.Valid {
+			drop["max_uploads"] = int64(maxUploads.Float64)
+		}
+		drops = append(drops, drop)
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Error iterating file drops: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drops")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"drops": drops,
+	})
+}
+
+// GetFileDrop returns one of the authenticated user's drops with its wrapped
+// private key and the files received through it.
+func GetFileDrop(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	dropID := c.Param("id")
+
+	var (
+		owner, publicKey, encryptedPrivateKey string
+		createdAt, expiresAt, revokedAt       *time.Time
+	)
+	err := database.DB.QueryRow(`
+		SELECT owner_username, public_key, encrypted_private_key, created_at, expires_at, revoked_at
+		FROM file_drops WHERE drop_id = ?
+	`, dropID).Scan(&owner, &publicKey, &encryptedPrivateKey, &createdAt, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows || (err == nil && owner != username) {
+		return echo.NewHTTPError(http.StatusNotFound, "File drop not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Database error loading file drop: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drop")
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.file_id, s.status, s.total_size, du.created_at,
+		       CASE WHEN fm.file_id IS NULL THEN 0 ELSE 1 END,
+		       COALESCE(fm.password_type, '')
+		FROM file_drop_uploads du
+		JOIN upload_sessions s ON s.id = du.session_id
+		LEFT JOIN file_metadata fm ON fm.file_id = s.file_id AND fm.deleted_at IS NULL
+		WHERE du.drop_id = ?
+		ORDER BY du.created_at
+	`, dropID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query drop uploads for %s: %v", dropID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drop")
+	}
+	defer rows.Close()
+
+	uploads := []map[string]interface{}{}
+	for rows.Next() {
+		var (
+			fileID, status, passwordType string
+			totalSize, present           float64 // rqlite returns numbers as float64
+			uploadedAt                   *time.Time
+		)
+		if err := rows.Scan(&fileID, &status, &totalSize, &uploadedAt, &present, &passwordType); err != nil {
+			logging.ErrorLogger.Printf("Error scanning drop upload row: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drop")
+		}
+		uploads = append(uploads, map[string]interface{}{
+			"file_id":     fileID,
+			"status":      status,
+			"size_bytes":  int64(totalSize),
+			"uploaded_at": uploadedAt,
+			"in_vault":    present == 1,
+			"accepted":    present == 1 && passwordType != "drop",
+		})
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Error iterating drop uploads: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file drop")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"drop_id":               dropID,
+		"public_key":            publicKey,
+		"encrypted_private_key": encryptedPrivateKey,
+		"created_at":            createdAt,
+		"expires_at":            expiresAt,
+		"revoked_at":            revokedAt,
+		"uploads":               uploads,
+	})
+}
+
+// RevokeFileDrop closes one of the authenticated user's drops. Files already
+// received stay in the vault.
+func RevokeFileDrop(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	dropID := c.Param("id")
+
+	result, err := database.DB.Exec(`
+		UPDATE file_drops SET revoked_at = CURRENT_TIMESTAMP
+		WHERE drop_id = ? AND owner_username = ? AND revoked_at IS NULL
+	`, dropID, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to revoke file drop: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke file drop")
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		var owner string
+		err := database.DB.QueryRow("SELECT owner_username FROM file_drops WHERE drop_id = ?", dropID).Scan(&owner)
+		if err != nil || owner != username {
+			return echo.NewHTTPError(http.StatusNotFound, "File drop not found")
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "File drop is already revoked")
+	}
+
+	database.LogUserAction(username, "revoked_drop", dropID)
+	logging.InfoLogger.Printf("File drop revoked: %s", dropID)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "File drop revoked successfully",
+	})
+}
+
+// loadPublicFileDrop loads the drop named by the :id route parameter for an
+// anonymous request. Unknown IDs feed the share enumeration guard; revoked
+// drops, and expired drops unless allowExpired, are refused.
+func loadPublicFileDrop(c echo.Context, endpoint string, allowExpired bool) (*fileDrop, error) {
+	dropID := c.Param("id")
+	entityID := logging.GetOrCreateEntityID(c)
+
+	var drop fileDrop
+	var maxBytes float64 // rqlite returns numbers as float64
+	var maxUploads sql.NullFloat64
+	err := database.DB.QueryRow(`
+		SELECT drop_id, owner_username, public_key, max_bytes, max_uploads, expires_at, revoked_at
+		FROM file_drops WHERE drop_id = ?
+	`, dropID).Scan(&drop.DropID, &drop.OwnerUsername, &drop.PublicKey, &maxBytes, &maxUploads, &drop.ExpiresAt, &drop.RevokedAt)
+	if err == sql.ErrNoRows {
+		logging.LogSecurityEventWithEntityID(
+			logging.EventShareNotFound,
+			entityID,
+			map[string]interface{}{
+				"endpoint":        endpoint,
+				"share_id_prefix": dropID[:min(8, len(dropID))],
+			},
+		)
+		NotifyShareNotFound(entityID, dropID)
+		return nil, echo.NewHTTPError(http.StatusNotFound, "File drop not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Database error accessing file drop: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+	}
+	drop.MaxBytes = int64(maxBytes)
+	if maxUploads.Valid {
+		drop.MaxUploads = sql.NullInt64{Int64: int64(maxUploads.Float64), Valid: true}
+	}
+
+	if drop.RevokedAt != nil {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "File drop has been closed")
+	}
+	if !allowExpired && time.Now().After(drop.ExpiresAt) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "File drop has expired")
+	}
+	return &drop, nil
+}
+
+// dropUsage returns the uploads through a drop that count toward its quota
+// (in progress or completed), their total size, and how many are still in
+// progress.
+func dropUsage(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, dropID string) (uploads, usedBytes, inProgress int64, err error) {
+	var uploadsF, usedF, inProgressF float64 // rqlite returns numbers as float64
+	err = q.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(s.total_size), 0),
+		       COALESCE(SUM(CASE WHEN s.status = 'in_progress' THEN 1 ELSE 0 END), 0)
+		FROM file_drop_uploads du
+		JOIN upload_sessions s ON s.id = du.session_id
+		WHERE du.drop_id = ? AND s.status IN ('in_progress', 'completed')
+	`, dropID).Scan(&uploadsF, &usedF, &inProgressF)
+	return int64(uploadsF), int64(usedF), int64(inProgressF), err
+}
+
+// validateDropUploadRequest checks the parts of an upload request that
+// differ for uploads through a drop. The returned error is safe to show the
+// sender.
+func validateDropUploadRequest(drop *fileDrop, passwordType, encryptedFEK, versionOf, encryptedFolder string, totalSize int64) *echo.HTTPError {
+	if passwordType != "drop" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Uploads to a file drop must use password type drop")
+	}
+	envelope, err := base64.StdEncoding.DecodeString(encryptedFEK)
+	if err != nil || len(envelope) != crypto.DropFEKEnvelopeSize() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file drop FEK envelope")
+	}
+	if _, keyType, err := crypto.ParseFEKEnvelopeHeader(envelope); err != nil || keyType != "drop" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file drop FEK envelope")
+	}
+	if versionOf != "" || encryptedFolder != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Uploads to a file drop cannot set a version or folder")
+	}
+	if totalSize > drop.MaxBytes {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is larger than this file drop accepts")
+	}
+	return nil
+}
+
+// checkDropCapacity checks, inside the upload's transaction, that one more
+// upload of totalSize bytes fits the drop's quota.
+func checkDropCapacity(tx *sql.Tx, drop *fileDrop, totalSize int64) *echo.HTTPError {
+	uploads, usedBytes, inProgress, err := dropUsage(tx, drop.DropID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to read usage of file drop %s...: %v", drop.DropID[:8], err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify file drop capacity")
+	}
+	if drop.MaxUploads.Valid && uploads >= drop.MaxUploads.Int64 {
+		return echo.NewHTTPError(http.StatusForbidden, "This file drop accepts no more files")
+	}
+	if usedBytes+totalSize > drop.MaxBytes {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("File is larger than the %d bytes this file drop still accepts", max(drop.MaxBytes-usedBytes, 0)))
+	}
+	if inProgress >= maxInProgressUploadsPerDrop {
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many uploads in progress for this file drop; try again later")
+	}
+	return nil
+}
+
+// requireDropSession checks that the :sessionId upload session was opened
+// through drop. Anything else is reported as not found.
+func requireDropSession(c echo.Context, drop *fileDrop) error {
+	var sessionDrop string
+	err := database.DB.QueryRow(
+		"SELECT drop_id FROM file_drop_uploads WHERE session_id = ?",
+		c.Param("sessionId"),
+	).Scan(&sessionDrop)
+	if err != nil && err != sql.ErrNoRows {
+		logging.ErrorLogger.Printf("Database error checking drop upload session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get session details")
+	}
+	if err == sql.ErrNoRows || sessionDrop != drop.DropID {
+		entityID := logging.GetOrCreateEntityID(c)
+		if recordErr := recordFailedAttempt(drop.DropID, entityID); recordErr != nil {
+			logging.ErrorLogger.Printf("Failed to record drop session attempt: %v", recordErr)
+		}
+		return echo.NewHTTPError(http.StatusNotFound, "Upload session not found")
+	}
+	return nil
+}
+
+// GetPublicFileDrop returns what a sender needs to upload to a drop: its
+// public key and remaining capacity.
+func GetPublicFileDrop(c echo.Context) error {
+	drop, err := loadPublicFileDrop(c, "get_file_drop", false)
+	if err != nil {
+		return err
+	}
+
+	uploads, usedBytes, _, err := dropUsage(database.DB, drop.DropID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to read usage of file drop %s...: %v", drop.DropID[:8], err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+	}
+
+	response := map[string]interface{}{
+		"drop_id":           drop.DropID,
+		"public_key":        drop.PublicKey,
+		"expires_at":        drop.ExpiresAt,
+		"remaining_bytes":   max(drop.MaxBytes-usedBytes, 0),
+		"remaining_uploads": nil,
+	}
+	if drop.MaxUploads.Valid {
+		response["remaining_uploads"] = max(drop.MaxUploads.Int64-uploads, 0)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// InitDropUpload starts an upload through a drop. The request body is that
+// of POST /api/uploads/init with password_type "drop".
+func InitDropUpload(c echo.Context) error {
+	drop, err := loadPublicFileDrop(c, "init_drop_upload", false)
+	if err != nil {
+		return err
+	}
+	return createUploadSession(c, drop.OwnerUsername, drop)
+}
+
+// UploadDropChunk stores one chunk of an upload through a drop.
+func UploadDropChunk(c echo.Context) error {
+	drop, err := loadPublicFileDrop(c, "upload_drop_chunk", true)
+	if err != nil {
+		return err
+	}
+	if err := requireDropSession(c, drop); err != nil {
+		return err
+	}
+	return uploadSessionChunk(c, drop.OwnerUsername)
+}
+
+// CompleteDropUpload finalizes an upload through a drop; the file joins the
+// owner's vault.
+func CompleteDropUpload(c echo.Context) error {
+	drop, err := loadPublicFileDrop(c, "complete_drop_upload", true)
+	if err != nil {
+		return err
+	}
+	if err := requireDropSession(c, drop); err != nil {
+		return err
+	}
+	return completeUploadSession(c, drop.OwnerUsername, drop.DropID)
+}
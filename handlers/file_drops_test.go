@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/crypto"
+)
+
+// dropRequest returns a valid POST /api/drops body for testShareID.
+func dropRequest(t *testing.T) map[string]interface{} {
+	t.Helper()
+	key, err := crypto.GenerateHybridKey()
+	require.NoError(t, err)
+	return map[string]interface{}{
+		"drop_id":               testShareID,
+		"public_key":            base64.StdEncoding.EncodeToString(key.PublicKey()),
+		"encrypted_private_key": base64.StdEncoding.EncodeToString(make([]byte, crypto.HybridPrivateKeySize+crypto.AesGcmOverhead())),
+		"max_bytes":             1000,
+		"max_uploads":           2,
+		"expires_after_minutes": 60,
+	}
+}
+
+// createDrop calls CreateFileDrop and returns the status code and decoded response.
+func createDrop(t *testing.T, username string, body map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	c, rec := authedContext(http.MethodPost, "/api/drops", raw, username)
+	if err := CreateFileDrop(c); err != nil {
+		return httpErrorCode(t, err), nil
+	}
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+// addDropUpload records an upload session opened through the test drop.
+func addDropUpload(t *testing.T, db *sql.DB, sessionID, fileID, status string, size int64) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO upload_sessions (id, file_id, encrypted_filename, filename_nonce, encrypted_sha256sum, sha256sum_nonce,
+			owner_username, total_size, chunk_size, total_chunks, password_type, status, encrypted_fek)
+		VALUES (?, ?, '', '', '', '', 'alice', ?, ?, 1, 'drop', ?, '')`,
+		sessionID, fileID, size, size, status)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO file_drop_uploads (session_id, drop_id) VALUES (?, ?)`, sessionID, testShareID)
+	require.NoError(t, err)
+}
+
+func TestCreateFileDrop_Validation(t *testing.T) {
+	db := setupShareBundleTest(t)
+
+	cases := map[string]func(map[string]interface{}){
+		"bad drop ID":           func(b map[string]interface{}) { b["drop_id"] = "short" },
+		"short public key":      func(b map[string]interface{}) { b["public_key"] = "AAAA" },
+		"short private key":     func(b map[string]interface{}) { b["encrypted_private_key"] = "AAAA" },
+		"no size":               func(b map[string]interface{}) { b["max_bytes"] = 0 },
+		"zero uploads":          func(b map[string]interface{}) { b["max_uploads"] = 0 },
+		"no expiry":             func(b map[string]interface{}) { delete(b, "expires_after_minutes") },
+		"public key not base64": func(b map[string]interface{}) { b["public_key"] = "not base64!" },
+	}
+	for name, mutate := range cases {
+		body := dropRequest(t)
+		mutate(body)
+		status, _ := createDrop(t, "alice", body)
+		assert.Equal(t, http.StatusBadRequest, status, name)
+	}
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_drops`).Scan(&count))
+	assert.Zero(t, count)
+
+	body := dropRequest(t)
+	status, response := createDrop(t, "alice", body)
+	require.Equal(t, http.StatusOK, status)
+	publicKey, err := base64.StdEncoding.DecodeString(body["public_key"].(string))
+	require.NoError(t, err)
+	fingerprint := crypto.HybridKeyFingerprint(publicKey)
+	assert.Equal(t, fingerprint, response["fingerprint"])
+	assert.Contains(t, response["drop_url"], "/api/public/drops/"+testShareID+"#"+fingerprint)
+
+	status, _ = createDrop(t, "bob", dropRequest(t))
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestFileDrop_OwnerViews(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createDrop(t, "alice", dropRequest(t))
+	require.Equal(t, http.StatusOK, status)
+
+	addDropUpload(t, db, "s1", "photo", "completed", 100)
+	addDropUpload(t, db, "s2", "pending-file", "in_progress", 300)
+	addDropUpload(t, db, "s3", "gone-file", "abandoned", 500)
+	_, err := db.Exec(`UPDATE file_metadata SET password_type = 'drop' WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+
+	c, rec := authedContext(http.MethodGet, "/api/drops", nil, "alice")
+	require.NoError(t, ListFileDrops(c))
+	var list struct {
+		Drops []struct {
+			DropID       string `json:"drop_id"`
+			MaxUploads   *int64 `json:"max_uploads"`
+			UploadCount  int64  `json:"upload_count"`
+			UsedBytes    int64  `json:"used_bytes"`
+			PendingFiles int64  `json:"pending_files"`
+			IsActive     bool   `json:"is_active"`
+		} `json:"drops"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Drops, 1)
+	drop := list.Drops[0]
+	assert.Equal(t, testShareID, drop.DropID)
+	require.NotNil(t, drop.MaxUploads)
+	assert.Equal(t, int64(2), *drop.MaxUploads)
+	assert.Equal(t, int64(2), drop.UploadCount, "abandoned uploads do not count")
+	assert.Equal(t, int64(400), drop.UsedBytes)
+	assert.Equal(t, int64(1), drop.PendingFiles)
+	assert.True(t, drop.IsActive)
+
+	c, rec = authedContext(http.MethodGet, "/api/drops", nil, "bob")
+	require.NoError(t, ListFileDrops(c))
+	assert.JSONEq(t, `{"drops":[]}`, rec.Body.String())
+
+	getDrop := func(username string) (int, map[string]interface{}) {
+		c, rec := authedContext(http.MethodGet, "/api/drops/"+testShareID, nil, username)
+		c.SetParamNames("id")
+		c.SetParamValues(testShareID)
+		if err := GetFileDrop(c); err != nil {
+			return httpErrorCode(t, err), nil
+		}
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return rec.Code, response
+	}
+	status, _ = getDrop("bob")
+	assert.Equal(t, http.StatusNotFound, status, "another user's drop")
+
+	status, response := getDrop("alice")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, response["encrypted_private_key"])
+	uploads := response["uploads"].([]interface{})
+	require.Len(t, uploads, 3)
+	first := uploads[0].(map[string]interface{})
+	assert.Equal(t, "photo", first["file_id"])
+	assert.Equal(t, true, first["in_vault"])
+	assert.Equal(t, false, first["accepted"])
+
+	_, err = db.Exec(`UPDATE file_metadata SET password_type = 'account' WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	_, response = getDrop("alice")
+	first = response["uploads"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, true, first["accepted"])
+}
+
+func TestRevokeFileDrop(t *testing.T) {
+	setupShareBundleTest(t)
+	status, _ := createDrop(t, "alice", dropRequest(t))
+	require.Equal(t, http.StatusOK, status)
+
+	revoke := func(username string) int {
+		c, rec := authedContext(http.MethodPost, "/api/drops/"+testShareID+"/revoke", nil, username)
+		c.SetParamNames("id")
+		c.SetParamValues(testShareID)
+		if err := RevokeFileDrop(c); err != nil {
+			return httpErrorCode(t, err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNotFound, revoke("bob"))
+	assert.Equal(t, http.StatusOK, revoke("alice"))
+	assert.Equal(t, http.StatusBadRequest, revoke("alice"), "already revoked")
+
+	c, _ := publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	assert.Equal(t, http.StatusForbidden, httpErrorCode(t, GetPublicFileDrop(c)))
+}
+
+func TestGetPublicFileDrop(t *testing.T) {
+	db := setupShareBundleTest(t)
+
+	c, _ := publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, GetPublicFileDrop(c)))
+
+	body := dropRequest(t)
+	status, _ := createDrop(t, "alice", body)
+	require.Equal(t, http.StatusOK, status)
+	addDropUpload(t, db, "s1", "photo", "completed", 400)
+
+	c, rec := publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	require.NoError(t, GetPublicFileDrop(c))
+	var response struct {
+		PublicKey        string `json:"public_key"`
+		RemainingBytes   int64  `json:"remaining_bytes"`
+		RemainingUploads *int64 `json:"remaining_uploads"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, body["public_key"], response.PublicKey)
+	assert.Equal(t, int64(600), response.RemainingBytes)
+	require.NotNil(t, response.RemainingUploads)
+	assert.Equal(t, int64(1), *response.RemainingUploads)
+	assert.NotContains(t, rec.Body.String(), "alice", "senders do not learn the owner")
+
+	_, err := db.Exec(`UPDATE file_drops SET expires_at = datetime('now', '-1 minute')`)
+	require.NoError(t, err)
+	c, _ = publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	assert.Equal(t, http.StatusForbidden, httpErrorCode(t, GetPublicFileDrop(c)))
+
+	// Uploads already under way may still finish after expiry
+	c, _ = publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	drop, err := loadPublicFileDrop(c, "test", true)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", drop.OwnerUsername)
+}
+
+func TestFileDrop_UploadChecks(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createDrop(t, "alice", dropRequest(t))
+	require.Equal(t, http.StatusOK, status)
+	c, _ := publicShareContext("/api/public/drops/"+testShareID, []string{"id"}, []string{testShareID})
+	drop, err := loadPublicFileDrop(c, "test", false)
+	require.NoError(t, err)
+
+	key, err := crypto.GenerateHybridKey()
+	require.NoError(t, err)
+	envelope, metadataKey, err := crypto.SealDropFEK(key.PublicKey(), "file-1", make([]byte, 32))
+	require.NoError(t, err)
+	crypto.SecureClear(metadataKey)
+	validFEK := base64.StdEncoding.EncodeToString(envelope)
+	accountFEK := base64.StdEncoding.EncodeToString(append([]byte{0x01, 0x01}, envelope[2:]...))
+
+	assert.Nil(t, validateDropUploadRequest(drop, "drop", validFEK, "", "", 1000))
+	checkCode := func(httpErr error) int {
+		require.Error(t, httpErr)
+		return httpErrorCode(t, httpErr)
+	}
+	assert.Equal(t, http.StatusBadRequest, checkCode(validateDropUploadRequest(drop, "account", validFEK, "", "", 10)))
+	assert.Equal(t, http.StatusBadRequest, checkCode(validateDropUploadRequest(drop, "drop", accountFEK, "", "", 10)))
+	assert.Equal(t, http.StatusBadRequest, checkCode(validateDropUploadRequest(drop, "drop", validFEK, "photo", "", 10)))
+	assert.Equal(t, http.StatusBadRequest, checkCode(validateDropUploadRequest(drop, "drop", validFEK, "", "folder", 10)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, checkCode(validateDropUploadRequest(drop, "drop", validFEK, "", "", 1001)))
+
+	capacity := func(size int64) *int {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		if httpErr := checkDropCapacity(tx, drop, size); httpErr != nil {
+			return &httpErr.Code
+		}
+		return nil
+	}
+	assert.Nil(t, capacity(1000))
+
+	addDropUpload(t, db, "s1", "photo", "completed", 600)
+	require.NotNil(t, capacity(500))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, *capacity(500))
+	assert.Nil(t, capacity(400))
+
+	addDropUpload(t, db, "s2", "other", "in_progress", 100)
+	require.NotNil(t, capacity(1))
+	assert.Equal(t, http.StatusForbidden, *capacity(1), "max_uploads reached")
+
+	// Sessions are only reachable through the drop that opened them
+	c, _ = publicShareContext("/", []string{"id", "sessionId"}, []string{testShareID, "s2"})
+	assert.NoError(t, requireDropSession(c, drop))
+	c, _ = publicShareContext("/", []string{"id", "sessionId"}, []string{testShareID, "unknown"})
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, requireDropSession(c, drop)))
+}
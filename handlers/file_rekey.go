@@ -0,0 +1,160 @@
+// file_rekey.go - Rename a file or re-wrap its FEK without re-uploading it
+// (see models/file_rekey.go). The client sends a new encrypted filename, or
+// the FEK unwrapped with the old KEK and wrapped again for the new password
+// type; the stored object is never read or rewritten.
+
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+// UpdateFileRequest renames and/or re-keys a file. The filename pair and
+// the encrypted_fek/password_type pair are each optional but must be sent
+// whole; password_hint is only used with a custom password. The SHA-256
+// pair may only accompany a re-key. A file received through a file drop,
+// whose metadata was encrypted for the drop rather than the account, can
+// only be updated by re-keying it with a new filename and SHA-256.
+type UpdateFileRequest struct {
+	EncryptedFilename  *string `json:"encrypted_filename"`
+	FilenameNonce      *string `json:"filename_nonce"`
+	EncryptedFEK       *string `json:"encrypted_fek"`
+	PasswordType       *string `json:"password_type"`
+	PasswordHint       string  `json:"password_hint"`
+	EncryptedSHA256sum *string `json:"encrypted_sha256sum"`
+	SHA256sumNonce     *string `json:"sha256sum_nonce"`
+}
+
+// fekEnvelopeMatches reports whether a base64 FEK envelope carries the
+// header wrapFEK writes for passwordType: version 0x01 followed by the key
+// type byte. The server cannot check the ciphertext, but a mismatched header
+// would leave password_type pointing clients at the wrong KEK.
+func fekEnvelopeMatches(encryptedFEK, passwordType string) bool {
+	keyType, err := crypto.KeyTypeForContext(passwordType)
+	if err != nil {
+		return false
+	}
+	envelope, err := base64.StdEncoding.DecodeString(encryptedFEK)
+	if err != nil || len(envelope) <= 2 {
+		return false
+	}
+	return envelope[0] == 0x01 && envelope[1] == keyType
+}
+
+// UpdateFile handles PATCH /api/files/:fileId
+// Replaces the encrypted filename and/or the FEK envelope of one of the
+// user's files. The file's search tokens are cleared on rename; the
+// response reports whether the client should index the new name.
+func UpdateFile(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+	fileID := c.Param("fileId")
+
+	var request UpdateFileRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	var filename *models.EncryptedValue
+	if request.EncryptedFilename != nil || request.FilenameNonce != nil {
+		if request.EncryptedFilename == nil || request.FilenameNonce == nil ||
+			*request.EncryptedFilename == "" || *request.FilenameNonce == "" {
+			return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_filename",
+				"Encrypted filename and filename nonce must be sent together")
+		}
+		filename = &models.EncryptedValue{Ciphertext: *request.EncryptedFilename, Nonce: *request.FilenameNonce}
+	}
+
+	var rekey *models.FileRekey
+	if request.EncryptedFEK != nil || request.PasswordType != nil {
+		if request.EncryptedFEK == nil || request.PasswordType == nil {
+			return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_fek",
+				"Encrypted FEK and password type must be sent together")
+		}
+		if *request.PasswordType != "account" && *request.PasswordType != "custom" {
+			return JSONErrorCode(c, http.StatusBadRequest, "invalid_password_type",
+				"Invalid password type")
+		}
+		if !fekEnvelopeMatches(*request.EncryptedFEK, *request.PasswordType) {
+			return JSONErrorCode(c, http.StatusBadRequest, "invalid_encrypted_fek",
+				"Encrypted FEK envelope does not match the password type")
+		}
+		rekey = &models.FileRekey{EncryptedFEK: *request.EncryptedFEK, PasswordType: *request.PasswordType}
+		if rekey.PasswordType == "custom" {
+			rekey.PasswordHint = request.PasswordHint
+		}
+		if request.EncryptedSHA256sum != nil || request.SHA256sumNonce != nil {
+			if request.EncryptedSHA256sum == nil || request.SHA256sumNonce == nil ||
+				*request.EncryptedSHA256sum == "" || *request.SHA256sumNonce == "" {
+				return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_sha256sum",
+					"Encrypted SHA256 and SHA256 nonce must be sent together")
+			}
+			rekey.SHA256 = &models.EncryptedValue{Ciphertext: *request.EncryptedSHA256sum, Nonce: *request.SHA256sumNonce}
+		}
+	} else if request.PasswordHint != "" {
+		return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_fek",
+			"A password hint can only be set when re-keying")
+	} else if request.EncryptedSHA256sum != nil || request.SHA256sumNonce != nil {
+		return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_fek",
+			"The encrypted SHA256 can only be replaced when re-keying")
+	}
+
+	if filename == nil && rekey == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Nothing to update")
+	}
+
+	file, err := models.GetFileByFileID(database.DB, fileID)
+	if err != nil {
+		if err.Error() == "file not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Database error checking file ownership for update: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update file")
+	}
+	if file.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+	if file.PasswordType == "drop" && (rekey == nil || filename == nil || rekey.SHA256 == nil) {
+		return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_metadata",
+			"A file drop upload must be re-keyed with its encrypted filename and SHA256 in one request")
+	}
+
+	tokensCleared, err := models.RenameOrRekeyFile(database.DB, fileID, username, filename, rekey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Failed to update file %s: %v", fileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update file")
+	}
+
+	if filename != nil {
+		database.LogUserAction(username, "renamed file", fileID)
+	}
+	if rekey != nil {
+		database.LogUserAction(username, "re-keyed file to "+rekey.PasswordType+" password", fileID)
+	}
+
+	passwordType := file.PasswordType
+	if rekey != nil {
+		passwordType = rekey.PasswordType
+	}
+	return JSONResponse(c, http.StatusOK, "File updated", map[string]interface{}{
+		"file_id":               fileID,
+		"password_type":         passwordType,
+		"search_tokens_cleared": tokensCleared,
+	})
+}
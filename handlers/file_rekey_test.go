@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchFile calls UpdateFile and returns the status code.
+func patchFile(t *testing.T, username, fileID, body string) int {
+	t.Helper()
+	c, rec := authedContext(http.MethodPatch, "/api/files/"+fileID, []byte(body), username)
+	c.SetParamNames("fileId")
+	c.SetParamValues(fileID)
+	if err := UpdateFile(c); err != nil {
+		httpErr, ok := err.(*echo.HTTPError)
+		require.True(t, ok, "expected *echo.HTTPError, got %v", err)
+		return httpErr.Code
+	}
+	return rec.Code
+}
+
+// testFEKEnvelope returns a base64 envelope with the header for keyType.
+func testFEKEnvelope(keyType byte) string {
+	return base64.StdEncoding.EncodeToString(append([]byte{0x01, keyType}, make([]byte, 60)...))
+}
+
+func TestUpdateFile_RenameClearsSearchTokens(t *testing.T) {
+	db := setupSearchTest(t)
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "alice", "tax-2024", tokTax))
+
+	require.Equal(t, http.StatusOK, patchFile(t, "alice", "tax-2024",
+		`{"encrypted_filename":"newName","filename_nonce":"newNonce"}`))
+
+	var name, nonce string
+	require.NoError(t, db.QueryRow(`SELECT encrypted_filename, filename_nonce FROM file_metadata WHERE file_id = 'tax-2024'`).Scan(&name, &nonce))
+	assert.Equal(t, "newName", name)
+	assert.Equal(t, "newNonce", nonce)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_search_tokens WHERE file_id = 'tax-2024'`).Scan(&count))
+	assert.Zero(t, count, "tokens of the old name are removed")
+}
+
+func TestUpdateFile_RekeyUpdatesPasswordType(t *testing.T) {
+	db := setupSearchTest(t)
+	custom := testFEKEnvelope(0x02)
+
+	require.Equal(t, http.StatusOK, patchFile(t, "alice", "photo",
+		`{"encrypted_fek":"`+custom+`","password_type":"custom","password_hint":"first pet"}`))
+
+	var fek, passwordType, hint string
+	require.NoError(t, db.QueryRow(`SELECT encrypted_fek, password_type, password_hint FROM file_metadata WHERE file_id = 'photo'`).Scan(&fek, &passwordType, &hint))
+	assert.Equal(t, custom, fek)
+	assert.Equal(t, "custom", passwordType)
+	assert.Equal(t, "first pet", hint)
+
+	// Back to the account password drops the hint
+	require.Equal(t, http.StatusOK, patchFile(t, "alice", "photo",
+		`{"encrypted_fek":"`+testFEKEnvelope(0x01)+`","password_type":"account","password_hint":"ignored"}`))
+	require.NoError(t, db.QueryRow(`SELECT password_type, password_hint FROM file_metadata WHERE file_id = 'photo'`).Scan(&passwordType, &hint))
+	assert.Equal(t, "account", passwordType)
+	assert.Empty(t, hint)
+}
+
+func TestUpdateFile_Validation(t *testing.T) {
+	db := setupSearchTest(t)
+	account := testFEKEnvelope(0x01)
+
+	assert.Equal(t, http.StatusForbidden, patchFile(t, "bob", "photo", `{"encrypted_filename":"x","filename_nonce":"n"}`))
+	assert.Equal(t, http.StatusNotFound, patchFile(t, "alice", "missing", `{"encrypted_filename":"x","filename_nonce":"n"}`))
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{}`), "nothing to update")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"encrypted_filename":"x"}`), "nonce missing")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"encrypted_filename":"","filename_nonce":""}`))
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"encrypted_fek":"`+account+`"}`), "password type missing")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"encrypted_fek":"`+account+`","password_type":"share"}`))
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo",
+		`{"encrypted_fek":"`+account+`","password_type":"custom"}`), "header names the account key type")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"encrypted_fek":"not base64!","password_type":"account"}`))
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo", `{"password_hint":"hint"}`))
+
+	// Trashed files cannot be changed
+	_, err := db.Exec(`UPDATE file_metadata SET deleted_at = CURRENT_TIMESTAMP WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, patchFile(t, "alice", "photo", `{"encrypted_filename":"x","filename_nonce":"n"}`))
+}
+
+func TestUpdateFile_AcceptsDropUpload(t *testing.T) {
+	db := setupSearchTest(t)
+	_, err := db.Exec(`UPDATE file_metadata SET password_type = 'drop' WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	account := testFEKEnvelope(0x01)
+
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo",
+		`{"encrypted_filename":"x","filename_nonce":"n"}`), "rename alone")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo",
+		`{"encrypted_filename":"x","filename_nonce":"n","encrypted_fek":"`+account+`","password_type":"account"}`), "SHA256 missing")
+	assert.Equal(t, http.StatusBadRequest, patchFile(t, "alice", "photo",
+		`{"encrypted_fek":"`+account+`","password_type":"account","encrypted_sha256sum":"s"}`), "SHA256 nonce missing")
+
+	require.Equal(t, http.StatusOK, patchFile(t, "alice", "photo",
+		`{"encrypted_filename":"x","filename_nonce":"n","encrypted_fek":"`+account+`","password_type":"account",`+
+			`"encrypted_sha256sum":"s","sha256sum_nonce":"sn"}`))
+	var passwordType, sha, shaNonce string
+	require.NoError(t, db.QueryRow(`SELECT password_type, encrypted_sha256sum, sha256sum_nonce FROM file_metadata WHERE file_id = 'photo'`).
+		Scan(&passwordType, &sha, &shaNonce))
+	assert.Equal(t, "account", passwordType)
+	assert.Equal(t, "s", sha)
+	assert.Equal(t, "sn", shaNonce)
+}
@@ -0,0 +1,134 @@
+// file_search.go - Encrypted search index (see models/file_search.go and
+// crypto/search_index.go). Clients store blind-index tokens for their files
+// and search by sending the tokens for their query words; the server only
+// matches opaque tokens and answers with file IDs, which the client then
+// resolves through GetFileMetadataBatch.
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+const (
+	maxSearchQueryTokens = 32
+	// maxSearchResults matches maxMetadataBatchSize so a client can resolve
+	// every hit with a single metadata batch request.
+	maxSearchResults = maxMetadataBatchSize
+)
+
+// SearchTokensRequest carries blind-index tokens, for indexing a file or
+// for a search.
+type SearchTokensRequest struct {
+	Tokens []string `json:"tokens"`
+}
+
+// normalizeSearchTokens checks that every token has the blind-index shape
+// and drops duplicates.
+func normalizeSearchTokens(tokens []string, max int) ([]string, error) {
+	if len(tokens) > max {
+		return nil, fmt.Errorf("at most %d tokens are allowed", max)
+	}
+	seen := make(map[string]struct{}, len(tokens))
+	out := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if !crypto.IsValidSearchToken(token) {
+			return nil, fmt.Errorf("invalid search token")
+		}
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		out = append(out, token)
+	}
+	return out, nil
+}
+
+// PutFileSearchTokens handles PUT /api/files/:fileId/search-tokens
+// Replaces the search tokens of one of the user's files. An empty list
+// removes the file from the index.
+func PutFileSearchTokens(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+	fileID := c.Param("fileId")
+
+	var request SearchTokensRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	tokens, err := normalizeSearchTokens(request.Tokens, crypto.MaxSearchKeywords)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	file, err := models.GetFileByFileID(database.DB, fileID)
+	if err != nil {
+		if err.Error() == "file not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Database error checking file ownership for indexing: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update search index")
+	}
+	if file.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	if err := models.SetFileSearchTokens(database.DB, fileID, username, tokens); err != nil {
+		logging.ErrorLogger.Printf("Failed to store search tokens for file %s: %v", fileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update search index")
+	}
+
+	return JSONResponse(c, http.StatusOK, "Search index updated", map[string]interface{}{
+		"file_id": fileID,
+		"tokens":  len(tokens),
+	})
+}
+
+// SearchFiles handles POST /api/files/search
+// Returns the IDs of the user's current files that carry every token in
+// the request, newest first, along with how many files are indexed at all.
+func SearchFiles(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+
+	var request SearchTokensRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	tokens, err := normalizeSearchTokens(request.Tokens, maxSearchQueryTokens)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(tokens) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one search token is required")
+	}
+
+	fileIDs, err := models.SearchFilesByTokens(database.DB, username, tokens, maxSearchResults)
+	if err != nil {
+		logging.ErrorLogger.Printf("SearchFiles: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Search failed")
+	}
+	indexed, err := models.CountIndexedFiles(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("SearchFiles: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Search failed")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"file_ids":      fileIDs,
+		"indexed_files": indexed,
+	})
+}
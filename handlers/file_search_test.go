@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+const (
+	tokTax    = "0123456789abcdef0123456789abcdef"
+	tokReturn = "fedcba9876543210fedcba9876543210"
+	tokPhoto  = "00000000000000000000000000000001"
+)
+
+func setupSearchTest(t *testing.T) *sql.DB {
+	t.Helper()
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	insertTestFile(t, db, provider, "alice", "tax-2024")
+	insertTestFile(t, db, provider, "alice", "tax-2025")
+	insertTestFile(t, db, provider, "alice", "photo")
+	insertTestFile(t, db, provider, "bob", "bob-tax")
+	_, err := db.Exec(`UPDATE file_metadata SET upload_date = '2026-02-01 00:00:00' WHERE file_id = 'tax-2025'`)
+	require.NoError(t, err)
+	return db
+}
+
+func putSearchTokens(t *testing.T, username, fileID string, tokens ...string) int {
+	t.Helper()
+	body, _ := json.Marshal(SearchTokensRequest{Tokens: tokens})
+	c, rec := authedContext(http.MethodPut, "/api/files/"+fileID+"/search-tokens", body, username)
+	c.SetParamNames("fileId")
+	c.SetParamValues(fileID)
+	if err := PutFileSearchTokens(c); err != nil {
+		return httpErrorCode(t, err)
+	}
+	return rec.Code
+}
+
+func searchFiles(t *testing.T, username string, tokens ...string) (int, []string, int) {
+	t.Helper()
+	body, _ := json.Marshal(SearchTokensRequest{Tokens: tokens})
+	c, rec := authedContext(http.MethodPost, "/api/files/search", body, username)
+	if err := SearchFiles(c); err != nil {
+		return httpErrorCode(t, err), nil, 0
+	}
+	var resp struct {
+		FileIDs      []string `json:"file_ids"`
+		IndexedFiles int      `json:"indexed_files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp.FileIDs, resp.IndexedFiles
+}
+
+func TestSearchFiles_MatchesAllTokens(t *testing.T) {
+	db := setupSearchTest(t)
+
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "alice", "tax-2024", tokTax, tokReturn))
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "alice", "tax-2025", tokTax, tokReturn, tokTax))
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "alice", "photo", tokPhoto))
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "bob", "bob-tax", tokTax))
+
+	code, ids, indexed := searchFiles(t, "alice", tokTax)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"tax-2025", "tax-2024"}, ids, "newest first, other users' files excluded")
+	assert.Equal(t, 3, indexed)
+
+	_, ids, _ = searchFiles(t, "alice", tokTax, tokPhoto)
+	assert.Empty(t, ids, "every token must match")
+
+	// Re-indexing replaces the old tokens
+	require.Equal(t, http.StatusOK, putSearchTokens(t, "alice", "tax-2024", tokPhoto))
+	_, ids, _ = searchFiles(t, "alice", tokTax, tokReturn)
+	assert.Equal(t, []string{"tax-2025"}, ids)
+
+	// Trashed files are not found
+	require.NoError(t, models.TrashFile(db, "tax-2025", "alice"))
+	_, ids, _ = searchFiles(t, "alice", tokTax)
+	assert.Empty(t, ids)
+}
+
+func TestSearchFiles_OnlyCurrentVersions(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertVersionedFile(t, db, provider, "alice", "doc-v1", "doc-v1", 1)
+	insertVersionedFile(t, db, provider, "alice", "doc-v2", "doc-v1", 2)
+	require.NoError(t, models.SetFileSearchTokens(db, "doc-v1", "alice", []string{tokTax}))
+	require.NoError(t, models.SetFileSearchTokens(db, "doc-v2", "alice", []string{tokTax}))
+
+	_, ids, _ := searchFiles(t, "alice", tokTax)
+	assert.Equal(t, []string{"doc-v2"}, ids)
+}
+
+func TestPutFileSearchTokens_Validation(t *testing.T) {
+	setupSearchTest(t)
+
+	assert.Equal(t, http.StatusForbidden, putSearchTokens(t, "bob", "tax-2024", tokTax))
+	assert.Equal(t, http.StatusNotFound, putSearchTokens(t, "alice", "missing", tokTax))
+	assert.Equal(t, http.StatusBadRequest, putSearchTokens(t, "alice", "tax-2024", "report"))
+	assert.Equal(t, http.StatusBadRequest, putSearchTokens(t, "alice", "tax-2024", strings.ToUpper(tokTax)))
+
+	tooMany := make([]string, crypto.MaxSearchKeywords+1)
+	for i := range tooMany {
+		tooMany[i] = tokTax
+	}
+	assert.Equal(t, http.StatusBadRequest, putSearchTokens(t, "alice", "tax-2024", tooMany...))
+
+	code, _, _ := searchFiles(t, "alice")
+	assert.Equal(t, http.StatusBadRequest, code, "a search needs at least one token")
+}
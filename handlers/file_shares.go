@@ -24,13 +24,14 @@ import (
 
 // ShareRequest represents a file sharing request (Argon2id-based anonymous shares)
 type ShareRequest struct {
-	ShareID             string `json:"share_id"` // Client-generated share ID
-	FileID              string `json:"file_id"`
-	Salt                string `json:"salt"`                  // Base64-encoded 32-byte salt
-	EncryptedEnvelope   string `json:"encrypted_envelope"`    // Base64-encoded Share Envelope (FEK + Download Token) encrypted with AAD
-	DownloadTokenHash   string `json:"download_token_hash"`   // SHA-256 hash of the Download Token
-	ExpiresAfterMinutes int    `json:"expires_after_minutes"` // Optional expiration in minutes (0 = no expiration)
-	MaxAccesses         *int   `json:"max_accesses"`          // Optional download limit (nil = unlimited)
+	ShareID             string   `json:"share_id"` // Client-generated share ID
+	FileID              string   `json:"file_id"`
+	FileIDs             []string `json:"file_ids,omitempty"`    // Bundle shares: every file, in envelope order (file_id, if set, must be the first)
+	Salt                string   `json:"salt"`                  // Base64-encoded 32-byte salt
+	EncryptedEnvelope   string   `json:"encrypted_envelope"`    // Base64-encoded Share Envelope (FEK + Download Token) encrypted with AAD
+	DownloadTokenHash   string   `json:"download_token_hash"`   // SHA-256 hash of the Download Token
+	ExpiresAfterMinutes int      `json:"expires_after_minutes"` // Optional expiration in minutes (0 = no expiration)
+	MaxAccesses         *int     `json:"max_accesses"`          // Optional download limit (nil = unlimited; per file for bundles)
 }
 
 // ShareResponse represents a file share creation response
@@ -39,6 +40,7 @@ type ShareResponse struct {
 	ShareURL  string     `json:"share_url"`
 	CreatedAt time.Time  `json:"created_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	FileCount int        `json:"file_count,omitempty"`
 }
 
 func publicShareBaseURL(c echo.Context) (string, error) {
@@ -63,7 +65,9 @@ func publicShareBaseURL(c echo.Context) (string, error) {
 	return scheme + "://" + host, nil
 }
 
-// CreateFileShare creates a new Argon2id-based anonymous file share
+// CreateFileShare creates a new Argon2id-based anonymous file share. When
+// file_ids lists several files it creates a share bundle instead (see
+// share_bundles.go); the envelope AAD then binds the first of them.
 func CreateFileShare(c echo.Context) error {
 	username := auth.GetUsernameFromToken(c)
 
@@ -94,6 +98,14 @@ func CreateFileShare(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to validate share ID")
 	}
 
+	isBundle := len(request.FileIDs) > 0
+	if isBundle {
+		if request.FileID != "" && request.FileID != request.FileIDs[0] {
+			return echo.NewHTTPError(http.StatusBadRequest, "File ID must be the first of the bundle's file IDs")
+		}
+		request.FileID = request.FileIDs[0]
+	}
+
 	if request.FileID == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "File ID is required")
 	}
@@ -118,24 +130,30 @@ func CreateFileShare(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Share URL configuration error")
 	}
 
-	// Validate that the user owns the file using the new encrypted schema
-	var ownerUsername string
-	var passwordType string
-
-	err = database.DB.QueryRow(
-		"SELECT owner_username, password_type FROM file_metadata WHERE file_id = ?",
-		request.FileID,
-	).Scan(&ownerUsername, &passwordType)
-
-	if err == sql.ErrNoRows {
-		return echo.NewHTTPError(http.StatusNotFound, "File not found")
-	} else if err != nil {
-		logging.ErrorLogger.Printf("Database error checking file_metadata for file %s: %v", request.FileID, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check file ownership")
-	}
+	if isBundle {
+		if httpErr := validateShareBundleFiles(username, request.FileIDs); httpErr != nil {
+			return httpErr
+		}
+	} else {
+		// Validate that the user owns the file using the new encrypted schema
+		var ownerUsername string
+		var passwordType string
+
+		err = database.DB.QueryRow(
+			"SELECT owner_username, password_type FROM file_metadata WHERE file_id = ? AND deleted_at IS NULL",
+			request.FileID,
+		).Scan(&ownerUsername, &passwordType)
+
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		} else if err != nil {
+			logging.ErrorLogger.Printf("Database error checking file_metadata for file %s: %v", request.FileID, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check file ownership")
+		}
 
-	if ownerUsername != username {
-		return echo.NewHTTPError(http.StatusForbidden, "Not authorized to share this file")
+		if ownerUsername != username {
+			return echo.NewHTTPError(http.StatusForbidden, "Not authorized to share this file")
+		}
 	}
 
 	// Calculate expiration time
@@ -152,11 +170,16 @@ func CreateFileShare(c echo.Context) error {
 	}
 
 	// Create file share record - store salt as base64 string directly
-	_, err = database.DB.Exec(`
+	const insertShareSQL = `
 		INSERT INTO file_share_keys (share_id, file_id, owner_username, salt, encrypted_fek, download_token_hash, created_at, expires_at, max_accesses)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)`,
-		request.ShareID, request.FileID, username, request.Salt, request.EncryptedEnvelope, request.DownloadTokenHash, expiresAt, maxAccesses,
-	)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)`
+	if isBundle {
+		err = createShareBundle(request, username, insertShareSQL, expiresAt, maxAccesses)
+	} else {
+		_, err = database.DB.Exec(insertShareSQL,
+			request.ShareID, request.FileID, username, request.Salt, request.EncryptedEnvelope, request.DownloadTokenHash, expiresAt, maxAccesses,
+		)
+	}
 	if err != nil {
 		logging.ErrorLogger.Printf("Failed to create file share record for file %s: %v", request.FileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create file share")
@@ -165,15 +188,42 @@ func CreateFileShare(c echo.Context) error {
 	shareURL := baseURL + "/shared/" + request.ShareID
 
 	createdAt := time.Now()
-	logging.InfoLogger.Printf("Anonymous share created: file=%s, share_id=%s...", request.FileID, request.ShareID[:8])
-	database.LogUserAction(username, "created_share", fmt.Sprintf("file:%s, share:%s...", request.FileID, request.ShareID[:8]))
-
-	return c.JSON(http.StatusOK, ShareResponse{
+	response := ShareResponse{
 		ShareID:   request.ShareID,
 		ShareURL:  shareURL,
 		CreatedAt: createdAt,
 		ExpiresAt: expiresAt,
-	})
+	}
+	if isBundle {
+		response.FileCount = len(request.FileIDs)
+		logging.InfoLogger.Printf("Anonymous share bundle created: files=%d, share_id=%s...", len(request.FileIDs), request.ShareID[:8])
+		database.LogUserAction(username, "created_share", fmt.Sprintf("bundle:%d files, share:%s...", len(request.FileIDs), request.ShareID[:8]))
+	} else {
+		logging.InfoLogger.Printf("Anonymous share created: file=%s, share_id=%s...", request.FileID, request.ShareID[:8])
+		database.LogUserAction(username, "created_share", fmt.Sprintf("file:%s, share:%s...", request.FileID, request.ShareID[:8]))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// createShareBundle inserts a bundle share and its member rows in one
+// transaction.
+func createShareBundle(request ShareRequest, username, insertShareSQL string, expiresAt *time.Time, maxAccesses sql.NullInt64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(insertShareSQL,
+		request.ShareID, request.FileID, username, request.Salt, request.EncryptedEnvelope, request.DownloadTokenHash, expiresAt, maxAccesses,
+	); err != nil {
+		return err
+	}
+	if err := insertShareBundleFiles(tx, request.ShareID, request.FileIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetShareEnvelope returns the encrypted envelope and salt for a share.
@@ -209,11 +259,12 @@ func GetShareEnvelope(c echo.Context) error {
 		RevokedReason     sql.NullString
 		AccessCount       float64
 		MaxAccesses       sql.NullFloat64
+		SuspendedAt       *time.Time
 	}
 
 	err := database.DB.QueryRow(`
 		SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason,
-		       access_count, max_accesses
+		       access_count, max_accesses, suspended_at
 		FROM file_share_keys 
 		WHERE share_id = ?
 	`, shareID).Scan(
@@ -226,6 +277,7 @@ func GetShareEnvelope(c echo.Context) error {
 		&share.RevokedReason,
 		&share.AccessCount,
 		&share.MaxAccesses,
+		&share.SuspendedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -264,11 +316,48 @@ func GetShareEnvelope(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, reason)
 	}
 
+	// The owner has paused the share (see UpdateShare)
+	if share.SuspendedAt != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Share is suspended")
+	}
+
 	// Check if max accesses limit has been reached
 	if share.MaxAccesses.Valid && int64(share.AccessCount) >= int64(share.MaxAccesses.Float64) {
 		return echo.NewHTTPError(http.StatusForbidden, "Download limit reached")
 	}
 
+	// A bundle reports its members instead of a single file; the recipient
+	// matches them against the file list in the decrypted envelope.
+	bundleFiles, err := listShareBundleFiles(shareID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to list share bundle files for %s: %v", shareID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file metadata")
+	}
+	if len(bundleFiles) > 0 {
+		var totalSize int64
+		available := 0
+		for _, file := range bundleFiles {
+			if file.Available {
+				totalSize += file.SizeBytes
+				available++
+			}
+		}
+		if available == 0 {
+			return echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+		}
+
+		logging.InfoLogger.Printf("Share envelope accessed: share_id=%s..., bundle=%d files, entity_id=%s", shareID[:8], len(bundleFiles), entityID)
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"share_id":           shareID,
+			"file_id":            share.FileID,
+			"salt":               share.Salt,
+			"encrypted_envelope": share.EncryptedEnvelope,
+			"size_bytes":         totalSize,
+			"files":              bundleFiles,
+		})
+	}
+
 	// Get file size (plaintext metadata like filename/sha256 is inside the encrypted
 	// ShareEnvelope, decrypted client-side with the share password — no need to send
 	// server-side encrypted metadata that share recipients cannot decrypt)
@@ -277,10 +366,13 @@ func GetShareEnvelope(c echo.Context) error {
 	err = database.DB.QueryRow(`
 		SELECT size_bytes
 		FROM file_metadata
-		WHERE file_id = ?
+		WHERE file_id = ? AND deleted_at IS NULL
 	`, share.FileID).Scan(&sizeF)
 
-	if err != nil {
+	if err == sql.ErrNoRows {
+		// The owner moved the file to the trash; the share works again if it is restored
+		return echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+	} else if err != nil {
 		logging.ErrorLogger.Printf("Failed to get file metadata for %s: %v", share.FileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file metadata")
 	}
@@ -360,6 +452,160 @@ func RevokeShare(c echo.Context) error {
 	})
 }
 
+// ShareUpdateRequest changes the policy of an existing share. Omitted fields
+// are left unchanged.
+type ShareUpdateRequest struct {
+	ExpiresAfterMinutes *int  `json:"expires_after_minutes"` // New expiry counted from now (0 = never expires)
+	ExtendMinutes       *int  `json:"extend_minutes"`        // Move the current expiry later (or earlier, if negative)
+	MaxAccesses         *int  `json:"max_accesses"`          // New download limit (0 = unlimited; per file for bundles)
+	Suspended           *bool `json:"suspended"`             // Pause or resume the share without revoking it
+}
+
+// UpdateShare changes the expiry, download limit or suspension of a share.
+// A share revoked automatically because it expired ('time') or ran out of
+// downloads ('exhausted') is reactivated once the update lifts that limit;
+// shares revoked for any other reason cannot be updated.
+// PATCH /api/shares/:id
+func UpdateShare(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	shareID := c.Param("id")
+
+	var request ShareUpdateRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if request.ExpiresAfterMinutes == nil && request.ExtendMinutes == nil && request.MaxAccesses == nil && request.Suspended == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Nothing to update")
+	}
+	if request.ExpiresAfterMinutes != nil && request.ExtendMinutes != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Use either expires_after_minutes or extend_minutes, not both")
+	}
+	if request.ExpiresAfterMinutes != nil && *request.ExpiresAfterMinutes < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must not be negative")
+	}
+	if request.MaxAccesses != nil && *request.MaxAccesses < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Max accesses must not be negative")
+	}
+
+	var share struct {
+		OwnerUsername string
+		ExpiresAt     *time.Time
+		RevokedAt     *time.Time
+		RevokedReason sql.NullString
+		AccessCount   float64
+		MaxAccesses   sql.NullFloat64
+		SuspendedAt   *time.Time
+	}
+	err := database.DB.QueryRow(`
+		SELECT owner_username, expires_at, revoked_at, revoked_reason,
+		       access_count, max_accesses, suspended_at
+		FROM file_share_keys
+		WHERE share_id = ?
+	`, shareID).Scan(
+		&share.OwnerUsername,
+		&share.ExpiresAt,
+		&share.RevokedAt,
+		&share.RevokedReason,
+		&share.AccessCount,
+		&share.MaxAccesses,
+		&share.SuspendedAt,
+	)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Share not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Database error checking share ownership: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+	}
+	if share.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	autoRevoked := share.RevokedReason.String == "time" || share.RevokedReason.String == "exhausted"
+	if share.RevokedAt != nil && !autoRevoked {
+		return echo.NewHTTPError(http.StatusConflict, "Revoked shares cannot be updated")
+	}
+
+	now := time.Now()
+	expiresAt := share.ExpiresAt
+	switch {
+	case request.ExpiresAfterMinutes != nil && *request.ExpiresAfterMinutes == 0:
+		expiresAt = nil
+	case request.ExpiresAfterMinutes != nil:
+		expiry := now.Add(time.Duration(*request.ExpiresAfterMinutes) * time.Minute)
+		expiresAt = &expiry
+	case request.ExtendMinutes != nil:
+		if share.ExpiresAt == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Share has no expiry to extend")
+		}
+		expiry := share.ExpiresAt.Add(time.Duration(*request.ExtendMinutes) * time.Minute)
+		expiresAt = &expiry
+	}
+	expiryChanged := request.ExpiresAfterMinutes != nil || request.ExtendMinutes != nil
+	if expiryChanged && expiresAt != nil && !expiresAt.After(now) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	accessCount := int64(share.AccessCount)
+	var maxAccesses sql.NullInt64
+	if share.MaxAccesses.Valid {
+		maxAccesses = sql.NullInt64{Int64: int64(share.MaxAccesses.Float64), Valid: true}
+	}
+	if request.MaxAccesses != nil {
+		if *request.MaxAccesses == 0 {
+			maxAccesses = sql.NullInt64{}
+		} else if int64(*request.MaxAccesses) <= accessCount {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("Max accesses must be more than the current download count (%d)", accessCount))
+		} else {
+			maxAccesses = sql.NullInt64{Int64: int64(*request.MaxAccesses), Valid: true}
+		}
+	}
+
+	suspendedAt := share.SuspendedAt
+	if request.Suspended != nil {
+		if !*request.Suspended {
+			suspendedAt = nil
+		} else if suspendedAt == nil {
+			suspendedAt = &now
+		}
+	}
+
+	// Lift an automatic revocation once neither limit applies any more
+	revokedAt, revokedReason := share.RevokedAt, share.RevokedReason
+	expired := expiresAt != nil && !expiresAt.After(now)
+	exhausted := maxAccesses.Valid && accessCount >= maxAccesses.Int64
+	if revokedAt != nil && !expired && !exhausted {
+		revokedAt, revokedReason = nil, sql.NullString{}
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE file_share_keys
+		SET expires_at = ?, max_accesses = ?, suspended_at = ?, revoked_at = ?, revoked_reason = ?
+		WHERE share_id = ?
+	`, expiresAt, maxAccesses, suspendedAt, revokedAt, revokedReason, shareID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to update share: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update share")
+	}
+
+	database.LogUserAction(username, "updated_share", shareID)
+	logging.InfoLogger.Printf("Share updated: %s", shareID)
+
+	response := map[string]interface{}{
+		"message":      "Share updated successfully",
+		"share_id":     shareID,
+		"expires_at":   expiresAt,
+		"max_accesses": nil,
+		"access_count": accessCount,
+		"suspended":    suspendedAt != nil,
+		"is_active":    revokedAt == nil && suspendedAt == nil && !expired && !exhausted,
+	}
+	if maxAccesses.Valid {
+		response["max_accesses"] = maxAccesses.Int64
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
 // GetSharedFile renders the share access page
 func GetSharedFile(c echo.Context) error {
 	shareID := c.Param("id")
@@ -411,7 +657,7 @@ func GetSharedFile(c echo.Context) error {
 	err = database.DB.QueryRow(`
 		SELECT 1
 		FROM file_metadata
-		WHERE file_id = ?
+		WHERE file_id = ? AND deleted_at IS NULL
 	`, share.FileID).Scan(&fileExists)
 
 	if err != nil {
@@ -436,11 +682,16 @@ func ListShares(c echo.Context) error {
 	}
 
 	// Query shares (owner already has file metadata via /api/files endpoints —
-	// no need to duplicate encrypted metadata here)
+	// no need to duplicate encrypted metadata here). For bundles, size_bytes is
+	// the total of all members and file_count is non-zero.
 	rows, err := database.DB.Query(`
 		SELECT sk.share_id, sk.file_id, sk.created_at, sk.expires_at,
 		       sk.revoked_at, sk.revoked_reason, sk.access_count, sk.max_accesses,
-		       fm.size_bytes
+		       sk.suspended_at,
+		       COALESCE((SELECT SUM(bfm.size_bytes) FROM file_share_bundle_files bf
+		                 JOIN file_metadata bfm ON bfm.file_id = bf.file_id
+		                 WHERE bf.share_id = sk.share_id), fm.size_bytes),
+		       (SELECT COUNT(*) FROM file_share_bundle_files bf WHERE bf.share_id = sk.share_id)
 		FROM file_share_keys sk
 		JOIN file_metadata fm ON sk.file_id = fm.file_id
 		WHERE sk.owner_username = ?
@@ -461,6 +712,7 @@ func ListShares(c echo.Context) error {
 	}
 
 	var shares []map[string]interface{}
+	var bundleShareIDs []string
 	for rows.Next() {
 		var share struct {
 			ShareID       string
@@ -471,7 +723,9 @@ func ListShares(c echo.Context) error {
 			RevokedReason sql.NullString
 			AccessCount   sql.NullFloat64
 			MaxAccesses   sql.NullFloat64
+			SuspendedAt   sql.NullString
 			Size          sql.NullFloat64 // rqlite returns numbers as float64
+			FileCount     sql.NullFloat64
 		}
 
 		if err := rows.Scan(
@@ -483,7 +737,9 @@ func ListShares(c echo.Context) error {
 			&share.RevokedReason,
 			&share.AccessCount,
 			&share.MaxAccesses,
+			&share.SuspendedAt,
 			&share.Size,
+			&share.FileCount,
 		); err != nil {
 			logging.ErrorLogger.Printf("Error scanning share row: %v", err)
 			continue
@@ -491,9 +747,9 @@ func ListShares(c echo.Context) error {
 
 		shareURL := baseURL + "/shared/" + share.ShareID
 
-		// Compute is_active: not revoked, not expired, and not exhausted
+		// Compute is_active: not revoked, not suspended, not expired, and not exhausted
 		isActive := true
-		if share.RevokedAt.Valid {
+		if share.RevokedAt.Valid || share.SuspendedAt.Valid {
 			isActive = false
 		}
 		if share.ExpiresAt.Valid && share.ExpiresAt.String != "" {
@@ -539,6 +795,12 @@ func ListShares(c echo.Context) error {
 			shareData["size_bytes"] = int64(share.Size.Float64)
 		}
 
+		shareData["file_count"] = 1
+		if share.FileCount.Valid && share.FileCount.Float64 > 0 {
+			shareData["file_count"] = int64(share.FileCount.Float64)
+			bundleShareIDs = append(bundleShareIDs, share.ShareID)
+		}
+
 		if share.ExpiresAt.Valid {
 			shareData["expires_at"] = share.ExpiresAt.String
 		} else {
@@ -563,9 +825,29 @@ func ListShares(c echo.Context) error {
 			shareData["max_accesses"] = nil
 		}
 
+		if share.SuspendedAt.Valid {
+			shareData["suspended_at"] = share.SuspendedAt.String
+		} else {
+			shareData["suspended_at"] = nil
+		}
+
 		shares = append(shares, shareData)
 	}
 
+	// Bundles also list every member file
+	if len(bundleShareIDs) > 0 {
+		bundleFileIDs, err := listShareBundleFileIDs(bundleShareIDs)
+		if err != nil {
+			logging.ErrorLogger.Printf("Failed to list share bundle files: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shares")
+		}
+		for _, shareData := range shares {
+			if fileIDs, ok := bundleFileIDs[shareData["share_id"].(string)]; ok {
+				shareData["file_ids"] = fileIDs
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"shares":   shares,
 		"limit":    limit,
@@ -603,11 +885,12 @@ func GetShareDownloadMetadata(c echo.Context) error {
 		RevokedReason sql.NullString
 		AccessCount   float64
 		MaxAccesses   sql.NullFloat64
+		SuspendedAt   *time.Time
 	}
 
 	err := database.DB.QueryRow(`
 		SELECT file_id, expires_at, revoked_at, revoked_reason,
-		       access_count, max_accesses
+		       access_count, max_accesses, suspended_at
 		FROM file_share_keys 
 		WHERE share_id = ?
 	`, shareID).Scan(
@@ -617,6 +900,7 @@ func GetShareDownloadMetadata(c echo.Context) error {
 		&share.RevokedReason,
 		&share.AccessCount,
 		&share.MaxAccesses,
+		&share.SuspendedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -655,11 +939,22 @@ func GetShareDownloadMetadata(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, reason)
 	}
 
+	// The owner has paused the share (see UpdateShare)
+	if share.SuspendedAt != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Share is suspended")
+	}
+
 	// Check if max accesses limit has been reached
 	if share.MaxAccesses.Valid && int64(share.AccessCount) >= int64(share.MaxAccesses.Float64) {
 		return echo.NewHTTPError(http.StatusForbidden, "Download limit reached")
 	}
 
+	// Bundles name the member being downloaded with ?file_id=
+	fileID, _, httpErr := resolveShareFile(shareID, share.FileID, c.QueryParam("file_id"))
+	if httpErr != nil {
+		return httpErr
+	}
+
 	// Get file chunk info
 	// Note: rqlite returns numbers as float64, so we scan into float64 and convert
 	var sizeBytes float64
@@ -669,11 +964,13 @@ func GetShareDownloadMetadata(c echo.Context) error {
 	err = database.DB.QueryRow(`
 		SELECT size_bytes, chunk_count, chunk_size_bytes
 		FROM file_metadata
-		WHERE file_id = ?
-	`, share.FileID).Scan(&sizeBytes, &chunkCount, &chunkSizeBytes)
+		WHERE file_id = ? AND deleted_at IS NULL
+	`, fileID).Scan(&sizeBytes, &chunkCount, &chunkSizeBytes)
 
-	if err != nil {
-		logging.ErrorLogger.Printf("Failed to get file metadata for %s: %v", share.FileID, err)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to get file metadata for %s: %v", fileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file metadata")
 	}
 
@@ -690,10 +987,10 @@ func GetShareDownloadMetadata(c echo.Context) error {
 		chunkSizeBytesInt = arkcrypto.PlaintextChunkSize() // default from config
 	}
 
-	logging.InfoLogger.Printf("Share chunk info accessed: share_id=%s..., file=%s, entity_id=%s", shareID[:8], share.FileID, entityID)
+	logging.InfoLogger.Printf("Share chunk info accessed: share_id=%s..., file=%s, entity_id=%s", shareID[:8], fileID, entityID)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"file_id":          share.FileID,
+		"file_id":          fileID,
 		"size_bytes":       sizeBytesInt,
 		"chunk_count":      chunkCountInt,
 		"chunk_size_bytes": chunkSizeBytesInt,
@@ -729,11 +1026,12 @@ func DownloadShareChunk(c echo.Context) error {
 		DownloadTokenHash string
 		AccessCount       float64
 		MaxAccesses       sql.NullFloat64
+		SuspendedAt       *time.Time
 	}
 
 	err = database.DB.QueryRow(`
 		SELECT file_id, owner_username, expires_at, revoked_at, revoked_reason, 
-		       download_token_hash, access_count, max_accesses
+		       download_token_hash, access_count, max_accesses, suspended_at
 		FROM file_share_keys 
 		WHERE share_id = ?
 	`, shareID).Scan(
@@ -745,6 +1043,7 @@ func DownloadShareChunk(c echo.Context) error {
 		&share.DownloadTokenHash,
 		&share.AccessCount,
 		&share.MaxAccesses,
+		&share.SuspendedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -773,6 +1072,12 @@ func DownloadShareChunk(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Share link has expired")
 	}
 
+	// Suspension also stops downloads that are already in progress
+	if share.SuspendedAt != nil {
+		logging.WarningLogger.Printf("Chunk download attempt on suspended share: share_id=%s", shareID[:8])
+		return echo.NewHTTPError(http.StatusForbidden, "Share is suspended")
+	}
+
 	// Validate Download Token using constant-time comparison
 	computedHash, err := hashDownloadToken(downloadToken)
 	if err != nil {
@@ -806,6 +1111,12 @@ func DownloadShareChunk(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Download limit reached")
 	}
 
+	// Bundles name the member being downloaded with ?file_id=
+	fileID, isBundle, httpErr := resolveShareFile(shareID, share.FileID, c.QueryParam("file_id"))
+	if httpErr != nil {
+		return httpErr
+	}
+
 	// Get file metadata
 	// Note: rqlite returns numbers as float64, so we scan into float64 and convert
 	var storageID string
@@ -816,11 +1127,13 @@ func DownloadShareChunk(c echo.Context) error {
 	err = database.DB.QueryRow(`
 		SELECT storage_id, size_bytes, chunk_count, chunk_size_bytes
 		FROM file_metadata
-		WHERE file_id = ?
-	`, share.FileID).Scan(&storageID, &sizeBytesF, &chunkCountF, &chunkSizeBytesF)
+		WHERE file_id = ? AND deleted_at IS NULL
+	`, fileID).Scan(&storageID, &sizeBytesF, &chunkCountF, &chunkSizeBytesF)
 
-	if err != nil {
-		logging.ErrorLogger.Printf("Failed to get file metadata for %s: %v", share.FileID, err)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to get file metadata for %s: %v", fileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file metadata")
 	}
 
@@ -870,7 +1183,18 @@ func DownloadShareChunk(c echo.Context) error {
 	// To prevent concurrent double-spend race conditions (TOCTOU), we execute an UPDATE
 	// statement with a conditional WHERE clause ensuring that the access_count is strictly less
 	// than max_accesses (if configured). We check RowsAffected to confirm the atomic update succeeded.
-	if chunkIndex == 0 {
+	// Bundle members are counted individually (see countShareBundleAccess).
+	if chunkIndex == 0 && isBundle {
+		counted, err := countShareBundleAccess(shareID, fileID)
+		if err != nil {
+			logging.ErrorLogger.Printf("Database error counting share bundle access: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+		}
+		if !counted {
+			logging.WarningLogger.Printf("Atomic access increment rejected for bundle member (limit reached): share_id=%s", shareID[:8])
+			return echo.NewHTTPError(http.StatusForbidden, "Download limit reached")
+		}
+	} else if chunkIndex == 0 {
 		result, err := database.DB.Exec(`
 			UPDATE file_share_keys 
 			SET access_count = access_count + 1 
@@ -900,10 +1224,15 @@ func DownloadShareChunk(c echo.Context) error {
 		}
 	}
 
+	// A new download counts as an access for storage tiering
+	if chunkIndex == 0 {
+		noteFileAccess(fileID)
+	}
+
 	// Get the chunk from storage
-	reader, _, err := storage.Registry.GetObjectChunkWithFallback(c.Request().Context(), storageID, startByte, actualChunkSize)
+	reader, _, err := storage.Registry.GetObjectChunkFrom(c.Request().Context(), fileReadProviders(fileID), storageID, startByte, actualChunkSize)
 	if err != nil {
-		logging.ErrorLogger.Printf("Failed to get chunk %d of file %s from storage: %v", chunkIndex, share.FileID, err)
+		logging.ErrorLogger.Printf("Failed to get chunk %d of file %s from storage: %v", chunkIndex, fileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve chunk from storage")
 	}
 	defer reader.Close()
@@ -918,13 +1247,28 @@ func DownloadShareChunk(c echo.Context) error {
 	c.Response().Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", startByte, endByte, sizeBytes))
 
 	// Log chunk download (only first and last to reduce noise)
+	entityID := logging.GetOrCreateEntityID(c)
 	if chunkIndex == 0 || chunkIndex == chunkCount-1 {
-		entityID := logging.GetOrCreateEntityID(c)
 		logging.InfoLogger.Printf("Share chunk download: share_id=%s..., chunk=%d/%d, entity_id=%s", shareID[:8], chunkIndex, chunkCount, entityID)
 	}
 
+	// Record the download in the owner's access log. Failures are logged
+	// rather than refusing the download.
+	accessID, err := shareAccessForChunk(shareID, fileID, entityID, chunkIndex, chunkCount)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to record share access: share_id=%s..., %v", shareID[:8], err)
+	}
+
 	// Stream the chunk
-	return c.Stream(http.StatusOK, "application/octet-stream", reader)
+	if err := c.Stream(http.StatusOK, "application/octet-stream", reader); err != nil {
+		return err
+	}
+	if accessID != 0 {
+		if err := recordShareAccessChunk(accessID, chunkIndex); err != nil {
+			logging.ErrorLogger.Printf("Failed to record share access chunk: share_id=%s..., %v", shareID[:8], err)
+		}
+	}
+	return nil
 }
 
 // generateShareID creates a cryptographically secure 256-bit share ID using Base64 URL-safe encoding
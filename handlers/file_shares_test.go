@@ -245,9 +245,9 @@ func TestGetShareEnvelope_Expired(t *testing.T) {
 
 	// Mock share lookup - returns expired share (expires_at in the past)
 	expiredTime := time.Now().Add(-24 * time.Hour)
-	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses FROM file_share_keys WHERE share_id = \?`
-	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses"}).
-		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", expiredTime, nil, nil, 0, nil)
+	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses, suspended_at FROM file_share_keys WHERE share_id = \?`
+	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses", "suspended_at"}).
+		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", expiredTime, nil, nil, 0, nil, nil)
 	mock.ExpectQuery(shareSQL).WithArgs("expired-share").WillReturnRows(shareRows)
 
 	err := GetShareEnvelope(c)
@@ -274,9 +274,9 @@ func TestGetShareEnvelope_Revoked(t *testing.T) {
 
 	// Mock share lookup - returns revoked share (revoked_at set)
 	revokedTime := time.Now().Add(-1 * time.Hour)
-	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses FROM file_share_keys WHERE share_id = \?`
-	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses"}).
-		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", nil, revokedTime, "manual", 0, nil)
+	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses, suspended_at FROM file_share_keys WHERE share_id = \?`
+	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses", "suspended_at"}).
+		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", nil, revokedTime, "manual", 0, nil, nil)
 	mock.ExpectQuery(shareSQL).WithArgs("revoked-share").WillReturnRows(shareRows)
 
 	err := GetShareEnvelope(c)
@@ -302,9 +302,9 @@ func TestGetShareEnvelope_MaxAccessesExceeded(t *testing.T) {
 	mock.ExpectQuery(rateLimitSQL).WithArgs("exhausted-share", sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
 
 	// Mock share lookup - access_count has reached max_accesses (3 of 3)
-	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses FROM file_share_keys WHERE share_id = \?`
-	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses"}).
-		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", nil, nil, nil, float64(3), float64(3))
+	shareSQL := `SELECT file_id, owner_username, salt, encrypted_fek, expires_at, revoked_at, revoked_reason, access_count, max_accesses, suspended_at FROM file_share_keys WHERE share_id = \?`
+	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "salt", "encrypted_fek", "expires_at", "revoked_at", "revoked_reason", "access_count", "max_accesses", "suspended_at"}).
+		AddRow("test-file-123", "owneruser", "test-salt", "ZW5jcnlwdGVkLWZlaw==", nil, nil, nil, float64(3), float64(3), nil)
 	mock.ExpectQuery(shareSQL).WithArgs("exhausted-share").WillReturnRows(shareRows)
 
 	err := GetShareEnvelope(c)
@@ -352,13 +352,13 @@ func TestListShares_Success(t *testing.T) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	c.Set("user", token)
 
-	// Mock the JOIN query with new column set (9 columns)
+	// Mock the JOIN query with new column set (11 columns)
 	sharesSQL := `SELECT sk.share_id, sk.file_id, sk.created_at, sk.expires_at`
 	sharesRows := sqlmock.NewRows([]string{
 		"share_id", "file_id", "created_at", "expires_at",
-		"revoked_at", "revoked_reason", "access_count", "max_accesses", "size_bytes",
+		"revoked_at", "revoked_reason", "access_count", "max_accesses", "suspended_at", "size_bytes", "file_count",
 	}).
-		AddRow("share-abc", "file-123", "2026-04-17 10:00:00", nil, nil, nil, float64(2), float64(10), float64(1048576))
+		AddRow("share-abc", "file-123", "2026-04-17 10:00:00", nil, nil, nil, float64(2), float64(10), nil, float64(1048576), float64(0))
 	mock.ExpectQuery(sharesSQL).WithArgs(username, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sharesRows)
 
 	err := ListShares(c)
@@ -377,6 +377,7 @@ func TestListShares_Success(t *testing.T) {
 	assert.Equal(t, "file-123", share["file_id"])
 	assert.Equal(t, true, share["is_active"])
 	assert.Equal(t, float64(2), share["access_count"])
+	assert.Equal(t, float64(1), share["file_count"])
 	assert.NotContains(t, share["share_url"], "evil.example")
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -484,8 +485,8 @@ func TestDownloadShareChunk_AtomicDoubleSpendBlocked(t *testing.T) {
 
 	// 1. Mock share SELECT lookup
 	shareSQL := `SELECT file_id, owner_username, expires_at, revoked_at, revoked_reason, \s*download_token_hash, access_count, max_accesses`
-	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "expires_at", "revoked_at", "revoked_reason", "download_token_hash", "access_count", "max_accesses"}).
-		AddRow("test-file-123", "owneruser", nil, nil, nil, expectedHash, float64(2), float64(2)) // access_count == max_accesses
+	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "expires_at", "revoked_at", "revoked_reason", "download_token_hash", "access_count", "max_accesses", "suspended_at"}).
+		AddRow("test-file-123", "owneruser", nil, nil, nil, expectedHash, float64(2), float64(2), nil) // access_count == max_accesses
 	mock.ExpectQuery(shareSQL).WithArgs("test-share-id").WillReturnRows(shareRows)
 
 	// Since access_count >= max_accesses, the token-verification and atomic UPDATE are never reached because chunkIndex == 0 check blocks early.
@@ -511,17 +512,22 @@ func TestDownloadShareChunk_AtomicConditionalFailure(t *testing.T) {
 
 	// 1. Mock share SELECT lookup
 	shareSQL := `SELECT file_id, owner_username, expires_at, revoked_at, revoked_reason, \s*download_token_hash, access_count, max_accesses`
-	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "expires_at", "revoked_at", "revoked_reason", "download_token_hash", "access_count", "max_accesses"}).
-		AddRow("test-file-123", "owneruser", nil, nil, nil, expectedHash, float64(1), float64(2)) // currently 1 of 2
+	shareRows := sqlmock.NewRows([]string{"file_id", "owner_username", "expires_at", "revoked_at", "revoked_reason", "download_token_hash", "access_count", "max_accesses", "suspended_at"}).
+		AddRow("test-file-123", "owneruser", nil, nil, nil, expectedHash, float64(1), float64(2), nil) // currently 1 of 2
 	mock.ExpectQuery(shareSQL).WithArgs("test-share-id").WillReturnRows(shareRows)
 
-	// 2. Mock file metadata lookup
+	// 2. Mock share bundle lookup (a single-file share has no members)
+	bundleSQL := `SELECT COUNT\(\*\), COALESCE\(SUM\(CASE WHEN file_id = \? THEN 1 ELSE 0 END\), 0\)\s+FROM file_share_bundle_files`
+	mock.ExpectQuery(bundleSQL).WithArgs("", "test-share-id").
+		WillReturnRows(sqlmock.NewRows([]string{"members", "matches"}).AddRow(float64(0), float64(0)))
+
+	// 3. Mock file metadata lookup
 	fileMetadataSQL := `SELECT storage_id, size_bytes, chunk_count, chunk_size_bytes`
 	fileMetadataRows := sqlmock.NewRows([]string{"storage_id", "size_bytes", "chunk_count", "chunk_size_bytes"}).
 		AddRow("test-storage-key", float64(1048576), float64(1), float64(1048576))
 	mock.ExpectQuery(fileMetadataSQL).WithArgs("test-file-123").WillReturnRows(fileMetadataRows)
 
-	// 3. Mock atomic UPDATE increment returning 0 rows affected (because another concurrent request grabbed the last spot)
+	// 4. Mock atomic UPDATE increment returning 0 rows affected (because another concurrent request grabbed the last spot)
 	updateSQL := `UPDATE file_share_keys`
 	mock.ExpectExec(updateSQL).WithArgs("test-share-id").WillReturnResult(sqlmock.NewResult(0, 0)) // 0 rows affected
 
@@ -534,3 +540,155 @@ func TestDownloadShareChunk_AtomicConditionalFailure(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+// UpdateShare Tests
+
+// updateShare calls UpdateShare on the test share and returns the status code
+// and decoded response.
+func updateShare(t *testing.T, username string, body map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	c, rec := authedContext(http.MethodPatch, "/api/shares/"+testShareID, raw, username)
+	c.SetParamNames("id")
+	c.SetParamValues(testShareID)
+	if err := UpdateShare(c); err != nil {
+		return httpErrorCode(t, err), nil
+	}
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+// shareEnvelopeStatus fetches the public envelope of the test share.
+func shareEnvelopeStatus(t *testing.T) int {
+	t.Helper()
+	c, rec := publicShareContext("/api/public/shares/"+testShareID+"/envelope", []string{"id"}, []string{testShareID})
+	if err := GetShareEnvelope(c); err != nil {
+		return httpErrorCode(t, err)
+	}
+	return rec.Code
+}
+
+func TestUpdateShare_ExpiryAndLimit(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{
+		"file_id": "photo", "expires_after_minutes": 60, "max_accesses": 2,
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var before time.Time
+	require.NoError(t, db.QueryRow(`SELECT expires_at FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&before))
+
+	status, response := updateShare(t, "alice", map[string]interface{}{"extend_minutes": 24 * 60})
+	require.Equal(t, http.StatusOK, status)
+	var after time.Time
+	require.NoError(t, db.QueryRow(`SELECT expires_at FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&after))
+	assert.Equal(t, 24*time.Hour, after.Sub(before).Round(time.Second))
+	assert.Equal(t, float64(2), response["max_accesses"], "unchanged")
+
+	status, response = updateShare(t, "alice", map[string]interface{}{"expires_after_minutes": 0, "max_accesses": 5})
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, response["expires_at"])
+	assert.Equal(t, float64(5), response["max_accesses"])
+
+	status, response = updateShare(t, "alice", map[string]interface{}{"max_accesses": 0})
+	require.Equal(t, http.StatusOK, status)
+	assert.Nil(t, response["max_accesses"])
+	assert.Equal(t, true, response["is_active"])
+
+	status, _ = updateShare(t, "bob", map[string]interface{}{"max_accesses": 1})
+	assert.Equal(t, http.StatusForbidden, status)
+	_, err := db.Exec(`DELETE FROM file_share_keys`)
+	require.NoError(t, err)
+	status, _ = updateShare(t, "alice", map[string]interface{}{"max_accesses": 1})
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestUpdateShare_Validation(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{"file_id": "photo"})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, http.StatusOK, downloadBundleChunk(t, ""))
+
+	cases := map[string]map[string]interface{}{
+		"nothing to update":     {},
+		"both expiry fields":    {"expires_after_minutes": 60, "extend_minutes": 60},
+		"negative expiry":       {"expires_after_minutes": -5},
+		"no expiry to extend":   {"extend_minutes": 60},
+		"negative max":          {"max_accesses": -1},
+		"max below downloads":   {"max_accesses": 1},
+		"non-boolean suspended": {"suspended": "yes"},
+	}
+	for name, body := range cases {
+		status, _ := updateShare(t, "alice", body)
+		assert.Equal(t, http.StatusBadRequest, status, name)
+	}
+
+	status, _ = updateShare(t, "alice", map[string]interface{}{"expires_after_minutes": 60})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = updateShare(t, "alice", map[string]interface{}{"extend_minutes": -120})
+	assert.Equal(t, http.StatusBadRequest, status, "expiry in the past")
+
+	_, err := db.Exec(`UPDATE file_share_keys SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = 'manual'`)
+	require.NoError(t, err)
+	status, _ = updateShare(t, "alice", map[string]interface{}{"suspended": false})
+	assert.Equal(t, http.StatusConflict, status, "manually revoked shares stay revoked")
+}
+
+func TestUpdateShare_SuspendAndResume(t *testing.T) {
+	setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{"file_id": "photo"})
+	require.Equal(t, http.StatusOK, status)
+
+	status, response := updateShare(t, "alice", map[string]interface{}{"suspended": true})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, response["suspended"])
+	assert.Equal(t, false, response["is_active"])
+	assert.Equal(t, http.StatusForbidden, shareEnvelopeStatus(t))
+	assert.Equal(t, http.StatusForbidden, downloadBundleChunk(t, ""))
+
+	c, rec := authedContext(http.MethodGet, "/api/shares", nil, "alice")
+	require.NoError(t, ListShares(c))
+	var list struct {
+		Shares []map[string]interface{} `json:"shares"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Shares, 1)
+	assert.Equal(t, false, list.Shares[0]["is_active"])
+	assert.NotNil(t, list.Shares[0]["suspended_at"])
+	assert.Nil(t, list.Shares[0]["revoked_at"], "suspension is not a revocation")
+
+	status, _ = updateShare(t, "alice", map[string]interface{}{"suspended": false})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, http.StatusOK, shareEnvelopeStatus(t))
+	assert.Equal(t, http.StatusOK, downloadBundleChunk(t, ""))
+}
+
+func TestUpdateShare_ReactivatesAutoRevokedShares(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{"file_id": "photo", "max_accesses": 1})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, http.StatusOK, downloadBundleChunk(t, ""))
+	_, err := db.Exec(`UPDATE file_share_keys SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = 'exhausted'`)
+	require.NoError(t, err)
+
+	status, response := updateShare(t, "alice", map[string]interface{}{"max_accesses": 2})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, response["is_active"])
+	assert.Equal(t, http.StatusOK, downloadBundleChunk(t, ""))
+
+	_, err = db.Exec(`UPDATE file_share_keys SET max_accesses = NULL, expires_at = ?,
+		revoked_at = CURRENT_TIMESTAMP, revoked_reason = 'time'`, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	status, response = updateShare(t, "alice", map[string]interface{}{"suspended": false})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, response["is_active"], "still expired")
+
+	status, response = updateShare(t, "alice", map[string]interface{}{"expires_after_minutes": 60})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, response["is_active"])
+	var revokedAt sql.NullString
+	require.NoError(t, db.QueryRow(`SELECT revoked_at FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&revokedAt))
+	assert.False(t, revokedAt.Valid)
+}
@@ -0,0 +1,162 @@
+// file_trash.go - Trash for deleted files (see models/file_trash.go).
+// DeleteFile moves a file to the trash unless asked for a permanent delete;
+// trashed files can be listed and restored until the retention period
+// (storage.trash_retention_days) runs out, after which the periodic cleanup
+// job deletes them exactly as a permanent DeleteFile would.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/config"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+// trashOwnedFile moves one of username's files to the trash and responds
+// with the time it will be purged. Its storage stays allocated, so the
+// storage figures in the response are unchanged.
+func trashOwnedFile(c echo.Context, username, fileID string, retentionDays int) error {
+	file, err := models.GetFileByFileID(database.DB, fileID)
+	if err != nil {
+		if err.Error() == "file not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Database error checking file ownership for deletion: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file")
+	}
+	if file.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Not authorized to delete this file")
+	}
+
+	if err := models.TrashFile(database.DB, fileID, username); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Failed to move file %s to trash: %v", fileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file")
+	}
+
+	database.LogUserAction(username, "moved to trash", fileID)
+	logging.InfoLogger.Printf("File moved to trash: file_id=%s", fileID)
+
+	user, err := models.GetUserByUsername(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to get user storage info: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get storage info")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "File moved to trash",
+		"trashed":  true,
+		"purge_at": time.Now().UTC().AddDate(0, 0, retentionDays),
+		"storage": map[string]interface{}{
+			"total_bytes":     user.TotalStorageBytes,
+			"limit_bytes":     user.StorageLimitBytes,
+			"available_bytes": user.StorageLimitBytes - user.TotalStorageBytes,
+		},
+	})
+}
+
+// ListTrash handles GET /api/files/trash
+// Lists the files in the user's trash, most recently deleted first, with the
+// time each one will be purged.
+func ListTrash(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+
+	files, err := models.GetTrashedFilesByOwner(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("ListTrash: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve trash")
+	}
+
+	type TrashListResponseItem struct {
+		*models.FileMetadataForClient
+		SizeReadable string    `json:"size_readable"`
+		DeletedAt    time.Time `json:"deleted_at"`
+		PurgeAt      time.Time `json:"purge_at"`
+	}
+
+	retentionDays := config.GetConfig().Storage.TrashRetentionDays
+	fileList := make([]TrashListResponseItem, 0, len(files))
+	for _, file := range files {
+		fileList = append(fileList, TrashListResponseItem{
+			FileMetadataForClient: file.ToClientMetadata(),
+			SizeReadable:          formatBytes(file.SizeBytes),
+			DeletedAt:             file.DeletedAt,
+			PurgeAt:               file.DeletedAt.AddDate(0, 0, retentionDays),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"files":          fileList,
+		"retention_days": retentionDays,
+	})
+}
+
+// RestoreFile handles POST /api/files/:fileId/restore
+// Takes a file out of the user's trash. Shares of the file work again once
+// it is restored.
+func RestoreFile(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+	fileID := c.Param("fileId")
+
+	if err := models.RestoreFile(database.DB, fileID, username); err != nil {
+		if err == sql.ErrNoRows {
+			return JSONErrorCode(c, http.StatusNotFound, "not_in_trash", "File not found in trash")
+		}
+		logging.ErrorLogger.Printf("Failed to restore file %s: %v", fileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to restore file")
+	}
+
+	database.LogUserAction(username, "restored from trash", fileID)
+	logging.InfoLogger.Printf("File restored from trash: file_id=%s", fileID)
+
+	return JSONResponse(c, http.StatusOK, "File restored", map[string]interface{}{
+		"file_id": fileID,
+	})
+}
+
+// purgeExpiredTrash permanently deletes files that have been in the trash
+// for longer than retentionDays. With a retention of 0 it empties every
+// trash, which only matters after the trash has been switched off.
+func purgeExpiredTrash(ctx context.Context, retentionDays int) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.ErrorLogger.Printf("Panic recovered in purgeExpiredTrash cleanup: %v", r)
+		}
+	}()
+
+	expired, err := models.GetExpiredTrash(database.DB, retentionDays)
+	if err != nil {
+		logging.ErrorLogger.Printf("trash purge: %v", err)
+		return
+	}
+
+	purged := 0
+	for _, ref := range expired {
+		if _, err := deleteOwnedFile(ctx, ref.OwnerUsername, ref.FileID); err != nil {
+			logging.ErrorLogger.Printf("trash purge: failed to delete file %s of %s: %v", ref.FileID, ref.OwnerUsername, err)
+			continue
+		}
+		database.LogUserAction(ref.OwnerUsername, "purged from trash", ref.FileID)
+		purged++
+	}
+	if purged > 0 {
+		logging.InfoLogger.Printf("trash purge: deleted %d file(s) older than %d day(s)", purged, retentionDays)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+func TestDeleteFile_MovesToTrashUntilRestored(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	insertTestFile(t, db, provider, "alice", "doc")
+
+	c, rec := authedContext(http.MethodDelete, "/api/files/doc", nil, "alice")
+	c.SetParamNames("fileId")
+	c.SetParamValues("doc")
+	require.NoError(t, DeleteFile(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, true, resp["trashed"])
+	assert.Equal(t, float64(100), resp["storage"].(map[string]interface{})["total_bytes"], "trashed files keep their storage")
+
+	_, err := provider.HeadObject(context.Background(), "stor-doc")
+	assert.NoError(t, err, "the object stays until the trash is purged")
+	_, err = models.GetFileByFileID(db, "doc")
+	assert.EqualError(t, err, "file not found")
+	assert.Empty(t, listFileIDs(t, ListFiles, "/api/files"))
+
+	trash := listFileIDs(t, ListTrash, "/api/files/trash")
+	require.Contains(t, trash, "doc")
+	deletedAt, err := time.Parse(time.RFC3339, trash["doc"]["deleted_at"].(string))
+	require.NoError(t, err)
+	purgeAt, err := time.Parse(time.RFC3339, trash["doc"]["purge_at"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, deletedAt.AddDate(0, 0, 30), purgeAt)
+
+	// Only the owner can restore
+	c, rec = authedContext(http.MethodPost, "/api/files/doc/restore", nil, "bob")
+	c.SetParamNames("fileId")
+	c.SetParamValues("doc")
+	require.NoError(t, RestoreFile(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = authedContext(http.MethodPost, "/api/files/doc/restore", nil, "alice")
+	c.SetParamNames("fileId")
+	c.SetParamValues("doc")
+	require.NoError(t, RestoreFile(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Contains(t, listFileIDs(t, ListFiles, "/api/files"), "doc")
+	assert.Empty(t, listFileIDs(t, ListTrash, "/api/files/trash"))
+
+	// A second restore finds nothing in the trash
+	c, rec = authedContext(http.MethodPost, "/api/files/doc/restore", nil, "alice")
+	c.SetParamNames("fileId")
+	c.SetParamValues("doc")
+	require.NoError(t, RestoreFile(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeleteFile_TrashingCurrentVersionUncoversPrevious(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertVersionedFile(t, db, provider, "alice", "doc-v1", "doc-v1", 1)
+	insertVersionedFile(t, db, provider, "alice", "doc-v2", "doc-v1", 2)
+
+	require.NoError(t, models.TrashFile(db, "doc-v2", "alice"))
+
+	current := listFileIDs(t, ListFiles, "/api/files")
+	require.Len(t, current, 1)
+	assert.Equal(t, float64(1), current["doc-v1"]["version_count"])
+
+	recent, err := models.GetRecentFileMetadataByOwner(db, "alice", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	assert.Equal(t, "doc-v1", recent[0].FileID)
+
+	// A new version is numbered after the trashed one
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	next, err := models.NextFileVersion(tx, "alice", "doc-v1")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, int64(3), next)
+}
+
+func TestDeleteFile_PermanentRemovesTrashedFile(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertTestFile(t, db, provider, "alice", "doc")
+	require.NoError(t, models.TrashFile(db, "doc", "alice"))
+
+	c, rec := authedContext(http.MethodDelete, "/api/files/doc?permanent=true", nil, "alice")
+	c.SetParamNames("fileId")
+	c.SetParamValues("doc")
+	require.NoError(t, DeleteFile(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_metadata`).Scan(&count))
+	assert.Zero(t, count)
+	_, err := provider.HeadObject(context.Background(), "stor-doc")
+	assert.Error(t, err)
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertTestFile(t, db, provider, "alice", "old")
+	insertTestFile(t, db, provider, "alice", "recent")
+	insertTestFile(t, db, provider, "alice", "live")
+	_, err := db.Exec(`UPDATE file_metadata SET deleted_at = datetime('now', '-31 days') WHERE file_id = 'old'`)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE file_metadata SET deleted_at = datetime('now', '-29 days') WHERE file_id = 'recent'`)
+	require.NoError(t, err)
+
+	purgeExpiredTrash(context.Background(), 30)
+
+	rows, err := db.Query(`SELECT file_id FROM file_metadata ORDER BY file_id`)
+	require.NoError(t, err)
+	var remaining []string
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		remaining = append(remaining, id)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"live", "recent"}, remaining)
+
+	var total int64
+	require.NoError(t, db.QueryRow(`SELECT total_storage_bytes FROM users WHERE username = 'alice'`).Scan(&total))
+	assert.Equal(t, int64(200), total, "purged files are credited back")
+	_, err = provider.HeadObject(context.Background(), "stor-old")
+	assert.Error(t, err, "purged file's object is removed")
+
+	var action string
+	require.NoError(t, db.QueryRow(`SELECT action FROM user_activity WHERE target = 'old'`).Scan(&action))
+	assert.Equal(t, "purged from trash", action)
+}
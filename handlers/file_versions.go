@@ -0,0 +1,136 @@
+// file_versions.go - Version retention for files uploaded as new versions of
+// an existing file (see models/file_version.go). Each user chooses how many
+// versions of a file to keep; when a new version pushes a chain past that
+// count, the oldest versions are deleted exactly as DeleteFile would: moved
+// to the trash while it is enabled, and deleted for good once it is purged.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/config"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+// applyVersionRetention prunes one version chain to the owner's retention
+// setting and returns the number of versions deleted. Failures are logged;
+// the chain is simply left longer than the setting until the next upload.
+func applyVersionRetention(ctx context.Context, username, versionGroup string) int {
+	keep, err := models.GetVersionRetention(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to read version retention for %s: %v", username, err)
+		return 0
+	}
+	if keep == 0 {
+		return 0
+	}
+	return pruneVersionChain(ctx, username, versionGroup, keep)
+}
+
+// pruneVersionChain deletes all but the newest keep versions of a chain.
+// Pruned versions go to the trash unless it is disabled
+// (storage.trash_retention_days = 0), so lowering the setting by mistake can
+// be undone until the trash is purged.
+func pruneVersionChain(ctx context.Context, username, versionGroup string, keep int) int {
+	versions, err := models.GetFileVersions(database.DB, username, versionGroup)
+	if err != nil {
+		logging.ErrorLogger.Printf("Version retention: %v", err)
+		return 0
+	}
+	toTrash := config.GetConfig().Storage.TrashRetentionDays > 0
+	pruned := 0
+	for _, v := range versions[min(keep, len(versions)):] {
+		if toTrash {
+			err = models.TrashFile(database.DB, v.FileID, username)
+		} else {
+			_, err = deleteOwnedFile(ctx, username, v.FileID)
+		}
+		if err != nil {
+			logging.ErrorLogger.Printf("Version retention: failed to delete version %d (file_id=%s) of %s: %v",
+				v.VersionNumber, v.FileID, versionGroup, err)
+			continue
+		}
+		if toTrash {
+			database.LogUserAction(username, "pruned version to trash", v.FileID)
+		} else {
+			database.LogUserAction(username, "pruned version", v.FileID)
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		logging.InfoLogger.Printf("Version retention: pruned %d old version(s) of %s for %s", pruned, versionGroup, username)
+	}
+	return pruned
+}
+
+// GetVersionRetention handles GET /api/user/version-retention
+// Returns how many versions of each file the user keeps (0 keeps all).
+func GetVersionRetention(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+
+	keep, err := models.GetVersionRetention(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to get version retention for %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve version retention")
+	}
+
+	return JSONResponse(c, http.StatusOK, "Version retention retrieved", map[string]interface{}{
+		"version_retention": keep,
+	})
+}
+
+// PutVersionRetention handles PUT /api/user/version-retention
+// Sets how many versions of each file the user keeps and immediately prunes
+// every chain that is now over the limit. 0 keeps all versions.
+func PutVersionRetention(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	if username == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+
+	var request struct {
+		VersionRetention *int `json:"version_retention"`
+	}
+	if err := c.Bind(&request); err != nil || request.VersionRetention == nil {
+		return JSONErrorCode(c, http.StatusBadRequest, "invalid_request", "version_retention is required")
+	}
+	keep := *request.VersionRetention
+	if keep < 0 || keep > models.MaxVersionRetention {
+		return JSONErrorCode(c, http.StatusBadRequest, "invalid_version_retention",
+			fmt.Sprintf("version_retention must be between 0 and %d", models.MaxVersionRetention))
+	}
+
+	if err := models.SetVersionRetention(database.DB, username, keep); err != nil {
+		logging.ErrorLogger.Printf("Failed to set version retention for %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save version retention")
+	}
+
+	pruned := 0
+	if keep > 0 {
+		groups, err := models.GetVersionGroupsOverRetention(database.DB, username, keep)
+		if err != nil {
+			logging.ErrorLogger.Printf("Version retention: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Version retention saved, but old versions could not be pruned")
+		}
+		for _, group := range groups {
+			pruned += pruneVersionChain(c.Request().Context(), username, group, keep)
+		}
+	}
+	database.LogUserAction(username, "set version retention", fmt.Sprintf("%d", keep))
+
+	return JSONResponse(c, http.StatusOK, "Version retention saved", map[string]interface{}{
+		"version_retention": keep,
+		"pruned_versions":   pruned,
+	})
+}
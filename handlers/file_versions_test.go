@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/config"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// insertVersionedFile stores a 100-byte file and its object. An empty group
+// leaves the file unversioned.
+func insertVersionedFile(t *testing.T, db *sql.DB, provider *storage.LocalFSStorage, owner, fileID, group string, number int) {
+	t.Helper()
+	insertTestFile(t, db, provider, owner, fileID)
+	if group != "" {
+		_, err := db.Exec(`UPDATE file_metadata SET version_group = ?, version_number = ? WHERE file_id = ?`, group, number, fileID)
+		require.NoError(t, err)
+	}
+}
+
+func TestNextFileVersion_StartsAndExtendsChain(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertVersionedFile(t, db, provider, "alice", "doc-v1", "", 1)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	next, err := models.NextFileVersion(tx, "alice", "doc-v1")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, int64(2), next)
+
+	// The root joined its own chain
+	var group string
+	require.NoError(t, db.QueryRow(`SELECT version_group FROM file_metadata WHERE file_id = 'doc-v1'`).Scan(&group))
+	assert.Equal(t, "doc-v1", group)
+
+	insertVersionedFile(t, db, provider, "alice", "doc-v2", "doc-v1", 2)
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	next, err = models.NextFileVersion(tx, "alice", "doc-v1")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, int64(3), next)
+}
+
+func TestListFiles_ShowsCurrentVersionsUnlessAllRequested(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertVersionedFile(t, db, provider, "alice", "doc-v1", "doc-v1", 1)
+	insertVersionedFile(t, db, provider, "alice", "doc-v2", "doc-v1", 2)
+	insertVersionedFile(t, db, provider, "alice", "doc-v3", "doc-v1", 3)
+	insertVersionedFile(t, db, provider, "alice", "solo", "", 1)
+
+	list := func(target string) map[string]map[string]interface{} {
+		c, rec := authedContext(http.MethodGet, target, nil, "alice")
+		require.NoError(t, ListFiles(c))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			Files   []map[string]interface{} `json:"files"`
+			Storage map[string]interface{}   `json:"storage"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, float64(400), resp.Storage["total_bytes"], "every version counts toward storage")
+		byID := make(map[string]map[string]interface{})
+		for _, f := range resp.Files {
+			byID[f["file_id"].(string)] = f
+		}
+		return byID
+	}
+
+	current := list("/api/files")
+	require.Len(t, current, 2)
+	assert.Equal(t, float64(3), current["doc-v3"]["version_number"])
+	assert.Equal(t, float64(3), current["doc-v3"]["version_count"])
+	assert.Equal(t, "doc-v1", current["doc-v3"]["version_group"])
+	assert.Equal(t, float64(1), current["solo"]["version_count"])
+	assert.NotContains(t, current["solo"], "version_group")
+
+	all := list("/api/files?versions=all")
+	assert.Len(t, all, 4)
+	assert.Equal(t, float64(3), all["doc-v1"]["version_count"])
+}
+
+func TestPutVersionRetention_PrunesOldestVersions(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	for i := 1; i <= 3; i++ {
+		insertVersionedFile(t, db, provider, "alice", fmt.Sprintf("doc-v%d", i), "doc-v1", i)
+	}
+	insertVersionedFile(t, db, provider, "alice", "solo", "", 1)
+	insertVersionedFile(t, db, provider, "bob", "bob-v1", "bob-v1", 1)
+	insertVersionedFile(t, db, provider, "bob", "bob-v2", "bob-v1", 2)
+
+	c, rec := authedContext(http.MethodPut, "/api/user/version-retention", []byte(`{"version_retention": 1}`), "alice")
+	require.NoError(t, PutVersionRetention(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Data struct {
+			VersionRetention int `json:"version_retention"`
+			PrunedVersions   int `json:"pruned_versions"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Data.VersionRetention)
+	assert.Equal(t, 2, resp.Data.PrunedVersions)
+
+	assert.Equal(t, []string{"bob-v1", "bob-v2", "doc-v3", "solo"}, versionTestFileIDs(t, db, "deleted_at IS NULL"),
+		"only alice's old versions are pruned")
+	assert.Equal(t, []string{"doc-v1", "doc-v2"}, versionTestFileIDs(t, db, "deleted_at IS NOT NULL"),
+		"pruned versions go to the trash")
+
+	var total int64
+	require.NoError(t, db.QueryRow(`SELECT total_storage_bytes FROM users WHERE username = 'alice'`).Scan(&total))
+	assert.Equal(t, int64(400), total, "trashed versions keep their storage until purged")
+
+	_, err := provider.HeadObject(context.Background(), "stor-doc-v1")
+	assert.NoError(t, err, "a trashed version's object stays until the trash is purged")
+
+	keep, err := models.GetVersionRetention(db, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, keep)
+}
+
+func TestPruneVersionChain_DeletesWhenTrashDisabled(t *testing.T) {
+	cfg := config.GetConfig()
+	originalDays := cfg.Storage.TrashRetentionDays
+	cfg.Storage.TrashRetentionDays = 0
+	t.Cleanup(func() { cfg.Storage.TrashRetentionDays = originalDays })
+
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	for i := 1; i <= 3; i++ {
+		insertVersionedFile(t, db, provider, "alice", fmt.Sprintf("doc-v%d", i), "doc-v1", i)
+	}
+
+	assert.Equal(t, 2, pruneVersionChain(context.Background(), "alice", "doc-v1", 1))
+	assert.Equal(t, []string{"doc-v3"}, versionTestFileIDs(t, db, "1 = 1"))
+
+	var total int64
+	require.NoError(t, db.QueryRow(`SELECT total_storage_bytes FROM users WHERE username = 'alice'`).Scan(&total))
+	assert.Equal(t, int64(100), total, "pruned versions are credited back")
+
+	_, err := provider.HeadObject(context.Background(), "stor-doc-v1")
+	assert.Error(t, err, "pruned version's object is removed")
+}
+
+// versionTestFileIDs returns the file_ids of the file_metadata rows matching
+// where, in order.
+func versionTestFileIDs(t *testing.T, db *sql.DB, where string) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT file_id FROM file_metadata WHERE ` + where + ` ORDER BY file_id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	return ids
+}
+
+func TestPutVersionRetention_RejectsInvalidValues(t *testing.T) {
+	setupHandlerTestDB(t)
+	for _, body := range []string{`{}`, `{"version_retention": -1}`, `{"version_retention": 1001}`} {
+		c, rec := authedContext(http.MethodPut, "/api/user/version-retention", []byte(body), "alice")
+		require.NoError(t, PutVersionRetention(c))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestApplyVersionRetention_KeepAllByDefault(t *testing.T) {
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice")
+	insertVersionedFile(t, db, provider, "alice", "doc-v1", "doc-v1", 1)
+	insertVersionedFile(t, db, provider, "alice", "doc-v2", "doc-v1", 2)
+
+	assert.Equal(t, 0, applyVersionRetention(context.Background(), "alice", "doc-v1"))
+
+	_, err := db.Exec(`UPDATE users SET version_retention = 1 WHERE username = 'alice'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applyVersionRetention(context.Background(), "alice", "doc-v1"))
+}
@@ -100,6 +100,8 @@ func GetFileMeta(c echo.Context) error {
 		"filename_nonce":        file.FilenameNonce,
 		"encrypted_sha256sum":   file.EncryptedSha256sum,
 		"sha256sum_nonce":       file.Sha256sumNonce,
+		"encrypted_folder":      file.EncryptedFolder, // empty for top-level files
+		"folder_nonce":          file.FolderNonce,
 		"encrypted_fek":         file.EncryptedFEK,
 		"password_hint":         file.PasswordHint,
 		"password_type":         file.PasswordType,
@@ -222,7 +224,9 @@ func GetFileEnvelope(c echo.Context) error {
 	})
 }
 
-// ListFiles returns a list of files owned by the user with encrypted metadata
+// ListFiles returns a list of files owned by the user with encrypted metadata.
+// Only the current version of each versioned file is listed unless the
+// request asks for ?versions=all.
 func ListFiles(c echo.Context) error {
 	username := auth.GetUsernameFromToken(c)
 	if username == "" {
@@ -253,19 +257,50 @@ func ListFiles(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve files")
 	}
 
-	// Prepare response data structure for files
+	annotations, err := models.GetFileAnnotationsByOwner(database.DB, username)
+	if err != nil {
+		logging.Log(logging.ERROR, "ListFiles: GetFileAnnotationsByOwner failed for user '%s': %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve files")
+	}
+
+	// Prepare response data structure for files. The encrypted tags and
+	// note are omitted for files that have none.
 	type FileListResponseItem struct {
 		*models.FileMetadataForClient
-		SizeReadable string `json:"size_readable"`
+		SizeReadable  string `json:"size_readable"`
+		TagsNonce     string `json:"tags_nonce,omitempty"`
+		EncryptedTags string `json:"encrypted_tags,omitempty"`
+		NoteNonce     string `json:"note_nonce,omitempty"`
+		EncryptedNote string `json:"encrypted_note,omitempty"`
 	}
 
+	// Count each version chain and find its current (highest) version
+	versionCounts := make(map[string]int)
+	currentVersions := make(map[string]int64)
+	for _, file := range files {
+		group := file.VersionGroupID()
+		versionCounts[group]++
+		currentVersions[group] = max(currentVersions[group], file.VersionNumber)
+	}
+	allVersions := c.QueryParam("versions") == "all"
+
 	var fileList []FileListResponseItem
 	for _, file := range files {
+		group := file.VersionGroupID()
+		if !allVersions && file.VersionNumber < currentVersions[group] {
+			continue
+		}
 		clientMeta := file.ToClientMetadata()
-		fileList = append(fileList, FileListResponseItem{
+		clientMeta.VersionCount = versionCounts[group]
+		item := FileListResponseItem{
 			FileMetadataForClient: clientMeta,
 			SizeReadable:          formatBytes(file.SizeBytes),
-		})
+		}
+		if a, ok := annotations[file.FileID]; ok {
+			item.TagsNonce, item.EncryptedTags = a.TagsNonce, a.EncryptedTags
+			item.NoteNonce, item.EncryptedNote = a.NoteNonce, a.EncryptedNote
+		}
+		fileList = append(fileList, item)
 	}
 
 	// Get user's storage information
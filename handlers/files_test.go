@@ -23,12 +23,23 @@ import (
 
 // Test DeleteFile
 //
-// The DeleteFile handler (handlers/uploads.go) queries 4 columns from file_metadata:
+// These tests use ?permanent=true; moving files to the trash is covered in
+// file_trash_test.go. A permanent DeleteFile (handlers/uploads.go) queries 4
+// columns from file_metadata:
 //   owner_username, storage_id, size_bytes, padded_size
-// Then queries file_storage_locations for active location records.
+// Then queries file_storage_locations for active location records, and
+// file_erasure_shards for the shards of erasure-coded files.
+// If shards exist: removes every shard object.
 // If locations exist: calls Registry.RemoveObjectAll() for multi-provider delete.
 // If no locations: falls back to Registry.Primary().RemoveObject() for pre-existing files.
 
+// expectNoErasureShards expects DeleteFile's erasure shard lookup and returns no shards.
+func expectNoErasureShards(mockDB sqlmock.Sqlmock, fileID string) {
+	mockDB.ExpectQuery(`SELECT file_id, storage_id, shard_index.* FROM file_erasure_shards`).WithArgs(fileID).WillReturnRows(
+		sqlmock.NewRows([]string{"file_id", "storage_id", "shard_index", "data_shards", "parity_shards", "stripe_unit", "object_size", "provider_id", "object_name", "sha256", "status"}),
+	)
+}
+
 // TestDeleteFile_Success_FallbackToPrimary tests successful file deletion
 // when no file_storage_locations records exist (pre-existing file or location query returns empty).
 // This exercises the fallback path: Primary().RemoveObject().
@@ -38,7 +49,7 @@ func TestDeleteFile_Success_FallbackToPrimary(t *testing.T) {
 	fileSize := int64(1024)
 	initialStorage := int64(5000)
 
-	c, rec, mockDB, mockStorage := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, rec, mockDB, mockStorage := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: username}
@@ -58,6 +69,7 @@ func TestDeleteFile_Success_FallbackToPrimary(t *testing.T) {
 	mockDB.ExpectQuery(locationSQL).WithArgs(fileID).WillReturnRows(
 		sqlmock.NewRows([]string{"id", "file_id", "provider_id", "storage_id", "status", "created_at", "verified_at"}),
 	)
+	expectNoErasureShards(mockDB, fileID)
 
 	// Fallback: delete from primary only
 	mockStorage.On("RemoveObject", mock.Anything, storageID, mock.AnythingOfType("storage.RemoveObjectOptions")).Return(nil).Once()
@@ -104,7 +116,7 @@ func TestDeleteFile_NotFound(t *testing.T) {
 	username := "user-delete"
 	fileID := "non-existent-file-123"
 
-	c, _, mockDB, _ := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, _, mockDB, _ := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: username}
@@ -134,7 +146,7 @@ func TestDeleteFile_NotOwner(t *testing.T) {
 	fileID := "someone-elses-file-456"
 	fileSize := int64(512)
 
-	c, _, mockDB, _ := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, _, mockDB, _ := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: requestingUsername}
@@ -165,7 +177,7 @@ func TestDeleteFile_StorageError(t *testing.T) {
 	fileID := "file-stor-err-789"
 	fileSize := int64(1024)
 
-	c, _, mockDB, mockStorage := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, _, mockDB, mockStorage := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: username}
@@ -184,6 +196,7 @@ func TestDeleteFile_StorageError(t *testing.T) {
 	mockDB.ExpectQuery(locationSQL).WithArgs(fileID).WillReturnRows(
 		sqlmock.NewRows([]string{"id", "file_id", "provider_id", "storage_id", "status", "created_at", "verified_at"}),
 	)
+	expectNoErasureShards(mockDB, fileID)
 
 	storageError := fmt.Errorf("simulated storage layer error")
 	mockStorage.On("RemoveObject", mock.Anything, storageID, mock.AnythingOfType("storage.RemoveObjectOptions")).Return(storageError).Once()
@@ -215,17 +228,24 @@ func TestListRecentFileMetadata(t *testing.T) {
 	// owner_username is included so the client can rebuild
 	// metadata AAD without a second round-trip.
 	query := `SELECT file_id, owner_username, password_type, filename_nonce, encrypted_filename,
-		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date
+		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date,
+		       COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\),
+		       COALESCE\(version_group, ''\), version_number
 		FROM file_metadata
-		WHERE owner_username = \?
+		WHERE owner_username = \? AND deleted_at IS NULL AND NOT EXISTS \(
+				SELECT 1 FROM file_metadata newer
+				WHERE newer.version_group = file_metadata.version_group
+				  AND newer.version_number > file_metadata.version_number
+				  AND newer.deleted_at IS NULL\)
 		ORDER BY upload_date DESC
 		LIMIT \? OFFSET \?`
 
 	rows := sqlmock.NewRows([]string{
 		"file_id", "owner_username", "password_type", "filename_nonce", "encrypted_filename",
 		"sha256sum_nonce", "encrypted_sha256sum", "size_bytes", "upload_date",
+		"folder_nonce", "encrypted_folder", "version_group", "version_number",
 	}).AddRow(
-		"file-1", username, "account", "nonce1", "encName1", "shaNonce1", "encSha1", 1024, "2024-01-01 12:00:00",
+		"file-1", username, "account", "nonce1", "encName1", "shaNonce1", "encSha1", 1024, "2024-01-01 12:00:00", "", "", "", 1,
 	)
 
 	mockDB.ExpectQuery(query).WithArgs(username, 100, 0).WillReturnRows(rows)
@@ -266,17 +286,20 @@ func TestGetFileMetadataBatch(t *testing.T) {
 	// Setup mock DB response for models.GetFileMetadataBatchByOwner.
 	// owner_username is included in the SELECT list.
 	query := `SELECT file_id, owner_username, password_type, filename_nonce, encrypted_filename,
-		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date
+		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date,
+		       COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\),
+		       COALESCE\(version_group, ''\), version_number
 		FROM file_metadata
 		WHERE owner_username = \? AND file_id IN \(\?,\?,\?\)`
 
 	rows := sqlmock.NewRows([]string{
 		"file_id", "owner_username", "password_type", "filename_nonce", "encrypted_filename",
 		"sha256sum_nonce", "encrypted_sha256sum", "size_bytes", "upload_date",
+		"folder_nonce", "encrypted_folder", "version_group", "version_number",
 	}).AddRow(
-		"file-1", username, "account", "nonce1", "encName1", "shaNonce1", "encSha1", 1024, "2024-01-01 12:00:00",
+		"file-1", username, "account", "nonce1", "encName1", "shaNonce1", "encSha1", 1024, "2024-01-01 12:00:00", "", "", "", 1,
 	).AddRow(
-		"file-2", username, "custom", "nonce2", "encName2", "shaNonce2", "encSha2", 2048, "2024-01-02 12:00:00",
+		"file-2", username, "custom", "nonce2", "encName2", "shaNonce2", "encSha2", 2048, "2024-01-02 12:00:00", "", "", "", 1,
 	)
 	// Specifically omitting file-999 to test missing logic
 
@@ -304,6 +327,12 @@ func TestGetFileMetadataBatch(t *testing.T) {
 
 // -- Priority 5: ListFiles + GetFileMeta tests --
 
+// annotationsSQL matches GetFileAnnotationsByOwner, which ListFiles runs
+// after fetching the files.
+const annotationsSQL = `SELECT file_id, COALESCE\(tags_nonce, ''\), COALESCE\(encrypted_tags, ''\), COALESCE\(note_nonce, ''\), COALESCE\(encrypted_note, ''\), updated_at FROM file_annotations WHERE owner_username = \?`
+
+var annotationColumns = []string{"file_id", "tags_nonce", "encrypted_tags", "note_nonce", "encrypted_note", "updated_at"}
+
 // TestListFiles_NoFiles tests that a user with no files gets empty list and correct storage info
 func TestListFiles_NoFiles(t *testing.T) {
 	username := "user-no-files"
@@ -317,11 +346,14 @@ func TestListFiles_NoFiles(t *testing.T) {
 	mockDB.ExpectPing()
 
 	// Mock GetFilesByOwner - returns empty result set
-	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE owner_username = \? ORDER BY upload_date DESC`
+	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE owner_username = \? AND deleted_at IS NULL ORDER BY upload_date DESC`
 	mockDB.ExpectQuery(filesSQL).WithArgs(username).WillReturnRows(
-		sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date"}),
+		sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date", "folder_nonce", "encrypted_folder", "version_group", "version_number"}),
 	)
 
+	// Mock GetFileAnnotationsByOwner - no annotations
+	mockDB.ExpectQuery(annotationsSQL).WithArgs(username).WillReturnRows(sqlmock.NewRows(annotationColumns))
+
 	// Mock GetUserByUsername for storage info
 	getUserSQL := `SELECT id, username, created_at, total_storage_bytes, storage_limit_bytes, is_approved, approved_by, approved_at, is_admin FROM users WHERE username = \?`
 	userRows := sqlmock.NewRows([]string{"id", "username", "created_at", "total_storage_bytes", "storage_limit_bytes", "is_approved", "approved_by", "approved_at", "is_admin"}).
@@ -359,12 +391,16 @@ func TestListFiles_WithFiles(t *testing.T) {
 
 	mockDB.ExpectPing()
 
-	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE owner_username = \? ORDER BY upload_date DESC`
-	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date"}).
-		AddRow(int64(1), "file-1", "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00").
-		AddRow(int64(2), "file-2", "stor-2", username, "hint", "custom", "nonce2", "encName2", "shaNonce2", "encSha2", "", "encFek2", int64(2048), nil, int64(1), int64(16777216), "2024-01-02 12:00:00")
+	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE owner_username = \? AND deleted_at IS NULL ORDER BY upload_date DESC`
+	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date", "folder_nonce", "encrypted_folder", "version_group", "version_number"}).
+		AddRow(int64(1), "file-1", "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00", "", "", "", int64(1)).
+		AddRow(int64(2), "file-2", "stor-2", username, "hint", "custom", "nonce2", "encName2", "shaNonce2", "encSha2", "", "encFek2", int64(2048), nil, int64(1), int64(16777216), "2024-01-02 12:00:00", "folderNonce2", "encFolder2", "", int64(1))
 	mockDB.ExpectQuery(filesSQL).WithArgs(username).WillReturnRows(fileRows)
 
+	mockDB.ExpectQuery(annotationsSQL).WithArgs(username).WillReturnRows(
+		sqlmock.NewRows(annotationColumns).AddRow("file-2", "tagsNonce2", "encTags2", "", "", "2024-01-03 12:00:00"),
+	)
+
 	getUserSQL := `SELECT id, username, created_at, total_storage_bytes, storage_limit_bytes, is_approved, approved_by, approved_at, is_admin FROM users WHERE username = \?`
 	userRows := sqlmock.NewRows([]string{"id", "username", "created_at", "total_storage_bytes", "storage_limit_bytes", "is_approved", "approved_by", "approved_at", "is_admin"}).
 		AddRow(int64(1), username, time.Now(), int64(3072), models.DefaultStorageLimit, true, nil, nil, false)
@@ -384,10 +420,19 @@ func TestListFiles_WithFiles(t *testing.T) {
 	file0 := files[0].(map[string]interface{})
 	assert.Equal(t, "file-1", file0["file_id"])
 	assert.Equal(t, "account", file0["password_type"])
+	assert.NotContains(t, file0, "encrypted_folder", "top-level files omit the folder fields")
+	assert.Equal(t, float64(1), file0["chunk_count"], "listing carries the chunk layout needed for chunk AAD")
+	assert.Equal(t, float64(16777216), file0["chunk_size_bytes"])
 
 	file1 := files[1].(map[string]interface{})
 	assert.Equal(t, "file-2", file1["file_id"])
 	assert.Equal(t, "custom", file1["password_type"])
+	assert.Equal(t, "encFolder2", file1["encrypted_folder"])
+	assert.Equal(t, "folderNonce2", file1["folder_nonce"])
+	assert.Equal(t, "encTags2", file1["encrypted_tags"])
+	assert.Equal(t, "tagsNonce2", file1["tags_nonce"])
+	assert.NotContains(t, file1, "encrypted_note", "files without a note omit the note fields")
+	assert.NotContains(t, file0, "encrypted_tags")
 
 	storage := resp["storage"].(map[string]interface{})
 	assert.Equal(t, float64(3072), storage["total_bytes"])
@@ -406,7 +451,7 @@ func TestListFiles_DBError(t *testing.T) {
 
 	mockDB.ExpectPing()
 
-	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE owner_username = \? ORDER BY upload_date DESC`
+	filesSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE owner_username = \? AND deleted_at IS NULL ORDER BY upload_date DESC`
 	mockDB.ExpectQuery(filesSQL).WithArgs(username).WillReturnError(fmt.Errorf("database connection lost"))
 
 	err := ListFiles(c)
@@ -431,9 +476,9 @@ func TestGetFileMeta_Success(t *testing.T) {
 	c.Set("user", token)
 
 	// Mock GetFileByFileID
-	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE file_id = \?`
-	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date"}).
-		AddRow(int64(1), fileID, "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(5000000), nil, int64(1), int64(16777216), "2024-01-01 12:00:00")
+	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE file_id = \?`
+	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date", "folder_nonce", "encrypted_folder", "version_group", "version_number"}).
+		AddRow(int64(1), fileID, "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(5000000), nil, int64(1), int64(16777216), "2024-01-01 12:00:00", "", "", "", int64(1))
 	mockDB.ExpectQuery(fileSQL).WithArgs(fileID).WillReturnRows(fileRows)
 
 	// Mock GetUserByUsername for approval check
@@ -474,9 +519,9 @@ func TestGetFileMeta_NotOwner(t *testing.T) {
 	c.Set("user", token)
 
 	// Mock GetFileByFileID - returns file owned by someone else
-	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE file_id = \?`
-	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date"}).
-		AddRow(int64(1), fileID, "stor-1", actualOwner, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00")
+	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE file_id = \?`
+	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date", "folder_nonce", "encrypted_folder", "version_group", "version_number"}).
+		AddRow(int64(1), fileID, "stor-1", actualOwner, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00", "", "", "", int64(1))
 	mockDB.ExpectQuery(fileSQL).WithArgs(fileID).WillReturnRows(fileRows)
 
 	err := GetFileMeta(c)
@@ -502,7 +547,7 @@ func TestGetFileMeta_FileNotFound(t *testing.T) {
 	c.Set("user", token)
 
 	// Mock GetFileByFileID - file not found
-	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE file_id = \?`
+	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE file_id = \?`
 	mockDB.ExpectQuery(fileSQL).WithArgs(fileID).WillReturnError(sql.ErrNoRows)
 
 	err := GetFileMeta(c)
@@ -527,9 +572,9 @@ func TestGetFileMeta_UnapprovedUser(t *testing.T) {
 	c.Set("user", token)
 
 	// Mock GetFileByFileID - file owned by requesting user
-	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date FROM file_metadata WHERE file_id = \?`
-	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date"}).
-		AddRow(int64(1), fileID, "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00")
+	fileSQL := `SELECT id, file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, COALESCE\(encrypted_file_sha256sum, ''\), encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes, upload_date, COALESCE\(folder_nonce, ''\), COALESCE\(encrypted_folder, ''\), COALESCE\(version_group, ''\), version_number FROM file_metadata WHERE file_id = \?`
+	fileRows := sqlmock.NewRows([]string{"id", "file_id", "storage_id", "owner_username", "password_hint", "password_type", "filename_nonce", "encrypted_filename", "sha256sum_nonce", "encrypted_sha256sum", "encrypted_file_sha256sum", "encrypted_fek", "size_bytes", "padded_size", "chunk_count", "chunk_size_bytes", "upload_date", "folder_nonce", "encrypted_folder", "version_group", "version_number"}).
+		AddRow(int64(1), fileID, "stor-1", username, "", "account", "nonce1", "encName1", "shaNonce1", "encSha1", "", "encFek1", int64(1024), nil, int64(1), int64(16777216), "2024-01-01 12:00:00", "", "", "", int64(1))
 	mockDB.ExpectQuery(fileSQL).WithArgs(fileID).WillReturnRows(fileRows)
 
 	// Mock GetUserByUsername - user NOT approved
@@ -588,7 +633,7 @@ func TestDeleteFile_WithMultiProviderLocations(t *testing.T) {
 	paddedSize := int64(2560)
 	initialStorage := int64(10000)
 
-	c, rec, mockDB, mockPrimary := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, rec, mockDB, mockPrimary := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: username}
@@ -617,6 +662,7 @@ func TestDeleteFile_WithMultiProviderLocations(t *testing.T) {
 		AddRow(int64(1), fileID, "mock-test", storageID, "active", "2026-01-01 00:00:00", nil).
 		AddRow(int64(2), fileID, "mock-secondary", storageID, "active", "2026-01-01 00:00:00", nil)
 	mockDB.ExpectQuery(locationSQL).WithArgs(fileID).WillReturnRows(locationRows)
+	expectNoErasureShards(mockDB, fileID)
 
 	// Mock RemoveObject on both providers (called by RemoveObjectAll)
 	mockPrimary.On("RemoveObject", mock.Anything, storageID, storage.RemoveObjectOptions{}).Return(nil).Once()
@@ -694,7 +740,7 @@ func TestDeleteFile_PartialDeleteFailure(t *testing.T) {
 	paddedSize := int64(5000)
 	initialStorage := int64(20000)
 
-	c, rec, mockDB, mockPrimary := setupTestEnv(t, http.MethodDelete, "/files/:fileId", nil)
+	c, rec, mockDB, mockPrimary := setupTestEnv(t, http.MethodDelete, "/files/:fileId?permanent=true", nil)
 	c.SetParamNames("fileId")
 	c.SetParamValues(fileID)
 	claims := &auth.Claims{Username: username}
@@ -722,6 +768,7 @@ func TestDeleteFile_PartialDeleteFailure(t *testing.T) {
 		AddRow(int64(1), fileID, "mock-test", storageID, "active", "2026-01-01 00:00:00", nil).
 		AddRow(int64(2), fileID, "mock-secondary", storageID, "active", "2026-01-01 00:00:00", nil)
 	mockDB.ExpectQuery(locationSQL).WithArgs(fileID).WillReturnRows(locationRows)
+	expectNoErasureShards(mockDB, fileID)
 
 	// Primary succeeds, secondary fails
 	mockPrimary.On("RemoveObject", mock.Anything, storageID, storage.RemoveObjectOptions{}).Return(nil).Once()
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/config"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/storage"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// Fixtures shared by the handler tests that run against the real schema.
+// Each feature's tests seed their own users and files on top of these.
+
+// setupHandlerTestDB swaps in a SQLite DB holding the full server schema and
+// a single local storage provider. The config is loaded too, since handlers
+// such as CreateFileShare read it and other tests may leave it unloaded.
+func setupHandlerTestDB(t *testing.T) (*sql.DB, *storage.LocalFSStorage) {
+	t.Helper()
+
+	_, err := config.LoadConfig()
+	require.NoError(t, err)
+
+	db := testutil.SchemaDB(t)
+
+	originalDB := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = originalDB })
+
+	provider, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	originalRegistry := storage.Registry
+	storage.Registry = storage.NewProviderRegistry(provider, "p1")
+	t.Cleanup(func() { storage.Registry = originalRegistry })
+
+	return db, provider
+}
+
+// insertTestFile stores a 100-byte unversioned file owned by owner, with
+// its object under storage ID "stor-"+fileID, and adds it to the owner's
+// storage usage.
+func insertTestFile(t *testing.T, db *sql.DB, provider *storage.LocalFSStorage, owner, fileID string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO file_metadata (file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename,
+			sha256sum_nonce, encrypted_sha256sum, encrypted_fek, size_bytes, upload_date)
+		VALUES (?, ?, ?, '', 'account', '', '', '', '', '', 100, '2026-01-01 00:00:00')`, fileID, "stor-"+fileID, owner)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE users SET total_storage_bytes = total_storage_bytes + 100 WHERE username = ?`, owner)
+	require.NoError(t, err)
+	_, err = provider.PutObject(context.Background(), "stor-"+fileID, bytes.NewReader(make([]byte, 100)), 100, storage.PutObjectOptions{})
+	require.NoError(t, err)
+}
+
+// authedContext builds a JSON request from username, as the JWT middleware
+// would leave it.
+func authedContext(method, target string, body []byte, username string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: &auth.Claims{Username: username}})
+	return c, rec
+}
+
+// httpErrorCode returns the status of an *echo.HTTPError.
+func httpErrorCode(t *testing.T, err error) int {
+	t.Helper()
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(t, ok, "expected *echo.HTTPError, got %v", err)
+	return httpErr.Code
+}
+
+// listFileIDs calls ListFiles or ListTrash as alice and returns the listed
+// files by file_id.
+func listFileIDs(t *testing.T, handler echo.HandlerFunc, target string) map[string]map[string]interface{} {
+	t.Helper()
+	c, rec := authedContext(http.MethodGet, target, nil, "alice")
+	require.NoError(t, handler(c))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Files []map[string]interface{} `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	byID := make(map[string]map[string]interface{})
+	for _, f := range resp.Files {
+		byID[f["file_id"].(string)] = f
+	}
+	return byID
+}
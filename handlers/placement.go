@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// placementProviders returns the providers of policy that are configured on
+// this server and not disabled in storage_providers, in policy order.
+func placementProviders(policy *models.PlacementPolicy) []string {
+	var ids []string
+	for _, id := range policy.ProviderIDs {
+		if storage.Registry.GetProvider(id) == nil {
+			continue
+		}
+		if record, err := models.GetStorageProviderByID(database.DB, id); err == nil && !record.IsActive {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// resolveUploadPlacement returns the user's placement policy (nil if none),
+// the provider a new upload should land on, and the candidate providers for
+// its replicas. Users without a policy land on the primary and replicate under
+// the server-wide write policy. It fails when the policy cannot be satisfied
+// by the currently available providers, rather than falling back to providers
+// outside the policy.
+func resolveUploadPlacement(username string) (*models.PlacementPolicy, string, []string, error) {
+	policy, err := models.GetUserPlacementPolicy(database.DB, username)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to load placement policy: %w", err)
+	}
+	if policy == nil {
+		return nil, storage.Registry.PrimaryID(), storage.Registry.ConfiguredProviderIDs(), nil
+	}
+
+	available := placementProviders(policy)
+	if len(available) < policy.Copies {
+		return policy, "", nil, fmt.Errorf("placement policy %s needs %d provider(s) but only %d are available",
+			policy.Name, policy.Copies, len(available))
+	}
+	return policy, available[0], available, nil
+}
+
+// uploadSessionProvider returns the provider holding an upload session's
+// multipart upload. Sessions created before placement policies have no
+// provider_id and always used the primary.
+func uploadSessionProvider(providerID string) (string, storage.ObjectStorageProvider) {
+	if providerID == "" {
+		return storage.Registry.PrimaryID(), storage.Registry.Primary()
+	}
+	return providerID, storage.Registry.GetProvider(providerID)
+}
+
+// placementLookup caches the placement policy of each file owner for the
+// duration of one background task.
+type placementLookup map[string]*models.PlacementPolicy
+
+// policyFor returns the owner's placement policy, or nil if they have none.
+func (l placementLookup) policyFor(username string) (*models.PlacementPolicy, error) {
+	if policy, ok := l[username]; ok {
+		return policy, nil
+	}
+	policy, err := models.GetUserPlacementPolicy(database.DB, username)
+	if err != nil {
+		return nil, err
+	}
+	l[username] = policy
+	return policy, nil
+}
+
+// allows reports whether the owner's placement policy permits a copy on
+// providerID. Lookup failures deny the copy, so residency rules are never
+// bypassed because of a transient database error.
+func (l placementLookup) allows(username, providerID string) bool {
+	policy, err := l.policyFor(username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Placement: failed to load policy for %s: %v", username, err)
+		return false
+	}
+	return policy == nil || policy.Allows(providerID)
+}
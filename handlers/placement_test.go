@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// assignTestPlacementPolicy creates a placement policy and assigns it to username.
+func assignTestPlacementPolicy(t *testing.T, username, name string, copies int, providerIDs ...string) {
+	t.Helper()
+	require.NoError(t, models.UpsertPlacementPolicy(database.DB, &models.PlacementPolicy{
+		Name: name, ProviderIDs: providerIDs, Copies: copies,
+	}))
+	_, err := database.DB.Exec("INSERT OR IGNORE INTO users (username) VALUES (?)", username)
+	require.NoError(t, err)
+	require.NoError(t, models.SetUserPlacementPolicy(database.DB, username, name))
+}
+
+func TestResolveUploadPlacement(t *testing.T) {
+	setupRepairTest(t)
+
+	// No policy: land on the primary, replicate per the write policy
+	policy, landing, candidates, err := resolveUploadPlacement("bob")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+	assert.Equal(t, "p1", landing)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, candidates)
+
+	// Policy order decides the landing provider; unconfigured providers are skipped
+	assignTestPlacementPolicy(t, "alice", "eu", 2, "gone", "p3", "p2")
+	policy, landing, candidates, err = resolveUploadPlacement("alice")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, "p3", landing)
+	assert.Equal(t, []string{"p3", "p2"}, candidates)
+
+	// A disabled provider leaves too few to meet the copy count: refuse
+	_, err = database.DB.Exec("UPDATE storage_providers SET is_active = false WHERE provider_id = 'p2'")
+	require.NoError(t, err)
+	_, _, _, err = resolveUploadPlacement("alice")
+	assert.Error(t, err)
+}
+
+func TestRepairFile_FollowsPlacementPolicy(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("blob")
+	assignTestPlacementPolicy(t, "alice", "eu", 2, "p3", "p2")
+
+	// The only copy is on p1, which the policy does not allow
+	addRepairTestFile(t, "f1", data, "p1")
+	putRepairTestObject(t, providers[0], "blob-f1", data)
+
+	// A task target of 1 is met by p1, but the policy needs two copies on its providers
+	items, err := buildRepairList("f1", 1, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 2, items[0].copyTarget(1))
+
+	outcome := repairFile(context.Background(), items[0], items[0].copyTarget(1), "")
+	require.NoError(t, outcome.err)
+	assert.Equal(t, 2, outcome.copiesCreated)
+
+	for _, id := range []string{"p3", "p2"} {
+		status, verified := repairTestLocationStatus(t, "f1", id)
+		assert.Equal(t, "active", status, id)
+		assert.True(t, verified, id)
+	}
+
+	items, err = buildRepairList("f1", 1, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestRunCopyTask_SkipsDestinationOutsidePolicy(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("blob")
+	assignTestPlacementPolicy(t, "alice", "eu", 1, "p3")
+
+	addRepairTestFile(t, "resident", data, "p3")
+	putRepairTestObject(t, providers[2], "blob-resident", data)
+	addRepairTestFile(t, "free", data, "p3")
+	putRepairTestObject(t, providers[2], "blob-free", data)
+	_, err := database.DB.Exec("UPDATE file_metadata SET owner_username = 'bob' WHERE file_id = 'free'")
+	require.NoError(t, err)
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := CopyTaskRequest{TaskType: "copy-all", AdminUsername: "admin", SourceID: "p3", DestID: "p1", Verify: true}
+	files, err := buildAllFileCopyList()
+	require.NoError(t, err)
+	taskID, err := models.CreateAdminTask(database.DB, req.TaskType, req.AdminUsername, len(files))
+	require.NoError(t, err)
+
+	tr.runCopyTask(context.Background(), taskID, req, providers[2], providers[0], files)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	var details CopyTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesCopied)
+	assert.Equal(t, 1, details.FilesNotAllowed)
+
+	_, err = providers[0].HeadObject(context.Background(), "blob-resident")
+	assert.Error(t, err, "a resident file must not leave its policy's providers")
+	status, _ := repairTestLocationStatus(t, "free", "p1")
+	assert.Equal(t, "active", status)
+}
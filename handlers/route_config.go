@@ -146,8 +146,24 @@ func RegisterRoutes() {
 	mfaProtectedGroup.GET("/api/files/metadata", ListRecentFileMetadata)
 	mfaProtectedGroup.POST("/api/files/metadata/batch", GetFileMetadataBatch)
 	mfaProtectedGroup.GET("/api/files/:fileId/meta", GetFileMeta)
+	mfaProtectedGroup.PATCH("/api/files/:fileId", UpdateFile)
 	mfaProtectedGroup.DELETE("/api/files/:fileId", DeleteFile)
 
+	// Trash - deleted files stay restorable for storage.trash_retention_days
+	mfaProtectedGroup.GET("/api/files/trash", ListTrash)
+	mfaProtectedGroup.POST("/api/files/:fileId/restore", RestoreFile)
+
+	// Encrypted search index - clients store and query blind-index tokens
+	mfaProtectedGroup.POST("/api/files/search", SearchFiles)
+	mfaProtectedGroup.PUT("/api/files/:fileId/search-tokens", PutFileSearchTokens)
+
+	// Annotations - client-encrypted tags and note per file
+	mfaProtectedGroup.PATCH("/api/files/:fileId/annotations", UpdateFileAnnotations)
+
+	// File versions - per-user retention count for version chains
+	mfaProtectedGroup.GET("/api/user/version-retention", GetVersionRetention)
+	mfaProtectedGroup.PUT("/api/user/version-retention", PutVersionRetention)
+
 	// Chunked downloads - require MFA
 	mfaProtectedGroup.GET("/api/files/:fileId/chunks/:chunkIndex", DownloadFileChunk)
 
@@ -162,7 +178,9 @@ func RegisterRoutes() {
 	mfaProtectedGroup.GET("/api/files/:fileId/envelope", GetFileEnvelope) // Get file envelope for share creation
 	mfaProtectedGroup.POST("/api/shares", CreateFileShare)                // Create anonymous share (file_id in body)
 	mfaProtectedGroup.GET("/api/shares", ListShares)                      // List user's shares
+	mfaProtectedGroup.PATCH("/api/shares/:id", UpdateShare)               // Change a share's expiry, limit or suspension
 	mfaProtectedGroup.POST("/api/shares/:id/revoke", RevokeShare)         // Revoke a share
+	mfaProtectedGroup.GET("/api/shares/:id/accesses", ListShareAccesses)  // Download history of a share
 
 	// Anonymous share access (no authentication required) - separate namespace with rate limiting
 	// Using /api/public/shares to avoid conflicts with authenticated /api/shares routes
@@ -180,6 +198,30 @@ func RegisterRoutes() {
 	publicShareGroup.GET("/:id/metadata", GetShareDownloadMetadata)     // Get metadata for shared file download
 	publicShareGroup.GET("/:id/chunks/:chunkIndex", DownloadShareChunk) // Download chunk of shared file
 
+	// File drops - owner endpoints (require MFA)
+	mfaProtectedGroup.POST("/api/drops", CreateFileDrop)
+	mfaProtectedGroup.GET("/api/drops", ListFileDrops)
+	mfaProtectedGroup.GET("/api/drops/:id", GetFileDrop)
+	mfaProtectedGroup.POST("/api/drops/:id/revoke", RevokeFileDrop)
+
+	// Direct user-to-user shares - FEKs sealed to the recipient's public key
+	mfaProtectedGroup.PUT("/api/user/encryption-key", PutUserEncryptionKey)
+	mfaProtectedGroup.GET("/api/user/encryption-key", GetUserEncryptionKey)
+	mfaProtectedGroup.GET("/api/users/:username/public-key", GetUserPublicKey)
+	mfaProtectedGroup.POST("/api/user-shares", CreateUserShare)
+	mfaProtectedGroup.GET("/api/user-shares", ListUserShares)
+	mfaProtectedGroup.DELETE("/api/user-shares/:id", DeleteUserShare)
+	mfaProtectedGroup.GET("/api/shared-with-me", ListSharedWithMe)
+
+	// Anonymous uploads through a file drop, guarded like public shares
+	publicDropGroup := Echo.Group("/api/public/drops")
+	publicDropGroup.Use(ShareEnumerationMiddleware)
+	publicDropGroup.Use(ShareRateLimitMiddleware)
+	publicDropGroup.GET("/:id", GetPublicFileDrop)
+	publicDropGroup.POST("/:id/uploads/init", InitDropUpload)
+	publicDropGroup.POST("/:id/uploads/:sessionId/chunks/:chunkNumber", UploadDropChunk)
+	publicDropGroup.POST("/:id/uploads/:sessionId/complete", CompleteDropUpload)
+
 	// File export token - requires TOTP (creates short-lived download token)
 	mfaProtectedGroup.POST("/api/files/:fileId/export-token", CreateExportToken)
 
@@ -244,6 +286,7 @@ func RegisterRoutes() {
 	adminGroup.POST("/users/:username/force-logout", AdminForceLogout)
 	adminGroup.POST("/users/:username/reset-mfa", AdminResetUserMFA)
 	adminGroup.GET("/users/:username/mfa-credentials", AdminListUserMFACredentials)
+	adminGroup.PUT("/users/:username/placement-policy", AdminSetUserPlacementPolicy)
 
 	// OPAQUE credential rotation: flag account(s) for one-time re-registration.
 	// The all-users route is registered before the parameterized route so it is
@@ -293,6 +336,13 @@ func RegisterRoutes() {
 	adminGroup.POST("/storage/verify-storage", AdminVerifyStorage)
 	adminGroup.POST("/storage/set-cost", AdminSetCost)
 	adminGroup.POST("/storage/verify-all", AdminVerifyAll)
+	adminGroup.POST("/storage/repair", AdminRepairStorage)
+	adminGroup.POST("/storage/drain-provider", AdminDrainProvider)
+	adminGroup.POST("/storage/tier", AdminTierStorage)
+	adminGroup.GET("/storage/tiering-report", AdminTieringReport)
+	adminGroup.GET("/storage/placement-policies", AdminListPlacementPolicies)
+	adminGroup.POST("/storage/placement-policies", AdminSetPlacementPolicy)
+	adminGroup.DELETE("/storage/placement-policies/:name", AdminDeletePlacementPolicy)
 	adminGroup.GET("/alerts/summary", AdminAlertsSummary)
 
 	// Billing - admin endpoints (storage credits / usage metering).
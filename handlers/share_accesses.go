@@ -0,0 +1,221 @@
+// share_accesses.go - Per-share download history for share owners.
+//
+// file_share_keys.access_count only says how many downloads started. Each
+// download of a shared file is also recorded in share_accesses with the
+// privacy-preserving entity ID of the requester, the chunks that were served
+// in full and whether every chunk was delivered, so an owner can confirm a
+// recipient actually received the file. Raw IP addresses are never stored.
+
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+)
+
+// shareAccess is one download of a shared file as reported to the owner.
+type shareAccess struct {
+	AccessID     int64      `json:"access_id"`
+	FileID       string     `json:"file_id"`
+	EntityID     string     `json:"entity_id"`
+	StartedAt    string     `json:"started_at"`
+	LastChunkAt  *string    `json:"last_chunk_at"`
+	CompletedAt  *string    `json:"completed_at"`
+	Completed    bool       `json:"completed"`
+	ChunkCount   int64      `json:"chunk_count"`
+	ChunksServed int64      `json:"chunks_served"`
+	ChunkRanges  [][2]int64 `json:"chunk_ranges"`
+}
+
+// shareAccessForChunk returns the share_accesses row a chunk request belongs
+// to. Chunk 0 starts a new download; later chunks continue the latest download
+// of the same file by the same entity, or start one if there is none (for
+// example when the entity ID rotated mid-download).
+func shareAccessForChunk(shareID, fileID, entityID string, chunkIndex, chunkCount int64) (int64, error) {
+	if chunkIndex > 0 {
+		var accessID float64 // rqlite returns numbers as float64
+		err := database.DB.QueryRow(`
+			SELECT id FROM share_accesses
+			WHERE share_id = ? AND file_id = ? AND entity_id = ?
+			ORDER BY id DESC LIMIT 1
+		`, shareID, fileID, entityID).Scan(&accessID)
+		if err == nil {
+			return int64(accessID), nil
+		} else if err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to look up share access: %w", err)
+		}
+	}
+
+	result, err := database.DB.Exec(`
+		INSERT INTO share_accesses (share_id, file_id, entity_id, chunk_count)
+		VALUES (?, ?, ?, ?)
+	`, shareID, fileID, entityID, chunkCount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record share access: %w", err)
+	}
+	accessID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read share access id: %w", err)
+	}
+	return accessID, nil
+}
+
+// recordShareAccessChunk notes that chunkIndex of an access was served in
+// full, and marks the access completed once every chunk has been served.
+func recordShareAccessChunk(accessID, chunkIndex int64) error {
+	if _, err := database.DB.Exec(
+		`INSERT OR IGNORE INTO share_access_chunks (access_id, chunk_index) VALUES (?, ?)`,
+		accessID, chunkIndex,
+	); err != nil {
+		return fmt.Errorf("failed to record share access chunk: %w", err)
+	}
+	if _, err := database.DB.Exec(`
+		UPDATE share_accesses
+		SET last_chunk_at = CURRENT_TIMESTAMP,
+		    completed_at = CASE
+		        WHEN completed_at IS NULL
+		         AND (SELECT COUNT(*) FROM share_access_chunks WHERE access_id = ?) >= chunk_count
+		        THEN CURRENT_TIMESTAMP ELSE completed_at END
+		WHERE id = ?
+	`, accessID, accessID); err != nil {
+		return fmt.Errorf("failed to update share access: %w", err)
+	}
+	return nil
+}
+
+// ListShareAccesses returns the download history of one of the user's shares,
+// newest first.
+// GET /api/shares/:id/accesses
+func ListShareAccesses(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	shareID := c.Param("id")
+	limit, offset, err := parseLimitOffset(c, defaultMetadataPageLimit, maxMetadataPageLimit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var ownerUsername string
+	var accessCount sql.NullFloat64
+	err = database.DB.QueryRow(
+		"SELECT owner_username, access_count FROM file_share_keys WHERE share_id = ?",
+		shareID,
+	).Scan(&ownerUsername, &accessCount)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Share not found")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Database error checking share ownership: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+	}
+	if ownerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT sa.id, sa.file_id, sa.entity_id, sa.chunk_count, sa.started_at,
+		       sa.last_chunk_at, sa.completed_at,
+		       (SELECT COUNT(*) FROM share_access_chunks sc WHERE sc.access_id = sa.id)
+		FROM share_accesses sa
+		WHERE sa.share_id = ?
+		ORDER BY sa.id DESC
+		LIMIT ? OFFSET ?
+	`, shareID, limit, offset)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query share accesses: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve share accesses")
+	}
+	defer rows.Close()
+
+	accesses := []*shareAccess{}
+	for rows.Next() {
+		var (
+			access                   shareAccess
+			id, chunkCount, served   float64
+			lastChunkAt, completedAt sql.NullString
+		)
+		if err := rows.Scan(&id, &access.FileID, &access.EntityID, &chunkCount, &access.StartedAt,
+			&lastChunkAt, &completedAt, &served); err != nil {
+			logging.ErrorLogger.Printf("Error scanning share access row: %v", err)
+			continue
+		}
+		access.AccessID = int64(id)
+		access.ChunkCount = int64(chunkCount)
+		access.ChunksServed = int64(served)
+		if lastChunkAt.Valid {
+			access.LastChunkAt = &lastChunkAt.String
+		}
+		if completedAt.Valid {
+			access.CompletedAt = &completedAt.String
+			access.Completed = true
+		}
+		access.ChunkRanges = [][2]int64{}
+		accesses = append(accesses, &access)
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Failed to read share accesses: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve share accesses")
+	}
+
+	if err := loadShareAccessRanges(accesses); err != nil {
+		logging.ErrorLogger.Printf("Failed to load share access chunks: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve share accesses")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"share_id":     shareID,
+		"access_count": int64(accessCount.Float64),
+		"accesses":     accesses,
+		"limit":        limit,
+		"offset":       offset,
+		"returned":     len(accesses),
+		"has_more":     len(accesses) == limit,
+	})
+}
+
+// loadShareAccessRanges fills in the served chunk ranges of each access as
+// inclusive [first, last] pairs.
+func loadShareAccessRanges(accesses []*shareAccess) error {
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*shareAccess, len(accesses))
+	args := make([]interface{}, 0, len(accesses))
+	for _, access := range accesses {
+		byID[access.AccessID] = access
+		args = append(args, access.AccessID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(accesses)), ",")
+	rows, err := database.DB.Query(
+		`SELECT access_id, chunk_index FROM share_access_chunks
+		 WHERE access_id IN (`+placeholders+`)
+		 ORDER BY access_id, chunk_index`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query share access chunks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accessIDF, chunkIndexF float64
+		if err := rows.Scan(&accessIDF, &chunkIndexF); err != nil {
+			return fmt.Errorf("failed to scan share access chunk: %w", err)
+		}
+		access := byID[int64(accessIDF)]
+		chunkIndex := int64(chunkIndexF)
+		if n := len(access.ChunkRanges); n > 0 && access.ChunkRanges[n-1][1]+1 == chunkIndex {
+			access.ChunkRanges[n-1][1] = chunkIndex
+		} else {
+			access.ChunkRanges = append(access.ChunkRanges, [2]int64{chunkIndex, chunkIndex})
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// setupShareAccessTest seeds alice's photo, split into two 50-byte chunks,
+// and bob.
+func setupShareAccessTest(t *testing.T) *sql.DB {
+	t.Helper()
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	insertTestFile(t, db, provider, "alice", "photo")
+	_, err := db.Exec(`UPDATE file_metadata SET chunk_count = 2, chunk_size_bytes = 50 WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	return db
+}
+
+// downloadShareChunkAt fetches one chunk of the test share and returns the
+// status code.
+func downloadShareChunkAt(t *testing.T, chunkIndex int) int {
+	t.Helper()
+	index := fmt.Sprint(chunkIndex)
+	c, rec := publicShareContext("/api/public/shares/"+testShareID+"/chunks/"+index,
+		[]string{"id", "chunkIndex"}, []string{testShareID, index})
+	if err := DownloadShareChunk(c); err != nil {
+		return httpErrorCode(t, err)
+	}
+	return rec.Code
+}
+
+// listShareAccesses calls ListShareAccesses for the test share.
+func listShareAccesses(t *testing.T, username string) (int, []shareAccess) {
+	t.Helper()
+	c, rec := authedContext(http.MethodGet, "/api/shares/"+testShareID+"/accesses", nil, username)
+	c.SetParamNames("id")
+	c.SetParamValues(testShareID)
+	if err := ListShareAccesses(c); err != nil {
+		return httpErrorCode(t, err), nil
+	}
+	var response struct {
+		AccessCount int           `json:"access_count"`
+		Accesses    []shareAccess `json:"accesses"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, len(response.Accesses), response.AccessCount)
+	return rec.Code, response.Accesses
+}
+
+func TestShareAccesses_RecordsDownloads(t *testing.T) {
+	setupShareAccessTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{"file_id": "photo"})
+	require.Equal(t, http.StatusOK, status)
+
+	status, accesses := listShareAccesses(t, "alice")
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, accesses)
+
+	// A full download, then one that stops after the first chunk
+	require.Equal(t, http.StatusOK, downloadShareChunkAt(t, 0))
+	require.Equal(t, http.StatusOK, downloadShareChunkAt(t, 1))
+	require.Equal(t, http.StatusOK, downloadShareChunkAt(t, 1), "repeated chunks are counted once")
+	require.Equal(t, http.StatusOK, downloadShareChunkAt(t, 0))
+
+	_, accesses = listShareAccesses(t, "alice")
+	require.Len(t, accesses, 2)
+	partial, full := accesses[0], accesses[1]
+
+	assert.Equal(t, "photo", full.FileID)
+	assert.NotEmpty(t, full.EntityID)
+	assert.True(t, full.Completed)
+	assert.NotNil(t, full.CompletedAt)
+	assert.Equal(t, int64(2), full.ChunkCount)
+	assert.Equal(t, int64(2), full.ChunksServed)
+	assert.Equal(t, [][2]int64{{0, 1}}, full.ChunkRanges)
+
+	assert.False(t, partial.Completed)
+	assert.Nil(t, partial.CompletedAt)
+	assert.NotNil(t, partial.LastChunkAt)
+	assert.Equal(t, int64(1), partial.ChunksServed)
+	assert.Equal(t, [][2]int64{{0, 0}}, partial.ChunkRanges)
+}
+
+func TestShareAccesses_RejectedDownloadsAreNotLogged(t *testing.T) {
+	db := setupShareAccessTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{"file_id": "photo", "max_accesses": 1})
+	require.Equal(t, http.StatusOK, status)
+
+	require.Equal(t, http.StatusOK, downloadShareChunkAt(t, 0))
+	assert.Equal(t, http.StatusForbidden, downloadShareChunkAt(t, 0), "limit reached")
+	assert.Equal(t, http.StatusBadRequest, downloadShareChunkAt(t, 2), "out of range")
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM share_accesses`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestShareAccesses_OwnerOnly(t *testing.T) {
+	setupShareAccessTest(t)
+
+	status, _ := listShareAccesses(t, "alice")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = createShare(t, "alice", map[string]interface{}{"file_id": "photo"})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = listShareAccesses(t, "bob")
+	assert.Equal(t, http.StatusForbidden, status)
+}
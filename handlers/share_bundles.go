@@ -0,0 +1,238 @@
+// share_bundles.go - Share bundles: one share link, password and Share
+// Envelope covering several files (for example a folder).
+//
+// A bundle is an ordinary file_share_keys row whose file_id is the first
+// file of the bundle; the other members live in file_share_bundle_files.
+// The encrypted envelope lists every member's FEK, so the server still never
+// sees a key. Public requests for a bundle name the member they want with
+// ?file_id=, and max_accesses is enforced per member: the share counts as
+// exhausted once every file has been downloaded that many times.
+
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+)
+
+// maxShareBundleFiles caps the number of files in one share bundle.
+const maxShareBundleFiles = 100
+
+// shareBundleFile is a member of a bundle as reported to share recipients.
+type shareBundleFile struct {
+	FileID    string `json:"file_id"`
+	SizeBytes int64  `json:"size_bytes"`
+	Available bool   `json:"available"`
+}
+
+// validateShareBundleFiles checks the file_ids of a bundle request: between
+// two and maxShareBundleFiles distinct IDs, each an untrashed file owned by
+// username. The returned error is safe to show the client.
+func validateShareBundleFiles(username string, fileIDs []string) *echo.HTTPError {
+	if len(fileIDs) < 2 {
+		return echo.NewHTTPError(http.StatusBadRequest, "A share bundle needs at least two files")
+	}
+	if len(fileIDs) > maxShareBundleFiles {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("A share bundle can hold at most %d files", maxShareBundleFiles))
+	}
+
+	seen := make(map[string]bool, len(fileIDs))
+	for _, fileID := range fileIDs {
+		if fileID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "File ID is required")
+		}
+		if seen[fileID] {
+			return echo.NewHTTPError(http.StatusBadRequest, "Duplicate file ID in share bundle")
+		}
+		seen[fileID] = true
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(fileIDs)), ",")
+	args := make([]interface{}, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		args = append(args, fileID)
+	}
+	rows, err := database.DB.Query(
+		`SELECT file_id, owner_username FROM file_metadata
+		 WHERE file_id IN (`+placeholders+`) AND deleted_at IS NULL`,
+		args...,
+	)
+	if err != nil {
+		logging.ErrorLogger.Printf("Database error checking share bundle files: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check file ownership")
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		var fileID, owner string
+		if err := rows.Scan(&fileID, &owner); err != nil {
+			logging.ErrorLogger.Printf("Error scanning share bundle file: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check file ownership")
+		}
+		if owner != username {
+			return echo.NewHTTPError(http.StatusForbidden, "Not authorized to share this file")
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Error iterating share bundle files: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check file ownership")
+	}
+	if found != len(fileIDs) {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+	return nil
+}
+
+// insertShareBundleFiles records the members of a bundle in share order.
+func insertShareBundleFiles(tx *sql.Tx, shareID string, fileIDs []string) error {
+	for i, fileID := range fileIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO file_share_bundle_files (share_id, file_id, position) VALUES (?, ?, ?)`,
+			shareID, fileID, i,
+		); err != nil {
+			return fmt.Errorf("failed to add file %s to share bundle: %w", fileID, err)
+		}
+	}
+	return nil
+}
+
+// resolveShareFile returns the file a public share request targets. For a
+// single-file share that is primaryFileID, and requested may only name it.
+// For a bundle, requested (the ?file_id= query parameter) is required and
+// must be a member. isBundle reports which kind of share it is.
+func resolveShareFile(shareID, primaryFileID, requested string) (fileID string, isBundle bool, httpErr *echo.HTTPError) {
+	// Note: rqlite returns numbers as float64, so we scan into float64 and convert
+	var members, matches float64
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN file_id = ? THEN 1 ELSE 0 END), 0)
+		FROM file_share_bundle_files
+		WHERE share_id = ?
+	`, requested, shareID).Scan(&members, &matches)
+	if err != nil {
+		logging.ErrorLogger.Printf("Database error resolving share bundle %s: %v", shareID, err)
+		return "", false, echo.NewHTTPError(http.StatusInternalServerError, "Failed to process request")
+	}
+
+	if members == 0 {
+		if requested != "" && requested != primaryFileID {
+			return "", false, echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+		}
+		return primaryFileID, false, nil
+	}
+	if requested == "" {
+		return "", true, echo.NewHTTPError(http.StatusBadRequest, "This share contains several files; file_id is required")
+	}
+	if matches == 0 {
+		return "", true, echo.NewHTTPError(http.StatusNotFound, "Shared file not found")
+	}
+	return requested, true, nil
+}
+
+// listShareBundleFiles returns the members of a share in share order, or
+// nil for a single-file share. Members in the trash are reported as not
+// available; they can be downloaded again if the owner restores them.
+func listShareBundleFiles(shareID string) ([]shareBundleFile, error) {
+	rows, err := database.DB.Query(`
+		SELECT bf.file_id, fm.size_bytes, CASE WHEN fm.deleted_at IS NULL THEN 1 ELSE 0 END
+		FROM file_share_bundle_files bf
+		JOIN file_metadata fm ON fm.file_id = bf.file_id
+		WHERE bf.share_id = ?
+		ORDER BY bf.position
+	`, shareID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share bundle files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []shareBundleFile
+	for rows.Next() {
+		var file shareBundleFile
+		var sizeF sql.NullFloat64 // rqlite returns numbers as float64
+		var availableF float64
+		if err := rows.Scan(&file.FileID, &sizeF, &availableF); err != nil {
+			return nil, fmt.Errorf("failed to scan share bundle file: %w", err)
+		}
+		if sizeF.Valid {
+			file.SizeBytes = int64(sizeF.Float64)
+		}
+		file.Available = availableF == 1
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// countShareBundleAccess records a new download of one bundle member. The
+// increment is conditional on the member being under the share's
+// max_accesses, so concurrent downloads cannot overspend it; the share's own
+// access_count is then set to the lowest member count. Returns false when
+// the member's limit is already reached.
+func countShareBundleAccess(shareID, fileID string) (bool, error) {
+	result, err := database.DB.Exec(`
+		UPDATE file_share_bundle_files
+		SET access_count = access_count + 1
+		WHERE share_id = ? AND file_id = ?
+		  AND access_count < COALESCE((SELECT max_accesses FROM file_share_keys WHERE share_id = ?), access_count + 1)
+	`, shareID, fileID, shareID)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment bundle access count: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check bundle access increment: %w", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if _, err := database.DB.Exec(`
+		UPDATE file_share_keys
+		SET access_count = (SELECT MIN(access_count) FROM file_share_bundle_files WHERE share_id = ?)
+		WHERE share_id = ?
+	`, shareID, shareID); err != nil {
+		return true, fmt.Errorf("failed to update share access count: %w", err)
+	}
+	return true, nil
+}
+
+// listShareBundleFileIDs returns the member file IDs of the given bundles,
+// keyed by share ID, in share order.
+func listShareBundleFileIDs(shareIDs []string) (map[string][]string, error) {
+	result := make(map[string][]string, len(shareIDs))
+	if len(shareIDs) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(shareIDs)), ",")
+	args := make([]interface{}, 0, len(shareIDs))
+	for _, shareID := range shareIDs {
+		args = append(args, shareID)
+	}
+	rows, err := database.DB.Query(
+		`SELECT share_id, file_id FROM file_share_bundle_files
+		 WHERE share_id IN (`+placeholders+`)
+		 ORDER BY share_id, position`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share bundle files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shareID, fileID string
+		if err := rows.Scan(&shareID, &fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan share bundle file: %w", err)
+		}
+		result[shareID] = append(result[shareID], fileID)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// testBundleToken is the base64 Download Token of the bundles created below.
+const testBundleToken = "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI="
+
+// setupShareBundleTest seeds alice and bob for share tests; alice owns
+// tax-2024, tax-2025 and photo and bob owns bob-tax.
+func setupShareBundleTest(t *testing.T) *sql.DB {
+	t.Helper()
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob")
+	for _, id := range []string{"tax-2024", "tax-2025", "photo"} {
+		insertTestFile(t, db, provider, "alice", id)
+	}
+	insertTestFile(t, db, provider, "bob", "bob-tax")
+	return db
+}
+
+// createShare calls CreateFileShare with the given file fields merged into a
+// valid request and returns the status code and decoded response.
+func createShare(t *testing.T, username string, fields map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	tokenHash, err := hashDownloadToken(testBundleToken)
+	require.NoError(t, err)
+	body := map[string]interface{}{
+		"share_id":            testShareID,
+		"salt":                "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI=",
+		"encrypted_envelope":  "ZW5jcnlwdGVkLWVudmVsb3BlLWRhdGE=",
+		"download_token_hash": tokenHash,
+	}
+	for k, v := range fields {
+		body[k] = v
+	}
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	c, rec := authedContext(http.MethodPost, "/api/shares", raw, username)
+	if err := CreateFileShare(c); err != nil {
+		return httpErrorCode(t, err), nil
+	}
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+// publicShareContext builds an anonymous request for a public share route.
+func publicShareContext(target string, names, values []string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("X-Download-Token", testBundleToken)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c, rec
+}
+
+// downloadBundleChunk fetches chunk 0 of a bundle member and returns the status code.
+func downloadBundleChunk(t *testing.T, fileID string) int {
+	t.Helper()
+	target := "/api/public/shares/" + testShareID + "/chunks/0"
+	if fileID != "" {
+		target += "?file_id=" + fileID
+	}
+	c, rec := publicShareContext(target, []string{"id", "chunkIndex"}, []string{testShareID, "0"})
+	if err := DownloadShareChunk(c); err != nil {
+		return httpErrorCode(t, err)
+	}
+	return rec.Code
+}
+
+func TestCreateFileShare_Bundle(t *testing.T) {
+	db := setupShareBundleTest(t)
+
+	status, response := createShare(t, "alice", map[string]interface{}{
+		"file_ids": []string{"tax-2025", "tax-2024", "photo"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(3), response["file_count"])
+
+	var primary string
+	require.NoError(t, db.QueryRow(`SELECT file_id FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&primary))
+	assert.Equal(t, "tax-2025", primary, "the first file binds the envelope")
+
+	rows, err := db.Query(`SELECT file_id FROM file_share_bundle_files WHERE share_id = ? ORDER BY position`, testShareID)
+	require.NoError(t, err)
+	defer rows.Close()
+	var members []string
+	for rows.Next() {
+		var fileID string
+		require.NoError(t, rows.Scan(&fileID))
+		members = append(members, fileID)
+	}
+	assert.Equal(t, []string{"tax-2025", "tax-2024", "photo"}, members)
+}
+
+func TestCreateFileShare_BundleValidation(t *testing.T) {
+	db := setupShareBundleTest(t)
+
+	bundle := func(ids ...string) map[string]interface{} {
+		return map[string]interface{}{"file_ids": ids}
+	}
+	status, _ := createShare(t, "alice", bundle("tax-2024"))
+	assert.Equal(t, http.StatusBadRequest, status, "a bundle needs two files")
+	status, _ = createShare(t, "alice", bundle("tax-2024", "tax-2024"))
+	assert.Equal(t, http.StatusBadRequest, status, "duplicate file")
+	status, _ = createShare(t, "alice", bundle("tax-2024", "bob-tax"))
+	assert.Equal(t, http.StatusForbidden, status, "another user's file")
+	status, _ = createShare(t, "alice", bundle("tax-2024", "missing"))
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = createShare(t, "alice", map[string]interface{}{
+		"file_id": "photo", "file_ids": []string{"tax-2024", "photo"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status, "file_id must be the first member")
+
+	_, err := db.Exec(`UPDATE file_metadata SET deleted_at = CURRENT_TIMESTAMP WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	status, _ = createShare(t, "alice", bundle("tax-2024", "photo"))
+	assert.Equal(t, http.StatusNotFound, status, "trashed files cannot be shared")
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_share_keys`).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestShareBundle_PublicAccess(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{
+		"file_ids":     []string{"tax-2024", "tax-2025"},
+		"max_accesses": 1,
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	// The envelope response lists the members
+	c, rec := publicShareContext("/api/public/shares/"+testShareID+"/envelope", []string{"id"}, []string{testShareID})
+	require.NoError(t, GetShareEnvelope(c))
+	var envelope struct {
+		SizeBytes int64             `json:"size_bytes"`
+		Files     []shareBundleFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope))
+	assert.Equal(t, int64(200), envelope.SizeBytes)
+	assert.Equal(t, []shareBundleFile{
+		{FileID: "tax-2024", SizeBytes: 100, Available: true},
+		{FileID: "tax-2025", SizeBytes: 100, Available: true},
+	}, envelope.Files)
+
+	// Chunk requests must name a member
+	assert.Equal(t, http.StatusBadRequest, downloadBundleChunk(t, ""))
+	assert.Equal(t, http.StatusNotFound, downloadBundleChunk(t, "photo"))
+
+	c, rec = publicShareContext("/api/public/shares/"+testShareID+"/metadata?file_id=tax-2025", []string{"id"}, []string{testShareID})
+	require.NoError(t, GetShareDownloadMetadata(c))
+	assert.Contains(t, rec.Body.String(), `"file_id":"tax-2025"`)
+
+	// max_accesses applies to each member; the share is exhausted once all are spent
+	assert.Equal(t, http.StatusOK, downloadBundleChunk(t, "tax-2024"))
+	assert.Equal(t, http.StatusForbidden, downloadBundleChunk(t, "tax-2024"))
+	var accessCount int
+	require.NoError(t, db.QueryRow(`SELECT access_count FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&accessCount))
+	assert.Zero(t, accessCount)
+
+	assert.Equal(t, http.StatusOK, downloadBundleChunk(t, "tax-2025"))
+	require.NoError(t, db.QueryRow(`SELECT access_count FROM file_share_keys WHERE share_id = ?`, testShareID).Scan(&accessCount))
+	assert.Equal(t, 1, accessCount)
+}
+
+func TestShareBundle_TrashedMemberAndListing(t *testing.T) {
+	db := setupShareBundleTest(t)
+	status, _ := createShare(t, "alice", map[string]interface{}{
+		"file_ids": []string{"tax-2024", "tax-2025"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	_, err := db.Exec(`UPDATE file_metadata SET deleted_at = CURRENT_TIMESTAMP WHERE file_id = 'tax-2025'`)
+	require.NoError(t, err)
+
+	c, rec := publicShareContext("/api/public/shares/"+testShareID+"/envelope", []string{"id"}, []string{testShareID})
+	require.NoError(t, GetShareEnvelope(c))
+	var envelope struct {
+		SizeBytes int64             `json:"size_bytes"`
+		Files     []shareBundleFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope))
+	require.Len(t, envelope.Files, 2)
+	assert.False(t, envelope.Files[1].Available)
+	assert.Equal(t, int64(100), envelope.SizeBytes, "the total counts available files only")
+	assert.Equal(t, http.StatusNotFound, downloadBundleChunk(t, "tax-2025"))
+
+	c, rec = authedContext(http.MethodGet, "/api/shares", nil, "alice")
+	require.NoError(t, ListShares(c))
+	var response struct {
+		Shares []struct {
+			ShareID   string   `json:"share_id"`
+			FileCount int      `json:"file_count"`
+			FileIDs   []string `json:"file_ids"`
+		} `json:"shares"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Shares, 1)
+	assert.Equal(t, 2, response.Shares[0].FileCount)
+	assert.Equal(t, []string{"tax-2024", "tax-2025"}, response.Shares[0].FileIDs)
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// scrubInterval is how often the background scrubber wakes up. Each pass checks
+// the share of active copies needed to cover every copy once per scrub period,
+// so coverage does not depend on the server staying up for a whole day.
+const scrubInterval = time.Hour
+
+// scrubItem is one stored copy, or one shard of an erasure-coded file, to re-hash.
+type scrubItem struct {
+	FileID       string
+	ProviderID   string
+	StorageID    string
+	ObjectName   string // object to read: StorageID, or the shard's object
+	ShardIndex   int    // -1 for a whole-file copy
+	ExpectedHash string
+}
+
+// scrubCandidates selects every active copy with a stored hash and every
+// active erasure shard, with the columns buildScrubList reads and orders by.
+const scrubCandidates = `
+		SELECT fsl.file_id, fsl.provider_id, fsl.storage_id, fsl.storage_id AS object_name, -1 AS shard_index,
+		       fm.stored_blob_sha256sum AS expected_hash, fsl.verified_at, fsl.id
+		FROM file_storage_locations fsl
+		JOIN file_metadata fm ON fsl.file_id = fm.file_id
+		WHERE fsl.status = 'active' AND COALESCE(fm.stored_blob_sha256sum, '') != ''
+		UNION ALL
+		SELECT file_id, provider_id, storage_id, object_name, shard_index, sha256, verified_at, id
+		FROM file_erasure_shards
+		WHERE status = 'active'`
+
+// scrubResult summarizes one scrub pass.
+type scrubResult struct {
+	Checked    int
+	Verified   int
+	Mismatched int
+	Errors     int
+	BytesRead  int64
+}
+
+// startStorageScrubber runs scrubStoredBlobs every scrubInterval until ctx is done.
+func startStorageScrubber(ctx context.Context, periodDays int, maxBytesPerSec int64) {
+	go func() {
+		ticker := time.NewTicker(scrubInterval)
+		defer ticker.Stop()
+
+		log := func(res scrubResult) {
+			if res.Checked > 0 {
+				logging.InfoLogger.Printf("Storage scrub: checked %d copies (verified: %d, mismatched: %d, errors: %d, bytes: %d)",
+					res.Checked, res.Verified, res.Mismatched, res.Errors, res.BytesRead)
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				log(scrubStoredBlobs(ctx, periodDays, maxBytesPerSec))
+			}
+		}
+	}()
+}
+
+// scrubStoredBlobs re-hashes the least recently verified slice of active copies
+// against stored_blob_sha256sum, and of erasure shards against the hash
+// recorded when they were striped. A matching copy gets its verified_at
+// bumped; a mismatching copy or shard is marked "corrupt" (so repair-storage
+// replaces or rebuilds it) and a security_alerts row is raised. Read errors
+// are logged and retried next pass.
+func scrubStoredBlobs(ctx context.Context, periodDays int, maxBytesPerSec int64) scrubResult {
+	var res scrubResult
+
+	defer func() {
+		if r := recover(); r != nil {
+			logging.ErrorLogger.Printf("Panic recovered in storage scrub: %v", r)
+		}
+	}()
+
+	if storage.Registry == nil || periodDays <= 0 {
+		return res
+	}
+
+	var totalRaw interface{}
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM (` + scrubCandidates + `)`).Scan(&totalRaw); err != nil {
+		logging.ErrorLogger.Printf("Storage scrub: failed to count stored copies: %v", err)
+		return res
+	}
+
+	items, err := buildScrubList(scrubBatchSize(toInt64FromInterface(totalRaw), periodDays))
+	if err != nil {
+		logging.ErrorLogger.Printf("Storage scrub: failed to build scrub list: %v", err)
+		return res
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return res
+		}
+
+		provider := storage.Registry.GetProvider(item.ProviderID)
+		if provider == nil {
+			// Not configured on this server; nothing to read from
+			continue
+		}
+
+		res.Checked++
+		hash, n, err := hashStoredObject(ctx, provider, item.ObjectName, maxBytesPerSec)
+		res.BytesRead += n
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.ErrorLogger.Printf("Storage scrub: failed to read file %s on %s: %v", item.FileID, item.ProviderID, err)
+			}
+			res.Errors++
+			continue
+		}
+
+		isShard := item.ShardIndex >= 0
+		if hash == item.ExpectedHash {
+			if isShard {
+				models.MarkFileErasureShardVerified(database.DB, item.StorageID, item.ShardIndex)
+			} else {
+				models.MarkFileStorageLocationVerified(database.DB, item.FileID, item.ProviderID)
+			}
+			res.Verified++
+			continue
+		}
+
+		res.Mismatched++
+		what := "Stored copy"
+		alertDetails := map[string]interface{}{
+			"file_id":       item.FileID,
+			"provider_id":   item.ProviderID,
+			"storage_id":    item.StorageID,
+			"expected_hash": item.ExpectedHash,
+			"actual_hash":   hash,
+		}
+		if isShard {
+			what = fmt.Sprintf("Erasure shard %d", item.ShardIndex)
+			alertDetails["shard_index"] = item.ShardIndex
+			models.UpdateFileErasureShardStatus(database.DB, item.StorageID, item.ShardIndex, "corrupt")
+		} else {
+			models.UpdateFileStorageLocationStatus(database.DB, item.FileID, item.ProviderID, "corrupt")
+		}
+		logging.ErrorLogger.Printf("Storage scrub: hash mismatch for %s of file %s on %s (expected %s, got %s)",
+			strings.ToLower(what), item.FileID, item.ProviderID, item.ExpectedHash, hash)
+		if err := models.CreateSecurityAlert(database.DB, models.AlertTypeStorageIntegrity,
+			string(logging.SeverityCritical), item.FileID,
+			fmt.Sprintf("%s of file %s on provider %s does not match its recorded hash", what, item.FileID, item.ProviderID),
+			alertDetails,
+		); err != nil {
+			logging.ErrorLogger.Printf("Storage scrub: failed to raise alert for file %s: %v", item.FileID, err)
+		}
+	}
+
+	return res
+}
+
+// scrubBatchSize returns how many copies one pass must check so that total
+// copies are all covered within periodDays of hourly passes.
+func scrubBatchSize(total int64, periodDays int) int {
+	passes := int64(periodDays) * int64(24*time.Hour/scrubInterval)
+	if total <= 0 || passes <= 0 {
+		return 0
+	}
+	return int((total + passes - 1) / passes)
+}
+
+// buildScrubList returns up to limit active copies with a stored hash and
+// active erasure shards, never verified ones first, then the longest since
+// their last verification.
+func buildScrubList(limit int) ([]scrubItem, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT file_id, provider_id, storage_id, object_name, shard_index, expected_hash
+		FROM (`+scrubCandidates+`)
+		ORDER BY verified_at IS NOT NULL, verified_at, id
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []scrubItem
+	for rows.Next() {
+		var item scrubItem
+		var shardIndexRaw interface{}
+		if err := rows.Scan(&item.FileID, &item.ProviderID, &item.StorageID, &item.ObjectName, &shardIndexRaw, &item.ExpectedHash); err != nil {
+			return nil, err
+		}
+		item.ShardIndex = int(toInt64FromInterface(shardIndexRaw))
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// hashStoredObject streams an object from provider, reading no faster than
+// maxBytesPerSec (0 means unlimited), and returns its hex SHA-256.
+func hashStoredObject(ctx context.Context, provider storage.ObjectStorageProvider, objectName string, maxBytesPerSec int64) (string, int64, error) {
+	obj, err := provider.GetObject(ctx, objectName, storage.GetObjectOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, &throttledReader{ctx: ctx, r: obj, bytesPerSec: maxBytesPerSec, start: time.Now()})
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), n, nil
+}
+
+// throttledReader limits the average read rate of r to bytesPerSec by sleeping
+// whenever reads get ahead of schedule.
+type throttledReader struct {
+	ctx         context.Context
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+	if t.bytesPerSec <= 0 || n == 0 {
+		return n, err
+	}
+
+	due := t.start.Add(time.Duration(float64(t.read) / float64(t.bytesPerSec) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
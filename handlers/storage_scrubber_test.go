@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/models"
+)
+
+func TestScrubBatchSize(t *testing.T) {
+	assert.Equal(t, 0, scrubBatchSize(0, 30))
+	assert.Equal(t, 0, scrubBatchSize(100, 0))
+	// 720 hourly passes in 30 days: fewer copies than passes still checks one per pass
+	assert.Equal(t, 1, scrubBatchSize(100, 30))
+	assert.Equal(t, 2, scrubBatchSize(1000, 30))
+	assert.Equal(t, 42, scrubBatchSize(1000, 1))
+}
+
+func TestScrubStoredBlobs_VerifiesAndFlagsMismatch(t *testing.T) {
+	providers := setupRepairTest(t)
+
+	good := []byte("good blob")
+	addRepairTestFile(t, "good", good, "p1")
+	putRepairTestObject(t, providers[0], "blob-good", good)
+
+	addRepairTestFile(t, "rotten", []byte("original"), "p1")
+	putRepairTestObject(t, providers[0], "blob-rotten", []byte("bitrot!!"))
+
+	// A one-day period with two copies checks one copy per pass
+	first := scrubStoredBlobs(context.Background(), 1, 0)
+	assert.Equal(t, 1, first.Checked)
+	second := scrubStoredBlobs(context.Background(), 1, 0)
+	assert.Equal(t, 1, second.Checked)
+	assert.Equal(t, 1, first.Verified+second.Verified)
+	assert.Equal(t, 1, first.Mismatched+second.Mismatched)
+
+	status, verified := repairTestLocationStatus(t, "good", "p1")
+	assert.Equal(t, "active", status)
+	assert.True(t, verified)
+
+	status, verified = repairTestLocationStatus(t, "rotten", "p1")
+	assert.Equal(t, "corrupt", status)
+	assert.False(t, verified)
+
+	count, err := models.CountUnacknowledgedSecurityAlerts(database.DB, models.AlertTypeStorageIntegrity)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	var entityID string
+	require.NoError(t, database.DB.QueryRow("SELECT entity_id FROM security_alerts").Scan(&entityID))
+	assert.Equal(t, "rotten", entityID)
+}
+
+func TestScrubStoredBlobs_OldestFirst(t *testing.T) {
+	providers := setupRepairTest(t)
+
+	data := []byte("blob")
+	for _, id := range []string{"a", "b"} {
+		addRepairTestFile(t, id, data, "p1")
+		putRepairTestObject(t, providers[0], "blob-"+id, data)
+	}
+	_, err := database.DB.Exec("UPDATE file_storage_locations SET verified_at = '2026-01-01 00:00:00' WHERE file_id = 'a'")
+	require.NoError(t, err)
+
+	items, err := buildScrubList(1)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "b", items[0].FileID, "never-verified copies come first")
+}
+
+func TestThrottledReader_LimitsRate(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2000)
+	r := &throttledReader{ctx: context.Background(), r: bytes.NewReader(data), bytesPerSec: 10000, start: time.Now()}
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestThrottledReader_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &throttledReader{ctx: ctx, r: bytes.NewReader(make([]byte, 1000)), bytesPerSec: 1, start: time.Now()}
+
+	_, err := io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// defaultTierColdAfterDays is how long a file must go without a download before
+// tier-storage moves it off the primary, when the request does not say.
+const defaultTierColdAfterDays = 30
+
+// bytesPerTB converts storage_providers.cost_per_tb_cents (decimal terabytes,
+// as providers bill) into a per-byte monthly cost.
+const bytesPerTB = 1e12
+
+// tierProvider is a storage provider and its monthly cost per TB.
+type tierProvider struct {
+	ID             string `json:"provider_id"`
+	CostPerTBCents int64  `json:"cost_per_tb_cents"`
+}
+
+// monthlyCostCents returns what storing n bytes on p costs per month, in cents.
+func (p tierProvider) monthlyCostCents(n int64) float64 {
+	return float64(n) * float64(p.CostPerTBCents) / bytesPerTB
+}
+
+// resolveTierProviders returns the primary (hot) provider and the cheapest
+// active configured provider that costs less (cold). Both need a cost set with
+// set-cost; providers without one are never chosen as the cold tier.
+func resolveTierProviders() (tierProvider, tierProvider, error) {
+	hot := tierProvider{ID: storage.Registry.PrimaryID()}
+	record, err := models.GetStorageProviderByID(database.DB, hot.ID)
+	if err != nil {
+		return hot, tierProvider{}, fmt.Errorf("failed to look up primary provider %s: %w", hot.ID, err)
+	}
+	if !record.CostPerTBCents.Valid || record.CostPerTBCents.Int64 <= 0 {
+		return hot, tierProvider{}, fmt.Errorf("primary provider %s has no cost set; use set-cost first", hot.ID)
+	}
+	hot.CostPerTBCents = record.CostPerTBCents.Int64
+
+	var cold tierProvider
+	for _, id := range storage.Registry.ConfiguredProviderIDs() {
+		if id == hot.ID {
+			continue
+		}
+		record, err := models.GetStorageProviderByID(database.DB, id)
+		if err != nil || !record.IsActive || !record.CostPerTBCents.Valid {
+			continue
+		}
+		cost := record.CostPerTBCents.Int64
+		if cost < hot.CostPerTBCents && (cold.ID == "" || cost < cold.CostPerTBCents) {
+			cold = tierProvider{ID: id, CostPerTBCents: cost}
+		}
+	}
+	if cold.ID == "" {
+		return hot, cold, fmt.Errorf("no active provider is cheaper than the primary %s", hot.ID)
+	}
+	return hot, cold, nil
+}
+
+// buildTieringLists returns the files to move off the hot provider (active
+// there and not downloaded in coldAfterDays) and the files to move back (tiered
+// off a provider, not on the hot provider, and downloaded since). Files that
+// were never downloaded are aged from their upload date.
+func buildTieringLists(coldAfterDays int, hotID string) ([]fileRepairItem, []fileRepairItem, error) {
+	rows, err := database.DB.Query(`
+		SELECT file_id FROM file_metadata
+		WHERE COALESCE(last_accessed_at, upload_date) < datetime('now', ?)`,
+		fmt.Sprintf("-%d days", coldAfterDays),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	cold := make(map[string]bool)
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, nil, err
+		}
+		cold[fileID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	all, err := loadFileRepairItems("", storage.Registry.ConfiguredProviderIDs())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var demote, promote []fileRepairItem
+	for _, item := range all {
+		onHot := false
+		for _, id := range item.Healthy {
+			if id == hotID {
+				onHot = true
+				break
+			}
+		}
+		switch {
+		case cold[item.FileID] && onHot:
+			demote = append(demote, item)
+		case !cold[item.FileID] && !onHot && len(item.Tiered) > 0:
+			promote = append(promote, item)
+		}
+	}
+	return demote, promote, nil
+}
+
+// demoteDestination returns where demoteFile puts item's copy from the hot
+// provider. That is the cold provider, unless the cold provider already holds
+// one of the file's copies: moving the hot copy there would leave the file with
+// one copy fewer, and repair-storage would not put it back because the tiered
+// location lowers the copy target. The hot copy then goes to another allowed
+// provider that holds none. Returns "" when there is no such provider.
+func demoteDestination(item fileRepairItem, hotID, coldID string) string {
+	tried := map[string]bool{hotID: true}
+	for _, id := range item.Healthy {
+		tried[id] = true
+	}
+	if !tried[coldID] {
+		return coldID
+	}
+	for _, id := range item.Tiered {
+		tried[id] = true
+	}
+	return nextRepairDestination(tried, item.Allowed)
+}
+
+// errNoSpareTierProvider means the cold provider already holds a copy of the
+// file and no other provider can take the hot copy.
+var errNoSpareTierProvider = errors.New("cold provider already holds a copy and no other provider is free")
+
+// lookupTierProvider returns id with its cost, or a zero cost when none is set.
+func lookupTierProvider(id string) tierProvider {
+	p := tierProvider{ID: id}
+	if record, err := models.GetStorageProviderByID(database.DB, id); err == nil && record.CostPerTBCents.Valid {
+		p.CostPerTBCents = record.CostPerTBCents.Int64
+	}
+	return p
+}
+
+// demoteFile moves item's copy off the hot provider. It copies the file to
+// the provider chosen by demoteDestination, verified against the stored hash,
+// then deletes the hot copy and marks that location "tiered" so repair-storage
+// does not put it back. It returns the provider that received the copy; when
+// there is none it returns errNoSpareTierProvider and leaves the file alone.
+func demoteFile(ctx context.Context, item fileRepairItem, hotID, coldID string) (repairOutcome, string) {
+	destID := demoteDestination(item, hotID, coldID)
+	if destID == "" {
+		return repairOutcome{err: errNoSpareTierProvider}, ""
+	}
+	destItem := item
+	destItem.Allowed = []string{destID}
+	destItem.Tiered = nil
+
+	outcome := repairFile(ctx, destItem, 1, "")
+	if outcome.err != nil {
+		return outcome, destID
+	}
+
+	if err := storage.Registry.GetProvider(hotID).RemoveObject(ctx, item.StorageID, storage.RemoveObjectOptions{}); err != nil {
+		outcome.err = fmt.Errorf("failed to delete copy on %s: %w", hotID, err)
+		return outcome, destID
+	}
+	models.UpdateFileStorageLocationStatus(database.DB, item.FileID, hotID, "tiered")
+	return outcome, destID
+}
+
+// promoteFile copies item back onto the hot provider from any healthy copy,
+// verifying it against the stored hash. The cold copy is kept, so moving the
+// file down again later is just a delete. Once the file is back, providers it
+// was tiered off no longer lower its copy target.
+func promoteFile(ctx context.Context, item fileRepairItem, hotID string) repairOutcome {
+	hotItem := item
+	hotItem.Allowed = []string{hotID}
+	hotItem.Tiered = nil
+
+	outcome := repairFile(ctx, hotItem, 1, "")
+	if outcome.err != nil {
+		return outcome
+	}
+	for _, id := range item.Tiered {
+		if id != hotID {
+			models.UpdateFileStorageLocationStatus(database.DB, item.FileID, id, "deleted")
+		}
+	}
+	return outcome
+}
+
+// tierPromotions holds the files being copied back after a download, so the
+// chunk requests of concurrent downloads do not start duplicate copies.
+var tierPromotions sync.Map
+
+// noteFileAccess records a download of fileID and, if tier-storage moved the
+// file off the primary, copies it back in the background. The download itself
+// is served from the cold copy meanwhile.
+func noteFileAccess(fileID string) {
+	if err := models.RecordFileAccess(database.DB, fileID); err != nil {
+		logging.ErrorLogger.Printf("Failed to record access to file %s: %v", fileID, err)
+		return
+	}
+
+	locations, err := models.GetFileStorageLocations(database.DB, fileID)
+	if err != nil {
+		return
+	}
+	tiered, onPrimary := false, false
+	for _, loc := range locations {
+		switch {
+		case loc.Status == "tiered":
+			tiered = true
+		case loc.Status == "active" && loc.ProviderID == storage.Registry.PrimaryID():
+			onPrimary = true
+		}
+	}
+	if !tiered || onPrimary {
+		return
+	}
+
+	if _, busy := tierPromotions.LoadOrStore(fileID, true); busy {
+		return
+	}
+	go func() {
+		defer tierPromotions.Delete(fileID)
+		promoteOnAccess(context.Background(), fileID)
+	}()
+}
+
+// promoteOnAccess moves a tiered file back onto the primary after a download.
+func promoteOnAccess(ctx context.Context, fileID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.ErrorLogger.Printf("Panic recovered in tiering promotion of file %s: %v", fileID, r)
+		}
+	}()
+
+	hotID := storage.Registry.PrimaryID()
+	items, err := loadFileRepairItems(fileID, storage.Registry.ConfiguredProviderIDs())
+	if err != nil || len(items) != 1 {
+		logging.ErrorLogger.Printf("Tiering: failed to load file %s for promotion: %v", fileID, err)
+		return
+	}
+	item := items[0]
+	if item.ExpectedHash == "" || !item.allows(hotID) {
+		return
+	}
+
+	outcome := promoteFile(ctx, item, hotID)
+	if outcome.err != nil {
+		logging.ErrorLogger.Printf("Tiering: failed to move file %s back to %s: %v", fileID, hotID, outcome.err)
+		return
+	}
+	logging.InfoLogger.Printf("Tiering: moved file %s back to %s after a download (%d bytes)", fileID, hotID, outcome.bytesCopied)
+}
+
+// TieringTaskRequest describes a tier-storage operation submitted by an admin API handler.
+type TieringTaskRequest struct {
+	AdminUsername string
+	ColdAfterDays int // days without a download before a file moves off the primary; 0 uses the default
+}
+
+// TieringTaskDetails holds the JSON-serializable details stored in admin_tasks.details.
+type TieringTaskDetails struct {
+	HotProviderID       string  `json:"hot_provider_id"`
+	ColdProviderID      string  `json:"cold_provider_id"`
+	ColdAfterDays       int     `json:"cold_after_days"`
+	FilesDemoted        int     `json:"files_demoted"`  // moved off the hot provider
+	FilesPromoted       int     `json:"files_promoted"` // moved back after being downloaded
+	FilesFailed         int     `json:"files_failed"`
+	FilesNotAllowed     int     `json:"files_not_allowed"` // destination outside the owner's placement policy
+	FilesSkippedNoHash  int     `json:"files_skipped_no_hash"`
+	FilesSkippedNoSpare int     `json:"files_skipped_no_spare"` // cold provider already holds a copy, no other provider free
+	CorruptCopies       int     `json:"corrupt_copies"`
+	BytesCopied         int64   `json:"bytes_copied"`
+	BytesFreed          int64   `json:"bytes_freed"`           // removed from the hot provider
+	MonthlySavingsCents float64 `json:"monthly_savings_cents"` // net change in monthly storage cost
+}
+
+// SubmitTieringTask moves files not downloaded in req.ColdAfterDays from the
+// primary to the cheapest active provider, and moves tiered files that were
+// downloaded since back, in the background. Returns the task ID immediately.
+func (tr *TaskRunner) SubmitTieringTask(req TieringTaskRequest) (string, error) {
+	if req.ColdAfterDays <= 0 {
+		req.ColdAfterDays = defaultTierColdAfterDays
+	}
+	hot, cold, err := resolveTierProviders()
+	if err != nil {
+		return "", err
+	}
+
+	demote, promote, err := buildTieringLists(req.ColdAfterDays, hot.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to build tiering list: %w", err)
+	}
+
+	taskID, err := models.CreateAdminTask(database.DB, "tier-storage", req.AdminUsername, len(demote)+len(promote))
+	if err != nil {
+		return "", fmt.Errorf("failed to create task record: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.mu.Lock()
+	tr.activeTasks[taskID] = cancel
+	tr.mu.Unlock()
+
+	go tr.runTieringTask(ctx, taskID, req, hot, cold, demote, promote)
+
+	return taskID, nil
+}
+
+// runTieringTask is the background goroutine that moves files between tiers.
+func (tr *TaskRunner) runTieringTask(
+	ctx context.Context,
+	taskID string,
+	req TieringTaskRequest,
+	hot, cold tierProvider,
+	demote, promote []fileRepairItem,
+) {
+	// Acquire semaphore slot
+	tr.semaphore <- struct{}{}
+	defer func() { <-tr.semaphore }()
+
+	// Clean up active task tracking when done
+	defer func() {
+		tr.mu.Lock()
+		delete(tr.activeTasks, taskID)
+		tr.mu.Unlock()
+	}()
+
+	// Mark task as running
+	if err := models.StartAdminTask(database.DB, taskID); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark as running: %v", taskID, err)
+		return
+	}
+
+	details := TieringTaskDetails{
+		HotProviderID:  hot.ID,
+		ColdProviderID: cold.ID,
+		ColdAfterDays:  req.ColdAfterDays,
+	}
+	var coldBytesAdded, hotBytesAdded int64
+	var otherCostCents float64 // copies moved to a provider other than the cold one
+	touched := map[string]bool{hot.ID: true, cold.ID: true}
+
+	persistDetails := func() {
+		details.MonthlySavingsCents = hot.monthlyCostCents(details.BytesFreed-hotBytesAdded) - cold.monthlyCostCents(coldBytesAdded) - otherCostCents
+		detailsSnap, _ := json.Marshal(details)
+		models.UpdateAdminTaskDetails(database.DB, taskID, string(detailsSnap))
+	}
+
+	type tierStep struct {
+		item    fileRepairItem
+		promote bool
+	}
+	steps := make([]tierStep, 0, len(demote)+len(promote))
+	for _, item := range promote {
+		steps = append(steps, tierStep{item, true})
+	}
+	for _, item := range demote {
+		steps = append(steps, tierStep{item, false})
+	}
+
+	for i, step := range steps {
+		// Check for cancellation between files
+		if ctx.Err() != nil {
+			models.UpdateAdminTaskStatus(database.DB, taskID, "canceled")
+			logging.InfoLogger.Printf("Task %s: canceled at file %d/%d", taskID, i, len(steps))
+			return
+		}
+
+		item := step.item
+		destID := cold.ID
+		if step.promote {
+			destID = hot.ID
+		}
+
+		switch {
+		case !item.allows(destID):
+			details.FilesNotAllowed++
+		case item.ExpectedHash == "":
+			// Without a stored hash a new copy cannot be verified, so don't move it
+			details.FilesSkippedNoHash++
+		case step.promote:
+			outcome := promoteFile(ctx, item, hot.ID)
+			details.CorruptCopies += outcome.corruptSources
+			details.BytesCopied += outcome.bytesCopied
+			hotBytesAdded += outcome.bytesCopied
+			if outcome.err != nil {
+				logging.ErrorLogger.Printf("Task %s: failed to move file %s back to %s: %v", taskID, item.FileID, hot.ID, outcome.err)
+				details.FilesFailed++
+			} else {
+				details.FilesPromoted++
+			}
+		default:
+			outcome, movedTo := demoteFile(ctx, item, hot.ID, cold.ID)
+			details.CorruptCopies += outcome.corruptSources
+			details.BytesCopied += outcome.bytesCopied
+			if movedTo == cold.ID {
+				coldBytesAdded += outcome.bytesCopied
+			} else if movedTo != "" {
+				otherCostCents += lookupTierProvider(movedTo).monthlyCostCents(outcome.bytesCopied)
+				touched[movedTo] = true
+			}
+			if errors.Is(outcome.err, errNoSpareTierProvider) {
+				// Deleting the hot copy would leave the file below its copy target
+				details.FilesSkippedNoSpare++
+			} else if outcome.err != nil {
+				logging.ErrorLogger.Printf("Task %s: failed to move file %s to %s, keeping copy on %s: %v",
+					taskID, item.FileID, cold.ID, hot.ID, outcome.err)
+				details.FilesFailed++
+			} else {
+				details.FilesDemoted++
+				details.BytesFreed += item.PaddedSize
+			}
+		}
+
+		persistDetails()
+		models.UpdateAdminTaskProgress(database.DB, taskID, i+1)
+	}
+
+	for id := range touched {
+		if err := models.RecalculateProviderStats(database.DB, id); err != nil {
+			logging.ErrorLogger.Printf("Task %s: failed to recalculate stats for provider %s: %v", taskID, id, err)
+		}
+	}
+
+	// Complete the task
+	persistDetails()
+	detailsJSON, _ := json.Marshal(details)
+	if err := models.CompleteAdminTask(database.DB, taskID, string(detailsJSON)); err != nil {
+		logging.ErrorLogger.Printf("Task %s: failed to mark complete: %v", taskID, err)
+	}
+
+	logging.InfoLogger.Printf("Task %s: tiering %s -> %s completed (demoted: %d, promoted: %d, failed: %d, freed: %d bytes, savings: %.2f cents/month)",
+		taskID, hot.ID, cold.ID, details.FilesDemoted, details.FilesPromoted, details.FilesFailed,
+		details.BytesFreed, details.MonthlySavingsCents)
+}
+
+// TieringReport projects what tier-storage would save.
+type TieringReport struct {
+	Hot                        tierProvider `json:"hot_provider"`
+	Cold                       tierProvider `json:"cold_provider"`
+	ColdAfterDays              int          `json:"cold_after_days"`
+	CandidateFiles             int          `json:"candidate_files"` // would move to the cold provider
+	CandidateBytes             int64        `json:"candidate_bytes"`
+	NewColdBytes               int64        `json:"new_cold_bytes"` // of which not yet on the cold provider
+	ProjectedSavingsCents      float64      `json:"projected_monthly_savings_cents"`
+	ReturningFiles             int          `json:"returning_files"` // tiered but downloaded since
+	ReturningBytes             int64        `json:"returning_bytes"`
+	TieredFiles                int          `json:"tiered_files"` // currently off the hot provider
+	TieredBytes                int64        `json:"tiered_bytes"`
+	CurrentMonthlySavingsCents float64      `json:"current_monthly_savings_cents"`
+}
+
+// buildTieringReport computes the tiering projection for coldAfterDays.
+// Savings are the hot provider's cost for the bytes it would no longer hold,
+// minus the cost of the new copies on the cold provider (or, for files it
+// already holds, on the provider that takes the hot copy instead).
+func buildTieringReport(coldAfterDays int) (*TieringReport, error) {
+	if coldAfterDays <= 0 {
+		coldAfterDays = defaultTierColdAfterDays
+	}
+	hot, cold, err := resolveTierProviders()
+	if err != nil {
+		return nil, err
+	}
+	demote, promote, err := buildTieringLists(coldAfterDays, hot.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TieringReport{Hot: hot, Cold: cold, ColdAfterDays: coldAfterDays}
+	var otherCostCents float64
+	for _, item := range demote {
+		if item.ExpectedHash == "" || !item.allows(cold.ID) {
+			continue
+		}
+		destID := demoteDestination(item, hot.ID, cold.ID)
+		if destID == "" {
+			continue
+		}
+		report.CandidateFiles++
+		report.CandidateBytes += item.PaddedSize
+		if destID == cold.ID {
+			report.NewColdBytes += item.PaddedSize
+		} else {
+			otherCostCents += lookupTierProvider(destID).monthlyCostCents(item.PaddedSize)
+		}
+	}
+	for _, item := range promote {
+		if item.ExpectedHash != "" && item.allows(hot.ID) {
+			report.ReturningFiles++
+			report.ReturningBytes += item.PaddedSize
+		}
+	}
+	report.ProjectedSavingsCents = hot.monthlyCostCents(report.CandidateBytes-report.ReturningBytes) - cold.monthlyCostCents(report.NewColdBytes) - otherCostCents
+
+	var filesRaw, bytesRaw interface{}
+	if err := database.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(COALESCE(fm.padded_size, fm.size_bytes)), 0)
+		FROM file_storage_locations fsl
+		JOIN file_metadata fm ON fsl.file_id = fm.file_id
+		WHERE fsl.provider_id = ? AND fsl.status = 'tiered'`, hot.ID,
+	).Scan(&filesRaw, &bytesRaw); err != nil {
+		return nil, err
+	}
+	report.TieredFiles = int(toInt64FromInterface(filesRaw))
+	report.TieredBytes = toInt64FromInterface(bytesRaw)
+	report.CurrentMonthlySavingsCents = hot.monthlyCostCents(report.TieredBytes)
+
+	return report, nil
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/models"
+	"github.com/arkfile/Arkfile/storage"
+)
+
+// setTieringTestCosts sets cost_per_tb_cents for p1, p2 and p3.
+func setTieringTestCosts(t *testing.T, p1, p2, p3 int64) {
+	t.Helper()
+	for id, cost := range map[string]int64{"p1": p1, "p2": p2, "p3": p3} {
+		_, err := database.DB.Exec("UPDATE storage_providers SET cost_per_tb_cents = ? WHERE provider_id = ?", cost, id)
+		require.NoError(t, err)
+	}
+}
+
+// ageTieringTestFile backdates a file's upload so it counts as cold.
+func ageTieringTestFile(t *testing.T, fileID string, days int) {
+	t.Helper()
+	_, err := database.DB.Exec("UPDATE file_metadata SET upload_date = datetime('now', ?) WHERE file_id = ?",
+		fmt.Sprintf("-%d days", days), fileID)
+	require.NoError(t, err)
+}
+
+func TestResolveTierProviders(t *testing.T) {
+	setupRepairTest(t)
+
+	_, _, err := resolveTierProviders()
+	assert.Error(t, err, "primary without a cost cannot be tiered")
+
+	setTieringTestCosts(t, 2300, 600, 400)
+	hot, cold, err := resolveTierProviders()
+	require.NoError(t, err)
+	assert.Equal(t, tierProvider{ID: "p1", CostPerTBCents: 2300}, hot)
+	assert.Equal(t, tierProvider{ID: "p3", CostPerTBCents: 400}, cold)
+
+	// The cheapest provider is disabled: fall back to the next cheapest
+	require.NoError(t, models.SetStorageProviderActive(database.DB, "p3", false))
+	_, cold, err = resolveTierProviders()
+	require.NoError(t, err)
+	assert.Equal(t, "p2", cold.ID)
+
+	// Nothing cheaper than the primary
+	setTieringTestCosts(t, 300, 600, 400)
+	_, _, err = resolveTierProviders()
+	assert.Error(t, err)
+}
+
+func TestRunTieringTask_MovesColdFilesOffPrimary(t *testing.T) {
+	providers := setupRepairTest(t)
+	setTieringTestCosts(t, 2300, 600, 400)
+	data := []byte("cold blob")
+
+	addRepairTestFile(t, "cold", data, "p1", "p2")
+	putRepairTestObject(t, providers[0], "blob-cold", data)
+	putRepairTestObject(t, providers[1], "blob-cold", data)
+	ageTieringTestFile(t, "cold", 60)
+
+	addRepairTestFile(t, "warm", data, "p1")
+	putRepairTestObject(t, providers[0], "blob-warm", data)
+
+	report, err := buildTieringReport(30)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.CandidateFiles)
+	assert.Equal(t, int64(len(data)), report.CandidateBytes)
+	assert.Equal(t, int64(len(data)), report.NewColdBytes)
+	assert.InDelta(t, float64(len(data))*(2300-400)/bytesPerTB, report.ProjectedSavingsCents, 1e-12)
+
+	hot, cold, err := resolveTierProviders()
+	require.NoError(t, err)
+	demote, promote, err := buildTieringLists(30, hot.ID)
+	require.NoError(t, err)
+	require.Len(t, demote, 1)
+	assert.Empty(t, promote)
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := TieringTaskRequest{AdminUsername: "admin", ColdAfterDays: 30}
+	taskID, err := models.CreateAdminTask(database.DB, "tier-storage", req.AdminUsername, len(demote))
+	require.NoError(t, err)
+	tr.runTieringTask(context.Background(), taskID, req, hot, cold, demote, promote)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", task.Status)
+	var details TieringTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesDemoted)
+	assert.Equal(t, int64(len(data)), details.BytesFreed)
+	assert.Greater(t, details.MonthlySavingsCents, float64(0))
+
+	// The cold copy is verified on p3 and gone from the primary
+	status, verified := repairTestLocationStatus(t, "cold", "p3")
+	assert.Equal(t, "active", status)
+	assert.True(t, verified)
+	status, _ = repairTestLocationStatus(t, "cold", "p1")
+	assert.Equal(t, "tiered", status)
+	_, err = providers[0].HeadObject(context.Background(), "blob-cold")
+	assert.Error(t, err)
+
+	// The recently uploaded file stays where it is
+	status, _ = repairTestLocationStatus(t, "warm", "p1")
+	assert.Equal(t, "active", status)
+
+	// repair-storage does not put the tiered copy back on the primary
+	items, err := buildRepairList("cold", 3, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestNoteFileAccess_MovesTieredFileBack(t *testing.T) {
+	providers := setupRepairTest(t)
+	data := []byte("tiered blob")
+
+	addRepairTestFile(t, "f1", data, "p3")
+	putRepairTestObject(t, providers[2], "blob-f1", data)
+	require.NoError(t, models.InsertFileStorageLocation(database.DB, "f1", "p1", "blob-f1", "tiered"))
+
+	assert.Equal(t, []string{"p3"}, fileReadProviders("f1"))
+
+	noteFileAccess("f1")
+
+	require.Eventually(t, func() bool {
+		_, busy := tierPromotions.Load("f1")
+		return !busy
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status, verified := repairTestLocationStatus(t, "f1", "p1")
+	assert.Equal(t, "active", status)
+	assert.True(t, verified)
+	_, err := providers[0].HeadObject(context.Background(), "blob-f1")
+	assert.NoError(t, err)
+
+	// The cold copy stays, and the access was recorded
+	status, _ = repairTestLocationStatus(t, "f1", "p3")
+	assert.Equal(t, "active", status)
+	var lastAccess *string
+	require.NoError(t, database.DB.QueryRow("SELECT last_accessed_at FROM file_metadata WHERE file_id = 'f1'").Scan(&lastAccess))
+	assert.NotNil(t, lastAccess)
+}
+
+func TestRunTieringTask_ColdProviderAlreadyHoldsCopy(t *testing.T) {
+	providers := setupRepairTest(t)
+	setTieringTestCosts(t, 2300, 600, 400)
+	data := []byte("replicated blob")
+
+	// On the primary and the cold provider, with p2 free to take the hot copy
+	addRepairTestFile(t, "spare", data, "p1", "p3")
+	putRepairTestObject(t, providers[0], "blob-spare", data)
+	putRepairTestObject(t, providers[2], "blob-spare", data)
+	ageTieringTestFile(t, "spare", 60)
+
+	// Already on every provider: nowhere to move the hot copy
+	addRepairTestFile(t, "full", data, "p1", "p2", "p3")
+	for _, p := range providers {
+		putRepairTestObject(t, p, "blob-full", data)
+	}
+	ageTieringTestFile(t, "full", 60)
+
+	report, err := buildTieringReport(30)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.CandidateFiles)
+	assert.Equal(t, int64(0), report.NewColdBytes)
+	assert.InDelta(t, float64(len(data))*(2300-600)/bytesPerTB, report.ProjectedSavingsCents, 1e-12)
+
+	hot, cold, err := resolveTierProviders()
+	require.NoError(t, err)
+	demote, promote, err := buildTieringLists(30, hot.ID)
+	require.NoError(t, err)
+	require.Len(t, demote, 2)
+
+	tr := &TaskRunner{activeTasks: make(map[string]context.CancelFunc), semaphore: make(chan struct{}, 1)}
+	req := TieringTaskRequest{AdminUsername: "admin", ColdAfterDays: 30}
+	taskID, err := models.CreateAdminTask(database.DB, "tier-storage", req.AdminUsername, len(demote))
+	require.NoError(t, err)
+	tr.runTieringTask(context.Background(), taskID, req, hot, cold, demote, promote)
+
+	task, err := models.GetAdminTask(database.DB, taskID)
+	require.NoError(t, err)
+	var details TieringTaskDetails
+	require.NoError(t, json.Unmarshal([]byte(task.Details.String), &details))
+	assert.Equal(t, 1, details.FilesDemoted)
+	assert.Equal(t, 1, details.FilesSkippedNoSpare)
+	assert.Equal(t, 0, details.FilesFailed)
+
+	// The hot copy moved to p2, so the file still has two copies
+	status, verified := repairTestLocationStatus(t, "spare", "p2")
+	assert.Equal(t, "active", status)
+	assert.True(t, verified)
+	status, _ = repairTestLocationStatus(t, "spare", "p3")
+	assert.Equal(t, "active", status)
+	status, _ = repairTestLocationStatus(t, "spare", "p1")
+	assert.Equal(t, "tiered", status)
+	_, err = providers[0].HeadObject(context.Background(), "blob-spare")
+	assert.Error(t, err)
+
+	// The file with no spare provider keeps its hot copy
+	status, _ = repairTestLocationStatus(t, "full", "p1")
+	assert.Equal(t, "active", status)
+	_, err = providers[0].HeadObject(context.Background(), "blob-full")
+	assert.NoError(t, err)
+
+	// With a target of three, the tiered location brings "spare" down to two
+	items, err := buildRepairList("", 3, storage.Registry.ConfiguredProviderIDs())
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
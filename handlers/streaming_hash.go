@@ -5,15 +5,33 @@ import (
 	"encoding/hex"
 	"hash"
 	"io"
+	"sync"
 )
 
-// StreamingHashState manages the running hash calculation during chunked uploads
+// StreamingHashState manages the running hash calculation during chunked uploads.
+//
+// The running hash must see the chunks in order. Chunks that arrive a little
+// ahead of the next expected one, as they do when a client uploads several
+// chunks in parallel, are held until the gap is filled, up to
+// maxHashReorderBytes. A resumed upload (client restart, server restart
+// dropping the in-memory state), a repeated chunk or a reorder window that
+// overflows breaks the ordering; the state then records that it is out of
+// order and CompleteUpload re-hashes the assembled object from storage instead.
 type StreamingHashState struct {
-	hash       hash.Hash
-	sessionID  string
-	totalBytes int64
+	mu           sync.Mutex
+	hash         hash.Hash
+	sessionID    string
+	totalBytes   int64
+	nextChunk    int
+	pending      map[int][]byte // chunks received ahead of nextChunk
+	pendingBytes int64
+	outOfOrder   bool
 }
 
+// maxHashReorderBytes caps the chunk data one hash state holds while waiting
+// for an earlier chunk.
+const maxHashReorderBytes = 64 * 1024 * 1024
+
 // NewStreamingHashState creates a new streaming hash state for an upload session
 func NewStreamingHashState(sessionID string) *StreamingHashState {
 	return &StreamingHashState{
@@ -34,6 +52,66 @@ func (s *StreamingHashState) WriteChunk(data []byte) ([]byte, error) {
 	return data, nil
 }
 
+// WriteChunkAt adds chunk chunkNumber to the running hash. A chunk that
+// arrives early is held until every chunk before it has been hashed; data
+// must not be modified afterwards. A chunk that cannot be placed in order
+// marks the state out of order, after which it stops hashing.
+func (s *StreamingHashState) WriteChunkAt(chunkNumber int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outOfOrder {
+		return nil
+	}
+	if chunkNumber > s.nextChunk {
+		if _, dup := s.pending[chunkNumber]; dup || s.pendingBytes+int64(len(data)) > maxHashReorderBytes {
+			s.markOutOfOrder()
+			return nil
+		}
+		if s.pending == nil {
+			s.pending = make(map[int][]byte)
+		}
+		s.pending[chunkNumber] = data
+		s.pendingBytes += int64(len(data))
+		return nil
+	}
+	if chunkNumber < s.nextChunk {
+		s.markOutOfOrder()
+		return nil
+	}
+
+	for {
+		n, err := s.hash.Write(data)
+		if err != nil {
+			return err
+		}
+		s.totalBytes += int64(n)
+		s.nextChunk++
+
+		next, ok := s.pending[s.nextChunk]
+		if !ok {
+			return nil
+		}
+		delete(s.pending, s.nextChunk)
+		s.pendingBytes -= int64(len(next))
+		data = next
+	}
+}
+
+func (s *StreamingHashState) markOutOfOrder() {
+	s.outOfOrder = true
+	s.pending = nil
+	s.pendingBytes = 0
+}
+
+// CoversChunks reports whether the running hash covers chunks 0..totalChunks-1
+// exactly once and in order.
+func (s *StreamingHashState) CoversChunks(totalChunks int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.outOfOrder && s.nextChunk == totalChunks
+}
+
 // FinalizeHash completes the hash calculation and returns the final SHA256 hex string
 func (s *StreamingHashState) FinalizeHash() string {
 	return hex.EncodeToString(s.hash.Sum(nil))
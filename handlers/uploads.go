@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	cryptoRand "crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -35,6 +36,72 @@ var (
 	hashStateMutex       sync.RWMutex
 )
 
+// uploadHashStates returns the running hash states of an upload session,
+// creating them if this process has not seen the session yet.
+func uploadHashStates(sessionID string) (*StreamingHashState, *StreamingHashState) {
+	hashStateMutex.Lock()
+	defer hashStateMutex.Unlock()
+
+	hashState, exists := streamingHashStates[sessionID]
+	if !exists {
+		hashState = NewStreamingHashState(sessionID)
+		streamingHashStates[sessionID] = hashState
+		logging.InfoLogger.Printf("Initialized streaming hash state for session %s", sessionID)
+	}
+	blobHashState, exists := storedBlobHashStates[sessionID]
+	if !exists {
+		blobHashState = NewStreamingHashState(sessionID)
+		storedBlobHashStates[sessionID] = blobHashState
+	}
+	return hashState, blobHashState
+}
+
+// rehashStoredUpload computes the encrypted data hash and the stored blob hash
+// of a completed multipart upload by reading the assembled object back from
+// its provider. Used when the running hashes do not cover every chunk exactly
+// once in order, as happens when an upload is resumed. The first
+// encryptedSize bytes are the encrypted data; the rest is padding.
+func rehashStoredUpload(ctx context.Context, provider storage.ObjectStorageProvider, objectName string, encryptedSize int64) (string, string, error) {
+	obj, err := provider.GetObject(ctx, objectName, storage.GetObjectOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	defer obj.Close()
+
+	dataHash := sha256.New()
+	blobHash := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(dataHash, blobHash), obj, encryptedSize); err != nil {
+		return "", "", fmt.Errorf("failed to read encrypted data: %w", err)
+	}
+	if _, err := io.Copy(blobHash, obj); err != nil {
+		return "", "", fmt.Errorf("failed to read padding: %w", err)
+	}
+	return hex.EncodeToString(dataHash.Sum(nil)), hex.EncodeToString(blobHash.Sum(nil)), nil
+}
+
+// removeUnrecordedCopies deletes the replicas and erasure shards that
+// CompleteUpload wrote before its metadata transaction, when that transaction
+// did not commit and so no storage location row points at them.
+func removeUnrecordedCopies(storageID string, replicas []storage.ReplicaResult, layout *storage.ErasureLayout) {
+	var locations []storage.RemoveLocation
+	for _, replica := range replicas {
+		locations = append(locations, storage.RemoveLocation{ProviderID: replica.ProviderID, StorageID: storageID})
+	}
+	if layout != nil {
+		for _, shard := range layout.Shards {
+			locations = append(locations, storage.RemoveLocation{ProviderID: shard.ProviderID, StorageID: shard.ObjectName})
+		}
+	}
+	if len(locations) == 0 {
+		return
+	}
+	for _, result := range storage.Registry.RemoveObjectAll(context.Background(), locations) {
+		if !result.Success {
+			logging.ErrorLogger.Printf("CompleteUpload: failed to remove unrecorded copy of %s from %s: %v", storageID, result.ProviderID, result.Error)
+		}
+	}
+}
+
 // Per-user cap on concurrent in-progress upload sessions. A buggy or hostile
 // client can otherwise open arbitrary numbers of init'd-but-never-completed
 // sessions, occupying storage they have not yet finalized and starving
@@ -58,8 +125,14 @@ const maxPaddingPerChunk = 16 * 1024 * 1024 // 16 MiB
 // the server returns HTTP 409 with stable error code "file_id_conflict";
 // clients retry up to 3 times with a freshly minted UUID.
 func CreateUploadSession(c echo.Context) error {
-	username := auth.GetUsernameFromToken(c)
+	return createUploadSession(c, auth.GetUsernameFromToken(c), nil)
+}
 
+// createUploadSession initializes a chunked upload into username's vault.
+// With a non-nil drop the upload arrives through that file drop: the FEK
+// must be sealed to the drop's key, and the drop's quota replaces the
+// per-user in-progress cap.
+func createUploadSession(c echo.Context, username string, drop *fileDrop) error {
 	var request struct {
 		// Client-supplied UUIDv4
 		FileID string `json:"file_id"`
@@ -71,6 +144,15 @@ func CreateUploadSession(c echo.Context) error {
 		Sha256sumNonce     string `json:"sha256sum_nonce"`
 		EncryptedFek       string `json:"encrypted_fek"`
 
+		// Optional folder path, encrypted like the filename. Omitted (or
+		// empty) for files at the top level.
+		EncryptedFolder string `json:"encrypted_folder"`
+		FolderNonce     string `json:"folder_nonce"`
+
+		// Optional file_id of an existing file (any of its versions) that
+		// this upload becomes the next version of.
+		VersionOf string `json:"version_of"`
+
 		TotalSize    int64  `json:"total_size"`
 		ChunkSize    int    `json:"chunk_size"`
 		PasswordHint string `json:"password_hint"`
@@ -113,9 +195,19 @@ func CreateUploadSession(c echo.Context) error {
 		return JSONErrorCode(c, http.StatusBadRequest, "missing_encrypted_fek",
 			"Missing encrypted FEK envelope")
 	}
+	if (request.EncryptedFolder == "") != (request.FolderNonce == "") {
+		return JSONErrorCode(c, http.StatusBadRequest, "invalid_encrypted_folder",
+			"Encrypted folder and folder nonce must be sent together")
+	}
 
 	// Validate password type
-	if request.PasswordType != "account" && request.PasswordType != "custom" {
+	if drop != nil {
+		if httpErr := validateDropUploadRequest(drop, request.PasswordType, request.EncryptedFek,
+			request.VersionOf, request.EncryptedFolder, request.TotalSize); httpErr != nil {
+			return httpErr
+		}
+		request.PasswordHint = ""
+	} else if request.PasswordType != "account" && request.PasswordType != "custom" {
 		return JSONErrorCode(c, http.StatusBadRequest, "invalid_password_type",
 			"Invalid password type")
 	}
@@ -145,6 +237,34 @@ func CreateUploadSession(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Storage limit would be exceeded")
 	}
 
+	// A new version joins the chain of the file it replaces. Prior versions
+	// keep counting toward the quota checked above.
+	var versionGroup sql.NullString
+	if request.VersionOf != "" {
+		target, err := models.GetFileByFileID(database.DB, request.VersionOf)
+		if err != nil && err.Error() != "file not found" {
+			logging.ErrorLogger.Printf("Failed to look up version target %s: %v", request.VersionOf, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to look up file")
+		}
+		if err != nil || target.OwnerUsername != username {
+			return JSONErrorCode(c, http.StatusNotFound, "version_target_not_found",
+				"The file to add a version to was not found")
+		}
+		versionGroup = sql.NullString{String: target.VersionGroupID(), Valid: true}
+	}
+
+	// Choose the provider that receives the multipart upload. Users with a
+	// storage placement policy land on the first available provider of their
+	// policy; if the policy cannot be met the upload is refused rather than
+	// stored outside it.
+	_, landingID, _, err := resolveUploadPlacement(username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Storage placement unavailable for user %s: %v", username, err)
+		return JSONErrorCode(c, http.StatusServiceUnavailable, "storage_placement_unavailable",
+			"The storage providers required by your account's placement policy are currently unavailable")
+	}
+	landingProvider := storage.Registry.GetProvider(landingID)
+
 	// Create upload session - with safe chunk size validation.
 	// fileID is the client-supplied UUIDv4 validated above; sessionID is
 	// the server-side identifier for this specific upload attempt.
@@ -203,27 +323,35 @@ func CreateUploadSession(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to clean up stale upload sessions")
 	}
 
-	var inProgressCount int
-	if err := tx.QueryRow(
-		`SELECT COUNT(*) FROM upload_sessions WHERE owner_username = ? AND status = 'in_progress'`,
-		username,
-	).Scan(&inProgressCount); err != nil {
-		logging.ErrorLogger.Printf("Failed to count in-progress upload sessions for user %s: %v", username, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify upload session capacity")
-	}
-	if inProgressCount >= maxInProgressUploadSessionsPerUser {
-		logging.InfoLogger.Printf("User %s blocked at upload session cap (%d in-progress, max %d)",
-			username, inProgressCount, maxInProgressUploadSessionsPerUser)
-		// Stable error code 'too_many_in_progress_uploads' lets clients switch
-		// on a code rather than parsing English. Aligned with the standing
-		// pattern in docs/wip/general-enhancements.md item 9.
-		return JSONErrorCodeData(c, http.StatusTooManyRequests,
-			"too_many_in_progress_uploads",
-			fmt.Sprintf("You have %d upload(s) already in progress (max %d). Cancel one or wait for it to complete or expire.", inProgressCount, maxInProgressUploadSessionsPerUser),
-			map[string]interface{}{
-				"in_progress_count": inProgressCount,
-				"max_in_progress":   maxInProgressUploadSessionsPerUser,
-			})
+	// Drop uploads are limited by the drop's quota instead; the owner's own
+	// uploads do not count against it and drop uploads do not count here.
+	if drop != nil {
+		if httpErr := checkDropCapacity(tx, drop, request.TotalSize); httpErr != nil {
+			return httpErr
+		}
+	} else {
+		var inProgressCount int
+		if err := tx.QueryRow(
+			`SELECT COUNT(*) FROM upload_sessions WHERE owner_username = ? AND status = 'in_progress' AND id NOT IN (SELECT session_id FROM file_drop_uploads)`,
+			username,
+		).Scan(&inProgressCount); err != nil {
+			logging.ErrorLogger.Printf("Failed to count in-progress upload sessions for user %s: %v", username, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify upload session capacity")
+		}
+		if inProgressCount >= maxInProgressUploadSessionsPerUser {
+			logging.InfoLogger.Printf("User %s blocked at upload session cap (%d in-progress, max %d)",
+				username, inProgressCount, maxInProgressUploadSessionsPerUser)
+			// Stable error code 'too_many_in_progress_uploads' lets clients switch
+			// on a code rather than parsing English. Aligned with the standing
+			// pattern in docs/wip/general-enhancements.md item 9.
+			return JSONErrorCodeData(c, http.StatusTooManyRequests,
+				"too_many_in_progress_uploads",
+				fmt.Sprintf("You have %d upload(s) already in progress (max %d). Cancel one or wait for it to complete or expire.", inProgressCount, maxInProgressUploadSessionsPerUser),
+				map[string]interface{}{
+					"in_progress_count": inProgressCount,
+					"max_in_progress":   maxInProgressUploadSessionsPerUser,
+				})
+		}
 	}
 
 	// global file_id uniqueness pre-check, inside the same
@@ -273,6 +401,11 @@ func CreateUploadSession(c echo.Context) error {
 	encryptedSha256sum := request.EncryptedSha256sum
 	sha256sumNonce := request.Sha256sumNonce
 	encryptedFek := request.EncryptedFek
+	var encryptedFolder, folderNonce sql.NullString
+	if request.EncryptedFolder != "" {
+		encryptedFolder = sql.NullString{String: request.EncryptedFolder, Valid: true}
+		folderNonce = sql.NullString{String: request.FolderNonce, Valid: true}
+	}
 
 	// Note: Encrypted metadata values arrive from client already base64-encoded.
 	// Do NOT re-encode them — store as-is to prevent double-encoding.
@@ -282,8 +415,8 @@ func CreateUploadSession(c echo.Context) error {
 	// pre-check above and this INSERT, surface the same stable
 	// file_id_conflict code so the client can retry uniformly.
 	_, err = tx.Exec(
-		"INSERT INTO upload_sessions (id, file_id, encrypted_filename, filename_nonce, encrypted_sha256sum, sha256sum_nonce, encrypted_fek, owner_username, total_size, chunk_size, total_chunks, password_hint, password_type, storage_id, padded_size, status, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		sessionID, fileID, encryptedFilename, filenameNonce, encryptedSha256sum, sha256sumNonce, encryptedFek, username, request.TotalSize, request.ChunkSize, totalChunks, request.PasswordHint, request.PasswordType, storageID, paddedSize, "in_progress", time.Now().Add(24*time.Hour),
+		"INSERT INTO upload_sessions (id, file_id, encrypted_filename, filename_nonce, encrypted_sha256sum, sha256sum_nonce, encrypted_folder, folder_nonce, version_group, encrypted_fek, owner_username, total_size, chunk_size, total_chunks, password_hint, password_type, storage_id, provider_id, padded_size, status, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sessionID, fileID, encryptedFilename, filenameNonce, encryptedSha256sum, sha256sumNonce, encryptedFolder, folderNonce, versionGroup, encryptedFek, username, request.TotalSize, request.ChunkSize, totalChunks, request.PasswordHint, request.PasswordType, storageID, landingID, paddedSize, "in_progress", time.Now().Add(24*time.Hour),
 	)
 	if err != nil {
 		if isUniqueConstraintFileID(err) {
@@ -293,13 +426,22 @@ func CreateUploadSession(c echo.Context) error {
 		logging.ErrorLogger.Printf("Failed to insert upload_sessions row for file_id %s: %v", fileID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload session")
 	}
+	if drop != nil {
+		if _, err := tx.Exec(
+			`INSERT INTO file_drop_uploads (session_id, drop_id) VALUES (?, ?)`,
+			sessionID, drop.DropID,
+		); err != nil {
+			logging.ErrorLogger.Printf("Failed to record drop upload for file_id %s: %v", fileID, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload session")
+		}
+	}
 
 	// Initialize multipart upload in storage with no identifying metadata
 	metadata := map[string]string{}
 
-	uploadID, err := storage.Registry.Primary().InitiateMultipartUpload(c.Request().Context(), storageID, metadata)
+	uploadID, err := landingProvider.InitiateMultipartUpload(c.Request().Context(), storageID, metadata)
 	if err != nil {
-		logging.ErrorLogger.Printf("Failed to initiate multipart upload for file_id %s (storage_id: %s) via provider: %v", fileID, storageID, err)
+		logging.ErrorLogger.Printf("Failed to initiate multipart upload for file_id %s (storage_id: %s) via provider %s: %v", fileID, storageID, landingID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to initialize storage upload")
 	}
 
@@ -310,14 +452,14 @@ func CreateUploadSession(c echo.Context) error {
 	)
 	if err != nil {
 		// Abort the multipart upload if we can't update the database
-		storage.Registry.Primary().AbortMultipartUpload(c.Request().Context(), storageID, uploadID)
+		landingProvider.AbortMultipartUpload(c.Request().Context(), storageID, uploadID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update upload session")
 	}
 
 	if err := tx.Commit(); err != nil {
 		// Attempt to abort the storage upload if we can't commit
 		if uploadID != "" {
-			storage.Registry.Primary().AbortMultipartUpload(c.Request().Context(), storageID, uploadID)
+			landingProvider.AbortMultipartUpload(c.Request().Context(), storageID, uploadID)
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to commit transaction")
 	}
@@ -345,13 +487,14 @@ func CancelUpload(c echo.Context) error {
 		fileID          string
 		storageID       string
 		storageUploadID string
+		providerID      sql.NullString
 		status          string
 	)
 
 	err := database.DB.QueryRow(
-		"SELECT owner_username, file_id, storage_id, storage_upload_id, status FROM upload_sessions WHERE id = ?",
+		"SELECT owner_username, file_id, storage_id, storage_upload_id, provider_id, status FROM upload_sessions WHERE id = ?",
 		sessionID,
-	).Scan(&ownerUsername, &fileID, &storageID, &storageUploadID, &status)
+	).Scan(&ownerUsername, &fileID, &storageID, &storageUploadID, &providerID, &status)
 
 	if err == sql.ErrNoRows {
 		return echo.NewHTTPError(http.StatusNotFound, "Upload session not found")
@@ -388,7 +531,11 @@ func CancelUpload(c echo.Context) error {
 
 	// Abort the multipart upload in storage using storage provider interface
 	if storageUploadID != "" && storageID != "" {
-		err = storage.Registry.Primary().AbortMultipartUpload(c.Request().Context(), storageID, storageUploadID)
+		if _, provider := uploadSessionProvider(providerID.String); provider == nil {
+			err = fmt.Errorf("provider %s is not configured", providerID.String)
+		} else {
+			err = provider.AbortMultipartUpload(c.Request().Context(), storageID, storageUploadID)
+		}
 		if err != nil {
 			logging.ErrorLogger.Printf("Failed to abort storage upload via storage provider: %v", err)
 			// Continue anyway - we still want to mark the session as canceled in the database
@@ -523,7 +670,11 @@ func GetUploadStatus(c echo.Context) error {
 
 // UploadChunk handles individual chunk uploads
 func UploadChunk(c echo.Context) error {
-	username := auth.GetUsernameFromToken(c)
+	return uploadSessionChunk(c, auth.GetUsernameFromToken(c))
+}
+
+// uploadSessionChunk stores one chunk of an upload session owned by username.
+func uploadSessionChunk(c echo.Context, username string) error {
 	sessionID := c.Param("sessionId")
 	chunkNumberStr := c.Param("chunkNumber")
 
@@ -540,6 +691,7 @@ func UploadChunk(c echo.Context) error {
 		fileID          string
 		storageID       string
 		storageUploadID sql.NullString
+		providerID      sql.NullString
 		status          string
 		totalChunks     int
 		totalSizeRaw    interface{}
@@ -547,9 +699,9 @@ func UploadChunk(c echo.Context) error {
 	)
 
 	err = database.DB.QueryRow(
-		"SELECT owner_username, file_id, storage_id, storage_upload_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = ?",
+		"SELECT owner_username, file_id, storage_id, storage_upload_id, provider_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = ?",
 		sessionID,
-	).Scan(&ownerUsername, &fileID, &storageID, &storageUploadID, &status, &totalChunks, &totalSizeRaw, &paddedSizeRaw)
+	).Scan(&ownerUsername, &fileID, &storageID, &storageUploadID, &providerID, &status, &totalChunks, &totalSizeRaw, &paddedSizeRaw)
 
 	if err == sql.ErrNoRows {
 		return echo.NewHTTPError(http.StatusNotFound, "Upload session not found")
@@ -657,23 +809,6 @@ func UploadChunk(c echo.Context) error {
 			"Chunk hash does not match uploaded chunk bytes")
 	}
 
-	// Get or create streaming hash state for this session
-	hashStateMutex.Lock()
-	hashState, exists := streamingHashStates[sessionID]
-	if !exists {
-		hashState = NewStreamingHashState(sessionID)
-		streamingHashStates[sessionID] = hashState
-		logging.InfoLogger.Printf("Initialized streaming hash state for session %s", sessionID)
-	}
-	hashStateMutex.Unlock()
-
-	// Add this chunk to the running hash calculation (hash only real encrypted data, not padding)
-	_, err = hashState.WriteChunk(chunkData)
-	if err != nil {
-		logging.ErrorLogger.Printf("Failed to update streaming hash for session %s, chunk %d: %v", sessionID, chunkNumber, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to calculate streaming hash")
-	}
-
 	// For the last chunk, append crypto-random padding bytes to obscure file size
 	// in the storage backend. Padding is appended AFTER hashing so the streaming
 	// hash covers only the real encrypted data. The combined (chunk + padding) is
@@ -695,28 +830,18 @@ func UploadChunk(c echo.Context) error {
 			chunkNumber, paddingSize, totalSize, paddedSize)
 	}
 
-	// Update stored blob hash with ALL data sent to S3 (including padding on last chunk).
-	// For non-last chunks uploadData == chunkData, so both hashes see the same bytes.
-	// For the last chunk, this hash additionally includes the padding bytes.
-	hashStateMutex.Lock()
-	blobHashState, blobExists := storedBlobHashStates[sessionID]
-	if !blobExists {
-		blobHashState = NewStreamingHashState(sessionID)
-		storedBlobHashStates[sessionID] = blobHashState
-	}
-	hashStateMutex.Unlock()
-
-	if _, err := blobHashState.WriteChunk(uploadData); err != nil {
-		logging.ErrorLogger.Printf("Failed to update stored blob hash for session %s, chunk %d: %v", sessionID, chunkNumber, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to calculate stored blob hash")
-	}
-
 	// Create a seekable reader from the upload data for S3
 	chunkReader := bytes.NewReader(uploadData)
 
+	landingID, landingProvider := uploadSessionProvider(providerID.String)
+	if landingProvider == nil {
+		logging.ErrorLogger.Printf("Upload session %s targets provider %s, which is not configured", sessionID, landingID)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Storage provider for this upload is unavailable")
+	}
+
 	var etag string
 	if storageUploadID.Valid && storageUploadID.String != "" {
-		part, err := storage.Registry.Primary().UploadPart(
+		part, err := landingProvider.UploadPart(
 			c.Request().Context(),
 			storageID,
 			storageUploadID.String,
@@ -747,6 +872,22 @@ func UploadChunk(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record chunk metadata")
 	}
 
+	// Add the chunk to the session's running hashes only once it is stored and
+	// recorded, so a chunk that failed part-way and is sent again (after a
+	// client restart, for example) is never hashed twice. The encrypted data
+	// hash covers only real encrypted data; the stored blob hash also covers
+	// the padding appended to the last chunk. For non-last chunks uploadData
+	// == chunkData, so both hashes see the same bytes.
+	hashState, blobHashState := uploadHashStates(sessionID)
+	if err := hashState.WriteChunkAt(chunkNumber, chunkData); err != nil {
+		logging.ErrorLogger.Printf("Failed to update streaming hash for session %s, chunk %d: %v", sessionID, chunkNumber, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to calculate streaming hash")
+	}
+	if err := blobHashState.WriteChunkAt(chunkNumber, uploadData); err != nil {
+		logging.ErrorLogger.Printf("Failed to update stored blob hash for session %s, chunk %d: %v", sessionID, chunkNumber, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to calculate stored blob hash")
+	}
+
 	logging.InfoLogger.Printf("Chunk uploaded: %s, file_id: %s, chunk: %d/%d",
 		sessionID, fileID, chunkNumber+1, totalChunks)
 
@@ -758,7 +899,13 @@ func UploadChunk(c echo.Context) error {
 
 // CompleteUpload finalizes a chunked upload
 func CompleteUpload(c echo.Context) error {
-	username := auth.GetUsernameFromToken(c)
+	return completeUploadSession(c, auth.GetUsernameFromToken(c), "")
+}
+
+// completeUploadSession finalizes an upload session owned by username. For
+// an upload through a file drop, dropID is the drop; the activity log names
+// it and the response leaves out the owner's storage figures.
+func completeUploadSession(c echo.Context, username, dropID string) error {
 	sessionID := c.Param("sessionId")
 
 	logging.InfoLogger.Printf("Attempting to complete upload for sessionID: '%s'", sessionID)
@@ -772,6 +919,7 @@ func CompleteUpload(c echo.Context) error {
 		fileID          sql.NullString
 		storageID       sql.NullString
 		storageUploadID sql.NullString
+		providerID      sql.NullString
 		status          string
 		totalChunks     int
 		passwordHint    sql.NullString
@@ -789,17 +937,19 @@ func CompleteUpload(c echo.Context) error {
 	var encryptedSha256sumBytes []byte
 	var sha256sumNonceBytes []byte
 	var encryptedFekBytes []byte
+	var encryptedFolder, folderNonce, versionGroup sql.NullString
 
 	err := database.DB.QueryRow(
-		`SELECT owner_username, file_id, storage_id, storage_upload_id, status, total_chunks,
+		`SELECT owner_username, file_id, storage_id, storage_upload_id, provider_id, status, total_chunks,
                 total_size, chunk_size, padded_size, password_hint, password_type, encrypted_filename, filename_nonce,
-                encrypted_sha256sum, sha256sum_nonce, encrypted_fek
+                encrypted_sha256sum, sha256sum_nonce, encrypted_fek, encrypted_folder, folder_nonce, version_group
          FROM upload_sessions WHERE id = ?`,
 		sessionID,
 	).Scan(
-		&ownerUsername, &fileID, &storageID, &storageUploadID, &status, &totalChunks,
+		&ownerUsername, &fileID, &storageID, &storageUploadID, &providerID, &status, &totalChunks,
 		&totalSizeRaw, &chunkSizeRaw, &paddedSizeRaw, &passwordHint, &passwordType,
 		&encryptedFilenameBytes, &filenameNonceBytes, &encryptedSha256sumBytes, &sha256sumNonceBytes, &encryptedFekBytes,
+		&encryptedFolder, &folderNonce, &versionGroup,
 	)
 
 	if err == sql.ErrNoRows {
@@ -899,44 +1049,102 @@ func CompleteUpload(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Error reading chunk data")
 	}
 
-	// Step 4: Get the streaming hashes calculated during chunk uploads
-	hashStateMutex.RLock()
+	// Step 4: Get the streaming hashes calculated during chunk uploads. They
+	// are only usable when they cover every chunk once and in order; a resumed
+	// upload is re-hashed from storage after the multipart upload completes.
+	hashStateMutex.Lock()
 	hashState, hashExists := streamingHashStates[sessionID]
 	blobHashState, blobHashExists := storedBlobHashStates[sessionID]
-	hashStateMutex.RUnlock()
+	delete(streamingHashStates, sessionID)
+	delete(storedBlobHashStates, sessionID)
+	hashStateMutex.Unlock()
 
-	var serverCalculatedHash string
-	if hashExists {
+	var serverCalculatedHash, storedBlobHash string
+	if hashExists && blobHashExists && hashState.CoversChunks(totalChunks) && blobHashState.CoversChunks(totalChunks) {
 		serverCalculatedHash = hashState.FinalizeHash()
-		logging.InfoLogger.Printf("Encrypted data hash for session %s: %s", sessionID, serverCalculatedHash)
-	} else {
-		logging.ErrorLogger.Printf("No streaming hash state found for session %s", sessionID)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Hash calculation failed - no streaming state found")
-	}
-
-	var storedBlobHash string
-	if blobHashExists {
 		storedBlobHash = blobHashState.FinalizeHash()
+		logging.InfoLogger.Printf("Encrypted data hash for session %s: %s", sessionID, serverCalculatedHash)
 		logging.InfoLogger.Printf("Stored blob hash for session %s: %s", sessionID, storedBlobHash)
 	} else {
-		logging.ErrorLogger.Printf("No stored blob hash state found for session %s", sessionID)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Blob hash calculation failed - no streaming state found")
+		logging.InfoLogger.Printf("Streaming hashes for session %s do not cover every chunk in order; re-hashing from storage", sessionID)
 	}
 
-	// Clean up both hash states since upload is completing
-	hashStateMutex.Lock()
-	delete(streamingHashStates, sessionID)
-	delete(storedBlobHashStates, sessionID)
-	hashStateMutex.Unlock()
-
-	// Step 5: Complete the multipart upload in storage.
+	// Step 5: Complete the multipart upload in storage on the provider chosen
+	// when the session was created.
 	// Padding was already appended to the last chunk during UploadChunk,
 	// so no separate padding part is needed here.
-	err = storage.Registry.Primary().CompleteMultipartUpload(c.Request().Context(), storageID.String, storageUploadID.String, parts)
+	landingID, landingProvider := uploadSessionProvider(providerID.String)
+	if landingProvider == nil {
+		logging.ErrorLogger.Printf("CompleteUpload: session %s targets provider %s, which is not configured", sessionID, landingID)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Storage provider for this upload is unavailable")
+	}
+	err = landingProvider.CompleteMultipartUpload(c.Request().Context(), storageID.String, storageUploadID.String, parts)
 	if err != nil {
 		logging.ErrorLogger.Printf("Failed to complete storage upload via storage provider: %v", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to complete storage upload: %v", err))
 	}
+	if storedBlobHash == "" {
+		serverCalculatedHash, storedBlobHash, err = rehashStoredUpload(c.Request().Context(), landingProvider, storageID.String, declaredSize)
+		if err != nil {
+			logging.ErrorLogger.Printf("CompleteUpload: failed to re-hash object %s for session %s: %v", storageID.String, sessionID, err)
+			if rmErr := landingProvider.RemoveObject(context.Background(), storageID.String, storage.RemoveObjectOptions{}); rmErr != nil {
+				logging.ErrorLogger.Printf("CompleteUpload: failed to remove object %s from %s after re-hash failure: %v", storageID.String, landingID, rmErr)
+			}
+			database.DB.Exec("UPDATE upload_sessions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", "failed", sessionID)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash uploaded file")
+		}
+		logging.InfoLogger.Printf("Re-hashed session %s from storage: encrypted data %s, stored blob %s", sessionID, serverCalculatedHash, storedBlobHash)
+	}
+
+	// Step 5b: Copy the blob to enough replica providers (hash-verified against
+	// the stored blob hash) before anything is recorded, so the file is never
+	// visible or acknowledged with fewer copies than required: the copy count
+	// of the owner's placement policy, restricted to the policy's providers, or
+	// otherwise the quorum write policy. Under the erasure write policy the blob
+	// is instead striped into shards across the providers, and the landed copy
+	// is removed once the shards are recorded. On failure the landed object is
+	// removed and the session is failed; the client must restart the upload.
+	required := storage.Registry.RequiredWriteCopies()
+	replicaTargets := storage.Registry.ConfiguredProviderIDs()
+	policy, err := models.GetUserPlacementPolicy(database.DB, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("CompleteUpload: failed to load placement policy for %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load storage placement policy")
+	}
+	if policy != nil {
+		required = policy.Copies
+		replicaTargets = placementProviders(policy)
+		if !policy.Allows(landingID) {
+			// The policy changed while the upload was in progress
+			err = fmt.Errorf("landing provider %s is not allowed by placement policy %s", landingID, policy.Name)
+			required = 0
+		}
+	}
+	var replicas []storage.ReplicaResult
+	var layout *storage.ErasureLayout
+	if err == nil && policy == nil && storage.Registry.WritePolicy() == storage.WritePolicyErasure {
+		layout, err = storage.Registry.StripeObject(c.Request().Context(), landingID, storageID.String, paddedSize, storedBlobHash)
+	} else if err == nil && required > 1 {
+		replicas, err = storage.Registry.ReplicateObjectFrom(c.Request().Context(), landingID, replicaTargets, storageID.String, paddedSize, storedBlobHash, required-1)
+	}
+	if err != nil {
+		logging.ErrorLogger.Printf("CompleteUpload: session %s failed write quorum: %v", sessionID, err)
+		if rmErr := landingProvider.RemoveObject(context.Background(), storageID.String, storage.RemoveObjectOptions{}); rmErr != nil {
+			logging.ErrorLogger.Printf("CompleteUpload: failed to remove object %s from %s after quorum failure: %v", storageID.String, landingID, rmErr)
+		}
+		database.DB.Exec("UPDATE upload_sessions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", "failed", sessionID)
+		return JSONErrorCode(c, http.StatusServiceUnavailable, "storage_quorum_not_met",
+			"Upload could not be stored on the required number of storage providers; please retry the upload")
+	}
+
+	// Nothing points at the replicas or shards until the transaction below
+	// commits, so remove them again if it does not.
+	recorded := false
+	defer func() {
+		if !recorded {
+			removeUnrecordedCopies(storageID.String, replicas, layout)
+		}
+	}()
 
 	// Step 6: Begin the final, short-lived transaction now that I/O is complete.
 	tx, err := database.DB.Begin()
@@ -980,7 +1188,7 @@ func CompleteUpload(c echo.Context) error {
 			"Upload size mismatch for session %s: expected padded size %d bytes, server stored %d bytes",
 			sessionID, paddedSize, actualStoredSize,
 		)
-		storage.Registry.Primary().RemoveObject(c.Request().Context(), storageID.String, storage.RemoveObjectOptions{})
+		landingProvider.RemoveObject(c.Request().Context(), storageID.String, storage.RemoveObjectOptions{})
 		return echo.NewHTTPError(http.StatusBadRequest, "Upload size mismatch: stored size does not match expected padded size")
 	}
 
@@ -995,10 +1203,21 @@ func CompleteUpload(c echo.Context) error {
 	// padded_size = paddedSize (the actual S3 object size, includes crypto-random padding appended to the last chunk).
 	// encrypted_file_sha256sum = hash of encrypted data only (pre-padding).
 	// stored_blob_sha256sum = hash of all bytes stored in S3 (encrypted data + padding).
+	// A new version takes the next number in its chain, numbered inside this
+	// transaction so concurrent uploads of the same file cannot collide.
+	var versionNumber int64 = 1
+	if versionGroup.Valid {
+		versionNumber, err = models.NextFileVersion(tx, username, versionGroup.String)
+		if err != nil {
+			logging.ErrorLogger.Printf("CompleteUpload: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record file version")
+		}
+	}
+
 	_, err = tx.Exec(`
-		INSERT INTO file_metadata (file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, encrypted_file_sha256sum, stored_blob_sha256sum, encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		fileID.String, storageID.String, username, passwordHint.String, passwordType.String, filenameNonce, encryptedFilename, sha256sumNonce, encryptedSha256sum, serverCalculatedHash, storedBlobHash, encryptedFek, declaredSize, paddedSize, chunkCount, chunkSizeBytes,
+		INSERT INTO file_metadata (file_id, storage_id, owner_username, password_hint, password_type, filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, folder_nonce, encrypted_folder, version_group, version_number, encrypted_file_sha256sum, stored_blob_sha256sum, encrypted_fek, size_bytes, padded_size, chunk_count, chunk_size_bytes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fileID.String, storageID.String, username, passwordHint.String, passwordType.String, filenameNonce, encryptedFilename, sha256sumNonce, encryptedSha256sum, folderNonce, encryptedFolder, versionGroup, versionNumber, serverCalculatedHash, storedBlobHash, encryptedFek, declaredSize, paddedSize, chunkCount, chunkSizeBytes,
 	)
 	if err != nil {
 		if isUniqueConstraintFileID(err) {
@@ -1011,16 +1230,36 @@ func CompleteUpload(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create file metadata")
 	}
 
-	// Record the file's storage location on the primary provider.
-	if err := models.InsertFileStorageLocation(tx, fileID.String, storage.Registry.PrimaryID(), storageID.String, "active"); err != nil {
-		logging.ErrorLogger.Printf("Failed to insert file_storage_location for file %s on provider %s: %v", fileID.String, storage.Registry.PrimaryID(), err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record storage location")
+	if layout != nil {
+		// Erasure-coded: the shards are the file's only storage
+		if err := recordErasureShards(tx, fileID.String, storageID.String, layout); err != nil {
+			logging.ErrorLogger.Printf("Failed to record erasure shards for file %s: %v", fileID.String, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record storage location")
+		}
+	} else {
+		// Record the file's storage location on the provider it landed on.
+		if err := models.InsertFileStorageLocation(tx, fileID.String, landingID, storageID.String, "active"); err != nil {
+			logging.ErrorLogger.Printf("Failed to insert file_storage_location for file %s on provider %s: %v", fileID.String, landingID, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record storage location")
+		}
+
+		// Update the landing provider's cached object count and total size.
+		if err := models.IncrementStorageProviderStats(tx, landingID, 1, paddedSize); err != nil {
+			logging.ErrorLogger.Printf("Failed to update provider stats for %s: %v", landingID, err)
+			// Non-fatal: stats can be recalculated later, don't block the upload
+		}
 	}
 
-	// Update the primary provider's cached object count and total size.
-	if err := models.IncrementStorageProviderStats(tx, storage.Registry.PrimaryID(), 1, paddedSize); err != nil {
-		logging.ErrorLogger.Printf("Failed to update provider stats for %s: %v", storage.Registry.PrimaryID(), err)
-		// Non-fatal: stats can be recalculated later, don't block the upload
+	// Record the verified replicas made above. These rows are committed
+	// together with the landing location and file metadata.
+	for _, replica := range replicas {
+		if err := models.InsertFileStorageLocation(tx, fileID.String, replica.ProviderID, storageID.String, "active"); err != nil {
+			logging.ErrorLogger.Printf("Failed to insert file_storage_location for file %s on provider %s: %v", fileID.String, replica.ProviderID, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record storage location")
+		}
+		if err := models.IncrementStorageProviderStats(tx, replica.ProviderID, 1, paddedSize); err != nil {
+			logging.ErrorLogger.Printf("Failed to update provider stats for %s: %v", replica.ProviderID, err)
+		}
 	}
 
 	// Update user's storage usage with the encrypted data size (not padded).
@@ -1035,20 +1274,55 @@ func CompleteUpload(c echo.Context) error {
 
 	// Commit the transaction.
 	if err := tx.Commit(); err != nil {
+		logging.ErrorLogger.Printf("CompleteUpload: failed to commit session %s: %v", sessionID, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to commit transaction")
 	}
+	recorded = true
 
 	logging.InfoLogger.Printf("Upload completed: %s, file_id: %s (size: %d bytes)", sessionID, fileID.String, actualStoredSize)
-	database.LogUserAction(username, "uploaded", fileID.String)
+	if dropID != "" {
+		database.LogUserAction(username, "received_drop_upload", fmt.Sprintf("%s, drop:%s...", fileID.String, dropID[:8]))
+	} else {
+		database.LogUserAction(username, "uploaded", fileID.String)
+	}
+
+	// The shards are recorded; the full landed copy is no longer needed.
+	if layout != nil {
+		if err := landingProvider.RemoveObject(context.Background(), storageID.String, storage.RemoveObjectOptions{}); err != nil {
+			logging.ErrorLogger.Printf("CompleteUpload: failed to remove striped object %s from %s: %v", storageID.String, landingID, err)
+		}
+	}
 
-	// Background replication to secondary provider (if enabled and configured).
-	// The upload response is returned immediately; replication happens asynchronously.
-	replicateToSecondary(fileID.String, storageID.String, paddedSize)
+	// Under the async-secondary write policy, queue a background copy to the
+	// secondary provider. The upload response is returned immediately. Users
+	// with a placement policy already have all their copies.
+	if policy == nil && storage.Registry.WritePolicy() == storage.WritePolicyAsyncSecondary {
+		replicateToSecondary(fileID.String, storageID.String, paddedSize)
+	}
+
+	// Apply the owner's version retention to the chain this upload extended.
+	if versionGroup.Valid {
+		if pruned := applyVersionRetention(c.Request().Context(), username, versionGroup.String); pruned > 0 {
+			if refreshed, err := models.GetUserByUsername(database.DB, username); err == nil {
+				user = refreshed
+			}
+		}
+	}
+
+	// Senders through a drop learn nothing about the owner's account
+	if dropID != "" {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":               "File uploaded successfully",
+			"file_id":               fileID.String,
+			"encrypted_file_sha256": serverCalculatedHash,
+		})
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message":               "File uploaded successfully",
 		"file_id":               fileID.String,
 		"storage_id":            storageID.String,
+		"version_number":        versionNumber,
 		"encrypted_file_sha256": serverCalculatedHash,
 		"storage": map[string]interface{}{
 			"total_bytes":     user.TotalStorageBytes,
@@ -1081,15 +1355,45 @@ func isUniqueConstraintFileID(err error) bool {
 	return strings.Contains(msg, "unique") && strings.Contains(msg, "file_id")
 }
 
-// DeleteFile handles file deletion across all storage providers
+// DeleteFile handles DELETE /api/files/:fileId
+// Moves the file to the trash (file_trash.go). With ?permanent=true, or when
+// the trash is disabled, the file is deleted from all storage providers
+// immediately; this also works on files already in the trash.
 func DeleteFile(c echo.Context) error {
 	username := auth.GetUsernameFromToken(c)
 	fileID := c.Param("fileId")
 
+	retentionDays := config.GetConfig().Storage.TrashRetentionDays
+	if c.QueryParam("permanent") != "true" && retentionDays > 0 {
+		return trashOwnedFile(c, username, fileID, retentionDays)
+	}
+
+	user, err := deleteOwnedFile(c.Request().Context(), username, fileID)
+	if err != nil {
+		return err
+	}
+
+	database.LogUserAction(username, "deleted", fileID)
+	logging.InfoLogger.Printf("File deleted: file_id=%s", fileID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "File deleted successfully",
+		"storage": map[string]interface{}{
+			"total_bytes":     user.TotalStorageBytes,
+			"limit_bytes":     user.StorageLimitBytes,
+			"available_bytes": user.StorageLimitBytes - user.TotalStorageBytes,
+		},
+	})
+}
+
+// deleteOwnedFile removes one of username's files from every storage
+// provider and from the database, and credits its size back to the user's
+// storage usage. Errors are HTTP errors ready to return from a handler.
+func deleteOwnedFile(ctx context.Context, username, fileID string) (*models.User, error) {
 	// Begin transaction
 	tx, err := database.DB.Begin()
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start transaction")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to start transaction")
 	}
 	defer tx.Rollback()
 
@@ -1115,12 +1419,12 @@ func DeleteFile(c echo.Context) error {
 		if err != sql.ErrNoRows {
 			logging.ErrorLogger.Printf("Database error checking file ownership for deletion: %v", err)
 		}
-		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		return nil, echo.NewHTTPError(http.StatusNotFound, "File not found")
 	}
 
 	// Verify ownership
 	if ownerUsername != username {
-		return echo.NewHTTPError(http.StatusForbidden, "Not authorized to delete this file")
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Not authorized to delete this file")
 	}
 
 	// Query all active storage locations for this file
@@ -1131,7 +1435,16 @@ func DeleteFile(c echo.Context) error {
 		locations = nil
 	}
 
-	if len(locations) > 0 {
+	// Erasure-coded files have shards instead of location records
+	shards, err := models.GetErasureShardsByFileID(database.DB, fileID)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query erasure shards for file %s: %v", fileID, err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file from storage")
+	}
+
+	if len(shards) > 0 {
+		removeErasureShards(ctx, fileID, shards)
+	} else if len(locations) > 0 {
 		// Multi-provider delete via registry
 		removeLocations := make([]storage.RemoveLocation, len(locations))
 		for i, loc := range locations {
@@ -1140,7 +1453,7 @@ func DeleteFile(c echo.Context) error {
 				StorageID:  loc.StorageID,
 			}
 		}
-		results := storage.Registry.RemoveObjectAll(c.Request().Context(), removeLocations)
+		results := storage.Registry.RemoveObjectAll(ctx, removeLocations)
 		for _, result := range results {
 			if result.Success {
 				models.UpdateFileStorageLocationStatus(database.DB, fileID, result.ProviderID, "deleted")
@@ -1154,10 +1467,10 @@ func DeleteFile(c echo.Context) error {
 		}
 	} else {
 		// No location records (pre-existing file or query failed): delete from primary
-		err = storage.Registry.Primary().RemoveObject(c.Request().Context(), storageID, storage.RemoveObjectOptions{})
+		err = storage.Registry.Primary().RemoveObject(ctx, storageID, storage.RemoveObjectOptions{})
 		if err != nil {
 			logging.ErrorLogger.Printf("Failed to remove file from primary storage: %v", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file from storage")
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file from storage")
 		}
 	}
 
@@ -1165,49 +1478,35 @@ func DeleteFile(c echo.Context) error {
 	_, err = tx.Exec("DELETE FROM file_metadata WHERE file_id = ?", fileID)
 	if err != nil {
 		logging.ErrorLogger.Printf("Failed to delete file metadata: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file metadata")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete file metadata")
 	}
 
 	// Update user's storage usage (reduce by encrypted data size, not padded)
 	user, err := models.GetUserByUsername(tx, username)
 	if err != nil {
 		logging.ErrorLogger.Printf("Failed to get user: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update storage usage")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to update storage usage")
 	}
 
 	if err := user.UpdateStorageUsage(tx, -fileSize); err != nil {
 		logging.ErrorLogger.Printf("Failed to update storage usage: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update storage usage")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to update storage usage")
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		logging.ErrorLogger.Printf("Failed to commit transaction: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to complete file deletion")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to complete file deletion")
 	}
 
-	database.LogUserAction(username, "deleted", fileID)
-	logging.InfoLogger.Printf("File deleted: file_id=%s", fileID)
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "File deleted successfully",
-		"storage": map[string]interface{}{
-			"total_bytes":     user.TotalStorageBytes,
-			"limit_bytes":     user.StorageLimitBytes,
-			"available_bytes": user.StorageLimitBytes - user.TotalStorageBytes,
-		},
-	})
+	return user, nil
 }
 
 // replicateToSecondary submits an automatic file copy task to the admin TaskRunner
 // to copy a newly uploaded file from the primary provider to the secondary provider.
-// This is a no-op when ENABLE_UPLOAD_REPLICATION is false or no secondary provider is configured.
+// Used by the async-secondary write policy; a no-op when no secondary provider is configured.
 // It returns immediately, running asynchronously in the task runner's concurrency-controlled queue.
 func replicateToSecondary(fileID, storageID string, paddedSize int64) {
-	cfg, err := config.LoadConfig()
-	if err != nil || !cfg.Storage.EnableUploadReplication {
-		return
-	}
 	if !storage.Registry.HasSecondary() {
 		return
 	}
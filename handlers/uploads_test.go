@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,6 +17,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/storage"
+	"github.com/arkfile/Arkfile/testutil"
 )
 
 // validTestFileID is a canonical lowercase UUIDv4 with RFC4122 variant
@@ -88,6 +93,31 @@ func TestCreateUploadSession_RejectsNonUUIDv4FileID(t *testing.T) {
 	}
 }
 
+// TestCreateUploadSession_RejectsUnpairedFolder verifies that an encrypted
+// folder path is only accepted together with its nonce. Rejection happens
+// before any DB query.
+func TestCreateUploadSession_RejectsUnpairedFolder(t *testing.T) {
+	for _, field := range []string{"encrypted_folder", "folder_nonce"} {
+		t.Run(field, func(t *testing.T) {
+			payload := buildValidInitPayload(validTestFileID)
+			payload[field] = "ZW5jcnlwdGVkLWZvbGRlcg=="
+			body, err := json.Marshal(payload)
+			require.NoError(t, err)
+
+			c, rec, _, _ := setupTestEnv(t, http.MethodPost, "/api/uploads/init", bytes.NewReader(body))
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{Username: "folder-user"})
+			c.Set("user", token)
+
+			require.NoError(t, CreateUploadSession(c))
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp APIResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid_encrypted_folder", resp.Error)
+		})
+	}
+}
+
 // TestCreateUploadSession_FileIDConflictStableError verifies that the server
 // returns HTTP 409 with stable error code "file_id_conflict" when the
 // client-supplied file_id already exists in either file_metadata or
@@ -120,6 +150,11 @@ func TestCreateUploadSession_FileIDConflictStableError(t *testing.T) {
 		mock.ExpectQuery(`SELECT id, username, created_at,\s+total_storage_bytes, storage_limit_bytes,\s+is_approved, approved_by, approved_at, is_admin\s+FROM users WHERE username = \?`).
 			WithArgs(username).WillReturnRows(userRows)
 
+		// Storage placement: no policy assigned, so the upload lands on the primary
+		mock.ExpectQuery(`SELECT placement_policy FROM users WHERE username = \?`).
+			WithArgs(username).
+			WillReturnRows(sqlmock.NewRows([]string{"placement_policy"}).AddRow(nil))
+
 		// BEGIN transaction
 		mock.ExpectBegin()
 
@@ -232,12 +267,12 @@ func TestUploadChunk_EnforcesPaddingCeiling(t *testing.T) {
 
 		// Mock session query return with massive padding: total_size = 100, padded_size = 20 * 1024 * 1024 (20 MiB of padding)
 		rows := sqlmock.NewRows([]string{
-			"owner_username", "file_id", "storage_id", "storage_upload_id", "status", "total_chunks", "total_size", "padded_size",
+			"owner_username", "file_id", "storage_id", "storage_upload_id", "provider_id", "status", "total_chunks", "total_size", "padded_size",
 		}).AddRow(
-			username, "file-id", "storage-id", "upload-id", "in_progress", 1, int64(100), int64(20*1024*1024),
+			username, "file-id", "storage-id", "upload-id", nil, "in_progress", 1, int64(100), int64(20*1024*1024),
 		)
 
-		mock.ExpectQuery(`SELECT owner_username, file_id, storage_id, storage_upload_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = \?`).
+		mock.ExpectQuery(`SELECT owner_username, file_id, storage_id, storage_upload_id, provider_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = \?`).
 			WithArgs(sessionID).
 			WillReturnRows(rows)
 
@@ -281,12 +316,12 @@ func TestUploadChunk_RejectsChunkHashMismatch(t *testing.T) {
 	// Session row with no padding (total_size == padded_size), in_progress,
 	// owned by the calling user, and a valid chunk index range.
 	rows := sqlmock.NewRows([]string{
-		"owner_username", "file_id", "storage_id", "storage_upload_id", "status", "total_chunks", "total_size", "padded_size",
+		"owner_username", "file_id", "storage_id", "storage_upload_id", "provider_id", "status", "total_chunks", "total_size", "padded_size",
 	}).AddRow(
-		username, "file-id", "storage-id", "upload-id", "in_progress", 1, int64(len(chunkBody)), int64(len(chunkBody)),
+		username, "file-id", "storage-id", "upload-id", nil, "in_progress", 1, int64(len(chunkBody)), int64(len(chunkBody)),
 	)
 
-	mock.ExpectQuery(`SELECT owner_username, file_id, storage_id, storage_upload_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = \?`).
+	mock.ExpectQuery(`SELECT owner_username, file_id, storage_id, storage_upload_id, provider_id, status, total_chunks, total_size, padded_size FROM upload_sessions WHERE id = \?`).
 		WithArgs(sessionID).
 		WillReturnRows(rows)
 
@@ -301,3 +336,118 @@ func TestUploadChunk_RejectsChunkHashMismatch(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+// TestStreamingHashState_OutOfOrderChunks verifies that the running upload
+// hash only covers a session when every chunk was hashed once and in order,
+// holding early chunks within the reorder window. A resumed upload whose
+// state was lost starts mid-stream and must fall back to re-hashing the
+// stored object.
+func TestStreamingHashState_OutOfOrderChunks(t *testing.T) {
+	inOrder := NewStreamingHashState("s1")
+	require.NoError(t, inOrder.WriteChunkAt(0, []byte("chunk-0")))
+	require.NoError(t, inOrder.WriteChunkAt(1, []byte("chunk-1")))
+	assert.True(t, inOrder.CoversChunks(2))
+	assert.False(t, inOrder.CoversChunks(3), "a missing trailing chunk is not covered")
+	want := sha256.Sum256([]byte("chunk-0chunk-1"))
+	assert.Equal(t, hex.EncodeToString(want[:]), inOrder.FinalizeHash())
+
+	// Server restarted after chunk 0: the fresh state first sees chunk 1
+	resumed := NewStreamingHashState("s2")
+	require.NoError(t, resumed.WriteChunkAt(1, []byte("chunk-1")))
+	assert.False(t, resumed.CoversChunks(2))
+
+	// Chunks uploaded in parallel arrive slightly out of order and are
+	// held until the gap is filled
+	parallel := NewStreamingHashState("s4")
+	require.NoError(t, parallel.WriteChunkAt(2, []byte("chunk-2")))
+	require.NoError(t, parallel.WriteChunkAt(1, []byte("chunk-1")))
+	assert.False(t, parallel.CoversChunks(3))
+	require.NoError(t, parallel.WriteChunkAt(0, []byte("chunk-0")))
+	assert.True(t, parallel.CoversChunks(3))
+	want = sha256.Sum256([]byte("chunk-0chunk-1chunk-2"))
+	assert.Equal(t, hex.EncodeToString(want[:]), parallel.FinalizeHash())
+
+	// Holding more than the reorder window gives up on the running hash
+	overflow := NewStreamingHashState("s5")
+	require.NoError(t, overflow.WriteChunkAt(1, make([]byte, maxHashReorderBytes)))
+	require.NoError(t, overflow.WriteChunkAt(2, []byte("x")))
+	require.NoError(t, overflow.WriteChunkAt(0, []byte("chunk-0")))
+	assert.False(t, overflow.CoversChunks(3))
+
+	// A chunk sent twice breaks the ordering too
+	repeated := NewStreamingHashState("s3")
+	require.NoError(t, repeated.WriteChunkAt(0, []byte("chunk-0")))
+	require.NoError(t, repeated.WriteChunkAt(0, []byte("chunk-0")))
+	require.NoError(t, repeated.WriteChunkAt(1, []byte("chunk-1")))
+	assert.False(t, repeated.CoversChunks(2))
+}
+
+// TestRehashStoredUpload verifies that a completed upload is re-hashed from
+// storage with the encrypted data hash excluding the trailing padding.
+func TestRehashStoredUpload(t *testing.T) {
+	providers := setupRepairTest(t)
+	encrypted := []byte("encrypted chunk bytes")
+	padding := []byte("random padding")
+	putRepairTestObject(t, providers[0], "blob-resumed", append(append([]byte{}, encrypted...), padding...))
+
+	dataHash, blobHash, err := rehashStoredUpload(context.Background(), providers[0], "blob-resumed", int64(len(encrypted)))
+	require.NoError(t, err)
+	wantData := sha256.Sum256(encrypted)
+	wantBlob := sha256.Sum256(append(append([]byte{}, encrypted...), padding...))
+	assert.Equal(t, hex.EncodeToString(wantData[:]), dataHash)
+	assert.Equal(t, hex.EncodeToString(wantBlob[:]), blobHash)
+
+	// The object is shorter than the declared encrypted size
+	_, _, err = rehashStoredUpload(context.Background(), providers[0], "blob-resumed", 1024)
+	assert.Error(t, err)
+}
+
+// TestCompleteUpload_CommitFailureRemovesReplicas verifies that quorum
+// replicas written before the metadata transaction are removed again when
+// the transaction does not commit.
+func TestCompleteUpload_CommitFailureRemovesReplicas(t *testing.T) {
+	db := testutil.SchemaDB(t, "_foreign_keys=1")
+	providers := setupRepairTestDB(t, db)
+	require.NoError(t, storage.Registry.SetWritePolicy(storage.WritePolicyQuorum, 3))
+	ctx := context.Background()
+
+	// A deferred foreign key violation lets every statement succeed and
+	// fails the commit
+	_, err := db.Exec(`
+		CREATE TABLE commit_guard (username TEXT REFERENCES users(username) DEFERRABLE INITIALLY DEFERRED);
+		CREATE TRIGGER fail_commit AFTER INSERT ON file_metadata BEGIN
+			INSERT INTO commit_guard VALUES ('nobody');
+		END;
+	`)
+	require.NoError(t, err)
+
+	data := []byte("encrypted chunk with padding")
+	uploadID, err := providers[0].InitiateMultipartUpload(ctx, "blob-up", nil)
+	require.NoError(t, err)
+	part, err := providers[0].UploadPart(ctx, "blob-up", uploadID, 1, bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO upload_sessions (id, file_id, encrypted_filename, filename_nonce, encrypted_sha256sum, sha256sum_nonce,
+			owner_username, total_size, chunk_size, total_chunks, password_type, storage_upload_id, storage_id, provider_id, padded_size, encrypted_fek)
+		VALUES ('sess-1', ?, 'name', 'nonce', 'sum', 'nonce', 'alice', ?, ?, 1, 'account', ?, 'blob-up', 'p1', ?, 'fek')`,
+		validTestFileID, len(data), len(data), uploadID, len(data))
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	_, err = db.Exec(`INSERT INTO upload_chunks (session_id, chunk_number, chunk_hash, chunk_size, etag) VALUES ('sess-1', 0, ?, ?, ?)`,
+		hex.EncodeToString(sum[:]), len(data), part.ETag)
+	require.NoError(t, err)
+
+	c, _ := authedContext(http.MethodPost, "/api/uploads/sess-1/complete", nil, "alice")
+	c.SetParamNames("sessionId")
+	c.SetParamValues("sess-1")
+	err = CompleteUpload(c)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, httpErrorCode(t, err))
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM file_metadata`).Scan(&count))
+	assert.Zero(t, count, "nothing was recorded")
+	for _, p := range providers[1:] {
+		_, err := p.HeadObject(ctx, "blob-up")
+		assert.Error(t, err, "replica on %s was not removed", p.RootDir())
+	}
+}
@@ -0,0 +1,378 @@
+// user_shares.go - Direct user-to-user sharing without a share password.
+//
+// Each user may publish a hybrid X25519 + ML-KEM-768 public key, stored
+// alongside the private key wrapped under their account key (see
+// crypto/user_keys.go). To share a file the owner's client unwraps the FEK
+// and seals it to the recipient's public key, and encrypts a copy of the
+// filename and SHA-256 with the key derived alongside. The file then shows
+// up in the recipient's "shared with me" listing and downloads through the
+// ordinary chunk endpoint. The server never sees a plaintext key.
+//
+// The recipient's copy of the metadata is not updated when the owner
+// renames the file; re-wrapping the owner's FEK does not affect it. Either
+// party may remove a share, which deletes it.
+
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/arkfile/Arkfile/auth"
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/database"
+	"github.com/arkfile/Arkfile/logging"
+	"github.com/arkfile/Arkfile/models"
+)
+
+// UserEncryptionKeyRequest is the body of PUT /api/user/encryption-key.
+type UserEncryptionKeyRequest struct {
+	PublicKey           string `json:"public_key"`            // Base64 hybrid public key
+	EncryptedPrivateKey string `json:"encrypted_private_key"` // Base64 private key, AES-GCM under the account key
+}
+
+// UserShareRequest is the body of POST /api/user-shares.
+type UserShareRequest struct {
+	FileID             string `json:"file_id"`
+	RecipientUsername  string `json:"recipient_username"`
+	EncryptedFEK       string `json:"encrypted_fek"` // FEK sealed to the recipient's public key
+	EncryptedFilename  string `json:"encrypted_filename"`
+	FilenameNonce      string `json:"filename_nonce"`
+	EncryptedSHA256sum string `json:"encrypted_sha256sum"`
+	SHA256sumNonce     string `json:"sha256sum_nonce"`
+}
+
+// PutUserEncryptionKey stores the authenticated user's encryption key pair.
+// A key cannot be replaced once set: existing shares are sealed to it.
+func PutUserEncryptionKey(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	var request UserEncryptionKeyRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(request.PublicKey)
+	if err != nil || len(publicKey) != crypto.HybridPublicKeySize {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid public key")
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(request.EncryptedPrivateKey)
+	if err != nil || len(privateKey) != crypto.HybridPrivateKeySize+crypto.AesGcmOverhead() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid encrypted private key")
+	}
+
+	if _, err := loadUserPublicKey(username); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "An encryption key is already set for this account")
+	} else if err != sql.ErrNoRows {
+		logging.ErrorLogger.Printf("Failed to check encryption key for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store encryption key")
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO user_encryption_keys (username, public_key, encrypted_private_key, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
+		username, request.PublicKey, request.EncryptedPrivateKey,
+	)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to store encryption key for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store encryption key")
+	}
+
+	database.LogUserAction(username, "created_encryption_key", "")
+	logging.InfoLogger.Printf("Encryption key created for user %s", username)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"fingerprint": crypto.HybridKeyFingerprint(publicKey),
+	})
+}
+
+// GetUserEncryptionKey returns the authenticated user's key pair, with the
+// private key still wrapped.
+func GetUserEncryptionKey(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	var publicKey, encryptedPrivateKey string
+	var createdAt *time.Time
+	err := database.DB.QueryRow(`
+		SELECT public_key, encrypted_private_key, created_at FROM user_encryption_keys WHERE username = ?
+	`, username).Scan(&publicKey, &encryptedPrivateKey, &createdAt)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "No encryption key is set for this account")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to load encryption key for user %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve encryption key")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"public_key":            publicKey,
+		"encrypted_private_key": encryptedPrivateKey,
+		"fingerprint":           publicKeyFingerprint(publicKey),
+		"created_at":            createdAt,
+	})
+}
+
+// GetUserPublicKey returns another user's public key. Unknown users and
+// users without a key get the same 404.
+func GetUserPublicKey(c echo.Context) error {
+	target := c.Param("username")
+
+	publicKey, err := loadUserPublicKey(target)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "No encryption key found for this user")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to load public key for user %s: %v", target, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve public key")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"username":    target,
+		"public_key":  publicKey,
+		"fingerprint": publicKeyFingerprint(publicKey),
+	})
+}
+
+// CreateUserShare shares one of the authenticated user's files with another
+// user.
+func CreateUserShare(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	var request UserShareRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if request.FileID == "" || request.RecipientUsername == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "File ID and recipient username are required")
+	}
+	if request.RecipientUsername == username {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot share a file with yourself")
+	}
+	if request.EncryptedFilename == "" || request.FilenameNonce == "" ||
+		request.EncryptedSHA256sum == "" || request.SHA256sumNonce == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Encrypted filename and SHA256 are required")
+	}
+	envelope, err := base64.StdEncoding.DecodeString(request.EncryptedFEK)
+	if err != nil || len(envelope) != crypto.UserShareFEKEnvelopeSize() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user share FEK envelope")
+	}
+	if _, keyType, err := crypto.ParseFEKEnvelopeHeader(envelope); err != nil || keyType != "user" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user share FEK envelope")
+	}
+
+	file, err := models.GetFileByFileID(database.DB, request.FileID)
+	if err != nil {
+		if err.Error() == "file not found" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		logging.ErrorLogger.Printf("Database error loading file for user share: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share")
+	}
+	if file.OwnerUsername != username {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+	if file.PasswordType == "drop" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Accept a file drop upload before sharing it")
+	}
+
+	if _, err := loadUserPublicKey(request.RecipientUsername); err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "No encryption key found for this user")
+	} else if err != nil {
+		logging.ErrorLogger.Printf("Failed to load public key for user %s: %v", request.RecipientUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share")
+	}
+
+	var existing string
+	err = database.DB.QueryRow(`SELECT share_id FROM user_file_shares WHERE file_id = ? AND recipient_username = ?`,
+		request.FileID, request.RecipientUsername).Scan(&existing)
+	if err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "File is already shared with this user")
+	} else if err != sql.ErrNoRows {
+		logging.ErrorLogger.Printf("Database error checking existing user share: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share")
+	}
+
+	shareID, err := generateShareID()
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to generate user share ID: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share")
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO user_file_shares (share_id, file_id, owner_username, recipient_username, encrypted_fek,
+			encrypted_filename, filename_nonce, encrypted_sha256sum, sha256sum_nonce, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		shareID, request.FileID, username, request.RecipientUsername, request.EncryptedFEK,
+		request.EncryptedFilename, request.FilenameNonce, request.EncryptedSHA256sum, request.SHA256sumNonce,
+	)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to create user share for file %s: %v", request.FileID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create share")
+	}
+
+	logging.InfoLogger.Printf("User share created: file_id=%s, owner=%s, recipient=%s", request.FileID, username, request.RecipientUsername)
+	database.LogUserAction(username, "shared_with_user", request.FileID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"share_id":           shareID,
+		"file_id":            request.FileID,
+		"recipient_username": request.RecipientUsername,
+	})
+}
+
+// ListUserShares lists the files the authenticated user has shared with
+// other users.
+func ListUserShares(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	rows, err := database.DB.Query(`
+		SELECT share_id, file_id, recipient_username, created_at
+		FROM user_file_shares
+		WHERE owner_username = ?
+		ORDER BY created_at DESC
+	`, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query user shares for %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shares")
+	}
+	defer rows.Close()
+
+	shares := []map[string]interface{}{}
+	for rows.Next() {
+		var shareID, fileID, recipient string
+		var createdAt *time.Time
+		if err := rows.Scan(&shareID, &fileID, &recipient, &createdAt); err != nil {
+			logging.ErrorLogger.Printf("Error scanning user share row: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shares")
+		}
+		shares = append(shares, map[string]interface{}{
+			"share_id":           shareID,
+			"file_id":            fileID,
+			"recipient_username": recipient,
+			"created_at":         createdAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Error iterating user shares: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shares")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"shares": shares,
+	})
+}
+
+// ListSharedWithMe lists the files other users have shared with the
+// authenticated user, with everything the client needs to decrypt them.
+// Files in the owner's trash are left out.
+func ListSharedWithMe(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+
+	rows, err := database.DB.Query(`
+		SELECT us.share_id, us.file_id, us.owner_username, us.encrypted_fek,
+		       us.encrypted_filename, us.filename_nonce, us.encrypted_sha256sum, us.sha256sum_nonce,
+		       us.created_at, fm.size_bytes, fm.chunk_count, fm.chunk_size_bytes
+		FROM user_file_shares us
+		JOIN file_metadata fm ON fm.file_id = us.file_id
+		WHERE us.recipient_username = ? AND fm.deleted_at IS NULL
+		ORDER BY us.created_at DESC
+	`, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to query files shared with %s: %v", username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shared files")
+	}
+	defer rows.Close()
+
+	files := []map[string]interface{}{}
+	for rows.Next() {
+		var (
+			shareID, fileID, owner, encryptedFEK                     string
+			encryptedFilename, filenameNonce, encryptedSHA, shaNonce string
+			sharedAt                                                 *time.Time
+			sizeBytes, chunkCount, chunkSizeBytes                    float64 // rqlite returns numbers as float64
+		)
+		if err := rows.Scan(&shareID, &fileID, &owner, &encryptedFEK,
+			&encryptedFilename, &filenameNonce, &encryptedSHA, &shaNonce,
+			&sharedAt, &sizeBytes, &chunkCount, &chunkSizeBytes); err != nil {
+			logging.ErrorLogger.Printf("Error scanning shared file row: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shared files")
+		}
+		files = append(files, map[string]interface{}{
+			"share_id":            shareID,
+			"file_id":             fileID,
+			"owner_username":      owner, // needed for metadata AAD reconstruction
+			"encrypted_fek":       encryptedFEK,
+			"encrypted_filename":  encryptedFilename,
+			"filename_nonce":      filenameNonce,
+			"encrypted_sha256sum": encryptedSHA,
+			"sha256sum_nonce":     shaNonce,
+			"shared_at":           sharedAt,
+			"size_bytes":          int64(sizeBytes),
+			"chunk_count":         int64(chunkCount),
+			"chunk_size_bytes":    int64(chunkSizeBytes),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		logging.ErrorLogger.Printf("Error iterating shared files: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve shared files")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"files": files,
+	})
+}
+
+// DeleteUserShare removes a user share. The owner revokes it; the recipient
+// may also remove a file they no longer want.
+func DeleteUserShare(c echo.Context) error {
+	username := auth.GetUsernameFromToken(c)
+	shareID := c.Param("id")
+
+	result, err := database.DB.Exec(`
+		DELETE FROM user_file_shares WHERE share_id = ? AND (owner_username = ? OR recipient_username = ?)
+	`, shareID, username, username)
+	if err != nil {
+		logging.ErrorLogger.Printf("Failed to delete user share: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove share")
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Share not found")
+	}
+
+	database.LogUserAction(username, "removed_user_share", shareID[:min(8, len(shareID))]+"...")
+	logging.InfoLogger.Printf("User share removed: share_id=%s..., by=%s", shareID[:min(8, len(shareID))], username)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Share removed successfully",
+	})
+}
+
+// hasUserShare reports whether fileID has been shared with username.
+func hasUserShare(fileID, username string) (bool, error) {
+	var shareID string
+	err := database.DB.QueryRow(`SELECT share_id FROM user_file_shares WHERE file_id = ? AND recipient_username = ?`,
+		fileID, username).Scan(&shareID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// loadUserPublicKey returns a user's base64 public key, or sql.ErrNoRows if
+// the user has none.
+func loadUserPublicKey(username string) (string, error) {
+	var publicKey string
+	err := database.DB.QueryRow(`SELECT public_key FROM user_encryption_keys WHERE username = ?`,
+		username).Scan(&publicKey)
+	return publicKey, err
+}
+
+// publicKeyFingerprint returns the fingerprint of a stored base64 public key.
+func publicKeyFingerprint(publicKey string) string {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return ""
+	}
+	return crypto.HybridKeyFingerprint(raw)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkfile/Arkfile/crypto"
+	"github.com/arkfile/Arkfile/testutil"
+)
+
+// setupUserShareTest seeds alice, bob and carol; alice owns photo and
+// tax-2024.
+func setupUserShareTest(t *testing.T) *sql.DB {
+	t.Helper()
+	db, provider := setupHandlerTestDB(t)
+	testutil.InsertUsers(t, db, "alice", "bob", "carol")
+	insertTestFile(t, db, provider, "alice", "photo")
+	insertTestFile(t, db, provider, "alice", "tax-2024")
+	return db
+}
+
+// putUserKey stores a new encryption key for username and returns it.
+func putUserKey(t *testing.T, username string) (*crypto.HybridPrivateKey, int) {
+	t.Helper()
+	key, err := crypto.GenerateHybridKey()
+	require.NoError(t, err)
+	body, _ := json.Marshal(UserEncryptionKeyRequest{
+		PublicKey:           base64.StdEncoding.EncodeToString(key.PublicKey()),
+		EncryptedPrivateKey: base64.StdEncoding.EncodeToString(make([]byte, crypto.HybridPrivateKeySize+crypto.AesGcmOverhead())),
+	})
+	c, rec := authedContext(http.MethodPut, "/api/user/encryption-key", body, username)
+	if err := PutUserEncryptionKey(c); err != nil {
+		return key, httpErrorCode(t, err)
+	}
+	return key, rec.Code
+}
+
+// userShareRequest returns a valid POST /api/user-shares body sharing fileID
+// with a recipient holding key.
+func userShareRequest(t *testing.T, key *crypto.HybridPrivateKey, fileID, recipient string) map[string]interface{} {
+	t.Helper()
+	envelope, metadataKey, err := crypto.SealUserShareFEK(key.PublicKey(), fileID, make([]byte, 32))
+	require.NoError(t, err)
+	crypto.SecureClear(metadataKey)
+	return map[string]interface{}{
+		"file_id":             fileID,
+		"recipient_username":  recipient,
+		"encrypted_fek":       base64.StdEncoding.EncodeToString(envelope),
+		"encrypted_filename":  "ZmlsZW5hbWU=",
+		"filename_nonce":      "bm9uY2Vub25jZTE=",
+		"encrypted_sha256sum": "c2hhMjU2",
+		"sha256sum_nonce":     "bm9uY2Vub25jZTI=",
+	}
+}
+
+// createUserShare calls CreateUserShare and returns the status code and
+// decoded response.
+func createUserShare(t *testing.T, username string, body map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	c, rec := authedContext(http.MethodPost, "/api/user-shares", raw, username)
+	if err := CreateUserShare(c); err != nil {
+		return httpErrorCode(t, err), nil
+	}
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+func TestUserEncryptionKey(t *testing.T) {
+	setupUserShareTest(t)
+
+	c, _ := authedContext(http.MethodGet, "/api/user/encryption-key", nil, "bob")
+	assert.Equal(t, http.StatusNotFound, httpErrorCode(t, GetUserEncryptionKey(c)))
+
+	key, status := putUserKey(t, "bob")
+	require.Equal(t, http.StatusOK, status)
+	_, status = putUserKey(t, "bob")
+	assert.Equal(t, http.StatusConflict, status, "keys cannot be replaced")
+
+	c, rec := authedContext(http.MethodGet, "/api/user/encryption-key", nil, "bob")
+	require.NoError(t, GetUserEncryptionKey(c))
+	var own map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &own))
+	assert.NotEmpty(t, own["encrypted_private_key"])
+
+	getPublic := func(target string) (int, map[string]interface{}) {
+		c, rec := authedContext(http.MethodGet, "/api/users/"+target+"/public-key", nil, "alice")
+		c.SetParamNames("username")
+		c.SetParamValues(target)
+		if err := GetUserPublicKey(c); err != nil {
+			return httpErrorCode(t, err), nil
+		}
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return rec.Code, response
+	}
+	status, response := getPublic("bob")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, crypto.HybridKeyFingerprint(key.PublicKey()), response["fingerprint"])
+	assert.NotContains(t, response, "encrypted_private_key")
+
+	status, _ = getPublic("carol")
+	assert.Equal(t, http.StatusNotFound, status, "no key")
+	status, _ = getPublic("nobody-here")
+	assert.Equal(t, http.StatusNotFound, status, "unknown user")
+}
+
+func TestCreateUserShare_Validation(t *testing.T) {
+	db := setupUserShareTest(t)
+	key, status := putUserKey(t, "bob")
+	require.Equal(t, http.StatusOK, status)
+
+	cases := map[string]struct {
+		username string
+		mutate   func(map[string]interface{})
+		want     int
+	}{
+		"share with self":  {"alice", func(b map[string]interface{}) { b["recipient_username"] = "alice" }, http.StatusBadRequest},
+		"no filename":      {"alice", func(b map[string]interface{}) { b["encrypted_filename"] = "" }, http.StatusBadRequest},
+		"short envelope":   {"alice", func(b map[string]interface{}) { b["encrypted_fek"] = "AQQAAA==" }, http.StatusBadRequest},
+		"recipient no key": {"alice", func(b map[string]interface{}) { b["recipient_username"] = "carol" }, http.StatusNotFound},
+		"another's file":   {"carol", func(b map[string]interface{}) {}, http.StatusForbidden},
+		"unknown file":     {"alice", func(b map[string]interface{}) { b["file_id"] = "missing" }, http.StatusNotFound},
+		"drop file":        {"alice", func(b map[string]interface{}) { b["file_id"] = "tax-2024" }, http.StatusBadRequest},
+		"account envelope": {"alice", func(b map[string]interface{}) {
+			raw, _ := base64.StdEncoding.DecodeString(b["encrypted_fek"].(string))
+			raw[1] = 0x01
+			b["encrypted_fek"] = base64.StdEncoding.EncodeToString(raw)
+		}, http.StatusBadRequest},
+	}
+	_, err := db.Exec(`UPDATE file_metadata SET password_type = 'drop' WHERE file_id = 'tax-2024'`)
+	require.NoError(t, err)
+	for name, tc := range cases {
+		body := userShareRequest(t, key, "photo", "bob")
+		tc.mutate(body)
+		status, _ := createUserShare(t, tc.username, body)
+		assert.Equal(t, tc.want, status, name)
+	}
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM user_file_shares`).Scan(&count))
+	assert.Zero(t, count)
+
+	status, response := createUserShare(t, "alice", userShareRequest(t, key, "photo", "bob"))
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, response["share_id"], 43)
+	status, _ = createUserShare(t, "alice", userShareRequest(t, key, "photo", "bob"))
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestUserShare_SharedWithMeAndRevoke(t *testing.T) {
+	db := setupUserShareTest(t)
+	key, status := putUserKey(t, "bob")
+	require.Equal(t, http.StatusOK, status)
+
+	downloadStatus := func(username string) int {
+		c, rec := authedContext(http.MethodGet, "/api/files/photo/chunks/0", nil, username)
+		c.SetParamNames("fileId", "chunkIndex")
+		c.SetParamValues("photo", "0")
+		if err := DownloadFileChunk(c); err != nil {
+			return httpErrorCode(t, err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, downloadStatus("bob"), "not shared yet")
+
+	status, response := createUserShare(t, "alice", userShareRequest(t, key, "photo", "bob"))
+	require.Equal(t, http.StatusOK, status)
+	shareID := response["share_id"].(string)
+
+	listWithMe := func(username string) []map[string]interface{} {
+		c, rec := authedContext(http.MethodGet, "/api/shared-with-me", nil, username)
+		require.NoError(t, ListSharedWithMe(c))
+		var list struct {
+			Files []map[string]interface{} `json:"files"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		return list.Files
+	}
+	files := listWithMe("bob")
+	require.Len(t, files, 1)
+	assert.Equal(t, "photo", files[0]["file_id"])
+	assert.Equal(t, "alice", files[0]["owner_username"])
+	assert.Equal(t, float64(100), files[0]["size_bytes"])
+	raw, err := base64.StdEncoding.DecodeString(files[0]["encrypted_fek"].(string))
+	require.NoError(t, err)
+	_, _, err = crypto.OpenUserShareFEK(key, "photo", raw)
+	assert.NoError(t, err, "recipient opens the sealed FEK")
+	assert.Empty(t, listWithMe("carol"))
+
+	c, rec := authedContext(http.MethodGet, "/api/user-shares", nil, "alice")
+	require.NoError(t, ListUserShares(c))
+	assert.Contains(t, rec.Body.String(), `"recipient_username":"bob"`)
+
+	assert.Equal(t, http.StatusOK, downloadStatus("bob"))
+	assert.Equal(t, http.StatusForbidden, downloadStatus("carol"))
+
+	// Trashed files drop out of the listing and cannot be downloaded
+	_, err = db.Exec(`UPDATE file_metadata SET deleted_at = CURRENT_TIMESTAMP WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+	assert.Empty(t, listWithMe("bob"))
+	assert.Equal(t, http.StatusNotFound, downloadStatus("bob"))
+	_, err = db.Exec(`UPDATE file_metadata SET deleted_at = NULL WHERE file_id = 'photo'`)
+	require.NoError(t, err)
+
+	remove := func(username string) int {
+		c, rec := authedContext(http.MethodDelete, "/api/user-shares/"+shareID, nil, username)
+		c.SetParamNames("id")
+		c.SetParamValues(shareID)
+		if err := DeleteUserShare(c); err != nil {
+			return httpErrorCode(t, err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove("carol"))
+	assert.Equal(t, http.StatusOK, remove("alice"))
+	assert.Equal(t, http.StatusNotFound, remove("bob"), "already revoked")
+	assert.Empty(t, listWithMe("bob"))
+	assert.Equal(t, http.StatusForbidden, downloadStatus("bob"))
+}
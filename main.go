@@ -212,6 +212,13 @@ func main() {
 	// Register storage providers in the database and backfill location records
 	registerAndBackfillStorageProviders()
 
+	// Apply the upload write policy now that the registry's roles are final
+	if err := configureStorageWritePolicy(cfg); err != nil {
+		log.Fatalf("Invalid storage write policy: %v", err)
+	}
+	storage.Registry.SetHedgedReads(cfg.Storage.HedgedReads)
+	storage.Registry.SetErasureLayoutSource(handlers.LookupErasureLayout)
+
 	// Initialize background task runner for admin copy operations
 	handlers.InitTaskRunner(2)
 
@@ -452,6 +459,78 @@ func runSchemaMigrations() {
 			description: "Add stored_blob_sha256sum to file_metadata",
 			sql:         "ALTER TABLE file_metadata ADD COLUMN stored_blob_sha256sum CHAR(64)",
 		},
+		// Storage placement policies: per-user policy assignment and the provider
+		// that holds each in-progress multipart upload.
+		{
+			description: "Add placement_policy to users",
+			sql:         "ALTER TABLE users ADD COLUMN placement_policy TEXT DEFAULT NULL",
+		},
+		{
+			description: "Add provider_id to upload_sessions",
+			sql:         "ALTER TABLE upload_sessions ADD COLUMN provider_id TEXT",
+		},
+		// Storage tiering: last download time, used to find cold blobs.
+		{
+			description: "Add last_accessed_at to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN last_accessed_at TIMESTAMP DEFAULT NULL",
+		},
+		// Folder hierarchy: client-encrypted folder path of each file.
+		{
+			description: "Add encrypted_folder to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN encrypted_folder TEXT DEFAULT NULL",
+		},
+		{
+			description: "Add folder_nonce to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN folder_nonce TEXT DEFAULT NULL",
+		},
+		{
+			description: "Add encrypted_folder to upload_sessions",
+			sql:         "ALTER TABLE upload_sessions ADD COLUMN encrypted_folder TEXT DEFAULT NULL",
+		},
+		{
+			description: "Add folder_nonce to upload_sessions",
+			sql:         "ALTER TABLE upload_sessions ADD COLUMN folder_nonce TEXT DEFAULT NULL",
+		},
+		// File versioning: version chains and the per-user retention count.
+		{
+			description: "Add version_group to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN version_group VARCHAR(36) DEFAULT NULL",
+		},
+		{
+			description: "Add version_number to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN version_number INTEGER NOT NULL DEFAULT 1",
+		},
+		{
+			description: "Add version_group to upload_sessions",
+			sql:         "ALTER TABLE upload_sessions ADD COLUMN version_group VARCHAR(36) DEFAULT NULL",
+		},
+		{
+			description: "Add version_retention to users",
+			sql:         "ALTER TABLE users ADD COLUMN version_retention INTEGER NOT NULL DEFAULT 0",
+		},
+		{
+			description: "Index file_metadata by version_group",
+			sql:         "CREATE INDEX IF NOT EXISTS idx_file_metadata_version_group ON file_metadata(version_group)",
+		},
+		// Trash: deleted files are kept for storage.trash_retention_days before being purged.
+		{
+			description: "Add deleted_at to file_metadata",
+			sql:         "ALTER TABLE file_metadata ADD COLUMN deleted_at TIMESTAMP DEFAULT NULL",
+		},
+		{
+			description: "Index file_metadata by deleted_at",
+			sql:         "CREATE INDEX IF NOT EXISTS idx_file_metadata_deleted_at ON file_metadata(deleted_at)",
+		},
+		// Share policy updates: owners can pause a share without revoking it.
+		{
+			description: "Add suspended_at to file_share_keys",
+			sql:         "ALTER TABLE file_share_keys ADD COLUMN suspended_at DATETIME DEFAULT NULL",
+		},
+		// Erasure coding: last time the scrubber matched each shard's hash.
+		{
+			description: "Add verified_at to file_erasure_shards",
+			sql:         "ALTER TABLE file_erasure_shards ADD COLUMN verified_at TIMESTAMP",
+		},
 		// Storage credits / billing meter (v2): rename _cents columns to _microcents.
 		// These run once on first startup after upgrading; safe no-op on subsequent runs
 		// and on fresh installs (where the unified schema already declares _microcents).
@@ -566,6 +645,10 @@ func configuredStorageProvidersFromEnv(reg *storage.ProviderRegistry) []configur
 		if region == "" {
 			region = "us-east-1"
 		}
+		// Local filesystem providers have no endpoint; record their root path instead
+		if providerType == string(storage.ProviderLocal) && endpoint == "" {
+			endpoint = "file://" + os.Getenv(envPrefix+"_PATH")
+		}
 		providers = append(providers, configuredStorageProvider{
 			provider:     provider,
 			providerID:   providerID,
@@ -657,6 +740,49 @@ func resolveStorageRoleAssignments(configured []configuredStorageProvider, dbRol
 	return assignments
 }
 
+// configureStorageWritePolicy parses STORAGE_WRITE_POLICY / STORAGE_WRITE_QUORUM
+// (and STORAGE_ERASURE_SHARDS for the erasure policy) and applies them to the
+// global registry. A quorum or shard count that the configured providers cannot
+// satisfy is a startup error rather than a silent downgrade.
+func configureStorageWritePolicy(cfg *config.Config) error {
+	policy, quorum, providers, err := storage.ParseWritePolicy(cfg.Storage.WritePolicy)
+	if err != nil {
+		return err
+	}
+	if providers > 0 {
+		if err := storage.Registry.CheckProviderCount(providers); err != nil {
+			return fmt.Errorf("storage write policy %q: %w", cfg.Storage.WritePolicy, err)
+		}
+	}
+	if policy == storage.WritePolicyErasure {
+		dataShards, parityShards, err := storage.ParseErasureShards(cfg.Storage.ErasureShards)
+		if err != nil {
+			return err
+		}
+		if err := storage.Registry.SetErasureCoding(dataShards, parityShards); err != nil {
+			return err
+		}
+	}
+	if policy == storage.WritePolicyQuorum && quorum == 0 {
+		quorum = cfg.Storage.WriteQuorum
+		if quorum == 0 {
+			quorum = 2
+		}
+	}
+	if err := storage.Registry.SetWritePolicy(policy, quorum); err != nil {
+		return err
+	}
+	if policy == storage.WritePolicyQuorum {
+		logging.InfoLogger.Printf("Storage write policy: quorum (%d of %d providers)", quorum, storage.Registry.ConfiguredProviderCount())
+	} else if policy == storage.WritePolicyErasure {
+		dataShards, parityShards := storage.Registry.ErasureShards()
+		logging.InfoLogger.Printf("Storage write policy: erasure (%d+%d shards across %d providers)", dataShards, parityShards, storage.Registry.ConfiguredProviderCount())
+	} else {
+		logging.InfoLogger.Printf("Storage write policy: %s", policy)
+	}
+	return nil
+}
+
 // registerAndBackfillStorageProviders upserts configured storage providers into the
 // database, backfills file_storage_locations for existing files, recalculates provider
 // stats, and marks stale admin tasks as failed. Called once on server startup after
@@ -668,8 +794,14 @@ func registerAndBackfillStorageProviders() {
 		return
 	}
 
-	// Helper to upsert a provider config into the DB
-	upsertProvider := func(providerID, providerType, bucket, endpoint, region, role, envPrefix string) {
+	// Helper to upsert a provider config into the DB. Returns whether the provider
+	// is active: a provider deactivated by drain-provider stays inactive even
+	// while it is still configured.
+	upsertProvider := func(providerID, providerType, bucket, endpoint, region, role, envPrefix string) bool {
+		isActive := true
+		if existing, err := models.GetStorageProviderByID(database.DB, providerID); err == nil && !existing.IsActive {
+			isActive = false
+		}
 		record := &models.StorageProviderRecord{
 			ProviderID:   providerID,
 			ProviderType: providerType,
@@ -678,17 +810,23 @@ func registerAndBackfillStorageProviders() {
 			Region:       region,
 			Role:         role,
 			EnvVarPrefix: envPrefix,
-			IsActive:     true,
+			IsActive:     isActive,
 		}
 		if err := models.UpsertStorageProvider(database.DB, record); err != nil {
 			log.Printf("Storage: failed to upsert provider %s: %v", providerID, err)
 		}
+		return isActive
 	}
 
-	// Read and upsert all configured providers using slot ordering initially
-	configuredProviders := configuredStorageProvidersFromEnv(reg)
-	for _, provider := range configuredProviders {
-		upsertProvider(provider.providerID, provider.providerType, provider.bucket, provider.endpoint, provider.region, provider.defaultRole, provider.envPrefix)
+	// Read and upsert all configured providers using slot ordering initially.
+	// Drained (inactive) providers keep their record but are not given a role.
+	var configuredProviders []configuredStorageProvider
+	for _, provider := range configuredStorageProvidersFromEnv(reg) {
+		if !upsertProvider(provider.providerID, provider.providerType, provider.bucket, provider.endpoint, provider.region, provider.defaultRole, provider.envPrefix) {
+			log.Printf("Storage: provider %s is inactive (drained); not assigning a role", provider.providerID)
+			continue
+		}
+		configuredProviders = append(configuredProviders, provider)
 	}
 
 	// Retrieve authoritative DB roles to reconcile the in-memory registry
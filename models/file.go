@@ -49,6 +49,10 @@ type File struct {
 	EncryptedFilename      string         // Now stored as base64 strings directly
 	Sha256sumNonce         string         // base64 nonce for EncryptedSha256sum (plaintext-file hash)
 	EncryptedSha256sum     string         // base64 ciphertext of SHA-256 of plaintext file; client-encrypted, AAD-bound
+	FolderNonce            string         // base64 nonce for EncryptedFolder; empty for top-level files
+	EncryptedFolder        string         // base64 ciphertext of the folder path; client-encrypted, AAD-bound; empty for top-level files
+	VersionGroup           string         // file_id of the first version in this file's version chain; empty if never versioned
+	VersionNumber          int64          // 1-based position in the version chain
 	EncryptedFileSha256sum sql.NullString `json:"-"` // PLAINTEXT server-computed hash of encrypted stream; name is historical, not ciphertext
 	EncryptedFEK           string         // Now stored as base64 strings directly
 
@@ -72,6 +76,10 @@ type FileMetadataListItem struct {
 	EncryptedFilename  string    `json:"encrypted_filename"`
 	Sha256sumNonce     string    `json:"sha256sum_nonce"`
 	EncryptedSha256sum string    `json:"encrypted_sha256sum"`
+	FolderNonce        string    `json:"folder_nonce,omitempty"`
+	EncryptedFolder    string    `json:"encrypted_folder,omitempty"`
+	VersionGroup       string    `json:"version_group,omitempty"`
+	VersionNumber      int64     `json:"version_number"`
 	SizeBytes          int64     `json:"size_bytes"`
 	UploadDate         time.Time `json:"upload_date"`
 }
@@ -104,7 +112,8 @@ func CalculateChunkCount(sizeBytes int64, chunkSizeBytes int64) int64 {
 	return count
 }
 
-// GetFileByFileID retrieves a file record by file_id
+// GetFileByFileID retrieves a file record by file_id. Files in the trash
+// are reported as not found.
 func GetFileByFileID(db *sql.DB, fileID string) (*File, error) {
 	file := &File{}
 	var encryptedFileSha256sum string
@@ -113,13 +122,16 @@ func GetFileByFileID(db *sql.DB, fileID string) (*File, error) {
 	var chunkCount interface{}     // Use interface{} to handle both int64 and float64
 	var chunkSizeBytes interface{} // Use interface{} to handle both int64 and float64
 	var uploadDateStr string       // Scan as string first to handle RQLite timestamp format
+	var versionNumber interface{}  // Use interface{} to handle both int64 and float64
 
 	err := db.QueryRow(`
 		SELECT id, file_id, storage_id, owner_username, password_hint, password_type,
 			   filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum,
 			   COALESCE(encrypted_file_sha256sum, ''), encrypted_fek, size_bytes, padded_size,
-			   chunk_count, chunk_size_bytes, upload_date
-		FROM file_metadata WHERE file_id = ?`,
+			   chunk_count, chunk_size_bytes, upload_date,
+			   COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+			   COALESCE(version_group, ''), version_number
+		FROM file_metadata WHERE file_id = ? AND deleted_at IS NULL`,
 		fileID,
 	).Scan(
 		&file.ID, &file.FileID, &file.StorageID, &file.OwnerUsername,
@@ -129,6 +141,8 @@ func GetFileByFileID(db *sql.DB, fileID string) (*File, error) {
 		&encryptedFileSha256sum, &file.EncryptedFEK,
 		&sizeBytes, &paddedSize,
 		&chunkCount, &chunkSizeBytes, &uploadDateStr,
+		&file.FolderNonce, &file.EncryptedFolder,
+		&file.VersionGroup, &versionNumber,
 	)
 
 	if err == sql.ErrNoRows {
@@ -198,6 +212,8 @@ func GetFileByFileID(db *sql.DB, fileID string) (*File, error) {
 		}
 	}
 
+	file.VersionNumber = max(toInt64Raw(versionNumber), 1)
+
 	// Handle the nullable encrypted_file_sha256sum field
 	if encryptedFileSha256sum != "" {
 		file.EncryptedFileSha256sum = sql.NullString{
@@ -223,12 +239,15 @@ func GetFileByStorageID(db *sql.DB, storageID string) (*File, error) {
 	var chunkCount interface{}     // Use interface{} to handle both int64 and float64
 	var chunkSizeBytes interface{} // Use interface{} to handle both int64 and float64
 	var uploadDateStr string       // Scan as string first to handle RQLite timestamp format
+	var versionNumber interface{}  // Use interface{} to handle both int64 and float64
 
 	err := db.QueryRow(`
 		SELECT id, file_id, storage_id, owner_username, password_hint, password_type,
 			   filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum,
 			   COALESCE(encrypted_file_sha256sum, ''), encrypted_fek, size_bytes, padded_size,
-			   chunk_count, chunk_size_bytes, upload_date
+			   chunk_count, chunk_size_bytes, upload_date,
+			   COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+			   COALESCE(version_group, ''), version_number
 		FROM file_metadata WHERE storage_id = ?`,
 		storageID,
 	).Scan(
@@ -239,6 +258,8 @@ func GetFileByStorageID(db *sql.DB, storageID string) (*File, error) {
 		&encryptedFileSha256sum, &file.EncryptedFEK,
 		&sizeBytes, &paddedSize,
 		&chunkCount, &chunkSizeBytes, &uploadDateStr,
+		&file.FolderNonce, &file.EncryptedFolder,
+		&file.VersionGroup, &versionNumber,
 	)
 
 	if err == sql.ErrNoRows {
@@ -308,6 +329,8 @@ func GetFileByStorageID(db *sql.DB, storageID string) (*File, error) {
 		}
 	}
 
+	file.VersionNumber = max(toInt64Raw(versionNumber), 1)
+
 	// Handle the nullable encrypted_file_sha256sum field
 	if encryptedFileSha256sum != "" {
 		file.EncryptedFileSha256sum = sql.NullString{
@@ -324,7 +347,8 @@ func GetFileByStorageID(db *sql.DB, storageID string) (*File, error) {
 	return file, nil
 }
 
-// GetFilesByOwner retrieves all files owned by a specific user
+// GetFilesByOwner retrieves all files owned by a specific user, excluding
+// files in the trash
 func GetFilesByOwner(db *sql.DB, ownerUsername string) ([]*File, error) {
 	if db == nil {
 		return nil, errors.New("database connection is nil")
@@ -334,8 +358,10 @@ func GetFilesByOwner(db *sql.DB, ownerUsername string) ([]*File, error) {
 		SELECT id, file_id, storage_id, owner_username, password_hint, password_type,
 			   filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum, 
 			   COALESCE(encrypted_file_sha256sum, ''), encrypted_fek, size_bytes, padded_size,
-			   chunk_count, chunk_size_bytes, upload_date 
-		FROM file_metadata WHERE owner_username = ? ORDER BY upload_date DESC`
+			   chunk_count, chunk_size_bytes, upload_date,
+			   COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+			   COALESCE(version_group, ''), version_number
+		FROM file_metadata WHERE owner_username = ? AND deleted_at IS NULL ORDER BY upload_date DESC`
 
 	rows, err := db.Query(query, ownerUsername)
 	if err != nil {
@@ -345,97 +371,112 @@ func GetFilesByOwner(db *sql.DB, ownerUsername string) ([]*File, error) {
 
 	var files []*File
 	for rows.Next() {
-		file := &File{}
-		var encryptedFileSha256sum string
-		var sizeBytes interface{}
-		var paddedSize interface{}
-		var chunkCount interface{}
-		var chunkSizeBytes interface{}
-		var uploadDateStr string
-
-		err := rows.Scan(
-			&file.ID, &file.FileID, &file.StorageID, &file.OwnerUsername,
-			&file.PasswordHint, &file.PasswordType,
-			&file.FilenameNonce, &file.EncryptedFilename,
-			&file.Sha256sumNonce, &file.EncryptedSha256sum,
-			&encryptedFileSha256sum, &file.EncryptedFEK,
-			&sizeBytes, &paddedSize,
-			&chunkCount, &chunkSizeBytes, &uploadDateStr,
-		)
+		file, err := scanFileRow(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row for user '%s': %w", ownerUsername, err)
 		}
+		files = append(files, file)
+	}
 
-		// Convert sizeBytes from interface{} to int64
-		switch v := sizeBytes.(type) {
-		case int64:
-			file.SizeBytes = v
-		case float64:
-			file.SizeBytes = int64(v)
-		case nil:
-			file.SizeBytes = 0
-		default:
-			return nil, fmt.Errorf("unexpected type for size_bytes: %T", v)
-		}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error for user '%s': %w", ownerUsername, err)
+	}
 
-		// Convert paddedSize from interface{} to sql.NullInt64
-		switch v := paddedSize.(type) {
-		case int64:
-			file.PaddedSize = sql.NullInt64{Int64: v, Valid: true}
-		case float64:
-			file.PaddedSize = sql.NullInt64{Int64: int64(v), Valid: true}
-		case nil:
-			file.PaddedSize = sql.NullInt64{Valid: false}
-		default:
-			file.PaddedSize = sql.NullInt64{Valid: false}
-		}
+	return files, nil
+}
 
-		// Convert chunkCount from interface{} to int64
-		switch v := chunkCount.(type) {
-		case int64:
-			file.ChunkCount = v
-		case float64:
-			file.ChunkCount = int64(v)
-		case nil:
-			file.ChunkCount = 1
-		default:
-			file.ChunkCount = 1
-		}
+// scanFileRow scans one row of the file column list used by GetFilesByOwner.
+// extra receives any columns the query selects after version_number.
+func scanFileRow(rows *sql.Rows, extra ...interface{}) (*File, error) {
+	file := &File{}
+	var encryptedFileSha256sum string
+	var sizeBytes interface{}
+	var paddedSize interface{}
+	var chunkCount interface{}
+	var chunkSizeBytes interface{}
+	var uploadDateStr string
+	var versionNumber interface{}
 
-		// Convert chunkSizeBytes from interface{} to int64
-		switch v := chunkSizeBytes.(type) {
-		case int64:
-			file.ChunkSizeBytes = v
-		case float64:
-			file.ChunkSizeBytes = int64(v)
-		case nil:
-			file.ChunkSizeBytes = crypto.PlaintextChunkSize()
-		default:
-			file.ChunkSizeBytes = crypto.PlaintextChunkSize()
-		}
+	dest := []interface{}{
+		&file.ID, &file.FileID, &file.StorageID, &file.OwnerUsername,
+		&file.PasswordHint, &file.PasswordType,
+		&file.FilenameNonce, &file.EncryptedFilename,
+		&file.Sha256sumNonce, &file.EncryptedSha256sum,
+		&encryptedFileSha256sum, &file.EncryptedFEK,
+		&sizeBytes, &paddedSize,
+		&chunkCount, &chunkSizeBytes, &uploadDateStr,
+		&file.FolderNonce, &file.EncryptedFolder,
+		&file.VersionGroup, &versionNumber,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
 
-		// Parse timestamp string to time.Time
-		if parsedTime, parseErr := time.Parse("2006-01-02 15:04:05", uploadDateStr); parseErr == nil {
-			file.UploadDate = parsedTime
-		} else if parsedTime, parseErr := time.Parse(time.RFC3339, uploadDateStr); parseErr == nil {
-			file.UploadDate = parsedTime
-		} else if uploadDateStr != "" {
-			// Skip if parsing fails - no logging needed in this bulk operation
-		}
+	// Convert sizeBytes from interface{} to int64
+	switch v := sizeBytes.(type) {
+	case int64:
+		file.SizeBytes = v
+	case float64:
+		file.SizeBytes = int64(v)
+	case nil:
+		file.SizeBytes = 0
+	default:
+		return nil, fmt.Errorf("unexpected type for size_bytes: %T", v)
+	}
 
-		// Handle nullable encrypted_file_sha256sum
-		if encryptedFileSha256sum != "" {
-			file.EncryptedFileSha256sum = sql.NullString{String: encryptedFileSha256sum, Valid: true}
-		}
+	// Convert paddedSize from interface{} to sql.NullInt64
+	switch v := paddedSize.(type) {
+	case int64:
+		file.PaddedSize = sql.NullInt64{Int64: v, Valid: true}
+	case float64:
+		file.PaddedSize = sql.NullInt64{Int64: int64(v), Valid: true}
+	case nil:
+		file.PaddedSize = sql.NullInt64{Valid: false}
+	default:
+		file.PaddedSize = sql.NullInt64{Valid: false}
+	}
 
-		files = append(files, file)
+	// Convert chunkCount from interface{} to int64
+	switch v := chunkCount.(type) {
+	case int64:
+		file.ChunkCount = v
+	case float64:
+		file.ChunkCount = int64(v)
+	case nil:
+		file.ChunkCount = 1
+	default:
+		file.ChunkCount = 1
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error for user '%s': %w", ownerUsername, err)
+	// Convert chunkSizeBytes from interface{} to int64
+	switch v := chunkSizeBytes.(type) {
+	case int64:
+		file.ChunkSizeBytes = v
+	case float64:
+		file.ChunkSizeBytes = int64(v)
+	case nil:
+		file.ChunkSizeBytes = crypto.PlaintextChunkSize()
+	default:
+		file.ChunkSizeBytes = crypto.PlaintextChunkSize()
 	}
 
-	return files, nil
+	// Parse timestamp string to time.Time
+	if parsedTime, parseErr := time.Parse("2006-01-02 15:04:05", uploadDateStr); parseErr == nil {
+		file.UploadDate = parsedTime
+	} else if parsedTime, parseErr := time.Parse(time.RFC3339, uploadDateStr); parseErr == nil {
+		file.UploadDate = parsedTime
+	} else if uploadDateStr != "" {
+		// Skip if parsing fails - no logging needed in this bulk operation
+	}
+
+	file.VersionNumber = max(toInt64Raw(versionNumber), 1)
+
+	// Handle nullable encrypted_file_sha256sum
+	if encryptedFileSha256sum != "" {
+		file.EncryptedFileSha256sum = sql.NullString{String: encryptedFileSha256sum, Valid: true}
+	}
+
+	return file, nil
 }
 
 // GetRecentFileMetadataByOwner retrieves a paginated recent metadata view for
@@ -447,9 +488,11 @@ func GetRecentFileMetadataByOwner(db *sql.DB, ownerUsername string, limit, offse
 
 	query := `
 		SELECT file_id, owner_username, password_type, filename_nonce, encrypted_filename,
-		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date
+		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date,
+		       COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+		       COALESCE(version_group, ''), version_number
 		FROM file_metadata
-		WHERE owner_username = ?
+		WHERE owner_username = ? AND deleted_at IS NULL AND ` + currentVersionCondition + `
 		ORDER BY upload_date DESC
 		LIMIT ? OFFSET ?`
 
@@ -489,9 +532,11 @@ func GetFileMetadataBatchByOwner(db *sql.DB, ownerUsername string, fileIDs []str
 	placeholders := strings.TrimRight(strings.Repeat("?,", len(fileIDs)), ",")
 	query := fmt.Sprintf(`
 		SELECT file_id, owner_username, password_type, filename_nonce, encrypted_filename,
-		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date
+		       sha256sum_nonce, encrypted_sha256sum, size_bytes, upload_date,
+		       COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+		       COALESCE(version_group, ''), version_number
 		FROM file_metadata
-		WHERE owner_username = ? AND file_id IN (%s)`, placeholders)
+		WHERE owner_username = ? AND file_id IN (%s) AND deleted_at IS NULL`, placeholders)
 
 	args := make([]interface{}, 0, len(fileIDs)+1)
 	args = append(args, ownerUsername)
@@ -527,6 +572,7 @@ func scanFileMetadataListItem(scanner interface {
 	item := &FileMetadataListItem{}
 	var sizeBytes interface{}
 	var uploadDateStr string
+	var versionNumber interface{}
 
 	err := scanner.Scan(
 		&item.FileID,
@@ -538,10 +584,15 @@ func scanFileMetadataListItem(scanner interface {
 		&item.EncryptedSha256sum,
 		&sizeBytes,
 		&uploadDateStr,
+		&item.FolderNonce,
+		&item.EncryptedFolder,
+		&item.VersionGroup,
+		&versionNumber,
 	)
 	if err != nil {
 		return nil, err
 	}
+	item.VersionNumber = max(toInt64Raw(versionNumber), 1)
 
 	switch v := sizeBytes.(type) {
 	case int64:
@@ -563,32 +614,24 @@ func scanFileMetadataListItem(scanner interface {
 	return item, nil
 }
 
-// DeleteFile removes a file record from the database by file_id
-func DeleteFile(db *sql.DB, fileID string, ownerUsername string) error {
-	result, err := db.Exec(
-		"DELETE FROM file_metadata WHERE file_id = ? AND owner_username = ?",
-		fileID, ownerUsername,
-	)
-	if err != nil {
-		return err
-	}
-
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if affected == 0 {
-		return errors.New("file not found or unauthorized")
-	}
-
-	return nil
+// RecordFileAccess sets last_accessed_at to now for a file being downloaded.
+// Storage tiering uses it to find blobs nobody has read in a while.
+func RecordFileAccess(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, fileID string) error {
+	_, err := db.Exec("UPDATE file_metadata SET last_accessed_at = CURRENT_TIMESTAMP WHERE file_id = ?", fileID)
+	return err
 }
 
 // FileMetadataForClient represents the encrypted metadata that gets sent to
 // the client. All binary data is Base64-encoded as strings for robust JSON
 // transport. OwnerUsername is included so the client can rebuild the
-// metadata AAD (encrypted_filename and encrypted_sha256sum decrypt require it).
+// metadata AAD (encrypted_filename, encrypted_sha256sum and encrypted_folder
+// decrypt require it). The folder fields are omitted for top-level files.
+// chunk_count is bound into every chunk's AAD, so clients that download
+// straight from a listing need it along with chunk_size_bytes. The version
+// fields place the file in its version chain; version_count is filled in by
+// listings.
 type FileMetadataForClient struct {
 	FileID             string    `json:"file_id"`
 	StorageID          string    `json:"storage_id"`
@@ -599,8 +642,15 @@ type FileMetadataForClient struct {
 	EncryptedFilename  string    `json:"encrypted_filename"`
 	Sha256sumNonce     string    `json:"sha256sum_nonce"`
 	EncryptedSha256sum string    `json:"encrypted_sha256sum"`
+	FolderNonce        string    `json:"folder_nonce,omitempty"`
+	EncryptedFolder    string    `json:"encrypted_folder,omitempty"`
 	EncryptedFEK       string    `json:"encrypted_fek"`
 	SizeBytes          int64     `json:"size_bytes"`
+	ChunkCount         int64     `json:"chunk_count"`
+	ChunkSizeBytes     int64     `json:"chunk_size_bytes"`
+	VersionGroup       string    `json:"version_group,omitempty"`
+	VersionNumber      int64     `json:"version_number"`
+	VersionCount       int       `json:"version_count"`
 	UploadDate         time.Time `json:"upload_date"`
 }
 
@@ -618,8 +668,15 @@ func (f *File) ToClientMetadata() *FileMetadataForClient {
 		EncryptedFilename:  f.EncryptedFilename,
 		Sha256sumNonce:     f.Sha256sumNonce,
 		EncryptedSha256sum: f.EncryptedSha256sum,
+		FolderNonce:        f.FolderNonce,
+		EncryptedFolder:    f.EncryptedFolder,
 		EncryptedFEK:       f.EncryptedFEK,
 		SizeBytes:          f.SizeBytes,
+		ChunkCount:         f.ChunkCount,
+		ChunkSizeBytes:     f.ChunkSizeBytes,
+		VersionGroup:       f.VersionGroup,
+		VersionNumber:      f.VersionNumber,
+		VersionCount:       1,
 		UploadDate:         f.UploadDate,
 	}
 }
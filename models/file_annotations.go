@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Annotations
+//
+// file_annotations holds a file's client-encrypted tags (a JSON object of
+// key/value strings) and note. Each is an AES-GCM ciphertext under the
+// owner's account key, AAD-bound to the file with its own field label, so
+// the server only stores and returns opaque values. A file without either
+// has no row; rows are removed with their file by the foreign key cascade.
+
+// FileAnnotations is the encrypted tags and note of a file.
+type FileAnnotations struct {
+	FileID        string    `json:"file_id"`
+	TagsNonce     string    `json:"tags_nonce,omitempty"`
+	EncryptedTags string    `json:"encrypted_tags,omitempty"`
+	NoteNonce     string    `json:"note_nonce,omitempty"`
+	EncryptedNote string    `json:"encrypted_note,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// EncryptedValue is a base64 ciphertext and its nonce. Both empty clears
+// the value.
+type EncryptedValue struct {
+	Ciphertext string
+	Nonce      string
+}
+
+// GetFileAnnotationsByOwner returns the annotations of a user's files,
+// keyed by file_id.
+func GetFileAnnotationsByOwner(db *sql.DB, ownerUsername string) (map[string]*FileAnnotations, error) {
+	rows, err := db.Query(`
+		SELECT file_id, COALESCE(tags_nonce, ''), COALESCE(encrypted_tags, ''),
+			   COALESCE(note_nonce, ''), COALESCE(encrypted_note, ''), updated_at
+		FROM file_annotations WHERE owner_username = ?`,
+		ownerUsername,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("annotations query failed for user '%s': %w", ownerUsername, err)
+	}
+	defer rows.Close()
+
+	annotations := make(map[string]*FileAnnotations)
+	for rows.Next() {
+		a := &FileAnnotations{}
+		var updatedAtStr string
+		if err := rows.Scan(&a.FileID, &a.TagsNonce, &a.EncryptedTags,
+			&a.NoteNonce, &a.EncryptedNote, &updatedAtStr); err != nil {
+			return nil, fmt.Errorf("failed to scan annotations for user '%s': %w", ownerUsername, err)
+		}
+		a.UpdatedAt = parseDBTimestamp(updatedAtStr)
+		annotations[a.FileID] = a
+	}
+	return annotations, rows.Err()
+}
+
+// UpdateFileAnnotations sets the tags and/or note of one of ownerUsername's
+// files; a nil value is left unchanged. Returns the annotations as stored.
+func UpdateFileAnnotations(db *sql.DB, fileID, ownerUsername string, tags, note *EncryptedValue) (*FileAnnotations, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT OR IGNORE INTO file_annotations (file_id, owner_username) VALUES (?, ?)`,
+		fileID, ownerUsername,
+	); err != nil {
+		return nil, fmt.Errorf("failed to create annotations: %w", err)
+	}
+	if tags != nil {
+		if _, err := tx.Exec(
+			`UPDATE file_annotations SET encrypted_tags = ?, tags_nonce = ?, updated_at = CURRENT_TIMESTAMP WHERE file_id = ?`,
+			tags.Ciphertext, tags.Nonce, fileID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to update tags: %w", err)
+		}
+	}
+	if note != nil {
+		if _, err := tx.Exec(
+			`UPDATE file_annotations SET encrypted_note = ?, note_nonce = ?, updated_at = CURRENT_TIMESTAMP WHERE file_id = ?`,
+			note.Ciphertext, note.Nonce, fileID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to update note: %w", err)
+		}
+	}
+
+	a := &FileAnnotations{FileID: fileID}
+	var updatedAtStr string
+	if err := tx.QueryRow(`
+		SELECT COALESCE(tags_nonce, ''), COALESCE(encrypted_tags, ''),
+			   COALESCE(note_nonce, ''), COALESCE(encrypted_note, ''), updated_at
+		FROM file_annotations WHERE file_id = ?`, fileID,
+	).Scan(&a.TagsNonce, &a.EncryptedTags, &a.NoteNonce, &a.EncryptedNote, &updatedAtStr); err != nil {
+		return nil, fmt.Errorf("failed to read annotations: %w", err)
+	}
+	a.UpdatedAt = parseDBTimestamp(updatedAtStr)
+
+	if a.EncryptedTags == "" && a.EncryptedNote == "" {
+		if _, err := tx.Exec(`DELETE FROM file_annotations WHERE file_id = ?`, fileID); err != nil {
+			return nil, fmt.Errorf("failed to remove empty annotations: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit annotations: %w", err)
+	}
+	return a, nil
+}
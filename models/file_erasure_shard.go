@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// FileErasureShard represents a row in the file_erasure_shards table: one
+// Reed-Solomon shard of a file stored under the erasure write policy. Every
+// row of a blob repeats the blob's layout (shard counts, stripe unit, size).
+type FileErasureShard struct {
+	FileID       string `json:"file_id"`
+	StorageID    string `json:"storage_id"`
+	ShardIndex   int    `json:"shard_index"`
+	DataShards   int    `json:"data_shards"`
+	ParityShards int    `json:"parity_shards"`
+	StripeUnit   int64  `json:"stripe_unit"`
+	ObjectSize   int64  `json:"object_size"`
+	ProviderID   string `json:"provider_id"`
+	ObjectName   string `json:"object_name"`
+	SHA256       string `json:"sha256"`
+	Status       string `json:"status"` // "active", "missing", "corrupt"
+}
+
+// InsertFileErasureShard records one stored shard of a file.
+func InsertFileErasureShard(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, shard *FileErasureShard) error {
+	status := shard.Status
+	if status == "" {
+		status = "active"
+	}
+	_, err := db.Exec(`
+		INSERT INTO file_erasure_shards (file_id, storage_id, shard_index, data_shards, parity_shards, stripe_unit, object_size, provider_id, object_name, sha256, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		shard.FileID, shard.StorageID, shard.ShardIndex, shard.DataShards, shard.ParityShards, shard.StripeUnit, shard.ObjectSize,
+		shard.ProviderID, shard.ObjectName, shard.SHA256, status,
+	)
+	return err
+}
+
+// GetErasureShardsByStorageID returns every shard row of the blob stored as
+// storageID, in shard order. Returns no rows for blobs stored as full replicas.
+func GetErasureShardsByStorageID(db interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, storageID string) ([]FileErasureShard, error) {
+	return queryErasureShards(db, "storage_id = ?", storageID)
+}
+
+// GetErasureShardsByFileID returns every shard row of a file, in shard order.
+func GetErasureShardsByFileID(db interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, fileID string) ([]FileErasureShard, error) {
+	return queryErasureShards(db, "file_id = ?", fileID)
+}
+
+// GetAllErasureShards returns every shard row, grouped by blob and in shard
+// order within each blob.
+func GetAllErasureShards(db interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}) ([]FileErasureShard, error) {
+	return queryErasureShards(db, "1 = 1")
+}
+
+func queryErasureShards(db interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, where string, args ...interface{}) ([]FileErasureShard, error) {
+	rows, err := db.Query(`
+		SELECT file_id, storage_id, shard_index, data_shards, parity_shards, stripe_unit, object_size, provider_id, object_name, sha256, status
+		FROM file_erasure_shards
+		WHERE `+where+`
+		ORDER BY storage_id, shard_index`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shards []FileErasureShard
+	for rows.Next() {
+		var shard FileErasureShard
+		// Scan numbers as interface{} because rqlite returns them as float64
+		var index, dataShards, parityShards, stripeUnit, objectSize interface{}
+		if err := rows.Scan(&shard.FileID, &shard.StorageID, &index, &dataShards, &parityShards, &stripeUnit, &objectSize,
+			&shard.ProviderID, &shard.ObjectName, &shard.SHA256, &shard.Status); err != nil {
+			return nil, err
+		}
+		shard.ShardIndex = int(toInt64Raw(index))
+		shard.DataShards = int(toInt64Raw(dataShards))
+		shard.ParityShards = int(toInt64Raw(parityShards))
+		shard.StripeUnit = toInt64Raw(stripeUnit)
+		shard.ObjectSize = toInt64Raw(objectSize)
+		shards = append(shards, shard)
+	}
+	return shards, rows.Err()
+}
+
+// UpdateFileErasureShardStatus updates the status of one shard of a blob.
+func UpdateFileErasureShardStatus(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, storageID string, shardIndex int, status string) error {
+	_, err := db.Exec(`
+		UPDATE file_erasure_shards
+		SET status = ?
+		WHERE storage_id = ? AND shard_index = ?`,
+		status, storageID, shardIndex,
+	)
+	return err
+}
+
+// MarkFileErasureShardVerified records that a shard was just read back and
+// matched its stored hash.
+func MarkFileErasureShardVerified(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, storageID string, shardIndex int) error {
+	_, err := db.Exec(`
+		UPDATE file_erasure_shards
+		SET verified_at = CURRENT_TIMESTAMP
+		WHERE storage_id = ? AND shard_index = ?`,
+		storageID, shardIndex,
+	)
+	return err
+}
+
+// SetFileErasureShardRebuilt records that a lost shard was rebuilt on
+// providerID: the shard is active and verified again.
+func SetFileErasureShardRebuilt(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, storageID string, shardIndex int, providerID string) error {
+	_, err := db.Exec(`
+		UPDATE file_erasure_shards
+		SET provider_id = ?, status = 'active', verified_at = CURRENT_TIMESTAMP
+		WHERE storage_id = ? AND shard_index = ?`,
+		providerID, storageID, shardIndex,
+	)
+	return err
+}
+
+// CountErasureShardsByProvider returns the number of active shards stored on a provider.
+func CountErasureShardsByProvider(db interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, providerID string) (int64, error) {
+	var countRaw interface{}
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM file_erasure_shards
+		WHERE provider_id = ? AND status = 'active'`, providerID,
+	).Scan(&countRaw)
+	return toInt64Raw(countRaw), err
+}
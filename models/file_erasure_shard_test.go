@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertFileErasureShard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO file_erasure_shards`).
+		WithArgs("file-1", "blob-1", 2, 2, 1, int64(1048576), int64(5000000), "prov-3", "blob-1.ec2", "abc", "active").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = InsertFileErasureShard(db, &FileErasureShard{
+		FileID: "file-1", StorageID: "blob-1", ShardIndex: 2, DataShards: 2, ParityShards: 1,
+		StripeUnit: 1048576, ObjectSize: 5000000, ProviderID: "prov-3", ObjectName: "blob-1.ec2", SHA256: "abc",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetErasureShardsByStorageID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"file_id", "storage_id", "shard_index", "data_shards", "parity_shards", "stripe_unit", "object_size", "provider_id", "object_name", "sha256", "status"}
+	mock.ExpectQuery(`SELECT file_id, storage_id, shard_index.* FROM file_erasure_shards\s+WHERE storage_id = \?`).
+		WithArgs("blob-1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("file-1", "blob-1", float64(0), float64(2), float64(1), float64(1048576), float64(5e6), "prov-1", "blob-1.ec0", "h0", "active").
+			AddRow("file-1", "blob-1", float64(1), float64(2), float64(1), float64(1048576), float64(5e6), "prov-2", "blob-1.ec1", "h1", "missing"))
+
+	shards, err := GetErasureShardsByStorageID(db, "blob-1")
+	require.NoError(t, err)
+	require.Len(t, shards, 2)
+	assert.Equal(t, 1, shards[1].ShardIndex)
+	assert.Equal(t, 2, shards[1].DataShards)
+	assert.Equal(t, int64(5000000), shards[1].ObjectSize)
+	assert.Equal(t, "missing", shards[1].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetFileErasureShardRebuilt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE file_erasure_shards\s+SET provider_id = \?, status = 'active', verified_at = CURRENT_TIMESTAMP\s+WHERE storage_id = \? AND shard_index = \?`).
+		WithArgs("prov-3", "blob-1", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, SetFileErasureShardRebuilt(db, "blob-1", 1, "prov-3"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
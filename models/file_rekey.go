@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Rename and re-key
+//
+// A file's name and its FEK envelope can be replaced without touching the
+// stored object: the client encrypts the new name, or unwraps the FEK with
+// the old KEK and wraps it again with the new one. The FEK itself never
+// changes, so the ciphertext in storage and any existing shares stay valid.
+
+// FileRekey is a re-wrapped FEK envelope and the password type it was
+// wrapped for. SHA256 optionally replaces the encrypted SHA-256 in the same
+// statement, for re-keys that also change the metadata key (accepting a
+// file drop upload).
+type FileRekey struct {
+	EncryptedFEK string
+	PasswordType string
+	PasswordHint string
+	SHA256       *EncryptedValue
+}
+
+// RenameOrRekeyFile replaces the encrypted filename and/or the FEK envelope
+// of one of ownerUsername's files in one statement, so encrypted_fek and
+// password_type always change together. A nil argument is left unchanged.
+// Renaming clears the file's search tokens, which were derived from the old
+// name; searchTokensCleared reports whether there were any. Returns
+// sql.ErrNoRows if the user has no such file outside the trash.
+func RenameOrRekeyFile(db *sql.DB, fileID, ownerUsername string, filename *EncryptedValue, rekey *FileRekey) (searchTokensCleared bool, err error) {
+	var sets []string
+	var args []interface{}
+	if filename != nil {
+		sets = append(sets, "encrypted_filename = ?", "filename_nonce = ?")
+		args = append(args, filename.Ciphertext, filename.Nonce)
+	}
+	if rekey != nil {
+		sets = append(sets, "encrypted_fek = ?", "password_type = ?", "password_hint = ?")
+		args = append(args, rekey.EncryptedFEK, rekey.PasswordType, rekey.PasswordHint)
+		if rekey.SHA256 != nil {
+			sets = append(sets, "encrypted_sha256sum = ?", "sha256sum_nonce = ?")
+			args = append(args, rekey.SHA256.Ciphertext, rekey.SHA256.Nonce)
+		}
+	}
+	if len(sets) == 0 {
+		return false, fmt.Errorf("nothing to update")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args = append(args, fileID, ownerUsername)
+	result, err := tx.Exec(
+		`UPDATE file_metadata SET `+strings.Join(sets, ", ")+`
+		 WHERE file_id = ? AND owner_username = ? AND deleted_at IS NULL`,
+		args...,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update file: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return false, sql.ErrNoRows
+	}
+
+	if filename != nil {
+		result, err := tx.Exec(`DELETE FROM file_search_tokens WHERE file_id = ?`, fileID)
+		if err != nil {
+			return false, fmt.Errorf("failed to clear search tokens: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			searchTokensCleared = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit file update: %w", err)
+	}
+	return searchTokensCleared, nil
+}
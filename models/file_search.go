@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Search index
+//
+// file_search_tokens holds the blind-index tokens a client has computed for
+// each of its files (crypto.BlindIndexToken). The server only ever compares
+// tokens, so searching works without it learning filenames or query words.
+// Tokens are removed with their file by the foreign key cascade.
+
+// SetFileSearchTokens replaces the search tokens of one of ownerUsername's
+// files. An empty token list removes the file from the index.
+func SetFileSearchTokens(db *sql.DB, fileID, ownerUsername string, tokens []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM file_search_tokens WHERE file_id = ?`, fileID); err != nil {
+		return fmt.Errorf("failed to clear search tokens: %w", err)
+	}
+	for _, token := range tokens {
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO file_search_tokens (file_id, owner_username, token) VALUES (?, ?, ?)`,
+			fileID, ownerUsername, token,
+		); err != nil {
+			return fmt.Errorf("failed to store search token: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SearchFilesByTokens returns the IDs of ownerUsername's files that carry
+// every one of tokens, newest first and at most limit of them. Only current
+// versions outside the trash are matched.
+func SearchFilesByTokens(db *sql.DB, ownerUsername string, tokens []string, limit int) ([]string, error) {
+	if len(tokens) == 0 {
+		return []string{}, nil
+	}
+
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(tokens)), ",")
+	query := fmt.Sprintf(`
+		SELECT file_metadata.file_id FROM file_search_tokens t
+		JOIN file_metadata ON file_metadata.file_id = t.file_id
+		WHERE t.owner_username = ? AND t.token IN (%s)
+		  AND file_metadata.deleted_at IS NULL AND %s
+		GROUP BY file_metadata.file_id
+		HAVING COUNT(DISTINCT t.token) = ?
+		ORDER BY MAX(file_metadata.upload_date) DESC
+		LIMIT ?`, placeholders, currentVersionCondition)
+
+	args := make([]interface{}, 0, len(tokens)+3)
+	args = append(args, ownerUsername)
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+	args = append(args, len(tokens), limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search query failed for user '%s': %w", ownerUsername, err)
+	}
+	defer rows.Close()
+
+	fileIDs := []string{}
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, err
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	return fileIDs, rows.Err()
+}
+
+// CountIndexedFiles returns how many of ownerUsername's files have search
+// tokens, so clients can tell an empty result from a missing index.
+func CountIndexedFiles(db *sql.DB, ownerUsername string) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(DISTINCT file_id) FROM file_search_tokens WHERE owner_username = ?`,
+		ownerUsername,
+	).Scan(&count)
+	return count, err
+}
@@ -11,7 +11,7 @@ type FileStorageLocation struct {
 	FileID     string         `json:"file_id"`
 	ProviderID string         `json:"provider_id"`
 	StorageID  string         `json:"storage_id"`
-	Status     string         `json:"status"` // "active", "pending", "failed", "deleted", "delete_failed"
+	Status     string         `json:"status"` // "active", "pending", "failed", "deleted", "delete_failed", "missing", "corrupt", "tiered"
 	CreatedAt  sql.NullString `json:"created_at"`
 	VerifiedAt sql.NullString `json:"verified_at"`
 }
@@ -41,6 +41,40 @@ func UpdateFileStorageLocationStatus(db interface {
 	return err
 }
 
+// SetFileStorageLocationStatus sets the status of the location record for a file
+// on a provider, creating the record if the file has none on that provider yet.
+func SetFileStorageLocationStatus(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, fileID, providerID, storageID, status string) error {
+	result, err := db.Exec(`
+		UPDATE file_storage_locations
+		SET status = ?
+		WHERE file_id = ? AND provider_id = ?`,
+		status, fileID, providerID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	return InsertFileStorageLocation(db, fileID, providerID, storageID, status)
+}
+
+// MarkFileStorageLocationVerified records that the copy of a file on a provider
+// was read back and matched its stored hash.
+func MarkFileStorageLocationVerified(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, fileID, providerID string) error {
+	_, err := db.Exec(`
+		UPDATE file_storage_locations
+		SET verified_at = CURRENT_TIMESTAMP
+		WHERE file_id = ? AND provider_id = ?`,
+		fileID, providerID,
+	)
+	return err
+}
+
 // GetFileStorageLocations returns all location records for a given file.
 func GetFileStorageLocations(db interface {
 	Query(string, ...interface{}) (*sql.Rows, error)
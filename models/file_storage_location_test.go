@@ -88,6 +88,51 @@ func TestUpdateFileStorageLocationStatus(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestSetFileStorageLocationStatus_UpdatesExisting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE file_storage_locations`).
+		WithArgs("pending", "file-1", "prov-2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = SetFileStorageLocationStatus(db, "file-1", "prov-2", "stor-1", "pending")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetFileStorageLocationStatus_InsertsMissing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE file_storage_locations`).
+		WithArgs("pending", "file-1", "prov-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO file_storage_locations`).
+		WithArgs("file-1", "prov-2", "stor-1", "pending").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	err = SetFileStorageLocationStatus(db, "file-1", "prov-2", "stor-1", "pending")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkFileStorageLocationVerified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE file_storage_locations SET verified_at = CURRENT_TIMESTAMP`).
+		WithArgs("file-1", "prov-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = MarkFileStorageLocationVerified(db, "file-1", "prov-1")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestBackfillFileStorageLocations(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Trash
+//
+// Deleting a file moves it to its owner's trash by setting
+// file_metadata.deleted_at; the stored objects are left untouched. Trashed
+// files disappear from listings, downloads and shares but can be restored
+// until the retention period (storage.trash_retention_days) runs out, after
+// which a background job deletes them for good. They keep counting toward
+// the owner's total_storage_bytes until then.
+
+// TrashedFile is a file in its owner's trash.
+type TrashedFile struct {
+	*File
+	DeletedAt time.Time
+}
+
+// TrashedFileRef names a file in the trash.
+type TrashedFileRef struct {
+	FileID        string
+	OwnerUsername string
+}
+
+// TrashFile moves one of ownerUsername's files to the trash. Returns
+// sql.ErrNoRows if the user has no such file outside the trash.
+func TrashFile(db DBTX, fileID, ownerUsername string) error {
+	result, err := db.Exec(
+		`UPDATE file_metadata SET deleted_at = CURRENT_TIMESTAMP
+		 WHERE file_id = ? AND owner_username = ? AND deleted_at IS NULL`,
+		fileID, ownerUsername,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RestoreFile takes one of ownerUsername's files out of the trash. Returns
+// sql.ErrNoRows if the file is not in the user's trash.
+func RestoreFile(db DBTX, fileID, ownerUsername string) error {
+	result, err := db.Exec(
+		`UPDATE file_metadata SET deleted_at = NULL
+		 WHERE file_id = ? AND owner_username = ? AND deleted_at IS NOT NULL`,
+		fileID, ownerUsername,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrashedFilesByOwner returns the files in a user's trash, most recently
+// deleted first.
+func GetTrashedFilesByOwner(db *sql.DB, ownerUsername string) ([]*TrashedFile, error) {
+	rows, err := db.Query(`
+		SELECT id, file_id, storage_id, owner_username, password_hint, password_type,
+			   filename_nonce, encrypted_filename, sha256sum_nonce, encrypted_sha256sum,
+			   COALESCE(encrypted_file_sha256sum, ''), encrypted_fek, size_bytes, padded_size,
+			   chunk_count, chunk_size_bytes, upload_date,
+			   COALESCE(folder_nonce, ''), COALESCE(encrypted_folder, ''),
+			   COALESCE(version_group, ''), version_number, deleted_at
+		FROM file_metadata WHERE owner_username = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`,
+		ownerUsername,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trash query failed for user '%s': %w", ownerUsername, err)
+	}
+	defer rows.Close()
+
+	var files []*TrashedFile
+	for rows.Next() {
+		var deletedAtStr string
+		file, err := scanFileRow(rows, &deletedAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash row for user '%s': %w", ownerUsername, err)
+		}
+		files = append(files, &TrashedFile{File: file, DeletedAt: parseDBTimestamp(deletedAtStr)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("trash rows iteration error for user '%s': %w", ownerUsername, err)
+	}
+	return files, nil
+}
+
+// GetExpiredTrash returns the files that have been in the trash for longer
+// than retentionDays.
+func GetExpiredTrash(db DBTX, retentionDays int) ([]TrashedFileRef, error) {
+	rows, err := db.Query(
+		`SELECT file_id, owner_username FROM file_metadata
+		 WHERE deleted_at IS NOT NULL AND deleted_at < datetime('now', ?)`,
+		fmt.Sprintf("-%d days", retentionDays),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired trash: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []TrashedFileRef
+	for rows.Next() {
+		var ref TrashedFileRef
+		if err := rows.Scan(&ref.FileID, &ref.OwnerUsername); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
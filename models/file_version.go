@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// File versions
+//
+// An upload can be marked as a new version of an existing file. The versions
+// of one file form a chain that shares a version_group (the file_id of the
+// first version) and is numbered from 1 in upload order. Every version is a
+// complete, independent file with its own file_id, FEK and chunks, so
+// downloads, shares and exports work on any version unchanged.
+//
+// Listings show only the newest version of each chain unless asked for all
+// of them. Older versions keep their storage and still count toward the
+// owner's total_storage_bytes (and therefore quota and billing) until they
+// are deleted, or pruned by the owner's retention setting
+// (users.version_retention; 0 keeps every version).
+
+// currentVersionCondition restricts a file_metadata query to the newest
+// version of each chain. Files that were never versioned have a NULL
+// version_group and always match.
+const currentVersionCondition = `NOT EXISTS (
+		SELECT 1 FROM file_metadata newer
+		WHERE newer.version_group = file_metadata.version_group
+		  AND newer.version_number > file_metadata.version_number
+		  AND newer.deleted_at IS NULL)`
+
+// MaxVersionRetention bounds the retention setting; larger values behave
+// like 0 (keep everything) in practice and are rejected to catch typos.
+const MaxVersionRetention = 1000
+
+// FileVersion identifies one stored version of a file.
+type FileVersion struct {
+	FileID        string
+	VersionNumber int64
+}
+
+// VersionGroupID returns the identifier of the file's version chain: its
+// version_group, or its own file_id if it was never versioned.
+func (f *File) VersionGroupID() string {
+	if f.VersionGroup != "" {
+		return f.VersionGroup
+	}
+	return f.FileID
+}
+
+// GetFileVersions returns the versions of a chain owned by ownerUsername,
+// newest first. Versions in the trash are not included.
+func GetFileVersions(db DBTX, ownerUsername, versionGroup string) ([]FileVersion, error) {
+	rows, err := db.Query(
+		`SELECT file_id, version_number FROM file_metadata
+		 WHERE owner_username = ? AND (version_group = ? OR file_id = ?) AND deleted_at IS NULL
+		 ORDER BY version_number DESC`,
+		ownerUsername, versionGroup, versionGroup,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %w", versionGroup, err)
+	}
+	defer rows.Close()
+
+	var versions []FileVersion
+	for rows.Next() {
+		var v FileVersion
+		var number interface{}
+		if err := rows.Scan(&v.FileID, &number); err != nil {
+			return nil, err
+		}
+		v.VersionNumber = max(toInt64Raw(number), 1)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// NextFileVersion links a version chain rooted at versionGroup (starting it
+// if the root file was never versioned) and returns the number the next
+// version takes. It must run in the transaction that inserts that version.
+// Versions in the trash keep their numbers, so a restored version never
+// collides with a newer one.
+func NextFileVersion(tx *sql.Tx, ownerUsername, versionGroup string) (int64, error) {
+	if _, err := tx.Exec(
+		`UPDATE file_metadata SET version_group = file_id
+		 WHERE file_id = ? AND owner_username = ? AND version_group IS NULL`,
+		versionGroup, ownerUsername,
+	); err != nil {
+		return 0, fmt.Errorf("failed to start version chain %s: %w", versionGroup, err)
+	}
+	var highest interface{}
+	if err := tx.QueryRow(
+		`SELECT MAX(version_number) FROM file_metadata WHERE owner_username = ? AND version_group = ?`,
+		ownerUsername, versionGroup,
+	).Scan(&highest); err != nil {
+		return 0, fmt.Errorf("failed to number version of %s: %w", versionGroup, err)
+	}
+	return toInt64Raw(highest) + 1, nil
+}
+
+// GetVersionRetention returns how many versions of each file the user keeps;
+// 0 keeps all of them.
+func GetVersionRetention(db DBTX, username string) (int, error) {
+	var keep interface{}
+	err := db.QueryRow("SELECT version_retention FROM users WHERE username = ?", username).Scan(&keep)
+	if err != nil {
+		return 0, err
+	}
+	return int(toInt64Raw(keep)), nil
+}
+
+// SetVersionRetention stores the user's retention setting. Returns
+// sql.ErrNoRows if the user does not exist.
+func SetVersionRetention(db DBTX, username string, keep int) error {
+	if keep < 0 || keep > MaxVersionRetention {
+		return fmt.Errorf("version retention must be between 0 and %d", MaxVersionRetention)
+	}
+	result, err := db.Exec("UPDATE users SET version_retention = ? WHERE username = ?", keep, username)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetVersionGroupsOverRetention returns the user's version chains that hold
+// more than keep versions outside the trash.
+func GetVersionGroupsOverRetention(db DBTX, ownerUsername string, keep int) ([]string, error) {
+	rows, err := db.Query(
+		`SELECT version_group FROM file_metadata
+		 WHERE owner_username = ? AND version_group IS NOT NULL AND deleted_at IS NULL
+		 GROUP BY version_group HAVING COUNT(*) > ?`,
+		ownerUsername, keep,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find version chains over retention: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []string
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPlacementPolicyInUse is returned by DeletePlacementPolicy while users are
+// still assigned to the policy.
+var ErrPlacementPolicyInUse = errors.New("placement policy is assigned to one or more users")
+
+// PlacementPolicy is a named storage placement rule. Every user assigned to the
+// policy has their files stored only on ProviderIDs, with Copies verified
+// copies per file. ProviderIDs is ordered: new uploads land on the first
+// available provider and replicas go to the next ones.
+type PlacementPolicy struct {
+	Name        string   `json:"name"`
+	ProviderIDs []string `json:"provider_ids"`
+	Copies      int      `json:"copies"`
+	Description string   `json:"description,omitempty"`
+	UserCount   int64    `json:"user_count"`
+	CreatedAt   string   `json:"created_at,omitempty"`
+	UpdatedAt   string   `json:"updated_at,omitempty"`
+}
+
+// Allows reports whether the policy permits a copy on providerID.
+func (p *PlacementPolicy) Allows(providerID string) bool {
+	for _, id := range p.ProviderIDs {
+		if id == providerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the policy has a name, at least one distinct provider,
+// and a copy count the provider list can satisfy.
+func (p *PlacementPolicy) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("policy name is required")
+	}
+	if len(p.ProviderIDs) == 0 {
+		return fmt.Errorf("at least one provider is required")
+	}
+	seen := make(map[string]bool, len(p.ProviderIDs))
+	for _, id := range p.ProviderIDs {
+		if id == "" {
+			return fmt.Errorf("provider IDs must not be empty")
+		}
+		if seen[id] {
+			return fmt.Errorf("provider %s is listed more than once", id)
+		}
+		seen[id] = true
+	}
+	if p.Copies < 1 || p.Copies > len(p.ProviderIDs) {
+		return fmt.Errorf("copies must be between 1 and %d (the number of listed providers)", len(p.ProviderIDs))
+	}
+	return nil
+}
+
+// UpsertPlacementPolicy creates the policy or replaces the providers, copies,
+// and description of an existing policy with the same name.
+func UpsertPlacementPolicy(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, p *PlacementPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	providersJSON, err := json.Marshal(p.ProviderIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO storage_placement_policies (name, provider_ids, copies, description)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			provider_ids = excluded.provider_ids,
+			copies = excluded.copies,
+			description = excluded.description,
+			updated_at = CURRENT_TIMESTAMP`,
+		p.Name, string(providersJSON), p.Copies, p.Description,
+	)
+	return err
+}
+
+// GetPlacementPolicy returns the named policy, or sql.ErrNoRows if it does not exist.
+func GetPlacementPolicy(db interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, name string) (*PlacementPolicy, error) {
+	var p PlacementPolicy
+	var providersJSON string
+	var copiesRaw, userCountRaw interface{}
+	var description sql.NullString
+	err := db.QueryRow(`
+		SELECT p.name, p.provider_ids, p.copies, p.description,
+		       (SELECT COUNT(*) FROM users u WHERE u.placement_policy = p.name),
+		       p.created_at, p.updated_at
+		FROM storage_placement_policies p WHERE p.name = ?`, name,
+	).Scan(&p.Name, &providersJSON, &copiesRaw, &description, &userCountRaw, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(providersJSON), &p.ProviderIDs); err != nil {
+		return nil, fmt.Errorf("invalid provider list for placement policy %s: %w", name, err)
+	}
+	p.Copies = int(toInt64Raw(copiesRaw))
+	p.Description = description.String
+	p.UserCount = toInt64Raw(userCountRaw)
+	return &p, nil
+}
+
+// ListPlacementPolicies returns every placement policy ordered by name.
+func ListPlacementPolicies(db interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}) ([]PlacementPolicy, error) {
+	rows, err := db.Query(`
+		SELECT p.name, p.provider_ids, p.copies, p.description,
+		       (SELECT COUNT(*) FROM users u WHERE u.placement_policy = p.name),
+		       p.created_at, p.updated_at
+		FROM storage_placement_policies p ORDER BY p.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []PlacementPolicy{}
+	for rows.Next() {
+		var p PlacementPolicy
+		var providersJSON string
+		var copiesRaw, userCountRaw interface{}
+		var description sql.NullString
+		if err := rows.Scan(&p.Name, &providersJSON, &copiesRaw, &description, &userCountRaw, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(providersJSON), &p.ProviderIDs); err != nil {
+			return nil, fmt.Errorf("invalid provider list for placement policy %s: %w", p.Name, err)
+		}
+		p.Copies = int(toInt64Raw(copiesRaw))
+		p.Description = description.String
+		p.UserCount = toInt64Raw(userCountRaw)
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// DeletePlacementPolicy removes the named policy. It refuses with
+// ErrPlacementPolicyInUse while any user is still assigned to it, and returns
+// sql.ErrNoRows if the policy does not exist.
+func DeletePlacementPolicy(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	QueryRow(string, ...interface{}) *sql.Row
+}, name string) error {
+	var countRaw interface{}
+	if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE placement_policy = ?", name).Scan(&countRaw); err != nil {
+		return err
+	}
+	if toInt64Raw(countRaw) > 0 {
+		return ErrPlacementPolicyInUse
+	}
+
+	result, err := db.Exec("DELETE FROM storage_placement_policies WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUserPlacementPolicy assigns the named policy to a user. An empty name
+// clears the assignment, returning the user to the server-wide write policy.
+// Returns sql.ErrNoRows if the user does not exist.
+func SetUserPlacementPolicy(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, username, policyName string) error {
+	var value interface{}
+	if policyName != "" {
+		value = policyName
+	}
+	result, err := db.Exec("UPDATE users SET placement_policy = ? WHERE username = ?", value, username)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserPlacementPolicy returns the placement policy assigned to a user, or
+// nil if the user has none.
+func GetUserPlacementPolicy(db interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, username string) (*PlacementPolicy, error) {
+	var policyName sql.NullString
+	err := db.QueryRow("SELECT placement_policy FROM users WHERE username = ?", username).Scan(&policyName)
+	if err == sql.ErrNoRows || (err == nil && policyName.String == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetPlacementPolicy(db, policyName.String)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacementPolicyValidate(t *testing.T) {
+	valid := &PlacementPolicy{Name: "eu", ProviderIDs: []string{"hetzner", "r2-eu"}, Copies: 2}
+	assert.NoError(t, valid.Validate())
+	assert.True(t, valid.Allows("r2-eu"))
+	assert.False(t, valid.Allows("wasabi-us"))
+
+	for name, p := range map[string]*PlacementPolicy{
+		"no name":        {ProviderIDs: []string{"a"}, Copies: 1},
+		"no providers":   {Name: "x", Copies: 1},
+		"duplicate":      {Name: "x", ProviderIDs: []string{"a", "a"}, Copies: 1},
+		"too many":       {Name: "x", ProviderIDs: []string{"a"}, Copies: 2},
+		"zero copies":    {Name: "x", ProviderIDs: []string{"a"}, Copies: 0},
+		"empty provider": {Name: "x", ProviderIDs: []string{""}, Copies: 1},
+	} {
+		assert.Error(t, p.Validate(), name)
+	}
+}
+
+func TestUpsertPlacementPolicy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO storage_placement_policies`).
+		WithArgs("eu", `["hetzner","r2-eu"]`, 2, "EU residency").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = UpsertPlacementPolicy(db, &PlacementPolicy{Name: "eu", ProviderIDs: []string{"hetzner", "r2-eu"}, Copies: 2, Description: "EU residency"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserPlacementPolicy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// No policy assigned
+	mock.ExpectQuery(`SELECT placement_policy FROM users`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"placement_policy"}).AddRow(nil))
+
+	policy, err := GetUserPlacementPolicy(db, "bob")
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	// Assigned policy
+	mock.ExpectQuery(`SELECT placement_policy FROM users`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"placement_policy"}).AddRow("eu"))
+	mock.ExpectQuery(`SELECT p.name, p.provider_ids, p.copies`).
+		WithArgs("eu").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "provider_ids", "copies", "description", "user_count", "created_at", "updated_at"}).
+			AddRow("eu", `["hetzner","r2-eu"]`, float64(2), nil, float64(1), "2026-01-01 00:00:00", "2026-01-01 00:00:00"))
+
+	policy, err = GetUserPlacementPolicy(db, "alice")
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, []string{"hetzner", "r2-eu"}, policy.ProviderIDs)
+	assert.Equal(t, 2, policy.Copies)
+	assert.Equal(t, int64(1), policy.UserCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePlacementPolicy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Still assigned
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE placement_policy = \?`).
+		WithArgs("eu").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	assert.ErrorIs(t, DeletePlacementPolicy(db, "eu"), ErrPlacementPolicyInUse)
+
+	// Unknown policy
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE placement_policy = \?`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectExec(`DELETE FROM storage_placement_policies`).
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, sql.ErrNoRows, DeletePlacementPolicy(db, "missing"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
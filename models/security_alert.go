@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Alert types written to the security_alerts table.
+const (
+	// AlertTypeStorageIntegrity is raised when a stored blob no longer hashes
+	// to its recorded stored_blob_sha256sum.
+	AlertTypeStorageIntegrity = "storage_integrity_mismatch"
+)
+
+// CreateSecurityAlert inserts a row into security_alerts. entityID associates
+// the alert with the affected object (for storage alerts, the file ID); details
+// is stored as JSON text and may be nil.
+func CreateSecurityAlert(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, alertType, severity, entityID, message string, details map[string]interface{}) error {
+	var detailsJSON sql.NullString
+	if details != nil {
+		raw, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO security_alerts (alert_type, severity, entity_id, time_window, message, details)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		alertType, severity, entityID, time.Now().UTC().Format("2006-01-02"), message, detailsJSON,
+	)
+	return err
+}
+
+// CountUnacknowledgedSecurityAlerts returns the number of alerts of the given
+// type that no admin has acknowledged yet.
+func CountUnacknowledgedSecurityAlerts(db interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, alertType string) (int64, error) {
+	var countRaw interface{}
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM security_alerts
+		WHERE alert_type = ? AND (acknowledged IS NULL OR acknowledged = false)`, alertType,
+	).Scan(&countRaw)
+	return toInt64Raw(countRaw), err
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSecurityAlert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO security_alerts`).
+		WithArgs(AlertTypeStorageIntegrity, "CRITICAL", "file-1", sqlmock.AnyArg(), "hash mismatch", `{"provider_id":"prov-1"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = CreateSecurityAlert(db, AlertTypeStorageIntegrity, "CRITICAL", "file-1", "hash mismatch",
+		map[string]interface{}{"provider_id": "prov-1"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountUnacknowledgedSecurityAlerts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM security_alerts`).
+		WithArgs(AlertTypeStorageIntegrity).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(float64(3)))
+
+	count, err := CountUnacknowledgedSecurityAlerts(db, AlertTypeStorageIntegrity)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
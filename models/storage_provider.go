@@ -87,6 +87,20 @@ func GetStorageProviderRole(db interface {
 	return role, err
 }
 
+// SetStorageProviderActive sets the is_active flag for a provider. Inactive
+// providers keep their record but receive no new copies.
+func SetStorageProviderActive(db interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, providerID string, active bool) error {
+	_, err := db.Exec(`
+		UPDATE storage_providers
+		SET is_active = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE provider_id = ?`,
+		active, providerID,
+	)
+	return err
+}
+
 // UpdateStorageProviderStats updates the cached object count and size for a provider.
 func UpdateStorageProviderStats(db interface {
 	Exec(string, ...interface{}) (sql.Result, error)
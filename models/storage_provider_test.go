@@ -188,3 +188,17 @@ func TestIncrementStorageProviderStats_Decrement(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestSetStorageProviderActive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE storage_providers SET is_active = \?`).
+		WithArgs(false, "prov-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = SetStorageProviderActive(db, "prov-1", false)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
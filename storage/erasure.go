@@ -0,0 +1,625 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Erasure-coded storage: instead of keeping full replicas, a blob is cut into
+// stripes and Reed-Solomon encoded into k data shards and m parity shards, one
+// per configured provider. Any k shards are enough to read the blob, so a 2+1
+// layout across three providers survives the loss of any one of them at 1.5x
+// storage overhead instead of the 3x of three full replicas.
+//
+// The code is github.com/klauspost/reedsolomon's default systematic
+// Vandermonde code over GF(2^8): data shards hold the blob's bytes unchanged,
+// and bytes at the same offset in every shard form one codeword, so any
+// aligned byte range of the shards can be encoded or rebuilt on its own.
+
+// maxErasureShards is the most shards (k+m) a GF(2^8) code supports.
+const maxErasureShards = 256
+
+// ErasureStripeUnit is the largest number of bytes a stripe places on each
+// shard. Small blobs use a smaller unit (see erasureStripeUnit) so that
+// zero-padding the last stripe does not dwarf the blob itself.
+const ErasureStripeUnit = 1024 * 1024
+
+// erasureUnitAlign is the granularity small stripe units are rounded up to.
+const erasureUnitAlign = 64
+
+// erasurePartStripes is how many stripes are encoded and written per round:
+// each shard is uploaded in parts of erasurePartStripes*StripeUnit bytes (8 MB
+// at the default unit), and full-object reads fetch the same amount per shard.
+const erasurePartStripes = 8
+
+// ErasureShard is one stored shard of an erasure-coded blob.
+type ErasureShard struct {
+	Index      int // 0..k-1 are data shards, k..k+m-1 parity shards
+	ProviderID string
+	ObjectName string
+	SHA256     string // hash of the whole shard object
+}
+
+// ErasureLayout describes how a blob was striped into Reed-Solomon shards.
+//
+// The blob is cut into stripes of DataShards*StripeUnit bytes, the last one
+// zero-padded. Stripe s stores its i-th StripeUnit bytes at offset
+// s*StripeUnit of data shard i, and the matching parity bytes at the same
+// offset of every parity shard. Shards lists the shards available to read
+// from; a lost shard is simply absent.
+type ErasureLayout struct {
+	DataShards   int
+	ParityShards int
+	StripeUnit   int64
+	ObjectSize   int64
+	Shards       []ErasureShard
+}
+
+// ShardSize returns the size in bytes of every shard object.
+func (l *ErasureLayout) ShardSize() int64 {
+	stripe := int64(l.DataShards) * l.StripeUnit
+	return (l.ObjectSize + stripe - 1) / stripe * l.StripeUnit
+}
+
+// ErasureShardName returns the object name of shard index of objectName.
+func ErasureShardName(objectName string, index int) string {
+	return fmt.Sprintf("%s.ec%d", objectName, index)
+}
+
+// erasureStripeUnit picks the stripe unit for a blob of objectSize bytes:
+// ErasureStripeUnit, or for blobs smaller than one full stripe, an even split
+// of the blob across the data shards.
+func erasureStripeUnit(objectSize int64, dataShards int) int64 {
+	perShard := (objectSize + int64(dataShards) - 1) / int64(dataShards)
+	if perShard >= ErasureStripeUnit {
+		return ErasureStripeUnit
+	}
+	unit := (perShard + erasureUnitAlign - 1) / erasureUnitAlign * erasureUnitAlign
+	return max(unit, erasureUnitAlign)
+}
+
+// ErasureLayoutFunc looks up the erasure layout of a stored object by name.
+// It returns nil and no error for objects stored as full replicas.
+type ErasureLayoutFunc func(ctx context.Context, objectName string) (*ErasureLayout, error)
+
+// ParseErasureShards parses a STORAGE_ERASURE_SHARDS value of the form "K+M",
+// for example "2+1", into data and parity shard counts.
+func ParseErasureShards(value string) (int, int, error) {
+	k, m, ok := strings.Cut(strings.TrimSpace(value), "+")
+	if ok {
+		dataShards, errK := strconv.Atoi(strings.TrimSpace(k))
+		parityShards, errM := strconv.Atoi(strings.TrimSpace(m))
+		if errK == nil && errM == nil && dataShards >= 1 && parityShards >= 1 && dataShards+parityShards <= maxErasureShards {
+			return dataShards, parityShards, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid erasure shard setting %q (expected K+M, e.g. 2+1)", value)
+}
+
+// SetErasureCoding sets the data and parity shard counts used by the erasure
+// write policy. Every shard goes to a different provider, so k+m must not
+// exceed the number of configured providers.
+func (r *ProviderRegistry) SetErasureCoding(dataShards, parityShards int) error {
+	if _, err := reedsolomon.New(dataShards, parityShards); err != nil {
+		return err
+	}
+	configured := r.ConfiguredProviderCount()
+	if dataShards+parityShards > configured {
+		return fmt.Errorf("erasure coding %d+%d needs %d providers but only %d are configured", dataShards, parityShards, dataShards+parityShards, configured)
+	}
+	r.erasureData = dataShards
+	r.erasureParity = parityShards
+	return nil
+}
+
+// ErasureShards returns the configured data and parity shard counts, or 0, 0
+// when erasure coding is not configured.
+func (r *ProviderRegistry) ErasureShards() (int, int) {
+	return r.erasureData, r.erasureParity
+}
+
+// SetErasureLayoutSource installs the lookup used by the read paths to
+// recognize erasure-coded objects. Without one, every object is read as a
+// full replica.
+func (r *ProviderRegistry) SetErasureLayoutSource(fn ErasureLayoutFunc) {
+	r.erasureLayouts = fn
+}
+
+func (r *ProviderRegistry) lookupErasureLayout(ctx context.Context, objectName string) (*ErasureLayout, error) {
+	if r.erasureLayouts == nil {
+		return nil, nil
+	}
+	layout, err := r.erasureLayouts(ctx, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up erasure layout for %s: %w", objectName, err)
+	}
+	return layout, nil
+}
+
+// shardUpload tracks one shard being written by StripeObject.
+type shardUpload struct {
+	provider ObjectStorageProvider
+	name     string
+	uploadID string // multipart upload in progress
+	parts    []CompletePart
+	written  bool // object exists and must be removed on failure
+	hasher   hash.Hash
+}
+
+func (u *shardUpload) write(ctx context.Context, data []byte, partNumber int) error {
+	u.hasher.Write(data)
+	if u.uploadID == "" {
+		if _, err := u.provider.PutObject(ctx, u.name, bytes.NewReader(data), int64(len(data)), PutObjectOptions{
+			ContentType: "application/octet-stream",
+		}); err != nil {
+			return fmt.Errorf("failed to put shard %s: %w", u.name, err)
+		}
+		u.written = true
+		return nil
+	}
+	part, err := u.provider.UploadPart(ctx, u.name, u.uploadID, partNumber, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d of shard %s: %w", partNumber, u.name, err)
+	}
+	u.parts = append(u.parts, part)
+	return nil
+}
+
+func (u *shardUpload) discard() {
+	ctx := context.Background()
+	if u.uploadID != "" {
+		u.provider.AbortMultipartUpload(ctx, u.name, u.uploadID)
+	}
+	if u.written {
+		u.provider.RemoveObject(ctx, u.name, RemoveObjectOptions{})
+	}
+}
+
+// readFullPadded fills buf from r, zero-filling whatever is left once r is
+// exhausted. Returns the number of bytes actually read.
+func readFullPadded(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		clear(buf[n:])
+		return n, nil
+	}
+	return n, err
+}
+
+// StripeObject reads objectName from the source provider, Reed-Solomon encodes
+// it with the configured k+m and writes shard i to the i-th configured
+// provider as ErasureShardName(objectName, i). The blob's SHA-256 is checked
+// against expectedSHA256 (skipped when empty) as it is read, and the returned
+// layout carries the SHA-256 of every shard.
+//
+// Shards are written in rounds of erasurePartStripes stripes, so memory use is
+// bounded by (k+m) parts regardless of the blob size. The source object is
+// left in place. On failure every shard written so far is removed again, so
+// the caller never ends up with shards it has not recorded.
+func (r *ProviderRegistry) StripeObject(ctx context.Context, sourceID, objectName string, objectSize int64, expectedSHA256 string) (*ErasureLayout, error) {
+	k, m := r.erasureData, r.erasureParity
+	if k == 0 {
+		return nil, errors.New("erasure coding is not configured")
+	}
+	if objectSize <= 0 {
+		return nil, fmt.Errorf("cannot stripe empty object %s", objectName)
+	}
+	enc, err := reedsolomon.New(k, m)
+	if err != nil {
+		return nil, err
+	}
+	source := r.GetProvider(sourceID)
+	if source == nil {
+		return nil, fmt.Errorf("source provider %s is not configured", sourceID)
+	}
+	ids := r.ConfiguredProviderIDs()
+	if len(ids) < k+m {
+		return nil, fmt.Errorf("erasure coding %d+%d needs %d providers but only %d are configured", k, m, k+m, len(ids))
+	}
+
+	layout := &ErasureLayout{
+		DataShards:   k,
+		ParityShards: m,
+		StripeUnit:   erasureStripeUnit(objectSize, k),
+		ObjectSize:   objectSize,
+	}
+	shardSize := layout.ShardSize()
+	partSize := shardSize
+	multipart := shardSize > CopyMultipartThreshold
+	if multipart {
+		partSize = erasurePartStripes * layout.StripeUnit
+	}
+
+	uploads := make([]*shardUpload, k+m)
+	for i := range uploads {
+		layout.Shards = append(layout.Shards, ErasureShard{Index: i, ProviderID: ids[i], ObjectName: ErasureShardName(objectName, i)})
+		uploads[i] = &shardUpload{provider: r.GetProvider(ids[i]), name: layout.Shards[i].ObjectName, hasher: sha256.New()}
+	}
+	fail := func(err error) (*ErasureLayout, error) {
+		for _, u := range uploads {
+			u.discard()
+		}
+		return nil, err
+	}
+
+	if multipart {
+		for i, u := range uploads {
+			uploadID, err := u.provider.InitiateMultipartUpload(ctx, u.name, map[string]string{
+				"erasure-shard": fmt.Sprintf("%d/%d+%d", i, k, m),
+			})
+			if err != nil {
+				return fail(fmt.Errorf("failed to initiate multipart upload of shard %s on %s: %w", u.name, ids[i], err))
+			}
+			u.uploadID = uploadID
+		}
+	}
+
+	obj, err := source.GetObject(ctx, objectName, GetObjectOptions{})
+	if err != nil {
+		return fail(fmt.Errorf("failed to get object from source: %w", err))
+	}
+	defer obj.Close()
+	blobHasher := sha256.New()
+	src := io.TeeReader(io.LimitReader(obj, objectSize), blobHasher)
+
+	bufs := make([][]byte, k+m)
+	for i := range bufs {
+		bufs[i] = make([]byte, partSize)
+	}
+	var readBytes int64
+	for offset, partNumber := int64(0), 1; offset < shardSize; offset, partNumber = offset+partSize, partNumber+1 {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+
+		n := min(partSize, shardSize-offset)
+		part := make([][]byte, k+m)
+		for i := range part {
+			part[i] = bufs[i][:n]
+		}
+		// Deal the next stripes out to the data shards, one unit each
+		for s := int64(0); s < n; s += layout.StripeUnit {
+			for d := 0; d < k; d++ {
+				got, err := readFullPadded(src, part[d][s:s+layout.StripeUnit])
+				if err != nil {
+					return fail(fmt.Errorf("failed to read object from source: %w", err))
+				}
+				readBytes += int64(got)
+			}
+		}
+		if err := enc.Encode(part); err != nil {
+			return fail(err)
+		}
+
+		errs := make([]error, k+m)
+		var wg sync.WaitGroup
+		for i, u := range uploads {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = u.write(ctx, part[i], partNumber)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return fail(err)
+		}
+	}
+
+	if readBytes != objectSize {
+		return fail(fmt.Errorf("source object %s is truncated: read %d of %d bytes", objectName, readBytes, objectSize))
+	}
+	if hash := hex.EncodeToString(blobHasher.Sum(nil)); expectedSHA256 != "" && hash != expectedSHA256 {
+		return fail(fmt.Errorf("hash mismatch reading %s from %s (expected %s, got %s)", objectName, sourceID, expectedSHA256, hash))
+	}
+
+	for _, u := range uploads {
+		if u.uploadID == "" {
+			continue
+		}
+		if err := u.provider.CompleteMultipartUpload(ctx, u.name, u.uploadID, u.parts); err != nil {
+			return fail(fmt.Errorf("failed to complete multipart upload of shard %s: %w", u.name, err))
+		}
+		u.uploadID = ""
+		u.written = true
+	}
+	for i, u := range uploads {
+		layout.Shards[i].SHA256 = hex.EncodeToString(u.hasher.Sum(nil))
+	}
+	return layout, nil
+}
+
+type shardRead struct {
+	index   int
+	data    []byte
+	err     error
+	latency time.Duration
+}
+
+func readShardRange(ctx context.Context, provider ObjectStorageProvider, objectName string, offset, length int64) ([]byte, error) {
+	reader, err := provider.GetObjectChunk(ctx, objectName, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("short read of shard %s: %w", objectName, err)
+	}
+	return data, nil
+}
+
+// fetchShards reads bytes [shardOffset, shardOffset+shardLength) of k of the
+// layout's shards in parallel, data shards first. For every shard that is
+// unreadable, the next shard the layout lists is fetched instead. It returns
+// the shards by index, nil for those not read, and the IDs of the providers
+// that served them.
+func (r *ProviderRegistry) fetchShards(ctx context.Context, objectName string, layout *ErasureLayout, shardOffset, shardLength int64) ([][]byte, []string, error) {
+	k, m := layout.DataShards, layout.ParityShards
+
+	available := make([]*ErasureShard, k+m)
+	for i := range layout.Shards {
+		shard := &layout.Shards[i]
+		if shard.Index >= 0 && shard.Index < k+m && r.GetProvider(shard.ProviderID) != nil {
+			available[shard.Index] = shard
+		}
+	}
+
+	results := make(chan shardRead, k+m)
+	next, pending := 0, 0
+	launch := func() bool {
+		for next < k+m && available[next] == nil {
+			next++
+		}
+		if next == k+m {
+			return false
+		}
+		shard := available[next]
+		next++
+		pending++
+		go func() {
+			start := time.Now()
+			data, err := readShardRange(ctx, r.GetProvider(shard.ProviderID), shard.ObjectName, shardOffset, shardLength)
+			results <- shardRead{index: shard.Index, data: data, err: err, latency: time.Since(start)}
+		}()
+		return true
+	}
+	for i := 0; i < k && launch(); i++ {
+	}
+
+	shards := make([][]byte, k+m)
+	have := 0
+	var lastErr error
+	for pending > 0 {
+		res := <-results
+		pending--
+		id := available[res.index].ProviderID
+		if res.err != nil {
+			if ctx.Err() != nil {
+				lastErr = ctx.Err()
+				continue
+			}
+			r.readStats.recordError(id, res.err)
+			log.Printf("Shard %d of %s unreadable on %s: %v", res.index, objectName, id, res.err)
+			lastErr = res.err
+			launch()
+			continue
+		}
+		r.readStats.recordSuccess(id, res.latency)
+		shards[res.index] = res.data
+		have++
+	}
+	if have < k {
+		if lastErr == nil {
+			lastErr = errors.New("shards unavailable")
+		}
+		return nil, nil, fmt.Errorf("erasure-coded object %s: only %d of the %d required shards are readable: %w", objectName, have, k, lastErr)
+	}
+
+	var served []string
+	for i, data := range shards {
+		if data != nil {
+			served = append(served, available[i].ProviderID)
+		}
+	}
+	return shards, served, nil
+}
+
+// readErasureRange returns bytes [offset, offset+length) of an erasure-coded
+// object, clipped to its size, and the IDs of the providers whose shards were
+// read. The stripes covering the range are fetched with fetchShards; data
+// shards it could not read are rebuilt from the parity shards it read instead.
+func (r *ProviderRegistry) readErasureRange(ctx context.Context, objectName string, layout *ErasureLayout, offset, length int64) ([]byte, []string, error) {
+	if offset < 0 || offset >= layout.ObjectSize {
+		return nil, nil, fmt.Errorf("offset %d is outside erasure-coded object %s (%d bytes)", offset, objectName, layout.ObjectSize)
+	}
+	length = min(length, layout.ObjectSize-offset)
+	k, m := layout.DataShards, layout.ParityShards
+	enc, err := reedsolomon.New(k, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unit := layout.StripeUnit
+	stripe := int64(k) * unit
+	first, last := offset/stripe, (offset+length-1)/stripe
+	shards, served, err := r.fetchShards(ctx, objectName, layout, first*unit, (last-first+1)*unit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for d := 0; d < k; d++ {
+		if shards[d] == nil {
+			if err := enc.ReconstructData(shards); err != nil {
+				return nil, nil, fmt.Errorf("failed to rebuild %s: %w", objectName, err)
+			}
+			log.Printf("Rebuilt %d byte(s) of %s from %d shard(s)", length, objectName, len(served))
+			break
+		}
+	}
+
+	out := make([]byte, length)
+	for n := int64(0); n < length; {
+		pos := offset + n
+		s, within := pos/stripe, pos%stripe
+		base := (s - first) * unit
+		d := within / unit
+		n += int64(copy(out[n:], shards[d][base+within%unit:base+unit]))
+	}
+	return out, served, nil
+}
+
+// RebuildErasureShards recreates lost shards of the erasure-coded object
+// objectName from the shards layout lists. Each entry of lost gives the index
+// of a shard to rebuild, the provider and object name to write it to, and in
+// SHA256 the hash recorded when the object was striped, which the rebuilt
+// shard must match. A shard may be rebuilt in place, overwriting a corrupt
+// copy; lost shards are never read even if layout lists them.
+//
+// Like StripeObject, shards are rebuilt in rounds of erasurePartStripes
+// stripes, and on failure every shard written so far is removed again.
+func (r *ProviderRegistry) RebuildErasureShards(ctx context.Context, objectName string, layout *ErasureLayout, lost []ErasureShard) error {
+	k, m := layout.DataShards, layout.ParityShards
+	enc, err := reedsolomon.New(k, m)
+	if err != nil {
+		return err
+	}
+
+	rebuild := make([]bool, k+m)
+	uploads := make([]*shardUpload, len(lost))
+	for i, shard := range lost {
+		if shard.Index < 0 || shard.Index >= k+m {
+			return fmt.Errorf("shard %d is outside the %d+%d layout of %s", shard.Index, k, m, objectName)
+		}
+		provider := r.GetProvider(shard.ProviderID)
+		if provider == nil {
+			return fmt.Errorf("provider %s is not configured", shard.ProviderID)
+		}
+		rebuild[shard.Index] = true
+		uploads[i] = &shardUpload{provider: provider, name: shard.ObjectName, hasher: sha256.New()}
+	}
+	survivors := &ErasureLayout{DataShards: k, ParityShards: m, StripeUnit: layout.StripeUnit, ObjectSize: layout.ObjectSize}
+	for _, shard := range layout.Shards {
+		if shard.Index >= 0 && shard.Index < k+m && !rebuild[shard.Index] {
+			survivors.Shards = append(survivors.Shards, shard)
+		}
+	}
+	fail := func(err error) error {
+		for _, u := range uploads {
+			u.discard()
+		}
+		return err
+	}
+
+	shardSize := layout.ShardSize()
+	partSize := shardSize
+	if shardSize > CopyMultipartThreshold {
+		partSize = erasurePartStripes * layout.StripeUnit
+		for i, u := range uploads {
+			uploadID, err := u.provider.InitiateMultipartUpload(ctx, u.name, map[string]string{
+				"erasure-shard": fmt.Sprintf("%d/%d+%d", lost[i].Index, k, m),
+			})
+			if err != nil {
+				return fail(fmt.Errorf("failed to initiate multipart upload of shard %s on %s: %w", u.name, lost[i].ProviderID, err))
+			}
+			u.uploadID = uploadID
+		}
+	}
+
+	for offset, partNumber := int64(0), 1; offset < shardSize; offset, partNumber = offset+partSize, partNumber+1 {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		shards, _, err := r.fetchShards(ctx, objectName, survivors, offset, min(partSize, shardSize-offset))
+		if err != nil {
+			return fail(err)
+		}
+		if err := enc.Reconstruct(shards); err != nil {
+			return fail(fmt.Errorf("failed to rebuild shards of %s: %w", objectName, err))
+		}
+		for i, u := range uploads {
+			if err := u.write(ctx, shards[lost[i].Index], partNumber); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	for i, u := range uploads {
+		if hash := hex.EncodeToString(u.hasher.Sum(nil)); hash != lost[i].SHA256 {
+			return fail(fmt.Errorf("rebuilt shard %d of %s does not match its recorded hash (expected %s, got %s)",
+				lost[i].Index, objectName, lost[i].SHA256, hash))
+		}
+	}
+	for i, u := range uploads {
+		if u.uploadID == "" {
+			continue
+		}
+		if err := u.provider.CompleteMultipartUpload(ctx, u.name, u.uploadID, u.parts); err != nil {
+			return fail(fmt.Errorf("failed to complete multipart upload of shard %s on %s: %w", u.name, lost[i].ProviderID, err))
+		}
+		u.uploadID = ""
+		u.written = true
+	}
+	return nil
+}
+
+// getErasureChunk is GetObjectChunkFrom for erasure-coded objects. The
+// returned provider ID lists every provider a shard was read from,
+// comma-separated.
+func (r *ProviderRegistry) getErasureChunk(ctx context.Context, objectName string, layout *ErasureLayout, offset, length int64) (io.ReadCloser, string, error) {
+	data, served, err := r.readErasureRange(ctx, objectName, layout, offset, length)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader(data)), strings.Join(served, ","), nil
+}
+
+// erasureObject streams a whole erasure-coded object for GetObjectWithFallback,
+// rebuilding erasurePartStripes stripes at a time.
+type erasureObject struct {
+	ctx    context.Context
+	r      *ProviderRegistry
+	name   string
+	layout *ErasureLayout
+	pos    int64
+	buf    []byte
+}
+
+func (o *erasureObject) Read(p []byte) (int, error) {
+	if len(o.buf) == 0 {
+		if o.pos >= o.layout.ObjectSize {
+			return 0, io.EOF
+		}
+		n := int64(erasurePartStripes*o.layout.DataShards) * o.layout.StripeUnit
+		data, _, err := o.r.readErasureRange(o.ctx, o.name, o.layout, o.pos, n)
+		if err != nil {
+			return 0, err
+		}
+		o.buf = data
+		o.pos += int64(len(data))
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *erasureObject) Close() error {
+	return nil
+}
+
+func (o *erasureObject) Stat() (ObjectInfo, error) {
+	return ObjectInfo{Key: o.name, Size: o.layout.ObjectSize, ContentType: "application/octet-stream"}, nil
+}
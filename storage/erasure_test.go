@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newErasureTestRegistry builds three local providers with 2+1 erasure coding
+// and stripes data, stored as "blob" on the primary, across them.
+func newErasureTestRegistry(t *testing.T, data []byte) (*ProviderRegistry, []*LocalFSStorage, *ErasureLayout) {
+	t.Helper()
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	require.NoError(t, reg.SetErasureCoding(2, 1))
+	require.NoError(t, reg.SetWritePolicy(WritePolicyErasure, 0))
+	putLocalObject(t, providers[0], "blob", data)
+
+	layout, err := reg.StripeObject(context.Background(), "p1", "blob", int64(len(data)), sha256Hex(data))
+	require.NoError(t, err)
+	reg.SetErasureLayoutSource(func(ctx context.Context, objectName string) (*ErasureLayout, error) {
+		if objectName != "blob" {
+			return nil, nil
+		}
+		return layout, nil
+	})
+	return reg, providers, layout
+}
+
+func randomErasureTestData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func readErasureTestChunk(t *testing.T, reg *ProviderRegistry, offset, length int64) []byte {
+	t.Helper()
+	reader, _, err := reg.GetObjectChunkWithFallback(context.Background(), "blob", offset, length)
+	require.NoError(t, err)
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return got
+}
+
+func TestParseErasureShards(t *testing.T) {
+	k, m, err := ParseErasureShards(" 2+1 ")
+	require.NoError(t, err)
+	assert.Equal(t, 2, k)
+	assert.Equal(t, 1, m)
+
+	for _, bad := range []string{"", "2", "2+0", "0+1", "a+b", "200+100"} {
+		_, _, err := ParseErasureShards(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSetErasureCoding(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 2)
+	assert.Error(t, reg.SetWritePolicy(WritePolicyErasure, 0), "shard counts must be configured first")
+	assert.Error(t, reg.SetErasureCoding(2, 1), "three shards need three providers")
+	require.NoError(t, reg.SetErasureCoding(1, 1))
+	require.NoError(t, reg.SetWritePolicy(WritePolicyErasure, 0))
+	assert.Equal(t, 1, reg.RequiredWriteCopies())
+	assert.Equal(t, 1, reg.ReplicationTarget())
+}
+
+func TestStripeObject_ReadsSurviveLostShard(t *testing.T) {
+	data := randomErasureTestData(5000)
+	reg, providers, layout := newErasureTestRegistry(t, data)
+
+	// 2500 bytes per data shard rounds up to a 2560-byte unit
+	assert.Equal(t, int64(2560), layout.StripeUnit)
+	require.Len(t, layout.Shards, 3)
+	for i, shard := range layout.Shards {
+		size, err := providers[i].HeadObject(context.Background(), shard.ObjectName)
+		require.NoError(t, err)
+		assert.Equal(t, layout.ShardSize(), size)
+		assert.Len(t, shard.SHA256, 64)
+	}
+	// Three shards of half the blob each: 1.5x overhead, not 3x
+	assert.Equal(t, int64(2560), layout.ShardSize())
+
+	assert.Equal(t, data[100:4100], readErasureTestChunk(t, reg, 100, 4000))
+
+	// Lose the primary's data shard: the chunk is rebuilt from the parity shard
+	require.NoError(t, providers[0].RemoveObject(context.Background(), layout.Shards[0].ObjectName, RemoveObjectOptions{}))
+	assert.Equal(t, data[100:4100], readErasureTestChunk(t, reg, 100, 4000))
+	assert.Equal(t, data[4990:], readErasureTestChunk(t, reg, 4990, 100), "reads are clipped to the blob")
+	assert.Equal(t, int64(2), reg.ReadStatsFor("p1").Errors)
+
+	// A second lost shard is more than 2+1 can survive
+	require.NoError(t, providers[2].RemoveObject(context.Background(), layout.Shards[2].ObjectName, RemoveObjectOptions{}))
+	_, _, err := reg.GetObjectChunkWithFallback(context.Background(), "blob", 0, 10)
+	assert.Error(t, err)
+}
+
+func TestStripeObject_MultipartShards(t *testing.T) {
+	// Large enough that each shard exceeds CopyMultipartThreshold
+	data := randomErasureTestData(11*1024*1024 + 123)
+	reg, providers, layout := newErasureTestRegistry(t, data)
+	assert.Equal(t, int64(ErasureStripeUnit), layout.StripeUnit)
+
+	// Drop the second data shard from the layout entirely
+	layout.Shards = append(layout.Shards[:1], layout.Shards[2:]...)
+
+	offset := int64(3*1024*1024 - 7)
+	assert.Equal(t, data[offset:offset+5*1024*1024], readErasureTestChunk(t, reg, offset, 5*1024*1024))
+
+	obj, providerID, err := reg.GetObjectWithFallback(context.Background(), "blob", GetObjectOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, providerID)
+	info, err := obj.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size)
+	got, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = providers[1].HeadObject(context.Background(), "blob.ec1")
+	assert.NoError(t, err, "the shard object itself is untouched")
+}
+
+func TestStripeObject_HashMismatchRemovesShards(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	require.NoError(t, reg.SetErasureCoding(2, 1))
+	data := []byte("padded encrypted blob")
+	putLocalObject(t, providers[0], "blob", data)
+
+	_, err := reg.StripeObject(context.Background(), "p1", "blob", int64(len(data)), sha256Hex([]byte("other")))
+	assert.Error(t, err)
+	for i, p := range providers {
+		_, err := p.HeadObject(context.Background(), ErasureShardName("blob", i))
+		assert.Error(t, err, "shard %d must be removed", i)
+	}
+	_, err = providers[0].HeadObject(context.Background(), "blob")
+	assert.NoError(t, err, "the source object is left alone")
+}
+
+func hashLocalObject(t *testing.T, p *LocalFSStorage, name string) string {
+	t.Helper()
+	obj, err := p.GetObject(context.Background(), name, GetObjectOptions{})
+	require.NoError(t, err)
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	return sha256Hex(data)
+}
+
+func TestRebuildErasureShards_InPlace(t *testing.T) {
+	data := randomErasureTestData(5000)
+	reg, providers, layout := newErasureTestRegistry(t, data)
+	lost := layout.Shards[0]
+	require.NoError(t, providers[0].RemoveObject(context.Background(), lost.ObjectName, RemoveObjectOptions{}))
+
+	require.NoError(t, reg.RebuildErasureShards(context.Background(), "blob", layout, []ErasureShard{lost}))
+	assert.Equal(t, lost.SHA256, hashLocalObject(t, providers[0], lost.ObjectName))
+
+	// The blob reads back without the parity shard, so the rebuilt data shard is used
+	layout.Shards = layout.Shards[:2]
+	assert.Equal(t, data, readErasureTestChunk(t, reg, 0, int64(len(data))))
+}
+
+func TestRebuildErasureShards_MultipartToOtherProvider(t *testing.T) {
+	data := randomErasureTestData(11*1024*1024 + 123)
+	reg, providers, layout := newErasureTestRegistry(t, data)
+	lost := layout.Shards[2]
+	lost.ProviderID = "p2"
+
+	require.NoError(t, reg.RebuildErasureShards(context.Background(), "blob", layout, []ErasureShard{lost}))
+	assert.Equal(t, lost.SHA256, hashLocalObject(t, providers[1], lost.ObjectName))
+}
+
+func TestRebuildErasureShards_CorruptSurvivorFails(t *testing.T) {
+	data := randomErasureTestData(5000)
+	reg, providers, layout := newErasureTestRegistry(t, data)
+	lost := layout.Shards[0]
+	require.NoError(t, providers[0].RemoveObject(context.Background(), lost.ObjectName, RemoveObjectOptions{}))
+	putLocalObject(t, providers[1], layout.Shards[1].ObjectName, make([]byte, layout.ShardSize()))
+
+	err := reg.RebuildErasureShards(context.Background(), "blob", layout, []ErasureShard{lost})
+	assert.ErrorContains(t, err, "does not match its recorded hash")
+	_, err = providers[0].HeadObject(context.Background(), lost.ObjectName)
+	assert.Error(t, err, "a bad rebuild is removed again")
+}
@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hedged read tuning. Latency is the time until GetObjectChunk returns a
+// reader (i.e. time to first byte for S3 providers), smoothed with an EWMA.
+const (
+	// readLatencyEWMAAlpha weights the newest sample in the latency and error-rate averages.
+	readLatencyEWMAAlpha = 0.2
+
+	// readStatsMinSamples is how many requests a provider needs before its
+	// measured latency and error rate are trusted for ordering decisions.
+	readStatsMinSamples = 5
+
+	// defaultHedgeDelay is the assumed latency of a provider with too few samples,
+	// and so the hedge delay used until real measurements exist.
+	defaultHedgeDelay = 250 * time.Millisecond
+
+	// minHedgeDelay / maxHedgeDelay bound the hedge delay derived from measured latency.
+	minHedgeDelay = 50 * time.Millisecond
+	maxHedgeDelay = 2 * time.Second
+
+	// hedgeLatencyMultiplier: a request is hedged once it has taken this many
+	// times the provider's average latency.
+	hedgeLatencyMultiplier = 2
+
+	// unhealthyErrorRate demotes a provider to the end of the read order while
+	// its last error is within unhealthyWindow. After the window it is tried in
+	// its normal place again, so a recovered provider does not stay demoted.
+	unhealthyErrorRate = 0.5
+	unhealthyWindow    = 5 * time.Minute
+
+	// readOrderPreferenceBias keeps the configured primary->secondary->tertiary
+	// order sticky: each step down the order must be this much (relative) faster
+	// to overtake the one above it, so egress-priced replicas are not preferred
+	// over a primary on measurement noise alone.
+	readOrderPreferenceBias = 0.5
+)
+
+// ProviderReadStats is a point-in-time snapshot of read health for one provider.
+type ProviderReadStats struct {
+	ProviderID   string    `json:"provider_id"`
+	Requests     int64     `json:"requests"`
+	Errors       int64     `json:"errors"`
+	Hedges       int64     `json:"hedges"`
+	ErrorRate    float64   `json:"error_rate"`
+	AvgLatencyMS float64   `json:"avg_latency_ms"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastErrorAt  time.Time `json:"last_error_at,omitempty"`
+}
+
+type providerReadStats struct {
+	requests      int64
+	errors        int64
+	hedges        int64 // times a hedge was sent because this provider was slow
+	latencyEWMA   float64
+	errorRateEWMA float64
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// readStatsTracker accumulates per-provider read latency and error rates.
+// Stats are keyed by provider ID so they survive role swaps.
+type readStatsTracker struct {
+	mu    sync.Mutex
+	stats map[string]*providerReadStats
+}
+
+func newReadStatsTracker() *readStatsTracker {
+	return &readStatsTracker{stats: make(map[string]*providerReadStats)}
+}
+
+func (t *readStatsTracker) get(providerID string) *providerReadStats {
+	s, ok := t.stats[providerID]
+	if !ok {
+		s = &providerReadStats{}
+		t.stats[providerID] = s
+	}
+	return s
+}
+
+func ewma(prev, sample float64, first bool) float64 {
+	if first {
+		return sample
+	}
+	return prev + readLatencyEWMAAlpha*(sample-prev)
+}
+
+func (t *readStatsTracker) recordSuccess(providerID string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.get(providerID)
+	s.requests++
+	ms := float64(latency) / float64(time.Millisecond)
+	s.latencyEWMA = ewma(s.latencyEWMA, ms, s.requests == 1)
+	s.errorRateEWMA = ewma(s.errorRateEWMA, 0, s.requests == 1)
+}
+
+// recordSlow records a request that was abandoned (because a hedged request to
+// another provider won) after `elapsed`. The elapsed time is a lower bound on
+// the real latency and is folded into the average so a degraded provider moves
+// down the read order even though it never returned.
+func (t *readStatsTracker) recordSlow(providerID string, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.get(providerID)
+	s.requests++
+	ms := float64(elapsed) / float64(time.Millisecond)
+	s.latencyEWMA = ewma(s.latencyEWMA, ms, s.requests == 1)
+}
+
+func (t *readStatsTracker) recordError(providerID string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.get(providerID)
+	s.requests++
+	s.errors++
+	s.errorRateEWMA = ewma(s.errorRateEWMA, 1, s.requests == 1)
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+func (t *readStatsTracker) recordHedge(providerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.get(providerID).hedges++
+}
+
+// expectedLatency returns the provider's smoothed latency, or defaultHedgeDelay
+// when there are too few samples to trust, and whether it is considered unhealthy.
+func (t *readStatsTracker) expectedLatency(providerID string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.stats[providerID]
+	if !ok || s.requests < readStatsMinSamples {
+		return defaultHedgeDelay, false
+	}
+	unhealthy := s.errorRateEWMA >= unhealthyErrorRate && time.Since(s.lastErrorAt) < unhealthyWindow
+	return time.Duration(s.latencyEWMA * float64(time.Millisecond)), unhealthy
+}
+
+func (t *readStatsTracker) snapshot(providerID string) ProviderReadStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := ProviderReadStats{ProviderID: providerID}
+	if s, ok := t.stats[providerID]; ok {
+		out.Requests = s.requests
+		out.Errors = s.errors
+		out.Hedges = s.hedges
+		out.ErrorRate = s.errorRateEWMA
+		out.AvgLatencyMS = s.latencyEWMA
+		out.LastError = s.lastError
+		out.LastErrorAt = s.lastErrorAt
+	}
+	return out
+}
+
+// SetHedgedReads enables or disables hedged chunk reads. When disabled,
+// GetObjectChunkWithFallback still orders providers by health but only moves
+// to the next provider after an error. Enabled by default.
+func (r *ProviderRegistry) SetHedgedReads(enabled bool) {
+	r.hedgingDisabled = !enabled
+}
+
+// ReadStats returns read health snapshots for all configured providers, in
+// configured (primary, secondary, tertiary) order.
+func (r *ProviderRegistry) ReadStats() []ProviderReadStats {
+	var out []ProviderReadStats
+	for _, c := range r.configuredReadCandidates() {
+		out = append(out, r.readStats.snapshot(c.id))
+	}
+	return out
+}
+
+// ReadStatsFor returns the read health snapshot for a single provider ID.
+func (r *ProviderRegistry) ReadStatsFor(providerID string) ProviderReadStats {
+	return r.readStats.snapshot(providerID)
+}
+
+type readCandidate struct {
+	id       string
+	provider ObjectStorageProvider
+}
+
+func (r *ProviderRegistry) configuredReadCandidates() []readCandidate {
+	candidates := []readCandidate{{r.primaryID, r.primary}}
+	if r.secondary != nil {
+		candidates = append(candidates, readCandidate{r.secondaryID, r.secondary})
+	}
+	if r.tertiary != nil {
+		candidates = append(candidates, readCandidate{r.tertiaryID, r.tertiary})
+	}
+	return candidates
+}
+
+// readCandidates returns providers in the order reads should try them:
+// healthy providers first, ordered by (biased) expected latency, then
+// providers whose recent error rate marks them unhealthy.
+func (r *ProviderRegistry) readCandidates() []readCandidate {
+	candidates := r.configuredReadCandidates()
+	type scored struct {
+		readCandidate
+		unhealthy bool
+		score     float64
+	}
+	ranked := make([]scored, len(candidates))
+	for i, c := range candidates {
+		latency, unhealthy := r.readStats.expectedLatency(c.id)
+		ranked[i] = scored{c, unhealthy, float64(latency) * (1 + readOrderPreferenceBias*float64(i))}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		if ranked[a].unhealthy != ranked[b].unhealthy {
+			return !ranked[a].unhealthy
+		}
+		return ranked[a].score < ranked[b].score
+	})
+	for i := range ranked {
+		candidates[i] = ranked[i].readCandidate
+	}
+	return candidates
+}
+
+// filterReadCandidates keeps the candidates whose ID is in providerIDs, in
+// their existing order. It returns candidates unchanged when providerIDs is
+// empty or none of them is configured.
+func filterReadCandidates(candidates []readCandidate, providerIDs []string) []readCandidate {
+	if len(providerIDs) == 0 {
+		return candidates
+	}
+	var filtered []readCandidate
+	for _, c := range candidates {
+		for _, id := range providerIDs {
+			if c.id == id {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	if len(filtered) == 0 {
+		return candidates
+	}
+	return filtered
+}
+
+// hedgeDelay returns how long to wait on providerID before hedging to the next one.
+func (r *ProviderRegistry) hedgeDelay(providerID string) time.Duration {
+	latency, _ := r.readStats.expectedLatency(providerID)
+	delay := latency * hedgeLatencyMultiplier
+	if delay < minHedgeDelay {
+		delay = minHedgeDelay
+	}
+	if delay > maxHedgeDelay {
+		delay = maxHedgeDelay
+	}
+	return delay
+}
+
+// cancelOnClose releases a winning attempt's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+type chunkAttempt struct {
+	index   int
+	reader  io.ReadCloser
+	err     error
+	latency time.Duration
+}
+
+// GetObjectChunkWithFallback reads a chunk from the healthiest provider and
+// returns the chunk reader, the provider ID that served it, and any error.
+//
+// Providers are tried in order of measured latency and error rate (see
+// readCandidates). If a provider errors, the next one is tried immediately; if
+// it is merely slow (no response within hedgeDelay), a hedged request is sent
+// to the next provider in parallel and whichever responds first wins. Losing
+// requests are cancelled and their readers closed.
+//
+// Erasure-coded objects (see erasure.go) are read from their shards instead,
+// rebuilding the chunk from the parity shards when a data shard is unavailable.
+func (r *ProviderRegistry) GetObjectChunkWithFallback(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, string, error) {
+	return r.GetObjectChunkFrom(ctx, nil, objectName, offset, length)
+}
+
+// GetObjectChunkFrom is GetObjectChunkWithFallback restricted to the providers
+// in providerIDs (for example, the providers a file has an active copy on), so
+// that providers known not to hold the object are never asked for it and do
+// not collect read errors. An empty providerIDs, or one naming no configured
+// provider, reads from every configured provider.
+func (r *ProviderRegistry) GetObjectChunkFrom(ctx context.Context, providerIDs []string, objectName string, offset, length int64) (io.ReadCloser, string, error) {
+	if layout, err := r.lookupErasureLayout(ctx, objectName); err != nil {
+		return nil, "", err
+	} else if layout != nil {
+		return r.getErasureChunk(ctx, objectName, layout, offset, length)
+	}
+
+	candidates := filterReadCandidates(r.readCandidates(), providerIDs)
+
+	// Single provider mode: nothing to hedge or fall back to
+	if len(candidates) == 1 {
+		id, provider := candidates[0].id, candidates[0].provider
+		start := time.Now()
+		reader, err := provider.GetObjectChunk(ctx, objectName, offset, length)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.readStats.recordError(id, err)
+			}
+			return nil, "", err
+		}
+		r.readStats.recordSuccess(id, time.Since(start))
+		return reader, id, nil
+	}
+
+	results := make(chan chunkAttempt, len(candidates))
+	cancels := make([]context.CancelFunc, len(candidates))
+	starts := make([]time.Time, len(candidates))
+	finished := make([]bool, len(candidates))
+	errs := make([]error, len(candidates))
+	launched, pending := 0, 0
+
+	launch := func() {
+		i := launched
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		starts[i] = time.Now()
+		launched++
+		pending++
+		go func() {
+			reader, err := candidates[i].provider.GetObjectChunk(attemptCtx, objectName, offset, length)
+			results <- chunkAttempt{index: i, reader: reader, err: err, latency: time.Since(starts[i])}
+		}()
+	}
+
+	var hedgeTimer *time.Timer
+	var hedgeC <-chan time.Time
+	armHedge := func() {
+		if hedgeTimer != nil {
+			hedgeTimer.Stop()
+		}
+		hedgeTimer, hedgeC = nil, nil
+		if r.hedgingDisabled || launched >= len(candidates) {
+			return
+		}
+		hedgeTimer = time.NewTimer(r.hedgeDelay(candidates[launched-1].id))
+		hedgeC = hedgeTimer.C
+	}
+	defer func() {
+		if hedgeTimer != nil {
+			hedgeTimer.Stop()
+		}
+	}()
+
+	// abandon cancels in-flight attempts other than `winner` (-1 when the caller
+	// gave up) and closes any readers they return after we've stopped listening.
+	// Attempts that lost a race count as slow samples for their provider.
+	abandon := func(winner int) {
+		for i := 0; i < launched; i++ {
+			if i == winner || finished[i] {
+				continue
+			}
+			if winner >= 0 {
+				r.readStats.recordSlow(candidates[i].id, time.Since(starts[i]))
+			}
+			cancels[i]()
+		}
+		if pending > 0 {
+			go func(n int) {
+				for ; n > 0; n-- {
+					if late := <-results; late.reader != nil {
+						late.reader.Close()
+					}
+				}
+			}(pending)
+		}
+	}
+
+	launch()
+	armHedge()
+
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			finished[res.index] = true
+			id := candidates[res.index].id
+
+			if res.err == nil {
+				r.readStats.recordSuccess(id, res.latency)
+				abandon(res.index)
+				if launched > 1 {
+					log.Printf("GetObjectChunk(%s) served by %s after trying %d provider(s)", objectName, id, launched)
+				}
+				return &cancelOnClose{ReadCloser: res.reader, cancel: cancels[res.index]}, id, nil
+			}
+
+			cancels[res.index]()
+			errs[res.index] = res.err
+			if ctx.Err() != nil {
+				abandon(-1)
+				return nil, "", ctx.Err()
+			}
+			r.readStats.recordError(id, res.err)
+			log.Printf("Provider %s failed for GetObjectChunk(%s): %v", id, objectName, res.err)
+
+			if launched < len(candidates) {
+				log.Printf("Trying provider %s for GetObjectChunk(%s)", candidates[launched].id, objectName)
+				launch()
+				armHedge()
+			}
+
+		case <-hedgeC:
+			slowID := candidates[launched-1].id
+			r.readStats.recordHedge(slowID)
+			log.Printf("Provider %s slow for GetObjectChunk(%s), hedging to %s", slowID, objectName, candidates[launched].id)
+			launch()
+			armHedge()
+
+		case <-ctx.Done():
+			abandon(-1)
+			return nil, "", ctx.Err()
+		}
+	}
+
+	// Every provider failed. Report the primary's error first, as before.
+	var primaryErr error
+	for i, c := range candidates {
+		if c.id == r.primaryID {
+			primaryErr = errs[i]
+		}
+	}
+	if primaryErr == nil {
+		primaryErr = errors.Join(errs...)
+	}
+	return nil, "", fmt.Errorf("all providers failed for GetObjectChunk(%s): primary(%s): %v", objectName, r.primaryID, primaryErr)
+}
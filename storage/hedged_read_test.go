@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// blockUntilCanceled makes a mock GetObjectChunk call hang until its context is canceled.
+func blockUntilCanceled(m *MockObjectStorageProvider, objectName string) {
+	m.On("GetObjectChunk", mock.Anything, objectName, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(nil, context.Canceled)
+}
+
+// --- Hedged read tests ---
+
+func TestGetObjectChunkWithFallback_HedgesSlowPrimary(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	blockUntilCanceled(primary, "test-obj")
+
+	secondary := newTestLocalProvider(t)
+	putLocalObject(t, secondary, "test-obj", []byte("0123456789"))
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	start := time.Now()
+	reader, providerID, err := reg.GetObjectChunkWithFallback(context.Background(), "test-obj", 0, 4)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, "secondary-1", providerID)
+	assert.Less(t, time.Since(start), maxHedgeDelay, "hedged request should not wait for the slow primary")
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(got))
+
+	// The primary is charged a hedge and a slow sample, not an error
+	stats := reg.ReadStatsFor("primary-1")
+	assert.Equal(t, int64(1), stats.Hedges)
+	assert.Equal(t, int64(0), stats.Errors)
+	assert.Greater(t, stats.AvgLatencyMS, float64(0))
+}
+
+func TestGetObjectChunkWithFallback_HedgingDisabledWaitsForPrimary(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	secondary := new(MockObjectStorageProvider)
+	primary.On("GetObjectChunk", mock.Anything, "test-obj", int64(0), int64(4)).
+		After(defaultHedgeDelay*hedgeLatencyMultiplier+100*time.Millisecond).
+		Return(io.NopCloser(bytes.NewReader([]byte("slow"))), nil)
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+	reg.SetHedgedReads(false)
+
+	reader, providerID, err := reg.GetObjectChunkWithFallback(context.Background(), "test-obj", 0, 4)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, "primary-1", providerID)
+	secondary.AssertNotCalled(t, "GetObjectChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetObjectChunkWithFallback_RecordsErrors(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	primary.On("GetObjectChunk", mock.Anything, "test-obj", int64(0), int64(4)).Return(nil, errors.New("primary down"))
+
+	secondary := newTestLocalProvider(t)
+	putLocalObject(t, secondary, "test-obj", []byte("data"))
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	reader, _, err := reg.GetObjectChunkWithFallback(context.Background(), "test-obj", 0, 4)
+	require.NoError(t, err)
+	reader.Close()
+
+	primaryStats := reg.ReadStatsFor("primary-1")
+	assert.Equal(t, int64(1), primaryStats.Requests)
+	assert.Equal(t, int64(1), primaryStats.Errors)
+	assert.Equal(t, "primary down", primaryStats.LastError)
+
+	secondaryStats := reg.ReadStatsFor("secondary-1")
+	assert.Equal(t, int64(1), secondaryStats.Requests)
+	assert.Equal(t, int64(0), secondaryStats.Errors)
+
+	all := reg.ReadStats()
+	require.Len(t, all, 2)
+	assert.Equal(t, "primary-1", all[0].ProviderID)
+	assert.Equal(t, "secondary-1", all[1].ProviderID)
+}
+
+func TestGetObjectChunkWithFallback_DemotesUnhealthyProvider(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	primary.On("GetObjectChunk", mock.Anything, "test-obj", int64(0), int64(4)).Return(nil, errors.New("primary down"))
+
+	secondary := newTestLocalProvider(t)
+	putLocalObject(t, secondary, "test-obj", []byte("data"))
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	for i := 0; i < readStatsMinSamples; i++ {
+		reader, _, err := reg.GetObjectChunkWithFallback(context.Background(), "test-obj", 0, 4)
+		require.NoError(t, err)
+		reader.Close()
+	}
+	primary.AssertNumberOfCalls(t, "GetObjectChunk", readStatsMinSamples)
+
+	// With enough failures recorded, the primary is no longer tried first
+	reader, providerID, err := reg.GetObjectChunkWithFallback(context.Background(), "test-obj", 0, 4)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, "secondary-1", providerID)
+	primary.AssertNumberOfCalls(t, "GetObjectChunk", readStatsMinSamples)
+}
+
+func TestGetObjectChunkWithFallback_PrefersMeasuredFasterReplica(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	secondary := new(MockObjectStorageProvider)
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	// Primary is consistently slow, secondary consistently fast
+	for i := 0; i < readStatsMinSamples; i++ {
+		reg.readStats.recordSuccess("primary-1", 400*time.Millisecond)
+		reg.readStats.recordSuccess("secondary-1", 20*time.Millisecond)
+	}
+	order := reg.readCandidates()
+	assert.Equal(t, "secondary-1", order[0].id)
+
+	// A replica that is only marginally faster does not overtake the primary
+	reg2 := newTestRegistry(primary, "primary-1")
+	reg2.SetSecondary(secondary, "secondary-1")
+	for i := 0; i < readStatsMinSamples; i++ {
+		reg2.readStats.recordSuccess("primary-1", 100*time.Millisecond)
+		reg2.readStats.recordSuccess("secondary-1", 80*time.Millisecond)
+	}
+	order = reg2.readCandidates()
+	assert.Equal(t, "primary-1", order[0].id)
+}
+
+func TestGetObjectChunkWithFallback_CallerCancel(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	secondary := new(MockObjectStorageProvider)
+	blockUntilCanceled(primary, "test-obj")
+	blockUntilCanceled(secondary, "test-obj")
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, providerID, err := reg.GetObjectChunkWithFallback(ctx, "test-obj", 0, 4)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, providerID)
+
+	// A caller giving up is not the provider's fault
+	assert.Equal(t, int64(0), reg.ReadStatsFor("primary-1").Errors)
+}
+
+func TestGetObjectChunkFrom_SkipsProvidersWithoutCopy(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+
+	secondary := newTestLocalProvider(t)
+	putLocalObject(t, secondary, "test-obj", []byte("data"))
+
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+
+	reader, providerID, err := reg.GetObjectChunkFrom(context.Background(), []string{"secondary-1"}, "test-obj", 0, 4)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, "secondary-1", providerID)
+	primary.AssertNotCalled(t, "GetObjectChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, int64(0), reg.ReadStatsFor("primary-1").Requests)
+
+	// Unknown provider IDs fall back to every configured provider
+	primary.On("GetObjectChunk", mock.Anything, "test-obj", int64(0), int64(4)).
+		Return(io.NopCloser(bytes.NewReader([]byte("data"))), nil)
+	reader, providerID, err = reg.GetObjectChunkFrom(context.Background(), []string{"gone"}, "test-obj", 0, 4)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, "primary-1", providerID)
+}
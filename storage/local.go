@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Directory layout under a LocalFSStorage root:
+//
+//	<root>/objects/<objectName>                    completed objects
+//	<root>/multipart/<uploadID>/object             object name the upload targets
+//	<root>/multipart/<uploadID>/part-<NNNNN>       uploaded parts
+//	<root>/tmp/                                    in-flight writes (renamed into place)
+const (
+	localObjectsDir   = "objects"
+	localMultipartDir = "multipart"
+	localTmpDir       = "tmp"
+	localUploadTarget = "object"
+)
+
+// LocalFSStorage implements the ObjectStorageProvider interface on top of a
+// local directory tree. It is intended for small single-host deployments that
+// do not want to run an S3 gateway, and as a real (non-mock) secondary or
+// tertiary target for registry and task runner tests.
+//
+// Objects are written to a temp file first and renamed into place, so a crash
+// never leaves a partially written object visible to GetObject or ListObjects.
+type LocalFSStorage struct {
+	rootDir string
+}
+
+// Ensure LocalFSStorage implements ObjectStorageProvider
+var _ ObjectStorageProvider = (*LocalFSStorage)(nil)
+
+// NewLocalProvider creates a LocalFSStorage rooted at rootDir, creating the
+// directory layout if it does not exist yet.
+func NewLocalProvider(rootDir string) (*LocalFSStorage, error) {
+	if rootDir == "" {
+		return nil, fmt.Errorf("local storage root directory is required")
+	}
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve local storage root %s: %w", rootDir, err)
+	}
+	for _, dir := range []string{localObjectsDir, localMultipartDir, localTmpDir} {
+		if err := os.MkdirAll(filepath.Join(absRoot, dir), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create local storage directory %s: %w", dir, err)
+		}
+	}
+	return &LocalFSStorage{rootDir: absRoot}, nil
+}
+
+// RootDir returns the absolute root directory of the provider.
+func (s *LocalFSStorage) RootDir() string {
+	return s.rootDir
+}
+
+// validateLocalObjectName rejects object names that would escape the objects
+// directory or create nested paths. Arkfile storage IDs are flat UUIDs, so a
+// single path element is all we need to support.
+func validateLocalObjectName(objectName string) error {
+	if objectName == "" || objectName == "." || objectName == ".." ||
+		strings.ContainsAny(objectName, `/\`) || strings.ContainsRune(objectName, 0) {
+		return fmt.Errorf("invalid object name for local storage: %q", objectName)
+	}
+	return nil
+}
+
+func (s *LocalFSStorage) objectPath(objectName string) (string, error) {
+	if err := validateLocalObjectName(objectName); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.rootDir, localObjectsDir, objectName), nil
+}
+
+func (s *LocalFSStorage) uploadDir(uploadID string) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", fmt.Errorf("invalid multipart upload ID: %q", uploadID)
+	}
+	return filepath.Join(s.rootDir, localMultipartDir, uploadID), nil
+}
+
+func partFileName(partNumber int) string {
+	return fmt.Sprintf("part-%05d", partNumber)
+}
+
+// writeAtomically streams reader into a temp file and renames it to dest.
+// If expectedSize is non-negative the number of bytes written must match it.
+// Returns the number of bytes written and the hex MD5 of the content.
+func (s *LocalFSStorage) writeAtomically(dest string, reader io.Reader, expectedSize int64) (int64, string, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.rootDir, localTmpDir), "write-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op after a successful rename
+
+	hasher := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), reader)
+	if err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("failed to write data: %w", err)
+	}
+	if expectedSize >= 0 && written != expectedSize {
+		tmp.Close()
+		return 0, "", fmt.Errorf("size mismatch: expected %d bytes, got %d", expectedSize, written)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmpName, dest); err != nil {
+		return 0, "", fmt.Errorf("failed to move data into place: %w", err)
+	}
+	return written, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// PutObject writes an object to the local objects directory.
+func (s *LocalFSStorage) PutObject(ctx context.Context, objectName string, reader io.Reader, objectSize int64, opts PutObjectOptions) (UploadInfo, error) {
+	path, err := s.objectPath(objectName)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return UploadInfo{}, err
+	}
+
+	written, etag, err := s.writeAtomically(path, reader, objectSize)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to put object: %w", err)
+	}
+
+	return UploadInfo{
+		Key:  objectName,
+		ETag: etag,
+		Size: written,
+	}, nil
+}
+
+// localObject wraps an open file to implement ReadableStoredObject.
+type localObject struct {
+	file   *os.File
+	reader io.Reader
+	info   ObjectInfo
+}
+
+func (o *localObject) Read(p []byte) (int, error) {
+	return o.reader.Read(p)
+}
+
+func (o *localObject) Close() error {
+	return o.file.Close()
+}
+
+func (o *localObject) Stat() (ObjectInfo, error) {
+	return o.info, nil
+}
+
+// GetObject opens an object for reading, honouring an optional byte range.
+// Range semantics match S3: both offsets are inclusive and a negative end
+// reads through to the end of the object.
+func (s *LocalFSStorage) GetObject(ctx context.Context, objectName string, opts GetObjectOptions) (ReadableStoredObject, error) {
+	path, err := s.objectPath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	obj := &localObject{
+		file:   file,
+		reader: file,
+		info: ObjectInfo{
+			Key:          objectName,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+			ContentType:  "application/octet-stream",
+		},
+	}
+
+	start, end, hasRange := opts.GetRange()
+	if hasRange {
+		if start >= fi.Size() && fi.Size() > 0 {
+			file.Close()
+			return nil, fmt.Errorf("failed to get object: range start %d beyond object size %d", start, fi.Size())
+		}
+		if end < 0 || end >= fi.Size() {
+			end = fi.Size() - 1
+		}
+		if _, err := file.Seek(start, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to seek object: %w", err)
+		}
+		length := end - start + 1
+		if length < 0 {
+			length = 0
+		}
+		obj.reader = io.LimitReader(file, length)
+		obj.info.Size = length
+	}
+
+	return obj, nil
+}
+
+// RemoveObject deletes an object. Removing an object that does not exist is
+// not an error, matching S3 DeleteObject semantics.
+func (s *LocalFSStorage) RemoveObject(ctx context.Context, objectName string, opts RemoveObjectOptions) error {
+	path, err := s.objectPath(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove object: %w", err)
+	}
+	return nil
+}
+
+// GetPresignedURL is not supported: there is no HTTP endpoint in front of a
+// local directory. All downloads are proxied through the Arkfile server.
+func (s *LocalFSStorage) GetPresignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	return "", fmt.Errorf("presigned URLs are not supported by the local filesystem provider")
+}
+
+// InitiateMultipartUpload creates a staging directory for the upload's parts.
+// Metadata is ignored: the local provider stores no per-object metadata.
+func (s *LocalFSStorage) InitiateMultipartUpload(ctx context.Context, objectName string, metadata map[string]string) (string, error) {
+	if err := validateLocalObjectName(objectName); err != nil {
+		return "", err
+	}
+
+	uploadID := uuid.New().String()
+	dir := filepath.Join(s.rootDir, localMultipartDir, uploadID)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, localUploadTarget), []byte(objectName), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// openUpload validates that uploadID exists and targets objectName.
+func (s *LocalFSStorage) openUpload(objectName, uploadID string) (string, error) {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return "", err
+	}
+	target, err := os.ReadFile(filepath.Join(dir, localUploadTarget))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("no such multipart upload: %s", uploadID)
+		}
+		return "", fmt.Errorf("failed to read multipart upload %s: %w", uploadID, err)
+	}
+	if string(target) != objectName {
+		return "", fmt.Errorf("multipart upload %s does not belong to object %s", uploadID, objectName)
+	}
+	return dir, nil
+}
+
+// UploadPart stores one part of a multipart upload. Re-uploading a part
+// number replaces the earlier data, as with S3.
+func (s *LocalFSStorage) UploadPart(ctx context.Context, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (CompletePart, error) {
+	if partNumber < 1 || partNumber > 10000 {
+		return CompletePart{}, fmt.Errorf("invalid part number %d", partNumber)
+	}
+	dir, err := s.openUpload(objectName, uploadID)
+	if err != nil {
+		return CompletePart{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return CompletePart{}, err
+	}
+
+	_, etag, err := s.writeAtomically(filepath.Join(dir, partFileName(partNumber)), reader, size)
+	if err != nil {
+		return CompletePart{}, fmt.Errorf("failed to upload part: %w", err)
+	}
+
+	return CompletePart{
+		PartNumber: partNumber,
+		ETag:       etag,
+	}, nil
+}
+
+// CompleteMultipartUpload concatenates the listed parts, in order, into the
+// final object and removes the staging directory. Each part's ETag must match
+// the one returned by UploadPart.
+func (s *LocalFSStorage) CompleteMultipartUpload(ctx context.Context, objectName, uploadID string, parts []CompletePart) error {
+	dir, err := s.openUpload(objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("cannot complete multipart upload %s with no parts", uploadID)
+	}
+	dest, err := s.objectPath(objectName)
+	if err != nil {
+		return err
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	files := make([]*os.File, 0, len(parts))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	lastPart := 0
+	for _, part := range parts {
+		if part.PartNumber <= lastPart {
+			return fmt.Errorf("parts must be listed in ascending order (part %d after %d)", part.PartNumber, lastPart)
+		}
+		lastPart = part.PartNumber
+
+		partPath := filepath.Join(dir, partFileName(part.PartNumber))
+		f, err := os.Open(partPath)
+		if err != nil {
+			return fmt.Errorf("missing part %d for upload %s: %w", part.PartNumber, uploadID, err)
+		}
+		files = append(files, f)
+
+		hasher := md5.New()
+		if _, err := io.Copy(hasher, f); err != nil {
+			return fmt.Errorf("failed to read part %d: %w", part.PartNumber, err)
+		}
+		if hex.EncodeToString(hasher.Sum(nil)) != strings.Trim(part.ETag, `"`) {
+			return fmt.Errorf("ETag mismatch for part %d of upload %s", part.PartNumber, uploadID)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind part %d: %w", part.PartNumber, err)
+		}
+		readers = append(readers, f)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, _, err := s.writeAtomically(dest, io.MultiReader(readers...), -1); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	for _, f := range files {
+		f.Close()
+	}
+	files = nil
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("multipart upload completed but staging cleanup failed: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards all parts of an in-progress upload.
+func (s *LocalFSStorage) AbortMultipartUpload(ctx context.Context, objectName, uploadID string) error {
+	dir, err := s.openUpload(objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// GetObjectChunk retrieves a specific chunk of an object
+func (s *LocalFSStorage) GetObjectChunk(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error) {
+	opts := GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	return s.GetObject(ctx, objectName, opts)
+}
+
+// HeadObject returns the size of an object in bytes without reading it.
+// Returns an error if the object does not exist.
+func (s *LocalFSStorage) HeadObject(ctx context.Context, objectName string) (int64, error) {
+	path, err := s.objectPath(objectName)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("HeadObject failed for %s: %w", objectName, err)
+	}
+	return fi.Size(), nil
+}
+
+// ListObjects returns the names of all completed objects, sorted.
+// In-progress multipart uploads and temp files are not included.
+func (s *LocalFSStorage) ListObjects(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.rootDir, localObjectsDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	objects := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			objects = append(objects, entry.Name())
+		}
+	}
+	sort.Strings(objects)
+	return objects, nil
+}
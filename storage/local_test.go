@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLocalProvider creates a LocalFSStorage rooted in a per-test temp directory.
+func newTestLocalProvider(t *testing.T) *LocalFSStorage {
+	t.Helper()
+	provider, err := NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	return provider
+}
+
+func putLocalObject(t *testing.T, provider *LocalFSStorage, name string, data []byte) {
+	t.Helper()
+	_, err := provider.PutObject(context.Background(), name, bytes.NewReader(data), int64(len(data)), PutObjectOptions{})
+	require.NoError(t, err)
+}
+
+// --- Basic object tests ---
+
+func TestLocalProvider_PutGetRoundTrip(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+	data := []byte("encrypted blob contents")
+
+	info, err := provider.PutObject(ctx, "obj-1", bytes.NewReader(data), int64(len(data)), PutObjectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "obj-1", info.Key)
+	assert.Equal(t, int64(len(data)), info.Size)
+	assert.NotEmpty(t, info.ETag)
+
+	obj, err := provider.GetObject(ctx, "obj-1", GetObjectOptions{})
+	require.NoError(t, err)
+	got, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.NoError(t, obj.Close())
+	assert.Equal(t, data, got)
+
+	stat, err := obj.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), stat.Size)
+
+	size, err := provider.HeadObject(ctx, "obj-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+}
+
+func TestLocalProvider_PutObject_SizeMismatch(t *testing.T) {
+	provider := newTestLocalProvider(t)
+
+	_, err := provider.PutObject(context.Background(), "obj-1", bytes.NewReader([]byte("short")), 100, PutObjectOptions{})
+	assert.Error(t, err)
+
+	// A failed write must not leave a visible object behind
+	_, err = provider.HeadObject(context.Background(), "obj-1")
+	assert.Error(t, err)
+}
+
+func TestLocalProvider_GetObject_Missing(t *testing.T) {
+	provider := newTestLocalProvider(t)
+
+	_, err := provider.GetObject(context.Background(), "missing", GetObjectOptions{})
+	assert.Error(t, err)
+	_, err = provider.HeadObject(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestLocalProvider_RemoveObject_Idempotent(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	putLocalObject(t, provider, "obj-1", []byte("data"))
+
+	assert.NoError(t, provider.RemoveObject(context.Background(), "obj-1", RemoveObjectOptions{}))
+	assert.NoError(t, provider.RemoveObject(context.Background(), "obj-1", RemoveObjectOptions{}))
+
+	_, err := provider.HeadObject(context.Background(), "obj-1")
+	assert.Error(t, err)
+}
+
+func TestLocalProvider_RejectsPathTraversal(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"", ".", "..", "../escape", "a/b", `a\b`} {
+		_, err := provider.PutObject(ctx, name, bytes.NewReader([]byte("x")), 1, PutObjectOptions{})
+		assert.Error(t, err, "name %q should be rejected", name)
+		_, err = provider.InitiateMultipartUpload(ctx, name, nil)
+		assert.Error(t, err, "name %q should be rejected", name)
+	}
+
+	_, err := os.Stat(filepath.Join(filepath.Dir(provider.RootDir()), "escape"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalProvider_GetPresignedURL_Unsupported(t *testing.T) {
+	provider := newTestLocalProvider(t)
+
+	_, err := provider.GetPresignedURL(context.Background(), "obj-1", 0)
+	assert.Error(t, err)
+}
+
+// --- Range read tests ---
+
+func TestLocalProvider_GetObjectChunk(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	putLocalObject(t, provider, "obj-1", []byte("0123456789"))
+
+	reader, err := provider.GetObjectChunk(context.Background(), "obj-1", 3, 4)
+	require.NoError(t, err)
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "3456", string(got))
+}
+
+func TestLocalProvider_GetObjectChunk_ClampsToEnd(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	putLocalObject(t, provider, "obj-1", []byte("0123456789"))
+
+	reader, err := provider.GetObjectChunk(context.Background(), "obj-1", 8, 100)
+	require.NoError(t, err)
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "89", string(got))
+}
+
+func TestLocalProvider_GetObjectChunk_StartBeyondEnd(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	putLocalObject(t, provider, "obj-1", []byte("0123456789"))
+
+	_, err := provider.GetObjectChunk(context.Background(), "obj-1", 10, 4)
+	assert.Error(t, err)
+}
+
+// --- Multipart tests ---
+
+func TestLocalProvider_MultipartUpload(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	uploadID, err := provider.InitiateMultipartUpload(ctx, "obj-1", map[string]string{"k": "v"})
+	require.NoError(t, err)
+
+	// Upload out of order; completion must follow part numbers, not arrival order
+	part2, err := provider.UploadPart(ctx, "obj-1", uploadID, 2, bytes.NewReader([]byte("world")), 5)
+	require.NoError(t, err)
+	part1, err := provider.UploadPart(ctx, "obj-1", uploadID, 1, bytes.NewReader([]byte("hello ")), 6)
+	require.NoError(t, err)
+
+	// Parts are not visible as objects until completion
+	objects, err := provider.ListObjects(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	require.NoError(t, provider.CompleteMultipartUpload(ctx, "obj-1", uploadID, []CompletePart{part1, part2}))
+
+	obj, err := provider.GetObject(ctx, "obj-1", GetObjectOptions{})
+	require.NoError(t, err)
+	got, err := io.ReadAll(obj)
+	obj.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(got))
+
+	// Staging directory is cleaned up
+	_, err = os.Stat(filepath.Join(provider.RootDir(), localMultipartDir, uploadID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalProvider_CompleteMultipartUpload_ETagMismatch(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	uploadID, err := provider.InitiateMultipartUpload(ctx, "obj-1", nil)
+	require.NoError(t, err)
+	part, err := provider.UploadPart(ctx, "obj-1", uploadID, 1, bytes.NewReader([]byte("data")), 4)
+	require.NoError(t, err)
+
+	part.ETag = "deadbeef"
+	err = provider.CompleteMultipartUpload(ctx, "obj-1", uploadID, []CompletePart{part})
+	assert.Error(t, err)
+
+	_, err = provider.HeadObject(ctx, "obj-1")
+	assert.Error(t, err)
+}
+
+func TestLocalProvider_CompleteMultipartUpload_MissingPart(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	uploadID, err := provider.InitiateMultipartUpload(ctx, "obj-1", nil)
+	require.NoError(t, err)
+	part1, err := provider.UploadPart(ctx, "obj-1", uploadID, 1, bytes.NewReader([]byte("data")), 4)
+	require.NoError(t, err)
+
+	err = provider.CompleteMultipartUpload(ctx, "obj-1", uploadID, []CompletePart{part1, {PartNumber: 2, ETag: "x"}})
+	assert.Error(t, err)
+}
+
+func TestLocalProvider_AbortMultipartUpload(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	uploadID, err := provider.InitiateMultipartUpload(ctx, "obj-1", nil)
+	require.NoError(t, err)
+	_, err = provider.UploadPart(ctx, "obj-1", uploadID, 1, bytes.NewReader([]byte("data")), 4)
+	require.NoError(t, err)
+
+	require.NoError(t, provider.AbortMultipartUpload(ctx, "obj-1", uploadID))
+
+	// The upload no longer exists
+	_, err = provider.UploadPart(ctx, "obj-1", uploadID, 2, bytes.NewReader([]byte("more")), 4)
+	assert.Error(t, err)
+	assert.Error(t, provider.AbortMultipartUpload(ctx, "obj-1", uploadID))
+}
+
+func TestLocalProvider_UploadPart_WrongObject(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	uploadID, err := provider.InitiateMultipartUpload(ctx, "obj-1", nil)
+	require.NoError(t, err)
+
+	_, err = provider.UploadPart(ctx, "obj-2", uploadID, 1, bytes.NewReader([]byte("data")), 4)
+	assert.Error(t, err)
+}
+
+// --- ListObjects tests ---
+
+func TestLocalProvider_ListObjects(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	putLocalObject(t, provider, "c", []byte("3"))
+	putLocalObject(t, provider, "a", []byte("1"))
+	putLocalObject(t, provider, "b", []byte("2"))
+
+	objects, err := provider.ListObjects(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, objects)
+}
+
+// --- Registry integration tests (real providers, no mocks) ---
+
+func TestLocalProvider_CopyObjectBetweenProviders_SmallObject(t *testing.T) {
+	source := newTestLocalProvider(t)
+	dest := newTestLocalProvider(t)
+	data := []byte("small encrypted blob")
+	putLocalObject(t, source, "obj-1", data)
+
+	reg := NewProviderRegistry(source, "local-a")
+	reg.SetSecondary(dest, "local-b")
+
+	hash, err := reg.CopyObjectBetweenProviders(context.Background(), "obj-1", source, dest, int64(len(data)), nil)
+	require.NoError(t, err)
+
+	expected := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(expected[:]), hash)
+
+	size, err := dest.HeadObject(context.Background(), "obj-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+}
+
+func TestLocalProvider_CopyObjectBetweenProviders_Multipart(t *testing.T) {
+	source := newTestLocalProvider(t)
+	dest := newTestLocalProvider(t)
+
+	// Just above the multipart threshold so the copy goes through the multipart path
+	data := bytes.Repeat([]byte{0xA5}, CopyMultipartThreshold+1024)
+	putLocalObject(t, source, "obj-1", data)
+
+	reg := NewProviderRegistry(source, "local-a")
+	reg.SetSecondary(dest, "local-b")
+
+	var lastProgress int64
+	hash, err := reg.CopyObjectBetweenProviders(context.Background(), "obj-1", source, dest, int64(len(data)),
+		func(bytesCopied int64) { lastProgress = bytesCopied })
+	require.NoError(t, err)
+
+	expected := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(expected[:]), hash)
+	assert.Equal(t, int64(len(data)), lastProgress)
+
+	obj, err := dest.GetObject(context.Background(), "obj-1", GetObjectOptions{})
+	require.NoError(t, err)
+	got, err := io.ReadAll(obj)
+	obj.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestLocalProvider_GetObjectChunkWithFallback(t *testing.T) {
+	primary := newTestLocalProvider(t)
+	secondary := newTestLocalProvider(t)
+
+	// Only the secondary holds the object
+	putLocalObject(t, secondary, "obj-1", []byte("0123456789"))
+
+	reg := NewProviderRegistry(primary, "local-a")
+	reg.SetSecondary(secondary, "local-b")
+
+	reader, providerID, err := reg.GetObjectChunkWithFallback(context.Background(), "obj-1", 2, 3)
+	require.NoError(t, err)
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(got))
+	assert.Equal(t, "local-b", providerID)
+}
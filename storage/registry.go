@@ -39,6 +39,16 @@ type ProviderRegistry struct {
 	primaryID   string                // e.g. "seaweedfs-local"
 	secondaryID string                // e.g. "wasabi-us-central-1"
 	tertiaryID  string                // e.g. "backblaze-us-west"
+
+	writePolicy WritePolicy // see write_policy.go; empty means primary-only
+	writeQuorum int         // providers required under WritePolicyQuorum
+
+	erasureData    int               // data shards under WritePolicyErasure (erasure.go)
+	erasureParity  int               // parity shards under WritePolicyErasure
+	erasureLayouts ErasureLayoutFunc // recognizes erasure-coded objects on read
+
+	readStats       *readStatsTracker // per-provider read latency/error stats (hedged_read.go)
+	hedgingDisabled bool
 }
 
 // Registry is the global provider registry instance.
@@ -50,6 +60,7 @@ func NewProviderRegistry(primary ObjectStorageProvider, primaryID string) *Provi
 	return &ProviderRegistry{
 		primary:   primary,
 		primaryID: primaryID,
+		readStats: newReadStatsTracker(),
 	}
 }
 
@@ -119,6 +130,26 @@ func (r *ProviderRegistry) SwapPrimarySecondary() {
 	r.primaryID, r.secondaryID = r.secondaryID, r.primaryID
 }
 
+// RemoveProvider takes a secondary or tertiary provider out of the in-memory
+// registry, e.g. after it has been drained. The primary cannot be removed.
+// Removing the secondary moves the tertiary (if any) into the secondary slot
+// so the primary -> secondary -> tertiary chain never has a gap.
+// Returns false if providerID is not the secondary or tertiary provider.
+func (r *ProviderRegistry) RemoveProvider(providerID string) bool {
+	switch {
+	case providerID == "" || providerID == r.primaryID:
+		return false
+	case providerID == r.secondaryID:
+		r.secondary, r.secondaryID = r.tertiary, r.tertiaryID
+		r.tertiary, r.tertiaryID = nil, ""
+	case providerID == r.tertiaryID:
+		r.tertiary, r.tertiaryID = nil, ""
+	default:
+		return false
+	}
+	return true
+}
+
 // GetProvider returns the provider instance matching the given ID, or nil if not found.
 func (r *ProviderRegistry) GetProvider(providerID string) ObjectStorageProvider {
 	switch providerID {
@@ -135,7 +166,15 @@ func (r *ProviderRegistry) GetProvider(providerID string) ObjectStorageProvider
 
 // GetObjectWithFallback attempts to GET from primary. On failure, tries secondary,
 // then tertiary. Returns the object, the provider ID that served it, and any error.
+// Erasure-coded objects are streamed from their shards instead (opts is ignored
+// for them) and the returned provider ID is empty.
 func (r *ProviderRegistry) GetObjectWithFallback(ctx context.Context, objectName string, opts GetObjectOptions) (ReadableStoredObject, string, error) {
+	if layout, err := r.lookupErasureLayout(ctx, objectName); err != nil {
+		return nil, "", err
+	} else if layout != nil {
+		return &erasureObject{ctx: ctx, r: r, name: objectName, layout: layout}, "", nil
+	}
+
 	// Try primary
 	obj, err := r.primary.GetObject(ctx, objectName, opts)
 	if err == nil {
@@ -325,40 +364,3 @@ func (r *ProviderRegistry) RemoveObjectAll(ctx context.Context, locations []Remo
 	}
 	return results
 }
-
-// GetObjectChunkWithFallback attempts chunked GET from primary, then secondary,
-// then tertiary. Returns the chunk reader, the provider ID that served it, and any error.
-func (r *ProviderRegistry) GetObjectChunkWithFallback(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, string, error) {
-	// Try primary
-	reader, err := r.primary.GetObjectChunk(ctx, objectName, offset, length)
-	if err == nil {
-		return reader, r.primaryID, nil
-	}
-	primaryErr := err
-
-	// Try secondary if available
-	if r.secondary != nil {
-		log.Printf("Primary provider %s failed for GetObjectChunk(%s), trying secondary %s: %v", r.primaryID, objectName, r.secondaryID, primaryErr)
-		reader, err = r.secondary.GetObjectChunk(ctx, objectName, offset, length)
-		if err == nil {
-			return reader, r.secondaryID, nil
-		}
-		log.Printf("Secondary provider %s also failed for GetObjectChunk(%s): %v", r.secondaryID, objectName, err)
-
-		// Try tertiary if available
-		if r.tertiary != nil {
-			log.Printf("Trying tertiary provider %s for GetObjectChunk(%s)", r.tertiaryID, objectName)
-			reader, err = r.tertiary.GetObjectChunk(ctx, objectName, offset, length)
-			if err == nil {
-				return reader, r.tertiaryID, nil
-			}
-			log.Printf("Tertiary provider %s also failed for GetObjectChunk(%s): %v", r.tertiaryID, objectName, err)
-			return nil, "", fmt.Errorf("all providers failed for GetObjectChunk(%s): primary(%s): %v", objectName, r.primaryID, primaryErr)
-		}
-
-		return nil, "", fmt.Errorf("all providers failed for GetObjectChunk(%s): primary(%s): %v", objectName, r.primaryID, primaryErr)
-	}
-
-	// Single provider mode
-	return nil, "", primaryErr
-}
@@ -97,6 +97,51 @@ func TestSwapPrimarySecondary(t *testing.T) {
 	assert.Equal(t, primary, reg.Secondary())
 }
 
+// --- RemoveProvider tests ---
+
+func TestRemoveProvider(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	secondary := new(MockObjectStorageProvider)
+	tertiary := new(MockObjectStorageProvider)
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+	reg.SetTertiary(tertiary, "tertiary-1")
+
+	assert.False(t, reg.RemoveProvider("primary-1"), "primary cannot be removed")
+	assert.False(t, reg.RemoveProvider("unknown"))
+
+	assert.True(t, reg.RemoveProvider("tertiary-1"))
+	assert.False(t, reg.HasTertiary())
+	assert.Nil(t, reg.GetProvider("tertiary-1"))
+
+	assert.True(t, reg.RemoveProvider("secondary-1"))
+	assert.False(t, reg.HasSecondary())
+	assert.Equal(t, 1, reg.ConfiguredProviderCount())
+}
+
+func TestRemoveProvider_SecondaryPromotesTertiary(t *testing.T) {
+	primary := new(MockObjectStorageProvider)
+	secondary := new(MockObjectStorageProvider)
+	tertiary := new(MockObjectStorageProvider)
+	reg := newTestRegistry(primary, "primary-1")
+	reg.SetSecondary(secondary, "secondary-1")
+	reg.SetTertiary(tertiary, "tertiary-1")
+
+	assert.True(t, reg.RemoveProvider("secondary-1"))
+	assert.True(t, reg.HasSecondary())
+	assert.False(t, reg.HasTertiary())
+	assert.Equal(t, "tertiary-1", reg.SecondaryID())
+	assert.Equal(t, tertiary, reg.Secondary())
+	assert.Empty(t, reg.TertiaryID())
+	assert.Nil(t, reg.GetProvider("secondary-1"))
+	assert.Equal(t, []string{"primary-1", "tertiary-1"}, reg.ConfiguredProviderIDs())
+
+	// The promoted provider can itself be removed again
+	assert.True(t, reg.RemoveProvider("tertiary-1"))
+	assert.False(t, reg.HasSecondary())
+	assert.Equal(t, 1, reg.ConfiguredProviderCount())
+}
+
 // --- GetObjectWithFallback tests ---
 
 func TestGetObjectWithFallback_PrimarySucceeds(t *testing.T) {
@@ -62,6 +62,16 @@ type S3ProviderConfig struct {
 	Bucket         string
 	Region         string
 	ForcePathStyle bool
+	LocalPath      string // root directory, only used by ProviderLocal
+}
+
+// location returns a human-readable description of where the provider stores
+// objects, for log messages: the bucket for S3 providers, the path for local.
+func (cfg S3ProviderConfig) location() string {
+	if cfg.ProviderType == ProviderLocal {
+		return cfg.LocalPath
+	}
+	return cfg.Bucket
 }
 
 // NewS3Provider creates a new S3AWSStorage instance from the given config.
@@ -112,6 +122,7 @@ func readStorageEnvVars(slot int) *S3ProviderConfig {
 	accessKey := os.Getenv(storageSlotEnvKey(slot, "ACCESS_KEY"))
 	secretKey := os.Getenv(storageSlotEnvKey(slot, "SECRET_KEY"))
 	bucketName := os.Getenv(storageSlotEnvKey(slot, "BUCKET"))
+	localPath := os.Getenv(storageSlotEnvKey(slot, "PATH"))
 	defaultEndpoint, usePathStyle := defaultEndpointAndPathStyle(provider, region)
 	if endpointURL == "" {
 		endpointURL = defaultEndpoint
@@ -128,7 +139,11 @@ func readStorageEnvVars(slot int) *S3ProviderConfig {
 
 	providerID := os.Getenv(storageProviderIDEnvKey(slot))
 	if providerID == "" {
-		providerID = fmt.Sprintf("%s:%s", provider, bucketName)
+		if provider == ProviderLocal {
+			providerID = fmt.Sprintf("%s:%s", provider, localPath)
+		} else {
+			providerID = fmt.Sprintf("%s:%s", provider, bucketName)
+		}
 	}
 
 	return &S3ProviderConfig{
@@ -140,6 +155,7 @@ func readStorageEnvVars(slot int) *S3ProviderConfig {
 		Bucket:         bucketName,
 		Region:         region,
 		ForcePathStyle: usePathStyle,
+		LocalPath:      localPath,
 	}
 }
 
@@ -201,40 +217,52 @@ func ensureBucketExists(client *s3.Client, bucketName string) {
 	}
 }
 
-// InitS3 initializes the S3 storage provider(s) and builds the ProviderRegistry.
+// newProviderFromConfig creates the provider implementation for cfg.ProviderType.
+// S3-compatible providers go through NewS3Provider; generic-s3 buckets are
+// created on first use. Local providers create their directory layout.
+func newProviderFromConfig(cfg S3ProviderConfig) (ObjectStorageProvider, error) {
+	if cfg.ProviderType == ProviderLocal {
+		if cfg.LocalPath == "" {
+			return nil, fmt.Errorf("local storage provider %s requires a PATH setting", cfg.ProviderID)
+		}
+		return NewLocalProvider(cfg.LocalPath)
+	}
+
+	provider, err := NewS3Provider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ProviderType == ProviderGenericS3 {
+		ensureBucketExists(provider.client, cfg.Bucket)
+	}
+	return provider, nil
+}
+
+// InitS3 initializes the storage provider(s) and builds the ProviderRegistry.
 func InitS3() error {
 	// Read and create primary provider
 	primaryCfg := readStorageEnvVars(1)
 	if primaryCfg == nil {
 		return fmt.Errorf("failed to initialize primary storage provider: STORAGE_PROVIDER_1 is not set")
 	}
-	primaryProvider, err := NewS3Provider(*primaryCfg)
+	primaryProvider, err := newProviderFromConfig(*primaryCfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize primary storage provider: %w", err)
 	}
 
 	// Build registry with primary
 	Registry = NewProviderRegistry(primaryProvider, primaryCfg.ProviderID)
-	log.Printf("Storage: primary provider initialized: %s (type=%s, bucket=%s)", primaryCfg.ProviderID, primaryCfg.ProviderType, primaryCfg.Bucket)
-
-	// Ensure primary bucket exists (for generic-s3 / local providers)
-	if primaryCfg.ProviderType == ProviderGenericS3 {
-		ensureBucketExists(primaryProvider.client, primaryCfg.Bucket)
-	}
+	log.Printf("Storage: primary provider initialized: %s (type=%s, location=%s)", primaryCfg.ProviderID, primaryCfg.ProviderType, primaryCfg.location())
 
 	// Read and create optional secondary provider
 	secondaryCfg := readStorageEnvVars(2)
 	if secondaryCfg != nil {
-		secondaryProvider, err := NewS3Provider(*secondaryCfg)
+		secondaryProvider, err := newProviderFromConfig(*secondaryCfg)
 		if err != nil {
 			log.Printf("Warning: Failed to initialize secondary storage provider %s: %v", secondaryCfg.ProviderID, err)
 		} else {
 			Registry.SetSecondary(secondaryProvider, secondaryCfg.ProviderID)
-			log.Printf("Storage: secondary provider initialized: %s (type=%s, bucket=%s)", secondaryCfg.ProviderID, secondaryCfg.ProviderType, secondaryCfg.Bucket)
-
-			if secondaryCfg.ProviderType == ProviderGenericS3 {
-				ensureBucketExists(secondaryProvider.client, secondaryCfg.Bucket)
-			}
+			log.Printf("Storage: secondary provider initialized: %s (type=%s, location=%s)", secondaryCfg.ProviderID, secondaryCfg.ProviderType, secondaryCfg.location())
 		}
 	}
 
@@ -242,16 +270,12 @@ func InitS3() error {
 	if Registry.HasSecondary() {
 		tertiaryCfg := readStorageEnvVars(3)
 		if tertiaryCfg != nil {
-			tertiaryProvider, err := NewS3Provider(*tertiaryCfg)
+			tertiaryProvider, err := newProviderFromConfig(*tertiaryCfg)
 			if err != nil {
 				log.Printf("Warning: Failed to initialize tertiary storage provider %s: %v", tertiaryCfg.ProviderID, err)
 			} else {
 				Registry.SetTertiary(tertiaryProvider, tertiaryCfg.ProviderID)
-				log.Printf("Storage: tertiary provider initialized: %s (type=%s, bucket=%s)", tertiaryCfg.ProviderID, tertiaryCfg.ProviderType, tertiaryCfg.Bucket)
-
-				if tertiaryCfg.ProviderType == ProviderGenericS3 {
-					ensureBucketExists(tertiaryProvider.client, tertiaryCfg.Bucket)
-				}
+				log.Printf("Storage: tertiary provider initialized: %s (type=%s, location=%s)", tertiaryCfg.ProviderID, tertiaryCfg.ProviderType, tertiaryCfg.location())
 			}
 		}
 	}
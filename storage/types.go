@@ -74,4 +74,5 @@ const (
 	ProviderHetzner      StorageProvider = "hetzner"
 	ProviderAmazonS3     StorageProvider = "aws-s3"
 	ProviderGenericS3    StorageProvider = "generic-s3"
+	ProviderLocal        StorageProvider = "local"
 )
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// WritePolicy controls how many providers must hold a verified copy of a newly
+// uploaded blob before the upload is acknowledged to the client.
+type WritePolicy string
+
+const (
+	// WritePolicyPrimaryOnly stores new uploads on the primary provider only.
+	WritePolicyPrimaryOnly WritePolicy = "primary-only"
+
+	// WritePolicyAsyncSecondary acknowledges the upload once it is on the primary
+	// and queues a background copy to the secondary provider.
+	WritePolicyAsyncSecondary WritePolicy = "async-secondary"
+
+	// WritePolicyQuorum acknowledges the upload only after the blob has been
+	// copied to, and hash-verified on, WriteQuorum providers (primary included).
+	WritePolicyQuorum WritePolicy = "quorum"
+
+	// WritePolicyErasure stripes each upload into Reed-Solomon data and parity
+	// shards across the configured providers (see erasure.go) instead of
+	// keeping full replicas. The upload is acknowledged once every shard is
+	// written.
+	WritePolicyErasure WritePolicy = "erasure"
+)
+
+// ParseWritePolicy parses a STORAGE_WRITE_POLICY value. Quorum policies may be
+// written as "quorum" (with the count supplied separately) or as "N-of-M"
+// shorthand such as "2-of-3", in which case the returned quorum is N and the
+// returned provider count is M. The provider count is 0 when the value does not
+// name one; otherwise the caller must check it with CheckProviderCount.
+func ParseWritePolicy(value string) (WritePolicy, int, int, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch WritePolicy(v) {
+	case WritePolicyPrimaryOnly:
+		return WritePolicyPrimaryOnly, 1, 0, nil
+	case WritePolicyAsyncSecondary:
+		return WritePolicyAsyncSecondary, 1, 0, nil
+	case WritePolicyQuorum:
+		return WritePolicyQuorum, 0, 0, nil
+	case WritePolicyErasure:
+		return WritePolicyErasure, 1, 0, nil
+	}
+
+	if n, m, ok := strings.Cut(v, "-of-"); ok {
+		quorum, errN := strconv.Atoi(n)
+		total, errM := strconv.Atoi(m)
+		if errN == nil && errM == nil && quorum >= 1 && quorum <= total {
+			return WritePolicyQuorum, quorum, total, nil
+		}
+	}
+
+	return "", 0, 0, fmt.Errorf("invalid storage write policy %q (expected primary-only, async-secondary, quorum, N-of-M, or erasure)", value)
+}
+
+// CheckProviderCount verifies that exactly `providers` providers are
+// configured, so that an "N-of-M" write policy means what it says: "2-of-5"
+// on a three-provider deployment is rejected rather than silently run as
+// "2-of-3".
+func (r *ProviderRegistry) CheckProviderCount(providers int) error {
+	if configured := r.ConfiguredProviderCount(); providers != configured {
+		return fmt.Errorf("write policy expects %d provider(s) but %d are configured", providers, configured)
+	}
+	return nil
+}
+
+// SetWritePolicy configures the upload write policy. For WritePolicyQuorum,
+// quorum is the number of providers (including the primary) that must hold an
+// active copy; it must not exceed the number of configured providers.
+// WritePolicyErasure requires SetErasureCoding to have been called first. Other
+// policies ignore quorum.
+func (r *ProviderRegistry) SetWritePolicy(policy WritePolicy, quorum int) error {
+	switch policy {
+	case WritePolicyPrimaryOnly, WritePolicyAsyncSecondary:
+		quorum = 1
+	case WritePolicyQuorum:
+		configured := r.ConfiguredProviderCount()
+		if quorum < 1 || quorum > configured {
+			return fmt.Errorf("write quorum %d is not satisfiable with %d configured provider(s)", quorum, configured)
+		}
+	case WritePolicyErasure:
+		if r.erasureData == 0 {
+			return fmt.Errorf("erasure write policy needs erasure coding to be configured")
+		}
+		quorum = 1
+	default:
+		return fmt.Errorf("unknown write policy %q", policy)
+	}
+
+	r.writePolicy = policy
+	r.writeQuorum = quorum
+	return nil
+}
+
+// WritePolicy returns the configured write policy. Defaults to primary-only.
+func (r *ProviderRegistry) WritePolicy() WritePolicy {
+	if r.writePolicy == "" {
+		return WritePolicyPrimaryOnly
+	}
+	return r.writePolicy
+}
+
+// RequiredWriteCopies returns how many providers must hold an active copy of a
+// new upload before it is acknowledged. Always at least 1.
+func (r *ProviderRegistry) RequiredWriteCopies() int {
+	if r.WritePolicy() != WritePolicyQuorum || r.writeQuorum < 1 {
+		return 1
+	}
+	return r.writeQuorum
+}
+
+// ConfiguredProviderCount returns the number of providers (1 to 3) in the registry.
+func (r *ProviderRegistry) ConfiguredProviderCount() int {
+	count := 0
+	if r.primary != nil {
+		count++
+	}
+	if r.secondary != nil {
+		count++
+	}
+	if r.tertiary != nil {
+		count++
+	}
+	return count
+}
+
+// ConfiguredProviderIDs returns the IDs of the configured providers in
+// primary, secondary, tertiary order.
+func (r *ProviderRegistry) ConfiguredProviderIDs() []string {
+	var ids []string
+	if r.primary != nil {
+		ids = append(ids, r.primaryID)
+	}
+	if r.secondary != nil {
+		ids = append(ids, r.secondaryID)
+	}
+	if r.tertiary != nil {
+		ids = append(ids, r.tertiaryID)
+	}
+	return ids
+}
+
+// ReplicationTarget returns how many providers should hold an active copy of
+// every blob under the current write policy: the write quorum for quorum
+// writes, two for async-secondary when a secondary is configured, otherwise
+// one. Background repair uses this to find under-replicated files.
+func (r *ProviderRegistry) ReplicationTarget() int {
+	switch r.WritePolicy() {
+	case WritePolicyQuorum:
+		return r.RequiredWriteCopies()
+	case WritePolicyAsyncSecondary:
+		if r.secondary != nil {
+			return 2
+		}
+	}
+	return 1
+}
+
+// ReplicaResult describes one verified replica created by ReplicateObject.
+type ReplicaResult struct {
+	ProviderID string
+	SHA256     string
+}
+
+// ReplicateObject copies objectName from the primary to the secondary and then
+// tertiary providers until `needed` verified replicas exist. Each copy's SHA-256
+// is checked against expectedSHA256 (skipped when empty); a mismatching copy is
+// removed and the next provider is tried.
+//
+// If fewer than `needed` replicas can be created, every replica that was
+// written is removed again and an error is returned, so the caller never ends
+// up with a partially replicated object it has not recorded.
+func (r *ProviderRegistry) ReplicateObject(ctx context.Context, objectName string, objectSize int64, expectedSHA256 string, needed int) ([]ReplicaResult, error) {
+	var targetIDs []string
+	if r.secondary != nil {
+		targetIDs = append(targetIDs, r.secondaryID)
+	}
+	if r.tertiary != nil {
+		targetIDs = append(targetIDs, r.tertiaryID)
+	}
+	return r.ReplicateObjectFrom(ctx, r.primaryID, targetIDs, objectName, objectSize, expectedSHA256, needed)
+}
+
+// ReplicateObjectFrom is ReplicateObject with an explicit source provider and
+// an ordered list of candidate target providers, as used by storage placement
+// policies. Target IDs that are not configured are ignored.
+func (r *ProviderRegistry) ReplicateObjectFrom(ctx context.Context, sourceID string, targetIDs []string, objectName string, objectSize int64, expectedSHA256 string, needed int) ([]ReplicaResult, error) {
+	if needed <= 0 {
+		return nil, nil
+	}
+
+	source := r.GetProvider(sourceID)
+	if source == nil {
+		return nil, fmt.Errorf("source provider %s is not configured", sourceID)
+	}
+
+	type target struct {
+		id       string
+		provider ObjectStorageProvider
+	}
+	var targets []target
+	for _, id := range targetIDs {
+		if id == sourceID {
+			continue
+		}
+		if provider := r.GetProvider(id); provider != nil {
+			targets = append(targets, target{id, provider})
+		}
+	}
+	if len(targets) < needed {
+		return nil, fmt.Errorf("write quorum needs %d replica(s) but only %d replica provider(s) are configured", needed, len(targets))
+	}
+
+	var replicas []ReplicaResult
+	var lastErr error
+	for _, t := range targets {
+		if len(replicas) == needed {
+			break
+		}
+
+		hash, err := r.CopyObjectBetweenProviders(ctx, objectName, source, t.provider, objectSize, nil)
+		if err == nil && expectedSHA256 != "" && hash != expectedSHA256 {
+			err = fmt.Errorf("hash mismatch on %s (expected %s, got %s)", t.id, expectedSHA256, hash)
+		}
+		if err != nil {
+			log.Printf("Storage: replica of %s to %s failed: %v", objectName, t.id, err)
+			lastErr = err
+			// Best effort: don't leave an unrecorded, possibly corrupt copy behind
+			t.provider.RemoveObject(ctx, objectName, RemoveObjectOptions{})
+			continue
+		}
+		replicas = append(replicas, ReplicaResult{ProviderID: t.id, SHA256: hash})
+	}
+
+	if len(replicas) < needed {
+		for _, replica := range replicas {
+			if provider := r.GetProvider(replica.ProviderID); provider != nil {
+				provider.RemoveObject(ctx, objectName, RemoveObjectOptions{})
+			}
+		}
+		return nil, fmt.Errorf("write quorum not reached for %s: %d of %d replica(s) created: %w", objectName, len(replicas), needed, lastErr)
+	}
+	return replicas, nil
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newLocalQuorumRegistry builds a registry of n (1-3) local providers.
+func newLocalQuorumRegistry(t *testing.T, n int) (*ProviderRegistry, []*LocalFSStorage) {
+	t.Helper()
+	providers := make([]*LocalFSStorage, n)
+	for i := range providers {
+		providers[i] = newTestLocalProvider(t)
+	}
+	reg := NewProviderRegistry(providers[0], "p1")
+	if n > 1 {
+		reg.SetSecondary(providers[1], "p2")
+	}
+	if n > 2 {
+		reg.SetTertiary(providers[2], "p3")
+	}
+	return reg, providers
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// --- ParseWritePolicy tests ---
+
+func TestParseWritePolicy(t *testing.T) {
+	tests := []struct {
+		input         string
+		wantPolicy    WritePolicy
+		wantQuorum    int
+		wantProviders int
+		wantErr       bool
+	}{
+		{"primary-only", WritePolicyPrimaryOnly, 1, 0, false},
+		{"async-secondary", WritePolicyAsyncSecondary, 1, 0, false},
+		{"quorum", WritePolicyQuorum, 0, 0, false},
+		{" Quorum ", WritePolicyQuorum, 0, 0, false},
+		{"2-of-3", WritePolicyQuorum, 2, 3, false},
+		{"3-of-3", WritePolicyQuorum, 3, 3, false},
+		{"2-of-5", WritePolicyQuorum, 2, 5, false},
+		{"erasure", WritePolicyErasure, 1, 0, false},
+		{"4-of-3", "", 0, 0, true},
+		{"0-of-2", "", 0, 0, true},
+		{"sync", "", 0, 0, true},
+		{"", "", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			policy, quorum, providers, err := ParseWritePolicy(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPolicy, policy)
+			assert.Equal(t, tt.wantQuorum, quorum)
+			assert.Equal(t, tt.wantProviders, providers)
+		})
+	}
+}
+
+func TestCheckProviderCount(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 3)
+	assert.NoError(t, reg.CheckProviderCount(3))
+	assert.Error(t, reg.CheckProviderCount(5), "2-of-5 on three providers")
+	assert.Error(t, reg.CheckProviderCount(2))
+}
+
+// --- SetWritePolicy tests ---
+
+func TestSetWritePolicy_DefaultsToPrimaryOnly(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 1)
+	assert.Equal(t, WritePolicyPrimaryOnly, reg.WritePolicy())
+	assert.Equal(t, 1, reg.RequiredWriteCopies())
+}
+
+func TestSetWritePolicy_QuorumWithinProviders(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 3)
+	require.NoError(t, reg.SetWritePolicy(WritePolicyQuorum, 2))
+	assert.Equal(t, WritePolicyQuorum, reg.WritePolicy())
+	assert.Equal(t, 2, reg.RequiredWriteCopies())
+}
+
+func TestSetWritePolicy_QuorumExceedsProviders(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 2)
+	assert.Error(t, reg.SetWritePolicy(WritePolicyQuorum, 3))
+	assert.Equal(t, WritePolicyPrimaryOnly, reg.WritePolicy())
+}
+
+func TestSetWritePolicy_AsyncIgnoresQuorum(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 2)
+	require.NoError(t, reg.SetWritePolicy(WritePolicyAsyncSecondary, 5))
+	assert.Equal(t, 1, reg.RequiredWriteCopies())
+}
+
+// --- ReplicateObject tests ---
+
+func TestReplicateObject_ReachesQuorum(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	data := []byte("padded encrypted blob")
+	putLocalObject(t, providers[0], "blob", data)
+
+	replicas, err := reg.ReplicateObject(context.Background(), "blob", int64(len(data)), sha256Hex(data), 2)
+	require.NoError(t, err)
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "p2", replicas[0].ProviderID)
+	assert.Equal(t, "p3", replicas[1].ProviderID)
+
+	for _, p := range providers[1:] {
+		size, err := p.HeadObject(context.Background(), "blob")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), size)
+	}
+}
+
+func TestReplicateObject_StopsAtQuorum(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	data := []byte("blob")
+	putLocalObject(t, providers[0], "blob", data)
+
+	replicas, err := reg.ReplicateObject(context.Background(), "blob", int64(len(data)), sha256Hex(data), 1)
+	require.NoError(t, err)
+	require.Len(t, replicas, 1)
+	assert.Equal(t, "p2", replicas[0].ProviderID)
+
+	// Tertiary is not written when the secondary already satisfies the quorum
+	_, err = providers[2].HeadObject(context.Background(), "blob")
+	assert.Error(t, err)
+}
+
+func TestReplicateObject_HashMismatchRollsBack(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	data := []byte("blob")
+	putLocalObject(t, providers[0], "blob", data)
+
+	_, err := reg.ReplicateObject(context.Background(), "blob", int64(len(data)), sha256Hex([]byte("other")), 2)
+	assert.Error(t, err)
+
+	// No replica may be left behind when the quorum is not reached
+	for _, p := range providers[1:] {
+		objects, listErr := p.ListObjects(context.Background())
+		require.NoError(t, listErr)
+		assert.Empty(t, objects)
+	}
+}
+
+func TestReplicateObject_FailsOverToTertiary(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	data := []byte("blob")
+	putLocalObject(t, providers[0], "blob", data)
+
+	// Secondary fails every write
+	failing := new(MockObjectStorageProvider)
+	failing.On("PutObject", mock.Anything, "blob", mock.Anything, int64(len(data)), mock.Anything).Return(UploadInfo{}, assert.AnError)
+	failing.On("RemoveObject", mock.Anything, "blob", mock.Anything).Return(nil)
+	reg.SetSecondary(failing, "p2")
+
+	replicas, err := reg.ReplicateObject(context.Background(), "blob", int64(len(data)), sha256Hex(data), 1)
+	require.NoError(t, err)
+	require.Len(t, replicas, 1)
+	assert.Equal(t, "p3", replicas[0].ProviderID)
+}
+
+func TestReplicateObject_NotEnoughProviders(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 2)
+	putLocalObject(t, providers[0], "blob", []byte("blob"))
+
+	_, err := reg.ReplicateObject(context.Background(), "blob", 4, "", 2)
+	assert.Error(t, err)
+}
+
+func TestReplicateObjectFrom_UsesListedTargets(t *testing.T) {
+	reg, providers := newLocalQuorumRegistry(t, 3)
+	data := []byte("blob")
+	putLocalObject(t, providers[2], "blob", data)
+
+	// Land on the tertiary, replicate only to the listed provider; the source
+	// and unknown IDs in the list are ignored
+	replicas, err := reg.ReplicateObjectFrom(context.Background(), "p3", []string{"p3", "unknown", "p1"},
+		"blob", int64(len(data)), sha256Hex(data), 1)
+	require.NoError(t, err)
+	require.Len(t, replicas, 1)
+	assert.Equal(t, "p1", replicas[0].ProviderID)
+
+	_, err = providers[1].HeadObject(context.Background(), "blob")
+	assert.Error(t, err, "providers outside the list are never written")
+
+	_, err = reg.ReplicateObjectFrom(context.Background(), "p3", []string{"p3", "p1"}, "blob", int64(len(data)), "", 2)
+	assert.Error(t, err)
+}
+
+// --- ReplicationTarget tests ---
+
+func TestReplicationTarget(t *testing.T) {
+	reg, _ := newLocalQuorumRegistry(t, 3)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, reg.ConfiguredProviderIDs())
+	assert.Equal(t, 1, reg.ReplicationTarget())
+
+	require.NoError(t, reg.SetWritePolicy(WritePolicyAsyncSecondary, 0))
+	assert.Equal(t, 2, reg.ReplicationTarget())
+
+	require.NoError(t, reg.SetWritePolicy(WritePolicyQuorum, 3))
+	assert.Equal(t, 3, reg.ReplicationTarget())
+
+	// Async-secondary without a secondary cannot keep more than one copy
+	single, _ := newLocalQuorumRegistry(t, 1)
+	require.NoError(t, single.SetWritePolicy(WritePolicyAsyncSecondary, 0))
+	assert.Equal(t, 1, single.ReplicationTarget())
+}
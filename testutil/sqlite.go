@@ -0,0 +1,70 @@
+// Package testutil holds helpers shared by tests in several packages.
+package testutil
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	schemaOnce sync.Once
+	schemaSQL  string
+	schemaErr  error
+)
+
+// unifiedSchema reads database/unified_schema.sql once per test binary.
+func unifiedSchema() (string, error) {
+	schemaOnce.Do(func() {
+		_, file, _, _ := runtime.Caller(0)
+		path := filepath.Join(filepath.Dir(file), "..", "database", "unified_schema.sql")
+		data, err := os.ReadFile(path)
+		schemaSQL, schemaErr = string(data), err
+	})
+	return schemaSQL, schemaErr
+}
+
+// SchemaDB opens an empty SQLite database in a temporary directory and
+// applies database/unified_schema.sql, so tests run against the tables the
+// server creates rather than a hand-copied subset. The database is a file
+// rather than :memory: because some handlers read outside their transaction
+// and so need a second connection. params are extra go-sqlite3 DSN
+// parameters, such as "_foreign_keys=1". It is closed when the test ends.
+func SchemaDB(t testing.TB, params ...string) *sql.DB {
+	t.Helper()
+
+	schema, err := unifiedSchema()
+	if err != nil {
+		t.Fatalf("failed to read unified schema: %v", err)
+	}
+
+	dsn := filepath.Join(t.TempDir(), "arkfile.db") + "?" + strings.Join(append([]string{"_busy_timeout=5000"}, params...), "&")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("failed to apply unified schema: %v", err)
+	}
+	return db
+}
+
+// InsertUsers adds approved accounts with default storage settings.
+func InsertUsers(t testing.TB, db *sql.DB, usernames ...string) {
+	t.Helper()
+	for _, username := range usernames {
+		_, err := db.Exec(`INSERT INTO users (username, username_folded, is_approved) VALUES (?, ?, true)`,
+			username, strings.ToLower(username))
+		if err != nil {
+			t.Fatalf("failed to insert user %s: %v", username, err)
+		}
+	}
+}